@@ -0,0 +1,51 @@
+#!/bin/sh
+# Entrypoint of the Docker image. The mode is the first argument or
+# REPORT_MODE:
+#   oneshot (default)  run a report command, e.g. add https://example.com/article
+#   serve              run the HTTP API on REPORT_ADDR (0.0.0.0:8080), which
+#                      needs API keys in the config file, or -no-auth
+#   daemon             ingest the feeds of REPORT_FEEDS every REPORT_INTERVAL (1h)
+# The remaining arguments are given to the command, before the output folder
+# REPORT_OUTPUT_FOLDER (/reports) for the serve and daemon modes.
+set -eu
+
+output_folder="${REPORT_OUTPUT_FOLDER:-/reports}"
+mode="${REPORT_MODE:-oneshot}"
+case "${1:-}" in
+oneshot | serve | daemon)
+    mode="$1"
+    shift
+    ;;
+esac
+
+case "$mode" in
+oneshot)
+    if [ $# -eq 0 ]; then
+        exec report -h
+    fi
+    exec report "$@"
+    ;;
+serve)
+    exec report serve -addr "${REPORT_ADDR:-0.0.0.0:8080}" "$@" "$output_folder"
+    ;;
+daemon)
+    feeds="${REPORT_FEEDS:-}"
+    if [ -z "$feeds" ]; then
+        echo "Error: REPORT_FEEDS must list the feed urls to ingest, separated by spaces" >&2
+        exit 1
+    fi
+    interval="${REPORT_INTERVAL:-1h}"
+
+    trap 'exit 0' INT TERM
+    while true; do
+        # shellcheck disable=SC2086 # the feeds are split on spaces
+        report ingest "$@" "$output_folder" $feeds || echo "Ingestion failed, next run in $interval" >&2
+        sleep "$interval" &
+        wait $!
+    done
+    ;;
+*)
+    echo "Error: unknown mode '$mode', expected oneshot, serve or daemon" >&2
+    exit 1
+    ;;
+esac
@@ -0,0 +1,28 @@
+#!/bin/sh
+# Entrypoint of the Home Assistant add-on: writes the config file of report
+# from the options of the add-on, then runs the server mode.
+set -eu
+
+options=/data/options.json
+
+# JSON being valid YAML, the config file is written as JSON
+jq '{
+    provider: .provider,
+    providers: ({
+        groq: {api_key: (.groq_api_key // "")},
+        openai: {api_key: (.openai_api_key // "")},
+        anthropic: {api_key: (.anthropic_api_key // "")}
+    } | with_entries(select(.value.api_key != ""))),
+    server: {api_keys: (if (.api_key // "") == "" then [] else [{name: "homeassistant", key: .api_key}] end)}
+}' "$options" > "$REPORT_CONFIG"
+
+output_folder="$(jq -r '.output_folder' "$options")"
+mkdir -p "$output_folder"
+
+# Pages of the local network are only fetched when allowed in the options
+block_private_networks="$(jq -r '.block_private_networks // true' "$options")"
+
+# Without an API key, the API is only open to the local network when allowed
+no_auth="$(jq -r '.no_auth // false' "$options")"
+
+exec report serve -addr 0.0.0.0:8080 -no-auth="$no_auth" -block-private-networks="$block_private_networks" "$output_folder"
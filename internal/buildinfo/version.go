@@ -0,0 +1,44 @@
+// Package buildinfo tells the version of the tool.
+package buildinfo
+
+import "runtime/debug"
+
+// Version is the one the binary gives to cli.Main, set at build time with
+// -ldflags "-X main.version=v1.2.3", else it is read from the module build
+// information.
+var Version = ""
+
+// GetVersion returns the version of the tool: the one set by the binary, else
+// the one of the module build information.
+func GetVersion() string {
+	if Version != "" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return "devel-" + revision
+}
@@ -0,0 +1,13 @@
+// Package errfmt formats the messages of the errors of the tool.
+package errfmt
+
+import "strings"
+
+// WithDiagnostics appends the diagnostics of an error to its message, between
+// parentheses.
+func WithDiagnostics(message string, diagnostics []string) string {
+	if len(diagnostics) == 0 {
+		return message
+	}
+	return message + " (" + strings.Join(diagnostics, ", ") + ")"
+}
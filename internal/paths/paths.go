@@ -0,0 +1,117 @@
+// Package paths locates the directories of the tool: config, cache, data
+// and state, following the conventions of each system.
+package paths
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	APP_FOLDER_NAME  = "report"
+	CONFIG_FILE_NAME = "config.yaml"
+
+	// Folder of the data directory holding the data of the output folders
+	DATA_FOLDERS_FOLDER = "folders"
+	// Marker file of an output folder, holding its ID, which names its data
+	OUTPUT_FOLDER_MARKER = ".report-folder"
+)
+
+// Each directory of the tool can be moved with an environment variable, else
+// it follows the XDG base directory specification on Linux and the BSDs, and
+// the usual locations on macOS and Windows.
+const (
+	CONFIG_DIR_ENV = "REPORT_CONFIG_DIR"
+	CACHE_DIR_ENV  = "REPORT_CACHE_DIR"
+	DATA_DIR_ENV   = "REPORT_DATA_DIR"
+	STATE_DIR_ENV  = "REPORT_STATE_DIR"
+)
+
+// ConfigDir holds the configuration file: $XDG_CONFIG_HOME/report,
+// ~/Library/Application Support/report or %AppData%\report.
+func ConfigDir() (string, error) {
+	return appDir(CONFIG_DIR_ENV, "", os.UserConfigDir)
+}
+
+// CacheDir holds data that can be deleted at any time: $XDG_CACHE_HOME/report,
+// ~/Library/Caches/report or %LocalAppData%\report\cache.
+func CacheDir() (string, error) {
+	return appDir(CACHE_DIR_ENV, "cache", os.UserCacheDir)
+}
+
+// DataDir holds the data kept about the output folders, such as the embeddings
+// of their reports: $XDG_DATA_HOME/report, ~/Library/Application Support/report
+// or %LocalAppData%\report\data.
+func DataDir() (string, error) {
+	return appDir(DATA_DIR_ENV, "data", func() (string, error) {
+		return userDir("XDG_DATA_HOME", ".local/share", "Library/Application Support")
+	})
+}
+
+// StateDir holds the logs and history of the tool: $XDG_STATE_HOME/report,
+// ~/Library/Logs/report or %LocalAppData%\report\state.
+func StateDir() (string, error) {
+	return appDir(STATE_DIR_ENV, "state", func() (string, error) {
+		return userDir("XDG_STATE_HOME", ".local/state", "Library/Logs")
+	})
+}
+
+// ConfigFilePath returns the path of the config file, in the config
+// directory.
+func ConfigFilePath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, CONFIG_FILE_NAME), nil
+}
+
+// appDir returns the directory of the tool inside a base directory. Windows
+// has fewer base directories, so they are shared using a subfolder.
+func appDir(overrideEnv, windowsSubfolder string, baseDir func() (string, error)) (string, error) {
+	if dir := os.Getenv(overrideEnv); dir != "" {
+		return dir, nil
+	}
+	base, err := baseDir()
+	if err != nil {
+		return "", fmt.Errorf("finding user directory, set %s: %w", overrideEnv, err)
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(base, APP_FOLDER_NAME, windowsSubfolder), nil
+	}
+	return filepath.Join(base, APP_FOLDER_NAME), nil
+}
+
+// userDir returns a base directory the standard library has no function for.
+func userDir(xdgEnv, unixPath, darwinPath string) (string, error) {
+	if runtime.GOOS == "windows" {
+		return os.UserCacheDir()
+	}
+	if dir := os.Getenv(xdgEnv); dir != "" && filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(home, darwinPath), nil
+	}
+	return filepath.Join(home, unixPath), nil
+}
+
+// ExpandHome replaces the ~ prefix of a path by the home directory of the
+// user.
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
@@ -0,0 +1,416 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+// ReportFlags are the flags of the commands creating reports.
+type ReportFlags struct {
+	// Config file the defaults and the credentials of the exports are read
+	// from
+	config          Config
+	referencesFile  *string
+	referenceFormat *string
+	contentType     *string
+	template        *string
+	typeTemplates   scrape.ContentTypeFiles
+	typePrompts     scrape.ContentTypeFiles
+	profile         *string
+	summaryLanguage *string
+	provider        *ProviderFlags
+	scraper         *scrape.Scraper
+	permissions     *export.FilePermissions
+	events          *report.ProgressEvents
+	format          *string
+	lineEndings     *string
+	bom             *bool
+	frontmatter     *string
+	filtersFile     *string
+	constraints     summarize.SummaryConstraints
+	chunkTokens     *int
+	includeContent  *string
+	excerptMode     *string
+	excerptLength   *int
+	cover           *string
+	tables          *bool
+	checkArchive    *bool
+	downloadImages  *bool
+	describeImages  *int
+	visionModel     *string
+	concurrency     *int
+	hostDelay       *time.Duration
+	force           *bool
+	slug            *bool
+	export          ExportTargets
+	notionDb        *string
+	noteLinks       export.NoteLinks
+	// Only defined for the commands summarizing the urls of the user, see
+	// addPreviewFlags
+	dryRun *bool
+	diff   *bool
+	review *bool
+}
+
+func addReportFlags(flags *flag.FlagSet, config Config) *ReportFlags {
+	f := &ReportFlags{config: config, typeTemplates: scrape.ContentTypeFiles{}, typePrompts: scrape.ContentTypeFiles{}, scraper: scrape.New()}
+	f.referencesFile = flags.String("references", "", "append a citation of the article to this references file (.bib or .json)")
+	f.referenceFormat = flags.String("reference-format", "", "format of the references file, bibtex or csl-json, guessed from its extension if not set")
+	f.contentType = flags.String("content-type", "", "content type of the page ("+strings.Join(scrape.ContentTypes, ", ")+"), detected if not set")
+	f.template = flags.String("template", config.Template, "use this report template, a Go text/template, for all content types")
+	flags.Var(f.typeTemplates, "type-template", "use this report template for a content type, as type=path (repeatable)")
+	flags.Var(f.typePrompts, "type-prompt", "use this prompt for a content type, as type=path (repeatable)")
+	f.profile = flags.String("profile", cmp.Or(config.Profile, summarize.DEFAULT_PROFILE), "prompt profile, the summary style, embedded or from the profiles folder of the config directory (see report profiles)")
+	f.summaryLanguage = flags.String("summary-lang", config.SummaryLanguage, "language of the summaries, as a code or a name (e.g. en, French), or "+summarize.SUMMARY_LANGUAGE_SOURCE+" for the language of the article, whatever the LLM picks if not set")
+	f.provider = addProviderFlags(flags, config)
+	f.format = flags.String("format", "", "comma separated list of output formats ("+strings.Join(export.OutputFormats(), ", ")+"), "+export.OUTPUT_FORMAT_MARKDOWN+" if no -export target writes files or prints the reports")
+	f.lineEndings = flags.String("line-endings", export.LINE_ENDINGS_LF, "line endings of the text reports, lf or crlf")
+	f.bom = flags.Bool("bom", false, "start the text reports with a UTF-8 byte order mark")
+	f.frontmatter = flags.String("frontmatter", export.FRONTMATTER_TEMPLATE, "frontmatter of the markdown reports, "+export.FRONTMATTER_TEMPLATE+" or "+export.FRONTMATTER_YAML+" (Obsidian compatible)")
+	f.filtersFile = flags.String("filters", "", "skip or flag articles matching the rules of this filter file")
+	flags.Var(&f.constraints.Keypoints, "keypoints", "number of keypoints, as min-max (e.g. 5-8), min- or an exact count")
+	flags.Var(&f.constraints.Tags, "tags", "number of tags, as min-max (e.g. 3-5), min- or an exact count")
+	flags.Func("max-tags", "maximum number of tags, like -tags -N", func(value string) error {
+		maxTags, err := strconv.Atoi(value)
+		if err != nil || maxTags <= 0 {
+			return fmt.Errorf("invalid number of tags '%s', expected a positive number", value)
+		}
+		f.constraints.Tags.Max = maxTags
+		return nil
+	})
+	flags.StringVar(&f.constraints.Length, "summary-length", "", "length of the summary: "+summarize.SUMMARY_LENGTH_SHORT+", "+summarize.SUMMARY_LENGTH_MEDIUM+" or "+summarize.SUMMARY_LENGTH_LONG+", the one of the prompt profile if not set")
+	f.includeContent = flags.String("include-content", export.INCLUDE_CONTENT_NONE, "text of the article included in the reports: "+export.INCLUDE_CONTENT_NONE+", "+export.INCLUDE_CONTENT_EXCERPT+" (its first paragraphs), "+export.INCLUDE_CONTENT_FULL+" or "+export.INCLUDE_CONTENT_MARKDOWN+" (the full article with its headings, lists, links and code blocks)")
+	f.excerptMode = flags.String("excerpt", summarize.EXCERPT_PARAGRAPHS, "how the excerpt is made: "+summarize.EXCERPT_PARAGRAPHS+" (the first paragraphs, without bylines and ads) or "+summarize.EXCERPT_LLM+" (picked by the LLM)")
+	f.excerptLength = flags.Int("excerpt-length", summarize.DEFAULT_EXCERPT_LENGTH, "maximum length of the excerpt, in characters")
+	f.cover = flags.String("cover", export.COVER_NONE, "cover image of the reports, for card previews: "+export.COVER_NONE+", "+export.COVER_LINK+" (its url) or "+export.COVER_DOWNLOAD+" (a copy in the "+export.COVERS_FOLDER_NAME+" folder)")
+	f.tables = flags.Bool("tables", false, "include the data tables of the articles, e.g. the figures of their charts, in the reports as markdown tables")
+	f.downloadImages = flags.Bool("download-images", false, "save the images of the articles in the "+export.ASSETS_FOLDER_NAME+" folder of the output folder, the reports referencing the local copies")
+	f.checkArchive = flags.Bool("check-archive", false, "compare the claims of the articles with the related reports of the output folder, found with the embeddings, and list the reports they contradict or update")
+	f.describeImages = flags.Int("describe-images", 0, "describe the first N images of the articles with a vision model, for charts and diagrams, 0 to disable")
+	f.visionModel = flags.String("vision-model", "", "model of the first LLM provider describing the images, reading images, instead of the model of the summaries")
+	f.chunkTokens = flags.Int("chunk-tokens", summarize.DEFAULT_CHUNK_TOKENS, "summarize articles longer than this number of tokens, counted with the tokenizer of the model, in chunks, 0 to disable")
+	f.permissions = addPermissionFlags(flags)
+	addScraperFlags(flags, f.scraper, config)
+	addLogFlags(flags)
+	f.events = addEventFlags(flags, config.Events)
+	f.concurrency = flags.Int("concurrency", max(1, config.Concurrency), "number of articles processed in parallel")
+	f.hostDelay = flags.Duration("host-delay", time.Second, "minimum delay between two requests to the same host")
+	f.force = flags.Bool("force", false, "summarize the articles already summarized into the output folder again")
+	flags.Var(&f.export, "export", "comma separated list of targets the reports are written to, "+exportTargets()+" (repeatable)")
+	f.notionDb = flags.String("notion-db", config.Notion.DatabaseId, "id or url of the Notion database the pages are created in, for -export "+export.EXPORT_NOTION)
+	addNoteLinkFlags(flags, &f.noteLinks, config.NoteLinks)
+	f.slug = flags.Bool("slug", false, "name the reports after the slug of their title (e.g. my-article-2.md) instead of the title, never asking for a file name")
+	return f
+}
+
+// addPreviewFlags adds the flags printing the reports instead of writing
+// them, for the commands summarizing the urls given by the user.
+func (f *ReportFlags) addPreviewFlags(flags *flag.FlagSet) {
+	f.dryRun = flags.Bool("dry-run", false, "extract and summarize the articles, but print the markdown reports instead of writing or exporting anything")
+	f.diff = flags.Bool("diff", false, "with -dry-run, print the changes to the existing reports as a unified diff")
+	f.review = flags.Bool("review", false, "show the title, summary, keypoints and tags of each article before writing its report, to edit them or summarize the article again")
+}
+
+// options validates the flags and returns the options of the reports.
+func (f *ReportFlags) options(outputFolder string) (report.ReportOptions, error) {
+	if *f.contentType != "" && !scrape.IsKnownContentType(*f.contentType) {
+		return report.ReportOptions{}, fmt.Errorf("unknown content type '%s'", *f.contentType)
+	}
+	if *f.frontmatter != export.FRONTMATTER_TEMPLATE && *f.frontmatter != export.FRONTMATTER_YAML {
+		return report.ReportOptions{}, fmt.Errorf("unknown frontmatter '%s', expected %s or %s", *f.frontmatter, export.FRONTMATTER_TEMPLATE, export.FRONTMATTER_YAML)
+	}
+	if err := export.ValidateIncludeContent(*f.includeContent); err != nil {
+		return report.ReportOptions{}, err
+	}
+	if err := summarize.ValidateExcerptMode(*f.excerptMode); err != nil {
+		return report.ReportOptions{}, err
+	}
+	if err := export.ValidateCoverMode(*f.cover); err != nil {
+		return report.ReportOptions{}, err
+	}
+	if *f.describeImages < 0 {
+		return report.ReportOptions{}, fmt.Errorf("invalid number of images %d, expected a positive number or 0", *f.describeImages)
+	}
+	if *f.excerptLength <= 0 {
+		return report.ReportOptions{}, fmt.Errorf("invalid excerpt length %d, expected a positive number of characters", *f.excerptLength)
+	}
+	if err := f.scraper.Load(); err != nil {
+		return report.ReportOptions{}, err
+	}
+	if err := summarize.ValidateSummaryLength(f.constraints.Length); err != nil {
+		return report.ReportOptions{}, err
+	}
+	if tags := f.constraints.Tags; tags.Max > 0 && tags.Min > tags.Max {
+		return report.ReportOptions{}, fmt.Errorf("-max-tags %d is lower than the minimum of -tags %d", tags.Max, tags.Min)
+	}
+	if err := f.events.Open(f.config.Webhook, f.scraper.Retry, f.scraper.Policy.Timeout); err != nil {
+		return report.ReportOptions{}, err
+	}
+	if err := f.noteLinks.Validate(); err != nil {
+		return report.ReportOptions{}, err
+	}
+
+	options := report.ReportOptions{
+		OutputFolder:    outputFolder,
+		Scraper:         f.scraper,
+		Permissions:     *f.permissions,
+		HostLimiter:     scrape.NewHostLimiter(*f.hostDelay),
+		ContentType:     *f.contentType,
+		Frontmatter:     *f.frontmatter,
+		Template:        *f.template,
+		TypeTemplates:   f.typeTemplates,
+		TypePrompts:     f.typePrompts,
+		Constraints:     f.constraints,
+		ChunkTokens:     *f.chunkTokens,
+		IncludeContent:  *f.includeContent,
+		ExcerptMode:     *f.excerptMode,
+		ExcerptLength:   *f.excerptLength,
+		CoverMode:       *f.cover,
+		Tables:          *f.tables,
+		DownloadImages:  *f.downloadImages,
+		DescribeImages:  *f.describeImages,
+		ReferencesFile:  *f.referencesFile,
+		ReferenceFormat: *f.referenceFormat,
+		Force:           *f.force,
+		Slug:            *f.slug,
+		NoteLinks:       f.noteLinks,
+		Events:          f.events,
+	}
+	if f.dryRun != nil {
+		options.DryRun, options.PreviewDiff = *f.dryRun, *f.diff
+		if options.PreviewDiff && !options.DryRun {
+			return report.ReportOptions{}, fmt.Errorf("-diff only applies with -dry-run")
+		}
+		options.Review = *f.review
+		if options.Review && !summarize.IsTerminal(os.Stdin) {
+			return report.ReportOptions{}, fmt.Errorf("-review needs a terminal to answer on the standard input")
+		}
+	}
+
+	var err error
+	options.Profile = *f.profile
+	options.SystemPrompt, err = summarize.GetProfilePrompt(*f.profile)
+	if err != nil {
+		return report.ReportOptions{}, err
+	}
+	options.SummaryLanguage, err = summarize.ParseSummaryLanguage(*f.summaryLanguage)
+	if err != nil {
+		return report.ReportOptions{}, err
+	}
+
+	if *f.format != "" {
+		options.Formats, err = export.ParseOutputFormats(*f.format)
+		if err != nil {
+			return report.ReportOptions{}, err
+		}
+	}
+
+	for _, target := range f.export {
+		webhookUrl, isWebhook := strings.CutPrefix(target, export.EXPORT_WEBHOOK+"=")
+		switch {
+		case target == report.EXPORT_STDOUT:
+			options.Stdout = true
+		case target == export.EXPORT_NOTION:
+			options.Notion, err = export.NewNotionExporter(*f.notionDb, f.config.Notion, f.scraper.Retry, f.scraper.Policy.Timeout)
+			if err != nil {
+				return report.ReportOptions{}, err
+			}
+		case target == export.EXPORT_KARAKEEP:
+			options.Karakeep, err = export.NewKarakeepClient(f.config.Karakeep, f.scraper.Retry, f.scraper.Policy.Timeout)
+			if err != nil {
+				return report.ReportOptions{}, err
+			}
+		case target == export.EXPORT_WALLABAG:
+			options.Wallabag, err = export.NewWallabagClient(f.config.Wallabag, f.scraper.Retry, f.scraper.Policy.Timeout)
+			if err != nil {
+				return report.ReportOptions{}, err
+			}
+		case target == export.EXPORT_WEBHOOK || isWebhook:
+			webhook, err := export.NewWebhookExporter(webhookUrl, f.config.Webhook, f.scraper.Retry, f.scraper.Policy.Timeout)
+			if err != nil {
+				return report.ReportOptions{}, err
+			}
+			options.Webhooks = append(options.Webhooks, webhook)
+		default:
+			formats, err := export.ParseOutputFormats(target)
+			if err != nil {
+				return report.ReportOptions{}, fmt.Errorf("unknown export '%s', expected %s", target, exportTargets())
+			}
+			if !slices.Contains(options.Formats, formats[0]) {
+				options.Formats = append(options.Formats, formats[0])
+			}
+		}
+	}
+	// The reports are written as markdown unless the targets say otherwise
+	if len(options.Formats) == 0 && !options.Stdout {
+		options.Formats = []string{export.OUTPUT_FORMAT_MARKDOWN}
+	}
+
+	options.Encoding, err = export.NewOutputEncoding(*f.lineEndings, *f.bom)
+	if err != nil {
+		return report.ReportOptions{}, err
+	}
+
+	if *f.filtersFile != "" {
+		options.Filters, err = report.LoadContentFilters(*f.filtersFile)
+		if err != nil {
+			return report.ReportOptions{}, err
+		}
+	}
+
+	// The LLM calls are retried and cached like the fetches
+	f.provider.options.Retry = f.scraper.Retry
+	f.scraper.Cache.Disabled = f.provider.options.Cache.Disabled
+	options.Provider, err = f.provider.provider()
+	if err != nil {
+		return report.ReportOptions{}, err
+	}
+	options.Tokenizers, err = summarize.NewTokenizers(f.config.Tokenizers)
+	if err != nil {
+		return report.ReportOptions{}, err
+	}
+	options.VisionProvider = options.Provider
+	if options.DescribeImages > 0 && *f.visionModel != "" {
+		options.VisionProvider, err = summarize.NewVisionProvider(*f.provider.name, *f.visionModel, f.provider.options)
+		if err != nil {
+			return report.ReportOptions{}, err
+		}
+	}
+
+	if outputFolder != "" {
+		options.Index, err = export.LoadArticleIndex(outputFolder, options.Permissions)
+		if err != nil {
+			return report.ReportOptions{}, err
+		}
+	}
+	if *f.checkArchive {
+		options.ArchiveChecker, err = export.NewArchiveChecker(context.Background(), outputFolder, options.Permissions)
+		if err != nil {
+			return report.ReportOptions{}, err
+		}
+	}
+
+	return options, nil
+}
+
+// runAdd creates the reports of urls, it is also the default command.
+func runAdd(config Config, args []string) error {
+	flags := flag.NewFlagSet("add", flag.ExitOnError)
+	reportFlags := addReportFlags(flags, config)
+	reportFlags.addPreviewFlags(flags)
+	fromFile := flags.String("from-file", "", "also process the urls listed in this file, one per line")
+	flags.Usage = func() {
+		fmt.Println("Usage: report add [options] <output-folder> <url|page.html|->...")
+		fmt.Println("       report add [options] -from-file urls.txt <output-folder> [<url>...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	// The output folder can be omitted when set in the config file, urls
+	// being told apart from it by their scheme, and saved pages by their
+	// extension
+	args = flags.Args()
+	if len(args) == 0 || scrape.LooksLikeArticleInput(args[0]) {
+		args = config.withDefaultOutputFolder(args, len(args)+1)
+	}
+	if len(args) < 1 || (len(args) < 2 && *fromFile == "") {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder and urls")
+	}
+
+	articleUrls := args[1:]
+	if *fromFile != "" {
+		fileUrls, err := readUrlsFile(*fromFile)
+		if err != nil {
+			return err
+		}
+		articleUrls = append(articleUrls, fileUrls...)
+	}
+
+	return createReports(args[0], articleUrls, reportFlags)
+}
+
+// runBatch creates the reports of the urls listed in files.
+func runBatch(config Config, args []string) error {
+	flags := flag.NewFlagSet("batch", flag.ExitOnError)
+	reportFlags := addReportFlags(flags, config)
+	reportFlags.addPreviewFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report batch [options] <output-folder> <urls-file>...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = flags.Args()
+	if len(args) == 1 {
+		args = config.withDefaultOutputFolder(args, 2)
+	}
+	if len(args) < 2 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder and urls files")
+	}
+
+	var articleUrls []string
+	for _, path := range args[1:] {
+		fileUrls, err := readUrlsFile(path)
+		if err != nil {
+			return err
+		}
+		articleUrls = append(articleUrls, fileUrls...)
+	}
+	if len(articleUrls) == 0 {
+		return fmt.Errorf("no url found in %s", strings.Join(args[1:], ", "))
+	}
+
+	return createReports(args[0], articleUrls, reportFlags)
+}
+
+func createReports(outputFolder string, articleUrls []string, reportFlags *ReportFlags) error {
+	options, err := reportFlags.options(outputFolder)
+	if err != nil {
+		return err
+	}
+	defer options.Events.Close()
+	options.AllowLocalInput = true
+	// The standard input holds the page, not the answers of the user
+	if slices.Contains(articleUrls, scrape.STDIN_INPUT) {
+		if options.Review {
+			return fmt.Errorf("-review cannot be used with a page read from the standard input")
+		}
+		options.NonInteractive = true
+	}
+	// Nobody can answer the prompts without a terminal, e.g. in a cron job
+	if !summarize.IsTerminal(os.Stdin) {
+		options.NonInteractive = true
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	ctx = summarize.WithTerminalProgress(ctx, *reportFlags.provider.progress, *reportFlags.concurrency)
+
+	client, err := report.New(report.WithReportOptions(options))
+	if err != nil {
+		return err
+	}
+	if len(articleUrls) == 1 {
+		return client.Create(ctx, articleUrls[0]).Err
+	}
+
+	results := client.CreateAll(ctx, articleUrls, *reportFlags.concurrency)
+	if !printBatchSummary(results) {
+		return fmt.Errorf("some articles failed")
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const (
+	DEFAULT_ARCHIVEBOX_INTERVAL = 10 * time.Minute
+	// Folder of the snapshots in the data folder of ArchiveBox, one folder per
+	// snapshot named after its timestamp
+	ARCHIVEBOX_ARCHIVE_FOLDER = "archive"
+	ARCHIVEBOX_INDEX_FILE     = "index.json"
+	// Prefix of the ArchiveBox data folders in the feed state
+	ARCHIVEBOX_STATE_PREFIX = "archivebox:"
+)
+
+// Outputs of a snapshot holding the page, in order of preference: the page
+// saved by SingleFile, then the DOM dumped by Chrome. The WARC archive of the
+// snapshot is read when neither exists.
+var archiveBoxPageFiles = []string{"singlefile.html", "output.html"}
+
+// ArchiveBoxSnapshot is a page archived by ArchiveBox, as described by the
+// index.json file of its folder.
+type ArchiveBoxSnapshot struct {
+	Url string `json:"url"`
+	// Unix time the page was added, with decimals, e.g. 1612345678.123
+	Timestamp string `json:"timestamp"`
+	Title     string `json:"title"`
+	// Comma separated, or a list in some versions
+	Tags json.RawMessage `json:"tags"`
+	// Folder of the snapshot
+	dir string
+}
+
+func runArchiveBox(config Config, args []string) error {
+	flags := flag.NewFlagSet("archivebox", flag.ExitOnError)
+	interval := flags.Duration("interval", DEFAULT_ARCHIVEBOX_INTERVAL, "delay between two scans of the data folder")
+	limit := flags.Int("n", 10, "maximum number of new snapshots summarized per scan, oldest first, 0 for all")
+	once := flags.Bool("once", false, "scan the data folder once and exit, e.g. from cron")
+	serverUrl := flags.String("url", config.ArchiveBox.Url, "address of the ArchiveBox server, e.g. http://localhost:8000, to link the reports to the snapshots on it rather than to their files")
+	reportFlags := addReportFlags(flags, config)
+	flags.Usage = func() {
+		fmt.Println("Usage: report archivebox [-interval 10m] [-n 10] [-once] [-url http://localhost:8000] [options] <output-folder> <archivebox-data-folder>")
+		fmt.Println("Watch the data folder of ArchiveBox, summarizing each newly archived page from its snapshot and linking the report to the snapshot.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 2)
+	if len(args) != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder and the ArchiveBox data folder")
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("invalid interval %s, expected a positive duration", *interval)
+	}
+	outputFolder, dataFolder := args[0], args[1]
+	if _, err := os.Stat(filepath.Join(dataFolder, ARCHIVEBOX_ARCHIVE_FOLDER)); err != nil {
+		return fmt.Errorf("not an ArchiveBox data folder: %w", err)
+	}
+
+	options, err := reportFlags.options(outputFolder)
+	if err != nil {
+		return err
+	}
+	defer options.Events.Close()
+	options.NonInteractive = true
+	client, err := report.New(report.WithReportOptions(options))
+	if err != nil {
+		return err
+	}
+
+	state, err := loadFeedState(outputFolder)
+	if err != nil {
+		return err
+	}
+	stateKey := ARCHIVEBOX_STATE_PREFIX + dataFolder
+	if absolute, err := filepath.Abs(dataFolder); err == nil {
+		stateKey = ARCHIVEBOX_STATE_PREFIX + absolute
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	ctx = summarize.WithTerminalProgress(ctx, *reportFlags.provider.progress, *reportFlags.concurrency)
+
+	for {
+		err := summarizeArchiveBoxSnapshots(ctx, dataFolder, strings.TrimSuffix(*serverUrl, "/"), stateKey, state, client, *limit, *reportFlags.concurrency)
+		if *once {
+			return err
+		}
+		if err != nil {
+			slog.Error("scanning ArchiveBox failed", "folder", dataFolder, "err", err)
+		}
+		if ctx.Err() != nil {
+			slog.Info("Stopped, the snapshots not summarized yet are picked up by the next run")
+			return nil
+		}
+
+		next := time.Now().Add(*interval)
+		slog.Info("Next scan", "at", next)
+		select {
+		case <-ctx.Done():
+			slog.Info("Stopped")
+			return nil
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// summarizeArchiveBoxSnapshots summarizes the snapshots of the data folder
+// that are neither summarized nor recorded in the feed state, and records
+// them once summarized or skipped.
+func summarizeArchiveBoxSnapshots(ctx context.Context, dataFolder, serverUrl, stateKey string, state *FeedState, client *report.Client, limit, concurrency int) error {
+	options := client.Options()
+	snapshots, err := listArchiveBoxSnapshots(dataFolder)
+	if err != nil {
+		return err
+	}
+
+	// The latest snapshot of a page wins
+	latest := map[string]ArchiveBoxSnapshot{}
+	var snapshotUrls []string
+	for _, snapshot := range snapshots {
+		if _, ok := latest[snapshot.Url]; !ok {
+			snapshotUrls = append(snapshotUrls, snapshot.Url)
+		}
+		latest[snapshot.Url] = snapshot
+	}
+
+	processed := map[string]bool{}
+	for _, snapshotUrl := range state.Feeds[stateKey] {
+		processed[snapshotUrl] = true
+	}
+	index := options.Index
+	if options.Force {
+		index = nil
+	}
+	newUrls := newFeedEntries(snapshotUrls, processed, index, 0)
+
+	// Snapshots still being archived have no page yet, they are picked up
+	// by a next scan
+	archivedPages := map[string]scrape.ArchivedPage{}
+	addedDates := map[string]time.Time{}
+	importedTags := map[string][]string{}
+	var articleUrls []string
+	for _, snapshotUrl := range newUrls {
+		if limit > 0 && len(articleUrls) == limit {
+			break
+		}
+		snapshot := latest[snapshotUrl]
+		page, err := snapshot.page(serverUrl)
+		if err != nil {
+			slog.Debug("Snapshot without page yet", "url", snapshot.Url, "folder", snapshot.dir, "err", err)
+			continue
+		}
+		articleUrls = append(articleUrls, snapshot.Url)
+		archivedPages[snapshot.Url] = page
+		addedDates[snapshot.Url] = page.Archived
+		importedTags[snapshot.Url] = snapshot.tags()
+	}
+	slog.Info("ArchiveBox scanned", "folder", dataFolder, "snapshots", len(snapshots), "new", len(articleUrls))
+	if len(articleUrls) == 0 {
+		return nil
+	}
+
+	results := client.Derive(func(options *report.ReportOptions) {
+		options.ArchivedPages = archivedPages
+		options.Feed = serverUrl
+		options.AddedDates = addedDates
+		options.ImportedTags = importedTags
+	}).CreateAll(ctx, articleUrls, concurrency)
+	failed := !printBatchSummary(results)
+
+	// Failed snapshots are left out so that they are retried on the next scan
+	for _, result := range results {
+		if result.Status != report.RESULT_FAILED {
+			state.Feeds[stateKey] = append(state.Feeds[stateKey], result.Url)
+		}
+	}
+	if err := state.save(options.OutputFolder, options.Permissions); err != nil {
+		return err
+	}
+	if failed {
+		return fmt.Errorf("some snapshots failed")
+	}
+	return nil
+}
+
+// listArchiveBoxSnapshots returns the snapshots of an ArchiveBox data
+// folder, oldest first.
+func listArchiveBoxSnapshots(dataFolder string) ([]ArchiveBoxSnapshot, error) {
+	archiveFolder := filepath.Join(dataFolder, ARCHIVEBOX_ARCHIVE_FOLDER)
+	entries, err := os.ReadDir(archiveFolder)
+	if err != nil {
+		return nil, fmt.Errorf("reading ArchiveBox archive folder: %w", err)
+	}
+
+	var snapshots []ArchiveBoxSnapshot
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(archiveFolder, entry.Name())
+		data, err := os.ReadFile(filepath.Join(dir, ARCHIVEBOX_INDEX_FILE))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading ArchiveBox snapshot: %w", err)
+		}
+		var snapshot ArchiveBoxSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			slog.Warn("skipping ArchiveBox snapshot", "folder", dir, "err", err)
+			continue
+		}
+		if !strings.HasPrefix(snapshot.Url, "http://") && !strings.HasPrefix(snapshot.Url, "https://") {
+			continue
+		}
+		snapshot.Timestamp = cmp.Or(snapshot.Timestamp, entry.Name())
+		snapshot.dir = dir
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].archived().Before(snapshots[j].archived())
+	})
+	return snapshots, nil
+}
+
+// archived returns the time the page was added to ArchiveBox.
+func (s ArchiveBoxSnapshot) archived() time.Time {
+	seconds, err := strconv.ParseFloat(s.Timestamp, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))).UTC()
+}
+
+// tags returns the tags of the snapshot, written like the tags of the reports.
+func (s ArchiveBoxSnapshot) tags() []string {
+	var names []string
+	var list string
+	if json.Unmarshal(s.Tags, &list) == nil {
+		names = strings.Split(list, ",")
+	} else {
+		json.Unmarshal(s.Tags, &names)
+	}
+	var tags []string
+	for _, name := range names {
+		if tag := reportTag(name); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// page returns the archived page of the snapshot, linked to the snapshot on
+// the server when its url is given, else to its files.
+func (s ArchiveBoxSnapshot) page(serverUrl string) (scrape.ArchivedPage, error) {
+	page := scrape.ArchivedPage{Url: s.Url, Archived: s.archived(), ArchiveUrl: scrape.FileUrl(filepath.Join(s.dir, "index.html"))}
+	if serverUrl != "" {
+		page.ArchiveUrl = serverUrl + "/" + ARCHIVEBOX_ARCHIVE_FOLDER + "/" + filepath.Base(s.dir) + "/index.html"
+	}
+
+	for _, name := range archiveBoxPageFiles {
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return scrape.ArchivedPage{}, err
+		}
+		saved, err := scrape.ReadSavedPage(data)
+		if err != nil {
+			return scrape.ArchivedPage{}, err
+		}
+		page.Html = saved.Html
+		return page, nil
+	}
+
+	warcFiles, _ := filepath.Glob(filepath.Join(s.dir, "warc", "*.warc*"))
+	match := regexp.MustCompile("^" + regexp.QuoteMeta(s.Url) + "$")
+	for _, warcFile := range warcFiles {
+		pages, err := scrape.ReadWarcFile(warcFile, match)
+		if err != nil {
+			return scrape.ArchivedPage{}, err
+		}
+		if len(pages) > 0 {
+			page.Html = pages[len(pages)-1].Html
+			return page, nil
+		}
+	}
+	return scrape.ArchivedPage{}, fmt.Errorf("no page in the snapshot")
+}
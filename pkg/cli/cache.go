@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/brequet/report/internal/paths"
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// addCacheFlags adds the flag disabling a cache to a flag set, see
+// addProviderFlags.
+func addCacheFlags(flags *flag.FlagSet, cache *scrape.ResponseCache) {
+	flags.BoolVar(&cache.Disabled, "no-cache", false, "neither read nor write the cache of the fetched pages and the LLM answers")
+}
+
+// addPageCacheFlags adds the flag setting how long the cached pages of a
+// cache are used without asking the server to a flag set.
+func addPageCacheFlags(flags *flag.FlagSet, cache *scrape.ResponseCache) {
+	flags.DurationVar(&cache.PageMaxAge, "page-cache-age", scrape.DEFAULT_PAGE_CACHE_AGE, "age under which a cached page is used without fetching it, older ones being fetched again unless the server tells they did not change")
+}
+
+func runCache(config Config, args []string) error {
+	flags := flag.NewFlagSet("cache", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: report cache [clear]")
+		fmt.Println("Print the size of the cache of the fetched pages, the LLM answers and the icons of the sites, or clear it.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return fmt.Errorf("finding cache directory: %w", err)
+	}
+	folders := []string{scrape.PAGE_CACHE_FOLDER, scrape.LLM_CACHE_FOLDER, ICON_CACHE_FOLDER}
+
+	switch {
+	case flags.NArg() == 0:
+		fmt.Printf("Cache: %s\n", dir)
+		for _, folder := range folders {
+			entries, size, err := folderSize(filepath.Join(dir, folder))
+			if err != nil {
+				return fmt.Errorf("reading cache: %w", err)
+			}
+			fmt.Printf("  %-6s %d entries, %.1f MB\n", folder, entries, float64(size)/1e6)
+		}
+		return nil
+	case flags.NArg() == 1 && flags.Arg(0) == "clear":
+		for _, folder := range folders {
+			if err := os.RemoveAll(filepath.Join(dir, folder)); err != nil {
+				return export.NewWriteError("removing", filepath.Join(dir, folder), err)
+			}
+		}
+		fmt.Printf("Cache cleared: %s\n", dir)
+		return nil
+	}
+	flags.Usage()
+	return fmt.Errorf("unknown cache command '%s', expected clear", flags.Arg(0))
+}
+
+// folderSize returns the number of files of a folder and their total size, 0
+// for a missing folder.
+func folderSize(folder string) (int, int64, error) {
+	entries, size := 0, int64(0)
+	err := filepath.WalkDir(folder, func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == folder {
+			return filepath.SkipDir
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		entries++
+		size += info.Size()
+		return nil
+	})
+	return entries, size, err
+}
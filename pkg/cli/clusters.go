@@ -0,0 +1,269 @@
+package cli
+
+import (
+	"context"
+	_ "embed"
+	"flag"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const (
+	CLUSTERS_MAX_ITERATIONS = 100
+	CLUSTERS_INDEX_FOLDER   = "topics"
+)
+
+//go:embed cluster-prompt.md
+var clusterPrompt string
+
+type Cluster struct {
+	Label       string          `json:"label"`
+	Description string          `json:"description"`
+	Reports     []export.Report `json:"-"`
+}
+
+func runClusters(config Config, args []string) error {
+	flags := flag.NewFlagSet("clusters", flag.ExitOnError)
+	k := flags.Int("k", 0, "number of clusters, estimated from the archive size if 0")
+	write := flags.Bool("write", false, "write one index page per cluster into the output folder")
+	indexFolder := flags.String("index-folder", CLUSTERS_INDEX_FOLDER, "folder, relative to the output folder, receiving the cluster index pages")
+	providerFlags := addProviderFlags(flags, config)
+	permissions := addPermissionFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report clusters [-k 0] [-write] [-index-folder topics] <output-folder>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder")
+	}
+	outputFolder := args[0]
+
+	provider, err := providerFlags.provider()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	_, reports, store, err := export.LoadEmbeddedReports(ctx, outputFolder, *permissions)
+	if err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		fmt.Println("No report found")
+		return nil
+	}
+
+	vectors := make([][]float64, len(reports))
+	for i, report := range reports {
+		vectors[i] = store.Entries[export.ReportFileName(report)].Vector
+	}
+
+	clusterCount := *k
+	if clusterCount <= 0 {
+		clusterCount = int(math.Round(math.Sqrt(float64(len(reports)) / 2)))
+	}
+	clusterCount = max(1, min(clusterCount, len(reports)))
+
+	assignments := kMeans(vectors, clusterCount)
+
+	clusters := make([]Cluster, clusterCount)
+	for i, report := range reports {
+		clusters[assignments[i]].Reports = append(clusters[assignments[i]].Reports, report)
+	}
+	clusters = removeEmptyClusters(clusters)
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return len(clusters[i].Reports) > len(clusters[j].Reports)
+	})
+
+	for i := range clusters {
+		if err := labelCluster(ctx, &clusters[i], provider); err != nil {
+			return fmt.Errorf("labeling cluster %d: %w", i+1, err)
+		}
+		if !export.IsValidWindowsFilename(clusters[i].Label) {
+			clusters[i].Label = fmt.Sprintf("Topic %d", i+1)
+		}
+	}
+	deduplicateClusterLabels(clusters)
+
+	fmt.Print(renderTopicMap(clusters))
+
+	if *write {
+		folder := filepath.Join(outputFolder, *indexFolder)
+		if err := writeClusterIndexPages(folder, *permissions, clusters); err != nil {
+			return err
+		}
+		fmt.Printf("Cluster index pages written to: %s\n", folder)
+	}
+
+	return nil
+}
+
+// kMeans groups the vectors in k clusters by cosine similarity and returns the
+// cluster of each vector. Centroids are seeded deterministically with the
+// farthest point heuristic so that a given archive always yields the same map.
+func kMeans(vectors [][]float64, k int) []int {
+	normalized := make([][]float64, len(vectors))
+	for i, vector := range vectors {
+		normalized[i] = normalizeVector(vector)
+	}
+
+	centroids := [][]float64{normalized[0]}
+	for len(centroids) < k {
+		farthest, farthestSimilarity := 0, math.Inf(1)
+		for i, vector := range normalized {
+			best := math.Inf(-1)
+			for _, centroid := range centroids {
+				best = math.Max(best, dotProduct(vector, centroid))
+			}
+			if best < farthestSimilarity {
+				farthest, farthestSimilarity = i, best
+			}
+		}
+		centroids = append(centroids, normalized[farthest])
+	}
+
+	assignments := make([]int, len(normalized))
+	for iteration := 0; iteration < CLUSTERS_MAX_ITERATIONS; iteration++ {
+		changed := iteration == 0
+		for i, vector := range normalized {
+			best, bestSimilarity := 0, math.Inf(-1)
+			for c, centroid := range centroids {
+				if similarity := dotProduct(vector, centroid); similarity > bestSimilarity {
+					best, bestSimilarity = c, similarity
+				}
+			}
+			if assignments[i] != best {
+				assignments[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		for c := range centroids {
+			sum := make([]float64, len(centroids[c]))
+			members := 0
+			for i, vector := range normalized {
+				if assignments[i] != c {
+					continue
+				}
+				members++
+				for d := range sum {
+					sum[d] += vector[d]
+				}
+			}
+			if members > 0 {
+				centroids[c] = normalizeVector(sum)
+			}
+		}
+	}
+
+	return assignments
+}
+
+func normalizeVector(vector []float64) []float64 {
+	norm := math.Sqrt(dotProduct(vector, vector))
+	normalized := make([]float64, len(vector))
+	if norm == 0 {
+		return normalized
+	}
+	for i, value := range vector {
+		normalized[i] = value / norm
+	}
+	return normalized
+}
+
+func dotProduct(a, b []float64) float64 {
+	var dot float64
+	for i := range min(len(a), len(b)) {
+		dot += a[i] * b[i]
+	}
+	return dot
+}
+
+func removeEmptyClusters(clusters []Cluster) []Cluster {
+	var nonEmpty []Cluster
+	for _, cluster := range clusters {
+		if len(cluster.Reports) > 0 {
+			nonEmpty = append(nonEmpty, cluster)
+		}
+	}
+	return nonEmpty
+}
+
+func labelCluster(ctx context.Context, cluster *Cluster, provider summarize.LLMProvider) error {
+	var lines []string
+	for _, report := range cluster.Reports {
+		lines = append(lines, fmt.Sprintf("- %s (tags: %s)", report.Title, strings.Join(report.Tags, ", ")))
+	}
+
+	_, err := summarize.CompleteJson(ctx, provider, []summarize.ChatMessage{
+		{Role: "system", Content: clusterPrompt},
+		{Role: "user", Content: strings.Join(lines, "\n")},
+	}, cluster)
+	if err != nil {
+		return fmt.Errorf("labeling cluster: %w", err)
+	}
+	cluster.Label = strings.TrimSpace(cluster.Label)
+	cluster.Description = strings.TrimSpace(cluster.Description)
+
+	return nil
+}
+
+// deduplicateClusterLabels numbers the clusters sharing a label, as each label
+// names an index page.
+func deduplicateClusterLabels(clusters []Cluster) {
+	seen := map[string]int{}
+	for i := range clusters {
+		key := strings.ToLower(clusters[i].Label)
+		seen[key]++
+		if seen[key] > 1 {
+			clusters[i].Label = fmt.Sprintf("%s %d", clusters[i].Label, seen[key])
+		}
+	}
+}
+
+func renderTopicMap(clusters []Cluster) string {
+	var sb strings.Builder
+	sb.WriteString("# Topic map\n")
+	for _, cluster := range clusters {
+		fmt.Fprintf(&sb, "\n## %s (%d)\n%s\n", cluster.Label, len(cluster.Reports), cluster.Description)
+		for _, report := range cluster.Reports {
+			fmt.Fprintf(&sb, "- %s\n", report.Title)
+		}
+	}
+	return sb.String()
+}
+
+func writeClusterIndexPages(folder string, permissions export.FilePermissions, clusters []Cluster) error {
+	if err := permissions.Mkdir(folder); err != nil {
+		return fmt.Errorf("creating index folder: %w", err)
+	}
+
+	for _, cluster := range clusters {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "# %s\n%s\n\n", cluster.Label, cluster.Description)
+		for _, report := range cluster.Reports {
+			fmt.Fprintf(&sb, "- [[%s]]\n", strings.TrimSuffix(export.ReportFileName(report), ".md"))
+		}
+
+		path := filepath.Join(folder, cluster.Label+".md")
+		if err := permissions.WriteFile(path, []byte(sb.String())); err != nil {
+			return fmt.Errorf("writing cluster index page: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,306 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/internal/paths"
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+	"gopkg.in/yaml.v3"
+)
+
+const CONFIG_FILE_ENV = "REPORT_CONFIG"
+
+// Config holds the defaults read from the configuration file. Command line
+// flags and environment variables take precedence over it.
+type Config struct {
+	OutputFolder string `yaml:"output_folder,omitempty"`
+	// Comma separated list of providers, like the -provider flag.
+	Provider  string                              `yaml:"provider,omitempty"`
+	Providers map[string]summarize.ProviderConfig `yaml:"providers,omitempty"`
+	// Sampling parameters of the LLM calls, like the -temperature,
+	// -max-tokens and -top-p flags. Models are set per provider.
+	Temperature *float64 `yaml:"temperature,omitempty"`
+	MaxTokens   int      `yaml:"max_tokens,omitempty"`
+	TopP        *float64 `yaml:"top_p,omitempty"`
+	Template    string   `yaml:"template,omitempty"`
+	// Prompt profile, like the -profile flag
+	Profile string `yaml:"profile,omitempty"`
+	// Language of the summaries, like the -summary-lang flag
+	SummaryLanguage string                 `yaml:"summary_language,omitempty"`
+	Concurrency     int                    `yaml:"concurrency,omitempty"`
+	Server          ServerConfig           `yaml:"server,omitempty"`
+	Watch           WatchConfig            `yaml:"watch,omitempty"`
+	Notion          export.NotionConfig    `yaml:"notion,omitempty"`
+	Karakeep        export.KarakeepConfig  `yaml:"karakeep,omitempty"`
+	Wallabag        export.WallabagConfig  `yaml:"wallabag,omitempty"`
+	Webhook         export.WebhookConfig   `yaml:"webhook,omitempty"`
+	Events          report.EventsConfig    `yaml:"events,omitempty"`
+	NoteLinks       export.NoteLinksConfig `yaml:"note_links,omitempty"`
+	Ocr             scrape.OcrConfig       `yaml:"ocr,omitempty"`
+	Log             LogConfig              `yaml:"log,omitempty"`
+	ArchiveBox      ArchiveBoxConfig       `yaml:"archivebox,omitempty"`
+	// Extraction rules of the sites the generic extractor gets wrong, by
+	// domain
+	Sites map[string]scrape.SiteConfig `yaml:"sites,omitempty"`
+	// Tokenizers of the models, by model pattern, e.g. gpt-4o* or llama3*
+	Tokenizers map[string]summarize.TokenizerConfig `yaml:"tokenizers,omitempty"`
+}
+
+// ArchiveBoxConfig holds the defaults of report archivebox.
+type ArchiveBoxConfig struct {
+	// Address of the server, like the -url flag
+	Url string `yaml:"url,omitempty"`
+}
+
+// LogConfig holds the defaults of the logs, overridden by the -verbose,
+// -quiet and -log-format flags.
+type LogConfig struct {
+	// debug, info, warn or error
+	Level string `yaml:"level,omitempty"`
+	// text or json
+	Format string `yaml:"format,omitempty"`
+}
+
+// WatchConfig holds the sources polled by report watch.
+type WatchConfig struct {
+	// Delay between two polls, like the -interval flag
+	Interval time.Duration `yaml:"interval,omitempty"`
+	Feeds    []string      `yaml:"feeds,omitempty"`
+	// Files or urls listing article urls, one per line
+	Lists []string `yaml:"lists,omitempty"`
+}
+
+func (c WatchConfig) interval() time.Duration {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return DEFAULT_WATCH_INTERVAL
+}
+
+func (c WatchConfig) sources() []WatchSource {
+	var sources []WatchSource
+	for _, feed := range c.Feeds {
+		sources = append(sources, WatchSource{Url: feed})
+	}
+	for _, list := range c.Lists {
+		sources = append(sources, WatchSource{Url: list, List: true})
+	}
+	return sources
+}
+
+type ServerConfig struct {
+	// Keys allowed to call the API of the server, which is open when empty
+	// and no OpenID provider is set.
+	ApiKeys []ApiKeyConfig `yaml:"api_keys,omitempty"`
+	// OpenID Connect provider whose ID tokens are accepted as well.
+	Oidc OidcConfig `yaml:"oidc,omitempty"`
+}
+
+type ApiKeyConfig struct {
+	// Name of the key, under which its usage is recorded.
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+	// Maximum number of articles and tokens per calendar month, 0 for no limit.
+	MonthlyArticles int `yaml:"monthly_articles,omitempty"`
+	MonthlyTokens   int `yaml:"monthly_tokens,omitempty"`
+	// Maximum number of requests per minute, 0 for no limit.
+	RateLimit int `yaml:"rate_limit,omitempty"`
+	// Whether the key can call the /admin endpoints.
+	Admin bool `yaml:"admin,omitempty"`
+	// Output folder of the reports of the key, and of its index, instead of
+	// the one of the server, so that the users of a server do not share
+	// their reports.
+	OutputFolder string `yaml:"output_folder,omitempty"`
+}
+
+type OidcConfig struct {
+	// Url of the provider, e.g. https://accounts.google.com, its discovery
+	// document being read from /.well-known/openid-configuration.
+	Issuer string `yaml:"issuer,omitempty"`
+	// Audience of the tokens, the client ID of the application.
+	Audience string `yaml:"audience,omitempty"`
+	// Claim naming the user, under which its usage is recorded, sub by
+	// default, e.g. email.
+	UserClaim string `yaml:"user_claim,omitempty"`
+	// Users, as named by the claim, who can call the /admin endpoints.
+	Admins []string `yaml:"admins,omitempty"`
+	// Limits of each user, as for the API keys.
+	MonthlyArticles int `yaml:"monthly_articles,omitempty"`
+	MonthlyTokens   int `yaml:"monthly_tokens,omitempty"`
+	RateLimit       int `yaml:"rate_limit,omitempty"`
+	// Output folder of each user, in which {user} is replaced by the slug of
+	// the user and a hash of it, e.g. ~/reports/{user}.
+	OutputFolder string `yaml:"output_folder,omitempty"`
+}
+
+// loadConfig reads the configuration file, from $REPORT_CONFIG or the config
+// directory. A missing file is an empty configuration.
+func loadConfig() (Config, error) {
+	path, err := userConfigPath()
+	if err != nil {
+		// No config directory, hence no config file
+		return Config{}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("reading config file: %w", err)
+	}
+
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("parsing config file '%s': %w", path, err)
+	}
+
+	for name, provider := range config.Providers {
+		if _, ok := summarize.LLMProviders[name]; !ok {
+			return Config{}, fmt.Errorf("config file '%s': unknown LLM provider '%s'", path, name)
+		}
+		if (provider.InputPrice != nil && *provider.InputPrice < 0) || (provider.OutputPrice != nil && *provider.OutputPrice < 0) {
+			return Config{}, fmt.Errorf("config file '%s': negative price for the LLM provider '%s'", path, name)
+		}
+	}
+	names := map[string]bool{}
+	for _, apiKey := range config.Server.ApiKeys {
+		if apiKey.Name == "" || apiKey.Key == "" {
+			return Config{}, fmt.Errorf("config file '%s': server API keys need a name and a key", path)
+		}
+		if names[apiKey.Name] {
+			return Config{}, fmt.Errorf("config file '%s': duplicate server API key name '%s'", path, apiKey.Name)
+		}
+		if apiKey.MonthlyArticles < 0 || apiKey.MonthlyTokens < 0 || apiKey.RateLimit < 0 {
+			return Config{}, fmt.Errorf("config file '%s': negative limit for the server API key '%s'", path, apiKey.Name)
+		}
+		names[apiKey.Name] = true
+	}
+	if oidc := config.Server.Oidc; oidc.Issuer != "" || oidc.Audience != "" {
+		if parsed, err := url.Parse(oidc.Issuer); err != nil || (parsed.Scheme != "https" && parsed.Hostname() != "localhost") {
+			return Config{}, fmt.Errorf("config file '%s': the server OpenID issuer must be an https url", path)
+		}
+		if oidc.Audience == "" {
+			return Config{}, fmt.Errorf("config file '%s': the server OpenID provider needs the audience of its tokens, the client ID", path)
+		}
+		if oidc.MonthlyArticles < 0 || oidc.MonthlyTokens < 0 || oidc.RateLimit < 0 {
+			return Config{}, fmt.Errorf("config file '%s': negative limit for the server OpenID users", path)
+		}
+		if oidc.OutputFolder != "" && !strings.Contains(oidc.OutputFolder, OIDC_USER_PLACEHOLDER) {
+			return Config{}, fmt.Errorf("config file '%s': the output folder of the server OpenID users needs %s, not to share it", path, OIDC_USER_PLACEHOLDER)
+		}
+	}
+	if _, err := scrape.CompileSiteRules(config.Sites); err != nil {
+		return Config{}, fmt.Errorf("config file '%s': %w", path, err)
+	}
+	if _, err := summarize.CompileTokenizerRules(config.Tokenizers); err != nil {
+		return Config{}, fmt.Errorf("config file '%s': %w", path, err)
+	}
+	config.OutputFolder = paths.ExpandHome(config.OutputFolder)
+	for i := range config.Server.ApiKeys {
+		config.Server.ApiKeys[i].OutputFolder = paths.ExpandHome(config.Server.ApiKeys[i].OutputFolder)
+	}
+	config.Server.Oidc.OutputFolder = paths.ExpandHome(config.Server.Oidc.OutputFolder)
+	config.Template = paths.ExpandHome(config.Template)
+	for i, list := range config.Watch.Lists {
+		config.Watch.Lists[i] = paths.ExpandHome(list)
+	}
+
+	return config, nil
+}
+
+// userConfigPath returns the path of the config file, $REPORT_CONFIG or the
+// config file of the config directory.
+func userConfigPath() (string, error) {
+	if path := os.Getenv(CONFIG_FILE_ENV); path != "" {
+		return path, nil
+	}
+	return paths.ConfigFilePath()
+}
+
+// withDefaultOutputFolder prepends the configured output folder to the
+// positional arguments of a command when they lack it, i.e. when there is
+// one argument less than expected.
+func (c Config) withDefaultOutputFolder(args []string, expected int) []string {
+	if len(args) == expected-1 && c.OutputFolder != "" {
+		return append([]string{c.OutputFolder}, args...)
+	}
+	return args
+}
+
+func runConfig(config Config, args []string) error {
+	flags := flag.NewFlagSet("config", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: report config")
+		fmt.Println("Print the path of the config file and the configuration read from it, API keys masked.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	path, err := userConfigPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		fmt.Printf("# %s (not found)\n", path)
+	} else {
+		fmt.Printf("# %s\n", path)
+	}
+
+	masked := config
+	masked.Providers = map[string]summarize.ProviderConfig{}
+	for name, provider := range config.Providers {
+		if provider.ApiKey != "" {
+			provider.ApiKey = maskApiKey(provider.ApiKey)
+		}
+		masked.Providers[name] = provider
+	}
+	masked.Server.ApiKeys = nil
+	for _, apiKey := range config.Server.ApiKeys {
+		apiKey.Key = maskApiKey(apiKey.Key)
+		masked.Server.ApiKeys = append(masked.Server.ApiKeys, apiKey)
+	}
+	if masked.Wallabag.ClientSecret != "" {
+		masked.Wallabag.ClientSecret = maskApiKey(masked.Wallabag.ClientSecret)
+	}
+	if masked.Wallabag.Password != "" {
+		masked.Wallabag.Password = maskApiKey(masked.Wallabag.Password)
+	}
+	if masked.Webhook.Token != "" {
+		masked.Webhook.Token = maskApiKey(masked.Webhook.Token)
+	}
+	if masked.Webhook.Secret != "" {
+		masked.Webhook.Secret = maskApiKey(masked.Webhook.Secret)
+	}
+	if masked.Events.Url != "" {
+		masked.Events.Url = report.RedactedUrl(masked.Events.Url)
+	}
+
+	data, err := yaml.Marshal(masked)
+	if err != nil {
+		return fmt.Errorf("marshaling config: %w", err)
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+func maskApiKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:4] + "****" + key[len(key)-4:]
+}
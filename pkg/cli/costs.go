@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+// CostGroup is the usage attributed to a feed, a profile or a tag.
+type CostGroup struct {
+	Name  string
+	Usage summarize.Usage
+}
+
+// groupCosts sums the usage of the records by feed, profile or tag. An
+// article with several tags counts fully toward each of them.
+func groupCosts(records []report.CostRecord, by string) []CostGroup {
+	usages := map[string]summarize.Usage{}
+	for _, record := range records {
+		usage := record.Usage
+		usage.Articles = 1
+		var names []string
+		switch by {
+		case report.COSTS_BY_FEED:
+			names = []string{cmp.Or(record.Feed, "(no feed)")}
+		case report.COSTS_BY_PROFILE:
+			names = []string{cmp.Or(record.Profile, "(no profile)")}
+		case report.COSTS_BY_TAG:
+			names = report.MergeTags(record.Tags, nil)
+			if len(names) == 0 {
+				names = []string{"(no tag)"}
+			}
+		}
+		for _, name := range names {
+			total := usages[name]
+			total.Add(usage)
+			usages[name] = total
+		}
+	}
+
+	groups := make([]CostGroup, 0, len(usages))
+	for name, usage := range usages {
+		groups = append(groups, CostGroup{Name: name, Usage: usage})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i].Usage, groups[j].Usage
+		if a.Cost != b.Cost {
+			return a.Cost > b.Cost
+		}
+		if a.InputTokens+a.OutputTokens != b.InputTokens+b.OutputTokens {
+			return a.InputTokens+a.OutputTokens > b.InputTokens+b.OutputTokens
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+func runCosts(config Config, args []string) error {
+	flags := flag.NewFlagSet("costs", flag.ExitOnError)
+	by := flags.String("by", report.COSTS_BY_FEED, "attribute the costs by "+report.COSTS_BY_FEED+", "+report.COSTS_BY_PROFILE+" or "+report.COSTS_BY_TAG)
+	since := flags.String("since", "30d", "include the articles summarized during this period (e.g. 30d, 4w, 720h)")
+	limit := flags.Int("n", 20, "maximum number of feeds, profiles or tags listed, 0 for all")
+	flags.Usage = func() {
+		fmt.Println("Usage: report costs [-by feed|profile|tag] [-since 30d] [-n 20]")
+		fmt.Println("Print the tokens consumed and their estimated cost by feed, prompt profile or tag.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *by != report.COSTS_BY_FEED && *by != report.COSTS_BY_PROFILE && *by != report.COSTS_BY_TAG {
+		return fmt.Errorf("unknown attribution '%s', expected %s, %s or %s", *by, report.COSTS_BY_FEED, report.COSTS_BY_PROFILE, report.COSTS_BY_TAG)
+	}
+	period, err := parsePeriod(*since)
+	if err != nil {
+		return fmt.Errorf("parsing since: %w", err)
+	}
+	start := time.Now().Add(-period)
+
+	records, err := report.LoadCostRecords(start)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		fmt.Printf("No usage recorded since %s\n", start.Format(scrape.DATE_FORMAT))
+		return nil
+	}
+
+	var total summarize.Usage
+	for _, record := range records {
+		total.Add(record.Usage)
+		total.Articles++
+	}
+	groups := groupCosts(records, *by)
+	fmt.Printf("Since %s: %d articles, %s\n\nBy %s:\n", start.Format(scrape.DATE_FORMAT), total.Articles, summarize.FormatUsage(total), *by)
+	for i, group := range groups {
+		if *limit > 0 && i == *limit {
+			fmt.Printf("  ... %d more\n", len(groups)-*limit)
+			break
+		}
+		usage := group.Usage
+		fmt.Printf("  %-50s %5d articles  %10d input  %9d output  %s\n", group.Name, usage.Articles, usage.InputTokens, usage.OutputTokens, summarize.FormatCost(usage.Cost))
+	}
+	if *by == report.COSTS_BY_TAG {
+		fmt.Println("\nAn article with several tags counts toward each of them.")
+	}
+	return nil
+}
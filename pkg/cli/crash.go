@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	"github.com/brequet/report/pkg/report"
+)
+
+// exitOnCrash recovers from a panic of the command line tool, writes a crash
+// report to the state directory and exits. It is deferred at the top of Main,
+// the library returning PanicError values instead.
+func exitOnCrash() {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+
+	crashReport := report.FormatCrashReport(recovered, "", debug.Stack())
+	fmt.Printf("Error: the tool crashed: %v\n", recovered)
+	if path, err := report.WriteCrashReport(crashReport); err != nil {
+		fmt.Printf("Could not write the crash report (%v), here it is:\n%s", err, crashReport)
+	} else {
+		fmt.Printf("A crash report was written to %s, please attach it when reporting the issue\n", path)
+	}
+	os.Exit(report.EXIT_CODE_CRASH)
+}
@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/brequet/report/internal/paths"
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+)
+
+func TestCheckLinkPanic(t *testing.T) {
+	t.Setenv(paths.STATE_DIR_ENV, t.TempDir())
+	// A limiter without its map of hosts makes the check panic
+	check := checkLink(context.Background(), scrape.New(), &scrape.HostLimiter{}, export.Report{Url: "https://example.com/"})
+	var panicErr *report.PanicError
+	if check.Dead || !errors.As(check.Err, &panicErr) {
+		t.Errorf("checkLink = %+v, want a failure with a PanicError", check)
+	}
+}
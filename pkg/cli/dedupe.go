@@ -0,0 +1,328 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// Cosine similarity of the summaries above which two reports of different
+// urls are about the same article, e.g. a syndicated copy
+const DEFAULT_DUPLICATE_SIMILARITY = 0.95
+
+func runDedupe(config Config, args []string) error {
+	flags := flag.NewFlagSet("dedupe", flag.ExitOnError)
+	similarity := flags.Float64("similarity", DEFAULT_DUPLICATE_SIMILARITY, "similarity of the summaries, from 0 to 1, above which reports of different urls are duplicates, when embeddings are configured")
+	interactive := flags.Bool("interactive", false, "ask whether to merge each group of duplicates, and merge the accepted ones")
+	permissions := addPermissionFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report dedupe [-similarity 0.95] [-interactive] <output-folder>")
+		fmt.Println("Find the reports of the same article in the output folder, and merge them.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder")
+	}
+	outputFolder := args[0]
+	if *similarity <= 0 || *similarity > 1 {
+		return fmt.Errorf("invalid similarity %g, expected a value above 0 and up to 1", *similarity)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	reports, vectors, err := loadDuplicateCandidates(ctx, outputFolder, *permissions)
+	if err != nil {
+		return err
+	}
+	groups := findDuplicates(reports, vectors, *similarity)
+	if len(groups) == 0 {
+		fmt.Println("No duplicate reports found")
+		return nil
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	merged := 0
+	for i, group := range groups {
+		printDuplicateGroup(i+1, group, vectors)
+		if !*interactive {
+			continue
+		}
+		keep, merge, quit := askDuplicateMerge(reader, len(group))
+		if quit {
+			break
+		}
+		if !merge {
+			continue
+		}
+		for j, report := range group {
+			if j == keep {
+				continue
+			}
+			rewritten, err := mergeDuplicateReport(outputFolder, *permissions, group[keep], report)
+			if err != nil {
+				return fmt.Errorf("merging '%s' into '%s': %w", report.Path, group[keep].Path, err)
+			}
+			fmt.Printf("Merged %s into %s, %d files with links updated\n", report.Path, group[keep].Path, len(rewritten))
+		}
+		merged++
+	}
+
+	if !*interactive {
+		fmt.Printf("\n%d groups of duplicates found, run with -interactive to merge them\n", len(groups))
+		return nil
+	}
+	fmt.Printf("\n%d of %d groups of duplicates merged\n", merged, len(groups))
+	return nil
+}
+
+// loadDuplicateCandidates loads the reports of the output folder, with the
+// normalized embeddings of their summaries when embeddings are configured,
+// keyed by file name. Without embeddings, only the urls are compared.
+func loadDuplicateCandidates(ctx context.Context, outputFolder string, permissions export.FilePermissions) ([]export.Report, map[string][]float64, error) {
+	if !export.IsEmbeddingConfigured() {
+		fmt.Fprintln(os.Stderr, "Embeddings are not configured, only the reports of the same URL are compared")
+		reports, err := export.LoadReports(outputFolder)
+		return reports, nil, err
+	}
+
+	_, reports, store, err := export.LoadEmbeddedReports(ctx, outputFolder, permissions)
+	if err != nil {
+		return nil, nil, err
+	}
+	vectors := map[string][]float64{}
+	for name, stored := range store.Entries {
+		vectors[name] = normalizeVector(stored.Vector)
+	}
+	return reports, vectors, nil
+}
+
+// findDuplicates groups the reports of the same canonical url, or whose
+// summaries are at least similarity similar. Each group is sorted from the
+// oldest report, the one proposed to be kept, to the newest.
+func findDuplicates(reports []export.Report, vectors map[string][]float64, similarity float64) [][]export.Report {
+	parent := make([]int, len(reports))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(i, j int) {
+		parent[find(i)] = find(j)
+	}
+
+	byUrl := map[string]int{}
+	for i, report := range reports {
+		key := scrape.CanonicalUrl(report.Url)
+		if j, found := byUrl[key]; found {
+			union(i, j)
+		} else {
+			byUrl[key] = i
+		}
+	}
+	for i := range reports {
+		a := vectors[export.ReportFileName(reports[i])]
+		if len(a) == 0 {
+			continue
+		}
+		for j := i + 1; j < len(reports); j++ {
+			b := vectors[export.ReportFileName(reports[j])]
+			if len(b) > 0 && dotProduct(a, b) >= similarity {
+				union(i, j)
+			}
+		}
+	}
+
+	members := map[int][]export.Report{}
+	for i, report := range reports {
+		members[find(i)] = append(members[find(i)], report)
+	}
+	var groups [][]export.Report
+	for _, group := range members {
+		if len(group) < 2 {
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool {
+			if group[i].DateCreated != group[j].DateCreated {
+				return group[i].DateCreated < group[j].DateCreated
+			}
+			return group[i].Path < group[j].Path
+		})
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0].Path < groups[j][0].Path
+	})
+	return groups
+}
+
+// printDuplicateGroup prints the reports of a group of duplicates, with why
+// each one is a duplicate of the first one.
+func printDuplicateGroup(number int, group []export.Report, vectors map[string][]float64) {
+	fmt.Printf("\nDuplicates %d:\n", number)
+	for i, report := range group {
+		reason := "proposed to keep"
+		if i > 0 {
+			var reasons []string
+			if scrape.CanonicalUrl(report.Url) == scrape.CanonicalUrl(group[0].Url) {
+				reasons = append(reasons, "same URL")
+			}
+			a, b := vectors[export.ReportFileName(group[0])], vectors[export.ReportFileName(report)]
+			if len(a) > 0 && len(b) > 0 {
+				reasons = append(reasons, fmt.Sprintf("similarity %.2f", dotProduct(a, b)))
+			}
+			reason = strings.Join(reasons, ", ")
+		}
+		fmt.Printf("  %d. %s (%s, %s)\n     %s\n", i+1, report.Title, report.DateCreated, reason, report.Path)
+	}
+}
+
+// askDuplicateMerge asks whether to merge a group of duplicates, and into
+// which of its reports. It returns the index of the report to keep, whether
+// to merge and whether to stop asking.
+func askDuplicateMerge(reader *bufio.Reader, size int) (int, bool, bool) {
+	report.StdinMutex.Lock()
+	defer report.StdinMutex.Unlock()
+
+	for {
+		fmt.Printf("Merge into report 1? [y]es, [n]o, the number of the report to keep, or [q]uit: ")
+		input, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || input == "") {
+			fmt.Println()
+			return 0, false, true
+		}
+
+		input = strings.ToLower(strings.TrimSpace(input))
+		switch input {
+		case "y", "yes":
+			return 0, true, false
+		case "", "n", "no":
+			return 0, false, false
+		case "q", "quit":
+			return 0, false, true
+		}
+		if number, err := strconv.Atoi(input); err == nil && number >= 1 && number <= size {
+			return number - 1, true, false
+		}
+		fmt.Printf("Expected y, n, q or a number from 1 to %d\n", size)
+	}
+}
+
+// mergeDuplicateReport merges a duplicate report into the report kept: the
+// regions the user wrote in the duplicate and its tags are added to the kept
+// report, the links to the duplicate are rewritten to the kept report, and
+// the duplicate is removed. The url of the duplicate stays in the index, with
+// the kept report, so that it is not summarized again. It returns the files
+// whose links were rewritten.
+func mergeDuplicateReport(outputFolder string, permissions export.FilePermissions, kept, duplicate export.Report) ([]string, error) {
+	data, err := os.ReadFile(duplicate.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading report: %w", err)
+	}
+	_, body := export.SplitFrontmatter(strings.ReplaceAll(string(data), "\r\n", "\n"))
+	if err := addToKeptReport(kept, permissions, export.UserRegions(body), duplicate.Tags); err != nil {
+		return nil, err
+	}
+
+	move := ReportMove{OldPath: absPath(duplicate.Path), NewPath: absPath(kept.Path)}
+	rewritten, err := rewriteFolderLinks(outputFolder, permissions, move, false)
+	if err != nil {
+		return rewritten, err
+	}
+	rewritten = slices.DeleteFunc(rewritten, func(path string) bool {
+		return path == move.OldPath
+	})
+	if err := os.Remove(duplicate.Path); err != nil {
+		return rewritten, export.NewWriteError("removing", duplicate.Path, err)
+	}
+
+	if err := moveIndexedReport(outputFolder, permissions, duplicate, move); err != nil {
+		slog.Warn("could not update the article index", "err", err)
+	}
+	if export.IsEmbeddingConfigured() {
+		if err := removeReportEmbedding(outputFolder, permissions, filepath.Base(duplicate.Path)); err != nil {
+			slog.Warn("could not update the embedding store", "err", err)
+		}
+	}
+	return rewritten, nil
+}
+
+// addToKeptReport appends the user regions of a duplicate to the kept
+// report, and adds the tags it does not have yet.
+func addToKeptReport(kept export.Report, permissions export.FilePermissions, regions []string, tags []string) error {
+	// The kept report is read again, as it may have been merged into already
+	kept, err := export.ParseReportFile(kept.Path)
+	if err != nil {
+		return fmt.Errorf("reading report: %w", err)
+	}
+	data, err := os.ReadFile(kept.Path)
+	if err != nil {
+		return fmt.Errorf("reading report: %w", err)
+	}
+	content := string(data)
+
+	if len(regions) > 0 {
+		newline := "\n"
+		if strings.Contains(content, "\r\n") {
+			newline = "\r\n"
+		}
+		content = strings.TrimRight(content, "\r\n") + newline + newline + strings.Join(regions, newline) + newline
+	}
+	merged := slices.Clone(kept.Tags)
+	for _, tag := range tags {
+		if !slices.Contains(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	if len(merged) > len(kept.Tags) {
+		if withTags, err := replaceFrontmatterTags(content, merged); err == nil {
+			content = withTags
+		} else {
+			slog.Debug("Tags of the duplicate not merged", "path", kept.Path, "err", err)
+		}
+	}
+
+	if content == string(data) {
+		return nil
+	}
+	return permissions.WriteFile(kept.Path, []byte(content))
+}
+
+// removeReportEmbedding removes the entry of a removed report from the
+// embedding store, keyed by file name.
+func removeReportEmbedding(outputFolder string, permissions export.FilePermissions, fileName string) error {
+	config, err := export.GetEmbeddingConfig()
+	if err != nil {
+		return err
+	}
+	store, err := export.LoadEmbeddingStore(outputFolder, config.Model)
+	if err != nil {
+		return err
+	}
+	if _, found := store.Entries[fileName]; !found {
+		return nil
+	}
+	delete(store.Entries, fileName)
+	return store.Save(outputFolder, permissions)
+}
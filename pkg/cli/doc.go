@@ -0,0 +1,4 @@
+// Package cli is the command line of the report tool, whose commands Main
+// runs. The commands parse their flags and the config file into the options
+// of the report, scrape, summarize and export packages, which do the work.
+package cli
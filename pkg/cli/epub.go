@@ -0,0 +1,250 @@
+package cli
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// Style of the chapters, left short so that the e-readers apply their own
+// fonts and margins.
+const epubStylesheet = `body { line-height: 1.4; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.1em; }
+.meta { font-size: 0.85em; color: #555; }
+.tags { font-size: 0.85em; font-style: italic; }
+`
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>`
+
+func runEpub(config Config, args []string) error {
+	flags := flag.NewFlagSet("epub", flag.ExitOnError)
+	since := flags.String("since", "7d", "include the reports created during this period (e.g. 7d, 2w)")
+	tags := flags.String("tags", "", "only include the reports with one of these comma separated tags")
+	title := flags.String("title", "", "title of the book, defaults to one with the issue date")
+	output := flags.String("o", "", "path of the EPUB file, defaults to reading-digest-<date>.epub in the current folder")
+	permissions := addPermissionFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report epub [-since 7d] [-tags go,web] [-title \"...\"] [-o digest.epub] <output-folder>")
+		fmt.Println("Bundle the reports of a period into an EPUB book, a chapter per article, to read them on an e-reader.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder")
+	}
+
+	period, err := parsePeriod(*since)
+	if err != nil {
+		return fmt.Errorf("parsing since: %w", err)
+	}
+
+	reports, err := export.LoadReports(args[0])
+	if err != nil {
+		return err
+	}
+
+	var tagFilter []string
+	if *tags != "" {
+		tagFilter = strings.Split(*tags, ",")
+	}
+	selected := selectNewsletterReports(reports, time.Now().Add(-period), tagFilter)
+	if len(selected) == 0 {
+		return fmt.Errorf("no report created during the last %s", *since)
+	}
+
+	now := time.Now()
+	if *title == "" {
+		*title = "Reading digest - " + now.Format("January 2, 2006")
+	}
+	if *output == "" {
+		*output = "reading-digest-" + now.Format(scrape.DATE_FORMAT) + ".epub"
+	}
+
+	book, err := renderEpub(*title, selected, now)
+	if err != nil {
+		return fmt.Errorf("rendering EPUB: %w", err)
+	}
+	if err := permissions.WriteFile(*output, book); err != nil {
+		return fmt.Errorf("writing EPUB: %w", err)
+	}
+	fmt.Printf("EPUB created successfully: %s\n", *output)
+	return nil
+}
+
+// renderEpub renders reports as an EPUB 3 book, a chapter per report,
+// readable by the older EPUB 2 readers thanks to its NCX table of contents.
+func renderEpub(title string, reports []export.Report, created time.Time) ([]byte, error) {
+	// The identifier stays the same for the same selection of reports, so
+	// that sending a digest again replaces the book on the e-reader
+	hash := sha256.New()
+	for _, report := range reports {
+		hash.Write([]byte(report.Id + report.Url + "\n"))
+	}
+	identifier := "urn:report:" + hex.EncodeToString(hash.Sum(nil))[:32]
+
+	// The book is in the language of most of its reports
+	languageCounts := map[string]int{}
+	language := "en"
+	for _, report := range reports {
+		if report.Language != "" {
+			languageCounts[report.Language]++
+			if languageCounts[report.Language] > languageCounts[language] {
+				language = report.Language
+			}
+		}
+	}
+
+	type chapter struct {
+		name, title, content string
+	}
+	chapters := make([]chapter, len(reports))
+	for i, report := range reports {
+		chapters[i] = chapter{
+			name:    fmt.Sprintf("chapter-%03d.xhtml", i+1),
+			title:   report.Title,
+			content: renderEpubChapter(report, language),
+		}
+	}
+
+	var manifest, spine, navItems, navPoints strings.Builder
+	for i, chapter := range chapters {
+		id := strings.TrimSuffix(chapter.name, ".xhtml")
+		fmt.Fprintf(&manifest, `<item id="%s" href="%s" media-type="application/xhtml+xml"/>`+"\n", id, chapter.name)
+		fmt.Fprintf(&spine, `<itemref idref="%s"/>`+"\n", id)
+		fmt.Fprintf(&navItems, `<li><a href="%s">%s</a></li>`+"\n", chapter.name, export.EscapeXml(chapter.title))
+		fmt.Fprintf(&navPoints, `<navPoint id="%s" playOrder="%d"><navLabel><text>%s</text></navLabel><content src="%s"/></navPoint>`+"\n", id, i+2, export.EscapeXml(chapter.title), chapter.name)
+	}
+
+	contentOpf := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="book-id">%s</dc:identifier>
+<dc:title>%s</dc:title>
+<dc:language>%s</dc:language>
+<dc:creator>report</dc:creator>
+<dc:date>%s</dc:date>
+<meta property="dcterms:modified">%s</meta>
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+<item id="style" href="style.css" media-type="text/css"/>
+%s</manifest>
+<spine toc="ncx">
+<itemref idref="nav"/>
+%s</spine>
+</package>`, identifier, export.EscapeXml(title), language, created.Format(scrape.DATE_FORMAT), created.UTC().Format(time.RFC3339), manifest.String(), spine.String())
+
+	nav := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%s" lang="%s">
+<head><title>%s</title><link rel="stylesheet" type="text/css" href="style.css"/></head>
+<body>
+<h1>%s</h1>
+<nav epub:type="toc" id="toc">
+<ol>
+%s</ol>
+</nav>
+</body>
+</html>`, language, language, export.EscapeXml(title), export.EscapeXml(title), navItems.String())
+
+	ncx := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+<head><meta name="dtb:uid" content="%s"/></head>
+<docTitle><text>%s</text></docTitle>
+<navMap>
+<navPoint id="nav" playOrder="1"><navLabel><text>Contents</text></navLabel><content src="nav.xhtml"/></navPoint>
+%s</navMap>
+</ncx>`, identifier, export.EscapeXml(title), navPoints.String())
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	// The mimetype file comes first, uncompressed, for the readers to
+	// recognize the format from the first bytes of the file
+	mimetype, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := mimetype.Write([]byte("application/epub+zip")); err != nil {
+		return nil, err
+	}
+	parts := []struct{ name, content string }{
+		{"META-INF/container.xml", epubContainer},
+		{"OEBPS/content.opf", contentOpf},
+		{"OEBPS/nav.xhtml", nav},
+		{"OEBPS/toc.ncx", ncx},
+		{"OEBPS/style.css", epubStylesheet},
+	}
+	for _, chapter := range chapters {
+		parts = append(parts, struct{ name, content string }{"OEBPS/" + chapter.name, chapter.content})
+	}
+	for _, part := range parts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderEpubChapter renders a report as the XHTML page of its chapter: its
+// title linking to the article, the summary, the key points and the tags.
+func renderEpubChapter(report export.Report, bookLanguage string) string {
+	language := report.Language
+	if language == "" {
+		language = bookLanguage
+	}
+	heading := report.KeypointsHeading
+	if heading == "" {
+		heading = "Key Points"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="%s" lang="%s">
+<head><title>%s</title><link rel="stylesheet" type="text/css" href="style.css"/></head>
+<body>
+<h1>%s</h1>
+`, language, language, export.EscapeXml(report.Title), export.EscapeXml(report.Title))
+
+	meta := []string{fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(report.Url), html.EscapeString(export.SiteNameFromUrl(report.Url)))}
+	if report.DateCreated != "" {
+		meta = append(meta, html.EscapeString(report.DateCreated))
+	}
+	fmt.Fprintf(&sb, "<p class=\"meta\">%s</p>\n", strings.Join(meta, " · "))
+
+	sb.WriteString(export.ReportToHtml(report.Summary, nil) + "\n")
+	if len(report.Keypoints) > 0 {
+		fmt.Fprintf(&sb, "<h2>%s</h2>\n%s\n", export.EscapeXml(heading), export.ReportToHtml("", report.Keypoints))
+	}
+	if len(report.Tags) > 0 {
+		fmt.Fprintf(&sb, "<p class=\"tags\">%s</p>\n", html.EscapeString("#"+strings.Join(report.Tags, " #")))
+	}
+	sb.WriteString("</body>\n</html>")
+	return sb.String()
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/brequet/report/pkg/report"
+)
+
+// addEventFlags adds the flags of the progress events to a flag set, their
+// defaults being read from the config file, see addReportFlags.
+func addEventFlags(flags *flag.FlagSet, config report.EventsConfig) *report.ProgressEvents {
+	events := &report.ProgressEvents{Types: config.Types}
+	flags.StringVar(&events.Url, "events", cmp.Or(os.Getenv("REPORT_EVENTS_URL"), config.Url), "webhook (http or https url) or NATS subject (nats://host:4222/subject) receiving the progress events of the articles")
+	flags.Func("event-types", "comma separated list of the progress events sent ("+strings.Join(report.EventTypes, ", ")+"), all if not set", func(value string) error {
+		types, err := parseEventTypes(value)
+		events.Types = types
+		return err
+	})
+	return events
+}
+
+func parseEventTypes(value string) ([]string, error) {
+	var types []string
+	for _, eventType := range strings.Split(value, ",") {
+		eventType = strings.ToLower(strings.TrimSpace(eventType))
+		if !slices.Contains(report.EventTypes, eventType) {
+			return nil, fmt.Errorf("unknown event '%s', expected %s", eventType, strings.Join(report.EventTypes, ", "))
+		}
+		types = append(types, eventType)
+	}
+	return types, nil
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/report"
+)
+
+// ExportTargets is the -export flag, repeatable, each value being a comma
+// separated list of targets.
+type ExportTargets []string
+
+func (e *ExportTargets) String() string {
+	return strings.Join(*e, ",")
+}
+
+func (e *ExportTargets) Set(value string) error {
+	for _, target := range strings.Split(value, ",") {
+		if target = strings.TrimSpace(target); target != "" {
+			*e = append(*e, target)
+		}
+	}
+	return nil
+}
+
+// exportTargets lists the values accepted by -export.
+func exportTargets() string {
+	return strings.Join(export.OutputFormats(), ", ") + ", " + report.EXPORT_STDOUT + ", " + export.EXPORT_NOTION + ", " + export.EXPORT_KARAKEEP + ", " + export.EXPORT_WALLABAG + ", " + export.EXPORT_WEBHOOK + " or " + export.EXPORT_WEBHOOK + "=<url>"
+}
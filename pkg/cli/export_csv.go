@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/brequet/report/pkg/export"
+)
+
+var csvHeader = []string{"title", "url", "date_created", "content_type", "tags", "summary"}
+
+func runExportCsv(config Config, args []string) error {
+	flags := flag.NewFlagSet("export-csv", flag.ExitOnError)
+	tsv := flags.Bool("tsv", false, "write tab separated values instead of comma separated values")
+	output := flags.String("o", "", "write to this file instead of stdout")
+	permissions := addPermissionFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report export-csv [-tsv] [-o reports.csv] <output-folder>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder")
+	}
+
+	reports, err := export.LoadReports(args[0])
+	if err != nil {
+		return err
+	}
+
+	var writer io.Writer = os.Stdout
+	if *output != "" {
+		file, err := permissions.CreateFile(*output)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	if err := writeReportsCsv(writer, reports, *tsv); err != nil {
+		return fmt.Errorf("writing reports: %w", err)
+	}
+
+	if *output != "" {
+		fmt.Printf("%d reports exported successfully: %s\n", len(reports), *output)
+	}
+	return nil
+}
+
+var tsvFieldReplacer = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ")
+
+func writeReportsCsv(writer io.Writer, reports []export.Report, tsv bool) error {
+	csvWriter := csv.NewWriter(writer)
+	if tsv {
+		csvWriter.Comma = '\t'
+	}
+
+	if err := csvWriter.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, report := range reports {
+		record := []string{
+			report.Title,
+			report.Url,
+			report.DateCreated,
+			report.ContentType,
+			strings.Join(report.Tags, ";"),
+			report.Summary,
+		}
+		// Tab separated values are expected to hold one record per line
+		if tsv {
+			for i, field := range record {
+				record[i] = tsvFieldReplacer.Replace(field)
+			}
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+)
+
+const (
+	FEED_FORMAT_JSON = "json"
+	FEED_FORMAT_RSS  = "rss"
+
+	JSON_FEED_VERSION = "https://jsonfeed.org/version/1.1"
+)
+
+type JsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageUrl string         `json:"home_page_url,omitempty"`
+	FeedUrl     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Items       []JsonFeedItem `json:"items"`
+}
+
+type JsonFeedItem struct {
+	Id            string   `json:"id"`
+	Url           string   `json:"url"`
+	ExternalUrl   string   `json:"external_url,omitempty"`
+	Title         string   `json:"title"`
+	ContentHtml   string   `json:"content_html"`
+	Summary       string   `json:"summary,omitempty"`
+	DatePublished string   `json:"date_published,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+type RssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel RssChannel `xml:"channel"`
+}
+
+type RssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []RssItem `xml:"item"`
+}
+
+type RssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Guid        string   `xml:"guid"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Categories  []string `xml:"category"`
+}
+
+func runFeed(config Config, args []string) error {
+	flags := flag.NewFlagSet("feed", flag.ExitOnError)
+	format := flags.String("format", FEED_FORMAT_JSON, "feed format, json (JSON Feed) or rss")
+	limit := flags.Int("n", 20, "number of reports in the feed")
+	title := flags.String("title", "My reading", "title of the feed")
+	baseUrl := flags.String("base-url", "", "url where the output folder is published, items then link to the reports instead of the articles")
+	output := flags.String("o", "", "feed file, defaults to feed.json or feed.xml in the output folder")
+	permissions := addPermissionFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report feed [-format json|rss] [-n 20] [-base-url https://...] [-o feed.json] <output-folder>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder")
+	}
+	outputFolder := args[0]
+
+	reports, err := export.LoadReports(outputFolder)
+	if err != nil {
+		return err
+	}
+	reports = latestReports(reports, *limit)
+
+	var content []byte
+	var defaultFileName string
+	switch *format {
+	case FEED_FORMAT_JSON:
+		content, err = renderJsonFeed(reports, *title, *baseUrl)
+		defaultFileName = "feed.json"
+	case FEED_FORMAT_RSS:
+		content, err = renderRssFeed(reports, *title, *baseUrl)
+		defaultFileName = "feed.xml"
+	default:
+		return fmt.Errorf("unknown feed format '%s', expected %s or %s", *format, FEED_FORMAT_JSON, FEED_FORMAT_RSS)
+	}
+	if err != nil {
+		return fmt.Errorf("rendering feed: %w", err)
+	}
+
+	outputPath := *output
+	if outputPath == "" {
+		outputPath = filepath.Join(outputFolder, defaultFileName)
+	}
+	if err := permissions.WriteFile(outputPath, content); err != nil {
+		return fmt.Errorf("writing feed: %w", err)
+	}
+
+	fmt.Printf("Feed created successfully: %s\n", outputPath)
+	return nil
+}
+
+// latestReports returns the most recently created reports, newest first.
+func latestReports(reports []export.Report, limit int) []export.Report {
+	sorted := append([]export.Report(nil), reports...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].DateCreated > sorted[j].DateCreated
+	})
+	return sorted[:min(limit, len(sorted))]
+}
+
+// reportLink returns the url of the published report if the output folder is
+// published, else the url of the article.
+func reportLink(report export.Report, baseUrl string) string {
+	if baseUrl == "" {
+		return report.Url
+	}
+	return strings.TrimSuffix(baseUrl, "/") + "/" + url.PathEscape(export.ReportFileName(report))
+}
+
+// reportGuid identifies a report in the feeds, by its ID for the reports
+// having one, so that renaming them does not publish them again.
+func reportGuid(report export.Report) string {
+	if report.Id != "" {
+		return "urn:ulid:" + report.Id
+	}
+	return report.Url
+}
+
+func renderJsonFeed(reports []export.Report, title, baseUrl string) ([]byte, error) {
+	feed := JsonFeed{
+		Version:     JSON_FEED_VERSION,
+		Title:       title,
+		HomePageUrl: baseUrl,
+		Description: "Summaries of the articles I read",
+		Items:       []JsonFeedItem{},
+	}
+	if baseUrl != "" {
+		feed.FeedUrl = strings.TrimSuffix(baseUrl, "/") + "/feed.json"
+	}
+
+	for _, report := range reports {
+		item := JsonFeedItem{
+			Id:          reportGuid(report),
+			Url:         reportLink(report, baseUrl),
+			Title:       report.Title,
+			ContentHtml: export.ReportToHtml(report.Summary, report.Keypoints),
+			Summary:     report.Summary,
+			Tags:        report.Tags,
+		}
+		if item.Url != report.Url {
+			item.ExternalUrl = report.Url
+		}
+		if date, ok := export.ParseReportDate(report); ok {
+			item.DatePublished = date.Format(time.RFC3339)
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func renderRssFeed(reports []export.Report, title, baseUrl string) ([]byte, error) {
+	feed := RssFeed{
+		Version: "2.0",
+		Channel: RssChannel{
+			Title:       title,
+			Link:        baseUrl,
+			Description: "Summaries of the articles I read",
+		},
+	}
+
+	for _, report := range reports {
+		item := RssItem{
+			Title:       report.Title,
+			Link:        reportLink(report, baseUrl),
+			Guid:        reportGuid(report),
+			Description: export.ReportToHtml(report.Summary, report.Keypoints),
+			Categories:  report.Tags,
+		}
+		if date, ok := export.ParseReportDate(report); ok {
+			item.PubDate = date.Format(time.RFC1123Z)
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	content, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(content, '\n')...), nil
+}
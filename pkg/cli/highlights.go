@@ -0,0 +1,425 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+//go:embed highlights-prompt.md
+var highlightsPrompt string
+
+//go:embed highlights-template.md
+var highlightsTemplate string
+
+const (
+	HIGHLIGHTS_KINDLE   = "kindle"
+	HIGHLIGHTS_READWISE = "readwise"
+	// Separator of the entries of the Kindle clippings file
+	KINDLE_CLIPPING_SEPARATOR = "=========="
+	// Layout of the dates of the Kindle clippings, in English
+	KINDLE_DATE_FORMAT = "Monday, January 2, 2006 3:04:05 PM"
+	// Books with fewer highlights than this are skipped by default
+	DEFAULT_MIN_HIGHLIGHTS = 3
+)
+
+// kindleMetadataRegex matches the metadata line of a Kindle clipping, e.g.
+// "- Your Highlight on page 12 | Location 180-182 | Added on Monday, March 4,
+// 2024 9:12:33 PM".
+var kindleMetadataRegex = regexp.MustCompile(`^- Your (\w+)(?: on| at)? (.*?)\s*\|?\s*Added on (.+)$`)
+
+// kindleAuthorRegex matches the author at the end of the title line of a
+// Kindle clipping, between parentheses.
+var kindleAuthorRegex = regexp.MustCompile(`^(.*?)\s*\(([^()]*)\)$`)
+
+// Highlight is a passage highlighted while reading, with the note written on
+// it, if any. Notes written apart from a highlight have no text.
+type Highlight struct {
+	Text     string
+	Note     string
+	Location string
+	Added    time.Time
+}
+
+// Quote returns the text of the highlight as a markdown block quote.
+func (h Highlight) Quote() string {
+	if h.Text == "" {
+		return ""
+	}
+	lines := strings.Split(h.Text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// HighlightedDocument is a book or an article with its highlights, in reading
+// order.
+type HighlightedDocument struct {
+	Title      string
+	Author     string
+	Highlights []Highlight
+}
+
+// HighlightsTemplateData is the data model of the highlights template.
+type HighlightsTemplateData struct {
+	Id         string
+	Title      string
+	Author     string
+	Source     string
+	Date       string
+	Summary    summarize.Summary
+	Highlights []Highlight
+}
+
+func runHighlights(config Config, args []string) error {
+	flags := flag.NewFlagSet("highlights", flag.ExitOnError)
+	book := flags.String("book", "", "only the books and articles whose title contains this text")
+	minHighlights := flags.Int("min", DEFAULT_MIN_HIGHLIGHTS, "skip the books and articles with fewer highlights")
+	dryRun := flags.Bool("dry-run", false, "list the books and articles and their number of highlights, without summarizing them")
+	providerFlags := addProviderFlags(flags, config)
+	permissions := addPermissionFlags(flags)
+	addRetryFlags(flags, &providerFlags.options.Retry)
+	flags.Usage = func() {
+		fmt.Println("Usage: report highlights [-book title] [-min 3] [-dry-run] <output-folder> <My Clippings.txt|readwise.csv>")
+		fmt.Println("Write a report per book or article of a Kindle clippings file or a Readwise export, combining the highlights with a synthesis by the LLM.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 2)
+	if len(args) != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder and a highlights file")
+	}
+	outputFolder := args[0]
+
+	documents, source, err := loadHighlights(args[1])
+	if err != nil {
+		return err
+	}
+	var selected []HighlightedDocument
+	for _, document := range documents {
+		if len(document.Highlights) < *minHighlights {
+			continue
+		}
+		if *book != "" && !strings.Contains(strings.ToLower(document.Title), strings.ToLower(*book)) {
+			continue
+		}
+		selected = append(selected, document)
+	}
+	fmt.Printf("%s: %d books and articles, %d with at least %d highlights\n", args[1], len(documents), len(selected), *minHighlights)
+
+	if *dryRun {
+		for _, document := range selected {
+			fmt.Printf("  %3d  %s\n", len(document.Highlights), highlightedTitle(document))
+		}
+		return nil
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+
+	provider, err := providerFlags.provider()
+	if err != nil {
+		return err
+	}
+	tokenizers, err := summarize.NewTokenizers(config.Tokenizers)
+	if err != nil {
+		return err
+	}
+	if err := permissions.Mkdir(outputFolder); err != nil {
+		return fmt.Errorf("creating output folder: %w", err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	ctx = summarize.WithTerminalProgress(ctx, *providerFlags.progress, 1)
+
+	failed := 0
+	for i, document := range selected {
+		if ctx.Err() != nil {
+			fmt.Printf("Interrupted, %d books and articles not summarized\n", len(selected)-i)
+			failed += len(selected) - i
+			break
+		}
+		path, err := writeHighlightsReport(ctx, provider, tokenizers, outputFolder, *permissions, document, source, time.Now())
+		if err != nil {
+			slog.Warn("could not summarize the highlights", "title", document.Title, "err", err)
+			failed++
+			continue
+		}
+		slog.Info("Highlights report written", "path", path)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d books and articles could not be summarized", failed)
+	}
+	return nil
+}
+
+func highlightedTitle(document HighlightedDocument) string {
+	if document.Author == "" {
+		return document.Title
+	}
+	return document.Title + " (" + document.Author + ")"
+}
+
+// loadHighlights reads a Kindle clippings file or a Readwise CSV export,
+// told apart by their extension, and returns the highlighted documents in the
+// order of the file and the source of the highlights.
+func loadHighlights(path string) ([]HighlightedDocument, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading highlights: %w", err)
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		documents, err := parseReadwiseCsv(file)
+		if err != nil {
+			return nil, "", fmt.Errorf("parsing Readwise export '%s': %w", path, err)
+		}
+		return documents, HIGHLIGHTS_READWISE, nil
+	}
+	documents, err := parseKindleClippings(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing Kindle clippings '%s': %w", path, err)
+	}
+	return documents, HIGHLIGHTS_KINDLE, nil
+}
+
+// parseKindleClippings parses the "My Clippings.txt" file of a Kindle, whose
+// entries are a title line, a metadata line, a blank line and the text,
+// separated by KINDLE_CLIPPING_SEPARATOR lines. Bookmarks are ignored, notes
+// are attached to the highlight they end, and highlights that were extended
+// later are only kept in their last version.
+func parseKindleClippings(reader io.Reader) ([]HighlightedDocument, error) {
+	var documents []HighlightedDocument
+	documentIndex := map[string]int{}
+
+	var entry []string
+	addEntry := func() {
+		defer func() { entry = nil }()
+		// The Kindle starts every entry with a byte order mark
+		for len(entry) > 0 && strings.TrimSpace(strings.TrimPrefix(entry[0], export.UTF8_BOM)) == "" {
+			entry = entry[1:]
+		}
+		if len(entry) < 2 {
+			return
+		}
+		match := kindleMetadataRegex.FindStringSubmatch(strings.TrimSpace(entry[1]))
+		if match == nil {
+			return
+		}
+		kind := strings.ToLower(match[1])
+		if kind != "highlight" && kind != "note" {
+			return
+		}
+
+		title, author := strings.TrimSpace(strings.TrimPrefix(entry[0], export.UTF8_BOM)), ""
+		if authorMatch := kindleAuthorRegex.FindStringSubmatch(title); authorMatch != nil {
+			title, author = authorMatch[1], authorMatch[2]
+		}
+		highlight := Highlight{Location: strings.TrimSpace(strings.Trim(match[2], "| "))}
+		highlight.Added, _ = time.Parse(KINDLE_DATE_FORMAT, strings.TrimSpace(match[3]))
+		text := strings.TrimSpace(strings.Join(entry[2:], "\n"))
+		if text == "" {
+			return
+		}
+
+		i, found := documentIndex[title]
+		if !found {
+			i = len(documents)
+			documentIndex[title] = i
+			documents = append(documents, HighlightedDocument{Title: title, Author: author})
+		}
+		document := &documents[i]
+
+		if kind == "note" {
+			if last := len(document.Highlights) - 1; last >= 0 && document.Highlights[last].Note == "" && endsAtLocation(document.Highlights[last].Location, highlight.Location) {
+				document.Highlights[last].Note = text
+				return
+			}
+			highlight.Note = text
+			document.Highlights = append(document.Highlights, highlight)
+			return
+		}
+
+		highlight.Text = text
+		// Extending a highlight adds a new clipping holding the old one
+		kept := document.Highlights[:0]
+		for _, previous := range document.Highlights {
+			if previous.Text != "" && previous.Note == "" && strings.Contains(text, previous.Text) {
+				continue
+			}
+			kept = append(kept, previous)
+		}
+		document.Highlights = append(kept, highlight)
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == KINDLE_CLIPPING_SEPARATOR {
+			addEntry()
+			continue
+		}
+		entry = append(entry, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	addEntry()
+	return documents, nil
+}
+
+// endsAtLocation tells if a highlight location, such as "page 12 | Location
+// 180-182", ends where a note is, such as "page 12 | Location 182".
+func endsAtLocation(highlightLocation, noteLocation string) bool {
+	lastNumber := func(location string) string {
+		fields := strings.FieldsFunc(location, func(r rune) bool { return r < '0' || r > '9' })
+		if len(fields) == 0 {
+			return ""
+		}
+		return fields[len(fields)-1]
+	}
+	end := lastNumber(highlightLocation)
+	return end != "" && end == lastNumber(noteLocation)
+}
+
+// parseReadwiseCsv parses the CSV export of Readwise, a highlight per row
+// with its book title and author, note, location and date.
+func parseReadwiseCsv(reader io.Reader) ([]HighlightedDocument, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, export.UTF8_BOM)))] = i
+	}
+	for _, required := range []string{"highlight", "book title"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("no '%s' column", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var documents []HighlightedDocument
+	documentIndex := map[string]int{}
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		title := field(record, "book title")
+		highlight := Highlight{Text: field(record, "highlight"), Note: field(record, "note")}
+		if title == "" || (highlight.Text == "" && highlight.Note == "") {
+			continue
+		}
+		if location := field(record, "location"); location != "" {
+			highlight.Location = strings.TrimSpace(field(record, "location type") + " " + location)
+		}
+		highlight.Added, _ = time.Parse("2006-01-02 15:04:05-07:00", field(record, "highlighted at"))
+
+		i, found := documentIndex[title]
+		if !found {
+			i = len(documents)
+			documentIndex[title] = i
+			documents = append(documents, HighlightedDocument{Title: title, Author: field(record, "book author")})
+		}
+		documents[i].Highlights = append(documents[i].Highlights, highlight)
+	}
+	return documents, nil
+}
+
+// writeHighlightsReport summarizes the highlights of a document and writes its
+// report, named after its title. A report written before is updated,
+// keeping what the user added to it (see export.MergeReport).
+func writeHighlightsReport(ctx context.Context, provider summarize.LLMProvider, tokenizers *summarize.Tokenizers, outputFolder string, permissions export.FilePermissions, document HighlightedDocument, source string, now time.Time) (string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n", highlightedTitle(document))
+	for _, highlight := range document.Highlights {
+		sb.WriteString("\n")
+		if highlight.Text != "" {
+			sb.WriteString(highlight.Quote() + "\n")
+		}
+		if highlight.Note != "" {
+			fmt.Fprintf(&sb, "My note: %s\n", highlight.Note)
+		}
+	}
+	article := export.Article{Article: scrape.Article{Title: document.Title, Content: sb.String()}}
+	if document.Author != "" {
+		article.Authors = []string{document.Author}
+	}
+
+	summary, err := summarize.Summarize(ctx, provider, tokenizers, article.Article, highlightsPrompt, summarize.SummaryConstraints{}, summarize.DEFAULT_CHUNK_TOKENS)
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	tmpl, err := template.New("highlights").Funcs(export.TemplateFuncs).Parse(highlightsTemplate)
+	if err != nil {
+		return "", fmt.Errorf("parsing highlights template: %w", err)
+	}
+	var content strings.Builder
+	err = tmpl.Execute(&content, HighlightsTemplateData{
+		Id:         export.NewReportId(now),
+		Title:      document.Title,
+		Author:     document.Author,
+		Source:     source,
+		Date:       now.Format(scrape.DATE_FORMAT),
+		Summary:    summary,
+		Highlights: document.Highlights,
+	})
+	if err != nil {
+		return "", fmt.Errorf("rendering highlights template: %w", err)
+	}
+
+	// Apart from the report of an article of the same title
+	path := filepath.Join(outputFolder, export.SanitizeFilename(document.Title+" - Highlights")+".md")
+	report := content.String()
+	existing, err := os.ReadFile(path)
+	if err == nil {
+		report = export.MergeReport(strings.TrimPrefix(export.NormalizeLineEndings(string(existing)), export.UTF8_BOM), report)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("reading report: %w", err)
+	}
+
+	if err := permissions.WriteFile(path, []byte(report)); err != nil {
+		return "", fmt.Errorf("writing output file: %v", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,322 @@
+package cli
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const (
+	IMPORT_SAFARI = "safari"
+	IMPORT_CHROME = "chrome"
+	// Folder of the Safari bookmarks holding the Reading List
+	SAFARI_READING_LIST_FOLDER = "com.apple.ReadingList"
+	// Microseconds between 1601-01-01, the epoch of the Chrome timestamps, and
+	// 1970-01-01
+	CHROME_EPOCH_OFFSET_MICROSECONDS = 11644473600000000
+)
+
+// ReadingListEntry is a page saved to be read later in a browser.
+type ReadingListEntry struct {
+	Url   string
+	Title string
+	// Date the page was saved, zero if the browser does not record it
+	Added time.Time
+	// Tags given to the page by the user, only kept by some services
+	Tags []string
+}
+
+// ChromeBookmarkNode is a folder or a bookmark of the Chrome bookmarks file.
+type ChromeBookmarkNode struct {
+	Type      string               `json:"type"`
+	Name      string               `json:"name"`
+	Url       string               `json:"url"`
+	DateAdded string               `json:"date_added"`
+	Children  []ChromeBookmarkNode `json:"children"`
+}
+
+type ChromeBookmarks struct {
+	Roots map[string]json.RawMessage `json:"roots"`
+}
+
+func runImport(config Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	source := flags.String("from", "", "browser to import from: "+IMPORT_SAFARI+" (its Reading List) or "+IMPORT_CHROME+" (a bookmarks folder)")
+	folder := flags.String("folder", "", "bookmarks folder to import, by name or path (e.g. 'Bookmarks bar/To read'), required for "+IMPORT_CHROME)
+	dryRun := flags.Bool("dry-run", false, "list the entries without summarizing them")
+	reportFlags := addReportFlags(flags, config)
+	flags.Usage = func() {
+		fmt.Println("Usage: report import -from safari|chrome [-folder name] [options] <output-folder> [bookmarks-file]")
+		fmt.Println("Summarize the pages of a browser reading list or bookmarks folder, oldest first, keeping the date they were added.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) == 0 || len(args) > 2 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder and an optional bookmarks file")
+	}
+	if *source != IMPORT_SAFARI && *source != IMPORT_CHROME {
+		flags.Usage()
+		return fmt.Errorf("unknown browser '%s', expected %s or %s", *source, IMPORT_SAFARI, IMPORT_CHROME)
+	}
+	outputFolder := args[0]
+
+	path := ""
+	if len(args) == 2 {
+		path = args[1]
+	} else {
+		var err error
+		if path, err = defaultBookmarksFile(*source); err != nil {
+			return err
+		}
+	}
+
+	var entries []ReadingListEntry
+	var err error
+	if *source == IMPORT_SAFARI {
+		entries, err = readSafariBookmarks(path, cmp.Or(*folder, SAFARI_READING_LIST_FOLDER))
+	} else {
+		if *folder == "" {
+			flags.Usage()
+			return fmt.Errorf("expected the -folder of the Chrome bookmarks to import")
+		}
+		entries, err = readChromeBookmarks(path, *folder)
+	}
+	if err != nil {
+		return err
+	}
+	entries = sortReadingList(entries)
+	fmt.Printf("%s: %d entries\n", path, len(entries))
+
+	if *dryRun {
+		for _, entry := range entries {
+			added := "unknown date"
+			if !entry.Added.IsZero() {
+				added = entry.Added.Format(scrape.DATE_FORMAT)
+			}
+			fmt.Printf("  %s  %s  %s\n", added, entry.Url, entry.Title)
+		}
+		return nil
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	options, err := reportFlags.options(outputFolder)
+	if err != nil {
+		return err
+	}
+	defer options.Events.Close()
+	options.NonInteractive = true
+	options.AddedDates = map[string]time.Time{}
+	articleUrls := make([]string, len(entries))
+	for i, entry := range entries {
+		articleUrls[i] = entry.Url
+		options.AddedDates[entry.Url] = entry.Added
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	ctx = summarize.WithTerminalProgress(ctx, *reportFlags.provider.progress, *reportFlags.concurrency)
+
+	client, err := report.New(report.WithReportOptions(options))
+	if err != nil {
+		return err
+	}
+	results := client.CreateAll(ctx, articleUrls, *reportFlags.concurrency)
+	if !printBatchSummary(results) {
+		return fmt.Errorf("some articles failed")
+	}
+	return nil
+}
+
+// defaultBookmarksFile returns the bookmarks file of the default profile of a
+// browser.
+func defaultBookmarksFile(source string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case source == IMPORT_SAFARI && runtime.GOOS == "darwin":
+		return filepath.Join(home, "Library", "Safari", "Bookmarks.plist"), nil
+	case source == IMPORT_SAFARI:
+		return "", fmt.Errorf("Safari bookmarks are only found on macOS, give the path of a copy of Bookmarks.plist")
+	case runtime.GOOS == "darwin":
+		return filepath.Join(home, "Library", "Application Support", "Google", "Chrome", "Default", "Bookmarks"), nil
+	case runtime.GOOS == "windows":
+		return filepath.Join(os.Getenv("LOCALAPPDATA"), "Google", "Chrome", "User Data", "Default", "Bookmarks"), nil
+	}
+	return filepath.Join(home, ".config", "google-chrome", "Default", "Bookmarks"), nil
+}
+
+// sortReadingList returns the entries with a valid url, without duplicates,
+// oldest first, the entries without a date keeping their order at the end.
+func sortReadingList(entries []ReadingListEntry) []ReadingListEntry {
+	var valid []ReadingListEntry
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Url, "http://") && !strings.HasPrefix(entry.Url, "https://") {
+			continue
+		}
+		key := scrape.CanonicalUrl(entry.Url)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		valid = append(valid, entry)
+	}
+	slices.SortStableFunc(valid, func(a, b ReadingListEntry) int {
+		switch {
+		case a.Added.IsZero() && b.Added.IsZero():
+			return 0
+		case a.Added.IsZero():
+			return 1
+		case b.Added.IsZero():
+			return -1
+		}
+		return a.Added.Compare(b.Added)
+	})
+	return valid
+}
+
+// readSafariBookmarks returns the bookmarks of a folder of the Safari
+// bookmarks, Bookmarks.plist, and of its subfolders. The entries of the
+// Reading List have the date they were added.
+func readSafariBookmarks(path, folder string) ([]ReadingListEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading Safari bookmarks: %w", err)
+	}
+	root, err := parsePlist(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Safari bookmarks '%s': %w", path, err)
+	}
+
+	var entries []ReadingListEntry
+	found := false
+	var walk func(node map[string]any, folderPath string, inFolder bool)
+	walk = func(node map[string]any, folderPath string, inFolder bool) {
+		if url, ok := node["URLString"].(string); ok {
+			if inFolder {
+				entries = append(entries, safariEntry(node, url))
+			}
+			return
+		}
+		if title, ok := node["Title"].(string); ok && title != "" {
+			folderPath = strings.TrimPrefix(folderPath+"/"+title, "/")
+			if isBookmarksFolder(folderPath, title, folder) {
+				inFolder = true
+				found = true
+			}
+		}
+		children, _ := node["Children"].([]any)
+		for _, child := range children {
+			if childNode, ok := child.(map[string]any); ok {
+				walk(childNode, folderPath, inFolder)
+			}
+		}
+	}
+	rootNode, ok := root.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("parsing Safari bookmarks '%s': unexpected root", path)
+	}
+	walk(rootNode, "", false)
+	if !found {
+		return nil, fmt.Errorf("no folder '%s' in the Safari bookmarks", folder)
+	}
+	return entries, nil
+}
+
+func safariEntry(node map[string]any, url string) ReadingListEntry {
+	entry := ReadingListEntry{Url: url}
+	if uriDictionary, ok := node["URIDictionary"].(map[string]any); ok {
+		entry.Title, _ = uriDictionary["title"].(string)
+	}
+	if readingList, ok := node["ReadingList"].(map[string]any); ok {
+		entry.Added, _ = readingList["DateAdded"].(time.Time)
+	}
+	return entry
+}
+
+// readChromeBookmarks returns the bookmarks of a folder of the Chrome
+// bookmarks file, and of its subfolders, with the date they were added.
+func readChromeBookmarks(path, folder string) ([]ReadingListEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading Chrome bookmarks: %w", err)
+	}
+	var bookmarks ChromeBookmarks
+	if err := json.Unmarshal(data, &bookmarks); err != nil {
+		return nil, fmt.Errorf("parsing Chrome bookmarks '%s': %w", path, err)
+	}
+
+	var entries []ReadingListEntry
+	found := false
+	var walk func(node ChromeBookmarkNode, folderPath string, inFolder bool)
+	walk = func(node ChromeBookmarkNode, folderPath string, inFolder bool) {
+		if node.Type == "url" {
+			if inFolder {
+				entries = append(entries, ReadingListEntry{Url: node.Url, Title: node.Name, Added: parseChromeTime(node.DateAdded)})
+			}
+			return
+		}
+		folderPath = strings.TrimPrefix(folderPath+"/"+node.Name, "/")
+		if isBookmarksFolder(folderPath, node.Name, folder) {
+			inFolder = true
+			found = true
+		}
+		for _, child := range node.Children {
+			walk(child, folderPath, inFolder)
+		}
+	}
+	// The roots also hold the sync metadata, which are not folders
+	rootNames := make([]string, 0, len(bookmarks.Roots))
+	for name := range bookmarks.Roots {
+		rootNames = append(rootNames, name)
+	}
+	slices.Sort(rootNames)
+	for _, name := range rootNames {
+		var root ChromeBookmarkNode
+		if err := json.Unmarshal(bookmarks.Roots[name], &root); err != nil || root.Type != "folder" {
+			continue
+		}
+		walk(root, "", false)
+	}
+	if !found {
+		return nil, fmt.Errorf("no folder '%s' in the Chrome bookmarks", folder)
+	}
+	return entries, nil
+}
+
+// isBookmarksFolder tells if a folder, given by its path and name, is the one
+// asked for by name or by the end of its path, ignoring the case.
+func isBookmarksFolder(path, name, folder string) bool {
+	folder = strings.Trim(folder, "/")
+	return strings.EqualFold(name, folder) || strings.EqualFold(path, folder) ||
+		strings.HasSuffix(strings.ToLower(path), "/"+strings.ToLower(folder))
+}
+
+// parseChromeTime returns the time of a Chrome timestamp, in microseconds
+// since 1601, zero if it is not set.
+func parseChromeTime(timestamp string) time.Time {
+	microseconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || microseconds <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(microseconds - CHROME_EPOCH_OFFSET_MICROSECONDS).UTC()
+}
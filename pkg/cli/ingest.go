@@ -0,0 +1,307 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const FEEDS_FILE_NAME = "feeds.json"
+
+// FeedDocument holds the entries of an RSS 2.0, RSS 1.0 (RDF) or Atom feed,
+// only one of the entry lists being filled depending on the format.
+type FeedDocument struct {
+	XMLName xml.Name
+	Channel struct {
+		Items []FeedItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []FeedItem  `xml:"item"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+type FeedItem struct {
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+	Guid  struct {
+		Value       string `xml:",chardata"`
+		IsPermaLink string `xml:"isPermaLink,attr"`
+	} `xml:"guid"`
+}
+
+type AtomEntry struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+}
+
+// FeedState records, per feed url, the entries that were already processed,
+// so that entries skipped by the content filters are not fetched again.
+type FeedState struct {
+	Feeds map[string][]string `json:"feeds"`
+}
+
+func runIngest(config Config, args []string) error {
+	flags := flag.NewFlagSet("ingest", flag.ExitOnError)
+	limit := flags.Int("n", 10, "maximum number of new entries summarized per feed, 0 for all")
+	dryRun := flags.Bool("dry-run", false, "list the new entries without summarizing them")
+	reportFlags := addReportFlags(flags, config)
+	flags.Usage = func() {
+		fmt.Println("Usage: report ingest [options] <output-folder> <feed-url>...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = flags.Args()
+	if len(args) == 1 {
+		args = config.withDefaultOutputFolder(args, 2)
+	}
+	if len(args) < 2 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder and feed urls")
+	}
+	outputFolder := args[0]
+
+	options, err := reportFlags.options(outputFolder)
+	if err != nil {
+		return err
+	}
+	defer options.Events.Close()
+	options.NonInteractive = true
+	client, err := report.New(report.WithReportOptions(options))
+	if err != nil {
+		return err
+	}
+
+	state, err := loadFeedState(outputFolder)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	ctx = summarize.WithTerminalProgress(ctx, *reportFlags.provider.progress, *reportFlags.concurrency)
+
+	processed := map[string]bool{}
+	failed := false
+	for _, feedUrl := range args[1:] {
+		if ctx.Err() != nil {
+			return fmt.Errorf("interrupted: %w", ctx.Err())
+		}
+
+		entryUrls, err := fetchFeedEntries(ctx, options.Scraper, feedUrl)
+		if err != nil {
+			slog.Error("reading feed failed", "feed", feedUrl, "err", err)
+			failed = true
+			continue
+		}
+
+		for _, entryUrl := range state.Feeds[feedUrl] {
+			processed[entryUrl] = true
+		}
+		index := options.Index
+		if options.Force {
+			index = nil
+		}
+		newUrls := newFeedEntries(entryUrls, processed, index, *limit)
+		slog.Info("Feed read", "feed", feedUrl, "entries", len(entryUrls), "new", len(newUrls))
+		if len(newUrls) == 0 || *dryRun {
+			for _, entryUrl := range newUrls {
+				fmt.Printf("  %s\n", entryUrl)
+			}
+			continue
+		}
+
+		results := client.Derive(func(options *report.ReportOptions) {
+			options.Feed = feedUrl
+		}).CreateAll(ctx, newUrls, *reportFlags.concurrency)
+		if !printBatchSummary(results) {
+			failed = true
+		}
+
+		// Failed entries are left out so that they are retried on the next run
+		for _, result := range results {
+			if result.Status != report.RESULT_FAILED {
+				state.Feeds[feedUrl] = append(state.Feeds[feedUrl], result.Url)
+				processed[result.Url] = true
+			}
+		}
+		if err := state.save(outputFolder, options.Permissions); err != nil {
+			return err
+		}
+	}
+
+	if failed {
+		return fmt.Errorf("some feeds or entries failed")
+	}
+	return nil
+}
+
+// fetchFeedEntries returns the article urls of a feed, in the order of the
+// feed, fetched by the scraper.
+func fetchFeedEntries(ctx context.Context, scraper *scrape.Scraper, feedUrl string) ([]string, error) {
+	var page string
+	err := scraper.Retry.Do(ctx, "fetching "+feedUrl, func() error {
+		var err error
+		page, err = scraper.FetchUrlAndReturnPage(ctx, feedUrl)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := url.Parse(feedUrl)
+	if err != nil {
+		return nil, fmt.Errorf("parsing feed url: %w", err)
+	}
+
+	return parseFeedEntries(page, base)
+}
+
+func parseFeedEntries(content string, base *url.URL) ([]string, error) {
+	var document FeedDocument
+	if err := xml.Unmarshal([]byte(content), &document); err != nil {
+		return nil, fmt.Errorf("parsing feed: %w", err)
+	}
+
+	var links []string
+	switch document.XMLName.Local {
+	case "rss":
+		for _, item := range document.Channel.Items {
+			links = append(links, item.link())
+		}
+	case "RDF":
+		for _, item := range document.Items {
+			links = append(links, item.link())
+		}
+	case "feed":
+		for _, entry := range document.Entries {
+			links = append(links, entry.link())
+		}
+	default:
+		return nil, fmt.Errorf("unknown feed format '%s', expected an RSS or Atom feed", document.XMLName.Local)
+	}
+
+	var entryUrls []string
+	seen := map[string]bool{}
+	for _, link := range links {
+		if link == "" {
+			continue
+		}
+		resolved, err := base.Parse(link)
+		if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+			slog.Warn("ignoring feed entry with invalid link", "link", link)
+			continue
+		}
+		if !seen[resolved.String()] {
+			seen[resolved.String()] = true
+			entryUrls = append(entryUrls, resolved.String())
+		}
+	}
+
+	return entryUrls, nil
+}
+
+func (i FeedItem) link() string {
+	if link := strings.TrimSpace(i.Link); link != "" {
+		return link
+	}
+	if i.Guid.IsPermaLink != "false" {
+		return strings.TrimSpace(i.Guid.Value)
+	}
+	return ""
+}
+
+func (e AtomEntry) link() string {
+	for _, link := range e.Links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	return ""
+}
+
+// newFeedEntries returns the entries that were neither processed nor indexed
+// yet, at most limit of them if limit is positive. Feeds list their latest
+// entries first.
+func newFeedEntries(entryUrls []string, processed map[string]bool, index *export.ArticleIndex, limit int) []string {
+	var newUrls []string
+	for _, entryUrl := range entryUrls {
+		if _, indexed := index.Lookup(entryUrl); indexed || processed[entryUrl] {
+			continue
+		}
+		if limit > 0 && len(newUrls) == limit {
+			break
+		}
+		newUrls = append(newUrls, entryUrl)
+	}
+	return newUrls
+}
+
+func feedStatePath(outputFolder string) (string, error) {
+	folder, err := export.ReportDataFolder(outputFolder)
+	if err != nil {
+		return "", fmt.Errorf("finding data folder: %w", err)
+	}
+	return filepath.Join(folder, FEEDS_FILE_NAME), nil
+}
+
+func loadFeedState(outputFolder string) (*FeedState, error) {
+	state := &FeedState{Feeds: map[string][]string{}}
+
+	path, err := feedStatePath(outputFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading feed state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("unmarshaling feed state: %w", err)
+	}
+	if state.Feeds == nil {
+		state.Feeds = map[string][]string{}
+	}
+	return state, nil
+}
+
+func (s *FeedState) save(outputFolder string, permissions export.FilePermissions) error {
+	path, err := feedStatePath(outputFolder)
+	if err != nil {
+		return err
+	}
+	if err := permissions.Mkdir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("creating data folder: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling feed state: %w", err)
+	}
+
+	if err := permissions.WriteFile(path, data); err != nil {
+		return fmt.Errorf("writing feed state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/report"
+)
+
+// Statuses of the jobs of the server
+const (
+	JOB_RUNNING = "running"
+	JOB_DONE    = "done"
+	JOB_FAILED  = "failed"
+)
+
+// Time a finished job is kept, for its client to get its result
+const JOB_RETENTION = time.Hour
+
+// JobResponse is the state of a job, with the progress events of its article
+// and, once done, the response of /summarize.
+type JobResponse struct {
+	Id      string                 `json:"id"`
+	Status  string                 `json:"status"`
+	Url     string                 `json:"url"`
+	Created time.Time              `json:"created"`
+	Events  []report.ProgressEvent `json:"events"`
+	Result  *SummarizeResponse     `json:"result,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+	// Status of the response the request would have had without async
+	StatusCode int `json:"status_code,omitempty"`
+}
+
+// Job is a summary requested with async, run in the background.
+type Job struct {
+	mu       sync.Mutex
+	response JobResponse
+	// Name of the key that created the job, the only one seeing it with the
+	// admin keys
+	key      string
+	finished time.Time
+	// Closed at each change of the job, and replaced, to wake the event
+	// streams
+	changed chan struct{}
+}
+
+// JobStore holds the jobs of the server, until JOB_RETENTION after they end.
+type JobStore struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	running sync.WaitGroup
+}
+
+func newJobStore() *JobStore {
+	return &JobStore{jobs: map[string]*Job{}}
+}
+
+// start runs a job in the background, the summary being made by run with a
+// context giving the progress events to the job.
+func (s *JobStore) start(ctx context.Context, keyName, articleUrl string, run func(ctx context.Context) (int, any)) *Job {
+	now := time.Now()
+	job := &Job{
+		response: JobResponse{Id: export.NewReportId(now), Status: JOB_RUNNING, Url: articleUrl, Created: now, Events: []report.ProgressEvent{}},
+		key:      keyName,
+		changed:  make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	for id, other := range s.jobs {
+		if other.isExpired(now) {
+			delete(s.jobs, id)
+		}
+	}
+	s.jobs[job.response.Id] = job
+	s.mu.Unlock()
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		statusCode, body := runJob(report.WithProgressListener(ctx, job.addEvent), articleUrl, run)
+		job.finish(statusCode, body)
+	}()
+	return job
+}
+
+// runJob runs the summary of a job. A panic fails the job, and not the whole
+// server with the other jobs: the server only recovers the panics of the
+// goroutines of the requests.
+func runJob(ctx context.Context, articleUrl string, run func(ctx context.Context) (int, any)) (statusCode int, body any) {
+	defer func() {
+		if err := report.NewPanicError(recover(), articleUrl); err != nil {
+			statusCode, body = http.StatusInternalServerError, ErrorResponse{Error: "internal error, see the crash report of the server"}
+		}
+	}()
+	return run(ctx)
+}
+
+// get returns a job, if the key can see it.
+func (s *JobStore) get(id string, apiKey ApiKeyConfig) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok || job.isExpired(time.Now()) || (job.key != apiKey.Name && !apiKey.Admin) {
+		return nil, false
+	}
+	return job, true
+}
+
+// wait waits for the running jobs to end.
+func (s *JobStore) wait() {
+	s.running.Wait()
+}
+
+func (j *Job) isExpired(now time.Time) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return !j.finished.IsZero() && now.Sub(j.finished) > JOB_RETENTION
+}
+
+func (j *Job) addEvent(event report.ProgressEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.response.Events = append(j.response.Events, event)
+	j.notify()
+}
+
+// finish records the response of the summary.
+func (j *Job) finish(statusCode int, body any) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.response.StatusCode = statusCode
+	j.response.Status = JOB_DONE
+	switch body := body.(type) {
+	case SummarizeResponse:
+		j.response.Result = &body
+	case ErrorResponse:
+		j.response.Status = JOB_FAILED
+		j.response.Error = body.Error
+	}
+	j.finished = time.Now()
+	j.notify()
+}
+
+// notify wakes the event streams, the lock of the job being held.
+func (j *Job) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// state returns a copy of the response of the job, the channel closed at its
+// next change, and whether it is finished.
+func (j *Job) state() (JobResponse, <-chan struct{}, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	response := j.response
+	response.Events = append([]report.ProgressEvent{}, j.response.Events...)
+	return response, j.changed, !j.finished.IsZero()
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.get(r.PathValue("id"), requestApiKey(r))
+	if !ok {
+		writeJsonError(w, http.StatusNotFound, fmt.Errorf("no job with ID '%s'", r.PathValue("id")))
+		return
+	}
+	response, _, _ := job.state()
+	writeJson(w, http.StatusOK, response)
+}
+
+// handleJobEvents streams the progress events of a job as server-sent events,
+// the past ones first, named after their type, then a done event with the
+// state of the job once it ends.
+func (s *Server) handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.get(r.PathValue("id"), requestApiKey(r))
+	if !ok {
+		writeJsonError(w, http.StatusNotFound, fmt.Errorf("no job with ID '%s'", r.PathValue("id")))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJsonError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	sent := 0
+	for {
+		response, changed, finished := job.state()
+		for ; sent < len(response.Events); sent++ {
+			writeServerSentEvent(w, sent, response.Events[sent].Event, response.Events[sent])
+		}
+		if finished {
+			writeServerSentEvent(w, sent, "done", response)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeServerSentEvent(w http.ResponseWriter, id int, event string, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event, data)
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/brequet/report/internal/paths"
+	"github.com/brequet/report/pkg/report"
+)
+
+func TestJobStore(t *testing.T) {
+	t.Setenv(paths.STATE_DIR_ENV, t.TempDir())
+	// Without a target, the events are only given to the job
+	var events *report.ProgressEvents
+	tests := []struct {
+		name       string
+		run        func(ctx context.Context) (int, any)
+		wantStatus string
+		wantCode   int
+		wantError  string
+	}{
+		{
+			name: "done",
+			run: func(ctx context.Context) (int, any) {
+				events.Emit(ctx, report.ProgressEvent{Event: report.EVENT_STARTED})
+				return http.StatusOK, SummarizeResponse{}
+			},
+			wantStatus: JOB_DONE,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name: "failed",
+			run: func(ctx context.Context) (int, any) {
+				events.Emit(ctx, report.ProgressEvent{Event: report.EVENT_STARTED})
+				return http.StatusBadGateway, ErrorResponse{Error: "fetching failed"}
+			},
+			wantStatus: JOB_FAILED,
+			wantCode:   http.StatusBadGateway,
+			wantError:  "fetching failed",
+		},
+		{
+			name: "panicked",
+			run: func(ctx context.Context) (int, any) {
+				events.Emit(ctx, report.ProgressEvent{Event: report.EVENT_STARTED})
+				panic("boom")
+			},
+			wantStatus: JOB_FAILED,
+			wantCode:   http.StatusInternalServerError,
+			wantError:  "internal error, see the crash report of the server",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newJobStore()
+			job := store.start(context.Background(), "alice", "https://example.com/", test.run)
+			store.wait()
+
+			response, _, finished := job.state()
+			if !finished || response.Status != test.wantStatus || response.StatusCode != test.wantCode || response.Error != test.wantError {
+				t.Errorf("job = %s %d %q, finished %v, want %s %d %q", response.Status, response.StatusCode, response.Error, finished, test.wantStatus, test.wantCode, test.wantError)
+			}
+			if (response.Result != nil) != (test.wantStatus == JOB_DONE) {
+				t.Errorf("job result = %v, want one only when done", response.Result)
+			}
+			if len(response.Events) != 1 || response.Events[0].Event != report.EVENT_STARTED {
+				t.Errorf("job events = %+v, want the started event", response.Events)
+			}
+		})
+	}
+}
+
+func TestJobStoreGet(t *testing.T) {
+	store := newJobStore()
+	job := store.start(context.Background(), "alice", "https://example.com/", func(ctx context.Context) (int, any) {
+		return http.StatusOK, SummarizeResponse{}
+	})
+	store.wait()
+	id := job.response.Id
+
+	tests := []struct {
+		name   string
+		id     string
+		apiKey ApiKeyConfig
+		found  bool
+	}{
+		{"creator", id, ApiKeyConfig{Name: "alice"}, true},
+		{"other key", id, ApiKeyConfig{Name: "bob"}, false},
+		{"admin key", id, ApiKeyConfig{Name: "root", Admin: true}, true},
+		{"unknown job", "01HXAAAAAAAAAAAAAAAAAAAAAA", ApiKeyConfig{Name: "alice"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, found := store.get(test.id, test.apiKey); found != test.found {
+				t.Errorf("get found = %v, want %v", found, test.found)
+			}
+		})
+	}
+}
+
+func TestHandleJobEvents(t *testing.T) {
+	var progress *report.ProgressEvents
+	server := &Server{jobs: newJobStore()}
+	job := server.jobs.start(context.Background(), "", "https://example.com/", func(ctx context.Context) (int, any) {
+		progress.Emit(ctx, report.ProgressEvent{Event: report.EVENT_STARTED})
+		progress.Emit(ctx, report.ProgressEvent{Event: report.EVENT_FETCHED})
+		return http.StatusOK, SummarizeResponse{}
+	})
+
+	request := httptest.NewRequest("GET", "/jobs/"+job.response.Id+"/events", nil)
+	request.SetPathValue("id", job.response.Id)
+	recorder := httptest.NewRecorder()
+	// The stream ends once the job is done
+	server.handleJobEvents(recorder, request)
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", contentType)
+	}
+	body := recorder.Body.String()
+	var events []string
+	for _, line := range strings.Split(body, "\n") {
+		if event, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, event)
+		}
+	}
+	if strings.Join(events, ",") != "started,fetched,done" {
+		t.Errorf("events = %v, want started, fetched and done:\n%s", events, body)
+	}
+	if !strings.Contains(body, `"status":"done"`) {
+		t.Errorf("done event without the state of the job:\n%s", body)
+	}
+}
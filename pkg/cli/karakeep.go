@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+func runKarakeep(config Config, args []string) error {
+	flags := flag.NewFlagSet("karakeep", flag.ExitOnError)
+	limit := flags.Int("n", 0, "maximum number of bookmarks summarized, oldest first, 0 for all")
+	writeBack := flags.Bool("write-back", true, "write the summaries and tags back to the bookmarks")
+	dryRun := flags.Bool("dry-run", false, "list the bookmarks to summarize without summarizing them")
+	reportFlags := addReportFlags(flags, config)
+	flags.Usage = func() {
+		fmt.Println("Usage: report karakeep [-n 0] [-write-back=false] [-dry-run] [options] <output-folder>")
+		fmt.Println("Summarize the link bookmarks of a Karakeep (Hoarder) server that have no summary yet, writing the summaries back to them.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder")
+	}
+	outputFolder := args[0]
+
+	client, err := export.NewKarakeepClient(reportFlags.config.Karakeep, reportFlags.scraper.Retry, reportFlags.scraper.Policy.Timeout)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	bookmarks, err := client.ListBookmarks(ctx)
+	if err != nil {
+		return err
+	}
+	// Bookmarks summarized by a previous sync, or by Karakeep itself, are
+	// left alone unless forced
+	var entries []ReadingListEntry
+	bookmarkIds := map[string]string{}
+	for _, bookmark := range bookmarks {
+		if bookmark.Content.Type != "link" || (bookmark.Summary != "" && !*reportFlags.force) {
+			continue
+		}
+		entries = append(entries, ReadingListEntry{Url: bookmark.Content.Url, Title: cmp.Or(bookmark.Title, bookmark.Content.Title), Added: bookmark.CreatedAt})
+		bookmarkIds[bookmark.Content.Url] = bookmark.Id
+	}
+	entries = sortReadingList(entries)
+	if *limit > 0 && len(entries) > *limit {
+		entries = entries[:*limit]
+	}
+	fmt.Printf("%s: %d bookmarks, %d to summarize\n", client.Url, len(bookmarks), len(entries))
+
+	if *dryRun {
+		for _, entry := range entries {
+			fmt.Printf("  %s  %s  %s\n", entry.Added.Format(scrape.DATE_FORMAT), entry.Url, entry.Title)
+		}
+		return nil
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	options, err := reportFlags.options(outputFolder)
+	if err != nil {
+		return err
+	}
+	defer options.Events.Close()
+	options.NonInteractive = true
+	options.Feed = client.Url
+	options.AddedDates = map[string]time.Time{}
+	if *writeBack {
+		options.Karakeep = client
+		options.KarakeepBookmarks = bookmarkIds
+	}
+	articleUrls := make([]string, len(entries))
+	for i, entry := range entries {
+		articleUrls[i] = entry.Url
+		options.AddedDates[entry.Url] = entry.Added
+	}
+
+	ctx = summarize.WithTerminalProgress(ctx, *reportFlags.provider.progress, *reportFlags.concurrency)
+	reportClient, err := report.New(report.WithReportOptions(options))
+	if err != nil {
+		return err
+	}
+	results := reportClient.CreateAll(ctx, articleUrls, *reportFlags.concurrency)
+	if !printBatchSummary(results) {
+		return fmt.Errorf("some bookmarks failed")
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+)
+
+const (
+	DEFAULT_LINKCHECK_INTERVAL = 7 * 24 * time.Hour
+	// Value of the link_status key of the reports whose article is gone
+	LINK_STATUS_DEAD = "dead"
+)
+
+// LinkCheck is the result of the check of the url of a report.
+type LinkCheck struct {
+	Report export.Report
+	Dead   bool
+	// Why the link could not be checked, the report being left as is
+	Err error
+}
+
+func runLinkcheck(config Config, args []string) error {
+	flags := flag.NewFlagSet("linkcheck", flag.ExitOnError)
+	interval := flags.Duration("interval", DEFAULT_LINKCHECK_INTERVAL, "delay between two checks of the links")
+	once := flags.Bool("once", false, "check the links once and exit, e.g. from cron")
+	archive := flags.Bool("archive", false, "link the reports of the dead pages to their latest Wayback Machine snapshot")
+	dryRun := flags.Bool("dry-run", false, "print the dead links without changing the reports")
+	concurrency := flags.Int("concurrency", 4, "number of links checked in parallel")
+	hostDelay := flags.Duration("host-delay", time.Second, "minimum delay between two requests to the same host")
+	scraper := scrape.New()
+	permissions := addPermissionFlags(flags)
+	addFetchPolicyFlags(flags, &scraper.Policy)
+	addFetchCredentialsFlags(flags, &scraper.Credentials)
+	addRetryFlags(flags, &scraper.Retry)
+	flags.Usage = func() {
+		fmt.Println("Usage: report linkcheck [-interval 168h] [-once] [-archive] [-dry-run] <output-folder>")
+		fmt.Println("Check the urls of the reports of the output folder, marking the reports whose page is gone in their frontmatter.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder")
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("invalid interval %s, expected a positive duration", *interval)
+	}
+	if *concurrency < 1 {
+		return fmt.Errorf("invalid concurrency %d, expected at least 1", *concurrency)
+	}
+	if err := scraper.Credentials.Load(); err != nil {
+		return err
+	}
+	outputFolder := args[0]
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	for {
+		err := checkReportLinks(ctx, scraper, outputFolder, *permissions, *archive, *dryRun, *concurrency, scrape.NewHostLimiter(*hostDelay))
+		if *once || *dryRun {
+			return err
+		}
+		if err != nil {
+			slog.Error("checking the links failed", "folder", outputFolder, "err", err)
+		}
+		if ctx.Err() != nil {
+			slog.Info("Stopped")
+			return nil
+		}
+
+		next := time.Now().Add(*interval)
+		slog.Info("Next check", "at", next)
+		select {
+		case <-ctx.Done():
+			slog.Info("Stopped")
+			return nil
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// checkReportLinks checks the urls of the reports of the output folder. The
+// reports of the dead pages get link_status: dead and the date it was found,
+// and their Wayback Machine snapshot as archive_url with archive, while the
+// reports of the pages back online lose them. The links are requested by the
+// scraper.
+func checkReportLinks(ctx context.Context, scraper *scrape.Scraper, outputFolder string, permissions export.FilePermissions, archive, dryRun bool, concurrency int, limiter *scrape.HostLimiter) error {
+	reports, err := export.LoadReports(outputFolder)
+	if err != nil {
+		return err
+	}
+	var checked []export.Report
+	for _, report := range reports {
+		if strings.HasPrefix(report.Url, "http://") || strings.HasPrefix(report.Url, "https://") {
+			checked = append(checked, report)
+		}
+	}
+
+	dead, newlyDead, alive, unknown := 0, 0, 0, 0
+	for _, check := range checkLinks(ctx, scraper, checked, concurrency, limiter) {
+		report := check.Report
+		switch {
+		case check.Err != nil:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			unknown++
+			slog.Warn("could not check the link", "url", report.Url, "err", check.Err)
+		case check.Dead:
+			dead++
+			if report.LinkStatus == LINK_STATUS_DEAD {
+				continue
+			}
+			newlyDead++
+			snapshotUrl := ""
+			if archive && report.ArchiveUrl == "" {
+				snapshotUrl = scraper.LookupSnapshot(ctx, report.Url)
+			}
+			fmt.Printf("Dead link: %s\n   %s\n", report.Title, report.Url)
+			if snapshotUrl != "" {
+				fmt.Printf("   archived: %s\n", snapshotUrl)
+			}
+			if !dryRun {
+				if err := markDeadLink(report, permissions, snapshotUrl, time.Now()); err != nil {
+					return err
+				}
+			}
+		case report.LinkStatus == LINK_STATUS_DEAD:
+			alive++
+			fmt.Printf("Back online: %s\n   %s\n", report.Title, report.Url)
+			if !dryRun {
+				if err := unmarkDeadLink(report, permissions); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	fmt.Printf("%d links checked: %d dead (%d new), %d back online, %d not checked\n", len(checked), dead, newlyDead, alive, unknown)
+	return nil
+}
+
+// checkLinks checks the urls of the reports with a pool of workers, and
+// returns the results in the order of the reports.
+func checkLinks(ctx context.Context, scraper *scrape.Scraper, reports []export.Report, concurrency int, limiter *scrape.HostLimiter) []LinkCheck {
+	checks := make([]LinkCheck, len(reports))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for range min(concurrency, len(reports)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				checks[i] = checkLink(ctx, scraper, limiter, reports[i])
+			}
+		}()
+	}
+	for i := range reports {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	return checks
+}
+
+// checkLink checks the url of a report. A panic fails the check of the link,
+// and not the whole program.
+func checkLink(ctx context.Context, scraper *scrape.Scraper, limiter *scrape.HostLimiter, indexed export.Report) (check LinkCheck) {
+	defer func() {
+		if err := report.NewPanicError(recover(), indexed.Url); err != nil {
+			check = LinkCheck{Report: indexed, Err: err}
+		}
+	}()
+	dead, err := scraper.IsDeadLink(ctx, limiter, indexed.Url)
+	return LinkCheck{Report: indexed, Dead: dead, Err: err}
+}
+
+// markDeadLink marks the report of a dead page in its frontmatter. With a
+// snapshot, it is added as archive_url and replaces the links to the page in
+// the report, its url being kept as the key of the article.
+func markDeadLink(report export.Report, permissions export.FilePermissions, snapshotUrl string, now time.Time) error {
+	values := map[string]string{"link_status": LINK_STATUS_DEAD, "link_checked": now.Format(scrape.DATE_FORMAT)}
+	return editReport(report.Path, permissions, func(content string) (string, error) {
+		if snapshotUrl != "" {
+			values["archive_url"] = snapshotUrl
+			content = strings.ReplaceAll(content, "]("+report.Url+")", "]("+snapshotUrl+")")
+		}
+		return setFrontmatterValues(content, values)
+	})
+}
+
+// unmarkDeadLink removes the dead link marks of the report of a page back
+// online, its archive_url being kept.
+func unmarkDeadLink(report export.Report, permissions export.FilePermissions) error {
+	return editReport(report.Path, permissions, func(content string) (string, error) {
+		return setFrontmatterValues(content, map[string]string{"link_status": "", "link_checked": ""})
+	})
+}
+
+func editReport(path string, permissions export.FilePermissions, edit func(content string) (string, error)) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading report: %w", err)
+	}
+	content, err := edit(string(data))
+	if err != nil {
+		return fmt.Errorf("editing '%s': %w", path, err)
+	}
+	return permissions.WriteFile(path, []byte(content))
+}
+
+// setFrontmatterValues sets single line keys of the frontmatter of a report,
+// replacing their value or adding them at the end of the frontmatter. An
+// empty value removes the key. The line endings of the report are kept.
+func setFrontmatterValues(content string, values map[string]string) (string, error) {
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+	}
+	lines := strings.Split(content, newline)
+	if len(lines) == 0 || strings.TrimPrefix(lines[0], export.UTF8_BOM) != "---" {
+		return "", fmt.Errorf("report has no frontmatter")
+	}
+	end := 1
+	for end < len(lines) && lines[end] != "---" {
+		end++
+	}
+	if end == len(lines) {
+		return "", fmt.Errorf("report frontmatter is not terminated")
+	}
+
+	set := map[string]bool{}
+	var frontmatter []string
+	for _, line := range lines[1:end] {
+		key, _, found := strings.Cut(line, ":")
+		value, isSet := values[strings.TrimSpace(key)]
+		if !found || !isSet || strings.HasPrefix(line, " ") {
+			frontmatter = append(frontmatter, line)
+			continue
+		}
+		set[strings.TrimSpace(key)] = true
+		if value != "" {
+			frontmatter = append(frontmatter, strings.TrimSpace(key)+": "+value)
+		}
+	}
+	var added []string
+	for key, value := range values {
+		if !set[key] && value != "" {
+			added = append(added, key+": "+value)
+		}
+	}
+	sort.Strings(added)
+	frontmatter = append(frontmatter, added...)
+
+	result := append([]string{lines[0]}, frontmatter...)
+	return strings.Join(append(result, lines[end:]...), newline), nil
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/brequet/report/pkg/summarize"
+)
+
+// ProviderFlags are the flags of the commands calling an LLM.
+type ProviderFlags struct {
+	name    *string
+	options summarize.ProviderOptions
+	// Show the progress of the LLM calls, see summarize.WithTerminalProgress
+	progress *bool
+}
+
+func addProviderFlags(flags *flag.FlagSet, config Config) *ProviderFlags {
+	f := &ProviderFlags{options: summarize.DefaultProviderOptions()}
+	f.options.Providers = config.Providers
+	flags.DurationVar(&f.options.Timeout, "llm-timeout", summarize.DEFAULT_LLM_TIMEOUT, "maximum duration of an LLM call, 0 for no limit")
+	f.progress = flags.Bool("progress", true, "show the progress of the LLM calls, streaming their answers, when the output is a terminal")
+	addGenerationFlags(flags, &f.options.Generation, config)
+	addCacheFlags(flags, &f.options.Cache)
+	f.name = flags.String("provider", cmp.Or(config.Provider, summarize.DEFAULT_LLM_PROVIDER), "LLM provider ("+strings.Join(summarize.LLMProviderNames(), ", ")+"), or a comma separated list of providers tried in order")
+	return f
+}
+
+// provider creates the provider of the flags.
+func (f *ProviderFlags) provider() (summarize.LLMProvider, error) {
+	return summarize.NewLLMProvider(*f.name, f.options)
+}
+
+func addGenerationFlags(flags *flag.FlagSet, generation *summarize.GenerationParams, config Config) {
+	defaults := summarize.GenerationParams{Temperature: summarize.DEFAULT_TEMPERATURE, MaxTokens: summarize.DEFAULT_MAX_TOKENS, TopP: summarize.DEFAULT_TOP_P}
+	if config.Temperature != nil {
+		defaults.Temperature = *config.Temperature
+	}
+	if config.MaxTokens != 0 {
+		defaults.MaxTokens = config.MaxTokens
+	}
+	if config.TopP != nil {
+		defaults.TopP = *config.TopP
+	}
+	flags.StringVar(&generation.Model, "model", "", "model of the LLM provider, the first one of a list, instead of its configured or default model")
+	flags.Float64Var(&generation.Temperature, "temperature", defaults.Temperature, fmt.Sprintf("sampling temperature of the LLM, from 0 to %g (at most 1 for anthropic)", summarize.MAX_TEMPERATURE))
+	flags.IntVar(&generation.MaxTokens, "max-tokens", defaults.MaxTokens, "maximum length of an LLM answer, in tokens")
+	flags.Float64Var(&generation.TopP, "top-p", defaults.TopP, "nucleus sampling of the LLM, the probability mass of the tokens considered, above 0 and up to 1")
+}
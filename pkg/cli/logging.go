@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Logs written for a person reading the terminal, prefixed by their level
+	// when they are not informational
+	LOG_FORMAT_TEXT = "text"
+	// Logs written as JSON lines, to be shipped to a log collector
+	LOG_FORMAT_JSON = "json"
+)
+
+// logLevel is the level of the messages logged: info by default, debug with
+// -verbose and warning with -quiet.
+var logLevel = new(slog.LevelVar)
+
+// setupLogging sets the default logger from the log section of the config
+// file, before the flags of the command are parsed.
+func setupLogging(config LogConfig) error {
+	if config.Level != "" {
+		if err := logLevel.UnmarshalText([]byte(config.Level)); err != nil {
+			return fmt.Errorf("unknown log level '%s', expected debug, info, warn or error", config.Level)
+		}
+	}
+	if config.Format == "" {
+		config.Format = LOG_FORMAT_TEXT
+	}
+	return setLogFormat(config.Format)
+}
+
+// setLogFormat sets the default logger, writing to the standard error in a
+// format.
+func setLogFormat(format string) error {
+	switch format {
+	case LOG_FORMAT_TEXT:
+		slog.SetDefault(slog.New(newConsoleHandler(os.Stderr, logLevel)))
+	case LOG_FORMAT_JSON:
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
+	default:
+		return fmt.Errorf("unknown log format '%s', expected %s or %s", format, LOG_FORMAT_TEXT, LOG_FORMAT_JSON)
+	}
+	return nil
+}
+
+// addLogFlags adds the flags setting the verbosity and the format of the
+// logs, which take effect as soon as they are parsed.
+func addLogFlags(flags *flag.FlagSet) {
+	setLevel := func(level slog.Level) func(string) error {
+		return func(value string) error {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			if enabled {
+				logLevel.Set(level)
+			} else {
+				logLevel.Set(slog.LevelInfo)
+			}
+			return nil
+		}
+	}
+	flags.BoolFunc("verbose", "log the HTTP requests and the extraction steps", setLevel(slog.LevelDebug))
+	flags.BoolFunc("quiet", "only log the warnings and errors, e.g. for cron jobs", setLevel(slog.LevelWarn))
+	flags.Func("log-format", "format of the logs, written to the standard error: "+LOG_FORMAT_TEXT+" or "+LOG_FORMAT_JSON, setLogFormat)
+}
+
+// isQuiet tells if the informational messages are left out, see -quiet.
+func isQuiet() bool {
+	return logLevel.Level() > slog.LevelInfo
+}
+
+// ConsoleHandler writes the logs as lines of text, the message followed by
+// its attributes as key=value and by its error, e.g.
+//
+//	Warning: could not download the cover image url=https://example.com/a.jpg: 404 Not Found
+type ConsoleHandler struct {
+	mu    *sync.Mutex
+	out   io.Writer
+	level slog.Leveler
+	// Attributes of the logger, formatted, and prefix of the keys of the
+	// attributes of the records
+	attrs  string
+	err    string
+	prefix string
+}
+
+func newConsoleHandler(out io.Writer, level slog.Leveler) *ConsoleHandler {
+	return &ConsoleHandler{mu: &sync.Mutex{}, out: out, level: level}
+}
+
+func (h *ConsoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *ConsoleHandler) Handle(_ context.Context, record slog.Record) error {
+	var sb strings.Builder
+	switch {
+	case record.Level >= slog.LevelError:
+		sb.WriteString("Error: ")
+	case record.Level >= slog.LevelWarn:
+		sb.WriteString("Warning: ")
+	case record.Level < slog.LevelInfo:
+		sb.WriteString("Debug: ")
+	}
+	sb.WriteString(record.Message)
+	sb.WriteString(h.attrs)
+	errText := h.err
+	record.Attrs(func(attr slog.Attr) bool {
+		if text, isErr := appendConsoleAttr(&sb, h.prefix, attr); isErr {
+			errText = text
+		}
+		return true
+	})
+	if errText != "" {
+		sb.WriteString(": " + errText)
+	}
+	sb.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.out, sb.String())
+	return err
+}
+
+func (h *ConsoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handler := *h
+	var sb strings.Builder
+	for _, attr := range attrs {
+		if text, isErr := appendConsoleAttr(&sb, h.prefix, attr); isErr {
+			handler.err = text
+		}
+	}
+	handler.attrs += sb.String()
+	return &handler
+}
+
+func (h *ConsoleHandler) WithGroup(name string) slog.Handler {
+	handler := *h
+	handler.prefix += name + "."
+	return &handler
+}
+
+// appendConsoleAttr writes an attribute as key=value, its value quoted when
+// needed, or nothing when its value is empty. An error, under the err key, is
+// not written but returned, to end the line.
+func appendConsoleAttr(sb *strings.Builder, prefix string, attr slog.Attr) (string, bool) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return "", false
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix += attr.Key + "."
+		}
+		errText, isErr := "", false
+		for _, groupAttr := range attr.Value.Group() {
+			if text, ok := appendConsoleAttr(sb, groupPrefix, groupAttr); ok {
+				errText, isErr = text, true
+			}
+		}
+		return errText, isErr
+	}
+	if attr.Key == "err" && prefix == "" {
+		return fmt.Sprintf("%+v", attr.Value.Any()), true
+	}
+
+	var value string
+	switch attr.Value.Kind() {
+	case slog.KindDuration:
+		value = attr.Value.Duration().Round(time.Millisecond).String()
+	case slog.KindTime:
+		value = attr.Value.Time().Format(time.DateTime)
+	default:
+		value = attr.Value.String()
+	}
+	// Unknown values are left out, the JSON logs keep them
+	if value == "" {
+		return "", false
+	}
+	if strings.ContainsAny(value, " =\"\t\n") {
+		value = strconv.Quote(value)
+	}
+	sb.WriteString(" " + prefix + attr.Key + "=" + value)
+	return "", false
+}
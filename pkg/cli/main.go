@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/brequet/report/internal/buildinfo"
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// commands maps subcommand names to their implementation, the default being
+// the creation of a report from an url.
+var commands = map[string]func(config Config, args []string) error{
+	"add":           runAdd,
+	"batch":         runBatch,
+	"ingest":        runIngest,
+	"config":        runConfig,
+	"version":       runVersion,
+	"semsearch":     runSemsearch,
+	"clusters":      runClusters,
+	"threads":       runThreads,
+	"trends":        runTrends,
+	"export-csv":    runExportCsv,
+	"feed":          runFeed,
+	"site":          runSite,
+	"newsletter":    runNewsletter,
+	"epub":          runEpub,
+	"print":         runPrint,
+	"paths":         runPaths,
+	"cache":         runCache,
+	"serve":         runServe,
+	"show":          runShow,
+	"mv":            runMv,
+	"dedupe":        runDedupe,
+	"linkcheck":     runLinkcheck,
+	"retag":         runRetag,
+	"update":        runUpdate,
+	"import":        runImport,
+	"watch":         runWatch,
+	"highlights":    runHighlights,
+	"karakeep":      runKarakeep,
+	"wallabag":      runWallabag,
+	"profiles":      runProfiles,
+	"import-pocket": runImportPocket,
+	"import-warc":   runImportWarc,
+	"archivebox":    runArchiveBox,
+	"stats":         runStats,
+	"costs":         runCosts,
+}
+
+// Main runs the command line tool on the arguments of the process, and exits
+// with status 1 when the command fails. buildVersion is the version the binary
+// was built with, empty to read it from the build information.
+func Main(buildVersion string) {
+	buildinfo.Version = buildVersion
+	defer exitOnCrash()
+
+	config, err := loadConfig()
+	if err == nil {
+		err = setupLogging(config.Log)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %+v\n", err)
+		os.Exit(1)
+	}
+	// The requests of the exports and of the rendering services
+	http.DefaultClient.Transport = scrape.LoggingTransport{}
+
+	if len(os.Args) >= 2 {
+		if command, ok := commands[os.Args[1]]; ok {
+			err := command(config, os.Args[2:])
+			if err != nil {
+				slog.Error("command failed", "command", os.Args[1], "err", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// Without a command, the arguments are the ones of the add command
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+	if os.Args[1] == "-h" || os.Args[1] == "-help" || os.Args[1] == "--help" {
+		printUsage()
+		return
+	}
+	err = runAdd(config, os.Args[1:])
+	if err != nil {
+		slog.Error("command failed", "command", "add", "err", err)
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Println("Usage: report [add] [options] <output-folder> <url|page.html|->...")
+	fmt.Println("       report [add] [options] -from-file urls.txt <output-folder> [<url>...]")
+	fmt.Println("       report batch [options] <output-folder> <urls-file>...")
+	fmt.Println("       report ingest [options] <output-folder> <feed-url>...")
+	fmt.Println("       report update [options] <output-folder> <report|id>...")
+	fmt.Println("       report import -from safari|chrome [-folder name] [options] <output-folder> [bookmarks-file]")
+	fmt.Println("       report watch [-interval 1h] [-n 10] [-once] [options] <output-folder>")
+	fmt.Println("       report highlights [-book title] [-min 3] [-dry-run] <output-folder> <My Clippings.txt|readwise.csv>")
+	fmt.Println("       report karakeep [-n 0] [-write-back=false] [-dry-run] [options] <output-folder>")
+	fmt.Println("       report wallabag [-n 0] [-write-back=false] [-dry-run] [options] <output-folder>")
+	fmt.Println("       report profiles [-show name]")
+	fmt.Println("       report import-pocket [-status all|unread|archive] [-dry-run] [options] <output-folder> <export.zip>")
+	fmt.Println("       report import-warc [-match regex] [-n 0] [-dry-run] [options] <output-folder> <archive.warc[.gz]>...")
+	fmt.Println("       report archivebox [-interval 10m] [-n 10] [-once] [-url http://localhost:8000] [options] <output-folder> <archivebox-data-folder>")
+	fmt.Println("       report stats [-months 12]")
+	fmt.Println("       report costs [-by feed|profile|tag] [-since 30d]")
+	fmt.Println("       report semsearch [-n 5] <output-folder> <query>")
+	fmt.Println("       report clusters [-k 0] [-write] <output-folder>")
+	fmt.Println("       report threads [-similarity 0.75] [-min-reports 3] [-force] <output-folder>")
+	fmt.Println("       report retag -taxonomy tags.yaml [-dry-run] <output-folder>")
+	fmt.Println("       report trends [-window 30d] [-o trends.md] <output-folder>")
+	fmt.Println("       report export-csv [-tsv] [-o reports.csv] <output-folder>")
+	fmt.Println("       report feed [-format json|rss] [-n 20] [-base-url https://...] <output-folder>")
+	fmt.Println("       report site [-title \"My reading\"] [-no-icons] [-o index.html] <output-folder>")
+	fmt.Println("       report newsletter [-since 7d] [-tags go,web] [-format markdown|html] <output-folder>")
+	fmt.Println("       report epub [-since 7d] [-tags go,web] [-o digest.epub] <output-folder>")
+	fmt.Println("       report show [-path] <output-folder> <id>")
+	fmt.Println("       report print [-o report.html] [-pdf] <output-folder> <report|id>")
+	fmt.Println("       report mv [-dry-run] <output-folder> <report|id> <new-name>")
+	fmt.Println("       report dedupe [-similarity 0.95] [-interactive] <output-folder>")
+	fmt.Println("       report linkcheck [-interval 168h] [-once] [-archive] [-dry-run] <output-folder>")
+	fmt.Println("       report paths [<output-folder>]")
+	fmt.Println("       report cache [clear]")
+	fmt.Println("       report serve [-addr localhost:8080] [<output-folder>]")
+	fmt.Println("       report config")
+	fmt.Println("       report version")
+	fmt.Println("Run report <command> -h for the options of a command.")
+}
+
+// interruptContext returns a context canceled on the first SIGINT or SIGTERM,
+// so that a run stops gracefully. A second signal terminates the process.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
@@ -0,0 +1,327 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// wikilinkRegex matches the wikilinks of Obsidian, [[target]], with an
+// optional heading, [[target#heading]], and label, [[target|label]].
+var wikilinkRegex = regexp.MustCompile(`\[\[([^\]|#\n]+)((?:#[^\]|\n]*)?(?:\|[^\]\n]*)?)\]\]`)
+
+// markdownLinkTargetRegex matches the target of the markdown links and
+// images, bare or between angle brackets when it contains spaces.
+var markdownLinkTargetRegex = regexp.MustCompile(`\]\((<[^>\n]+>|[^)\s]+)\)`)
+
+// ReportMove is the renaming of a report, its paths being absolute.
+type ReportMove struct {
+	OldPath string
+	NewPath string
+}
+
+func runMv(config Config, args []string) error {
+	flags := flag.NewFlagSet("mv", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "print the files whose links would be rewritten, without changing anything")
+	permissions := addPermissionFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report mv [-dry-run] <output-folder> <report|id> <new-name>")
+		fmt.Println("Rename or move a report within the output folder, rewriting the links of the other reports to it.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 3)
+	if len(args) != 3 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder, a report and its new name")
+	}
+	outputFolder := args[0]
+
+	report, err := resolveReport(outputFolder, args[1])
+	if err != nil {
+		return err
+	}
+	move, err := newReportMove(outputFolder, report.Path, args[2])
+	if err != nil {
+		return err
+	}
+
+	rewritten, err := moveReport(outputFolder, *permissions, report, move, *dryRun)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		for _, path := range rewritten {
+			fmt.Printf("Links to update: %s\n", path)
+		}
+		fmt.Printf("Report would be moved: %s -> %s\n", report.Path, move.NewPath)
+		return nil
+	}
+	for _, path := range rewritten {
+		fmt.Printf("Links updated: %s\n", path)
+	}
+	fmt.Printf("Report moved successfully: %s -> %s\n", report.Path, move.NewPath)
+	return nil
+}
+
+// resolveReport returns the report designated by its path, its file name in
+// the output folder or its ID.
+func resolveReport(outputFolder, designation string) (export.Report, error) {
+	for _, path := range []string{designation, filepath.Join(outputFolder, designation), filepath.Join(outputFolder, designation+".md")} {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return export.ParseReportFile(path)
+		}
+	}
+	return export.FindReportById(outputFolder, designation)
+}
+
+// newReportMove checks the new name of a report, a file name or a path in the
+// output folder, the .md extension being optional.
+func newReportMove(outputFolder, oldPath, newName string) (ReportMove, error) {
+	if !strings.EqualFold(filepath.Ext(newName), ".md") {
+		newName += ".md"
+	}
+	if !export.IsValidWindowsFilename(strings.TrimSuffix(filepath.Base(newName), filepath.Ext(newName))) {
+		return ReportMove{}, fmt.Errorf("'%s' is not a valid Windows filename", filepath.Base(newName))
+	}
+
+	folder, err := filepath.Abs(outputFolder)
+	if err != nil {
+		return ReportMove{}, fmt.Errorf("resolving output folder: %w", err)
+	}
+	var move ReportMove
+	if move.OldPath, err = filepath.Abs(oldPath); err != nil {
+		return ReportMove{}, fmt.Errorf("resolving report path: %w", err)
+	}
+	if filepath.IsAbs(newName) {
+		move.NewPath = filepath.Clean(newName)
+	} else {
+		move.NewPath = filepath.Join(folder, newName)
+	}
+
+	if relative, err := filepath.Rel(folder, move.NewPath); err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return ReportMove{}, fmt.Errorf("'%s' is outside of the output folder, the links to the report could not be kept", newName)
+	}
+	// Renaming to change the case only is allowed on case insensitive file systems
+	if _, err := os.Stat(move.NewPath); err == nil && !strings.EqualFold(move.NewPath, move.OldPath) {
+		return ReportMove{}, fmt.Errorf("'%s' already exists", move.NewPath)
+	}
+	if move.NewPath == move.OldPath {
+		return ReportMove{}, fmt.Errorf("the report is already named '%s'", filepath.Base(move.NewPath))
+	}
+	return move, nil
+}
+
+// moveReport renames a report and rewrites the links to it in the markdown
+// files of the output folder, the index and the embedding store. It returns
+// the files whose links were rewritten, which are only listed on a dry run.
+func moveReport(outputFolder string, permissions export.FilePermissions, report export.Report, move ReportMove, dryRun bool) ([]string, error) {
+	// Links are rewritten before the rename, so that an interrupted move
+	// leaves links to a file still there rather than to a missing one
+	rewritten, err := rewriteFolderLinks(outputFolder, permissions, move, dryRun)
+	if err != nil || dryRun {
+		return rewritten, err
+	}
+
+	if err := permissions.Mkdir(filepath.Dir(move.NewPath)); err != nil {
+		return rewritten, fmt.Errorf("creating folder: %w", err)
+	}
+	if err := os.Rename(move.OldPath, move.NewPath); err != nil {
+		return rewritten, export.NewWriteError("renaming", move.OldPath, err)
+	}
+
+	if err := moveIndexedReport(outputFolder, permissions, report, move); err != nil {
+		slog.Warn("could not update the article index", "err", err)
+	}
+	if export.IsEmbeddingConfigured() {
+		if err := moveReportEmbedding(outputFolder, permissions, move); err != nil {
+			slog.Warn("could not update the embedding store", "err", err)
+		}
+	}
+	return rewritten, nil
+}
+
+// rewriteFolderLinks rewrites the links to a moved report in the markdown
+// files of the output folder, see rewriteReportLinks. It returns the files
+// whose links were rewritten, which are only listed on a dry run.
+func rewriteFolderLinks(outputFolder string, permissions export.FilePermissions, move ReportMove, dryRun bool) ([]string, error) {
+	folder, err := filepath.Abs(outputFolder)
+	if err != nil {
+		return nil, fmt.Errorf("resolving output folder: %w", err)
+	}
+
+	var rewritten []string
+	err = filepath.WalkDir(folder, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != folder && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading '%s': %w", path, err)
+		}
+		linkingDir := filepath.Dir(path)
+		newLinkingDir := linkingDir
+		if path == move.OldPath {
+			newLinkingDir = filepath.Dir(move.NewPath)
+		}
+		content := rewriteReportLinks(string(data), folder, linkingDir, newLinkingDir, move)
+		if content == string(data) {
+			return nil
+		}
+
+		rewritten = append(rewritten, path)
+		if dryRun {
+			return nil
+		}
+		return permissions.WriteFile(path, []byte(content))
+	})
+	if err != nil {
+		return rewritten, fmt.Errorf("rewriting links: %w", err)
+	}
+	return rewritten, nil
+}
+
+// rewriteReportLinks rewrites the wikilinks and the relative markdown links
+// to a moved report. The relative links of the moved report itself are also
+// rewritten when it changes folder, from linkingDir to newLinkingDir.
+func rewriteReportLinks(content, folder, linkingDir, newLinkingDir string, move ReportMove) string {
+	oldName := strings.TrimSuffix(filepath.Base(move.OldPath), filepath.Ext(move.OldPath))
+	newName := strings.TrimSuffix(filepath.Base(move.NewPath), filepath.Ext(move.NewPath))
+
+	content = wikilinkRegex.ReplaceAllStringFunc(content, func(link string) string {
+		match := wikilinkRegex.FindStringSubmatch(link)
+		target := strings.TrimSpace(match[1])
+		// Wikilinks name a note, or give its path from the vault root
+		if strings.Contains(target, "/") {
+			if filepath.Join(folder, filepath.FromSlash(strings.TrimSuffix(target, ".md"))) != strings.TrimSuffix(move.OldPath, filepath.Ext(move.OldPath)) {
+				return link
+			}
+			relative, _ := filepath.Rel(folder, move.NewPath)
+			return "[[" + strings.TrimSuffix(filepath.ToSlash(relative), ".md") + match[2] + "]]"
+		}
+		if strings.TrimSuffix(target, ".md") != oldName {
+			return link
+		}
+		return "[[" + newName + match[2] + "]]"
+	})
+
+	return markdownLinkTargetRegex.ReplaceAllStringFunc(content, func(link string) string {
+		rawTarget := markdownLinkTargetRegex.FindStringSubmatch(link)[1]
+		bracketed := strings.HasPrefix(rawTarget, "<")
+		target := strings.Trim(rawTarget, "<>")
+		if strings.Contains(target, ":") || strings.HasPrefix(target, "/") || strings.HasPrefix(target, "#") {
+			return link
+		}
+		target, fragment, _ := strings.Cut(target, "#")
+		decoded, err := url.PathUnescape(target)
+		if err != nil {
+			return link
+		}
+
+		targetPath := filepath.Join(linkingDir, filepath.FromSlash(decoded))
+		if targetPath == move.OldPath {
+			targetPath = move.NewPath
+		} else if linkingDir == newLinkingDir {
+			return link
+		}
+		relative, err := filepath.Rel(newLinkingDir, targetPath)
+		if err != nil {
+			return link
+		}
+
+		newTarget := filepath.ToSlash(relative)
+		if !bracketed {
+			newTarget = (&url.URL{Path: newTarget}).EscapedPath()
+		}
+		if fragment != "" {
+			newTarget += "#" + fragment
+		}
+		if bracketed {
+			newTarget = "<" + newTarget + ">"
+		}
+		return "](" + newTarget + ")"
+	})
+}
+
+// moveIndexedReport replaces the path of a moved report in the index.
+func moveIndexedReport(outputFolder string, permissions export.FilePermissions, report export.Report, move ReportMove) error {
+	index, err := export.LoadArticleIndex(outputFolder, permissions)
+	if err != nil {
+		return err
+	}
+	relative, err := filepath.Rel(absPath(outputFolder), move.NewPath)
+	if err != nil {
+		return err
+	}
+	key := scrape.CanonicalUrl(report.Url)
+	return index.Update(func(articles map[string]export.IndexedArticle) {
+		indexed, found := articles[key]
+		if !found {
+			return
+		}
+		var paths []string
+		for _, path := range indexed.Paths {
+			if absPath(path) == move.OldPath || filepath.Base(path) == filepath.Base(move.OldPath) {
+				// Same form as the paths of the new reports
+				path = filepath.Join(outputFolder, relative)
+			}
+			// A report merged into another one may already be listed
+			if !slices.Contains(paths, path) {
+				paths = append(paths, path)
+			}
+		}
+		indexed.Paths = paths
+		articles[key] = indexed
+	})
+}
+
+// moveReportEmbedding renames the entry of a moved report in the embedding
+// store, keyed by file name.
+func moveReportEmbedding(outputFolder string, permissions export.FilePermissions, move ReportMove) error {
+	config, err := export.GetEmbeddingConfig()
+	if err != nil {
+		return err
+	}
+	store, err := export.LoadEmbeddingStore(outputFolder, config.Model)
+	if err != nil {
+		return err
+	}
+	entry, found := store.Entries[filepath.Base(move.OldPath)]
+	if !found {
+		return nil
+	}
+	delete(store.Entries, filepath.Base(move.OldPath))
+	store.Entries[filepath.Base(move.NewPath)] = entry
+	return store.Save(outputFolder, permissions)
+}
+
+// absPath returns the absolute path of a path, or the path itself if the
+// working directory is unknown.
+func absPath(path string) string {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return absolute
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"context"
+	_ "embed"
+	"flag"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const (
+	NEWSLETTER_FORMAT_MARKDOWN = "markdown"
+	NEWSLETTER_FORMAT_HTML     = "html"
+)
+
+//go:embed newsletter-prompt.md
+var newsletterPrompt string
+
+type NewsletterIntro struct {
+	Intro string `json:"intro"`
+}
+
+func runNewsletter(config Config, args []string) error {
+	flags := flag.NewFlagSet("newsletter", flag.ExitOnError)
+	since := flags.String("since", "7d", "include the reports created during this period (e.g. 7d, 2w)")
+	tags := flags.String("tags", "", "only include the reports with one of these comma separated tags")
+	title := flags.String("title", "", "title of the newsletter, defaults to one with the issue date")
+	format := flags.String("format", NEWSLETTER_FORMAT_MARKDOWN, "newsletter format, markdown or html")
+	output := flags.String("o", "", "write the newsletter to this file instead of stdout")
+	providerFlags := addProviderFlags(flags, config)
+	permissions := addPermissionFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report newsletter [-since 7d] [-tags go,web] [-format markdown|html] [-o newsletter.md] <output-folder>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder")
+	}
+	if *format != NEWSLETTER_FORMAT_MARKDOWN && *format != NEWSLETTER_FORMAT_HTML {
+		return fmt.Errorf("unknown newsletter format '%s', expected %s or %s", *format, NEWSLETTER_FORMAT_MARKDOWN, NEWSLETTER_FORMAT_HTML)
+	}
+
+	period, err := parsePeriod(*since)
+	if err != nil {
+		return fmt.Errorf("parsing since: %w", err)
+	}
+
+	provider, err := providerFlags.provider()
+	if err != nil {
+		return err
+	}
+
+	reports, err := export.LoadReports(args[0])
+	if err != nil {
+		return err
+	}
+
+	var tagFilter []string
+	if *tags != "" {
+		tagFilter = strings.Split(*tags, ",")
+	}
+	selected := selectNewsletterReports(reports, time.Now().Add(-period), tagFilter)
+	if len(selected) == 0 {
+		return fmt.Errorf("no report created during the last %s", *since)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	intro, err := getNewsletterIntro(ctx, selected, provider)
+	if err != nil {
+		return fmt.Errorf("writing newsletter intro: %w", err)
+	}
+
+	if *title == "" {
+		*title = "Reading notes - " + time.Now().Format("January 2, 2006")
+	}
+
+	var content string
+	if *format == NEWSLETTER_FORMAT_HTML {
+		content = renderNewsletterHtml(*title, intro, selected)
+	} else {
+		content = renderNewsletterMarkdown(*title, intro, selected)
+	}
+
+	if *output == "" {
+		fmt.Print(content)
+		return nil
+	}
+	if err := permissions.WriteFile(*output, []byte(content)); err != nil {
+		return fmt.Errorf("writing newsletter: %w", err)
+	}
+	fmt.Printf("Newsletter created successfully: %s\n", *output)
+
+	return nil
+}
+
+// selectNewsletterReports returns the reports created since the given time,
+// oldest first, keeping only those having one of the tags if any is given.
+func selectNewsletterReports(reports []export.Report, since time.Time, tags []string) []export.Report {
+	var selected []export.Report
+	for _, report := range latestReports(reports, len(reports)) {
+		date, ok := export.ParseReportDate(report)
+		if !ok || date.Before(since.Truncate(24*time.Hour)) {
+			continue
+		}
+		if len(tags) > 0 && !hasAnyTag(report, tags) {
+			continue
+		}
+		selected = append([]export.Report{report}, selected...)
+	}
+	return selected
+}
+
+func hasAnyTag(report export.Report, tags []string) bool {
+	for _, tag := range tags {
+		for _, reportTag := range report.Tags {
+			if strings.EqualFold(strings.TrimSpace(tag), reportTag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getNewsletterIntro(ctx context.Context, reports []export.Report, provider summarize.LLMProvider) (string, error) {
+	var sb strings.Builder
+	for _, report := range reports {
+		fmt.Fprintf(&sb, "## %s\nTags: %s\n%s\n\n", report.Title, strings.Join(report.Tags, ", "), report.Summary)
+	}
+
+	var intro NewsletterIntro
+	_, err := summarize.CompleteJson(ctx, provider, []summarize.ChatMessage{
+		{Role: "system", Content: newsletterPrompt},
+		{Role: "user", Content: sb.String()},
+	}, &intro)
+	if err != nil {
+		return "", fmt.Errorf("writing newsletter intro: %w", err)
+	}
+
+	return strings.TrimSpace(intro.Intro), nil
+}
+
+func renderNewsletterMarkdown(title, intro string, reports []export.Report) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n%s\n", title, intro)
+	for _, report := range reports {
+		fmt.Fprintf(&sb, "\n## [%s](%s)\n\n%s\n\n", report.Title, report.Url, report.Summary)
+		for _, keypoint := range report.Keypoints {
+			fmt.Fprintf(&sb, "- %s\n", keypoint)
+		}
+		if len(report.Tags) > 0 {
+			fmt.Fprintf(&sb, "\n*%s*\n", "#"+strings.Join(report.Tags, " #"))
+		}
+	}
+	return sb.String()
+}
+
+func renderNewsletterHtml(title, intro string, reports []export.Report) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<h1>%s</h1>\n%s\n", html.EscapeString(title), export.ReportToHtml(intro, nil))
+	for _, report := range reports {
+		fmt.Fprintf(&sb, "<h2><a href=\"%s\">%s</a></h2>\n%s\n", html.EscapeString(report.Url), html.EscapeString(report.Title), export.ReportToHtml(report.Summary, report.Keypoints))
+		if len(report.Tags) > 0 {
+			fmt.Fprintf(&sb, "<p><em>%s</em></p>\n", html.EscapeString("#"+strings.Join(report.Tags, " #")))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,17 @@
+package cli
+
+import (
+	"cmp"
+	"flag"
+
+	"github.com/brequet/report/pkg/export"
+)
+
+// addNoteLinkFlags adds the flags of the notes linking to the reports to a
+// flag set, their defaults being read from the config file.
+func addNoteLinkFlags(flags *flag.FlagSet, links *export.NoteLinks, config export.NoteLinksConfig) {
+	flags.StringVar(&links.IndexNote, "link-note", config.Note, "add a wikilink to each new markdown report to this note, e.g. a map of content, relative to the output folder")
+	flags.StringVar(&links.DailyFolder, "daily-note", config.DailyFolder, "add a wikilink to each new markdown report to the daily note of this folder, relative to the output folder, . for the output folder itself")
+	flags.StringVar(&links.DailyFormat, "daily-note-format", cmp.Or(config.DailyFormat, export.DEFAULT_DAILY_NOTE_FORMAT), "file name of the daily notes, as a date format of the Obsidian Daily notes plugin")
+	flags.StringVar(&links.Heading, "link-heading", config.Heading, "heading of the notes the links are added under, e.g. \"## Reading\", added if missing, the end of the notes if not set")
+}
@@ -0,0 +1,332 @@
+package cli
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OIDC_FETCH_TIMEOUT = 10 * time.Second
+	// Minimum delay between two fetches of the keys of the provider, when a
+	// token is signed with an unknown key
+	OIDC_KEYS_REFRESH_DELAY = time.Minute
+	// Tolerated difference between the clocks of the provider and the server
+	OIDC_CLOCK_SKEW = time.Minute
+	// Claim naming the users by default
+	OIDC_DEFAULT_USER_CLAIM = "sub"
+)
+
+// OidcVerifier checks the ID tokens of an OpenID Connect provider, JSON Web
+// Tokens signed with the keys the provider publishes.
+type OidcVerifier struct {
+	config OidcConfig
+
+	mu      sync.Mutex
+	jwksUri string
+	// Public keys of the provider, by key ID
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// OidcClaims are the claims of an ID token the server checks.
+type OidcClaims struct {
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	Expires   int64           `json:"exp"`
+	NotBefore int64           `json:"nbf"`
+	// All the claims, to read the one naming the user
+	all map[string]any
+}
+
+func newOidcVerifier(config OidcConfig) *OidcVerifier {
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	if config.UserClaim == "" {
+		config.UserClaim = OIDC_DEFAULT_USER_CLAIM
+	}
+	return &OidcVerifier{config: config, keys: map[string]crypto.PublicKey{}}
+}
+
+// isJwt tells whether a bearer token looks like a JSON Web Token rather than
+// an API key: three base64url parts separated by dots.
+func isJwt(token string) bool {
+	return strings.Count(token, ".") == 2 && strings.HasPrefix(token, "eyJ")
+}
+
+// verify checks the signature, the issuer, the audience and the validity
+// period of an ID token, and returns the user it names.
+func (v *OidcVerifier) verify(ctx context.Context, token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJwtPart(parts[0], &header); err != nil {
+		return "", fmt.Errorf("malformed token header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed token signature: %w", err)
+	}
+	key, err := v.key(ctx, header.Kid, now)
+	if err != nil {
+		return "", err
+	}
+	if err := verifyJwtSignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return "", err
+	}
+
+	var claims OidcClaims
+	if err := decodeJwtPart(parts[1], &claims); err != nil {
+		return "", fmt.Errorf("malformed token claims: %w", err)
+	}
+	if err := decodeJwtPart(parts[1], &claims.all); err != nil {
+		return "", fmt.Errorf("malformed token claims: %w", err)
+	}
+	if strings.TrimSuffix(claims.Issuer, "/") != v.config.Issuer {
+		return "", fmt.Errorf("token issued by '%s', expected '%s'", claims.Issuer, v.config.Issuer)
+	}
+	if !claims.hasAudience(v.config.Audience) {
+		return "", fmt.Errorf("token not issued for the audience '%s'", v.config.Audience)
+	}
+	if claims.Expires == 0 || now.After(time.Unix(claims.Expires, 0).Add(OIDC_CLOCK_SKEW)) {
+		return "", fmt.Errorf("token expired")
+	}
+	if claims.NotBefore != 0 && now.Add(OIDC_CLOCK_SKEW).Before(time.Unix(claims.NotBefore, 0)) {
+		return "", fmt.Errorf("token not valid yet")
+	}
+	user, _ := claims.all[v.config.UserClaim].(string)
+	if user == "" {
+		return "", fmt.Errorf("token without the claim '%s'", v.config.UserClaim)
+	}
+	return user, nil
+}
+
+// hasAudience tells whether the audience of the claims, a string or a list
+// of strings, holds the given one.
+func (c OidcClaims) hasAudience(audience string) bool {
+	var single string
+	if json.Unmarshal(c.Audience, &single) == nil {
+		return single == audience
+	}
+	var list []string
+	return json.Unmarshal(c.Audience, &list) == nil && slices.Contains(list, audience)
+}
+
+func decodeJwtPart(part string, value any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+// verifyJwtSignature checks the signature of a token with the RSA and ECDSA
+// algorithms of OpenID Connect. Unsigned and HMAC tokens are refused, the
+// server having no shared secret with the provider.
+func verifyJwtSignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256", "PS256":
+		hash = crypto.SHA256
+	case "RS384", "ES384", "PS384":
+		hash = crypto.SHA384
+	case "RS512", "ES512", "PS512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported token algorithm '%s'", alg)
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(signed))
+	digest := hasher.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		var err error
+		if strings.HasPrefix(alg, "RS") {
+			err = rsa.VerifyPKCS1v15(key, hash, digest, signature)
+		} else if strings.HasPrefix(alg, "PS") {
+			err = rsa.VerifyPSS(key, hash, digest, signature, nil)
+		} else {
+			err = fmt.Errorf("algorithm '%s' with an RSA key", alg)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid token signature: %w", err)
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			return fmt.Errorf("invalid token signature")
+		}
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return fmt.Errorf("invalid token signature")
+		}
+	default:
+		return fmt.Errorf("unsupported key type")
+	}
+	return nil
+}
+
+// key returns the public key of the provider with an ID, fetching the keys
+// again when it is unknown, e.g. after a key rotation.
+func (v *OidcVerifier) key(ctx context.Context, kid string, now time.Time) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if !v.fetched.IsZero() && now.Sub(v.fetched) < OIDC_KEYS_REFRESH_DELAY {
+		return nil, fmt.Errorf("token signed with an unknown key '%s'", kid)
+	}
+	if err := v.fetchKeys(ctx); err != nil {
+		return nil, fmt.Errorf("fetching the keys of the OpenID provider: %w", err)
+	}
+	v.fetched = now
+	key, ok := v.keys[kid]
+	if !ok && kid == "" && len(v.keys) == 1 {
+		// A provider with a single key may not name it
+		for _, key = range v.keys {
+			ok = true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("token signed with an unknown key '%s'", kid)
+	}
+	return key, nil
+}
+
+// fetchKeys reads the keys of the provider from its JWKS document, found in
+// its discovery document.
+func (v *OidcVerifier) fetchKeys(ctx context.Context) error {
+	if v.jwksUri == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JwksUri string `json:"jwks_uri"`
+		}
+		if err := fetchOidcJson(ctx, v.config.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+			return err
+		}
+		if strings.TrimSuffix(discovery.Issuer, "/") != v.config.Issuer {
+			return fmt.Errorf("discovery document of the issuer '%s'", discovery.Issuer)
+		}
+		if discovery.JwksUri == "" {
+			return fmt.Errorf("discovery document without jwks_uri")
+		}
+		v.jwksUri = discovery.JwksUri
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := fetchOidcJson(ctx, v.jwksUri, &jwks); err != nil {
+		return err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJwk(jwk.Kty, jwk.N, jwk.E, jwk.Crv, jwk.X, jwk.Y)
+		if err != nil {
+			slog.Warn("ignoring a key of the OpenID provider", "kid", jwk.Kid, "err", err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no signing key")
+	}
+	v.keys = keys
+	slog.Debug("OpenID provider keys fetched", "issuer", v.config.Issuer, "keys", len(keys))
+	return nil
+}
+
+// parseJwk reads an RSA or elliptic curve public key of a JWKS document.
+func parseJwk(kty, n, e, crv, x, y string) (crypto.PublicKey, error) {
+	decode := func(value string) (*big.Int, error) {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(data) == 0 {
+			return nil, fmt.Errorf("invalid key parameter")
+		}
+		return new(big.Int).SetBytes(data), nil
+	}
+	switch kty {
+	case "RSA":
+		modulus, err := decode(n)
+		if err != nil {
+			return nil, err
+		}
+		exponent, err := decode(e)
+		if err != nil || !exponent.IsInt64() {
+			return nil, fmt.Errorf("invalid key exponent")
+		}
+		return &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve '%s'", crv)
+		}
+		px, err := decode(x)
+		if err != nil {
+			return nil, err
+		}
+		py, err := decode(y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(px, py) {
+			return nil, fmt.Errorf("point not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: px, Y: py}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type '%s'", kty)
+}
+
+func fetchOidcJson(ctx context.Context, documentUrl string, value any) error {
+	ctx, cancel := context.WithTimeout(ctx, OIDC_FETCH_TIMEOUT)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, documentUrl, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: status %s", documentUrl, res.Status)
+	}
+	if err := json.NewDecoder(res.Body).Decode(value); err != nil {
+		return fmt.Errorf("%s: %w", documentUrl, err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/brequet/report/internal/paths"
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+func runPaths(config Config, args []string) error {
+	flags := flag.NewFlagSet("paths", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: report paths [<output-folder>]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) > 1 {
+		flags.Usage()
+		return fmt.Errorf("expected at most an output folder")
+	}
+
+	configFile, err := paths.ConfigFilePath()
+	if err != nil {
+		return err
+	}
+	locations := [][2]string{{"config file", configFile}}
+	for _, dir := range []struct {
+		name string
+		get  func() (string, error)
+	}{
+		{"config", paths.ConfigDir},
+		{"cache", paths.CacheDir},
+		{"data", paths.DataDir},
+		{"state", paths.StateDir},
+		{"profiles", summarize.ProfilesDir},
+	} {
+		path, err := dir.get()
+		if err != nil {
+			return err
+		}
+		locations = append(locations, [2]string{dir.name, path})
+	}
+
+	if len(args) == 1 {
+		folder, err := export.ReportDataFolder(args[0])
+		if err != nil {
+			return err
+		}
+		locations = append(locations, [2]string{"folder data", folder}, [2]string{"embeddings", filepath.Join(folder, export.EMBEDDINGS_FILE_NAME)})
+	}
+
+	for _, path := range locations {
+		fmt.Printf("%-12s %s\n", path[0]+":", path[1])
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"flag"
+
+	"github.com/brequet/report/pkg/export"
+)
+
+// addPermissionFlags adds the flags setting the output permissions to a flag
+// set.
+func addPermissionFlags(flags *flag.FlagSet) *export.FilePermissions {
+	permissions := &export.FilePermissions{}
+	flags.Func("file-mode", "octal mode of the written files (e.g. 0664), umask filtered 0666 if not set", func(value string) error {
+		mode, err := export.ParseFileMode(value)
+		permissions.FileMode = &mode
+		return err
+	})
+	flags.Func("dir-mode", "octal mode of the created folders (e.g. 0775), umask filtered 0777 if not set", func(value string) error {
+		mode, err := export.ParseFileMode(value)
+		permissions.DirMode = &mode
+		return err
+	})
+	flags.Func("group", "group (name or id) owning the written files and folders", func(value string) error {
+		gid, err := export.LookupGroupId(value)
+		permissions.Gid = &gid
+		return err
+	})
+	return permissions
+}
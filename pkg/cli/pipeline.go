@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+// readUrlsFile reads a list of urls, one per line. Empty lines and lines
+// starting with # are ignored.
+func readUrlsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening urls file: %w", err)
+	}
+	defer file.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading urls file: %w", err)
+	}
+
+	return urls, nil
+}
+
+// printBatchSummary prints the outcome of each url of a batch and returns
+// false if any of them failed. Nothing is printed with -quiet, the failures
+// being logged as they happen.
+func printBatchSummary(results []report.ArticleResult) bool {
+	counts := map[string]int{}
+	var usage summarize.Usage
+	for _, result := range results {
+		counts[result.Status]++
+		usage.Add(result.Usage)
+	}
+	if isQuiet() {
+		return counts[report.RESULT_FAILED] == 0
+	}
+
+	fmt.Println("\nSummary:")
+	for _, result := range results {
+		switch result.Status {
+		case report.RESULT_CREATED:
+			fmt.Printf("  ok      %s -> %s (%d tokens, %s)\n", result.Url, strings.Join(result.OutputPaths, ", "), result.Usage.InputTokens+result.Usage.OutputTokens, summarize.FormatCost(result.Usage.Cost))
+		case report.RESULT_PREVIEWED:
+			fmt.Printf("  preview %s (%d tokens, %s)\n", result.Url, result.Usage.InputTokens+result.Usage.OutputTokens, summarize.FormatCost(result.Usage.Cost))
+		case report.RESULT_SKIPPED:
+			fmt.Printf("  skipped %s\n", result.Url)
+		case report.RESULT_FAILED:
+			fmt.Printf("  failed  %s: %v\n", result.Url, result.Err)
+		}
+	}
+	if counts[report.RESULT_PREVIEWED] > 0 {
+		fmt.Printf("%d previewed, %d skipped, %d failed\n", counts[report.RESULT_PREVIEWED], counts[report.RESULT_SKIPPED], counts[report.RESULT_FAILED])
+	} else {
+		fmt.Printf("%d created, %d skipped, %d failed\n", counts[report.RESULT_CREATED], counts[report.RESULT_SKIPPED], counts[report.RESULT_FAILED])
+	}
+	if usage.InputTokens+usage.OutputTokens > 0 {
+		fmt.Printf("Usage: %s (estimated)\n", summarize.FormatUsage(usage))
+	}
+
+	return counts[report.RESULT_FAILED] == 0
+}
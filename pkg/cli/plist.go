@@ -0,0 +1,355 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	BINARY_PLIST_HEADER = "bplist00"
+	// Size of the trailer ending the binary property lists
+	BINARY_PLIST_TRAILER_SIZE = 32
+	// Maximum nesting of the property lists, against cyclic references
+	MAX_PLIST_DEPTH = 64
+)
+
+// Reference date of the dates of the property lists
+var plistEpoch = time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// parsePlist parses a property list, binary or XML, as written by macOS. Its
+// values are a map[string]any, []any, string, int64, float64, bool,
+// time.Time or []byte.
+func parsePlist(data []byte) (any, error) {
+	if bytes.HasPrefix(data, []byte(BINARY_PLIST_HEADER)) {
+		return parseBinaryPlist(data)
+	}
+	return parseXmlPlist(data)
+}
+
+type binaryPlist struct {
+	data          []byte
+	offsets       []uint64
+	objectRefSize int
+	// Objects already decoded, by reference
+	decoded map[uint64]any
+}
+
+func parseBinaryPlist(data []byte) (any, error) {
+	if len(data) < len(BINARY_PLIST_HEADER)+BINARY_PLIST_TRAILER_SIZE {
+		return nil, fmt.Errorf("binary property list too short")
+	}
+	trailer := data[len(data)-BINARY_PLIST_TRAILER_SIZE:]
+	offsetSize := int(trailer[6])
+	objectRefSize := int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:16])
+	topObject := binary.BigEndian.Uint64(trailer[16:24])
+	offsetTableOffset := binary.BigEndian.Uint64(trailer[24:32])
+
+	if offsetSize < 1 || offsetSize > 8 || objectRefSize < 1 || objectRefSize > 8 {
+		return nil, fmt.Errorf("invalid binary property list trailer")
+	}
+	// The offset table ends where the trailer starts
+	tableEnd := uint64(len(data) - BINARY_PLIST_TRAILER_SIZE)
+	if numObjects == 0 || topObject >= numObjects || offsetTableOffset > tableEnd ||
+		numObjects > (tableEnd-offsetTableOffset)/uint64(offsetSize) {
+		return nil, fmt.Errorf("invalid binary property list offset table")
+	}
+
+	plist := binaryPlist{data: data, objectRefSize: objectRefSize, offsets: make([]uint64, numObjects), decoded: map[uint64]any{}}
+	for i := range plist.offsets {
+		start := offsetTableOffset + uint64(i*offsetSize)
+		plist.offsets[i] = readBigEndian(data[start : start+uint64(offsetSize)])
+	}
+	return plist.object(topObject, 0)
+}
+
+func readBigEndian(b []byte) uint64 {
+	var value uint64
+	for _, c := range b {
+		value = value<<8 | uint64(c)
+	}
+	return value
+}
+
+// bytes returns n bytes of the property list from offset, or an error if
+// they are out of bounds.
+func (p binaryPlist) bytes(offset, n uint64) ([]byte, error) {
+	if offset > uint64(len(p.data)) || n > uint64(len(p.data))-offset {
+		return nil, fmt.Errorf("binary property list object out of bounds")
+	}
+	return p.data[offset : offset+n], nil
+}
+
+// object returns the object of a reference. An object is decoded once, and
+// shared by the containers referencing it: decoded for each reference, a
+// chain of arrays referencing the next one twice would take exponential time.
+func (p binaryPlist) object(ref uint64, depth int) (any, error) {
+	if ref >= uint64(len(p.offsets)) {
+		return nil, fmt.Errorf("invalid binary property list reference %d", ref)
+	}
+	if value, ok := p.decoded[ref]; ok {
+		return value, nil
+	}
+	value, err := p.decodeObject(ref, depth)
+	if err != nil {
+		return nil, err
+	}
+	p.decoded[ref] = value
+	return value, nil
+}
+
+func (p binaryPlist) decodeObject(ref uint64, depth int) (any, error) {
+	if depth > MAX_PLIST_DEPTH {
+		return nil, fmt.Errorf("binary property list nested too deeply")
+	}
+	offset := p.offsets[ref]
+	marker, err := p.bytes(offset, 1)
+	if err != nil {
+		return nil, err
+	}
+	kind, info := marker[0]>>4, marker[0]&0x0f
+	offset++
+
+	switch kind {
+	case 0x0:
+		switch info {
+		case 0x8:
+			return false, nil
+		case 0x9:
+			return true, nil
+		}
+		return nil, nil
+	case 0x1:
+		b, err := p.bytes(offset, 1<<info)
+		if err != nil {
+			return nil, err
+		}
+		// 16 bytes integers hold the value in their low 8 bytes
+		if len(b) > 8 {
+			b = b[len(b)-8:]
+		}
+		return int64(readBigEndian(b)), nil
+	case 0x2, 0x3:
+		if kind == 0x3 {
+			info = 3
+		}
+		b, err := p.bytes(offset, 1<<info)
+		if err != nil {
+			return nil, err
+		}
+		var value float64
+		switch len(b) {
+		case 4:
+			value = float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+		case 8:
+			value = math.Float64frombits(binary.BigEndian.Uint64(b))
+		default:
+			return nil, fmt.Errorf("invalid binary property list real of %d bytes", len(b))
+		}
+		if kind == 0x3 {
+			return plistDate(value), nil
+		}
+		return value, nil
+	case 0x8:
+		b, err := p.bytes(offset, uint64(info)+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 8 {
+			b = b[len(b)-8:]
+		}
+		return int64(readBigEndian(b)), nil
+	}
+
+	// The other objects have a length, in their marker or in the integer
+	// following it
+	length := uint64(info)
+	if info == 0xf {
+		lengthMarker, err := p.bytes(offset, 1)
+		if err != nil {
+			return nil, err
+		}
+		if lengthMarker[0]>>4 != 0x1 {
+			return nil, fmt.Errorf("invalid binary property list length")
+		}
+		size := uint64(1) << (lengthMarker[0] & 0x0f)
+		b, err := p.bytes(offset+1, size)
+		if err != nil {
+			return nil, err
+		}
+		length = readBigEndian(b)
+		offset += 1 + size
+	}
+
+	switch kind {
+	case 0x4:
+		b, err := p.bytes(offset, length)
+		return bytes.Clone(b), err
+	case 0x5:
+		b, err := p.bytes(offset, length)
+		return string(b), err
+	case 0x6:
+		if length > uint64(len(p.data))/2 {
+			return nil, fmt.Errorf("binary property list object out of bounds")
+		}
+		b, err := p.bytes(offset, 2*length)
+		if err != nil {
+			return nil, err
+		}
+		units := make([]uint16, length)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(b[2*i:])
+		}
+		return string(utf16.Decode(units)), nil
+	case 0xa:
+		refs, err := p.refs(offset, length)
+		if err != nil {
+			return nil, err
+		}
+		array := make([]any, len(refs))
+		for i, ref := range refs {
+			if array[i], err = p.object(ref, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	case 0xd:
+		if length > uint64(len(p.data)) {
+			return nil, fmt.Errorf("binary property list object out of bounds")
+		}
+		refs, err := p.refs(offset, 2*length)
+		if err != nil {
+			return nil, err
+		}
+		dict := make(map[string]any, length)
+		for i := uint64(0); i < length; i++ {
+			key, err := p.object(refs[i], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			keyString, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("binary property list dictionary key is not a string")
+			}
+			if dict[keyString], err = p.object(refs[length+i], depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	}
+	return nil, fmt.Errorf("unknown binary property list object type 0x%x", kind)
+}
+
+func (p binaryPlist) refs(offset, n uint64) ([]uint64, error) {
+	if n > uint64(len(p.data)) {
+		return nil, fmt.Errorf("binary property list object out of bounds")
+	}
+	b, err := p.bytes(offset, n*uint64(p.objectRefSize))
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]uint64, n)
+	for i := range refs {
+		refs[i] = readBigEndian(b[i*p.objectRefSize : (i+1)*p.objectRefSize])
+	}
+	return refs, nil
+}
+
+// plistDate returns the time of a date of a binary property list, in seconds
+// since 2001.
+func plistDate(seconds float64) time.Time {
+	whole, fraction := math.Modf(seconds)
+	return plistEpoch.Add(time.Duration(whole) * time.Second).Add(time.Duration(fraction * float64(time.Second)))
+}
+
+func parseXmlPlist(data []byte) (any, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("parsing property list: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local != "plist" {
+			return xmlPlistValue(decoder, start, 0)
+		}
+	}
+}
+
+// xmlPlistValue returns the value of the element start of an XML property
+// list, reading the decoder up to its end.
+func xmlPlistValue(decoder *xml.Decoder, start xml.StartElement, depth int) (any, error) {
+	if depth > MAX_PLIST_DEPTH {
+		return nil, fmt.Errorf("property list nested too deeply")
+	}
+
+	switch start.Name.Local {
+	case "dict", "array":
+		dict := map[string]any{}
+		var array []any
+		key := ""
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, fmt.Errorf("parsing property list: %w", err)
+			}
+			switch token := token.(type) {
+			case xml.EndElement:
+				if start.Name.Local == "dict" {
+					return dict, nil
+				}
+				return array, nil
+			case xml.StartElement:
+				if start.Name.Local == "dict" && token.Name.Local == "key" {
+					if err := decoder.DecodeElement(&key, &token); err != nil {
+						return nil, fmt.Errorf("parsing property list: %w", err)
+					}
+					continue
+				}
+				value, err := xmlPlistValue(decoder, token, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				if start.Name.Local == "dict" {
+					dict[key] = value
+				} else {
+					array = append(array, value)
+				}
+			}
+		}
+	case "true", "false":
+		if err := decoder.Skip(); err != nil {
+			return nil, fmt.Errorf("parsing property list: %w", err)
+		}
+		return start.Name.Local == "true", nil
+	}
+
+	var text string
+	if err := decoder.DecodeElement(&text, &start); err != nil {
+		return nil, fmt.Errorf("parsing property list: %w", err)
+	}
+	if start.Name.Local == "string" {
+		// The whitespace of the strings is theirs, the one of the other
+		// values is indentation
+		return text, nil
+	}
+	text = strings.TrimSpace(text)
+	switch start.Name.Local {
+	case "integer":
+		return strconv.ParseInt(text, 10, 64)
+	case "real":
+		return strconv.ParseFloat(text, 64)
+	case "date":
+		return time.Parse(time.RFC3339, text)
+	case "data":
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+	}
+	return nil, fmt.Errorf("unknown property list element <%s>", start.Name.Local)
+}
@@ -0,0 +1,287 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// plistWriter writes the binary property lists of the tests, its objects
+// written before the containers referencing them.
+type plistWriter struct {
+	objects [][]byte
+}
+
+func (w *plistWriter) add(object []byte) uint64 {
+	w.objects = append(w.objects, object)
+	return uint64(len(w.objects) - 1)
+}
+
+func plistMarker(kind byte, length int) []byte {
+	if length < 0xf {
+		return []byte{kind<<4 | byte(length)}
+	}
+	return []byte{kind<<4 | 0xf, 0x11, byte(length >> 8), byte(length)}
+}
+
+func (w *plistWriter) value(value any) uint64 {
+	switch value := value.(type) {
+	case bool:
+		if value {
+			return w.add([]byte{0x09})
+		}
+		return w.add([]byte{0x08})
+	case int64:
+		return w.add(binary.BigEndian.AppendUint64([]byte{0x13}, uint64(value)))
+	case float64:
+		return w.add(binary.BigEndian.AppendUint64([]byte{0x23}, math.Float64bits(value)))
+	case time.Time:
+		seconds := value.Sub(plistEpoch).Seconds()
+		return w.add(binary.BigEndian.AppendUint64([]byte{0x33}, math.Float64bits(seconds)))
+	case []byte:
+		return w.add(append(plistMarker(0x4, len(value)), value...))
+	case string:
+		if isAscii(value) {
+			return w.add(append(plistMarker(0x5, len(value)), value...))
+		}
+		units := utf16.Encode([]rune(value))
+		object := plistMarker(0x6, len(units))
+		for _, unit := range units {
+			object = binary.BigEndian.AppendUint16(object, unit)
+		}
+		return w.add(object)
+	case []any:
+		var refs []byte
+		for _, item := range value {
+			refs = append(refs, byte(w.value(item)))
+		}
+		return w.add(append(plistMarker(0xa, len(value)), refs...))
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		var keyRefs, valueRefs []byte
+		for _, key := range keys {
+			keyRefs = append(keyRefs, byte(w.value(key)))
+			valueRefs = append(valueRefs, byte(w.value(value[key])))
+		}
+		return w.add(append(append(plistMarker(0xd, len(keys)), keyRefs...), valueRefs...))
+	}
+	panic(fmt.Sprintf("unsupported property list value %T", value))
+}
+
+func isAscii(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeBinaryPlist writes a value as a binary property list, with 2 bytes
+// offsets and 1 byte references.
+func encodeBinaryPlist(value any) []byte {
+	var w plistWriter
+	return w.encode(w.value(value))
+}
+
+// encode writes the objects as a binary property list whose top object is
+// top.
+func (w *plistWriter) encode(top uint64) []byte {
+	data := []byte(BINARY_PLIST_HEADER)
+	offsets := make([]uint64, len(w.objects))
+	for i, object := range w.objects {
+		offsets[i] = uint64(len(data))
+		data = append(data, object...)
+	}
+	offsetTable := uint64(len(data))
+	for _, offset := range offsets {
+		data = binary.BigEndian.AppendUint16(data, uint16(offset))
+	}
+	data = append(data, 0, 0, 0, 0, 0, 0, 2, 1)
+	data = binary.BigEndian.AppendUint64(data, uint64(len(w.objects)))
+	data = binary.BigEndian.AppendUint64(data, top)
+	return binary.BigEndian.AppendUint64(data, offsetTable)
+}
+
+// encodeXmlPlist writes a value as an XML property list.
+func encodeXmlPlist(value any) []byte {
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	sb.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	sb.WriteString(`<plist version="1.0">` + "\n")
+	writeXmlPlistValue(&sb, value)
+	sb.WriteString("</plist>\n")
+	return []byte(sb.String())
+}
+
+func writeXmlPlistValue(sb *strings.Builder, value any) {
+	element := func(name, text string) {
+		sb.WriteString("<" + name + ">")
+		xml.EscapeText(sb, []byte(text))
+		sb.WriteString("</" + name + ">\n")
+	}
+	switch value := value.(type) {
+	case bool:
+		sb.WriteString("<" + strconv.FormatBool(value) + "/>\n")
+	case int64:
+		element("integer", strconv.FormatInt(value, 10))
+	case float64:
+		element("real", strconv.FormatFloat(value, 'g', -1, 64))
+	case time.Time:
+		element("date", value.UTC().Format(time.RFC3339))
+	case []byte:
+		element("data", base64.StdEncoding.EncodeToString(value))
+	case string:
+		element("string", value)
+	case []any:
+		sb.WriteString("<array>\n")
+		for _, item := range value {
+			writeXmlPlistValue(sb, item)
+		}
+		sb.WriteString("</array>\n")
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		sb.WriteString("<dict>\n")
+		for _, key := range keys {
+			element("key", key)
+			writeXmlPlistValue(sb, value[key])
+		}
+		sb.WriteString("</dict>\n")
+	default:
+		panic(fmt.Sprintf("unsupported property list value %T", value))
+	}
+}
+
+func TestPlistRoundTrip(t *testing.T) {
+	longString := strings.Repeat("reading list ", 5)
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"string", "https://example.com/article"},
+		{"utf-16 string", "Café – 日本語"},
+		{"long string", longString},
+		{"integer", int64(1234567890123)},
+		{"negative integer", int64(-42)},
+		{"real", 3.25},
+		{"true", true},
+		{"false", false},
+		{"date", time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC)},
+		{"data", []byte{0x00, 0x01, 0xfe, 0xff}},
+		{"array", []any{"a", int64(1), true}},
+		{"safari reading list", map[string]any{
+			"Title": "BookmarksBar",
+			"Children": []any{
+				map[string]any{
+					"URLString":       "https://example.com/one",
+					"URIDictionary":   map[string]any{"title": "One"},
+					"ReadingList":     map[string]any{"DateAdded": time.Date(2023, time.December, 24, 8, 0, 0, 0, time.UTC), "PreviewText": "Première page"},
+					"WebBookmarkType": "WebBookmarkTypeLeaf",
+				},
+				map[string]any{
+					"URLString":       "https://example.com/two",
+					"WebBookmarkType": "WebBookmarkTypeLeaf",
+				},
+			},
+		}},
+	}
+	for _, test := range tests {
+		encodings := []struct {
+			name string
+			data []byte
+		}{
+			{"binary", encodeBinaryPlist(test.value)},
+			{"xml", encodeXmlPlist(test.value)},
+		}
+		for _, encoding := range encodings {
+			t.Run(test.name+"/"+encoding.name, func(t *testing.T) {
+				value, err := parsePlist(encoding.data)
+				if err != nil {
+					t.Fatalf("parsePlist: %v", err)
+				}
+				if !reflect.DeepEqual(value, test.value) {
+					t.Errorf("parsePlist = %#v, want %#v", value, test.value)
+				}
+			})
+		}
+	}
+}
+
+func TestParseBinaryPlistSharedObjects(t *testing.T) {
+	// Each array references the next one twice: decoded again for each of
+	// its references, the 40 arrays would take 2^40 decodings
+	const depth = 40
+	var w plistWriter
+	ref := w.value("leaf")
+	for range depth {
+		ref = w.add(append(plistMarker(0xa, 2), byte(ref), byte(ref)))
+	}
+	data := w.encode(ref)
+
+	parsed := make(chan any, 1)
+	go func() {
+		value, err := parsePlist(data)
+		if err != nil {
+			t.Errorf("parsePlist: %v", err)
+		}
+		parsed <- value
+	}()
+	var value any
+	select {
+	case value = <-parsed:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("parsePlist of %d bytes still running", len(data))
+	}
+	for i := range depth {
+		array, ok := value.([]any)
+		if !ok || len(array) != 2 {
+			t.Fatalf("value at depth %d = %#v, want an array of 2 items", i, value)
+		}
+		value = array[1]
+	}
+	if value != "leaf" {
+		t.Errorf("value at depth %d = %#v, want the leaf", depth, value)
+	}
+}
+
+func TestParseBinaryPlistErrors(t *testing.T) {
+	valid := encodeBinaryPlist(map[string]any{"key": "value"})
+	cyclic := encodeBinaryPlist([]any{"item"})
+	// The array, the last object, references itself instead of its item
+	cyclic[bytes.LastIndexByte(cyclic[:len(cyclic)-BINARY_PLIST_TRAILER_SIZE-4], 0xa1)+1] = 1
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"too short", []byte(BINARY_PLIST_HEADER + "\x00")},
+		{"truncated offset table", append(bytes.Clone(valid[:len(valid)-BINARY_PLIST_TRAILER_SIZE-2]), valid[len(valid)-BINARY_PLIST_TRAILER_SIZE:]...)},
+		{"cyclic", cyclic},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if value, err := parsePlist(test.data); err == nil {
+				t.Errorf("parsePlist = %#v, want an error", value)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const (
+	POCKET_STATUS_ALL     = "all"
+	POCKET_STATUS_UNREAD  = "unread"
+	POCKET_STATUS_ARCHIVE = "archive"
+)
+
+// PocketItem is a page saved in Pocket, as listed by its export.
+type PocketItem struct {
+	ReadingListEntry
+	// unread or archive
+	Status string
+}
+
+func runImportPocket(config Config, args []string) error {
+	flags := flag.NewFlagSet("import-pocket", flag.ExitOnError)
+	status := flags.String("status", POCKET_STATUS_ALL, "items to import: "+POCKET_STATUS_ALL+", "+POCKET_STATUS_UNREAD+" or "+POCKET_STATUS_ARCHIVE)
+	dryRun := flags.Bool("dry-run", false, "list the items without summarizing them")
+	reportFlags := addReportFlags(flags, config)
+	flags.Usage = func() {
+		fmt.Println("Usage: report import-pocket [-status all|unread|archive] [-dry-run] [options] <output-folder> <export.zip|part_000000.csv>")
+		fmt.Println("Summarize the items of a Pocket export, oldest first, keeping their Pocket tags and the date they were saved.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 2)
+	if len(args) != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder and a Pocket export file")
+	}
+	if *status != POCKET_STATUS_ALL && *status != POCKET_STATUS_UNREAD && *status != POCKET_STATUS_ARCHIVE {
+		return fmt.Errorf("unknown status '%s', expected %s, %s or %s", *status, POCKET_STATUS_ALL, POCKET_STATUS_UNREAD, POCKET_STATUS_ARCHIVE)
+	}
+	outputFolder, path := args[0], args[1]
+
+	items, err := readPocketExport(path)
+	if err != nil {
+		return err
+	}
+	var entries []ReadingListEntry
+	for _, item := range items {
+		if *status == POCKET_STATUS_ALL || item.Status == *status {
+			entries = append(entries, item.ReadingListEntry)
+		}
+	}
+	entries = sortReadingList(entries)
+	fmt.Printf("%s: %d items, %d to import\n", path, len(items), len(entries))
+
+	if *dryRun {
+		for _, entry := range entries {
+			fmt.Printf("  %s  %s  %s", entry.Added.Format(scrape.DATE_FORMAT), entry.Url, entry.Title)
+			if len(entry.Tags) > 0 {
+				fmt.Printf("  [%s]", strings.Join(entry.Tags, ", "))
+			}
+			fmt.Println()
+		}
+		return nil
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	options, err := reportFlags.options(outputFolder)
+	if err != nil {
+		return err
+	}
+	defer options.Events.Close()
+	options.NonInteractive = true
+	options.AddedDates = map[string]time.Time{}
+	options.ImportedTags = map[string][]string{}
+	articleUrls := make([]string, len(entries))
+	for i, entry := range entries {
+		articleUrls[i] = entry.Url
+		options.AddedDates[entry.Url] = entry.Added
+		options.ImportedTags[entry.Url] = entry.Tags
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	ctx = summarize.WithTerminalProgress(ctx, *reportFlags.provider.progress, *reportFlags.concurrency)
+
+	client, err := report.New(report.WithReportOptions(options))
+	if err != nil {
+		return err
+	}
+	results := client.CreateAll(ctx, articleUrls, *reportFlags.concurrency)
+	if !printBatchSummary(results) {
+		return fmt.Errorf("some items failed")
+	}
+	return nil
+}
+
+// readPocketExport returns the items of a Pocket export: the zip archive
+// downloaded from Pocket, holding CSV files, or one of these files.
+func readPocketExport(exportPath string) ([]PocketItem, error) {
+	if !strings.EqualFold(path.Ext(exportPath), ".zip") {
+		file, err := os.Open(exportPath)
+		if err != nil {
+			return nil, fmt.Errorf("opening Pocket export: %w", err)
+		}
+		defer file.Close()
+		items, err := parsePocketCsv(file)
+		if err != nil {
+			return nil, fmt.Errorf("parsing Pocket export '%s': %w", exportPath, err)
+		}
+		return items, nil
+	}
+
+	archive, err := zip.OpenReader(exportPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening Pocket export: %w", err)
+	}
+	defer archive.Close()
+
+	// The items are split in part_000000.csv, part_000001.csv...
+	var files []*zip.File
+	for _, file := range archive.File {
+		if strings.EqualFold(path.Ext(file.Name), ".csv") && !strings.HasPrefix(path.Base(file.Name), ".") {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no CSV file in the Pocket export '%s'", exportPath)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	var items []PocketItem
+	for _, file := range files {
+		reader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("opening '%s' of the Pocket export: %w", file.Name, err)
+		}
+		fileItems, err := parsePocketCsv(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parsing '%s' of the Pocket export: %w", file.Name, err)
+		}
+		items = append(items, fileItems...)
+	}
+	return items, nil
+}
+
+// parsePocketCsv parses a CSV file of a Pocket export, with the title, url,
+// time_added (a Unix timestamp), tags (separated by |) and status columns.
+func parsePocketCsv(reader io.Reader) ([]PocketItem, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, export.UTF8_BOM)))] = i
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, fmt.Errorf("no 'url' column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var items []PocketItem
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		item := PocketItem{ReadingListEntry: ReadingListEntry{Url: field(record, "url"), Title: field(record, "title")}, Status: field(record, "status")}
+		if item.Url == "" {
+			continue
+		}
+		if seconds, err := strconv.ParseInt(field(record, "time_added"), 10, 64); err == nil && seconds > 0 {
+			item.Added = time.Unix(seconds, 0).UTC()
+		}
+		for _, tag := range strings.Split(field(record, "tags"), "|") {
+			if tag = reportTag(tag); tag != "" {
+				item.Tags = append(item.Tags, tag)
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// reportTag returns a tag of another service, such as Pocket, written like
+// the tags of the reports, lower case with dashes.
+func reportTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+}
@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// Style of the printable reports: a single readable column on screen, and
+// the urls of the links written after them on paper.
+const printStylesheet = `body { max-width: 40em; margin: 2em auto; padding: 0 1em; font-family: Georgia, "Times New Roman", serif; line-height: 1.5; color: #111; }
+h1 { font-size: 1.6em; line-height: 1.25; margin-bottom: 0.3em; }
+h2 { font-size: 1.15em; margin-top: 1.5em; }
+.meta, .tags, footer { font-size: 0.85em; color: #555; }
+.tags { font-style: italic; }
+footer { margin-top: 2em; border-top: 1px solid #ccc; padding-top: 0.5em; word-break: break-all; }
+a { color: inherit; }
+@page { margin: 2cm; }
+@media print {
+  body { margin: 0; max-width: none; font-size: 11pt; }
+  h2 { break-after: avoid; }
+  li { break-inside: avoid; }
+  main a[href^="http"]::after { content: " (" attr(href) ")"; font-size: 0.85em; color: #555; }
+}
+`
+
+func runPrint(config Config, args []string) error {
+	flags := flag.NewFlagSet("print", flag.ExitOnError)
+	output := flags.String("o", "", "path of the HTML file, defaults to the name of the report with .html in the current folder")
+	pdf := flags.Bool("pdf", false, "also print the page to a PDF file next to the HTML file, with a headless browser")
+	browser := flags.String("renderer", "", "browser executable printing the PDF, found in the PATH if not set")
+	permissions := addPermissionFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report print [-o report.html] [-pdf] [-renderer chromium] <output-folder> <report|id>")
+		fmt.Println("Write a report as a standalone HTML page, ready to print or share, and optionally as a PDF.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 2)
+	if len(args) != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder and a report")
+	}
+
+	report, err := resolveReport(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(filepath.Base(report.Path), filepath.Ext(report.Path)) + ".html"
+	}
+
+	if err := permissions.WriteFile(*output, []byte(renderPrintableReport(report))); err != nil {
+		return fmt.Errorf("writing HTML page: %w", err)
+	}
+	fmt.Printf("HTML page created successfully: %s\n", *output)
+	if !*pdf {
+		return nil
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	pdfPath := strings.TrimSuffix(*output, filepath.Ext(*output)) + ".pdf"
+	if err := printToPdf(ctx, scrape.Renderer{Backend: *browser}, scrape.DEFAULT_FETCH_TIMEOUT, *output, pdfPath, *permissions); err != nil {
+		return err
+	}
+	fmt.Printf("PDF created successfully: %s\n", pdfPath)
+	return nil
+}
+
+// renderPrintableReport renders a report as a standalone HTML page: its
+// title, the link to the article, the summary, the key points and the tags.
+// The notes of the report are left out, the page being meant for sharing.
+func renderPrintableReport(report export.Report) string {
+	language := report.Language
+	if language == "" {
+		language = "en"
+	}
+	heading := report.KeypointsHeading
+	if heading == "" {
+		heading = "Key Points"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<!DOCTYPE html>
+<html lang="%s">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>
+%s</style>
+</head>
+<body>
+<main>
+<h1>%s</h1>
+`, html.EscapeString(language), html.EscapeString(report.Title), printStylesheet, html.EscapeString(report.Title))
+
+	meta := []string{fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(report.Url), html.EscapeString(export.SiteNameFromUrl(report.Url)))}
+	if report.DateCreated != "" {
+		meta = append(meta, "summarized on "+html.EscapeString(report.DateCreated))
+	}
+	fmt.Fprintf(&sb, "<p class=\"meta\">%s</p>\n", strings.Join(meta, " · "))
+
+	sb.WriteString(export.ReportToHtml(report.Summary, nil) + "\n")
+	if len(report.Keypoints) > 0 {
+		fmt.Fprintf(&sb, "<h2>%s</h2>\n%s\n", html.EscapeString(heading), export.ReportToHtml("", report.Keypoints))
+	}
+	if len(report.Tags) > 0 {
+		fmt.Fprintf(&sb, "<p class=\"tags\">%s</p>\n", html.EscapeString("#"+strings.Join(report.Tags, " #")))
+	}
+	fmt.Fprintf(&sb, "</main>\n<footer>Source: %s</footer>\n</body>\n</html>\n", html.EscapeString(report.Url))
+	return sb.String()
+}
+
+// printToPdf prints an HTML file to a PDF file with a headless browser,
+// without the date and the file url that browsers write in the margins. The
+// browser is stopped after the timeout, 0 for no limit.
+func printToPdf(ctx context.Context, r scrape.Renderer, timeout time.Duration, htmlPath, pdfPath string, permissions export.FilePermissions) error {
+	ctx, cancel := scrape.WithTimeout(ctx, timeout, "PDF printing")
+	defer cancel()
+
+	browser, err := r.Browser()
+	if err != nil {
+		return fmt.Errorf("printing the PDF: %w, or print the HTML page from a browser", err)
+	}
+	absolutePdfPath, err := filepath.Abs(pdfPath)
+	if err != nil {
+		return fmt.Errorf("resolving PDF path: %w", err)
+	}
+
+	// The browser writes the PDF with its own mode: it is printed in a folder
+	// only the user can read, given the output permissions, then moved
+	folder, err := os.MkdirTemp(filepath.Dir(absolutePdfPath), ".print-*")
+	if err != nil {
+		return export.NewWriteError("creating folder in", filepath.Dir(absolutePdfPath), err)
+	}
+	defer os.RemoveAll(folder)
+	printedPath := filepath.Join(folder, filepath.Base(absolutePdfPath))
+
+	// --print-to-pdf-no-header is the name of --no-pdf-header-footer in the
+	// browsers before version 111
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, browser, "--headless", "--disable-gpu", "--no-pdf-header-footer", "--print-to-pdf-no-header", "--print-to-pdf="+printedPath, scrape.FileUrl(htmlPath))
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("printing the PDF with %s: %w: %s", browser, scrape.TimeoutCause(ctx, err), strings.TrimSpace(stderr.String()))
+	}
+	if _, err := os.Stat(printedPath); err != nil {
+		return fmt.Errorf("printing the PDF with %s: no PDF written: %s", browser, strings.TrimSpace(stderr.String()))
+	}
+	if err := permissions.Apply(printedPath, permissions.FileMode); err != nil {
+		return err
+	}
+	if err := os.Rename(printedPath, absolutePdfPath); err != nil {
+		return export.NewWriteError("moving the PDF to", absolutePdfPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/brequet/report/pkg/summarize"
+)
+
+func runProfiles(config Config, args []string) error {
+	flags := flag.NewFlagSet("profiles", flag.ExitOnError)
+	show := flags.String("show", "", "print the system prompt of this profile")
+	flags.Usage = func() {
+		fmt.Println("Usage: report profiles [-show name]")
+		fmt.Println("List the prompt profiles, selected with -profile, and the folder of the user's profiles.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *show != "" {
+		prompt, err := summarize.GetProfilePrompt(*show)
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.TrimSpace(prompt))
+		return nil
+	}
+
+	profiles, err := summarize.ListProfiles()
+	if err != nil {
+		return err
+	}
+	for _, profile := range profiles {
+		source := "embedded"
+		if profile.Path != "" {
+			source = profile.Path
+		}
+		fmt.Printf("%-20s %s\n", profile.Name, source)
+	}
+	if dir, err := summarize.ProfilesDir(); err == nil {
+		fmt.Printf("\nAdd a profile by writing its system prompt to %s\n", filepath.Join(dir, "<name>"+summarize.PROFILE_FILE_EXTENSION))
+	}
+	return nil
+}
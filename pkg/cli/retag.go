@@ -0,0 +1,275 @@
+package cli
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/summarize"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed retag-prompt.md
+var retagPrompt string
+
+// TaxonomyTag is a tag of the taxonomy the reports are retagged with, with an
+// optional description guiding the LLM.
+type TaxonomyTag struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// RetagResult is the answer of the LLM retagging a report.
+type RetagResult struct {
+	Tags []string `json:"tags"`
+}
+
+func runRetag(config Config, args []string) error {
+	flags := flag.NewFlagSet("retag", flag.ExitOnError)
+	taxonomyPath := flags.String("taxonomy", "", "YAML file listing the tags to retag the reports with (required)")
+	dryRun := flags.Bool("dry-run", false, "print the new tags of the reports without changing them")
+	providerFlags := addProviderFlags(flags, config)
+	permissions := addPermissionFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report retag -taxonomy tags.yaml [-dry-run] <output-folder>")
+		fmt.Println("Replace the tags of the reports by tags of the taxonomy, picked by the LLM from their summaries.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) != 1 || *taxonomyPath == "" {
+		flags.Usage()
+		return fmt.Errorf("expected a taxonomy and an output folder")
+	}
+	outputFolder := args[0]
+
+	taxonomy, err := loadTaxonomy(*taxonomyPath)
+	if err != nil {
+		return err
+	}
+	provider, err := providerFlags.provider()
+	if err != nil {
+		return err
+	}
+	reports, err := export.LoadReports(outputFolder)
+	if err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		fmt.Println("No report found")
+		return nil
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	ctx = summarize.WithTerminalProgress(ctx, *providerFlags.progress, 1)
+
+	retagged, failed := 0, 0
+	for i, report := range reports {
+		if ctx.Err() != nil {
+			fmt.Printf("Interrupted, %d reports not retagged\n", len(reports)-i)
+			break
+		}
+
+		tags, err := retagReport(ctx, provider, taxonomy, report)
+		if err != nil {
+			slog.Warn("could not retag the report", "report", export.ReportFileName(report), "err", err)
+			failed++
+			continue
+		}
+		if len(tags) == 0 {
+			fmt.Printf("%s: no tag of the taxonomy fits, tags kept\n", report.Title)
+			continue
+		}
+		if strings.Join(tags, ",") == strings.Join(report.Tags, ",") {
+			fmt.Printf("%s: unchanged\n", report.Title)
+			continue
+		}
+
+		fmt.Printf("%s: %s -> %s\n", report.Title, strings.Join(report.Tags, ", "), strings.Join(tags, ", "))
+		if *dryRun {
+			retagged++
+			continue
+		}
+		if err := writeReportTags(report.Path, *permissions, tags); err != nil {
+			slog.Warn("could not update the report", "path", report.Path, "err", err)
+			failed++
+			continue
+		}
+		retagged++
+	}
+
+	if *dryRun {
+		fmt.Printf("%d of %d reports would be retagged\n", retagged, len(reports))
+	} else {
+		fmt.Printf("%d of %d reports retagged\n", retagged, len(reports))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d reports could not be retagged", failed)
+	}
+	return nil
+}
+
+// loadTaxonomy reads the tags of a taxonomy file: a list of tags, a list of
+// tags with a name and a description, or a map of tag names to descriptions,
+// at the top level or under a "tags" key.
+func loadTaxonomy(path string) ([]TaxonomyTag, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading taxonomy: %w", err)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("parsing taxonomy '%s': %w", path, err)
+	}
+	if len(document.Content) == 0 {
+		return nil, fmt.Errorf("taxonomy '%s' is empty", path)
+	}
+	node := document.Content[0]
+	if node.Kind == yaml.MappingNode && len(node.Content) == 2 && node.Content[0].Value == "tags" {
+		node = node.Content[1]
+	}
+
+	var tags []TaxonomyTag
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			tag := TaxonomyTag{Name: item.Value}
+			if item.Kind == yaml.MappingNode {
+				if err := item.Decode(&tag); err != nil {
+					return nil, fmt.Errorf("parsing taxonomy '%s': %w", path, err)
+				}
+			}
+			tags = append(tags, tag)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			tags = append(tags, TaxonomyTag{Name: node.Content[i].Value, Description: node.Content[i+1].Value})
+		}
+	default:
+		return nil, fmt.Errorf("taxonomy '%s' is not a list or a map of tags", path)
+	}
+
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		key := strings.ToLower(strings.TrimSpace(tag.Name))
+		if key == "" {
+			return nil, fmt.Errorf("taxonomy '%s': tag without a name", path)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("taxonomy '%s': duplicate tag '%s'", path, tag.Name)
+		}
+		seen[key] = true
+	}
+	return tags, nil
+}
+
+// retagReport asks the LLM for the tags of the taxonomy fitting a report,
+// from its summary. Tags outside of the taxonomy are dropped.
+func retagReport(ctx context.Context, provider summarize.LLMProvider, taxonomy []TaxonomyTag, report export.Report) ([]string, error) {
+	var taxonomyLines []string
+	for _, tag := range taxonomy {
+		line := "- " + tag.Name
+		if tag.Description != "" {
+			line += ": " + tag.Description
+		}
+		taxonomyLines = append(taxonomyLines, line)
+	}
+	prompt := strings.TrimSpace(retagPrompt) + "\n\nThe new set of tags:\n" + strings.Join(taxonomyLines, "\n")
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n%s\n", report.Title, report.Summary)
+	for _, keypoint := range report.Keypoints {
+		fmt.Fprintf(&sb, "- %s\n", keypoint)
+	}
+	fmt.Fprintf(&sb, "\nCurrent tags: %s\n", strings.Join(report.Tags, ", "))
+
+	answer, err := provider.Complete(ctx, []summarize.ChatMessage{
+		{Role: "system", Content: prompt},
+		{Role: "user", Content: sb.String()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result RetagResult
+	if err := json.Unmarshal([]byte(answer), &result); err != nil {
+		return nil, fmt.Errorf("unmarshaling tags: %w", err)
+	}
+
+	names := map[string]string{}
+	for _, tag := range taxonomy {
+		names[strings.ToLower(strings.TrimSpace(tag.Name))] = strings.TrimSpace(tag.Name)
+	}
+	var tags []string
+	seen := map[string]bool{}
+	for _, tag := range result.Tags {
+		name, ok := names[strings.ToLower(strings.TrimSpace(tag))]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	return tags, nil
+}
+
+// writeReportTags replaces the tags of the frontmatter of a report, keeping
+// the indentation of its list and its line endings.
+func writeReportTags(path string, permissions export.FilePermissions, tags []string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading report: %w", err)
+	}
+	content, err := replaceFrontmatterTags(string(data), tags)
+	if err != nil {
+		return err
+	}
+	return permissions.WriteFile(path, []byte(content))
+}
+
+func replaceFrontmatterTags(content string, tags []string) (string, error) {
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+	}
+	lines := strings.Split(content, newline)
+	if len(lines) == 0 || strings.TrimPrefix(lines[0], export.UTF8_BOM) != "---" {
+		return "", fmt.Errorf("report has no frontmatter")
+	}
+
+	for i := 1; i < len(lines) && lines[i] != "---"; i++ {
+		if !strings.HasPrefix(lines[i], "tags:") {
+			continue
+		}
+
+		end := i + 1
+		indent := ""
+		for ; end < len(lines) && lines[end] != "---"; end++ {
+			trimmed := strings.TrimLeft(lines[end], " ")
+			if !strings.HasPrefix(trimmed, "- ") && trimmed != "-" {
+				break
+			}
+			if end == i+1 {
+				indent = lines[end][:len(lines[end])-len(trimmed)]
+			}
+		}
+
+		tagLines := []string{"tags:"}
+		for _, tag := range tags {
+			tagLines = append(tagLines, indent+"- "+tag)
+		}
+
+		lines = append(lines[:i], append(tagLines, lines[end:]...)...)
+		return strings.Join(lines, newline), nil
+	}
+	return "", fmt.Errorf("report has no tags in its frontmatter")
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"cmp"
+	"flag"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// addScraperFlags adds the flags setting a scraper to a flag set, with the
+// site rules and the defaults of the config file.
+func addScraperFlags(flags *flag.FlagSet, scraper *scrape.Scraper, config Config) {
+	addFetchPolicyFlags(flags, &scraper.Policy)
+	addPageCacheFlags(flags, &scraper.Cache)
+	addFetchCredentialsFlags(flags, &scraper.Credentials)
+	addRenderFlags(flags, &scraper.Renderer)
+	addOcrFlags(flags, &scraper.Ocr, config.Ocr)
+	addArchiveFlags(flags, &scraper.Archive)
+	addRetryFlags(flags, &scraper.Retry)
+	// The rules are checked when the config file is loaded
+	scraper.SiteRules, _ = scrape.CompileSiteRules(config.Sites)
+}
+
+// addFetchCredentialsFlags adds the flags setting fetch credentials to a flag
+// set.
+func addFetchCredentialsFlags(flags *flag.FlagSet, credentials *scrape.FetchCredentials) {
+	if credentials.Headers == nil {
+		credentials.Headers = scrape.HeaderFlags{}
+	}
+	flags.StringVar(&credentials.UserAgent, "user-agent", scrape.DefaultUserAgent(), "User-Agent header of the page fetches")
+	flags.Var(credentials.Headers, "header", "add this header to the page fetches, as 'Name: value' (repeatable)")
+	flags.StringVar(&credentials.BasicAuth, "basic-auth", "", "fetch the pages with this basic authentication, as user:password")
+	flags.StringVar(&credentials.CookieFile, "cookie-file", "", "send the cookies of this Netscape cookie file (cookies.txt) with the page fetches")
+}
+
+// addFetchPolicyFlags adds the flags setting a fetch policy to a flag set.
+func addFetchPolicyFlags(flags *flag.FlagSet, policy *scrape.FetchPolicy) {
+	flags.IntVar(&policy.MaxRedirects, "max-redirects", scrape.DEFAULT_MAX_REDIRECTS, "maximum number of redirects followed when fetching a page")
+	flags.BoolVar(&policy.BlockPrivateNetworks, "block-private-networks", false, "refuse to fetch pages from localhost and private networks")
+	flags.DurationVar(&policy.Timeout, "fetch-timeout", scrape.DEFAULT_FETCH_TIMEOUT, "maximum duration of a page fetch, 0 for no limit")
+}
+
+// addOcrFlags adds the flags setting an OCR engine to a flag set.
+func addOcrFlags(flags *flag.FlagSet, ocrEngine *scrape.OcrEngine, config scrape.OcrConfig) {
+	flags.StringVar(&ocrEngine.Mode, "ocr", cmp.Or(config.Mode, scrape.OCR_NEVER), "read the text of the images of the pages: "+scrape.OCR_NEVER+", "+scrape.OCR_AUTO+" (when the page is mostly images) or "+scrape.OCR_ALWAYS)
+	flags.StringVar(&ocrEngine.Backend, "ocr-backend", config.Backend, "tesseract executable, or url of an OCR service, found in the PATH if not set")
+	flags.StringVar(&ocrEngine.Language, "ocr-lang", config.Language, "languages of the text of the images, as given to the OCR engine (e.g. eng+fra)")
+}
+
+// addRenderFlags adds the flags setting a renderer to a flag set.
+func addRenderFlags(flags *flag.FlagSet, renderer *scrape.Renderer) {
+	flags.StringVar(&renderer.Mode, "render", scrape.RENDER_AUTO, "render the pages with a headless browser: "+scrape.RENDER_AUTO+" (when their content looks empty), "+scrape.RENDER_ALWAYS+" or "+scrape.RENDER_NEVER)
+	flags.StringVar(&renderer.Backend, "renderer", "", "browser executable, or url of a rendering service, found in the PATH if not set")
+}
+
+// addRetryFlags adds the flags setting a retry policy to a flag set.
+func addRetryFlags(flags *flag.FlagSet, policy *scrape.RetryPolicy) {
+	defaults := scrape.DefaultRetryPolicy()
+	flags.IntVar(&policy.MaxRetries, "retries", defaults.MaxRetries, "number of retries of page fetches and LLM calls failing for a transient reason")
+	flags.DurationVar(&policy.BaseDelay, "retry-delay", defaults.BaseDelay, "delay before the first retry, doubled at each retry")
+	flags.DurationVar(&policy.MaxDelay, "retry-max-delay", defaults.MaxDelay, "maximum delay between two retries")
+}
+
+// addArchiveFlags adds the flag setting an archive mode to a flag set.
+func addArchiveFlags(flags *flag.FlagSet, archiveMode *string) {
+	flags.StringVar(archiveMode, "archive", scrape.ARCHIVE_AUTO, "summarize the Wayback Machine copy of the pages: "+scrape.ARCHIVE_AUTO+" (when they are gone or paywalled), "+scrape.ARCHIVE_ALWAYS+" or "+scrape.ARCHIVE_NEVER)
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/brequet/report/pkg/export"
+)
+
+func runSemsearch(config Config, args []string) error {
+	flags := flag.NewFlagSet("semsearch", flag.ExitOnError)
+	limit := flags.Int("n", 5, "number of results to display")
+	permissions := addPermissionFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report semsearch [-n 5] <output-folder> <query>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 2)
+	if len(args) != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder and a query")
+	}
+	outputFolder := args[0]
+	query := args[1]
+
+	ctx, stop := interruptContext()
+	defer stop()
+	embeddingConfig, reports, store, err := export.LoadEmbeddedReports(ctx, outputFolder, *permissions)
+	if err != nil {
+		return err
+	}
+
+	results, err := export.SemanticSearch(ctx, embeddingConfig, store, reports, query)
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No report found")
+		return nil
+	}
+
+	for i, result := range results[:min(*limit, len(results))] {
+		fmt.Printf("%d. [%.3f] %s\n   %s\n   %s\n", i+1, result.Score, result.Report.Title, result.Report.Url, result.Report.Path)
+	}
+
+	return nil
+}
@@ -0,0 +1,701 @@
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brequet/report/internal/buildinfo"
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const (
+	DEFAULT_SERVER_ADDR = "localhost:8080"
+	// Maximum size of a request body, requests only hold an url and options.
+	SERVER_MAX_BODY_SIZE = 64 * 1024
+	// Time given to the requests in flight to end when the server stops
+	SERVER_SHUTDOWN_TIMEOUT = 30 * time.Second
+)
+
+type SummarizeRequest struct {
+	Url string `json:"url"`
+	// Message containing the url, such as a link shared to a chat, used when
+	// no url is given. Its first http or https url is summarized.
+	Text string `json:"text"`
+	// Write the report to the output folder, in the configured formats.
+	Write       bool   `json:"write"`
+	ContentType string `json:"content_type"`
+	// Write the report again if the article was already summarized.
+	Force bool `json:"force"`
+	// Summarize in the background, the response being a job to follow at
+	// /jobs/{id} and /jobs/{id}/events.
+	Async bool `json:"async"`
+}
+
+type SummarizeResponse struct {
+	// ID of the report, to get it back from /reports/{id} once written
+	Id          string `json:"id,omitempty"`
+	Url         string `json:"url"`
+	Status      string `json:"status"`
+	Title       string `json:"title,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Author      string `json:"author,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+	// Publication date of the article, as YYYY-MM-DD
+	Published string `json:"published,omitempty"`
+	// Wayback Machine snapshot the article was read from, if any
+	ArchiveUrl string `json:"archive_url,omitempty"`
+	// Url of the image representing the article
+	Image       string   `json:"image,omitempty"`
+	Summary     string   `json:"summary,omitempty"`
+	Keypoints   []string `json:"keypoints,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	OutputPaths []string `json:"output_paths,omitempty"`
+}
+
+// ReportResponse is a report of the output folder, read back from its file.
+type ReportResponse struct {
+	Id          string   `json:"id"`
+	Url         string   `json:"url"`
+	Title       string   `json:"title"`
+	ContentType string   `json:"content_type,omitempty"`
+	Date        string   `json:"date,omitempty"`
+	Summary     string   `json:"summary"`
+	Keypoints   []string `json:"keypoints"`
+	Tags        []string `json:"tags"`
+	Path        string   `json:"path"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// ServerInfo describes the server to the home automation platforms and other
+// clients discovering it.
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Authentication of the API: "none", "api_key", "oidc" or "api_key,oidc"
+	Auth      string         `json:"auth"`
+	Writes    bool           `json:"writes"`
+	Endpoints []EndpointInfo `json:"endpoints"`
+}
+
+type EndpointInfo struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Description string `json:"description"`
+}
+
+var serverEndpoints = []EndpointInfo{
+	{Method: "GET", Path: "/", Description: "describe the server"},
+	{Method: "GET", Path: "/health", Description: "check that the server is up"},
+	{Method: "POST", Path: "/summarize", Description: "summarize the page of the url, or of the first url of the text"},
+	{Method: "GET", Path: "/jobs/{id}", Description: "state and result of the async summary with this ID"},
+	{Method: "GET", Path: "/jobs/{id}/events", Description: "progress events of the async summary with this ID, as server-sent events"},
+	{Method: "GET", Path: "/reports/{id}", Description: "report of the output folder with this ID"},
+	{Method: "GET", Path: "/usage", Description: "usage and quotas of the API key"},
+	{Method: "GET", Path: "/admin/usage", Description: "usage and quotas of every API key and user, for the admin keys"},
+}
+
+type UsageResponse struct {
+	Key string `json:"key"`
+	// Usage by month, as YYYY-MM
+	Months          map[string]summarize.Usage `json:"months"`
+	MonthlyArticles int                        `json:"monthly_articles,omitempty"`
+	MonthlyTokens   int                        `json:"monthly_tokens,omitempty"`
+	RateLimit       int                        `json:"rate_limit,omitempty"`
+}
+
+// Server exposes the creation of reports as a JSON API.
+type Server struct {
+	client  *report.Client
+	apiKeys []ApiKeyConfig
+	// OpenID provider whose ID tokens are accepted, nil when not configured
+	oidc    *OidcVerifier
+	usage   *UsageStore
+	limiter *RateLimiter
+	jobs    *JobStore
+	// Context of the async jobs, canceled when the server stops
+	ctx context.Context
+
+	mu sync.Mutex
+	// Clients of the keys with their own output folder, by folder
+	folderClients map[string]*report.Client
+}
+
+const (
+	// ANONYMOUS_KEY_NAME is the name the usage is recorded under when the
+	// server has no API keys.
+	ANONYMOUS_KEY_NAME = "anonymous"
+	// Prefix of the names the usage of the OpenID users is recorded under
+	OIDC_KEY_PREFIX = "oidc:"
+	// Placeholder of the user in the output folder of the OpenID users
+	OIDC_USER_PLACEHOLDER = "{user}"
+)
+
+type apiKeyContextKey struct{}
+
+func runServe(config Config, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", DEFAULT_SERVER_ADDR, "address to listen on, e.g. :8080 to listen on all interfaces")
+	noAuth := flags.Bool("no-auth", false, "serve the API without API keys on an address other than localhost, to anyone reaching it")
+	reportFlags := addReportFlags(flags, config)
+	flags.Usage = func() {
+		fmt.Println("Usage: report serve [-addr localhost:8080] [-no-auth] [options] [<output-folder>]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	// The urls come from the clients of the API, private networks are only
+	// reachable when explicitly allowed
+	blockPrivateNetworksSet := false
+	flags.Visit(func(f *flag.Flag) {
+		blockPrivateNetworksSet = blockPrivateNetworksSet || f.Name == "block-private-networks"
+	})
+	if !blockPrivateNetworksSet {
+		reportFlags.scraper.Policy.BlockPrivateNetworks = true
+	}
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) > 1 {
+		flags.Usage()
+		return fmt.Errorf("expected at most an output folder")
+	}
+	var outputFolder string
+	if len(args) == 1 {
+		outputFolder = args[0]
+	}
+
+	options, err := reportFlags.options(outputFolder)
+	if err != nil {
+		return err
+	}
+	defer options.Events.Close()
+	options.NonInteractive = true
+	client, err := report.New(report.WithReportOptions(options))
+	if err != nil {
+		return err
+	}
+
+	usage, err := loadUsageStore()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	server := &Server{client: client, apiKeys: config.Server.ApiKeys, usage: usage, limiter: newRateLimiter(), jobs: newJobStore(), ctx: ctx, folderClients: map[string]*report.Client{}}
+	if config.Server.Oidc.Issuer != "" {
+		server.oidc = newOidcVerifier(config.Server.Oidc)
+	}
+	if !server.hasAuth() {
+		// An open API spends the tokens of the provider for anyone reaching it
+		if !*noAuth && !isLoopbackAddr(*addr) {
+			return fmt.Errorf("refusing to serve the API without API keys on %s, configure API keys or an OpenID provider in the config file, or pass -no-auth", *addr)
+		}
+		slog.Warn("no API keys or OpenID provider configured, the API is open to anyone reaching the server")
+	}
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           server.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Closed once the requests in flight are done, ListenAndServe returning
+	// as soon as the shutdown starts
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), SERVER_SHUTDOWN_TIMEOUT)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("requests still running at shutdown", "err", err)
+		}
+	}()
+
+	slog.Info("Listening", "addr", *addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serving: %w", err)
+	}
+	<-shutdownDone
+	// The jobs end with the interruption of their context
+	server.jobs.wait()
+	return nil
+}
+
+// isLoopbackAddr tells whether a listening address only accepts the
+// connections of the machine itself: localhost or a loopback IP. An address
+// without host listens on all interfaces.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsLoopback()
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	// Discovery and health checks need no API key, they disclose nothing
+	mux.HandleFunc("GET /{$}", s.handleInfo)
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.Handle("POST /summarize", s.withApiKey(http.HandlerFunc(s.handleSummarize)))
+	mux.Handle("GET /jobs/{id}", s.withApiKey(http.HandlerFunc(s.handleJob)))
+	mux.Handle("GET /jobs/{id}/events", s.withApiKey(http.HandlerFunc(s.handleJobEvents)))
+	mux.Handle("GET /reports/{id}", s.withApiKey(http.HandlerFunc(s.handleReport)))
+	mux.Handle("GET /usage", s.withApiKey(http.HandlerFunc(s.handleUsage)))
+	mux.Handle("GET /admin/usage", s.withApiKey(withAdmin(http.HandlerFunc(s.handleAdminUsage))))
+	mux.HandleFunc("OPTIONS /", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return withCors(mux)
+}
+
+// hasAuth tells whether the requests are authenticated, with API keys or the
+// tokens of an OpenID provider.
+func (s *Server) hasAuth() bool {
+	return len(s.apiKeys) > 0 || s.oidc != nil
+}
+
+// withApiKey authenticates the requests with the API key given as a bearer
+// token or in the X-API-Key header, or the ID token of the OpenID provider
+// given as a bearer token, when the server has them. The requests of a key
+// beyond its rate limit are refused.
+func (s *Server) withApiKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Without authentication, anyone reaching the server is its admin
+		apiKey := ApiKeyConfig{Name: ANONYMOUS_KEY_NAME, Admin: true}
+		if s.hasAuth() {
+			key := r.Header.Get("X-API-Key")
+			if bearer, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+				key = bearer
+			}
+			found := false
+			for _, candidate := range s.apiKeys {
+				if subtle.ConstantTimeCompare([]byte(candidate.Key), []byte(key)) == 1 {
+					apiKey, found = candidate, true
+				}
+			}
+			if !found && s.oidc != nil && isJwt(key) {
+				user, err := s.oidc.verify(r.Context(), key, time.Now())
+				if err != nil {
+					slog.Debug("OpenID token refused", "err", err)
+				} else {
+					apiKey, found = s.oidcUser(user), true
+				}
+			}
+			if !found {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeJsonError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid API key"))
+				return
+			}
+		}
+		if allowed, retryAfter := s.limiter.allow(apiKey.Name, apiKey.RateLimit, time.Now()); !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			writeJsonError(w, http.StatusTooManyRequests, fmt.Errorf("rate limit of %d requests per minute exceeded", apiKey.RateLimit))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, apiKey)))
+	})
+}
+
+// oidcUser returns the key of a user of the OpenID provider, with the limits
+// of the users.
+func (s *Server) oidcUser(user string) ApiKeyConfig {
+	config := s.oidc.config
+	apiKey := ApiKeyConfig{
+		Name:            OIDC_KEY_PREFIX + user,
+		MonthlyArticles: config.MonthlyArticles,
+		MonthlyTokens:   config.MonthlyTokens,
+		RateLimit:       config.RateLimit,
+		Admin:           slices.Contains(config.Admins, user),
+	}
+	if config.OutputFolder != "" {
+		// The user is not trusted as a path, and users whose slugs are the
+		// same must not share a folder
+		hash := sha256.Sum256([]byte(user))
+		folder := export.Slugify(user) + "-" + hex.EncodeToString(hash[:4])
+		apiKey.OutputFolder = strings.ReplaceAll(config.OutputFolder, OIDC_USER_PLACEHOLDER, folder)
+	}
+	return apiKey
+}
+
+// keyClient returns the client of the requests of a key: the one of the
+// server, with the output folder of the key, its index and its archive when
+// it has its own.
+func (s *Server) keyClient(apiKey ApiKeyConfig) (*report.Client, error) {
+	serverOptions := s.client.Options()
+	folder := apiKey.OutputFolder
+	if folder == "" || folder == serverOptions.OutputFolder {
+		return s.client, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if client, ok := s.folderClients[folder]; ok {
+		return client, nil
+	}
+
+	index, err := export.LoadArticleIndex(folder, serverOptions.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	var archiveChecker *export.ArchiveChecker
+	if serverOptions.ArchiveChecker != nil {
+		archiveChecker, err = export.NewArchiveChecker(s.ctx, folder, serverOptions.Permissions)
+		if err != nil {
+			return nil, err
+		}
+	}
+	client := s.client.Derive(func(options *report.ReportOptions) {
+		options.OutputFolder = folder
+		options.Index = index
+		options.ArchiveChecker = archiveChecker
+	})
+	s.folderClients[folder] = client
+	return client, nil
+}
+
+// withAdmin refuses the requests of the keys that are not admin keys.
+func withAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !requestApiKey(r).Admin {
+			writeJsonError(w, http.StatusForbidden, fmt.Errorf("admin key required"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func requestApiKey(r *http.Request) ApiKeyConfig {
+	apiKey, _ := r.Context().Value(apiKeyContextKey{}).(ApiKeyConfig)
+	return apiKey
+}
+
+// RateLimiter limits the requests of each key with a token bucket refilled
+// at the rate limit of the key, and as large, so that a key can make its
+// requests of a minute in a burst.
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newRateLimiter() *RateLimiter {
+	return &RateLimiter{buckets: map[string]*rateBucket{}}
+}
+
+// allow takes a token from the bucket of a key, and otherwise returns how long
+// to wait for the next one. A limit of 0 allows everything.
+func (l *RateLimiter) allow(keyName string, perMinute int, now time.Time) (bool, time.Duration) {
+	if perMinute <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[keyName]
+	if !ok {
+		bucket = &rateBucket{tokens: float64(perMinute), updated: now}
+		l.buckets[keyName] = bucket
+	}
+	rate := float64(perMinute) / time.Minute.Seconds()
+	bucket.tokens = min(float64(perMinute), bucket.tokens+now.Sub(bucket.updated).Seconds()*rate)
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	var methods []string
+	if len(s.apiKeys) > 0 {
+		methods = append(methods, "api_key")
+	}
+	if s.oidc != nil {
+		methods = append(methods, "oidc")
+	}
+	auth := "none"
+	if len(methods) > 0 {
+		auth = strings.Join(methods, ",")
+	}
+	writeJson(w, http.StatusOK, ServerInfo{
+		Name:      "report",
+		Version:   buildinfo.GetVersion(),
+		Auth:      auth,
+		Writes:    s.writes(),
+		Endpoints: serverEndpoints,
+	})
+}
+
+// writes tells whether the server, or some of its keys, has an output folder
+// to write the reports to.
+func (s *Server) writes() bool {
+	if s.client.Options().OutputFolder != "" || (s.oidc != nil && s.oidc.config.OutputFolder != "") {
+		return true
+	}
+	return slices.ContainsFunc(s.apiKeys, func(apiKey ApiKeyConfig) bool { return apiKey.OutputFolder != "" })
+}
+
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	apiKey := requestApiKey(r)
+	writeJson(w, http.StatusOK, UsageResponse{
+		Key:             apiKey.Name,
+		Months:          s.usage.keyUsage(apiKey.Name),
+		MonthlyArticles: apiKey.MonthlyArticles,
+		MonthlyTokens:   apiKey.MonthlyTokens,
+		RateLimit:       apiKey.RateLimit,
+	})
+}
+
+// handleAdminUsage returns the usage of the API keys, of the OpenID users and
+// of the keys no longer configured, with their limits.
+func (s *Server) handleAdminUsage(w http.ResponseWriter, r *http.Request) {
+	keys := map[string]ApiKeyConfig{}
+	for _, apiKey := range s.apiKeys {
+		keys[apiKey.Name] = apiKey
+	}
+	names := s.usage.keyNames()
+	for _, apiKey := range s.apiKeys {
+		if !slices.Contains(names, apiKey.Name) {
+			names = append(names, apiKey.Name)
+		}
+	}
+	sort.Strings(names)
+
+	responses := []UsageResponse{}
+	for _, name := range names {
+		apiKey, ok := keys[name]
+		if user, found := strings.CutPrefix(name, OIDC_KEY_PREFIX); !ok && found && s.oidc != nil {
+			apiKey = s.oidcUser(user)
+		}
+		responses = append(responses, UsageResponse{
+			Key:             name,
+			Months:          s.usage.keyUsage(name),
+			MonthlyArticles: apiKey.MonthlyArticles,
+			MonthlyTokens:   apiKey.MonthlyTokens,
+			RateLimit:       apiKey.RateLimit,
+		})
+	}
+	writeJson(w, http.StatusOK, responses)
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	client, err := s.keyClient(requestApiKey(r))
+	if err != nil {
+		writeJsonError(w, http.StatusInternalServerError, err)
+		return
+	}
+	outputFolder := client.Options().OutputFolder
+	if outputFolder == "" {
+		writeJsonError(w, http.StatusNotFound, fmt.Errorf("the server has no output folder"))
+		return
+	}
+	report, err := export.FindReportById(outputFolder, r.PathValue("id"))
+	if err != nil {
+		writeJsonError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJson(w, http.StatusOK, ReportResponse{
+		Id:          report.Id,
+		Url:         report.Url,
+		Title:       report.Title,
+		ContentType: report.ContentType,
+		Date:        report.DateCreated,
+		Summary:     report.Summary,
+		Keypoints:   report.Keypoints,
+		Tags:        report.Tags,
+		Path:        export.ReportFileName(report),
+	})
+}
+
+// withCors allows the API to be called from any page, for bookmarklets.
+func withCors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleSummarize(w http.ResponseWriter, r *http.Request) {
+	var request SummarizeRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, SERVER_MAX_BODY_SIZE))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeJsonError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	if strings.TrimSpace(request.Url) == "" {
+		request.Url = textUrlRegex.FindString(request.Text)
+	}
+	if strings.TrimSpace(request.Url) == "" {
+		writeJsonError(w, http.StatusBadRequest, fmt.Errorf("invalid request: no url"))
+		return
+	}
+	if request.ContentType != "" && !scrape.IsKnownContentType(request.ContentType) {
+		writeJsonError(w, http.StatusBadRequest, fmt.Errorf("unknown content type '%s', expected one of %s", request.ContentType, strings.Join(scrape.ContentTypes, ", ")))
+		return
+	}
+
+	apiKey := requestApiKey(r)
+	client, err := s.keyClient(apiKey)
+	if err != nil {
+		writeJsonError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if request.Write && client.Options().OutputFolder == "" {
+		writeJsonError(w, http.StatusBadRequest, fmt.Errorf("cannot write the report, the server has no output folder"))
+		return
+	}
+	client = client.Derive(func(options *report.ReportOptions) {
+		if request.ContentType != "" {
+			options.ContentType = request.ContentType
+		}
+		if request.Write {
+			options.Force = options.Force || request.Force
+		} else {
+			options.Formats = nil
+			options.ReferencesFile = ""
+			options.Index = nil
+		}
+	})
+
+	// The article is counted in the quota before its summary starts
+	recordUsage, err := s.usage.reserveArticle(apiKey, time.Now())
+	if err != nil {
+		writeJsonError(w, http.StatusTooManyRequests, err)
+		return
+	}
+
+	if request.Async {
+		job := s.jobs.start(s.ctx, apiKey.Name, request.Url, func(ctx context.Context) (int, any) {
+			return s.summarize(ctx, request.Url, client, apiKey, recordUsage)
+		})
+		response, _, _ := job.state()
+		w.Header().Set("Location", "/jobs/"+response.Id)
+		writeJson(w, http.StatusAccepted, response)
+		return
+	}
+	statusCode, response := s.summarize(r.Context(), request.Url, client, apiKey, recordUsage)
+	writeJson(w, statusCode, response)
+}
+
+// summarize creates the report of an url for a key, and returns the status
+// and the body of the response: a SummarizeResponse, or an ErrorResponse. The
+// usage of the report is given to recordUsage, even if it panics.
+func (s *Server) summarize(ctx context.Context, articleUrl string, client *report.Client, apiKey ApiKeyConfig, recordUsage func(usage summarize.Usage) error) (int, any) {
+	ctx, recorder := summarize.WithUsageRecorder(ctx)
+	var result report.ArticleResult
+	defer func() {
+		usage := recorder.Usage()
+		if result.Status == report.RESULT_CREATED {
+			usage.Articles = 1
+		}
+		if err := recordUsage(usage); err != nil {
+			slog.Warn("could not record usage", "err", err)
+		}
+	}()
+	result = client.Create(ctx, articleUrl)
+
+	if result.Err != nil {
+		slog.Error("article failed", "url", articleUrl, "key", apiKey.Name, "err", result.Err)
+		return errorStatusCode(result.Err), ErrorResponse{Error: result.Err.Error()}
+	}
+
+	response := SummarizeResponse{
+		Url:         result.Url,
+		Status:      result.Status,
+		Title:       result.Article.Title,
+		ContentType: result.Article.ContentType,
+		Author:      result.Article.Author(),
+		SiteName:    result.Article.SiteName,
+		ArchiveUrl:  result.Article.ArchiveUrl,
+		Image:       result.Article.Image,
+		OutputPaths: result.OutputPaths,
+	}
+	// A report not written cannot be found by its ID
+	if len(result.OutputPaths) > 0 {
+		response.Id = result.Article.Id
+	}
+	if !result.Article.Published.IsZero() {
+		response.Published = result.Article.Published.Format(scrape.DATE_FORMAT)
+	}
+	if summary := result.Article.Summary; summary != nil {
+		response.Summary = summary.Summary
+		response.Keypoints = summary.Keypoints
+		response.Tags = summary.Tags
+	}
+
+	if result.Status == report.RESULT_SKIPPED {
+		return http.StatusUnprocessableEntity, response
+	}
+	return http.StatusOK, response
+}
+
+// textUrlRegex matches the urls of a message, without the punctuation ending
+// the sentence or closing the parenthesis around them.
+var textUrlRegex = regexp.MustCompile(`https?://[^\s<>"']*[^\s<>"'.,;:!?)\]]`)
+
+// errorStatusCode maps the error of a report to the status of the response.
+func errorStatusCode(err error) int {
+	switch {
+	case errors.Is(err, scrape.ErrBlockedUrl):
+		return http.StatusBadRequest
+	case errors.Is(err, scrape.ErrPaywalled), errors.Is(err, scrape.ErrNoTitle), errors.Is(err, scrape.ErrUnsupportedContent):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, scrape.ErrRateLimited):
+		return http.StatusServiceUnavailable
+	}
+
+	var stageErr *report.StageError
+	if errors.As(err, &stageErr) && (stageErr.Stage == report.STAGE_SCRAPE || stageErr.Stage == report.STAGE_SUMMARIZE) {
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJson(w http.ResponseWriter, statusCode int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeJsonError(w http.ResponseWriter, statusCode int, err error) {
+	writeJson(w, statusCode, ErrorResponse{Error: err.Error()})
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brequet/report/internal/paths"
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+func newTestServer(t *testing.T, apiKeys ...ApiKeyConfig) *Server {
+	t.Helper()
+	t.Setenv(paths.STATE_DIR_ENV, t.TempDir())
+	usage, err := loadUsageStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := report.New(report.WithProvider(failingProvider{}), report.WithCache(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Server{client: client, apiKeys: apiKeys, usage: usage, limiter: newRateLimiter(), jobs: newJobStore(), folderClients: map[string]*report.Client{}}
+}
+
+// failingProvider fails every call, the tests of the server stopping before
+// the summaries.
+type failingProvider struct{}
+
+func (failingProvider) Name() string {
+	return "failing"
+}
+
+func (failingProvider) Complete(ctx context.Context, messages []summarize.ChatMessage) (string, error) {
+	return "", errors.New("no LLM in the tests")
+}
+
+func TestServerAuth(t *testing.T) {
+	server := newTestServer(t,
+		ApiKeyConfig{Name: "alice", Key: "alice-key"},
+		ApiKeyConfig{Name: "bob", Key: "bob-key", RateLimit: 1},
+		ApiKeyConfig{Name: "root", Key: "root-key", Admin: true},
+	)
+	routes := server.routes()
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		want    int
+		wantKey string
+	}{
+		{"no key", "/usage", nil, http.StatusUnauthorized, ""},
+		{"wrong key", "/usage", map[string]string{"X-API-Key": "nope"}, http.StatusUnauthorized, ""},
+		{"key header", "/usage", map[string]string{"X-API-Key": "alice-key"}, http.StatusOK, "alice"},
+		{"bearer token", "/usage", map[string]string{"Authorization": "Bearer alice-key"}, http.StatusOK, "alice"},
+		{"open endpoint", "/health", nil, http.StatusOK, ""},
+		{"admin endpoint", "/admin/usage", map[string]string{"X-API-Key": "alice-key"}, http.StatusForbidden, ""},
+		{"admin key", "/admin/usage", map[string]string{"X-API-Key": "root-key"}, http.StatusOK, ""},
+		{"within rate limit", "/usage", map[string]string{"X-API-Key": "bob-key"}, http.StatusOK, "bob"},
+		{"beyond rate limit", "/usage", map[string]string{"X-API-Key": "bob-key"}, http.StatusTooManyRequests, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", test.path, nil)
+			for name, value := range test.headers {
+				request.Header.Set(name, value)
+			}
+			recorder := httptest.NewRecorder()
+			routes.ServeHTTP(recorder, request)
+			if recorder.Code != test.want {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.want, recorder.Body)
+			}
+			if test.wantKey != "" {
+				var usage UsageResponse
+				if err := json.Unmarshal(recorder.Body.Bytes(), &usage); err != nil || usage.Key != test.wantKey {
+					t.Errorf("usage of key %q, %v, want %q", usage.Key, err, test.wantKey)
+				}
+			}
+		})
+	}
+}
+
+func TestReserveArticle(t *testing.T) {
+	now := time.Date(2024, 6, 2, 12, 0, 0, 0, time.UTC)
+	apiKey := ApiKeyConfig{Name: "alice", MonthlyArticles: 2, MonthlyTokens: 1000}
+
+	t.Run("concurrent requests", func(t *testing.T) {
+		store := newTestServer(t).usage
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		var records []func(summarize.Usage) error
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if record, err := store.reserveArticle(apiKey, now); err == nil {
+					mu.Lock()
+					records = append(records, record)
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if len(records) != apiKey.MonthlyArticles {
+			t.Fatalf("reserved %d articles, want %d", len(records), apiKey.MonthlyArticles)
+		}
+		for _, record := range records {
+			if err := record(summarize.Usage{Articles: 1}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := store.reserveArticle(apiKey, now); err == nil {
+			t.Errorf("reserved an article beyond the quota")
+		}
+	})
+
+	t.Run("failed articles given back", func(t *testing.T) {
+		store := newTestServer(t).usage
+		for range 5 {
+			record, err := store.reserveArticle(apiKey, now)
+			if err != nil {
+				t.Fatalf("reserveArticle: %v", err)
+			}
+			if err := record(summarize.Usage{InputTokens: 10}); err != nil {
+				t.Fatal(err)
+			}
+			// Recording twice does not give back another article
+			if err := record(summarize.Usage{InputTokens: 10}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if usage := store.keyUsage(apiKey.Name)[report.UsageMonth(now)]; usage != (summarize.Usage{InputTokens: 50}) {
+			t.Errorf("usage = %+v, want the tokens of the failed articles", usage)
+		}
+	})
+
+	t.Run("tokens exhausted", func(t *testing.T) {
+		store := newTestServer(t).usage
+		record, err := store.reserveArticle(apiKey, now)
+		if err != nil {
+			t.Fatalf("reserveArticle: %v", err)
+		}
+		if err := record(summarize.Usage{Articles: 1, InputTokens: 900, OutputTokens: 100}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.reserveArticle(apiKey, now); err == nil || !strings.Contains(err.Error(), "tokens") {
+			t.Errorf("reserveArticle = %v, want the token quota exhausted", err)
+		}
+		// The quota is monthly
+		if _, err := store.reserveArticle(apiKey, now.AddDate(0, 1, 0)); err != nil {
+			t.Errorf("reserveArticle next month: %v", err)
+		}
+	})
+}
+
+func TestSummarizeQuota(t *testing.T) {
+	apiKey := ApiKeyConfig{Name: "alice", Key: "alice-key", MonthlyArticles: 1}
+	server := newTestServer(t, apiKey)
+	// An article being summarized holds the whole quota
+	if _, err := server.usage.reserveArticle(apiKey, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest("POST", "/summarize", strings.NewReader(`{"url": "https://example.com/"}`))
+	request.Header.Set("X-API-Key", apiKey.Key)
+	recorder := httptest.NewRecorder()
+	server.routes().ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusTooManyRequests || !strings.Contains(recorder.Body.String(), "quota") {
+		t.Errorf("status = %d %s, want the quota exhausted", recorder.Code, recorder.Body)
+	}
+}
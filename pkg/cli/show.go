@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/brequet/report/pkg/export"
+)
+
+func runShow(config Config, args []string) error {
+	flags := flag.NewFlagSet("show", flag.ExitOnError)
+	pathOnly := flags.Bool("path", false, "print the path of the report instead of its content")
+	flags.Usage = func() {
+		fmt.Println("Usage: report show [-path] <output-folder> <id>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 2)
+	if len(args) != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder and a report ID")
+	}
+
+	report, err := export.FindReportById(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if *pathOnly {
+		fmt.Println(report.Path)
+		return nil
+	}
+
+	content, err := os.ReadFile(report.Path)
+	if err != nil {
+		return fmt.Errorf("reading report: %w", err)
+	}
+	fmt.Print(string(content))
+	return nil
+}
@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"flag"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/scrape"
+)
+
+const (
+	SITE_PAGE_FILE_NAME = "index.html"
+	// Folder of the icons of the sites, in the output folder
+	ICONS_FOLDER_NAME = "icons"
+	// Folder of the icons in the cache directory
+	ICON_CACHE_FOLDER = "icons"
+	// Age after which the icon of a site is fetched again, to follow its
+	// changes and give another chance to the sites without one
+	ICON_CACHE_AGE = 30 * 24 * time.Hour
+)
+
+//go:embed site-template.html
+var siteTemplate string
+
+// SitePage is the data of the index page of the output folder.
+type SitePage struct {
+	Title     string
+	Generated string
+	Reports   []SiteReport
+}
+
+// SiteReport is a report of the index page, its paths being relative to the
+// page.
+type SiteReport struct {
+	Title   string
+	Url     string
+	Site    string
+	Date    string
+	Summary template.HTML
+	Tags    []string
+	Dead    bool
+	Path    string
+	// Icon of the site of the article, empty if it has none
+	Icon string
+}
+
+// CachedIcon is the icon of a site in the cache, without body when the site
+// has none.
+type CachedIcon struct {
+	Host      string    `json:"host"`
+	Fetched   time.Time `json:"fetched"`
+	Extension string    `json:"extension,omitempty"`
+	Body      []byte    `json:"body,omitempty"`
+}
+
+func runSite(config Config, args []string) error {
+	flags := flag.NewFlagSet("site", flag.ExitOnError)
+	title := flags.String("title", "My reading", "title of the page")
+	noIcons := flags.Bool("no-icons", false, "do not fetch the icons of the sites")
+	output := flags.String("o", "", "page file, defaults to index.html in the output folder")
+	scraper := scrape.New()
+	addFetchPolicyFlags(flags, &scraper.Policy)
+	addCacheFlags(flags, &scraper.Cache)
+	permissions := addPermissionFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report site [-title \"My reading\"] [-no-icons] [-o index.html] <output-folder>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder")
+	}
+	outputFolder := args[0]
+
+	reports, err := export.LoadReports(outputFolder)
+	if err != nil {
+		return err
+	}
+	reports = latestReports(reports, len(reports))
+
+	outputPath := *output
+	if outputPath == "" {
+		outputPath = filepath.Join(outputFolder, SITE_PAGE_FILE_NAME)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	// The icon of each site, by host, relative to the output folder
+	icons := map[string]string{}
+	if !*noIcons {
+		for _, report := range reports {
+			host := reportHost(report)
+			if _, ok := icons[host]; ok || host == "" {
+				continue
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			icons[host] = siteIcon(ctx, scraper, outputFolder, *permissions, host)
+		}
+	}
+
+	page := SitePage{Title: *title, Generated: time.Now().Format(scrape.DATE_FORMAT)}
+	for _, report := range reports {
+		siteReport := SiteReport{
+			Title:   report.Title,
+			Url:     report.Url,
+			Site:    export.SiteNameFromUrl(report.Url),
+			Date:    report.DateCreated,
+			Summary: template.HTML(export.ReportToHtml(report.Summary, nil)),
+			Tags:    report.Tags,
+			Dead:    report.LinkStatus == LINK_STATUS_DEAD,
+			Path:    pageLink(outputPath, report.Path),
+		}
+		if icon := icons[reportHost(report)]; icon != "" {
+			siteReport.Icon = pageLink(outputPath, filepath.Join(outputFolder, icon))
+		}
+		page.Reports = append(page.Reports, siteReport)
+	}
+
+	content, err := renderSitePage(page)
+	if err != nil {
+		return err
+	}
+	if err := permissions.WriteFile(outputPath, content); err != nil {
+		return fmt.Errorf("writing page: %w", err)
+	}
+
+	fmt.Printf("Page created successfully: %s\n", outputPath)
+	return nil
+}
+
+func renderSitePage(page SitePage) ([]byte, error) {
+	tmpl, err := template.New("site").Parse(siteTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing page template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, page); err != nil {
+		return nil, fmt.Errorf("rendering page: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// pageLink returns the link from the page to a file, relative to the folder
+// of the page and escaped.
+func pageLink(pagePath, path string) string {
+	from, errFrom := filepath.Abs(filepath.Dir(pagePath))
+	to, errTo := filepath.Abs(path)
+	relative, err := filepath.Rel(from, to)
+	if errFrom != nil || errTo != nil || err != nil {
+		relative = path
+	}
+	segments := strings.Split(filepath.ToSlash(relative), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func reportHost(report export.Report) string {
+	parsed, err := url.Parse(report.Url)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// siteIcon returns the path of the icon of a site, relative to the output
+// folder, saving it to the icons folder from the cache, or from the site when
+// not cached or older than ICON_CACHE_AGE. It returns an empty path for the
+// sites without icon, and when the icon cannot be saved. The icons are
+// fetched by the scraper, and cached in its cache.
+func siteIcon(ctx context.Context, scraper *scrape.Scraper, outputFolder string, permissions export.FilePermissions, host string) string {
+	var icon CachedIcon
+	if !scraper.Cache.Read(ICON_CACHE_FOLDER, scrape.CacheKey(host), &icon) || time.Since(icon.Fetched) > ICON_CACHE_AGE {
+		icon = CachedIcon{Host: host, Fetched: time.Now()}
+		data, extension, err := scraper.FetchSiteIcon(ctx, host)
+		if err != nil {
+			slog.Debug("No icon for the site", "host", host, "err", err)
+		} else {
+			icon.Body, icon.Extension = data, extension
+		}
+		// An interrupted fetch tells nothing about the site
+		if ctx.Err() == nil {
+			scraper.Cache.Write(ICON_CACHE_FOLDER, scrape.CacheKey(host), icon)
+		}
+	}
+	if len(icon.Body) == 0 {
+		return ""
+	}
+
+	iconsFolder := filepath.Join(outputFolder, ICONS_FOLDER_NAME)
+	if err := permissions.Mkdir(iconsFolder); err != nil {
+		slog.Warn("could not create the icons folder", "path", iconsFolder, "err", err)
+		return ""
+	}
+	fileName := host + icon.Extension
+	if err := permissions.WriteFile(filepath.Join(iconsFolder, fileName), icon.Body); err != nil {
+		slog.Warn("could not save the icon", "host", host, "err", err)
+		return ""
+	}
+	// Slashes, like the other paths of the output folder
+	return ICONS_FOLDER_NAME + "/" + fileName
+}
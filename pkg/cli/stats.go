@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+func runStats(config Config, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	months := flags.Int("months", 12, "number of months listed, 0 for all")
+	flags.Usage = func() {
+		fmt.Println("Usage: report stats [-months 12]")
+		fmt.Println("Print the articles summarized, the tokens consumed and their estimated cost, in total, by month and by model.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	stats, err := report.LoadUsageStats()
+	if err != nil {
+		return err
+	}
+	if stats.Total == (summarize.Usage{}) {
+		fmt.Println("No usage recorded yet")
+		return nil
+	}
+
+	fmt.Printf("Total: %d articles, %s\n", stats.Total.Articles, summarize.FormatUsage(stats.Total))
+
+	monthNames := make([]string, 0, len(stats.Months))
+	for month := range stats.Months {
+		monthNames = append(monthNames, month)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(monthNames)))
+	if *months > 0 && len(monthNames) > *months {
+		monthNames = monthNames[:*months]
+	}
+	fmt.Println("\nBy month:")
+	for _, month := range monthNames {
+		usage := stats.Months[month]
+		fmt.Printf("  %s  %5d articles  %10d input  %9d output  %s\n", month, usage.Articles, usage.InputTokens, usage.OutputTokens, summarize.FormatCost(usage.Cost))
+	}
+
+	modelNames := make([]string, 0, len(stats.Models))
+	for model := range stats.Models {
+		modelNames = append(modelNames, model)
+	}
+	sort.Slice(modelNames, func(i, j int) bool {
+		return stats.Models[modelNames[i]].InputTokens+stats.Models[modelNames[i]].OutputTokens > stats.Models[modelNames[j]].InputTokens+stats.Models[modelNames[j]].OutputTokens
+	})
+	fmt.Println("\nBy model:")
+	for _, model := range modelNames {
+		usage := stats.Models[model]
+		cost := summarize.FormatCost(usage.Cost)
+		provider, name, _ := strings.Cut(model, "/")
+		if _, ok := summarize.LookupModelPrice(provider, name, config.Providers[provider]); !ok {
+			cost = "unknown price"
+		}
+		fmt.Printf("  %-40s %10d input  %9d output  %s\n", model, usage.InputTokens, usage.OutputTokens, cost)
+	}
+
+	fmt.Printf("\nCosts are estimated from the prices of the models, set input_price and output_price (USD per million tokens) for a provider in the config file to change them. Stats file: %s\n", stats.Path)
+	return nil
+}
@@ -0,0 +1,338 @@
+package cli
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const (
+	THREADS_FILE_NAME = "threads.json"
+	THREADS_FOLDER    = "threads"
+	// Cosine similarity of the summaries above which a report follows a story
+	DEFAULT_THREAD_SIMILARITY = 0.75
+	// Reports of a story a new report is compared with, the latest ones, as a
+	// story drifts from its first articles
+	THREAD_RECENT_REPORTS = 3
+	// Stories with fewer reports get no thread
+	DEFAULT_THREAD_MIN_REPORTS = 3
+)
+
+//go:embed thread-prompt.md
+var threadPrompt string
+
+// Thread is an ongoing story followed through several reports, with its
+// timeline written by the LLM.
+type Thread struct {
+	Title    string        `json:"title"`
+	Summary  string        `json:"summary"`
+	Timeline []ThreadEvent `json:"timeline"`
+	// Reports of the story, from the oldest to the newest
+	Reports []export.Report `json:"-"`
+}
+
+// ThreadEvent is what a report adds to a story, Report being its number in
+// the reports of the thread, from 1.
+type ThreadEvent struct {
+	Report      int    `json:"report"`
+	Development string `json:"development"`
+}
+
+// StoredThread is a thread as its page was last written, so that only the
+// threads whose reports changed are written again.
+type StoredThread struct {
+	// Hash of the file names and summaries of the reports of the thread
+	Hash   string `json:"hash"`
+	Thread Thread `json:"thread"`
+	// Path of the page, relative to the output folder
+	Page string `json:"page"`
+}
+
+// ThreadStore holds the threads of an output folder, keyed by the file name
+// of the first report of their story.
+type ThreadStore struct {
+	Threads map[string]StoredThread `json:"threads"`
+}
+
+func runThreads(config Config, args []string) error {
+	flags := flag.NewFlagSet("threads", flag.ExitOnError)
+	similarity := flags.Float64("similarity", DEFAULT_THREAD_SIMILARITY, "similarity of the summaries, from 0 to 1, above which a report follows the story of earlier reports")
+	minReports := flags.Int("min-reports", DEFAULT_THREAD_MIN_REPORTS, "minimum number of reports of a story to write its thread")
+	folder := flags.String("folder", THREADS_FOLDER, "folder, relative to the output folder, receiving the thread pages")
+	force := flags.Bool("force", false, "write the timelines of every thread again, even those whose reports did not change")
+	providerFlags := addProviderFlags(flags, config)
+	permissions := addPermissionFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report threads [-similarity 0.75] [-min-reports 3] [-folder threads] [-force] <output-folder>")
+		fmt.Println("Group the reports about the same ongoing story into threads, and keep a timeline page of each story up to date.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder")
+	}
+	outputFolder := args[0]
+	if *similarity <= 0 || *similarity > 1 {
+		return fmt.Errorf("invalid similarity %g, expected a value above 0 and up to 1", *similarity)
+	}
+	if *minReports < 2 {
+		return fmt.Errorf("invalid minimum number of reports %d, expected at least 2", *minReports)
+	}
+
+	provider, err := providerFlags.provider()
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	_, reports, embeddings, err := export.LoadEmbeddedReports(ctx, outputFolder, *permissions)
+	if err != nil {
+		return err
+	}
+	store, err := loadThreadStore(outputFolder)
+	if err != nil {
+		return err
+	}
+
+	var threads []StoredThread
+	titles := map[string]int{}
+	previous := store.Threads
+	store.Threads = map[string]StoredThread{}
+	for _, reports := range groupThreads(reports, embeddings, *similarity) {
+		if len(reports) < *minReports {
+			continue
+		}
+		key := export.ReportFileName(reports[0])
+		thread, written := previous[key], false
+		if thread.Hash != threadHash(reports) || *force || !fileExists(filepath.Join(outputFolder, thread.Page)) {
+			thread.Thread, err = writeThreadTimeline(ctx, provider, reports)
+			if err != nil {
+				return fmt.Errorf("writing the timeline of the story of '%s': %w", reports[0].Title, err)
+			}
+			thread.Hash, written = threadHash(reports), true
+		}
+		thread.Thread.Reports = reports
+
+		// Stories sharing a name are numbered, as the name is the file name
+		// of the page
+		title := thread.Thread.Title
+		if !export.IsValidWindowsFilename(title) {
+			title = fmt.Sprintf("Story %d", len(threads)+1)
+		}
+		titles[strings.ToLower(title)]++
+		if count := titles[strings.ToLower(title)]; count > 1 {
+			title = fmt.Sprintf("%s %d", title, count)
+		}
+		page := filepath.ToSlash(filepath.Join(*folder, title+".md"))
+		if written || page != thread.Page {
+			if err := writeThreadPage(outputFolder, *permissions, page, thread.Thread); err != nil {
+				return err
+			}
+			removeStalePage(outputFolder, thread.Page, page)
+		}
+		thread.Page = page
+		store.Threads[key] = thread
+		threads = append(threads, thread)
+	}
+	// The stories that no longer have enough reports lose their page
+	for key, thread := range previous {
+		if _, ok := store.Threads[key]; !ok {
+			removeStalePage(outputFolder, thread.Page, "")
+		}
+	}
+	if err := store.save(outputFolder, *permissions); err != nil {
+		return err
+	}
+
+	if len(threads) == 0 {
+		fmt.Println("No story followed by enough reports")
+		return nil
+	}
+	for _, thread := range threads {
+		fmt.Printf("%s (%d reports)\n   %s\n", thread.Thread.Title, len(thread.Thread.Reports), filepath.Join(outputFolder, thread.Page))
+	}
+	return nil
+}
+
+// groupThreads groups the reports into stories, from the oldest report to the
+// newest: a report follows the story whose latest reports it is the most
+// similar to, if similar enough, else starts a story. The reports without an
+// embedding are left out.
+func groupThreads(reports []export.Report, store *export.EmbeddingStore, similarity float64) [][]export.Report {
+	sorted := make([]export.Report, len(reports))
+	copy(sorted, reports)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return reportDate(sorted[i]) < reportDate(sorted[j])
+	})
+
+	var threads [][]export.Report
+	for _, report := range sorted {
+		vector := store.Entries[export.ReportFileName(report)].Vector
+		if len(vector) == 0 {
+			continue
+		}
+		best, bestSimilarity := -1, similarity
+		for i, thread := range threads {
+			for _, member := range thread[max(0, len(thread)-THREAD_RECENT_REPORTS):] {
+				if score := export.CosineSimilarity(vector, store.Entries[export.ReportFileName(member)].Vector); score >= bestSimilarity {
+					best, bestSimilarity = i, score
+				}
+			}
+		}
+		if best < 0 {
+			threads = append(threads, []export.Report{report})
+		} else {
+			threads[best] = append(threads[best], report)
+		}
+	}
+	return threads
+}
+
+// reportDate returns the date of the article of a report, its publication
+// date if known, else the creation date of the report.
+func reportDate(report export.Report) string {
+	if report.Published != "" {
+		return report.Published
+	}
+	return report.DateCreated
+}
+
+func threadHash(reports []export.Report) string {
+	var parts []string
+	for _, report := range reports {
+		parts = append(parts, export.ReportFileName(report), report.Summary)
+	}
+	return export.HashEmbeddingText(strings.Join(parts, "\n"))
+}
+
+// writeThreadTimeline asks the LLM for the name, the state and the timeline
+// of the story of reports.
+func writeThreadTimeline(ctx context.Context, provider summarize.LLMProvider, reports []export.Report) (Thread, error) {
+	var sb strings.Builder
+	for i, report := range reports {
+		fmt.Fprintf(&sb, "# Report %d (%s): %s\n\n%s\n\n", i+1, reportDate(report), report.Title, report.Summary)
+	}
+
+	var thread Thread
+	_, err := summarize.CompleteJson(ctx, provider, []summarize.ChatMessage{
+		{Role: "system", Content: threadPrompt},
+		{Role: "user", Content: strings.TrimSpace(sb.String())},
+	}, &thread)
+	if err != nil {
+		return Thread{}, err
+	}
+	thread.Title = strings.TrimSpace(thread.Title)
+	thread.Summary = strings.TrimSpace(thread.Summary)
+	return thread, nil
+}
+
+// renderThreadPage renders the page of a thread: the state of the story, then
+// its timeline linking to the reports.
+func renderThreadPage(thread Thread) string {
+	developments := map[int]string{}
+	for _, event := range thread.Timeline {
+		developments[event.Report] = strings.TrimSpace(event.Development)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n%s\n\n## Timeline\n", thread.Title, thread.Summary)
+	for i, report := range thread.Reports {
+		fmt.Fprintf(&sb, "- **%s** [[%s]]", reportDate(report), strings.TrimSuffix(export.ReportFileName(report), ".md"))
+		if development := developments[i+1]; development != "" {
+			sb.WriteString(": " + development)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func writeThreadPage(outputFolder string, permissions export.FilePermissions, page string, thread Thread) error {
+	path := filepath.Join(outputFolder, page)
+	if err := permissions.Mkdir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("creating threads folder: %w", err)
+	}
+	if err := permissions.WriteFile(path, []byte(renderThreadPage(thread))); err != nil {
+		return fmt.Errorf("writing thread page: %w", err)
+	}
+	return nil
+}
+
+// removeStalePage removes the page a thread was written to before, when it
+// is not the page it is written to now.
+func removeStalePage(outputFolder, stale, current string) {
+	if stale == "" || stale == current {
+		return
+	}
+	if err := os.Remove(filepath.Join(outputFolder, stale)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "could not remove the former thread page '%s': %v\n", stale, err)
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func threadStorePath(outputFolder string) (string, error) {
+	folder, err := export.ReportDataFolder(outputFolder)
+	if err != nil {
+		return "", fmt.Errorf("finding data folder: %w", err)
+	}
+	return filepath.Join(folder, THREADS_FILE_NAME), nil
+}
+
+func loadThreadStore(outputFolder string) (*ThreadStore, error) {
+	store := &ThreadStore{Threads: map[string]StoredThread{}}
+
+	path, err := threadStorePath(outputFolder)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading thread store: %w", err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("unmarshaling thread store: %w", err)
+	}
+	if store.Threads == nil {
+		store.Threads = map[string]StoredThread{}
+	}
+	return store, nil
+}
+
+func (s *ThreadStore) save(outputFolder string, permissions export.FilePermissions) error {
+	path, err := threadStorePath(outputFolder)
+	if err != nil {
+		return err
+	}
+	if err := permissions.Mkdir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("creating data folder: %w", err)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshaling thread store: %w", err)
+	}
+	if err := permissions.WriteFile(path, data); err != nil {
+		return fmt.Errorf("writing thread store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/scrape"
+)
+
+type TagTrend struct {
+	Tag      string
+	Current  int
+	Previous int
+}
+
+func (t TagTrend) Delta() int {
+	return t.Current - t.Previous
+}
+
+func runTrends(config Config, args []string) error {
+	flags := flag.NewFlagSet("trends", flag.ExitOnError)
+	window := flags.String("window", "30d", "size of the compared time windows (e.g. 7d, 4w, 720h)")
+	limit := flags.Int("n", 10, "maximum number of emerging and declining topics to display")
+	output := flags.String("o", "", "write the trend report to this file instead of stdout")
+	permissions := addPermissionFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report trends [-window 30d] [-n 10] [-o trends.md] <output-folder>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder")
+	}
+	outputFolder := args[0]
+
+	windowSize, err := parsePeriod(*window)
+	if err != nil {
+		return fmt.Errorf("parsing window: %w", err)
+	}
+
+	reports, err := export.LoadReports(outputFolder)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	currentStart := now.Add(-windowSize)
+	previousStart := currentStart.Add(-windowSize)
+
+	trends := computeTagTrends(reports, previousStart, currentStart, now)
+	content := renderTrendReport(trends, previousStart, currentStart, now, *limit)
+
+	if *output == "" {
+		fmt.Print(content)
+		return nil
+	}
+
+	if err := permissions.WriteFile(*output, []byte(content)); err != nil {
+		return fmt.Errorf("writing trend report: %w", err)
+	}
+	fmt.Printf("Trend report created successfully: %s\n", *output)
+
+	return nil
+}
+
+// parsePeriod parses a period expressed in days ("7d"), weeks ("2w") or as a
+// Go duration ("36h").
+func parsePeriod(period string) (time.Duration, error) {
+	day := 24 * time.Hour
+	for suffix, unit := range map[string]time.Duration{"d": day, "w": 7 * day} {
+		if value, found := strings.CutSuffix(period, suffix); found {
+			count, err := strconv.Atoi(value)
+			if err != nil || count <= 0 {
+				return 0, fmt.Errorf("invalid period '%s'", period)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+
+	duration, err := time.ParseDuration(period)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid period '%s'", period)
+	}
+	return duration, nil
+}
+
+// computeTagTrends counts the tags of the reports created in the previous
+// window [previousStart, currentStart) and in the current window
+// [currentStart, end].
+func computeTagTrends(reports []export.Report, previousStart, currentStart, end time.Time) []TagTrend {
+	trendsByTag := map[string]*TagTrend{}
+	for _, report := range reports {
+		date, ok := export.ParseReportDate(report)
+		if !ok || date.Before(previousStart) || date.After(end) {
+			continue
+		}
+
+		for _, tag := range report.Tags {
+			trend, ok := trendsByTag[tag]
+			if !ok {
+				trend = &TagTrend{Tag: tag}
+				trendsByTag[tag] = trend
+			}
+			if date.Before(currentStart) {
+				trend.Previous++
+			} else {
+				trend.Current++
+			}
+		}
+	}
+
+	trends := make([]TagTrend, 0, len(trendsByTag))
+	for _, trend := range trendsByTag {
+		trends = append(trends, *trend)
+	}
+	sort.Slice(trends, func(i, j int) bool {
+		return trends[i].Tag < trends[j].Tag
+	})
+
+	return trends
+}
+
+func renderTrendReport(trends []TagTrend, previousStart, currentStart, end time.Time, limit int) string {
+	var emerging, declining []TagTrend
+	for _, trend := range trends {
+		switch {
+		case trend.Delta() > 0:
+			emerging = append(emerging, trend)
+		case trend.Delta() < 0:
+			declining = append(declining, trend)
+		}
+	}
+	sort.SliceStable(emerging, func(i, j int) bool {
+		return emerging[i].Delta() > emerging[j].Delta()
+	})
+	sort.SliceStable(declining, func(i, j int) bool {
+		return declining[i].Delta() < declining[j].Delta()
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Reading trends\n\nComparing %s → %s with %s → %s.\n",
+		currentStart.Format(scrape.DATE_FORMAT), end.Format(scrape.DATE_FORMAT),
+		previousStart.Format(scrape.DATE_FORMAT), currentStart.Format(scrape.DATE_FORMAT))
+
+	writeSection := func(title string, trends []TagTrend) {
+		fmt.Fprintf(&sb, "\n## %s\n", title)
+		if len(trends) == 0 {
+			sb.WriteString("Nothing to report.\n")
+			return
+		}
+		sb.WriteString("| Tag | Previous | Current | Change |\n|---|---|---|---|\n")
+		for _, trend := range trends[:min(limit, len(trends))] {
+			fmt.Fprintf(&sb, "| %s | %d | %d | %+d |\n", trend.Tag, trend.Previous, trend.Current, trend.Delta())
+		}
+	}
+	writeSection("Emerging topics", emerging)
+	writeSection("Declining topics", declining)
+
+	return sb.String()
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+func runUpdate(config Config, args []string) error {
+	flags := flag.NewFlagSet("update", flag.ExitOnError)
+	reportFlags := addReportFlags(flags, config)
+	reportFlags.addPreviewFlags(flags)
+	flags.Usage = func() {
+		fmt.Println("Usage: report update [options] <output-folder> <report|id>...")
+		fmt.Println("Summarize the articles of reports again, keeping the sections and the marked regions added by the user.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = flags.Args()
+	if len(args) == 1 {
+		args = config.withDefaultOutputFolder(args, 2)
+	}
+	if len(args) < 2 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder and reports")
+	}
+	outputFolder := args[0]
+
+	var reports []export.Report
+	for _, designation := range args[1:] {
+		report, err := resolveReport(outputFolder, designation)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+
+	options, err := reportFlags.options(outputFolder)
+	if err != nil {
+		return err
+	}
+	defer options.Events.Close()
+	// Only the markdown reports hold the notes of the user
+	options.Formats = []string{export.OUTPUT_FORMAT_MARKDOWN}
+	options.ReferencesFile = ""
+	options.Force = true
+	options.NonInteractive = true
+	client, err := report.New(report.WithReportOptions(options))
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	ctx = summarize.WithTerminalProgress(ctx, *reportFlags.provider.progress, 1)
+
+	var results []report.ArticleResult
+	for _, indexed := range reports {
+		if ctx.Err() != nil {
+			results = append(results, report.ArticleResult{Url: indexed.Url, Status: report.RESULT_FAILED, Err: ctx.Err()})
+			continue
+		}
+		results = append(results, client.Derive(func(options *report.ReportOptions) {
+			options.UpdatePath = indexed.Path
+		}).Create(ctx, indexed.Url))
+	}
+
+	if len(results) == 1 {
+		return results[0].Err
+	}
+	if !printBatchSummary(results) {
+		return fmt.Errorf("some reports failed")
+	}
+	return nil
+}
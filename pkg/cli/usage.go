@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/brequet/report/internal/paths"
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const USAGE_FILE_NAME = "usage.json"
+
+// UsageStore holds the monthly usage of each API key of the server, persisted
+// in the state directory.
+type UsageStore struct {
+	mu   sync.Mutex
+	path string
+	// Usage by key name, then by month
+	Keys map[string]map[string]summarize.Usage `json:"keys"`
+	// Articles being summarized by key name, then by month, counted in the
+	// quotas until their usage is recorded
+	reserved map[string]map[string]int
+}
+
+func loadUsageStore() (*UsageStore, error) {
+	dir, err := paths.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	store := &UsageStore{path: filepath.Join(dir, USAGE_FILE_NAME), Keys: map[string]map[string]summarize.Usage{}, reserved: map[string]map[string]int{}}
+
+	data, err := os.ReadFile(store.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading usage: %w", err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("unmarshaling usage: %w", err)
+	}
+	if store.Keys == nil {
+		store.Keys = map[string]map[string]summarize.Usage{}
+	}
+	return store, nil
+}
+
+// reserveArticle reserves an article in the monthly quota of a key, failing
+// when the quota is exhausted. The quota is checked and the article reserved
+// under the lock, so that concurrent requests cannot exceed it together. The
+// returned function must be called once the article is done, with its usage:
+// it records the usage and gives the reservation back, the article only
+// counting in the usage when created.
+func (s *UsageStore) reserveArticle(apiKey ApiKeyConfig, now time.Time) (func(usage summarize.Usage) error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	month := report.UsageMonth(now)
+	usage := s.Keys[apiKey.Name][month]
+	reserved := s.reserved[apiKey.Name][month]
+	if apiKey.MonthlyArticles > 0 && usage.Articles+reserved >= apiKey.MonthlyArticles {
+		return nil, fmt.Errorf("monthly quota of %d articles exhausted", apiKey.MonthlyArticles)
+	}
+	if apiKey.MonthlyTokens > 0 && usage.InputTokens+usage.OutputTokens >= apiKey.MonthlyTokens {
+		return nil, fmt.Errorf("monthly quota of %d tokens exhausted", apiKey.MonthlyTokens)
+	}
+
+	if s.reserved[apiKey.Name] == nil {
+		s.reserved[apiKey.Name] = map[string]int{}
+	}
+	s.reserved[apiKey.Name][month]++
+	var once sync.Once
+	return func(usage summarize.Usage) error {
+		var err error
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.reserved[apiKey.Name][month]--; s.reserved[apiKey.Name][month] == 0 {
+				delete(s.reserved[apiKey.Name], month)
+			}
+			err = s.addLocked(apiKey.Name, month, usage)
+		})
+		return err
+	}, nil
+}
+
+// keyUsage returns the usage of a key, by month.
+func (s *UsageStore) keyUsage(keyName string) map[string]summarize.Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	months := map[string]summarize.Usage{}
+	for month, usage := range s.Keys[keyName] {
+		months[month] = usage
+	}
+	return months
+}
+
+// keyNames returns the names of the keys with a recorded usage, sorted.
+func (s *UsageStore) keyNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.Keys))
+	for name := range s.Keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addLocked records usage for a key in a month and saves the store, the lock
+// being held.
+func (s *UsageStore) addLocked(keyName, month string, usage summarize.Usage) error {
+	if s.Keys[keyName] == nil {
+		s.Keys[keyName] = map[string]summarize.Usage{}
+	}
+	monthUsage := s.Keys[keyName][month]
+	monthUsage.Add(usage)
+	s.Keys[keyName][month] = monthUsage
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("creating state folder: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling usage: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("writing usage: %w", err)
+	}
+	return nil
+}
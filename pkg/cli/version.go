@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+
+	"github.com/brequet/report/internal/buildinfo"
+)
+
+func runVersion(config Config, args []string) error {
+	flags := flag.NewFlagSet("version", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: report version")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	fmt.Printf("report %s (%s %s/%s)\n", buildinfo.GetVersion(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+func runWallabag(config Config, args []string) error {
+	flags := flag.NewFlagSet("wallabag", flag.ExitOnError)
+	limit := flags.Int("n", 0, "maximum number of entries summarized, oldest first, 0 for all")
+	writeBack := flags.Bool("write-back", true, "write the summaries back to the entries as annotations, and the tags to the entries")
+	dryRun := flags.Bool("dry-run", false, "list the entries to summarize without summarizing them")
+	reportFlags := addReportFlags(flags, config)
+	flags.Usage = func() {
+		fmt.Println("Usage: report wallabag [-n 0] [-write-back=false] [-dry-run] [options] <output-folder>")
+		fmt.Println("Summarize the unread entries of a Wallabag server that have no summary annotation yet, writing the summaries and the tags back to them.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder")
+	}
+	outputFolder := args[0]
+
+	client, err := export.NewWallabagClient(reportFlags.config.Wallabag, reportFlags.scraper.Retry, reportFlags.scraper.Policy.Timeout)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	wallabagEntries, err := client.ListEntries(ctx)
+	if err != nil {
+		return err
+	}
+	// Entries summarized by a previous sync are left alone unless forced,
+	// their annotation being updated then
+	var entries []ReadingListEntry
+	entriesByUrl := map[string]export.WallabagEntry{}
+	for _, entry := range wallabagEntries {
+		if _, found := entry.SummaryAnnotation(); found && !*reportFlags.force {
+			continue
+		}
+		var tags []string
+		for _, tag := range entry.Tags {
+			if name := reportTag(tag.Label); name != "" {
+				tags = append(tags, name)
+			}
+		}
+		entries = append(entries, ReadingListEntry{Url: entry.Url, Title: entry.Title, Added: entry.Created(), Tags: tags})
+		entriesByUrl[entry.Url] = entry
+	}
+	entries = sortReadingList(entries)
+	if *limit > 0 && len(entries) > *limit {
+		entries = entries[:*limit]
+	}
+	fmt.Printf("%s: %d entries, %d to summarize\n", client.Url, len(wallabagEntries), len(entries))
+
+	if *dryRun {
+		for _, entry := range entries {
+			fmt.Printf("  %s  %s  %s\n", entry.Added.Format(scrape.DATE_FORMAT), entry.Url, entry.Title)
+		}
+		return nil
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	options, err := reportFlags.options(outputFolder)
+	if err != nil {
+		return err
+	}
+	defer options.Events.Close()
+	options.NonInteractive = true
+	options.Feed = client.Url
+	options.AddedDates = map[string]time.Time{}
+	options.ImportedTags = map[string][]string{}
+	if *writeBack {
+		options.Wallabag = client
+		options.WallabagEntries = entriesByUrl
+	}
+	articleUrls := make([]string, len(entries))
+	for i, entry := range entries {
+		articleUrls[i] = entry.Url
+		options.AddedDates[entry.Url] = entry.Added
+		options.ImportedTags[entry.Url] = entry.Tags
+	}
+
+	ctx = summarize.WithTerminalProgress(ctx, *reportFlags.provider.progress, *reportFlags.concurrency)
+	reportClient, err := report.New(report.WithReportOptions(options))
+	if err != nil {
+		return err
+	}
+	results := reportClient.CreateAll(ctx, articleUrls, *reportFlags.concurrency)
+	if !printBatchSummary(results) {
+		return fmt.Errorf("some entries failed")
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+func runImportWarc(config Config, args []string) error {
+	flags := flag.NewFlagSet("import-warc", flag.ExitOnError)
+	match := flags.String("match", "", "only summarize the pages whose url matches this regular expression")
+	limit := flags.Int("n", 0, "maximum number of pages summarized, oldest first, 0 for all")
+	dryRun := flags.Bool("dry-run", false, "list the pages without summarizing them")
+	reportFlags := addReportFlags(flags, config)
+	flags.Usage = func() {
+		fmt.Println("Usage: report import-warc [-match regex] [-n 0] [-dry-run] [options] <output-folder> <archive.warc[.gz]>...")
+		fmt.Println("Summarize the HTML pages of WARC archives, e.g. written by wget --warc-file or ArchiveBox, oldest first, without fetching them again.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 2)
+	if len(args) < 2 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder and WARC files")
+	}
+	var matchRegex *regexp.Regexp
+	if *match != "" {
+		var err error
+		matchRegex, err = regexp.Compile(*match)
+		if err != nil {
+			return fmt.Errorf("invalid -match expression: %w", err)
+		}
+	}
+	outputFolder := args[0]
+
+	pages := map[string]scrape.ArchivedPage{}
+	for _, path := range args[1:] {
+		filePages, err := scrape.ReadWarcFile(path, matchRegex)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d HTML pages\n", path, len(filePages))
+		// The latest capture of a page wins
+		for _, page := range filePages {
+			if previous, ok := pages[page.Url]; !ok || page.Archived.After(previous.Archived) {
+				pages[page.Url] = page
+			}
+		}
+	}
+	sorted := make([]scrape.ArchivedPage, 0, len(pages))
+	for _, page := range pages {
+		sorted = append(sorted, page)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].Archived.Equal(sorted[j].Archived) {
+			return sorted[i].Archived.Before(sorted[j].Archived)
+		}
+		return sorted[i].Url < sorted[j].Url
+	})
+	if *limit > 0 && len(sorted) > *limit {
+		sorted = sorted[:*limit]
+	}
+
+	if *dryRun {
+		for _, page := range sorted {
+			fmt.Printf("  %s  %s  %d KB\n", page.Archived.Format(scrape.DATE_FORMAT), page.Url, len(page.Html)/1024)
+		}
+		return nil
+	}
+	if len(sorted) == 0 {
+		return nil
+	}
+
+	options, err := reportFlags.options(outputFolder)
+	if err != nil {
+		return err
+	}
+	defer options.Events.Close()
+	options.NonInteractive = true
+	options.ArchivedPages = pages
+	articleUrls := make([]string, len(sorted))
+	for i, page := range sorted {
+		articleUrls[i] = page.Url
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	ctx = summarize.WithTerminalProgress(ctx, *reportFlags.provider.progress, *reportFlags.concurrency)
+
+	client, err := report.New(report.WithReportOptions(options))
+	if err != nil {
+		return err
+	}
+	results := client.CreateAll(ctx, articleUrls, *reportFlags.concurrency)
+	if !printBatchSummary(results) {
+		return fmt.Errorf("some pages failed")
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/report"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const DEFAULT_WATCH_INTERVAL = time.Hour
+
+// WatchSource is a source polled by the watch mode: a feed, or a file or url
+// listing article urls, one per line.
+type WatchSource struct {
+	Url  string
+	List bool
+}
+
+func runWatch(config Config, args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := flags.Duration("interval", config.Watch.interval(), "delay between two polls of the sources")
+	limit := flags.Int("n", 10, "maximum number of new entries summarized per source and poll, 0 for all")
+	once := flags.Bool("once", false, "poll the sources once and exit, e.g. from cron")
+	reportFlags := addReportFlags(flags, config)
+	flags.Usage = func() {
+		fmt.Println("Usage: report watch [-interval 1h] [-n 10] [-once] [options] <output-folder>")
+		fmt.Println("Poll the feeds and url lists of the watch section of the config file, summarizing their new entries into a folder per day.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	args = config.withDefaultOutputFolder(flags.Args(), 1)
+	if len(args) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected an output folder")
+	}
+	outputFolder := args[0]
+	if *interval <= 0 {
+		return fmt.Errorf("invalid interval %s, expected a positive duration", *interval)
+	}
+
+	sources := config.Watch.sources()
+	if len(sources) == 0 {
+		return fmt.Errorf("no source to watch, add feeds or lists to the watch section of the config file")
+	}
+
+	options, err := reportFlags.options(outputFolder)
+	if err != nil {
+		return err
+	}
+	defer options.Events.Close()
+	options.NonInteractive = true
+	client, err := report.New(report.WithReportOptions(options))
+	if err != nil {
+		return err
+	}
+
+	state, err := loadFeedState(outputFolder)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	ctx = summarize.WithTerminalProgress(ctx, *reportFlags.provider.progress, *reportFlags.concurrency)
+
+	for {
+		slog.Info("Polling sources", "sources", len(sources))
+		failed := false
+		for _, source := range sources {
+			if ctx.Err() != nil {
+				break
+			}
+			if err := pollWatchSource(ctx, outputFolder, source, state, client, *limit, *reportFlags.concurrency); err != nil {
+				slog.Error("polling failed", "source", source.Url, "err", err)
+				failed = true
+			}
+		}
+
+		if *once {
+			if failed {
+				return fmt.Errorf("some sources or entries failed")
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			slog.Info("Stopped, the entries not summarized yet are picked up by the next run")
+			return nil
+		}
+
+		next := time.Now().Add(*interval)
+		slog.Info("Next poll", "at", next)
+		select {
+		case <-ctx.Done():
+			slog.Info("Stopped")
+			return nil
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// pollWatchSource summarizes the new entries of a source into the folder of
+// the day, and records them in the feed state once summarized or skipped.
+func pollWatchSource(ctx context.Context, outputFolder string, source WatchSource, state *FeedState, client *report.Client, limit, concurrency int) error {
+	options := client.Options()
+	var entryUrls []string
+	var err error
+	if source.List {
+		entryUrls, err = fetchWatchList(ctx, options.Scraper, source.Url)
+	} else {
+		entryUrls, err = fetchFeedEntries(ctx, options.Scraper, source.Url)
+	}
+	if err != nil {
+		return err
+	}
+
+	processed := map[string]bool{}
+	for _, entryUrl := range state.Feeds[source.Url] {
+		processed[entryUrl] = true
+	}
+	index := options.Index
+	if options.Force {
+		index = nil
+	}
+	newUrls := newFeedEntries(entryUrls, processed, index, limit)
+	slog.Info("Source polled", "source", source.Url, "entries", len(entryUrls), "new", len(newUrls))
+	if len(newUrls) == 0 {
+		return nil
+	}
+
+	// The index stays the one of the output folder, so that an article is
+	// not summarized again on another day
+	dayFolder := filepath.Join(outputFolder, time.Now().Format(scrape.DATE_FORMAT))
+	if err := options.Permissions.Mkdir(dayFolder); err != nil {
+		return fmt.Errorf("creating folder: %w", err)
+	}
+
+	results := client.Derive(func(options *report.ReportOptions) {
+		options.OutputFolder = dayFolder
+		options.Feed = source.Url
+	}).CreateAll(ctx, newUrls, concurrency)
+	failed := !printBatchSummary(results)
+
+	// Failed entries are left out so that they are retried on the next poll
+	for _, result := range results {
+		if result.Status != report.RESULT_FAILED {
+			state.Feeds[source.Url] = append(state.Feeds[source.Url], result.Url)
+		}
+	}
+	if err := state.save(outputFolder, options.Permissions); err != nil {
+		return err
+	}
+	if failed {
+		return fmt.Errorf("some entries failed")
+	}
+	return nil
+}
+
+// fetchWatchList returns the urls of a list, a local file or an url fetched
+// by the scraper, one per line.
+func fetchWatchList(ctx context.Context, scraper *scrape.Scraper, source string) ([]string, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return readUrlsFile(source)
+	}
+
+	var page string
+	err := scraper.Retry.Do(ctx, "fetching "+source, func() error {
+		var err error
+		page, err = scraper.FetchUrlAndReturnPage(ctx, source)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, line := range strings.Split(page, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			urls = append(urls, line)
+		}
+	}
+	return urls, nil
+}
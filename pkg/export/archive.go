@@ -0,0 +1,217 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Report is a report previously exported to the output folder, read back
+// from its markdown file.
+type Report struct {
+	Id          string
+	Path        string
+	Title       string
+	Url         string
+	ContentType string
+	DateCreated string
+	// Publication date of the article, empty if unknown
+	Published string
+	Tags      []string
+	// ISO 639-1 code of the language of the article, empty if unknown
+	Language string
+	// Wayback Machine snapshot of the article, if the report links to one
+	ArchiveUrl string
+	// LINK_STATUS_DEAD when report linkcheck found the article gone
+	LinkStatus string
+	Summary    string
+	// Heading of the key points, which depends on the content type
+	KeypointsHeading string
+	Keypoints        []string
+}
+
+// LoadReports reads every report of the output folder and of its subfolders,
+// such as the dated folders of report watch. Markdown files that were not
+// produced by this tool, and hidden folders, such as the one of Obsidian, are
+// ignored.
+func LoadReports(outputFolder string) ([]Report, error) {
+	if _, err := os.Stat(outputFolder); err != nil {
+		return nil, fmt.Errorf("reading output folder '%s': %w", outputFolder, err)
+	}
+
+	var reports []Report
+	err := filepath.WalkDir(outputFolder, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("reading output folder '%s': %w", outputFolder, err)
+		}
+		if entry.IsDir() {
+			if path != outputFolder && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(entry.Name()) != ".md" {
+			return nil
+		}
+
+		report, err := ParseReportFile(path)
+		if err != nil {
+			return fmt.Errorf("parsing report '%s': %w", path, err)
+		}
+		if report.Url != "" {
+			reports = append(reports, report)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Path < reports[j].Path
+	})
+
+	return reports, nil
+}
+
+// ParseReportFile reads the front matter and the summary of a markdown report.
+func ParseReportFile(path string) (Report, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Report{}, err
+	}
+	defer file.Close()
+
+	report := Report{Path: path}
+
+	var section string
+	var summaryLines, frontmatterLines []string
+	inFrontmatter := false
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNumber := 0; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if lineNumber == 0 {
+			line = strings.TrimPrefix(line, UTF8_BOM)
+		}
+
+		if line == "---" {
+			if lineNumber == 0 {
+				inFrontmatter = true
+				continue
+			}
+			if inFrontmatter {
+				inFrontmatter = false
+				section = ""
+				continue
+			}
+		}
+
+		if inFrontmatter {
+			frontmatterLines = append(frontmatterLines, line)
+			continue
+		}
+
+		// The key points heading depends on the content type template, it is
+		// the first heading following the summary
+		if strings.HasPrefix(line, "# ") {
+			switch {
+			case line == "# Summary":
+				section = "summary"
+			case section == "summary":
+				section = "keypoints"
+				report.KeypointsHeading = strings.TrimPrefix(line, "# ")
+			default:
+				section = ""
+			}
+			continue
+		}
+
+		switch section {
+		case "summary":
+			summaryLines = append(summaryLines, line)
+		case "keypoints":
+			if strings.HasPrefix(line, "- ") {
+				report.Keypoints = append(report.Keypoints, strings.TrimPrefix(line, "- "))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Report{}, err
+	}
+
+	parseFrontmatter(&report, frontmatterLines)
+	report.Summary = strings.TrimSpace(strings.Join(summaryLines, "\n"))
+
+	return report, nil
+}
+
+// parseFrontmatter reads the frontmatter line by line, as the templates
+// substitute values without any escaping, which is not always valid YAML. It
+// also reads the quoted values and indented lists of a YAML frontmatter.
+func parseFrontmatter(report *Report, lines []string) {
+	var key string
+	for _, line := range lines {
+		if item, found := strings.CutPrefix(strings.TrimSpace(line), "- "); found && key == "tags" {
+			report.Tags = append(report.Tags, unquoteYamlValue(item))
+			continue
+		}
+
+		var value string
+		var found bool
+		key, value, found = strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = unquoteYamlValue(value)
+		switch key {
+		case "id":
+			report.Id = value
+		case "title":
+			report.Title = value
+		case "url":
+			report.Url = value
+		case "content_type":
+			report.ContentType = value
+		case "date_created", "date":
+			report.DateCreated = value
+		case "published":
+			report.Published = value
+		case "language":
+			report.Language = value
+		case "archive_url":
+			report.ArchiveUrl = value
+		case "link_status":
+			report.LinkStatus = value
+		}
+	}
+}
+
+func unquoteYamlValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 {
+		return value
+	}
+	switch {
+	case value[0] == '"' && value[len(value)-1] == '"':
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+	case value[0] == '\'' && value[len(value)-1] == '\'':
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	return value
+}
+
+// ReportFileName returns the file name of a report, which identifies it in the
+// output folder.
+func ReportFileName(report Report) string {
+	return filepath.Base(report.Path)
+}
@@ -0,0 +1,169 @@
+package export
+
+import (
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+//go:embed article-template.md
+var articleTemplate string
+
+// Article is a scraped article, summarized, as written to its reports.
+type Article struct {
+	scrape.Article
+	// Stable ID of the reports of the article, see NewReportId
+	Id string
+	// Other titles of the article, such as its original title when renamed
+	Aliases []string
+	// File name of the reports, without extension, when it is not the title
+	// (see -slug)
+	Slug string
+	// Lede of the article, only set when the reports include an excerpt
+	Excerpt string
+	// Cover referenced by the reports, the url of the image of the article or
+	// the path of a downloaded copy (see -cover)
+	Cover string
+	// Descriptions of the first images of the article by a vision model (see
+	// -describe-images)
+	ImageDescriptions []summarize.ImageDescription
+	// Previous reports of the output folder the article contradicts or
+	// updates (see -check-archive)
+	Conflicts []ArchiveConflict
+	// Date the article was added to the reading list it was imported from
+	// (see report import)
+	Added   time.Time
+	Summary *summarize.Summary
+}
+
+// ExportArticle writes the markdown report of an article to the output folder
+// and returns its path.
+func ExportArticle(outputFolder string, permissions FilePermissions, article Article, template, frontmatter, includeContent string, encoding OutputEncoding) (string, error) {
+	outputPath, content, err := RenderArticleFile(outputFolder, article, template, frontmatter, includeContent)
+	if err != nil {
+		return "", err
+	}
+
+	err = permissions.WriteFile(outputPath, encoding.encode(content))
+	if err != nil {
+		return "", fmt.Errorf("writing output file: %v", err)
+	}
+
+	slog.Info("Article created successfully", "path", outputPath)
+	return outputPath, nil
+}
+
+// RenderArticleFile returns the path of the markdown report of an article in
+// the output folder and its content.
+func RenderArticleFile(outputFolder string, article Article, template, frontmatter, includeContent string) (string, string, error) {
+	content, err := RenderArticle(article, template, frontmatter, includeContent)
+	if err != nil {
+		return "", "", err
+	}
+
+	outputPath := filepath.Join(outputFolder, article.FileName()+".md")
+
+	// A report written again keeps what is outside of its generated regions
+	if existing, err := os.ReadFile(outputPath); err == nil {
+		if merged, ok := mergeGeneratedRegions(strings.TrimPrefix(NormalizeLineEndings(string(existing)), UTF8_BOM), content); ok {
+			content = merged
+		}
+	}
+	return outputPath, content, nil
+}
+
+// RenderArticle renders the markdown report of an article.
+func RenderArticle(article Article, template, frontmatter, includeContent string) (string, error) {
+	if err := checkArticleIsComplete(article); err != nil {
+		return "", err
+	}
+
+	data := newTemplateData(article, time.Now())
+	data.ContentHeading, data.Content = includedContent(article, includeContent)
+	content, err := renderReportTemplate(template, data)
+	if err != nil {
+		return "", err
+	}
+
+	if frontmatter == FRONTMATTER_YAML {
+		yamlFrontmatter, err := renderYamlFrontmatter(article, data.Date)
+		if err != nil {
+			return "", err
+		}
+		content = replaceFrontmatter(content, yamlFrontmatter)
+	}
+	return content, nil
+}
+
+func checkArticleIsComplete(article Article) error {
+	if article.Title == "" || article.Summary == nil || len(article.Summary.Keypoints) == 0 || len(article.Summary.Tags) == 0 {
+		incompleteArticleStr := fmt.Sprintf(`
+		- title: %s (needs to be set)
+		- is summary nil: %t (needs to be true)
+		- keypoints length: %d (needs > 0)
+		- tags length: %d (needs > 0)
+		`,
+			article.Title,
+			article.Summary == nil,
+			len(article.Summary.Keypoints),
+			len(article.Summary.Tags),
+		)
+		return fmt.Errorf("article is incomplete: \n%s", incompleteArticleStr)
+	}
+
+	return nil
+}
+
+// IsValidWindowsFilename tells if a file name is valid on Windows, the most
+// restrictive of the systems.
+func IsValidWindowsFilename(filename string) bool {
+	invalidChars := regexp.MustCompile(`[<>:"/\\|?*\x00-\x1F]`)
+	if invalidChars.MatchString(filename) {
+		return false
+	}
+
+	if len(filename) > 255 || len(filename) == 0 {
+		return false
+	}
+
+	if strings.HasSuffix(filename, " ") || strings.HasSuffix(filename, ".") {
+		return false
+	}
+
+	return true
+}
+
+var invalidFilenameCharsRegex = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1F]+`)
+
+// SanitizeFilename turns a title into a valid Windows filename without asking
+// the user, for when nobody is there to answer.
+func SanitizeFilename(title string) string {
+	filename := strings.Join(strings.Fields(invalidFilenameCharsRegex.ReplaceAllString(title, " ")), " ")
+	for len(filename) > 200 {
+		_, size := utf8.DecodeLastRuneInString(filename)
+		filename = filename[:len(filename)-size]
+	}
+	filename = strings.TrimRight(filename, " .")
+	if filename == "" {
+		return "article"
+	}
+	return filename
+}
+
+// FileName returns the file name of the reports of the article, without
+// extension.
+func (a Article) FileName() string {
+	if a.Slug != "" {
+		return a.Slug
+	}
+	return a.Title
+}
@@ -0,0 +1,77 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const (
+	// Folder of the downloaded images of the articles, in the output folder,
+	// holding a folder per article
+	ASSETS_FOLDER_NAME = "assets"
+	// Images downloaded per article, in the order of the page
+	MAX_DOWNLOADED_IMAGES = 50
+)
+
+// DownloadArticleImages saves the images of the body of an article in its
+// folder of the assets folder, named after its title, and returns the article
+// with its reports referencing the local copies: the images of its markdown
+// text and of the image descriptions, downloaded by the scraper. The images
+// that cannot be downloaded keep their url.
+func DownloadArticleImages(ctx context.Context, scraper *scrape.Scraper, outputFolder string, permissions FilePermissions, article Article) Article {
+	if len(article.Images) == 0 {
+		return article
+	}
+	folderName := SanitizeFilename(article.Title)
+	folder := filepath.Join(outputFolder, ASSETS_FOLDER_NAME, folderName)
+	if err := permissions.Mkdir(folder); err != nil {
+		slog.Warn("could not create the assets folder", "path", folder, "err", err)
+		return article
+	}
+
+	images := article.Images
+	localPaths := map[string]string{}
+	for i := range images[:min(len(images), MAX_DOWNLOADED_IMAGES)] {
+		if ctx.Err() != nil {
+			break
+		}
+		data, extension, err := scraper.DownloadImage(ctx, images[i].Url, "image download")
+		if err != nil {
+			slog.Warn("could not download the image", "url", images[i].Url, "err", err)
+			continue
+		}
+		fileName := fmt.Sprintf("%02d%s", i+1, extension)
+		if err := permissions.WriteFile(filepath.Join(folder, fileName), data); err != nil {
+			slog.Warn("could not save the image", "url", images[i].Url, "err", err)
+			continue
+		}
+		// Slashes, for the note apps of every platform, and escaped, for the
+		// markdown links
+		images[i].Path = ASSETS_FOLDER_NAME + "/" + url.PathEscape(folderName) + "/" + fileName
+		localPaths[images[i].Url] = images[i].Path
+	}
+	slog.Debug("Images downloaded", "url", article.Url, "images", len(images), "downloaded", len(localPaths))
+
+	var descriptions []summarize.ImageDescription
+	for _, description := range article.ImageDescriptions {
+		description.Path = localPaths[description.Url]
+		descriptions = append(descriptions, description)
+	}
+	article.ImageDescriptions = descriptions
+
+	if article.Markdown != "" && len(localPaths) > 0 {
+		var replacements []string
+		for imageUrl, localPath := range localPaths {
+			replacements = append(replacements, "]("+scrape.MarkdownLinkUrl(imageUrl)+")", "]("+localPath+")")
+		}
+		article.Markdown = strings.NewReplacer(replacements...).Replace(article.Markdown)
+	}
+	return article
+}
@@ -0,0 +1,298 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+const (
+	REFERENCE_FORMAT_BIBTEX  = "bibtex"
+	REFERENCE_FORMAT_CSLJSON = "csl-json"
+)
+
+// Citation holds the bibliographic information of an article. Fields that
+// could not be found on the page are left empty.
+type Citation struct {
+	Key       string
+	Authors   []string
+	Title     string
+	Site      string
+	Published time.Time
+	Url       string
+	Accessed  time.Time
+}
+
+func newArticleCitation(article Article, accessed time.Time) Citation {
+	citation := Citation{
+		Authors:   article.Authors,
+		Title:     article.Title,
+		Site:      article.SiteName,
+		Published: article.Published,
+		Url:       article.Url,
+		Accessed:  accessed,
+	}
+	if citation.Site == "" {
+		citation.Site = SiteNameFromUrl(article.Url)
+	}
+	citation.Key = citationKey(citation)
+	return citation
+}
+
+// SiteNameFromUrl returns the host of an url without its www. prefix, empty
+// if the url is invalid.
+func SiteNameFromUrl(articleUrl string) string {
+	parsed, err := url.Parse(articleUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(parsed.Hostname(), "www.")
+}
+
+var nonAlphanumericRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// citationKey builds a key in the usual "authoryearword" form, from the first
+// author or the site name when the author is unknown.
+func citationKey(citation Citation) string {
+	prefix := strings.Split(citation.Site, ".")[0]
+	if len(citation.Authors) > 0 {
+		prefix = citation.Authors[0]
+	}
+	if fields := strings.Fields(prefix); len(fields) > 0 {
+		prefix = fields[len(fields)-1]
+	}
+
+	date := citation.Published
+	if date.IsZero() {
+		date = citation.Accessed
+	}
+
+	var word string
+	for _, field := range strings.Fields(strings.ToLower(citation.Title)) {
+		field = nonAlphanumericRegex.ReplaceAllString(field, "")
+		if len(field) > 3 {
+			word = field
+			break
+		}
+	}
+
+	key := nonAlphanumericRegex.ReplaceAllString(strings.ToLower(prefix), "") + date.Format("2006") + word
+	if key == date.Format("2006") {
+		key = "ref" + key
+	}
+	return key
+}
+
+func guessReferenceFormat(referencesFile string) (string, error) {
+	switch strings.ToLower(filepath.Ext(referencesFile)) {
+	case ".bib":
+		return REFERENCE_FORMAT_BIBTEX, nil
+	case ".json":
+		return REFERENCE_FORMAT_CSLJSON, nil
+	}
+	return "", fmt.Errorf("cannot guess reference format of '%s', use a .bib or .json file or set the format", referencesFile)
+}
+
+// AppendArticleReference adds a citation of the article to the references
+// file, creating it if needed. An article already referenced is not added twice.
+func AppendArticleReference(referencesFile string, permissions FilePermissions, format string, article Article) error {
+	if format == "" {
+		guessed, err := guessReferenceFormat(referencesFile)
+		if err != nil {
+			return err
+		}
+		format = guessed
+	}
+
+	citation := newArticleCitation(article, time.Now())
+
+	var err error
+	switch format {
+	case REFERENCE_FORMAT_BIBTEX:
+		err = appendBibtexReference(referencesFile, permissions, citation)
+	case REFERENCE_FORMAT_CSLJSON:
+		err = appendCslJsonReference(referencesFile, permissions, citation)
+	default:
+		return fmt.Errorf("unknown reference format '%s', expected %s or %s", format, REFERENCE_FORMAT_BIBTEX, REFERENCE_FORMAT_CSLJSON)
+	}
+	if err != nil {
+		return fmt.Errorf("appending reference to '%s': %w", referencesFile, err)
+	}
+
+	slog.Info("Reference added successfully", "path", referencesFile)
+	return nil
+}
+
+func appendBibtexReference(referencesFile string, permissions FilePermissions, citation Citation) error {
+	existing, err := os.ReadFile(referencesFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if strings.Contains(string(existing), "url = {"+citation.Url+"}") {
+		return nil
+	}
+
+	baseKey := citation.Key
+	for suffix := 'a'; strings.Contains(string(existing), "{"+citation.Key+","); suffix++ {
+		citation.Key = baseKey + string(suffix)
+	}
+
+	file, err := permissions.appendFile(referencesFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n\n") {
+		if _, err := file.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+
+	_, err = file.WriteString(formatBibtexEntry(citation))
+	return err
+}
+
+func formatBibtexEntry(citation Citation) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "@misc{%s,\n", citation.Key)
+	if len(citation.Authors) > 0 {
+		fmt.Fprintf(&sb, "  author = {%s},\n", escapeBibtex(strings.Join(citation.Authors, " and ")))
+	}
+	fmt.Fprintf(&sb, "  title = {{%s}},\n", escapeBibtex(citation.Title))
+	if citation.Site != "" {
+		fmt.Fprintf(&sb, "  organization = {%s},\n", escapeBibtex(citation.Site))
+	}
+	if !citation.Published.IsZero() {
+		fmt.Fprintf(&sb, "  year = {%s},\n", citation.Published.Format("2006"))
+		fmt.Fprintf(&sb, "  date = {%s},\n", citation.Published.Format(scrape.DATE_FORMAT))
+	}
+	fmt.Fprintf(&sb, "  howpublished = {\\url{%s}},\n", citation.Url)
+	fmt.Fprintf(&sb, "  url = {%s},\n", citation.Url)
+	fmt.Fprintf(&sb, "  urldate = {%s},\n", citation.Accessed.Format(scrape.DATE_FORMAT))
+	fmt.Fprintf(&sb, "  note = {Accessed: %s}\n", citation.Accessed.Format(scrape.DATE_FORMAT))
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+var bibtexReplacer = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+func escapeBibtex(value string) string {
+	return bibtexReplacer.Replace(value)
+}
+
+type CslDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+type CslName struct {
+	Literal string `json:"literal"`
+}
+
+type CslItem struct {
+	Id             string    `json:"id"`
+	Type           string    `json:"type"`
+	Title          string    `json:"title"`
+	ContainerTitle string    `json:"container-title,omitempty"`
+	Author         []CslName `json:"author,omitempty"`
+	Issued         *CslDate  `json:"issued,omitempty"`
+	Accessed       *CslDate  `json:"accessed,omitempty"`
+	Url            string    `json:"URL"`
+}
+
+func newCslDate(date time.Time) *CslDate {
+	if date.IsZero() {
+		return nil
+	}
+	return &CslDate{DateParts: [][]int{{date.Year(), int(date.Month()), date.Day()}}}
+}
+
+// appendCslJsonReference adds the citation to a CSL-JSON file. The items
+// already in the file are kept as they are, with the fields the tool does not
+// write, such as the family and given names of the authors.
+func appendCslJsonReference(referencesFile string, permissions FilePermissions, citation Citation) error {
+	var items []json.RawMessage
+
+	existing, err := os.ReadFile(referencesFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if len(strings.TrimSpace(string(existing))) > 0 {
+		if err := json.Unmarshal(existing, &items); err != nil {
+			return fmt.Errorf("unmarshaling CSL-JSON references: %w", err)
+		}
+	}
+
+	ids := map[string]bool{}
+	for _, raw := range items {
+		var item struct {
+			Id  any    `json:"id"`
+			Url string `json:"URL"`
+		}
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return fmt.Errorf("unmarshaling CSL-JSON references: %w", err)
+		}
+		if item.Url == citation.Url {
+			return nil
+		}
+		// CSL-JSON ids are strings or numbers
+		ids[fmt.Sprint(item.Id)] = true
+	}
+
+	id := citation.Key
+	for suffix := 'a'; ids[id]; suffix++ {
+		id = citation.Key + string(suffix)
+	}
+
+	item := CslItem{
+		Id:             id,
+		Type:           "webpage",
+		Title:          citation.Title,
+		ContainerTitle: citation.Site,
+		Issued:         newCslDate(citation.Published),
+		Accessed:       newCslDate(citation.Accessed),
+		Url:            citation.Url,
+	}
+	for _, author := range citation.Authors {
+		item.Author = append(item.Author, CslName{Literal: author})
+	}
+	var itemBuf bytes.Buffer
+	itemEncoder := json.NewEncoder(&itemBuf)
+	itemEncoder.SetEscapeHTML(false)
+	if err := itemEncoder.Encode(item); err != nil {
+		return fmt.Errorf("marshaling CSL-JSON references: %w", err)
+	}
+	items = append(items, bytes.TrimSpace(itemBuf.Bytes()))
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(items); err != nil {
+		return fmt.Errorf("marshaling CSL-JSON references: %w", err)
+	}
+
+	return permissions.WriteFile(referencesFile, buf.Bytes())
+}
@@ -0,0 +1,46 @@
+package export
+
+// KarakeepConfig holds the server of report karakeep and of the Karakeep
+// export.
+type KarakeepConfig struct {
+	// Address of the server, e.g. https://karakeep.example.com
+	Url    string `yaml:"url,omitempty"`
+	ApiKey string `yaml:"api_key,omitempty"`
+}
+
+// WallabagConfig holds the server and the account of report wallabag and of
+// the Wallabag export.
+type WallabagConfig struct {
+	// Address of the server, e.g. https://app.wallabag.it
+	Url          string `yaml:"url,omitempty"`
+	ClientId     string `yaml:"client_id,omitempty"`
+	ClientSecret string `yaml:"client_secret,omitempty"`
+	Username     string `yaml:"username,omitempty"`
+	Password     string `yaml:"password,omitempty"`
+}
+
+// WebhookConfig holds the default webhook of -export webhook.
+type WebhookConfig struct {
+	// Address the JSON reports are posted to
+	Url   string `yaml:"url,omitempty"`
+	Token string `yaml:"token,omitempty"`
+	// Key of the signature of the requests
+	Secret string `yaml:"secret,omitempty"`
+}
+
+// NoteLinksConfig holds the defaults of the notes linking to the new reports,
+// like the -link-note, -daily-note, -daily-note-format and -link-heading
+// flags.
+type NoteLinksConfig struct {
+	Note        string `yaml:"note,omitempty"`
+	DailyFolder string `yaml:"daily_folder,omitempty"`
+	DailyFormat string `yaml:"daily_format,omitempty"`
+	Heading     string `yaml:"heading,omitempty"`
+}
+
+// NotionConfig holds the defaults of the Notion export.
+type NotionConfig struct {
+	ApiKey string `yaml:"api_key,omitempty"`
+	// Database the pages are created in, like the -notion-db flag
+	DatabaseId string `yaml:"database_id,omitempty"`
+}
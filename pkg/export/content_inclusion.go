@@ -0,0 +1,45 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// The report only holds the summary
+	INCLUDE_CONTENT_NONE = "none"
+	// The report also holds the first paragraphs of the article
+	INCLUDE_CONTENT_EXCERPT = "excerpt"
+	// The report also holds the whole cleaned text of the article, to keep
+	// it when the page disappears
+	INCLUDE_CONTENT_FULL = "full"
+	// The report also holds the whole article converted to markdown, with its
+	// headings, lists, links and code blocks, for offline reading
+	INCLUDE_CONTENT_MARKDOWN = "markdown"
+)
+
+// ValidateIncludeContent checks a content inclusion policy, see -include-content.
+func ValidateIncludeContent(policy string) error {
+	if policy != INCLUDE_CONTENT_NONE && policy != INCLUDE_CONTENT_EXCERPT && policy != INCLUDE_CONTENT_FULL && policy != INCLUDE_CONTENT_MARKDOWN {
+		return fmt.Errorf("unknown content inclusion '%s', expected %s, %s, %s or %s", policy, INCLUDE_CONTENT_NONE, INCLUDE_CONTENT_EXCERPT, INCLUDE_CONTENT_FULL, INCLUDE_CONTENT_MARKDOWN)
+	}
+	return nil
+}
+
+// includedContent returns the heading and the text of the article included in
+// its report, both empty when the article text is not included.
+func includedContent(article Article, policy string) (string, string) {
+	switch policy {
+	case INCLUDE_CONTENT_EXCERPT:
+		return "Excerpt", article.Excerpt
+	case INCLUDE_CONTENT_FULL:
+		return "Article", strings.TrimSpace(article.Content)
+	case INCLUDE_CONTENT_MARKDOWN:
+		// The PDF documents and the text pages have no markup
+		if article.Markdown == "" {
+			return "Article", strings.TrimSpace(article.Content)
+		}
+		return "Article", article.Markdown
+	}
+	return "", ""
+}
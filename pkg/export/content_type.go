@@ -0,0 +1,41 @@
+package export
+
+import (
+	"embed"
+	"fmt"
+	"os"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// Each content type, except the default article type, has its own report
+// template, found in content-types/<type>/template.md.
+//
+//go:embed content-types/*/template.md
+var contentTypeTemplates embed.FS
+
+// GetContentTypeTemplate returns the report template of the content type:
+// the user's template for the type if one is configured, else the user's
+// template for all types, else the embedded one.
+func GetContentTypeTemplate(contentType string, userTemplates scrape.ContentTypeFiles, userTemplate string) (string, error) {
+	if path, ok := userTemplates[contentType]; ok {
+		template, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading %s template: %w", contentType, err)
+		}
+		return string(template), nil
+	}
+	if userTemplate != "" {
+		template, err := os.ReadFile(userTemplate)
+		if err != nil {
+			return "", fmt.Errorf("reading template: %w", err)
+		}
+		return string(template), nil
+	}
+
+	template, err := contentTypeTemplates.ReadFile("content-types/" + contentType + "/template.md")
+	if err != nil {
+		return articleTemplate, nil
+	}
+	return string(template), nil
+}
@@ -0,0 +1,156 @@
+package export
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const (
+	// Previous reports compared with an article, the most similar ones
+	CONTRADICTION_RELATED_REPORTS = 5
+	// Cosine similarity of the summaries above which a previous report is
+	// related to the article
+	CONTRADICTION_MIN_SIMILARITY = 0.5
+
+	CONFLICT_CONTRADICTION = "contradiction"
+	CONFLICT_UPDATE        = "update"
+)
+
+//go:embed contradiction-prompt.md
+var contradictionPrompt string
+
+// ArchiveConflict is a previous report of the output folder that an article
+// contradicts or updates (see -check-archive).
+type ArchiveConflict struct {
+	// CONFLICT_CONTRADICTION or CONFLICT_UPDATE
+	Kind string `json:"kind"`
+	// Title of the previous report, its file name without extension, for the
+	// links to it, and its creation date
+	Title string `json:"title"`
+	Link  string `json:"link"`
+	Date  string `json:"date,omitempty"`
+	// What the article says instead of the report
+	Note string `json:"note"`
+}
+
+// ContradictionResult is the answer of the LLM comparing an article with the
+// previous reports.
+type ContradictionResult struct {
+	Findings []struct {
+		Report int    `json:"report"`
+		Kind   string `json:"kind"`
+		Note   string `json:"note"`
+	} `json:"findings"`
+}
+
+// ArchiveChecker compares the articles with the reports of the output folder
+// found related by their embeddings, as they were when the run started.
+type ArchiveChecker struct {
+	config  EmbeddingConfig
+	reports []Report
+	store   *EmbeddingStore
+}
+
+// NewArchiveChecker loads the reports of an output folder and their
+// embeddings, embedding those missing.
+func NewArchiveChecker(ctx context.Context, outputFolder string, permissions FilePermissions) (*ArchiveChecker, error) {
+	if !IsEmbeddingConfigured() {
+		return nil, fmt.Errorf("checking the archive needs the embeddings of the reports, set EMBEDDING_API_KEY or EMBEDDING_API_URL")
+	}
+	config, reports, store, err := LoadEmbeddedReports(ctx, outputFolder, permissions)
+	if err != nil {
+		return nil, fmt.Errorf("loading the reports to check: %w", err)
+	}
+	return &ArchiveChecker{config: config, reports: reports, store: store}, nil
+}
+
+// relatedReports returns the reports whose summary is the most similar to
+// the summary of an article, other than the reports of the article itself.
+func (c *ArchiveChecker) relatedReports(ctx context.Context, article Article) ([]Report, error) {
+	vectors, err := getEmbeddings(ctx, c.config, []string{reportEmbeddingText(article.Title, article.Summary.Summary)})
+	if err != nil {
+		return nil, fmt.Errorf("embedding article summary: %w", err)
+	}
+
+	var results []SemsearchResult
+	for _, report := range c.reports {
+		stored, ok := c.store.Entries[ReportFileName(report)]
+		if !ok || report.Url == article.Url {
+			continue
+		}
+		if score := CosineSimilarity(vectors[0], stored.Vector); score >= CONTRADICTION_MIN_SIMILARITY {
+			results = append(results, SemsearchResult{Report: report, Score: score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	var related []Report
+	for _, result := range results[:min(len(results), CONTRADICTION_RELATED_REPORTS)] {
+		related = append(related, result.Report)
+	}
+	return related, nil
+}
+
+// Check asks the LLM which of the reports related to an article the article
+// contradicts or updates. The article is not checked when this fails, the
+// report being written without the conflicts.
+func (c *ArchiveChecker) Check(ctx context.Context, provider summarize.LLMProvider, article Article) []ArchiveConflict {
+	related, err := c.relatedReports(ctx, article)
+	if err != nil {
+		slog.Warn("could not find the reports related to the article", "url", article.Url, "err", err)
+		return nil
+	}
+	if len(related) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# New article: %s\n\n%s\n", article.Title, article.Summary.Summary)
+	for _, keypoint := range article.Summary.Keypoints {
+		fmt.Fprintf(&sb, "- %s\n", keypoint)
+	}
+	for i, report := range related {
+		fmt.Fprintf(&sb, "\n# Previous report %d: %s (%s)\n\n%s\n", i+1, report.Title, report.DateCreated, report.Summary)
+		for _, keypoint := range report.Keypoints {
+			fmt.Fprintf(&sb, "- %s\n", keypoint)
+		}
+	}
+
+	var result ContradictionResult
+	_, err = summarize.CompleteJson(ctx, provider, []summarize.ChatMessage{
+		{Role: "system", Content: contradictionPrompt},
+		{Role: "user", Content: sb.String()},
+	}, &result)
+	if err != nil {
+		slog.Warn("could not compare the article with the previous reports", "url", article.Url, "err", err)
+		return nil
+	}
+
+	var conflicts []ArchiveConflict
+	for _, finding := range result.Findings {
+		kind := strings.ToLower(strings.TrimSpace(finding.Kind))
+		note := strings.TrimSpace(finding.Note)
+		if finding.Report < 1 || finding.Report > len(related) || (kind != CONFLICT_CONTRADICTION && kind != CONFLICT_UPDATE) || note == "" {
+			slog.Debug("Archive finding ignored", "url", article.Url, "report", finding.Report, "kind", finding.Kind)
+			continue
+		}
+		report := related[finding.Report-1]
+		conflicts = append(conflicts, ArchiveConflict{
+			Kind:  kind,
+			Title: report.Title,
+			Link:  strings.TrimSuffix(ReportFileName(report), ".md"),
+			Date:  report.DateCreated,
+			Note:  note,
+		})
+	}
+	slog.Debug("Archive checked", "url", article.Url, "related", len(related), "conflicts", len(conflicts))
+	return conflicts
+}
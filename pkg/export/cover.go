@@ -0,0 +1,71 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+const (
+	// The reports do not reference the cover image
+	COVER_NONE = "none"
+	// The reports reference the cover image by its url
+	COVER_LINK = "link"
+	// The cover image is downloaded next to the reports, which reference the
+	// local copy
+	COVER_DOWNLOAD = "download"
+	// Folder of the downloaded cover images, in the output folder
+	COVERS_FOLDER_NAME = "covers"
+)
+
+// ValidateCoverMode checks a cover mode, see -cover.
+func ValidateCoverMode(mode string) error {
+	if mode != COVER_NONE && mode != COVER_LINK && mode != COVER_DOWNLOAD {
+		return fmt.Errorf("unknown cover mode '%s', expected %s, %s or %s", mode, COVER_NONE, COVER_LINK, COVER_DOWNLOAD)
+	}
+	return nil
+}
+
+// ArticleCover returns the cover referenced by the reports of an article: the
+// url of its image, or the path of its downloaded copy relative to the output
+// folder, downloaded by the scraper. The image is not referenced if it cannot
+// be downloaded.
+func ArticleCover(ctx context.Context, scraper *scrape.Scraper, outputFolder string, permissions FilePermissions, article Article, mode string) string {
+	if article.Image == "" || mode == COVER_NONE {
+		return ""
+	}
+	if mode == COVER_LINK || outputFolder == "" {
+		return article.Image
+	}
+
+	coverPath, err := downloadCoverImage(ctx, scraper, outputFolder, permissions, article)
+	if err != nil {
+		slog.Warn("could not download the cover image", "url", article.Image, "err", err)
+		return ""
+	}
+	return coverPath
+}
+
+// downloadCoverImage saves the image of an article in the covers folder, and
+// returns its path relative to the output folder.
+func downloadCoverImage(ctx context.Context, scraper *scrape.Scraper, outputFolder string, permissions FilePermissions, article Article) (string, error) {
+	data, extension, err := scraper.DownloadImage(ctx, article.Image, "cover download")
+	if err != nil {
+		return "", err
+	}
+
+	coversFolder := filepath.Join(outputFolder, COVERS_FOLDER_NAME)
+	if err := permissions.Mkdir(coversFolder); err != nil {
+		return "", fmt.Errorf("creating covers folder: %w", err)
+	}
+	fileName := SanitizeFilename(article.Title) + extension
+	if err := permissions.WriteFile(filepath.Join(coversFolder, fileName), data); err != nil {
+		return "", err
+	}
+
+	// Slashes, for the note apps of every platform
+	return COVERS_FOLDER_NAME + "/" + fileName, nil
+}
@@ -0,0 +1,5 @@
+// Package export writes the reports of the summarized articles, as markdown
+// rendered with a template or in the other output formats, to an output
+// folder or to the integrations such as Notion or a webhook, and reads the
+// reports of an output folder back.
+package export
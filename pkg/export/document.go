@@ -0,0 +1,156 @@
+package export
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// ReportDocument is the structured representation of a report, shared by the
+// exporters of the formats that are not rendered from a markdown template.
+type ReportDocument struct {
+	Id          string
+	Title       string
+	Url         string
+	ContentType string
+	Author      string
+	Site        string
+	Published   string
+	DateAdded   string
+	DateCreated string
+	// Detected language of the article, as an ISO 639-1 code
+	Language string
+	// Wayback Machine snapshot the article was read from, if any
+	ArchiveUrl       string
+	Tags             []string
+	Summary          string
+	KeypointsHeading string
+	Keypoints        []string
+	// Text of the article included in the report, in paragraphs separated by
+	// blank lines, empty if not included.
+	ContentHeading string
+	Content        string
+}
+
+var keypointsHeadings = map[string]string{
+	scrape.CONTENT_TYPE_NEWS:          "What Happened",
+	scrape.CONTENT_TYPE_TUTORIAL:      "Steps",
+	scrape.CONTENT_TYPE_PAPER:         "Contributions",
+	scrape.CONTENT_TYPE_OPINION:       "Arguments",
+	scrape.CONTENT_TYPE_RELEASE_NOTES: "Changes",
+	scrape.CONTENT_TYPE_VIDEO:         "Key Moments",
+}
+
+func newReportDocument(article Article, created time.Time) ReportDocument {
+	heading, ok := keypointsHeadings[article.ContentType]
+	if !ok {
+		heading = "Key Points"
+	}
+
+	site := article.SiteName
+	if site == "" {
+		site = SiteNameFromUrl(article.Url)
+	}
+
+	doc := ReportDocument{
+		Id:               article.Id,
+		Title:            article.Title,
+		Url:              article.Url,
+		ContentType:      article.ContentType,
+		Author:           article.Author(),
+		Site:             site,
+		DateCreated:      created.Format(scrape.DATE_FORMAT),
+		ArchiveUrl:       article.ArchiveUrl,
+		Language:         article.Language,
+		Tags:             article.Summary.Tags,
+		Summary:          article.Summary.Summary,
+		KeypointsHeading: heading,
+		Keypoints:        article.Summary.Keypoints,
+	}
+	if !article.Published.IsZero() {
+		doc.Published = article.Published.Format(scrape.DATE_FORMAT)
+	}
+	if !article.Added.IsZero() {
+		doc.DateAdded = article.Added.Format(scrape.DATE_FORMAT)
+	}
+	return doc
+}
+
+// DocumentExporter renders a report document in a given file format. The
+// output encoding only applies to text formats.
+type DocumentExporter struct {
+	Extension string
+	Binary    bool
+	Render    func(doc ReportDocument) ([]byte, error)
+}
+
+const OUTPUT_FORMAT_MARKDOWN = "markdown"
+
+// documentExporters lists the output formats besides markdown, which is
+// rendered from the report templates, and the JSON formats.
+var documentExporters = map[string]DocumentExporter{
+	"asciidoc": {Extension: ".adoc", Render: renderAsciidoc},
+	"rst":      {Extension: ".rst", Render: renderRestructuredText},
+	"pdf":      {Extension: ".pdf", Binary: true, Render: renderPdf},
+	"docx":     {Extension: ".docx", Binary: true, Render: renderDocx},
+}
+
+// OutputFormats returns the output formats of the reports, markdown first.
+func OutputFormats() []string {
+	formats := []string{OUTPUT_FORMAT_MARKDOWN, OUTPUT_FORMAT_JSON, OUTPUT_FORMAT_NDJSON}
+	for format := range documentExporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats[1:])
+	return formats
+}
+
+// ParseOutputFormats parses a comma separated list of output formats.
+func ParseOutputFormats(value string) ([]string, error) {
+	var formats []string
+	for _, format := range strings.Split(value, ",") {
+		format = strings.TrimSpace(format)
+		if _, ok := documentExporters[format]; !ok && format != OUTPUT_FORMAT_MARKDOWN && format != OUTPUT_FORMAT_JSON && format != OUTPUT_FORMAT_NDJSON {
+			return nil, fmt.Errorf("unknown output format '%s', expected one of %s", format, strings.Join(OutputFormats(), ", "))
+		}
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+// ExportArticleDocument writes the report of an article in a document format,
+// such as docx or pdf, to the output folder and returns its path.
+func ExportArticleDocument(outputFolder string, permissions FilePermissions, article Article, format, includeContent string, encoding OutputEncoding) (string, error) {
+	if err := checkArticleIsComplete(article); err != nil {
+		return "", err
+	}
+
+	// The documents hold the text of the article, not its markdown
+	if includeContent == INCLUDE_CONTENT_MARKDOWN {
+		includeContent = INCLUDE_CONTENT_FULL
+	}
+	doc := newReportDocument(article, time.Now())
+	doc.ContentHeading, doc.Content = includedContent(article, includeContent)
+
+	exporter := documentExporters[format]
+	content, err := exporter.Render(doc)
+	if err != nil {
+		return "", fmt.Errorf("rendering %s document: %w", format, err)
+	}
+	if !exporter.Binary {
+		content = encoding.encode(string(content))
+	}
+
+	outputPath := filepath.Join(outputFolder, article.FileName()+exporter.Extension)
+	if err := permissions.WriteFile(outputPath, content); err != nil {
+		return "", fmt.Errorf("writing output file: %v", err)
+	}
+
+	slog.Info("Article created successfully", "path", outputPath)
+	return outputPath, nil
+}
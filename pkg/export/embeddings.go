@@ -0,0 +1,334 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const (
+	EMBEDDING_API_URL = "https://api.openai.com/v1/embeddings"
+	EMBEDDING_MODEL   = "text-embedding-3-small"
+
+	// Folder, inside the output folder, where data about the reports used to be
+	// kept before it moved to the data directory.
+	REPORT_DATA_FOLDER   = ".report"
+	EMBEDDINGS_FILE_NAME = "embeddings.json"
+)
+
+type EmbeddingRequestBody struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type EmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+type EmbeddingConfig struct {
+	ApiUrl string
+	ApiKey string
+	Model  string
+	// Maximum duration of a request, 0 for no limit
+	Timeout time.Duration
+}
+
+// IsEmbeddingConfigured tells if the environment sets an embedding endpoint.
+func IsEmbeddingConfigured() bool {
+	return os.Getenv("EMBEDDING_API_KEY") != "" || os.Getenv("EMBEDDING_API_URL") != ""
+}
+
+// GetEmbeddingConfig reads the embedding endpoint configuration from the
+// environment. Any OpenAI compatible endpoint works, including a local Ollama.
+func GetEmbeddingConfig() (EmbeddingConfig, error) {
+	config := EmbeddingConfig{
+		ApiUrl:  os.Getenv("EMBEDDING_API_URL"),
+		ApiKey:  os.Getenv("EMBEDDING_API_KEY"),
+		Model:   os.Getenv("EMBEDDING_MODEL"),
+		Timeout: summarize.DEFAULT_LLM_TIMEOUT,
+	}
+	if config.ApiUrl == "" {
+		config.ApiUrl = EMBEDDING_API_URL
+	}
+	if config.Model == "" {
+		config.Model = EMBEDDING_MODEL
+	}
+	if config.ApiKey == "" && config.ApiUrl == EMBEDDING_API_URL {
+		return EmbeddingConfig{}, fmt.Errorf("EMBEDDING_API_KEY environment variable not set")
+	}
+
+	return config, nil
+}
+
+func getEmbeddings(ctx context.Context, config EmbeddingConfig, inputs []string) ([][]float64, error) {
+	jsonData, err := json.Marshal(EmbeddingRequestBody{Model: config.Model, Input: inputs})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling JSON: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", config.ApiUrl, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if config.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+config.ApiKey)
+	}
+
+	client := &http.Client{Transport: scrape.LoggingTransport{}, Timeout: config.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+
+	var embeddingResp EmbeddingResponse
+	jsonErr := json.Unmarshal(body, &embeddingResp)
+	if jsonErr == nil && embeddingResp.Error != nil {
+		return nil, fmt.Errorf("API error: %s (Type: %s)", embeddingResp.Error.Message, embeddingResp.Error.Type)
+	}
+	// The error pages of the proxies are not JSON
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("API error: status %s", resp.Status)
+	}
+	if jsonErr != nil {
+		return nil, fmt.Errorf("unmarshaling response: %w", jsonErr)
+	}
+	if len(embeddingResp.Data) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(embeddingResp.Data))
+	}
+
+	embeddings := make([][]float64, len(inputs))
+	for _, data := range embeddingResp.Data {
+		if data.Index < 0 || data.Index >= len(inputs) {
+			return nil, fmt.Errorf("embedding index %d out of range", data.Index)
+		}
+		embeddings[data.Index] = data.Embedding
+	}
+
+	return embeddings, nil
+}
+
+type StoredEmbedding struct {
+	// Hash of the embedded text, used to detect reports edited since they were embedded.
+	Hash   string    `json:"hash"`
+	Vector []float64 `json:"vector"`
+}
+
+// EmbeddingStore holds the summary embedding of every report of an output
+// folder, keyed by report file name.
+type EmbeddingStore struct {
+	Model   string                     `json:"model"`
+	Entries map[string]StoredEmbedding `json:"entries"`
+}
+
+func embeddingStorePath(outputFolder string) (string, error) {
+	folder, err := ReportDataFolder(outputFolder)
+	if err != nil {
+		return "", fmt.Errorf("finding data folder: %w", err)
+	}
+	return filepath.Join(folder, EMBEDDINGS_FILE_NAME), nil
+}
+
+// LoadEmbeddingStore reads the embeddings of the reports of an output folder,
+// an empty store if there are none or they were made with another model.
+func LoadEmbeddingStore(outputFolder, model string) (*EmbeddingStore, error) {
+	store := &EmbeddingStore{Model: model, Entries: map[string]StoredEmbedding{}}
+
+	path, err := embeddingStorePath(outputFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading embedding store: %w", err)
+	}
+
+	var stored EmbeddingStore
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("unmarshaling embedding store: %w", err)
+	}
+	// Vectors from another model are not comparable, start over
+	if stored.Model != model || stored.Entries == nil {
+		return store, nil
+	}
+
+	return &stored, nil
+}
+
+// Save writes the store to the data folder of the output folder.
+func (s *EmbeddingStore) Save(outputFolder string, permissions FilePermissions) error {
+	path, err := embeddingStorePath(outputFolder)
+	if err != nil {
+		return err
+	}
+	if err := permissions.Mkdir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("creating data folder: %w", err)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshaling embedding store: %w", err)
+	}
+
+	if err := permissions.WriteFile(path, data); err != nil {
+		return fmt.Errorf("writing embedding store: %w", err)
+	}
+
+	return nil
+}
+
+func reportEmbeddingText(title, summary string) string {
+	return strings.TrimSpace(title) + "\n\n" + strings.TrimSpace(summary)
+}
+
+// HashEmbeddingText returns the hash of an embedded text, to tell when the
+// text of a report changed.
+func HashEmbeddingText(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// updateReportEmbeddings embeds every report that is missing from the store
+// or was modified since it was embedded. It returns true if the store changed.
+func (s *EmbeddingStore) updateReportEmbeddings(ctx context.Context, config EmbeddingConfig, reports []Report) (bool, error) {
+	const batchSize = 64
+
+	var names, texts []string
+	for _, report := range reports {
+		name := ReportFileName(report)
+		text := reportEmbeddingText(report.Title, report.Summary)
+		if stored, ok := s.Entries[name]; ok && stored.Hash == HashEmbeddingText(text) {
+			continue
+		}
+		names = append(names, name)
+		texts = append(texts, text)
+	}
+
+	for start := 0; start < len(texts); start += batchSize {
+		end := min(start+batchSize, len(texts))
+		vectors, err := getEmbeddings(ctx, config, texts[start:end])
+		if err != nil {
+			return start > 0, fmt.Errorf("embedding reports: %w", err)
+		}
+		for i, vector := range vectors {
+			s.Entries[names[start+i]] = StoredEmbedding{
+				Hash:   HashEmbeddingText(texts[start+i]),
+				Vector: vector,
+			}
+		}
+	}
+
+	return len(texts) > 0, nil
+}
+
+// LoadEmbeddedReports loads the reports of the output folder along with their
+// embeddings. Reports written before embeddings were configured, or edited by
+// hand, are embedded on the fly.
+func LoadEmbeddedReports(ctx context.Context, outputFolder string, permissions FilePermissions) (EmbeddingConfig, []Report, *EmbeddingStore, error) {
+	config, err := GetEmbeddingConfig()
+	if err != nil {
+		return EmbeddingConfig{}, nil, nil, err
+	}
+
+	reports, err := LoadReports(outputFolder)
+	if err != nil {
+		return EmbeddingConfig{}, nil, nil, err
+	}
+
+	store, err := LoadEmbeddingStore(outputFolder, config.Model)
+	if err != nil {
+		return EmbeddingConfig{}, nil, nil, err
+	}
+
+	updated, err := store.updateReportEmbeddings(ctx, config, reports)
+	if updated {
+		if saveErr := store.Save(outputFolder, permissions); saveErr != nil {
+			return EmbeddingConfig{}, nil, nil, saveErr
+		}
+	}
+	if err != nil {
+		return EmbeddingConfig{}, nil, nil, err
+	}
+
+	return config, reports, store, nil
+}
+
+// StoreArticleEmbedding embeds the summary of a freshly exported article so
+// that it is immediately available to semantic search.
+func StoreArticleEmbedding(ctx context.Context, outputFolder string, permissions FilePermissions, outputPath string, article Article) error {
+	config, err := GetEmbeddingConfig()
+	if err != nil {
+		return err
+	}
+
+	store, err := LoadEmbeddingStore(outputFolder, config.Model)
+	if err != nil {
+		return err
+	}
+
+	text := reportEmbeddingText(article.Title, article.Summary.Summary)
+	vectors, err := getEmbeddings(ctx, config, []string{text})
+	if err != nil {
+		return fmt.Errorf("embedding article summary: %w", err)
+	}
+
+	store.Entries[filepath.Base(outputPath)] = StoredEmbedding{
+		Hash:   HashEmbeddingText(text),
+		Vector: vectors[0],
+	}
+
+	return store.Save(outputFolder, permissions)
+}
+
+// CosineSimilarity returns the cosine similarity of two vectors, 0 if their
+// dimensions differ.
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
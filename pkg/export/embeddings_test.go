@@ -0,0 +1,59 @@
+package export
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetEmbeddings(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    [][]float64
+		wantErr string
+	}{
+		{"embeddings by index", http.StatusOK, `{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`, [][]float64{{1, 0}, {0, 1}}, ""},
+		{"API error", http.StatusUnauthorized, `{"error": {"message": "invalid key", "type": "auth"}}`, nil, "invalid key"},
+		{"error page", http.StatusBadGateway, `<html>Bad Gateway</html>`, nil, "502"},
+		{"error status with embeddings", http.StatusInternalServerError, `{"data": []}`, nil, "500"},
+		{"missing embedding", http.StatusOK, `{"data": [{"index": 0, "embedding": [1, 0]}]}`, nil, "expected 2 embeddings"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			embeddings, err := getEmbeddings(context.Background(), EmbeddingConfig{ApiUrl: server.URL, Model: "test"}, []string{"a", "b"})
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("getEmbeddings error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(embeddings, test.want) {
+				t.Errorf("getEmbeddings = %v, %v, want %v", embeddings, err, test.want)
+			}
+		})
+	}
+}
+
+func TestGetEmbeddingsCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := getEmbeddings(ctx, EmbeddingConfig{ApiUrl: server.URL, Model: "test"}, []string{"a"}); err == nil {
+		t.Errorf("getEmbeddings with a canceled context succeeded")
+	}
+}
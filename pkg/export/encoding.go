@@ -0,0 +1,50 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	LINE_ENDINGS_LF   = "lf"
+	LINE_ENDINGS_CRLF = "crlf"
+
+	UTF8_BOM = "\ufeff"
+)
+
+// OutputEncoding describes how text reports are written, some downstream
+// Windows tools requiring CRLF line endings or a byte order mark.
+type OutputEncoding struct {
+	CRLF bool
+	BOM  bool
+}
+
+// NewOutputEncoding returns the encoding of the text reports with line endings,
+// lf or crlf, and a byte order mark if bom is set.
+func NewOutputEncoding(lineEndings string, bom bool) (OutputEncoding, error) {
+	switch lineEndings {
+	case LINE_ENDINGS_LF:
+		return OutputEncoding{BOM: bom}, nil
+	case LINE_ENDINGS_CRLF:
+		return OutputEncoding{CRLF: true, BOM: bom}, nil
+	}
+	return OutputEncoding{}, fmt.Errorf("unknown line endings '%s', expected %s or %s", lineEndings, LINE_ENDINGS_LF, LINE_ENDINGS_CRLF)
+}
+
+// NormalizeLineEndings converts CRLF and CR line endings to LF, so that
+// templates and LLM answers render the same whatever platform they come from.
+func NormalizeLineEndings(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n")
+}
+
+func (e OutputEncoding) encode(content string) []byte {
+	content = strings.TrimPrefix(NormalizeLineEndings(content), UTF8_BOM)
+	if e.CRLF {
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	}
+	if e.BOM {
+		content = UTF8_BOM + content
+	}
+	return []byte(content)
+}
@@ -0,0 +1,46 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+
+	"github.com/brequet/report/internal/errfmt"
+)
+
+// WriteError is the failure of a file system operation on an output file.
+type WriteError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *WriteError) Error() string {
+	// The path is already part of the message, do not repeat it
+	cause := e.Err
+	var pathErr *fs.PathError
+	if errors.As(cause, &pathErr) {
+		cause = pathErr.Err
+	}
+
+	var diagnostics []string
+	var errno syscall.Errno
+	if errors.As(cause, &errno) {
+		diagnostics = append(diagnostics, fmt.Sprintf("errno %d", uintptr(errno)))
+	}
+	return errfmt.WithDiagnostics(fmt.Sprintf("%s '%s': %v", e.Op, e.Path, cause), diagnostics)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// NewWriteError returns the WriteError of an operation on a path, nil if err
+// is nil.
+func NewWriteError(op, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &WriteError{Op: op, Path: path, Err: err}
+}
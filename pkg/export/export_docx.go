@@ -0,0 +1,273 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+const (
+	docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>
+<Override PartName="/word/numbering.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"/>
+<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>
+</Types>`
+
+	docxRootRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>
+</Relationships>`
+
+	docxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:cs="Calibri"/><w:sz w:val="22"/></w:rPr></w:rPrDefault><w:pPrDefault><w:pPr><w:spacing w:after="120" w:line="276" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>
+<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/></w:style>
+<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:pPr><w:spacing w:after="240"/></w:pPr><w:rPr><w:b/><w:sz w:val="48"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:pPr><w:keepNext/><w:spacing w:before="360" w:after="120"/><w:outlineLvl w:val="0"/></w:pPr><w:rPr><w:b/><w:color w:val="2F5496"/><w:sz w:val="32"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="ListBullet"><w:name w:val="List Bullet"/><w:basedOn w:val="Normal"/><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr></w:style>
+<w:style w:type="character" w:styleId="Hyperlink"><w:name w:val="Hyperlink"/><w:rPr><w:color w:val="0563C1"/><w:u w:val="single"/></w:rPr></w:style>
+<w:style w:type="table" w:styleId="TableGrid"><w:name w:val="Table Grid"/><w:tblPr><w:tblBorders><w:top w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:left w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:bottom w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:right w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:insideH w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:insideV w:val="single" w:sz="4" w:space="0" w:color="auto"/></w:tblBorders></w:tblPr></w:style>
+</w:styles>`
+
+	docxNumbering = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:abstractNum w:abstractNumId="0"><w:lvl w:ilvl="0"><w:start w:val="1"/><w:numFmt w:val="bullet"/><w:lvlText w:val="•"/><w:lvlJc w:val="left"/><w:pPr><w:ind w:left="720" w:hanging="360"/></w:pPr></w:lvl></w:abstractNum>
+<w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num>
+</w:numbering>`
+)
+
+// InlineSegment is a piece of text with uniform formatting, as found in the
+// inline markdown of the LLM answers.
+type InlineSegment struct {
+	Text string
+	Bold bool
+	Link string
+}
+
+// parseInlineMarkdown splits text into segments of plain, bold and link text.
+// Code spans are kept as plain text.
+func parseInlineMarkdown(text string) []InlineSegment {
+	text = markdownCodeRegex.ReplaceAllString(text, "$1")
+
+	var segments []InlineSegment
+	for _, part := range splitByRegexMatches(text, markdownLinkRegex.FindAllStringSubmatchIndex(text, -1)) {
+		if part.match != nil {
+			segments = append(segments, InlineSegment{Text: text[part.match[2]:part.match[3]], Link: text[part.match[4]:part.match[5]]})
+			continue
+		}
+		for _, boldPart := range splitByRegexMatches(part.text, markdownBoldRegex.FindAllStringSubmatchIndex(part.text, -1)) {
+			if boldPart.match != nil {
+				segments = append(segments, InlineSegment{Text: part.text[boldPart.match[2]:boldPart.match[3]], Bold: true})
+			} else if boldPart.text != "" {
+				segments = append(segments, InlineSegment{Text: boldPart.text})
+			}
+		}
+	}
+	return segments
+}
+
+type regexSplitPart struct {
+	text  string
+	match []int
+}
+
+func splitByRegexMatches(text string, matches [][]int) []regexSplitPart {
+	var parts []regexSplitPart
+	position := 0
+	for _, match := range matches {
+		if match[0] > position {
+			parts = append(parts, regexSplitPart{text: text[position:match[0]]})
+		}
+		parts = append(parts, regexSplitPart{match: match})
+		position = match[1]
+	}
+	if position < len(text) {
+		parts = append(parts, regexSplitPart{text: text[position:]})
+	}
+	return parts
+}
+
+// EscapeXml escapes a text for the content or the attributes of an XML
+// document.
+func EscapeXml(text string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
+
+// docxWriter accumulates the body of a word document and the hyperlinks it
+// references.
+type docxWriter struct {
+	body  strings.Builder
+	links []string
+}
+
+func (w *docxWriter) run(text string, bold bool, style string) string {
+	var properties string
+	if style != "" {
+		properties += `<w:rStyle w:val="` + style + `"/>`
+	}
+	if bold {
+		properties += "<w:b/>"
+	}
+	if properties != "" {
+		properties = "<w:rPr>" + properties + "</w:rPr>"
+	}
+	return `<w:r>` + properties + `<w:t xml:space="preserve">` + EscapeXml(text) + `</w:t></w:r>`
+}
+
+func (w *docxWriter) runs(segments []InlineSegment) string {
+	var sb strings.Builder
+	for _, segment := range segments {
+		if segment.Link == "" {
+			sb.WriteString(w.run(segment.Text, segment.Bold, ""))
+			continue
+		}
+		w.links = append(w.links, segment.Link)
+		fmt.Fprintf(&sb, `<w:hyperlink r:id="rLink%d">%s</w:hyperlink>`, len(w.links), w.run(segment.Text, false, "Hyperlink"))
+	}
+	return sb.String()
+}
+
+func (w *docxWriter) paragraph(style string, segments []InlineSegment) {
+	w.body.WriteString("<w:p>")
+	if style != "" {
+		w.body.WriteString(`<w:pPr><w:pStyle w:val="` + style + `"/></w:pPr>`)
+	}
+	w.body.WriteString(w.runs(segments))
+	w.body.WriteString("</w:p>")
+}
+
+func (w *docxWriter) table(rows [][2][]InlineSegment) {
+	w.body.WriteString(`<w:tbl><w:tblPr><w:tblStyle w:val="TableGrid"/><w:tblW w:w="5000" w:type="pct"/></w:tblPr><w:tblGrid><w:gridCol w:w="2400"/><w:gridCol w:w="6600"/></w:tblGrid>`)
+	for _, row := range rows {
+		w.body.WriteString("<w:tr>")
+		for i, cell := range row {
+			shading := ""
+			if i == 0 {
+				shading = `<w:shd w:val="clear" w:color="auto" w:fill="EBEBEB"/>`
+			}
+			fmt.Fprintf(&w.body, `<w:tc><w:tcPr>%s</w:tcPr><w:p>%s</w:p></w:tc>`, shading, w.runs(cell))
+		}
+		w.body.WriteString("</w:tr>")
+	}
+	w.body.WriteString("</w:tbl>")
+}
+
+func renderDocx(doc ReportDocument) ([]byte, error) {
+	w := &docxWriter{}
+	text := func(value string) []InlineSegment {
+		return []InlineSegment{{Text: value}}
+	}
+	bold := func(value string) []InlineSegment {
+		return []InlineSegment{{Text: value, Bold: true}}
+	}
+
+	w.paragraph("Title", text(doc.Title))
+	rows := [][2][]InlineSegment{
+		{bold("URL"), {{Text: doc.Url, Link: doc.Url}}},
+		{bold("Site"), text(doc.Site)},
+	}
+	if doc.Author != "" {
+		rows = append(rows, [2][]InlineSegment{bold("Author"), text(doc.Author)})
+	}
+	if doc.Published != "" {
+		rows = append(rows, [2][]InlineSegment{bold("Published"), text(doc.Published)})
+	}
+	if doc.ArchiveUrl != "" {
+		rows = append(rows, [2][]InlineSegment{bold("Archived copy"), {{Text: doc.ArchiveUrl, Link: doc.ArchiveUrl}}})
+	}
+	if doc.DateAdded != "" {
+		rows = append(rows, [2][]InlineSegment{bold("Date added"), text(doc.DateAdded)})
+	}
+	if doc.Language != "" {
+		rows = append(rows, [2][]InlineSegment{bold("Language"), text(scrape.LanguageName(doc.Language))})
+	}
+	rows = append(rows,
+		[2][]InlineSegment{bold("Content type"), text(doc.ContentType)},
+		[2][]InlineSegment{bold("Date created"), text(doc.DateCreated)},
+		[2][]InlineSegment{bold("Tags"), text(strings.Join(doc.Tags, ", "))},
+		[2][]InlineSegment{bold("ID"), text(doc.Id)},
+	)
+	w.table(rows)
+
+	w.paragraph("Heading1", text("Summary"))
+	for _, paragraph := range strings.Split(doc.Summary, "\n") {
+		if strings.TrimSpace(paragraph) != "" {
+			w.paragraph("", parseInlineMarkdown(paragraph))
+		}
+	}
+
+	w.paragraph("Heading1", text(doc.KeypointsHeading))
+	for _, keypoint := range doc.Keypoints {
+		w.paragraph("ListBullet", parseInlineMarkdown(keypoint))
+	}
+
+	if doc.Content != "" {
+		w.paragraph("Heading1", text(doc.ContentHeading))
+		for _, paragraph := range strings.Split(doc.Content, "\n\n") {
+			w.paragraph("", text(paragraph))
+		}
+	}
+
+	document := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body>` +
+		w.body.String() +
+		`<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="708" w:footer="708" w:gutter="0"/></w:sectPr></w:body></w:document>`
+
+	var relationships strings.Builder
+	relationships.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+<Relationship Id="rNumbering" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering" Target="numbering.xml"/>
+`)
+	for i, link := range w.links {
+		fmt.Fprintf(&relationships, `<Relationship Id="rLink%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="%s" TargetMode="External"/>`+"\n", i+1, EscapeXml(link))
+	}
+	relationships.WriteString("</Relationships>")
+
+	core := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+<dc:title>%s</dc:title>
+<dc:subject>%s</dc:subject>
+<cp:keywords>%s</cp:keywords>
+<dc:creator>report</dc:creator>
+<dcterms:created xsi:type="dcterms:W3CDTF">%s</dcterms:created>
+</cp:coreProperties>`, EscapeXml(doc.Title), EscapeXml(doc.Url), EscapeXml(strings.Join(doc.Tags, ", ")), time.Now().UTC().Format(time.RFC3339))
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxRootRelationships},
+		{"word/document.xml", document},
+		{"word/styles.xml", docxStyles},
+		{"word/numbering.xml", docxNumbering},
+		{"word/_rels/document.xml.rels", relationships.String()},
+		{"docProps/core.xml", core},
+	}
+	for _, part := range parts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,170 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// readDocx opens a DOCX document and returns its parts by name, checking that
+// each XML part is well-formed.
+func readDocx(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("opening DOCX: %v", err)
+	}
+	parts := map[string]string{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("reading %s: %v", file.Name, err)
+		}
+		decoder := xml.NewDecoder(bytes.NewReader(content))
+		for {
+			if _, err := decoder.Token(); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well-formed XML: %v", file.Name, err)
+			}
+		}
+		parts[file.Name] = string(content)
+	}
+	return parts
+}
+
+// docxText returns the texts of the runs of a document, a line per paragraph
+// starting with its style.
+func docxText(t *testing.T, document string) string {
+	t.Helper()
+	var lines []string
+	inText := false
+	decoder := xml.NewDecoder(strings.NewReader(document))
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return strings.Join(lines, "\n")
+		}
+		if err != nil {
+			t.Fatalf("decoding document.xml: %v", err)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			switch token.Name.Local {
+			case "p":
+				lines = append(lines, ": ")
+			case "pStyle":
+				for _, attr := range token.Attr {
+					if attr.Name.Local == "val" {
+						lines[len(lines)-1] = attr.Value + lines[len(lines)-1]
+					}
+				}
+			case "t":
+				inText = true
+			}
+		case xml.EndElement:
+			inText = inText && token.Name.Local != "t"
+		case xml.CharData:
+			if inText {
+				lines[len(lines)-1] += string(token)
+			}
+		}
+	}
+}
+
+func TestRenderDocx(t *testing.T) {
+	doc := ReportDocument{
+		Id:               "01HXAAAAAAAAAAAAAAAAAAAAAA",
+		Title:            "Tom & Jerry <3\x0b",
+		Url:              "https://example.com/a?b=1&c=2",
+		Site:             "Example",
+		Language:         "fr",
+		ContentType:      scrape.CONTENT_TYPE_NEWS,
+		DateCreated:      "2024-06-02",
+		Tags:             []string{"cartoon", "cats"},
+		Summary:          "First **bold** paragraph.\n\nSecond with a [link](https://example.com/link).",
+		KeypointsHeading: "What Happened",
+		Keypoints:        []string{"One", "Two"},
+		ContentHeading:   "Content",
+		Content:          "Paragraph one.\n\nParagraph two.",
+	}
+	data, err := renderDocx(doc)
+	if err != nil {
+		t.Fatalf("renderDocx: %v", err)
+	}
+	parts := readDocx(t, data)
+
+	names := make([]string, 0, len(parts))
+	for name := range parts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	wantNames := []string{"[Content_Types].xml", "_rels/.rels", "docProps/core.xml", "word/_rels/document.xml.rels", "word/document.xml", "word/numbering.xml", "word/styles.xml"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("DOCX parts = %v, want %v", names, wantNames)
+	}
+
+	// Every part but the relationships has a content type
+	for _, name := range names {
+		if strings.HasSuffix(name, ".rels") || name == "[Content_Types].xml" {
+			continue
+		}
+		if !strings.Contains(parts["[Content_Types].xml"], `PartName="/`+name+`"`) {
+			t.Errorf("no content type for %s", name)
+		}
+	}
+
+	// Every relationship used by the document is declared
+	relationships := parts["word/_rels/document.xml.rels"]
+	for _, match := range regexp.MustCompile(`r:id="([^"]+)"`).FindAllStringSubmatch(parts["word/document.xml"], -1) {
+		if !strings.Contains(relationships, `Id="`+match[1]+`"`) {
+			t.Errorf("relationship %s of the document is not declared", match[1])
+		}
+	}
+	for _, link := range []string{"https://example.com/a?b=1&amp;c=2", "https://example.com/link"} {
+		if !strings.Contains(relationships, `Target="`+link+`" TargetMode="External"`) {
+			t.Errorf("no relationship to %s", link)
+		}
+	}
+
+	want := strings.Join([]string{
+		"Title: Tom & Jerry <3�",
+		// The cells of the table of the details
+		": URL", ": https://example.com/a?b=1&c=2",
+		": Site", ": Example",
+		": Language", ": French",
+		": Content type", ": news",
+		": Date created", ": 2024-06-02",
+		": Tags", ": cartoon, cats",
+		": ID", ": 01HXAAAAAAAAAAAAAAAAAAAAAA",
+		"Heading1: Summary",
+		": First bold paragraph.",
+		": Second with a link.",
+		"Heading1: What Happened",
+		"ListBullet: One",
+		"ListBullet: Two",
+		"Heading1: Content",
+		": Paragraph one.",
+		": Paragraph two.",
+	}, "\n")
+	if text := docxText(t, parts["word/document.xml"]); text != want {
+		t.Errorf("document text =\n%s\nwant\n%s", text, want)
+	}
+	if !strings.Contains(parts["docProps/core.xml"], "<dc:title>Tom &amp; Jerry &lt;3�</dc:title>") {
+		t.Errorf("core properties = %s, want the escaped title", parts["docProps/core.xml"])
+	}
+}
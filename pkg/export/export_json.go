@@ -0,0 +1,147 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const (
+	// One JSON file per article, named after its title
+	OUTPUT_FORMAT_JSON = "json"
+	// One JSON line per article, appended to NDJSON_FILE_NAME, for batches
+	OUTPUT_FORMAT_NDJSON = "ndjson"
+	NDJSON_FILE_NAME     = "reports.ndjson"
+)
+
+// JsonReport is the report of an article in the JSON output formats, holding
+// everything known about the article, for jq or a database.
+type JsonReport struct {
+	Id          string   `json:"id"`
+	Url         string   `json:"url"`
+	Title       string   `json:"title"`
+	Aliases     []string `json:"aliases,omitempty"`
+	ContentType string   `json:"content_type"`
+	Authors     []string `json:"authors,omitempty"`
+	SiteName    string   `json:"site_name,omitempty"`
+	// Publication time of the article, absent if the page does not give it
+	Published *time.Time `json:"published,omitempty"`
+	Summary   string     `json:"summary"`
+	Keypoints []string   `json:"keypoints"`
+	Tags      []string   `json:"tags"`
+	// Url of the image representing the article, and the cover referenced
+	// by the reports
+	Image string `json:"image,omitempty"`
+	Cover string `json:"cover,omitempty"`
+	// Descriptions of the key images of the article, see -describe-images
+	Images []summarize.ImageDescription `json:"images,omitempty"`
+	// Previous reports the article contradicts or updates, see -check-archive
+	Conflicts []ArchiveConflict `json:"conflicts,omitempty"`
+	// Data tables of the article, see -tables
+	Tables []scrape.DataTable `json:"tables,omitempty"`
+	// Wayback Machine snapshot the article was read from, if any
+	ArchiveUrl string `json:"archive_url,omitempty"`
+	// Time the article was added to the reading list it was imported from
+	Added *time.Time `json:"added,omitempty"`
+	// Detected language of the article, as an ISO 639-1 code
+	Language string `json:"language,omitempty"`
+	// Length of a video, in seconds
+	Duration int `json:"duration,omitempty"`
+	// Lede of the article, when the reports include an excerpt
+	Excerpt string `json:"excerpt,omitempty"`
+	// Text content of the page, in paragraphs separated by blank lines
+	Content string         `json:"content"`
+	Usage   JsonTokenUsage `json:"usage"`
+	Created time.Time      `json:"created"`
+}
+
+// JsonTokenUsage is the number of tokens consumed by the LLM calls summarizing
+// the article, 0 when the providers do not report it.
+type JsonTokenUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	// Estimated cost in USD, 0 for the models without a known price
+	Cost float64 `json:"cost"`
+}
+
+func newJsonReport(article Article, usage summarize.Usage, created time.Time) JsonReport {
+	report := JsonReport{
+		Id:          article.Id,
+		Url:         article.Url,
+		Title:       article.Title,
+		Aliases:     article.Aliases,
+		ContentType: article.ContentType,
+		Authors:     article.Authors,
+		SiteName:    article.SiteName,
+		Summary:     article.Summary.Summary,
+		Keypoints:   article.Summary.Keypoints,
+		Tags:        article.Summary.Tags,
+		Image:       article.Image,
+		Cover:       article.Cover,
+		Images:      article.ImageDescriptions,
+		Conflicts:   article.Conflicts,
+		Tables:      article.Tables,
+		ArchiveUrl:  article.ArchiveUrl,
+		Excerpt:     article.Excerpt,
+		Language:    article.Language,
+		Duration:    int(article.Duration.Seconds()),
+		Content:     article.Content,
+		Usage:       JsonTokenUsage{InputTokens: usage.InputTokens, OutputTokens: usage.OutputTokens, Cost: usage.Cost},
+		Created:     created,
+	}
+	if !article.Published.IsZero() {
+		report.Published = &article.Published
+	}
+	if !article.Added.IsZero() {
+		report.Added = &article.Added
+	}
+	return report
+}
+
+// ExportArticleJson writes the JSON report of an article, to its own file or
+// appended to the NDJSON file of the output folder.
+func ExportArticleJson(outputFolder string, permissions FilePermissions, article Article, format string, usage summarize.Usage) (string, error) {
+	if err := checkArticleIsComplete(article); err != nil {
+		return "", err
+	}
+
+	report := newJsonReport(article, usage, time.Now())
+
+	if format == OUTPUT_FORMAT_NDJSON {
+		line, err := json.Marshal(report)
+		if err != nil {
+			return "", fmt.Errorf("encoding JSON report: %w", err)
+		}
+
+		outputPath := filepath.Join(outputFolder, NDJSON_FILE_NAME)
+		file, err := permissions.appendFile(outputPath)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		if _, err := file.Write(append(line, '\n')); err != nil {
+			return "", NewWriteError("writing", outputPath, err)
+		}
+
+		slog.Info("Article appended successfully", "path", outputPath)
+		return outputPath, nil
+	}
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding JSON report: %w", err)
+	}
+
+	outputPath := filepath.Join(outputFolder, article.FileName()+".json")
+	if err := permissions.WriteFile(outputPath, append(content, '\n')); err != nil {
+		return "", fmt.Errorf("writing output file: %v", err)
+	}
+
+	slog.Info("Article created successfully", "path", outputPath)
+	return outputPath, nil
+}
@@ -0,0 +1,147 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	markdownBoldRegex = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	markdownCodeRegex = regexp.MustCompile("`([^`]+)`")
+	markdownLinkRegex = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+)
+
+// markdownToAsciidoc converts the inline markdown the LLM uses in its answers
+// (bold, code and links) to AsciiDoc.
+func markdownToAsciidoc(text string) string {
+	text = markdownLinkRegex.ReplaceAllString(text, "$2[$1]")
+	text = markdownBoldRegex.ReplaceAllString(text, "*$1*")
+	return text
+}
+
+func renderAsciidoc(doc ReportDocument) ([]byte, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "= %s\n", doc.Title)
+	fmt.Fprintf(&sb, ":id: %s\n", doc.Id)
+	fmt.Fprintf(&sb, ":url: %s\n", doc.Url)
+	fmt.Fprintf(&sb, ":content-type: %s\n", doc.ContentType)
+	if doc.Author != "" {
+		fmt.Fprintf(&sb, ":author: %s\n", doc.Author)
+	}
+	fmt.Fprintf(&sb, ":site: %s\n", doc.Site)
+	if doc.Published != "" {
+		fmt.Fprintf(&sb, ":published: %s\n", doc.Published)
+	}
+	if doc.ArchiveUrl != "" {
+		fmt.Fprintf(&sb, ":archive-url: %s\n", doc.ArchiveUrl)
+	}
+	if doc.DateAdded != "" {
+		fmt.Fprintf(&sb, ":date-added: %s\n", doc.DateAdded)
+	}
+	if doc.Language != "" {
+		fmt.Fprintf(&sb, ":lang: %s\n", doc.Language)
+	}
+	fmt.Fprintf(&sb, ":date-created: %s\n", doc.DateCreated)
+	fmt.Fprintf(&sb, ":last-consulted: %s\n", doc.DateCreated)
+	fmt.Fprintf(&sb, ":tags: %s\n", strings.Join(doc.Tags, ", "))
+
+	fmt.Fprintf(&sb, "\n%s[Source]\n", doc.Url)
+	fmt.Fprintf(&sb, "\n== Summary\n\n%s\n", markdownToAsciidoc(doc.Summary))
+	fmt.Fprintf(&sb, "\n== %s\n\n", doc.KeypointsHeading)
+	for _, keypoint := range doc.Keypoints {
+		fmt.Fprintf(&sb, "* %s\n", markdownToAsciidoc(keypoint))
+	}
+	if doc.Content != "" {
+		fmt.Fprintf(&sb, "\n== %s\n\n%s\n", doc.ContentHeading, doc.Content)
+	}
+
+	return []byte(sb.String()), nil
+}
+
+// markdownToRestructuredText converts the inline markdown the LLM uses in its
+// answers (bold, code and links) to reStructuredText.
+func markdownToRestructuredText(text string) string {
+	text = markdownCodeRegex.ReplaceAllString(text, "``$1``")
+	text = markdownLinkRegex.ReplaceAllString(text, "`$1 <$2>`__")
+	return text
+}
+
+func restructuredTextHeading(title string, underline rune, overline bool) string {
+	line := strings.Repeat(string(underline), utf8.RuneCountInString(title))
+	if overline {
+		return line + "\n" + title + "\n" + line + "\n"
+	}
+	return title + "\n" + line + "\n"
+}
+
+func renderRestructuredText(doc ReportDocument) ([]byte, error) {
+	var sb strings.Builder
+	sb.WriteString(restructuredTextHeading(doc.Title, '=', true))
+	fmt.Fprintf(&sb, "\n:id: %s\n", doc.Id)
+	fmt.Fprintf(&sb, ":url: %s\n", doc.Url)
+	fmt.Fprintf(&sb, ":content_type: %s\n", doc.ContentType)
+	if doc.Author != "" {
+		fmt.Fprintf(&sb, ":author: %s\n", doc.Author)
+	}
+	fmt.Fprintf(&sb, ":site: %s\n", doc.Site)
+	if doc.Published != "" {
+		fmt.Fprintf(&sb, ":published: %s\n", doc.Published)
+	}
+	if doc.ArchiveUrl != "" {
+		fmt.Fprintf(&sb, ":archive_url: %s\n", doc.ArchiveUrl)
+	}
+	if doc.DateAdded != "" {
+		fmt.Fprintf(&sb, ":date_added: %s\n", doc.DateAdded)
+	}
+	if doc.Language != "" {
+		fmt.Fprintf(&sb, ":language: %s\n", doc.Language)
+	}
+	fmt.Fprintf(&sb, ":date_created: %s\n", doc.DateCreated)
+	fmt.Fprintf(&sb, ":last_consulted: %s\n", doc.DateCreated)
+	fmt.Fprintf(&sb, ":tags: %s\n", strings.Join(doc.Tags, ", "))
+
+	sb.WriteString("\n" + restructuredTextHeading("Summary", '=', false))
+	fmt.Fprintf(&sb, "\n%s\n", markdownToRestructuredText(doc.Summary))
+	sb.WriteString("\n" + restructuredTextHeading(doc.KeypointsHeading, '=', false) + "\n")
+	for _, keypoint := range doc.Keypoints {
+		fmt.Fprintf(&sb, "- %s\n", markdownToRestructuredText(keypoint))
+	}
+	if doc.Content != "" {
+		sb.WriteString("\n" + restructuredTextHeading(doc.ContentHeading, '=', false))
+		fmt.Fprintf(&sb, "\n%s\n", doc.Content)
+	}
+
+	return []byte(sb.String()), nil
+}
+
+// markdownToHtml converts the inline markdown the LLM uses in its answers
+// (bold, code and links) to HTML.
+func markdownToHtml(text string) string {
+	text = html.EscapeString(text)
+	text = markdownCodeRegex.ReplaceAllString(text, "<code>$1</code>")
+	text = markdownLinkRegex.ReplaceAllString(text, `<a href="$2">$1</a>`)
+	text = markdownBoldRegex.ReplaceAllString(text, "<strong>$1</strong>")
+	return text
+}
+
+// ReportToHtml renders the summary and the keypoints of a report as an HTML
+// fragment.
+func ReportToHtml(summary string, keypoints []string) string {
+	var sb strings.Builder
+	for _, paragraph := range strings.Split(summary, "\n") {
+		if strings.TrimSpace(paragraph) != "" {
+			fmt.Fprintf(&sb, "<p>%s</p>", markdownToHtml(paragraph))
+		}
+	}
+	if len(keypoints) > 0 {
+		sb.WriteString("<ul>")
+		for _, keypoint := range keypoints {
+			fmt.Fprintf(&sb, "<li>%s</li>", markdownToHtml(keypoint))
+		}
+		sb.WriteString("</ul>")
+	}
+	return sb.String()
+}
@@ -0,0 +1,374 @@
+package export
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/jung-kurt/gofpdf"
+)
+
+const (
+	PDF_FONT        = "DejaVu"
+	PDF_LINE_HEIGHT = 6
+	// Width of the labels of the tables
+	PDF_LABEL_WIDTH = 40.0
+)
+
+var (
+	// Layout of the PDF briefs, a line per block: "% " lines make the title
+	// page, "# " lines are headings, "| label | value |" lines the rows of a
+	// table, the rows without a value being left out, "- " lines the items of
+	// a list, and the other lines paragraphs, separated by blank lines. The
+	// values of the report are only read as text, see pdfContent.
+	//go:embed pdf-template.md
+	pdfTemplate string
+
+	// DejaVu Sans Condensed, whose glyphs cover the scripts of most languages,
+	// the core fonts of PDF only covering the cp1252 code page
+	//go:embed fonts/DejaVuSansCondensed.ttf
+	pdfFontRegular []byte
+	//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+	pdfFontBold []byte
+	//go:embed fonts/DejaVuSansCondensed-Oblique.ttf
+	pdfFontItalic []byte
+)
+
+var pdfHtmlEscaper = strings.NewReplacer("<", "‹", ">", "›")
+
+// pdfText replaces the characters the PDF library cannot write, the ones
+// outside of the basic multilingual plane such as the emojis.
+func pdfText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r > 0xffff {
+			return '\ufffd'
+		}
+		return r
+	}, text)
+}
+
+// markdownToPdfHtml converts the inline markdown the LLM uses in its answers to
+// the basic HTML subset understood by the PDF library (bold and links).
+func markdownToPdfHtml(text string) string {
+	text = pdfHtmlEscaper.Replace(text)
+	text = markdownLinkRegex.ReplaceAllString(text, `<a href="$2">$1</a>`)
+	text = markdownBoldRegex.ReplaceAllString(text, "<b>$1</b>")
+	text = markdownCodeRegex.ReplaceAllString(text, "$1")
+	return text
+}
+
+// PdfBlock is a block of the layout of a PDF brief, read from the lines of
+// its template.
+type PdfBlock struct {
+	// One of the PDF_BLOCK_* kinds
+	Kind string
+	Text string
+	// Label of a table row
+	Label string
+}
+
+const (
+	PDF_BLOCK_TITLE     = "title"
+	PDF_BLOCK_HEADING   = "heading"
+	PDF_BLOCK_ROW       = "row"
+	PDF_BLOCK_ITEM      = "item"
+	PDF_BLOCK_PARAGRAPH = "paragraph"
+)
+
+// Character around the references to the content in the rendered PDF
+// template, a private use character
+const PDF_CONTENT_MARK = '\ue000'
+
+var pdfContentRefRegex = regexp.MustCompile(string(PDF_CONTENT_MARK) + `(\d+)` + string(PDF_CONTENT_MARK))
+
+// pdfContent holds the values of a report while its PDF template is rendered,
+// the template only getting references to them: the text of the article and
+// of its summary is never read as layout, whatever its lines look like.
+type pdfContent struct {
+	values []string
+}
+
+// ref returns the reference to a value, empty for an empty value so that the
+// conditions of the template still apply.
+func (c *pdfContent) ref(value string) string {
+	value = strings.ReplaceAll(value, string(PDF_CONTENT_MARK), "")
+	if value == "" {
+		return ""
+	}
+	c.values = append(c.values, value)
+	return fmt.Sprintf("%c%d%c", PDF_CONTENT_MARK, len(c.values)-1, PDF_CONTENT_MARK)
+}
+
+func (c *pdfContent) refs(values []string) []string {
+	refs := make([]string, len(values))
+	for i, value := range values {
+		refs[i] = c.ref(value)
+	}
+	return refs
+}
+
+// resolve replaces the references of a text of the layout by their values.
+func (c *pdfContent) resolve(text string) string {
+	return pdfContentRefRegex.ReplaceAllStringFunc(text, func(ref string) string {
+		i, err := strconv.Atoi(strings.Trim(ref, string(PDF_CONTENT_MARK)))
+		if err != nil || i >= len(c.values) {
+			return ""
+		}
+		return c.values[i]
+	})
+}
+
+// document returns the report with its values replaced by references.
+func (c *pdfContent) document(doc ReportDocument) ReportDocument {
+	return ReportDocument{
+		Id:               c.ref(doc.Id),
+		Title:            c.ref(doc.Title),
+		Url:              c.ref(doc.Url),
+		ContentType:      c.ref(doc.ContentType),
+		Author:           c.ref(doc.Author),
+		Site:             c.ref(doc.Site),
+		Published:        c.ref(doc.Published),
+		DateAdded:        c.ref(doc.DateAdded),
+		DateCreated:      c.ref(doc.DateCreated),
+		Language:         c.ref(doc.Language),
+		ArchiveUrl:       c.ref(doc.ArchiveUrl),
+		Tags:             c.refs(doc.Tags),
+		Summary:          c.ref(doc.Summary),
+		KeypointsHeading: c.ref(doc.KeypointsHeading),
+		Keypoints:        c.refs(doc.Keypoints),
+		ContentHeading:   c.ref(doc.ContentHeading),
+		Content:          c.ref(doc.Content),
+	}
+}
+
+// funcs are the functions of the PDF template, reading the values of the
+// references they are given.
+func (c *pdfContent) funcs() template.FuncMap {
+	return template.FuncMap{
+		"join": func(refs []string, separator string) string {
+			values := make([]string, len(refs))
+			for i, ref := range refs {
+				values[i] = c.resolve(ref)
+			}
+			return c.ref(strings.Join(values, separator))
+		},
+		"languageName": func(ref string) string {
+			return c.ref(scrape.LanguageName(c.resolve(ref)))
+		},
+	}
+}
+
+// pdfLayout renders the PDF template with the values of a report and returns
+// the blocks of the brief.
+func pdfLayout(doc ReportDocument) ([]PdfBlock, error) {
+	content := &pdfContent{}
+	tmpl, err := template.New("pdf").Funcs(content.funcs()).Parse(pdfTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing PDF template: %w", err)
+	}
+	var layout strings.Builder
+	if err := tmpl.Execute(&layout, content.document(doc)); err != nil {
+		return nil, fmt.Errorf("rendering PDF template: %w", err)
+	}
+	return parsePdfLayout(layout.String(), content.resolve), nil
+}
+
+// parsePdfLayout reads the blocks of the rendered PDF template, the text of
+// the blocks being resolved once their kind is known. The lines of a
+// paragraph are joined, and the blank lines of the paragraphs of the content
+// split them; the blocks left empty are dropped.
+func parsePdfLayout(layout string, resolve func(string) string) []PdfBlock {
+	var blocks []PdfBlock
+	var paragraph []string
+	add := func(kind, label, text string) {
+		text = resolve(text)
+		if kind == PDF_BLOCK_PARAGRAPH {
+			for _, part := range splitParagraphs(text) {
+				blocks = append(blocks, PdfBlock{Kind: kind, Text: strings.Join(strings.Fields(part), " ")})
+			}
+			return
+		}
+		if text = strings.Join(strings.Fields(text), " "); text != "" {
+			blocks = append(blocks, PdfBlock{Kind: kind, Label: resolve(label), Text: text})
+		}
+	}
+	endParagraph := func() {
+		if len(paragraph) > 0 {
+			add(PDF_BLOCK_PARAGRAPH, "", strings.Join(paragraph, "\n"))
+			paragraph = nil
+		}
+	}
+	for _, line := range strings.Split(layout, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			endParagraph()
+		case line == "%" || strings.HasPrefix(line, "% "):
+			endParagraph()
+			add(PDF_BLOCK_TITLE, "", line[1:])
+		case line == "#" || strings.HasPrefix(line, "# "):
+			endParagraph()
+			add(PDF_BLOCK_HEADING, "", line[1:])
+		case line == "-" || strings.HasPrefix(line, "- "):
+			endParagraph()
+			add(PDF_BLOCK_ITEM, "", line[1:])
+		case strings.HasPrefix(line, "|") && strings.HasSuffix(line, "|") && strings.Contains(line[1:len(line)-1], "|"):
+			endParagraph()
+			label, value, _ := strings.Cut(strings.TrimSuffix(line[1:], "|"), "|")
+			add(PDF_BLOCK_ROW, strings.TrimSpace(label), value)
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	endParagraph()
+	return blocks
+}
+
+// splitParagraphs splits a text at its blank lines.
+func splitParagraphs(text string) []string {
+	var paragraphs []string
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+			continue
+		}
+		if len(lines) > 0 {
+			paragraphs = append(paragraphs, strings.Join(lines, "\n"))
+			lines = nil
+		}
+	}
+	if len(lines) > 0 {
+		paragraphs = append(paragraphs, strings.Join(lines, "\n"))
+	}
+	return paragraphs
+}
+
+func renderPdf(doc ReportDocument) ([]byte, error) {
+	blocks, err := pdfLayout(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(PDF_FONT, "", pdfFontRegular)
+	pdf.AddUTF8FontFromBytes(PDF_FONT, "B", pdfFontBold)
+	pdf.AddUTF8FontFromBytes(PDF_FONT, "I", pdfFontItalic)
+	if err := pdf.Error(); err != nil {
+		return nil, fmt.Errorf("loading PDF fonts: %w", err)
+	}
+	pdf.SetTitle(pdfText(doc.Title), true)
+	pdf.SetSubject(doc.Url, true)
+	pdf.SetKeywords(strings.Join(doc.Tags, " "), true)
+	pdf.SetCreator("report", true)
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	html := pdf.HTMLBasicNew()
+
+	var titlePage []string
+	for i := range blocks {
+		blocks[i].Label, blocks[i].Text = pdfText(blocks[i].Label), pdfText(blocks[i].Text)
+	}
+	for len(blocks) > 0 && blocks[0].Kind == PDF_BLOCK_TITLE {
+		titlePage = append(titlePage, blocks[0].Text)
+		blocks = blocks[1:]
+	}
+
+	pdf.SetFooterFunc(func() {
+		if len(titlePage) > 0 && pdf.PageNo() == 1 {
+			return
+		}
+		page := pdf.PageNo()
+		if len(titlePage) > 0 {
+			page--
+		}
+		pdf.SetY(-15)
+		pdf.SetFont(PDF_FONT, "I", 8)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.CellFormat(0, 10, fmt.Sprintf("%s - %d", pdfText(doc.Title), page), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	pageWidth, pageHeight := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	contentWidth := pageWidth - left - right
+
+	// Title page: the title, then its subtitles
+	if len(titlePage) > 0 {
+		pdf.AddPage()
+		pdf.SetFont(PDF_FONT, "B", 26)
+		titleLines := pdf.SplitText(titlePage[0], contentWidth)
+		pdf.SetY(pageHeight/3 - float64(len(titleLines))*12/2)
+		pdf.MultiCell(0, 12, titlePage[0], "", "C", false)
+		pdf.Ln(8)
+		pdf.SetFont(PDF_FONT, "", 12)
+		pdf.SetTextColor(96, 96, 96)
+		for _, subtitle := range titlePage[1:] {
+			pdf.MultiCell(0, PDF_LINE_HEIGHT, subtitle, "", "C", false)
+		}
+		pdf.SetTextColor(0, 0, 0)
+	}
+
+	pdf.AddPage()
+	previous := ""
+	for _, block := range blocks {
+		// Space between a table or a list and the next block
+		if previous != block.Kind && (previous == PDF_BLOCK_ROW || previous == PDF_BLOCK_ITEM) {
+			pdf.Ln(6)
+		}
+		switch block.Kind {
+		case PDF_BLOCK_TITLE, PDF_BLOCK_HEADING:
+			writePdfHeading(pdf, block.Text)
+		case PDF_BLOCK_ROW:
+			writePdfRow(pdf, block.Label, block.Text, contentWidth)
+		case PDF_BLOCK_ITEM:
+			pdf.SetFont(PDF_FONT, "", 11)
+			pdf.Write(PDF_LINE_HEIGHT, "• ")
+			pdf.SetLeftMargin(left + 5)
+			html.Write(PDF_LINE_HEIGHT, markdownToPdfHtml(block.Text))
+			pdf.SetLeftMargin(left)
+			pdf.Ln(PDF_LINE_HEIGHT + 2)
+		case PDF_BLOCK_PARAGRAPH:
+			pdf.SetFont(PDF_FONT, "", 11)
+			html.Write(PDF_LINE_HEIGHT, markdownToPdfHtml(block.Text))
+			pdf.Ln(PDF_LINE_HEIGHT + 4)
+		}
+		previous = block.Kind
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writePdfHeading(pdf *gofpdf.Fpdf, heading string) {
+	if pdf.GetY() > 30 {
+		pdf.Ln(4)
+	}
+	pdf.SetFont(PDF_FONT, "B", 16)
+	pdf.CellFormat(0, 10, heading, "B", 1, "L", false, 0, "")
+	pdf.Ln(4)
+}
+
+// writePdfRow writes a row of a table, its value wrapped over as many lines
+// as needed.
+func writePdfRow(pdf *gofpdf.Fpdf, label, value string, contentWidth float64) {
+	pdf.SetFillColor(235, 235, 235)
+	pdf.SetFont(PDF_FONT, "", 11)
+	valueLines := pdf.SplitText(value, contentWidth-PDF_LABEL_WIDTH-2)
+	rowHeight := float64(max(1, len(valueLines))) * PDF_LINE_HEIGHT
+
+	x, y := pdf.GetXY()
+	pdf.SetFont(PDF_FONT, "B", 11)
+	pdf.CellFormat(PDF_LABEL_WIDTH, rowHeight, label, "1", 0, "L", true, 0, "")
+	pdf.SetFont(PDF_FONT, "", 11)
+	pdf.SetXY(x+PDF_LABEL_WIDTH, y)
+	pdf.MultiCell(contentWidth-PDF_LABEL_WIDTH, PDF_LINE_HEIGHT, value, "1", "L", false)
+}
@@ -0,0 +1,85 @@
+package export
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestPdfLayout(t *testing.T) {
+	doc := ReportDocument{
+		Id:               "01HXAAAAAAAAAAAAAAAAAAAAAA",
+		Title:            "An article",
+		Url:              "https://example.com/article",
+		Site:             "Example",
+		DateCreated:      "2024-06-02",
+		Language:         "fr",
+		Tags:             []string{"go", "pdf"},
+		Summary:          "# Not a heading\n- not an item\n| not | a row |\n\n% not a title either",
+		KeypointsHeading: "Key Points",
+		Keypoints:        []string{"First point", "Second point\n# still the second point"},
+	}
+	want := []PdfBlock{
+		{Kind: PDF_BLOCK_TITLE, Text: "An article"},
+		{Kind: PDF_BLOCK_TITLE, Text: "Example"},
+		{Kind: PDF_BLOCK_TITLE, Text: "Article brief - 2024-06-02"},
+		{Kind: PDF_BLOCK_HEADING, Text: "Details"},
+		{Kind: PDF_BLOCK_ROW, Label: "URL", Text: "https://example.com/article"},
+		{Kind: PDF_BLOCK_ROW, Label: "Site", Text: "Example"},
+		{Kind: PDF_BLOCK_ROW, Label: "Language", Text: "French"},
+		{Kind: PDF_BLOCK_ROW, Label: "Date created", Text: "2024-06-02"},
+		{Kind: PDF_BLOCK_ROW, Label: "Tags", Text: "go, pdf"},
+		{Kind: PDF_BLOCK_ROW, Label: "ID", Text: "01HXAAAAAAAAAAAAAAAAAAAAAA"},
+		{Kind: PDF_BLOCK_HEADING, Text: "Summary"},
+		{Kind: PDF_BLOCK_PARAGRAPH, Text: "# Not a heading - not an item | not | a row |"},
+		{Kind: PDF_BLOCK_PARAGRAPH, Text: "% not a title either"},
+		{Kind: PDF_BLOCK_HEADING, Text: "Key Points"},
+		{Kind: PDF_BLOCK_ITEM, Text: "First point"},
+		{Kind: PDF_BLOCK_ITEM, Text: "Second point # still the second point"},
+	}
+
+	blocks, err := pdfLayout(doc)
+	if err != nil {
+		t.Fatalf("pdfLayout: %v", err)
+	}
+	if !reflect.DeepEqual(blocks, want) {
+		t.Errorf("pdfLayout =\n%+v\nwant\n%+v", blocks, want)
+	}
+}
+
+func TestParsePdfLayout(t *testing.T) {
+	identity := func(text string) string { return text }
+	tests := []struct {
+		name   string
+		layout string
+		want   []PdfBlock
+	}{
+		{"empty title line", "% Title\n%\n% \n", []PdfBlock{{Kind: PDF_BLOCK_TITLE, Text: "Title"}}},
+		{"row without value", "| URL |  |\n| Site | Example |", []PdfBlock{{Kind: PDF_BLOCK_ROW, Label: "Site", Text: "Example"}}},
+		{"paragraph lines joined", "one\ntwo\n\nthree", []PdfBlock{{Kind: PDF_BLOCK_PARAGRAPH, Text: "one two"}, {Kind: PDF_BLOCK_PARAGRAPH, Text: "three"}}},
+		{"list", "- a\n-\n- b", []PdfBlock{{Kind: PDF_BLOCK_ITEM, Text: "a"}, {Kind: PDF_BLOCK_ITEM, Text: "b"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if blocks := parsePdfLayout(test.layout, identity); !reflect.DeepEqual(blocks, test.want) {
+				t.Errorf("parsePdfLayout = %+v, want %+v", blocks, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderPdf(t *testing.T) {
+	data, err := renderPdf(ReportDocument{
+		Title:            "Un café 😀",
+		Url:              "https://example.com/",
+		Summary:          "A **bold** summary with a [link](https://example.com/).",
+		KeypointsHeading: "Key Points",
+		Keypoints:        []string{"Point"},
+	})
+	if err != nil {
+		t.Fatalf("renderPdf: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) || !bytes.Contains(data, []byte("%%EOF")) {
+		t.Errorf("renderPdf = %d bytes, want a PDF document", len(data))
+	}
+}
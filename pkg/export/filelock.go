@@ -0,0 +1,25 @@
+package export
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockFile takes an exclusive lock on a file, shared with the other processes
+// of the tool, e.g. the server and a cron job writing to the same output
+// folder. The lock file is created with the permissions if needed, and kept.
+// The returned function releases the lock.
+func (p FilePermissions) lockFile(path string) (func(), error) {
+	file, err := p.openFile(path, os.O_RDWR|os.O_CREATE)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFileHandle(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("locking %s: %w", path, err)
+	}
+	return func() {
+		unlockFileHandle(file)
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,15 @@
+//go:build !(unix && !aix && !solaris) && !windows
+
+package export
+
+import "os"
+
+// Without file locks, e.g. under WebAssembly, only the lock of the process
+// protects the files
+func lockFileHandle(file *os.File) error {
+	return nil
+}
+
+func unlockFileHandle(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,18 @@
+package export
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// The whole file is locked, as the lock files have no content
+const lockFileRange = ^uint32(0)
+
+func lockFileHandle(file *os.File) error {
+	return windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, lockFileRange, lockFileRange, &windows.Overlapped{})
+}
+
+func unlockFileHandle(file *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, lockFileRange, lockFileRange, &windows.Overlapped{})
+}
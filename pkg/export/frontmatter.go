@@ -0,0 +1,86 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/brequet/report/pkg/scrape"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// The frontmatter written by the report template, with text substitution
+	FRONTMATTER_TEMPLATE = "template"
+	// A YAML frontmatter replacing the one of the template, as expected by Obsidian
+	FRONTMATTER_YAML = "yaml"
+)
+
+// YamlFrontmatter is the frontmatter of a report written as proper YAML, with
+// the tags and aliases as lists so that Obsidian picks them up.
+type YamlFrontmatter struct {
+	Id          string   `yaml:"id,omitempty"`
+	Title       string   `yaml:"title"`
+	Aliases     []string `yaml:"aliases,omitempty"`
+	Url         string   `yaml:"url"`
+	ContentType string   `yaml:"content_type,omitempty"`
+	Author      string   `yaml:"author,omitempty"`
+	SiteName    string   `yaml:"site_name,omitempty"`
+	Published   string   `yaml:"published,omitempty"`
+	Cover       string   `yaml:"cover,omitempty"`
+	ArchiveUrl  string   `yaml:"archive_url,omitempty"`
+	DateAdded   string   `yaml:"date_added,omitempty"`
+	Language    string   `yaml:"language,omitempty"`
+	Duration    string   `yaml:"duration,omitempty"`
+	Date        string   `yaml:"date"`
+	Tags        []string `yaml:"tags"`
+}
+
+func renderYamlFrontmatter(article Article, date string) (string, error) {
+	frontmatter := YamlFrontmatter{
+		Id:          article.Id,
+		Title:       article.Title,
+		Aliases:     article.Aliases,
+		Url:         article.Url,
+		ContentType: article.ContentType,
+		Author:      article.Author(),
+		SiteName:    article.SiteName,
+		Cover:       article.Cover,
+		ArchiveUrl:  article.ArchiveUrl,
+		Language:    article.Language,
+		Date:        date,
+		Tags:        make([]string, len(article.Summary.Tags)),
+	}
+	if !article.Published.IsZero() {
+		frontmatter.Published = article.Published.Format(scrape.DATE_FORMAT)
+	}
+	if article.Duration > 0 {
+		frontmatter.Duration = article.Duration.String()
+	}
+	if !article.Added.IsZero() {
+		frontmatter.DateAdded = article.Added.Format(scrape.DATE_FORMAT)
+	}
+	// Obsidian tags cannot contain spaces
+	for i, tag := range article.Summary.Tags {
+		frontmatter.Tags[i] = strings.ReplaceAll(strings.TrimSpace(tag), " ", "-")
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(frontmatter); err != nil {
+		return "", fmt.Errorf("marshaling frontmatter: %w", err)
+	}
+	return "---\n" + buf.String() + "---\n", nil
+}
+
+// replaceFrontmatter replaces the frontmatter of a markdown document, adding
+// it if the document has none.
+func replaceFrontmatter(content, frontmatter string) string {
+	if rest, found := strings.CutPrefix(content, "---\n"); found {
+		if end := strings.Index(rest, "\n---\n"); end >= 0 {
+			return frontmatter + rest[end+len("\n---\n"):]
+		}
+	}
+	return frontmatter + content
+}
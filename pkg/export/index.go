@@ -0,0 +1,204 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const (
+	INDEX_FILE_NAME = "index.json"
+	// Suffix of the lock file of the index, next to it
+	INDEX_LOCK_FILE_SUFFIX = ".lock"
+)
+
+// IndexedArticle records an article already summarized into the output folder.
+type IndexedArticle struct {
+	Id           string   `json:"id,omitempty"`
+	Url          string   `json:"url"`
+	Title        string   `json:"title"`
+	Paths        []string `json:"paths"`
+	Date         string   `json:"date"`
+	InputTokens  int      `json:"input_tokens,omitempty"`
+	OutputTokens int      `json:"output_tokens,omitempty"`
+	// Estimated cost of the tokens, in USD
+	Cost float64 `json:"cost,omitempty"`
+	// Exports of the report to the services, such as Notion or a webhook
+	Exports []IndexedExport `json:"exports,omitempty"`
+}
+
+// IndexedExport records the export of a report to a service.
+type IndexedExport struct {
+	Target string `json:"target"`
+	// Url of the page or of the server, empty when the export failed
+	Location string `json:"location,omitempty"`
+	Date     string `json:"date"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ArticleIndex holds the articles of an output folder keyed by canonical url,
+// so that an article is not summarized twice. A nil index records nothing.
+type ArticleIndex struct {
+	mu           sync.Mutex
+	outputFolder string
+	permissions  FilePermissions
+	Articles     map[string]IndexedArticle `json:"articles"`
+}
+
+func articleIndexPath(outputFolder string) (string, error) {
+	folder, err := ReportDataFolder(outputFolder)
+	if err != nil {
+		return "", fmt.Errorf("finding data folder: %w", err)
+	}
+	return filepath.Join(folder, INDEX_FILE_NAME), nil
+}
+
+// LoadArticleIndex loads the index of the output folder, saved with the
+// permissions. The first time, the index is built from the reports already in
+// the output folder.
+func LoadArticleIndex(outputFolder string, permissions FilePermissions) (*ArticleIndex, error) {
+	index := &ArticleIndex{outputFolder: outputFolder, permissions: permissions, Articles: map[string]IndexedArticle{}}
+
+	path, err := articleIndexPath(outputFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	articles, err := readArticleIndex(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return index, index.addReports()
+	}
+	if err != nil {
+		return nil, err
+	}
+	index.Articles = articles
+	return index, nil
+}
+
+// readArticleIndex reads the articles of an index file.
+func readArticleIndex(path string) (map[string]IndexedArticle, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading article index: %w", err)
+	}
+
+	var index ArticleIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("unmarshaling article index: %w", err)
+	}
+	if index.Articles == nil {
+		index.Articles = map[string]IndexedArticle{}
+	}
+	return index.Articles, nil
+}
+
+func (i *ArticleIndex) addReports() error {
+	if _, err := os.Stat(i.outputFolder); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	reports, err := LoadReports(i.outputFolder)
+	if err != nil {
+		return fmt.Errorf("indexing existing reports: %w", err)
+	}
+	for _, report := range reports {
+		i.Articles[scrape.CanonicalUrl(report.Url)] = IndexedArticle{
+			Id:    report.Id,
+			Url:   report.Url,
+			Title: report.Title,
+			Paths: []string{report.Path},
+			Date:  report.DateCreated,
+		}
+	}
+	return nil
+}
+
+// Lookup returns the indexed article with the same canonical url, if any.
+func (i *ArticleIndex) Lookup(articleUrl string) (IndexedArticle, bool) {
+	if i == nil {
+		return IndexedArticle{}, false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	article, ok := i.Articles[scrape.CanonicalUrl(articleUrl)]
+	return article, ok
+}
+
+// Add records a summarized article and saves the index.
+func (i *ArticleIndex) Add(article Article, paths []string, exports []IndexedExport, usage summarize.Usage, now time.Time) error {
+	// Pages read from the standard input have no url to be found by
+	if i == nil || article.Url == "" {
+		return nil
+	}
+	return i.Update(func(articles map[string]IndexedArticle) {
+		articles[scrape.CanonicalUrl(article.Url)] = IndexedArticle{
+			Id:           article.Id,
+			Url:          article.Url,
+			Title:        article.Title,
+			Paths:        paths,
+			Date:         now.Format(scrape.DATE_FORMAT),
+			InputTokens:  usage.InputTokens,
+			OutputTokens: usage.OutputTokens,
+			Cost:         usage.Cost,
+			Exports:      exports,
+		}
+	})
+}
+
+// Update changes the articles of the index and saves it, under the lock of
+// its file shared with the other processes writing to the output folder, such
+// as a server and a cron job. The index is read again first, not to lose the
+// articles they added since it was loaded.
+func (i *ArticleIndex) Update(change func(articles map[string]IndexedArticle)) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	path, err := articleIndexPath(i.outputFolder)
+	if err != nil {
+		return err
+	}
+	if err := i.permissions.Mkdir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("creating data folder: %w", err)
+	}
+	unlock, err := i.permissions.lockFile(path + INDEX_LOCK_FILE_SUFFIX)
+	if err != nil {
+		return fmt.Errorf("locking article index: %w", err)
+	}
+	defer unlock()
+
+	// Without a file yet, the index built from the reports is kept
+	articles, err := readArticleIndex(path)
+	if err == nil {
+		i.Articles = articles
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	change(i.Articles)
+
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling article index: %w", err)
+	}
+	// The other processes read the index without the lock
+	if err := i.permissions.replaceFile(path, data); err != nil {
+		return fmt.Errorf("writing article index: %w", err)
+	}
+	return nil
+}
+
+// ParseReportDate returns the creation date of a report, false if it has none.
+func ParseReportDate(report Report) (time.Time, bool) {
+	date, err := time.ParseInLocation(scrape.DATE_FORMAT, report.DateCreated, time.Local)
+	return date, err == nil
+}
@@ -0,0 +1,76 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brequet/report/internal/paths"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+func TestArticleIndexConcurrentWriters(t *testing.T) {
+	t.Setenv(paths.DATA_DIR_ENV, t.TempDir())
+	outputFolder := t.TempDir()
+	now := time.Date(2024, 6, 2, 12, 0, 0, 0, time.UTC)
+
+	// Each index stands for a process writing to the output folder, such as
+	// the server and a cron job
+	const writers, articles = 8, 25
+	indexes := make([]*ArticleIndex, writers)
+	for w := range indexes {
+		index, err := LoadArticleIndex(outputFolder, FilePermissions{})
+		if err != nil {
+			t.Fatalf("loadArticleIndex: %v", err)
+		}
+		indexes[w] = index
+	}
+	var wg sync.WaitGroup
+	for w, index := range indexes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for a := range articles {
+				article := Article{Article: scrape.Article{Url: fmt.Sprintf("https://example.com/%d/%d", w, a), Title: "Article"}}
+				if err := index.Add(article, []string{"report.md"}, nil, summarize.Usage{}, now); err != nil {
+					t.Errorf("add: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	index, err := LoadArticleIndex(outputFolder, FilePermissions{})
+	if err != nil {
+		t.Fatalf("loadArticleIndex: %v", err)
+	}
+	if len(index.Articles) != writers*articles {
+		t.Errorf("index has %d articles, want %d", len(index.Articles), writers*articles)
+	}
+	for w := range writers {
+		for a := range articles {
+			if _, found := index.Lookup(fmt.Sprintf("https://example.com/%d/%d", w, a)); !found {
+				t.Errorf("article %d of writer %d lost", a, w)
+			}
+		}
+	}
+
+	// No temporary file is left behind
+	path, err := articleIndexPath(outputFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); name != INDEX_FILE_NAME && name != INDEX_FILE_NAME+INDEX_LOCK_FILE_SUFFIX {
+			t.Errorf("unexpected file %s next to the index", name)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package export
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+const (
+	EXPORT_KARAKEEP = "karakeep"
+	// Number of bookmarks per page of the Karakeep API
+	KARAKEEP_PAGE_SIZE = 100
+)
+
+// KarakeepClient calls the REST API of a Karakeep (formerly Hoarder) server,
+// to read its bookmarks and write the summaries back to them.
+type KarakeepClient struct {
+	// Address of the server, e.g. https://karakeep.example.com
+	Url    string
+	ApiKey string
+
+	retry   scrape.RetryPolicy
+	timeout time.Duration
+}
+
+type KarakeepBookmark struct {
+	Id        string        `json:"id"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Title     string        `json:"title"`
+	Summary   string        `json:"summary"`
+	Tags      []KarakeepTag `json:"tags"`
+	Content   struct {
+		Type  string `json:"type"`
+		Url   string `json:"url"`
+		Title string `json:"title"`
+	} `json:"content"`
+}
+
+type KarakeepTag struct {
+	Name string `json:"name"`
+}
+
+type KarakeepBookmarksPage struct {
+	Bookmarks  []KarakeepBookmark `json:"bookmarks"`
+	NextCursor string             `json:"nextCursor"`
+}
+
+type KarakeepErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewKarakeepClient returns the client of the Karakeep server set by
+// KARAKEEP_URL and KARAKEEP_API_KEY, or by the config file. Its calls are
+// retried with the policy, and limited to the timeout.
+func NewKarakeepClient(config KarakeepConfig, retry scrape.RetryPolicy, timeout time.Duration) (*KarakeepClient, error) {
+	client := &KarakeepClient{
+		Url:     strings.TrimSuffix(cmp.Or(os.Getenv("KARAKEEP_URL"), config.Url), "/"),
+		ApiKey:  cmp.Or(os.Getenv("KARAKEEP_API_KEY"), config.ApiKey),
+		retry:   retry,
+		timeout: timeout,
+	}
+	if client.Url == "" {
+		return nil, fmt.Errorf("KARAKEEP_URL environment variable is not set")
+	}
+	if client.ApiKey == "" {
+		return nil, fmt.Errorf("KARAKEEP_API_KEY environment variable is not set")
+	}
+	return client, nil
+}
+
+// Export writes the summary and the tags of an article to its bookmark, the
+// bookmark of the article url being created if bookmarkId is empty.
+func (c *KarakeepClient) Export(ctx context.Context, article Article, bookmarkId string) error {
+	if err := checkArticleIsComplete(article); err != nil {
+		return err
+	}
+	if bookmarkId == "" {
+		var bookmark KarakeepBookmark
+		body := map[string]string{"type": "link", "url": article.Url, "title": article.Title}
+		err := c.retry.Do(ctx, "creating the Karakeep bookmark of "+article.Url, func() error {
+			return c.request(ctx, "POST", "/bookmarks", body, &bookmark)
+		})
+		if err != nil {
+			return err
+		}
+		bookmarkId = bookmark.Id
+	}
+
+	body := map[string]string{"summary": karakeepSummary(article)}
+	err := c.retry.Do(ctx, "writing the Karakeep summary of "+article.Url, func() error {
+		return c.request(ctx, "PATCH", "/bookmarks/"+url.PathEscape(bookmarkId), body, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(article.Summary.Tags) == 0 {
+		return nil
+	}
+	var tags []map[string]string
+	for _, tag := range article.Summary.Tags {
+		tags = append(tags, map[string]string{"tagName": tag})
+	}
+	return c.retry.Do(ctx, "tagging the Karakeep bookmark of "+article.Url, func() error {
+		return c.request(ctx, "POST", "/bookmarks/"+url.PathEscape(bookmarkId)+"/tags", map[string]any{"tags": tags}, nil)
+	})
+}
+
+// karakeepSummary returns the summary written to a bookmark, in markdown: the
+// summary followed by the key points.
+func karakeepSummary(article Article) string {
+	doc := newReportDocument(article, time.Now())
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSpace(doc.Summary))
+	if len(doc.Keypoints) > 0 {
+		sb.WriteString("\n\n**" + doc.KeypointsHeading + "**\n")
+		for _, keypoint := range doc.Keypoints {
+			sb.WriteString("\n- " + keypoint)
+		}
+	}
+	return sb.String()
+}
+
+// ListBookmarks returns all the bookmarks of the server, not archived,
+// following the pages of the API.
+func (c *KarakeepClient) ListBookmarks(ctx context.Context) ([]KarakeepBookmark, error) {
+	var bookmarks []KarakeepBookmark
+	cursor := ""
+	for {
+		query := url.Values{"limit": {fmt.Sprint(KARAKEEP_PAGE_SIZE)}, "archived": {"false"}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		var page KarakeepBookmarksPage
+		err := c.retry.Do(ctx, "listing the Karakeep bookmarks", func() error {
+			return c.request(ctx, "GET", "/bookmarks?"+query.Encode(), nil, &page)
+		})
+		if err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, page.Bookmarks...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return bookmarks, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// request calls the Karakeep API, decoding its answer into result unless it
+// is nil.
+func (c *KarakeepClient) request(ctx context.Context, method, path string, body, result any) error {
+	ctx, cancel := scrape.WithTimeout(ctx, c.timeout, "Karakeep API call")
+	defer cancel()
+
+	apiUrl := c.Url + "/api/v1" + path
+	var reader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshaling Karakeep request: %w", err)
+		}
+		reader = bytes.NewReader(jsonData)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, apiUrl, reader)
+	if err != nil {
+		return &scrape.FetchError{Url: apiUrl, Err: err}
+	}
+	req.Header.Set("Authorization", "Bearer "+c.ApiKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &scrape.FetchError{Url: apiUrl, Err: scrape.TimeoutCause(ctx, err)}
+	}
+	defer res.Body.Close()
+
+	fetchErr := scrape.FetchError{Url: apiUrl, StatusCode: res.StatusCode, Status: res.Status, RetryAfter: scrape.ParseRetryAfter(res.Header.Get("Retry-After"))}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		fetchErr.Err = fmt.Errorf("reading body: %w", scrape.TimeoutCause(ctx, err))
+		return &fetchErr
+	}
+	if res.StatusCode >= 400 {
+		var karakeepErr KarakeepErrorResponse
+		if json.Unmarshal(data, &karakeepErr) == nil && karakeepErr.Message != "" {
+			fetchErr.Err = fmt.Errorf("%s: %s", karakeepErr.Code, karakeepErr.Message)
+		} else {
+			fetchErr.Err = fmt.Errorf("unexpected response status")
+		}
+		return &fetchErr
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("unmarshaling Karakeep response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// NoteLinks adds a wikilink to each new markdown report to notes of the
+// vault, such as a map of content or the daily note, so that the reports show
+// up in the reading log of the user.
+type NoteLinks struct {
+	// Note listing the reports, relative to the output folder unless absolute
+	IndexNote string
+	// Folder of the daily notes, relative to the output folder unless
+	// absolute, and their file name as a date format of the Daily notes
+	// plugin of Obsidian (moment.js), e.g. YYYY-MM-DD
+	DailyFolder string
+	DailyFormat string
+	// Heading of the notes the links are added under, e.g. ## Reading, the
+	// links being added at the end of the notes if empty
+	Heading string
+}
+
+const DEFAULT_DAILY_NOTE_FORMAT = "YYYY-MM-DD"
+
+// momentTokens are the tokens of the moment.js date formats, longest first,
+// and their value.
+var momentTokens = []struct {
+	token  string
+	format func(time.Time) string
+}{
+	{"YYYY", func(t time.Time) string { return t.Format("2006") }},
+	{"YY", func(t time.Time) string { return t.Format("06") }},
+	{"MMMM", func(t time.Time) string { return t.Format("January") }},
+	{"MMM", func(t time.Time) string { return t.Format("Jan") }},
+	{"MM", func(t time.Time) string { return t.Format("01") }},
+	{"M", func(t time.Time) string { return t.Format("1") }},
+	{"DD", func(t time.Time) string { return t.Format("02") }},
+	{"D", func(t time.Time) string { return t.Format("2") }},
+	{"dddd", func(t time.Time) string { return t.Format("Monday") }},
+	{"ddd", func(t time.Time) string { return t.Format("Mon") }},
+	{"ww", func(t time.Time) string { _, week := t.ISOWeek(); return fmt.Sprintf("%02d", week) }},
+	{"w", func(t time.Time) string { _, week := t.ISOWeek(); return fmt.Sprint(week) }},
+}
+
+// Enabled tells if any note links to the reports.
+func (l NoteLinks) Enabled() bool {
+	return l.IndexNote != "" || l.DailyFolder != ""
+}
+
+// Validate checks the daily note format and the heading.
+func (l NoteLinks) Validate() error {
+	if l.DailyFolder != "" && strings.TrimSpace(formatMomentDate(l.DailyFormat, time.Now())) == "" {
+		return fmt.Errorf("invalid daily note format '%s', expected a date format such as %s", l.DailyFormat, DEFAULT_DAILY_NOTE_FORMAT)
+	}
+	if l.Heading != "" && headingLevel(l.Heading) == 0 {
+		return fmt.Errorf("invalid heading '%s', expected a markdown heading such as ## Reading", l.Heading)
+	}
+	return nil
+}
+
+// notes returns the paths of the notes linking to the reports written at a
+// date.
+func (l NoteLinks) notes(outputFolder string, now time.Time) []string {
+	var notes []string
+	dailyNote := ""
+	if l.DailyFolder != "" {
+		dailyNote = filepath.Join(l.DailyFolder, formatMomentDate(l.DailyFormat, now))
+	}
+	for _, note := range []string{l.IndexNote, dailyNote} {
+		if note == "" {
+			continue
+		}
+		if !strings.EqualFold(filepath.Ext(note), ".md") {
+			note += ".md"
+		}
+		if !filepath.IsAbs(note) {
+			note = filepath.Join(outputFolder, note)
+		}
+		notes = append(notes, note)
+	}
+	return notes
+}
+
+// Link adds a wikilink to a report to the notes, creating them if missing.
+// The notes already linking to the report are left as is.
+func (l NoteLinks) Link(outputFolder string, permissions FilePermissions, reportPath, title string, now time.Time) error {
+	name := strings.TrimSuffix(filepath.Base(reportPath), filepath.Ext(reportPath))
+	link := "[[" + name + "]]"
+	if title != "" && title != name {
+		link = "[[" + name + "|" + title + "]]"
+	}
+
+	for _, note := range l.notes(outputFolder, now) {
+		data, err := os.ReadFile(note)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("reading note: %w", err)
+		}
+		content := string(data)
+		if strings.Contains(content, "[["+name+"]]") || strings.Contains(content, "[["+name+"|") {
+			continue
+		}
+		if err := permissions.Mkdir(filepath.Dir(note)); err != nil {
+			return fmt.Errorf("creating note folder: %w", err)
+		}
+		if err := permissions.WriteFile(note, []byte(addNoteLink(content, l.Heading, "- "+link))); err != nil {
+			return err
+		}
+		slog.Info("Report linked from note", "note", note)
+	}
+	return nil
+}
+
+// addNoteLink adds a line at the end of the section of a heading of a note,
+// adding the heading at the end of the note if missing, or at the end of the
+// note without a heading. The line endings of the note are kept.
+func addNoteLink(content, heading, line string) string {
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+	}
+	lines := strings.Split(strings.TrimRight(content, "\r\n"), newline)
+	if content == "" {
+		lines = nil
+	}
+
+	start := -1
+	if heading != "" {
+		for i, existing := range lines {
+			if strings.TrimSpace(existing) == strings.TrimSpace(heading) {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			if len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, heading)
+			start = len(lines) - 1
+		}
+	}
+
+	// The section ends at the next heading of the same or a higher level
+	end := len(lines)
+	if start >= 0 {
+		level := headingLevel(lines[start])
+		for i := start + 1; i < len(lines); i++ {
+			if other := headingLevel(lines[i]); other > 0 && other <= level {
+				end = i
+				break
+			}
+		}
+	}
+	// The line follows the last line of the section that is not blank
+	at := end
+	for at > start+1 && strings.TrimSpace(lines[at-1]) == "" {
+		at--
+	}
+
+	lines = append(lines[:at], append([]string{line}, lines[at:]...)...)
+	return strings.Join(lines, newline) + newline
+}
+
+// headingLevel returns the level of a markdown heading, 0 if the line is not
+// a heading.
+func headingLevel(line string) int {
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level == 0 || level > 6 || (len(line) > level && line[level] != ' ') {
+		return 0
+	}
+	return level
+}
+
+// formatMomentDate formats a date with a moment.js date format, the text
+// between square brackets being kept as is.
+func formatMomentDate(format string, t time.Time) string {
+	var sb strings.Builder
+	for i := 0; i < len(format); {
+		if format[i] == '[' {
+			if end := strings.IndexByte(format[i:], ']'); end > 0 {
+				sb.WriteString(format[i+1 : i+end])
+				i += end + 1
+				continue
+			}
+		}
+		matched := false
+		for _, token := range momentTokens {
+			if strings.HasPrefix(format[i:], token.token) {
+				sb.WriteString(token.format(t))
+				i += len(token.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			sb.WriteByte(format[i])
+			i++
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,291 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+const (
+	EXPORT_NOTION      = "notion"
+	NOTION_API_URL     = "https://api.notion.com/v1"
+	NOTION_API_VERSION = "2022-06-28"
+	// Maximum length of a text object of the Notion API
+	NOTION_MAX_TEXT_LENGTH = 2000
+)
+
+// NotionExporter creates a page per article in a Notion database, the
+// integration of the API key having access to it.
+type NotionExporter struct {
+	ApiKey     string
+	DatabaseId string
+
+	retry   scrape.RetryPolicy
+	timeout time.Duration
+
+	// Properties of the database the title, tags and url are written to,
+	// looked up once
+	schemaOnce sync.Once
+	schema     NotionSchema
+	schemaErr  error
+}
+
+// NotionSchema holds the names of the properties of a database the reports
+// are written to. The tags and url are left out when the database has no
+// property of their type.
+type NotionSchema struct {
+	Title string
+	Tags  string
+	Url   string
+}
+
+type NotionDatabase struct {
+	Properties map[string]struct {
+		Type string `json:"type"`
+	} `json:"properties"`
+}
+
+type NotionPage struct {
+	Id  string `json:"id"`
+	Url string `json:"url"`
+}
+
+type NotionErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type NotionRichText struct {
+	Type        string             `json:"type"`
+	Text        NotionText         `json:"text"`
+	Annotations *NotionAnnotations `json:"annotations,omitempty"`
+}
+
+type NotionText struct {
+	Content string      `json:"content"`
+	Link    *NotionLink `json:"link,omitempty"`
+}
+
+type NotionLink struct {
+	Url string `json:"url"`
+}
+
+type NotionAnnotations struct {
+	Bold bool `json:"bold"`
+}
+
+// NotionBlock is a block of the content of a page, a heading, a paragraph
+// or a bullet.
+type NotionBlock struct {
+	Object string `json:"object"`
+	Type   string `json:"type"`
+	// Content of the block, under the key of its type
+	Heading   *NotionBlockText `json:"heading_2,omitempty"`
+	Paragraph *NotionBlockText `json:"paragraph,omitempty"`
+	Bullet    *NotionBlockText `json:"bulleted_list_item,omitempty"`
+}
+
+type NotionBlockText struct {
+	RichText []NotionRichText `json:"rich_text"`
+}
+
+// NewNotionExporter returns the Notion exporter of a database, the API key
+// being read from NOTION_API_KEY or the config file. Its calls are retried
+// with the policy, and limited to the timeout.
+func NewNotionExporter(databaseId string, config NotionConfig, retry scrape.RetryPolicy, timeout time.Duration) (*NotionExporter, error) {
+	if databaseId == "" {
+		return nil, fmt.Errorf("the Notion export needs a database, set -notion-db")
+	}
+	apiKey := os.Getenv("NOTION_API_KEY")
+	if apiKey == "" {
+		apiKey = config.ApiKey
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("NOTION_API_KEY environment variable is not set")
+	}
+	// Database ids are also copied with dashes or as the url of the database
+	databaseId = databaseId[strings.LastIndex(databaseId, "/")+1:]
+	databaseId, _, _ = strings.Cut(databaseId, "?")
+	if index := strings.LastIndex(databaseId, "-"); len(databaseId)-index-1 == 32 {
+		databaseId = databaseId[index+1:]
+	}
+	return &NotionExporter{ApiKey: apiKey, DatabaseId: strings.ReplaceAll(databaseId, "-", ""), retry: retry, timeout: timeout}, nil
+}
+
+// Export creates the page of an article in the database and returns its url.
+func (e *NotionExporter) Export(ctx context.Context, article Article, now time.Time) (string, error) {
+	if err := checkArticleIsComplete(article); err != nil {
+		return "", err
+	}
+	e.schemaOnce.Do(func() {
+		e.schema, e.schemaErr = e.loadSchema(ctx)
+	})
+	if e.schemaErr != nil {
+		return "", e.schemaErr
+	}
+
+	doc := newReportDocument(article, now)
+	properties := map[string]any{
+		e.schema.Title: map[string]any{"title": notionRichText(doc.Title)},
+	}
+	if e.schema.Tags != "" {
+		var tags []map[string]string
+		for _, tag := range doc.Tags {
+			// Select options cannot contain commas
+			tags = append(tags, map[string]string{"name": strings.ReplaceAll(tag, ",", " ")})
+		}
+		properties[e.schema.Tags] = map[string]any{"multi_select": tags}
+	}
+	if e.schema.Url != "" && doc.Url != "" {
+		properties[e.schema.Url] = map[string]any{"url": doc.Url}
+	}
+
+	blocks := []NotionBlock{notionHeading("Summary")}
+	for _, paragraph := range strings.Split(doc.Summary, "\n\n") {
+		if strings.TrimSpace(paragraph) != "" {
+			blocks = append(blocks, NotionBlock{Object: "block", Type: "paragraph", Paragraph: &NotionBlockText{RichText: notionRichText(paragraph)}})
+		}
+	}
+	blocks = append(blocks, notionHeading(doc.KeypointsHeading))
+	for _, keypoint := range doc.Keypoints {
+		blocks = append(blocks, NotionBlock{Object: "block", Type: "bulleted_list_item", Bullet: &NotionBlockText{RichText: notionRichText(keypoint)}})
+	}
+
+	var page NotionPage
+	body := map[string]any{
+		"parent":     map[string]string{"database_id": e.DatabaseId},
+		"properties": properties,
+		"children":   blocks,
+	}
+	err := e.retry.Do(ctx, "creating the Notion page of "+article.Url, func() error {
+		return e.request(ctx, "POST", "/pages", body, &page)
+	})
+	if err != nil {
+		return "", err
+	}
+	return page.Url, nil
+}
+
+// loadSchema finds the properties of the database to write the title, the
+// tags and the url to: the title property, and the multi-select and url
+// properties named Tags and URL, else the first ones.
+func (e *NotionExporter) loadSchema(ctx context.Context) (NotionSchema, error) {
+	var database NotionDatabase
+	err := e.retry.Do(ctx, "reading the Notion database", func() error {
+		return e.request(ctx, "GET", "/databases/"+e.DatabaseId, nil, &database)
+	})
+	if err != nil {
+		return NotionSchema{}, err
+	}
+
+	var schema NotionSchema
+	pick := func(current *string, name, preferred string) {
+		if *current == "" || strings.EqualFold(name, preferred) {
+			*current = name
+		}
+	}
+	for name, property := range database.Properties {
+		switch property.Type {
+		case "title":
+			schema.Title = name
+		case "multi_select":
+			pick(&schema.Tags, name, "Tags")
+		case "url":
+			pick(&schema.Url, name, "URL")
+		}
+	}
+	if schema.Title == "" {
+		return NotionSchema{}, fmt.Errorf("the Notion database has no title property")
+	}
+	return schema, nil
+}
+
+// request calls the Notion API, decoding its answer into result.
+func (e *NotionExporter) request(ctx context.Context, method, path string, body, result any) error {
+	ctx, cancel := scrape.WithTimeout(ctx, e.timeout, "Notion API call")
+	defer cancel()
+
+	apiUrl := NOTION_API_URL + path
+	var reader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshaling Notion request: %w", err)
+		}
+		reader = bytes.NewReader(jsonData)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, apiUrl, reader)
+	if err != nil {
+		return &scrape.FetchError{Url: apiUrl, Err: err}
+	}
+	req.Header.Set("Authorization", "Bearer "+e.ApiKey)
+	req.Header.Set("Notion-Version", NOTION_API_VERSION)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &scrape.FetchError{Url: apiUrl, Err: scrape.TimeoutCause(ctx, err)}
+	}
+	defer res.Body.Close()
+
+	fetchErr := scrape.FetchError{Url: apiUrl, StatusCode: res.StatusCode, Status: res.Status, RetryAfter: scrape.ParseRetryAfter(res.Header.Get("Retry-After"))}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		fetchErr.Err = fmt.Errorf("reading body: %w", scrape.TimeoutCause(ctx, err))
+		return &fetchErr
+	}
+	if res.StatusCode >= 400 {
+		var notionErr NotionErrorResponse
+		if json.Unmarshal(data, &notionErr) == nil && notionErr.Message != "" {
+			fetchErr.Err = fmt.Errorf("%s: %s", notionErr.Code, notionErr.Message)
+		} else {
+			fetchErr.Err = fmt.Errorf("unexpected response status")
+		}
+		return &fetchErr
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("unmarshaling Notion response: %w", err)
+	}
+	return nil
+}
+
+func notionHeading(title string) NotionBlock {
+	return NotionBlock{Object: "block", Type: "heading_2", Heading: &NotionBlockText{RichText: notionRichText(title)}}
+}
+
+// notionRichText converts the inline markdown of the LLM answers (bold and
+// links) to Notion rich text, split in text objects of at most
+// NOTION_MAX_TEXT_LENGTH characters.
+func notionRichText(text string) []NotionRichText {
+	var richText []NotionRichText
+	for _, segment := range parseInlineMarkdown(strings.TrimSpace(text)) {
+		content := segment.Text
+		for content != "" {
+			part := content
+			if utf8.RuneCountInString(part) > NOTION_MAX_TEXT_LENGTH {
+				part = string([]rune(part)[:NOTION_MAX_TEXT_LENGTH])
+			}
+			content = content[len(part):]
+
+			item := NotionRichText{Type: "text", Text: NotionText{Content: part}}
+			if segment.Link != "" {
+				item.Text.Link = &NotionLink{Url: segment.Link}
+			}
+			if segment.Bold {
+				item.Annotations = &NotionAnnotations{Bold: true}
+			}
+			richText = append(richText, item)
+		}
+	}
+	return richText
+}
@@ -0,0 +1,96 @@
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/internal/paths"
+)
+
+// ReportDataFolder returns the folder holding the data kept about an output
+// folder. It is named after the ID of the output folder, stored in a marker
+// file inside it, so that the data follows the folder when it is moved or
+// renamed. Output folders created before the data moved to the data directory
+// keep using their own .report folder.
+func ReportDataFolder(outputFolder string) (string, error) {
+	legacy := filepath.Join(outputFolder, REPORT_DATA_FOLDER)
+	if info, err := os.Stat(legacy); err == nil && info.IsDir() {
+		return legacy, nil
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	dir, err := paths.DataDir()
+	if err != nil {
+		return "", err
+	}
+	absolute, err := filepath.Abs(outputFolder)
+	if err != nil {
+		return "", err
+	}
+	// Folder of the data before the marker file, named after the path
+	hash := sha256.Sum256([]byte(absolute))
+	byPath := filepath.Join(dir, paths.DATA_FOLDERS_FOLDER, filepath.Base(absolute)+"-"+hex.EncodeToString(hash[:8]))
+
+	id, created, err := outputFolderId(outputFolder)
+	if err != nil {
+		// A read-only output folder cannot hold the marker file
+		slog.Debug("No ID for the output folder, keying its data by its path", "folder", outputFolder, "err", err)
+		return byPath, nil
+	}
+	folder := filepath.Join(dir, paths.DATA_FOLDERS_FOLDER, id)
+	if created {
+		if err := os.Rename(byPath, folder); err == nil {
+			slog.Info("Data of the output folder moved", "from", byPath, "to", folder)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("moving the data of the output folder: %w", err)
+		}
+	}
+	return folder, nil
+}
+
+// outputFolderId returns the ID of an output folder, read from its marker
+// file, which is created the first time. It also tells whether it was.
+func outputFolderId(outputFolder string) (string, bool, error) {
+	path := filepath.Join(outputFolder, paths.OUTPUT_FOLDER_MARKER)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		id := strings.TrimSpace(string(data))
+		if len(id) != REPORT_ID_LENGTH || strings.Trim(id, REPORT_ID_ALPHABET) != "" {
+			return "", false, fmt.Errorf("invalid ID in '%s'", path)
+		}
+		return id, false, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", false, err
+	}
+
+	id := NewReportId(time.Now())
+	if err := os.MkdirAll(outputFolder, 0777); err != nil {
+		return "", false, err
+	}
+	// Created exclusively, another process may be creating it too
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if errors.Is(err, fs.ErrExist) {
+		return outputFolderId(outputFolder)
+	} else if err != nil {
+		return "", false, err
+	}
+	_, err = fmt.Fprintln(file, id)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", false, err
+	}
+	return id, true, nil
+}
@@ -0,0 +1,178 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// FilePermissions are the modes and group of the files and folders written by
+// the tool. Unset modes default to 0666 for files and 0777 for folders,
+// filtered by the umask like for any program. Set modes are applied as is,
+// whatever the umask, so that reports can be made group writable or private.
+// The zero value writes with the defaults.
+type FilePermissions struct {
+	FileMode *os.FileMode
+	DirMode  *os.FileMode
+	// Group id given to written files and folders, nil to keep the default
+	// one.
+	Gid *int
+}
+
+// ParseFileMode parses an octal file mode, e.g. 0644.
+func ParseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode '%s', expected an octal mode such as 0644", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// LookupGroupId returns the ID of a group, given by name or by ID.
+func LookupGroupId(value string) (int, error) {
+	if gid, err := strconv.Atoi(value); err == nil {
+		return gid, nil
+	}
+	group, err := user.LookupGroup(value)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(group.Gid)
+}
+
+// Apply sets the mode, if not nil, and the group of the permissions to a
+// path.
+func (p FilePermissions) Apply(path string, mode *os.FileMode) error {
+	if mode != nil {
+		if err := os.Chmod(path, *mode); err != nil {
+			return NewWriteError("setting mode of", path, err)
+		}
+	}
+	if p.Gid != nil {
+		if err := os.Lchown(path, -1, *p.Gid); err != nil {
+			return NewWriteError("setting group of", path, err)
+		}
+	}
+	return nil
+}
+
+// applyFile sets the mode and group of an open file, before anything is
+// written to it.
+func (p FilePermissions) applyFile(file *os.File) error {
+	if p.FileMode != nil {
+		if err := file.Chmod(*p.FileMode); err != nil {
+			return NewWriteError("setting mode of", file.Name(), err)
+		}
+	}
+	if p.Gid != nil {
+		if err := file.Chown(-1, *p.Gid); err != nil {
+			return NewWriteError("setting group of", file.Name(), err)
+		}
+	}
+	return nil
+}
+
+// createMode is the mode new files are created with, filtered by the umask,
+// so that a private file is never readable by others while it is written.
+func (p FilePermissions) createMode() os.FileMode {
+	if p.FileMode != nil {
+		return *p.FileMode
+	}
+	return 0666
+}
+
+// WriteFile writes a file with the permissions.
+func (p FilePermissions) WriteFile(path string, data []byte) error {
+	file, err := p.openFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return NewWriteError("writing", path, err)
+	}
+	return nil
+}
+
+// replaceFile writes a file with the permissions through a temporary file
+// renamed over it, so that the file is never seen half written, even if the
+// tool is interrupted.
+func (p FilePermissions) replaceFile(path string, data []byte) error {
+	tempPath := path + ".tmp-" + strconv.FormatUint(rand.Uint64(), 36)
+	file, err := p.openFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return NewWriteError("writing", path, err)
+	}
+	return nil
+}
+
+// CreateFile creates or truncates a file with the permissions.
+func (p FilePermissions) CreateFile(path string) (*os.File, error) {
+	return p.openFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+}
+
+// appendFile opens a file for appending, creating it with the permissions if
+// needed.
+func (p FilePermissions) appendFile(path string) (*os.File, error) {
+	return p.openFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY)
+}
+
+// openFile opens a file, created with the file mode. The mode and group are
+// also set on an existing file before it is returned, so that its new content
+// is never written with its former permissions.
+func (p FilePermissions) openFile(path string, flag int) (*os.File, error) {
+	file, err := os.OpenFile(path, flag, p.createMode())
+	if err != nil {
+		return nil, NewWriteError("opening", path, err)
+	}
+	if err := p.applyFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// Mkdir creates a folder and its missing parents with the permissions.
+// Existing folders are left untouched.
+func (p FilePermissions) Mkdir(path string) error {
+	var missing []string
+	for current := filepath.Clean(path); ; current = filepath.Dir(current) {
+		if _, err := os.Stat(current); err == nil || !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		missing = append(missing, current)
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+
+	if err := os.MkdirAll(path, 0777); err != nil {
+		return NewWriteError("creating folder", path, err)
+	}
+
+	for _, folder := range missing {
+		if err := p.Apply(folder, p.DirMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
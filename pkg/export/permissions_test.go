@@ -0,0 +1,92 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func fileMode(t *testing.T, path string) os.FileMode {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Mode().Perm()
+}
+
+func TestOutputFileModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix modes on Windows")
+	}
+	private, shared := os.FileMode(0600), os.FileMode(0664)
+	tests := []struct {
+		name     string
+		existing os.FileMode
+		mode     *os.FileMode
+		open     func(p FilePermissions, path string) (*os.File, error)
+		want     os.FileMode
+	}{
+		{"new private file", 0, &private, FilePermissions.CreateFile, 0600},
+		{"existing file made private", 0644, &private, FilePermissions.CreateFile, 0600},
+		{"appended file made private", 0644, &private, FilePermissions.appendFile, 0600},
+		{"shared file whatever the umask", 0, &shared, FilePermissions.CreateFile, 0664},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			permissions := FilePermissions{FileMode: test.mode}
+			path := filepath.Join(t.TempDir(), "report.md")
+			if test.existing != 0 {
+				if err := os.WriteFile(path, []byte("previous"), test.existing); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chmod(path, test.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			file, err := test.open(permissions, path)
+			if err != nil {
+				t.Fatalf("opening output file: %v", err)
+			}
+			defer file.Close()
+			// The mode is set before anything is written
+			if mode := fileMode(t, path); mode != test.want {
+				t.Errorf("mode of the opened file = %o, want %o", mode, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix modes on Windows")
+	}
+	private, privateDir := os.FileMode(0600), os.FileMode(0700)
+	permissions := FilePermissions{FileMode: &private, DirMode: &privateDir}
+
+	folder := filepath.Join(t.TempDir(), "reports", "2024")
+	if err := permissions.Mkdir(folder); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, path := range []string{folder, filepath.Dir(folder)} {
+		if mode := fileMode(t, path); mode != privateDir {
+			t.Errorf("mode of %s = %o, want %o", path, mode, privateDir)
+		}
+	}
+
+	path := filepath.Join(folder, "report.md")
+	if err := os.WriteFile(path, []byte("a longer previous content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := permissions.WriteFile(path, []byte("new")); err != nil {
+		t.Fatalf("writeFile: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "new" {
+		t.Errorf("written file = %q, %v, want %q", data, err, "new")
+	}
+	if mode := fileMode(t, path); mode != private {
+		t.Errorf("mode of the written file = %o, want %o", mode, private)
+	}
+}
@@ -0,0 +1,73 @@
+package export
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Crockford's base32, without the letters mistaken for digits (I, L, O) and U
+const REPORT_ID_ALPHABET = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Length of a report ID, a ULID encoding 128 bits in 26 characters
+const REPORT_ID_LENGTH = 26
+
+// NewReportId returns a ULID: 48 bits of time in milliseconds followed by 80
+// random bits. The IDs of the reports sort in their creation order.
+func NewReportId(now time.Time) string {
+	var data [16]byte
+	binary.BigEndian.PutUint64(data[:8], uint64(now.UnixMilli())<<16)
+	rand.Read(data[6:])
+
+	high := binary.BigEndian.Uint64(data[:8])
+	low := binary.BigEndian.Uint64(data[8:])
+	id := make([]byte, REPORT_ID_LENGTH)
+	for i := REPORT_ID_LENGTH - 1; i >= 0; i-- {
+		id[i] = REPORT_ID_ALPHABET[low&0x1f]
+		low = low>>5 | high<<59
+		high >>= 5
+	}
+	return string(id)
+}
+
+// ArticleReportId returns the ID of the reports of an article, the one of its
+// previous reports when it is summarized again, so that its links survive.
+func ArticleReportId(index *ArticleIndex, articleUrl string, now time.Time) string {
+	if indexed, found := index.Lookup(articleUrl); found && indexed.Id != "" && articleUrl != "" {
+		return indexed.Id
+	}
+	return NewReportId(now)
+}
+
+// FindReportById returns the report of the output folder with the given ID,
+// or the only one whose ID starts with it.
+func FindReportById(outputFolder, id string) (Report, error) {
+	id = strings.ToUpper(strings.TrimSpace(id))
+	if id == "" {
+		return Report{}, fmt.Errorf("empty report ID")
+	}
+
+	reports, err := LoadReports(outputFolder)
+	if err != nil {
+		return Report{}, err
+	}
+
+	var matches []Report
+	for _, report := range reports {
+		if report.Id == id {
+			return report, nil
+		}
+		if report.Id != "" && strings.HasPrefix(report.Id, id) {
+			matches = append(matches, report)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Report{}, fmt.Errorf("no report with ID '%s' in '%s'", id, outputFolder)
+	case 1:
+		return matches[0], nil
+	}
+	return Report{}, fmt.Errorf("ID '%s' is ambiguous, it starts %d report IDs", id, len(matches))
+}
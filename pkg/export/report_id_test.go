@@ -0,0 +1,58 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewReportId(t *testing.T) {
+	tests := []struct {
+		name string
+		now  time.Time
+		// Time part of the ID, its first 10 characters
+		want string
+	}{
+		{"unix epoch", time.UnixMilli(0), "0000000000"},
+		{"one millisecond", time.UnixMilli(1), "0000000001"},
+		{"ulid spec example", time.UnixMilli(1469918176385), "01ARYZ6S41"},
+		{"largest time", time.UnixMilli(1<<48 - 1), "7ZZZZZZZZZ"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id := NewReportId(test.now)
+			if len(id) != REPORT_ID_LENGTH {
+				t.Fatalf("newReportId = %q, want %d characters", id, REPORT_ID_LENGTH)
+			}
+			if strings.Trim(id, REPORT_ID_ALPHABET) != "" {
+				t.Errorf("newReportId = %q, want only characters of %q", id, REPORT_ID_ALPHABET)
+			}
+			if id[:10] != test.want {
+				t.Errorf("newReportId = %q, want a time part of %q", id, test.want)
+			}
+		})
+	}
+}
+
+func TestNewReportIdOrder(t *testing.T) {
+	start := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	seen := map[string]bool{}
+	previous := ""
+	for i := 0; i < 1000; i++ {
+		id := NewReportId(start.Add(time.Duration(i) * time.Millisecond))
+		if seen[id] {
+			t.Fatalf("newReportId returned %q twice", id)
+		}
+		seen[id] = true
+		if id <= previous {
+			t.Fatalf("newReportId = %q after %q, want IDs sorting in their creation order", id, previous)
+		}
+		previous = id
+	}
+
+	// The IDs of the same millisecond differ by their random part
+	now := time.Now()
+	if a, b := NewReportId(now), NewReportId(now); a == b || a[:10] != b[:10] {
+		t.Errorf("newReportId = %q and %q, want the same time part and different random parts", a, b)
+	}
+}
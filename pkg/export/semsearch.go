@@ -0,0 +1,40 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+type SemsearchResult struct {
+	Report Report
+	Score  float64
+}
+
+// SemanticSearch returns the reports ranked by the similarity of their
+// embedding with the one of a query.
+func SemanticSearch(ctx context.Context, config EmbeddingConfig, store *EmbeddingStore, reports []Report, query string) ([]SemsearchResult, error) {
+	vectors, err := getEmbeddings(ctx, config, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("embedding query: %w", err)
+	}
+	queryVector := vectors[0]
+
+	var results []SemsearchResult
+	for _, report := range reports {
+		stored, ok := store.Entries[ReportFileName(report)]
+		if !ok {
+			continue
+		}
+		results = append(results, SemsearchResult{
+			Report: report,
+			Score:  CosineSimilarity(queryVector, stored.Vector),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	return results, nil
+}
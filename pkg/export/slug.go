@@ -0,0 +1,119 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	// Maximum length of a slug, before the number telling apart the
+	// articles sharing it.
+	MAX_SLUG_LENGTH = 80
+	// Slug of the titles without any letter or digit in the Latin alphabet
+	DEFAULT_SLUG = "article"
+)
+
+// slugTransliterations spells the Latin letters that are not plain ASCII.
+var slugTransliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ą': "a", 'ă': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ı': "i",
+	'ł': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'ţ': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+	'&': "and",
+}
+
+// Slugify returns a file name made of the lower case ASCII letters and digits
+// of a title separated by dashes, at most MAX_SLUG_LENGTH long, e.g.
+// "Écrire du Go: 10 astuces" becomes "ecrire-du-go-10-astuces".
+func Slugify(title string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if transliteration, ok := slugTransliterations[r]; ok {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteString(transliteration)
+			dash = false
+			continue
+		}
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+
+	slug := sb.String()
+	if len(slug) > MAX_SLUG_LENGTH {
+		slug = slug[:MAX_SLUG_LENGTH]
+		// Whole words only, when the title has some
+		if cut := strings.LastIndexByte(slug, '-'); cut > MAX_SLUG_LENGTH/2 {
+			slug = slug[:cut]
+		}
+	}
+	if slug == "" {
+		return DEFAULT_SLUG
+	}
+	return slug
+}
+
+// UniqueSlug returns the slug of an article, numbered with -2, -3, etc. when
+// a report of another article already has its file name in one of the output
+// formats. The reports of the article itself, from the index, are overwritten.
+func UniqueSlug(outputFolder, title string, formats []string, ownPaths []string) string {
+	slug := Slugify(title)
+	for number := 1; ; number++ {
+		candidate := slug
+		if number > 1 {
+			candidate = fmt.Sprintf("%s-%d", slug, number)
+		}
+		if !isSlugTaken(outputFolder, candidate, formats, ownPaths) {
+			return candidate
+		}
+	}
+}
+
+func isSlugTaken(outputFolder, slug string, formats []string, ownPaths []string) bool {
+	for _, format := range formats {
+		extension := outputFormatExtension(format)
+		if extension == "" {
+			continue
+		}
+		path := filepath.Join(outputFolder, slug+extension)
+		if slices.Contains(ownPaths, path) {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// outputFormatExtension returns the extension of the files of an output
+// format, empty for the formats appending to a shared file.
+func outputFormatExtension(format string) string {
+	switch format {
+	case OUTPUT_FORMAT_MARKDOWN:
+		return ".md"
+	case OUTPUT_FORMAT_JSON:
+		return ".json"
+	case OUTPUT_FORMAT_NDJSON:
+		return ""
+	}
+	return documentExporters[format].Extension
+}
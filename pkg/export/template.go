@@ -0,0 +1,111 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+// TemplateData is the data model of the report templates, which are Go
+// text/template templates, e.g.:
+//
+//	# {{.Article.Title}}
+//	{{range .Summary.Tags}}#{{.}} {{end}}
+type TemplateData struct {
+	// Article is the scraped article: .Article.Id, .Article.Title, .Article.Url,
+	// .Article.ContentType, .Article.Aliases, .Article.Content, .Article.Excerpt,
+	// .Article.Image and .Article.Cover (see -cover), .Article.ImageDescriptions,
+	// each with .Url, .Path (see -download-images) and .Description (see
+	// -describe-images), .Article.Tables, each with .Caption, .Header, .Rows and
+	// .Markdown (see -tables), .Article.Conflicts, each with .Kind, .Title, .Link,
+	// .Date and .Note (see -check-archive), .Article.ArchiveUrl and
+	// .Article.Archived (see -archive), .Article.Language (its detected language
+	// code) and the metadata of the page, .Article.Authors (or .Article.Author,
+	// comma separated), .Article.SiteName and .Article.Published.
+	Article Article
+	// Summary is the LLM summary: .Summary.Summary, .Summary.Keypoints and
+	// .Summary.Tags.
+	Summary summarize.Summary
+	// Date is the creation date of the report, as YYYY-MM-DD.
+	Date string
+	// Time is the creation time of the report, for other formats, e.g.
+	// {{.Time.Format "January 2, 2006"}}.
+	Time time.Time
+	// Host is the host name of the article, e.g. example.com.
+	Host string
+	// Published is the publication date of the article, as YYYY-MM-DD, empty
+	// if the page does not give it.
+	Published string
+	// Added is the date the article was added to the reading list it was
+	// imported from, as YYYY-MM-DD, empty if it was not imported.
+	Added string
+	// Content is the text of the article included in the report, as set by
+	// -include-content, and ContentHeading its heading ("Excerpt" or
+	// "Article"). Both are empty when the text is not included.
+	Content        string
+	ContentHeading string
+}
+
+func newTemplateData(article Article, now time.Time) TemplateData {
+	data := TemplateData{
+		Article: article,
+		Summary: *article.Summary,
+		Date:    now.Format(scrape.DATE_FORMAT),
+		Time:    now,
+		Host:    SiteNameFromUrl(article.Url),
+	}
+	if !article.Published.IsZero() {
+		data.Published = article.Published.Format(scrape.DATE_FORMAT)
+	}
+	if !article.Added.IsZero() {
+		data.Added = article.Added.Format(scrape.DATE_FORMAT)
+	}
+	return data
+}
+
+var TemplateFuncs = template.FuncMap{
+	"join":    strings.Join,
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"replace": strings.ReplaceAll,
+	"trim":    strings.TrimSpace,
+}
+
+// renderReportTemplate fills a report template. Templates written for the
+// KEY_* placeholders of the first versions are still supported.
+func renderReportTemplate(reportTemplate string, data TemplateData) (string, error) {
+	if isPlaceholderTemplate(reportTemplate) {
+		return renderPlaceholderTemplate(reportTemplate, data), nil
+	}
+
+	parsed, err := template.New("report").Funcs(TemplateFuncs).Option("missingkey=error").Parse(reportTemplate)
+	if err != nil {
+		return "", fmt.Errorf("parsing report template: %w", err)
+	}
+
+	var sb strings.Builder
+	if err := parsed.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("executing report template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+func isPlaceholderTemplate(reportTemplate string) bool {
+	return strings.Contains(reportTemplate, "KEY_") && !strings.Contains(reportTemplate, "{{")
+}
+
+func renderPlaceholderTemplate(reportTemplate string, data TemplateData) string {
+	return strings.NewReplacer(
+		"KEY_ARTICLE_TITLE", data.Article.Title,
+		"KEY_URL", data.Article.Url,
+		"KEY_CONTENT_TYPE", data.Article.ContentType,
+		"KEY_CREATION_DATE", data.Date,
+		"KEY_SUMMARY", data.Summary.Summary,
+		"KEY_KEYPOINTS", "- "+strings.Join(data.Summary.Keypoints, "\n- "),
+		"KEY_TAGS", "- "+strings.Join(data.Summary.Tags, "\n- "),
+	).Replace(reportTemplate)
+}
@@ -0,0 +1,339 @@
+package export
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	// Markers of the regions of a report written by the user, kept when the
+	// report is updated even within the sections written by the tool.
+	USER_REGION_START = "<!-- report:user -->"
+	USER_REGION_END   = "<!-- /report:user -->"
+	// Markers of the regions of a template managed by the tool. When a report
+	// has them, only these regions are rewritten, the rest of the report being
+	// the user's.
+	GENERATED_REGION_START = "<!-- report:generated -->"
+	GENERATED_REGION_END   = "<!-- /report:generated -->"
+)
+
+// Frontmatter keys whose value is kept when a report is updated.
+var preservedFrontmatterKeys = []string{"id", "date_created", "date"}
+
+// ReportSection is a part of the body of a report, from a heading to the next
+// one. The section before the first heading has no heading.
+type ReportSection struct {
+	Heading string
+	Lines   []string
+}
+
+func (s ReportSection) key(occurrence int) string {
+	return fmt.Sprintf("%s\x00%d", strings.TrimSpace(s.Heading), occurrence)
+}
+
+// UpdateArticle writes the report of an article over an existing report,
+// keeping what the user added to it (see mergeReportContent).
+func UpdateArticle(path string, permissions FilePermissions, article Article, template, frontmatter, includeContent string, encoding OutputEncoding) (string, error) {
+	content, err := RenderUpdatedArticle(path, article, template, frontmatter, includeContent)
+	if err != nil {
+		return "", err
+	}
+	if err := permissions.WriteFile(path, encoding.encode(content)); err != nil {
+		return "", fmt.Errorf("writing output file: %v", err)
+	}
+	slog.Info("Article updated successfully", "path", path)
+	return path, nil
+}
+
+// RenderUpdatedArticle renders the markdown report of an article merged with
+// its existing report at path.
+func RenderUpdatedArticle(path string, article Article, template, frontmatter, includeContent string) (string, error) {
+	generated, err := RenderArticle(article, template, frontmatter, includeContent)
+	if err != nil {
+		return "", err
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading report: %w", err)
+	}
+	return MergeReport(strings.TrimPrefix(NormalizeLineEndings(string(existing)), UTF8_BOM), generated), nil
+}
+
+// MergeReport merges a report regenerated by the tool with the existing one,
+// by generated regions when both have the same number of them (see
+// mergeGeneratedRegions), else by sections (see mergeReportContent).
+func MergeReport(existing, generated string) string {
+	if merged, ok := mergeGeneratedRegions(existing, generated); ok {
+		return merged
+	}
+	return mergeReportContent(existing, generated)
+}
+
+// mergeGeneratedRegions replaces the regions between GENERATED_REGION_START
+// and GENERATED_REGION_END of the existing report by those of the generated
+// one, in order, and merges their frontmatter as mergeReportContent does. The
+// rest of the existing report is kept as is. It returns false when the
+// generated report has no such region, or not as many as the existing one.
+func mergeGeneratedRegions(existing, generated string) (string, bool) {
+	existingFrontmatter, existingBody := SplitFrontmatter(existing)
+	generatedFrontmatter, generatedBody := SplitFrontmatter(generated)
+
+	outside, _ := splitGeneratedRegions(existingBody)
+	_, regions := splitGeneratedRegions(generatedBody)
+	if len(regions) == 0 || len(outside) != len(regions)+1 {
+		return "", false
+	}
+
+	var sb strings.Builder
+	switch {
+	case generatedFrontmatter != nil:
+		writeFrontmatter(&sb, mergeFrontmatter(existingFrontmatter, generatedFrontmatter))
+	case existingFrontmatter != nil:
+		writeFrontmatter(&sb, existingFrontmatter)
+	}
+	for i, lines := range outside {
+		if i > 0 {
+			lines = append(regions[i-1], lines...)
+		}
+		for _, line := range lines {
+			sb.WriteString(line + "\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n") + "\n", true
+}
+
+// splitGeneratedRegions returns the generated regions of the lines of a
+// report, markers included, and the parts of the lines around them, one more
+// than the regions. An unterminated region runs to the end of the lines.
+func splitGeneratedRegions(lines []string) ([][]string, [][]string) {
+	outside := [][]string{{}}
+	var regions [][]string
+	inRegion := false
+	for _, line := range lines {
+		switch {
+		case !inRegion && strings.TrimSpace(line) == GENERATED_REGION_START:
+			inRegion = true
+			regions = append(regions, []string{line})
+		case inRegion:
+			regions[len(regions)-1] = append(regions[len(regions)-1], line)
+			if strings.TrimSpace(line) == GENERATED_REGION_END {
+				inRegion = false
+				outside = append(outside, []string{})
+			}
+		default:
+			outside[len(outside)-1] = append(outside[len(outside)-1], line)
+		}
+	}
+	if inRegion {
+		outside = append(outside, []string{})
+	}
+	return outside, regions
+}
+
+func writeFrontmatter(sb *strings.Builder, lines []string) {
+	sb.WriteString("---\n")
+	for _, line := range lines {
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("---\n")
+}
+
+// mergeReportContent merges a report regenerated by the tool with the
+// existing one. The sections of the existing report that the generated one
+// does not have were added by the user and are kept after the section they
+// followed, as are the regions between USER_REGION_START and USER_REGION_END
+// in the generated sections. The frontmatter is the generated one, with the
+// keys added by the user and the creation date and ID of the existing report.
+func mergeReportContent(existing, generated string) string {
+	existingFrontmatter, existingBody := SplitFrontmatter(existing)
+	generatedFrontmatter, generatedBody := SplitFrontmatter(generated)
+
+	var sb strings.Builder
+	if generatedFrontmatter != nil {
+		writeFrontmatter(&sb, mergeFrontmatter(existingFrontmatter, generatedFrontmatter))
+	}
+
+	generatedSections := splitReportSections(generatedBody)
+	generatedKeys := map[string]bool{}
+	forEachSectionKey(generatedSections, func(key string, _ ReportSection) {
+		generatedKeys[key] = true
+	})
+
+	// Content of the user, by key of the generated section it follows
+	kept := map[string][]string{}
+	anchor := generatedSections[0].key(0)
+	forEachSectionKey(splitReportSections(existingBody), func(key string, section ReportSection) {
+		if section.Heading == "" {
+			// Text above the first heading is the user's when the template
+			// writes none
+			if isBlank(generatedSections[0].Lines) {
+				kept[anchor] = append(kept[anchor], trimBlankLines(section.Lines)...)
+			} else {
+				kept[anchor] = append(kept[anchor], UserRegions(section.Lines)...)
+			}
+			return
+		}
+		if generatedKeys[key] {
+			anchor = key
+			kept[anchor] = append(kept[anchor], UserRegions(section.Lines)...)
+			return
+		}
+		kept[anchor] = append(kept[anchor], section.Heading)
+		kept[anchor] = append(kept[anchor], section.Lines...)
+	})
+
+	forEachSectionKey(generatedSections, func(key string, section ReportSection) {
+		if section.Heading != "" {
+			sb.WriteString(section.Heading + "\n")
+		}
+		lines := section.Lines
+		if len(kept[key]) > 0 {
+			lines = append(trimBlankLines(lines), kept[key]...)
+		}
+		for _, line := range lines {
+			sb.WriteString(line + "\n")
+		}
+	})
+	return strings.TrimRight(sb.String(), "\n") + "\n"
+}
+
+// SplitFrontmatter returns the lines of the frontmatter of a markdown
+// document, nil if it has none, and the lines of its body.
+func SplitFrontmatter(content string) ([]string, []string) {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if len(lines) == 0 || lines[0] != "---" {
+		return nil, lines
+	}
+	for i := 1; i < len(lines); i++ {
+		if lines[i] == "---" {
+			return lines[1:i], lines[i+1:]
+		}
+	}
+	return nil, lines
+}
+
+// mergeFrontmatter returns the generated frontmatter, with the preserved keys
+// of the existing one and the keys only the existing one has.
+func mergeFrontmatter(existing, generated []string) []string {
+	existingEntries := splitFrontmatterEntries(existing)
+	generatedEntries := splitFrontmatterEntries(generated)
+
+	generatedKeys := map[string]bool{}
+	var merged []string
+	for _, entry := range generatedEntries {
+		generatedKeys[entry.key] = true
+		lines := entry.lines
+		for _, existingEntry := range existingEntries {
+			if existingEntry.key == entry.key && isPreservedFrontmatterKey(entry.key) {
+				lines = existingEntry.lines
+			}
+		}
+		merged = append(merged, lines...)
+	}
+	for _, entry := range existingEntries {
+		if !generatedKeys[entry.key] {
+			merged = append(merged, entry.lines...)
+		}
+	}
+	return merged
+}
+
+type frontmatterEntry struct {
+	key   string
+	lines []string
+}
+
+// splitFrontmatterEntries groups the lines of a frontmatter by key, a key
+// owning the indented and list lines following it.
+func splitFrontmatterEntries(lines []string) []frontmatterEntry {
+	var entries []frontmatterEntry
+	for _, line := range lines {
+		key, _, found := strings.Cut(line, ":")
+		isContinuation := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "-") || !found
+		if isContinuation && len(entries) > 0 {
+			entries[len(entries)-1].lines = append(entries[len(entries)-1].lines, line)
+			continue
+		}
+		entries = append(entries, frontmatterEntry{key: strings.TrimSpace(key), lines: []string{line}})
+	}
+	return entries
+}
+
+func isPreservedFrontmatterKey(key string) bool {
+	for _, preserved := range preservedFrontmatterKeys {
+		if key == preserved {
+			return true
+		}
+	}
+	return false
+}
+
+// splitReportSections splits the body of a report at its headings, ignoring
+// the lines of the code blocks. The first section, before any heading, has no
+// heading.
+func splitReportSections(lines []string) []ReportSection {
+	sections := []ReportSection{{}}
+	inCodeBlock := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			inCodeBlock = !inCodeBlock
+		}
+		if !inCodeBlock && isMarkdownHeading(line) {
+			sections = append(sections, ReportSection{Heading: line})
+			continue
+		}
+		sections[len(sections)-1].Lines = append(sections[len(sections)-1].Lines, line)
+	}
+	return sections
+}
+
+// forEachSectionKey calls fn with the sections and their key, the heading
+// numbered by its occurrences.
+func forEachSectionKey(sections []ReportSection, fn func(key string, section ReportSection)) {
+	occurrences := map[string]int{}
+	for _, section := range sections {
+		heading := strings.TrimSpace(section.Heading)
+		fn(section.key(occurrences[heading]), section)
+		occurrences[heading]++
+	}
+}
+
+func isMarkdownHeading(line string) bool {
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	return level >= 1 && level <= 6 && (len(line) == level || line[level] == ' ')
+}
+
+// UserRegions returns the regions of lines marked as written by the user,
+// markers included. An unterminated region runs to the end of the lines.
+func UserRegions(lines []string) []string {
+	var regions []string
+	inRegion := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == USER_REGION_START {
+			inRegion = true
+		}
+		if inRegion {
+			regions = append(regions, line)
+		}
+		if strings.TrimSpace(line) == USER_REGION_END {
+			inRegion = false
+		}
+	}
+	return regions
+}
+
+func isBlank(lines []string) bool {
+	return len(trimBlankLines(lines)) == 0
+}
+
+// trimBlankLines removes the blank lines at the end of lines.
+func trimBlankLines(lines []string) []string {
+	end := len(lines)
+	for end > 0 && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	return lines[:end]
+}
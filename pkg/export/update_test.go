@@ -0,0 +1,259 @@
+package export
+
+import (
+	"strings"
+	"testing"
+)
+
+func joinLines(l ...string) string {
+	return strings.Join(l, "\n") + "\n"
+}
+
+func TestMergeGeneratedRegions(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  string
+		generated string
+		want      string
+		merged    bool
+	}{
+		{
+			name: "regions replaced in order",
+			existing: joinLines(
+				"---",
+				"id: 01HXAAAAAAAAAAAAAAAAAAAAAA",
+				"title: Old title",
+				"rating: 5",
+				"---",
+				"My notes on top.",
+				GENERATED_REGION_START,
+				"Old summary",
+				GENERATED_REGION_END,
+				"Between the regions.",
+				"  "+GENERATED_REGION_START,
+				"- old keypoint",
+				"  "+GENERATED_REGION_END,
+				"My conclusion.",
+			),
+			generated: joinLines(
+				"---",
+				"id: 01HXBBBBBBBBBBBBBBBBBBBBBB",
+				"title: New title",
+				"---",
+				"# New title",
+				GENERATED_REGION_START,
+				"New summary",
+				GENERATED_REGION_END,
+				GENERATED_REGION_START,
+				"- new keypoint",
+				"- another keypoint",
+				GENERATED_REGION_END,
+			),
+			want: joinLines(
+				"---",
+				"id: 01HXAAAAAAAAAAAAAAAAAAAAAA",
+				"title: New title",
+				"rating: 5",
+				"---",
+				"My notes on top.",
+				GENERATED_REGION_START,
+				"New summary",
+				GENERATED_REGION_END,
+				"Between the regions.",
+				GENERATED_REGION_START,
+				"- new keypoint",
+				"- another keypoint",
+				GENERATED_REGION_END,
+				"My conclusion.",
+			),
+			merged: true,
+		},
+		{
+			name:      "existing frontmatter kept",
+			existing:  joinLines("---", "tags: [mine]", "---", GENERATED_REGION_START, "old", GENERATED_REGION_END),
+			generated: joinLines(GENERATED_REGION_START, "new", GENERATED_REGION_END),
+			want:      joinLines("---", "tags: [mine]", "---", GENERATED_REGION_START, "new", GENERATED_REGION_END),
+			merged:    true,
+		},
+		{
+			name:      "unterminated region",
+			existing:  joinLines("Notes", GENERATED_REGION_START, "old", "old too"),
+			generated: joinLines(GENERATED_REGION_START, "new"),
+			want:      joinLines("Notes", GENERATED_REGION_START, "new"),
+			merged:    true,
+		},
+		{
+			name:      "no generated region",
+			existing:  joinLines(GENERATED_REGION_START, "old", GENERATED_REGION_END),
+			generated: joinLines("# Title", "Summary"),
+		},
+		{
+			name:      "different number of regions",
+			existing:  joinLines(GENERATED_REGION_START, "old", GENERATED_REGION_END),
+			generated: joinLines(GENERATED_REGION_START, "a", GENERATED_REGION_END, GENERATED_REGION_START, "b", GENERATED_REGION_END),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged, ok := mergeGeneratedRegions(test.existing, test.generated)
+			if ok != test.merged {
+				t.Fatalf("mergeGeneratedRegions merged = %v, want %v", ok, test.merged)
+			}
+			if merged != test.want {
+				t.Errorf("mergeGeneratedRegions =\n%s\nwant\n%s", merged, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeReport(t *testing.T) {
+	generated := joinLines(
+		"---",
+		"id: 01HXBBBBBBBBBBBBBBBBBBBBBB",
+		"date_created: 2024-06-02",
+		"title: An article",
+		"tags:",
+		"  - new",
+		"---",
+		"# An article",
+		"",
+		"## Summary",
+		"New summary",
+		"",
+		"## Key points",
+		"- new point",
+	)
+	tests := []struct {
+		name     string
+		existing string
+		want     string
+	}{
+		{
+			name:     "unchanged report",
+			existing: generated,
+			want: joinLines(
+				"---",
+				"id: 01HXBBBBBBBBBBBBBBBBBBBBBB",
+				"date_created: 2024-06-02",
+				"title: An article",
+				"tags:",
+				"  - new",
+				"---",
+				"# An article",
+				"",
+				"## Summary",
+				"New summary",
+				"",
+				"## Key points",
+				"- new point",
+			),
+		},
+		{
+			name: "user sections, regions and keys kept",
+			existing: joinLines(
+				"---",
+				"id: 01HXAAAAAAAAAAAAAAAAAAAAAA",
+				"date_created: 2024-01-15",
+				"title: Old title",
+				"tags:",
+				"  - old",
+				"status: read",
+				"---",
+				"# An article",
+				"",
+				"## Summary",
+				"Old summary",
+				USER_REGION_START,
+				"My remark on the summary.",
+				USER_REGION_END,
+				"",
+				"## My notes",
+				"Notes of the user.",
+				"",
+				"## Key points",
+				"- old point",
+			),
+			want: joinLines(
+				"---",
+				"id: 01HXAAAAAAAAAAAAAAAAAAAAAA",
+				"date_created: 2024-01-15",
+				"title: An article",
+				"tags:",
+				"  - new",
+				"status: read",
+				"---",
+				"# An article",
+				"",
+				"## Summary",
+				"New summary",
+				USER_REGION_START,
+				"My remark on the summary.",
+				USER_REGION_END,
+				"## My notes",
+				"Notes of the user.",
+				"",
+				"## Key points",
+				"- new point",
+			),
+		},
+		{
+			name: "headings in code blocks",
+			existing: joinLines(
+				"# An article",
+				"",
+				"## Summary",
+				"Old summary",
+				"",
+				"## Snippets",
+				"```sh",
+				"## Summary",
+				"```",
+			),
+			want: joinLines(
+				"---",
+				"id: 01HXBBBBBBBBBBBBBBBBBBBBBB",
+				"date_created: 2024-06-02",
+				"title: An article",
+				"tags:",
+				"  - new",
+				"---",
+				"# An article",
+				"",
+				"## Summary",
+				"New summary",
+				"## Snippets",
+				"```sh",
+				"## Summary",
+				"```",
+				"## Key points",
+				"- new point",
+			),
+		},
+		{
+			name: "generated regions preferred",
+			existing: joinLines(
+				"My own introduction.",
+				GENERATED_REGION_START,
+				"Old",
+				GENERATED_REGION_END,
+			),
+			want: joinLines(
+				"My own introduction.",
+				GENERATED_REGION_START,
+				"New",
+				GENERATED_REGION_END,
+			),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			regenerated := generated
+			if strings.Contains(test.existing, GENERATED_REGION_START) {
+				regenerated = joinLines(GENERATED_REGION_START, "New", GENERATED_REGION_END)
+			}
+			if merged := MergeReport(test.existing, regenerated); merged != test.want {
+				t.Errorf("mergeReport =\n%s\nwant\n%s", merged, test.want)
+			}
+		})
+	}
+}
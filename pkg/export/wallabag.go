@@ -0,0 +1,332 @@
+package export
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+const (
+	EXPORT_WALLABAG = "wallabag"
+	// Number of entries per page of the Wallabag API
+	WALLABAG_PAGE_SIZE = 100
+	// Start of the text of the annotations holding the summaries, telling
+	// them from the annotations of the user
+	WALLABAG_SUMMARY_PREFIX = "Summary: "
+)
+
+// WallabagClient calls the REST API of a Wallabag server, to read its entries
+// and write the summaries back to them as annotations.
+type WallabagClient struct {
+	// Address of the server, e.g. https://app.wallabag.it
+	Url string
+	// OAuth client of the API and account of the user
+	ClientId     string
+	ClientSecret string
+	Username     string
+	Password     string
+
+	retry   scrape.RetryPolicy
+	timeout time.Duration
+
+	mu sync.Mutex
+	// Access token of the API and its expiration, shared by the concurrent
+	// exports
+	accessToken string
+	expires     time.Time
+}
+
+type WallabagEntry struct {
+	Id    int    `json:"id"`
+	Url   string `json:"url"`
+	Title string `json:"title"`
+	// Date the entry was saved, e.g. 2024-03-01T10:20:30+0100
+	CreatedAt   string               `json:"created_at"`
+	Tags        []WallabagTag        `json:"tags"`
+	Annotations []WallabagAnnotation `json:"annotations"`
+}
+
+type WallabagTag struct {
+	Label string `json:"label"`
+}
+
+type WallabagAnnotation struct {
+	Id    int    `json:"id"`
+	Text  string `json:"text"`
+	Quote string `json:"quote"`
+}
+
+type WallabagEntriesPage struct {
+	Page     int `json:"page"`
+	Pages    int `json:"pages"`
+	Embedded struct {
+		Items []WallabagEntry `json:"items"`
+	} `json:"_embedded"`
+}
+
+type WallabagTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type WallabagErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	Message          string `json:"message"`
+}
+
+// NewWallabagClient returns the client of the Wallabag server set by
+// WALLABAG_URL, WALLABAG_CLIENT_ID, WALLABAG_CLIENT_SECRET, WALLABAG_USERNAME
+// and WALLABAG_PASSWORD, or by the config file. Its calls are retried with
+// the policy, and limited to the timeout.
+func NewWallabagClient(config WallabagConfig, retry scrape.RetryPolicy, timeout time.Duration) (*WallabagClient, error) {
+	client := &WallabagClient{
+		Url:          strings.TrimSuffix(cmp.Or(os.Getenv("WALLABAG_URL"), config.Url), "/"),
+		ClientId:     cmp.Or(os.Getenv("WALLABAG_CLIENT_ID"), config.ClientId),
+		ClientSecret: cmp.Or(os.Getenv("WALLABAG_CLIENT_SECRET"), config.ClientSecret),
+		Username:     cmp.Or(os.Getenv("WALLABAG_USERNAME"), config.Username),
+		Password:     cmp.Or(os.Getenv("WALLABAG_PASSWORD"), config.Password),
+		retry:        retry,
+		timeout:      timeout,
+	}
+	for _, setting := range []struct{ name, value string }{
+		{"WALLABAG_URL", client.Url},
+		{"WALLABAG_CLIENT_ID", client.ClientId},
+		{"WALLABAG_CLIENT_SECRET", client.ClientSecret},
+		{"WALLABAG_USERNAME", client.Username},
+		{"WALLABAG_PASSWORD", client.Password},
+	} {
+		if setting.value == "" {
+			return nil, fmt.Errorf("%s environment variable is not set", setting.name)
+		}
+	}
+	return client, nil
+}
+
+// Created returns the date the entry was saved, zero if unknown.
+func (e WallabagEntry) Created() time.Time {
+	for _, layout := range []string{"2006-01-02T15:04:05-0700", time.RFC3339} {
+		if created, err := time.Parse(layout, e.CreatedAt); err == nil {
+			return created
+		}
+	}
+	return time.Time{}
+}
+
+// SummaryAnnotation returns the annotation holding the summary of the entry,
+// false if it has none.
+func (e WallabagEntry) SummaryAnnotation() (WallabagAnnotation, bool) {
+	for _, annotation := range e.Annotations {
+		if strings.HasPrefix(annotation.Text, WALLABAG_SUMMARY_PREFIX) {
+			return annotation, true
+		}
+	}
+	return WallabagAnnotation{}, false
+}
+
+// Export writes the summary of an article to its entry as an annotation, the
+// annotation of a previous summary being updated, and adds the tags of the
+// article to the entry. The entry of the article url is created if entry has
+// no id.
+func (c *WallabagClient) Export(ctx context.Context, article Article, entry WallabagEntry) error {
+	if err := checkArticleIsComplete(article); err != nil {
+		return err
+	}
+	tags := strings.Join(article.Summary.Tags, ",")
+	if entry.Id == 0 {
+		// The entry of an url already saved is returned as is
+		body := map[string]string{"url": article.Url, "title": article.Title, "tags": tags}
+		err := c.retry.Do(ctx, "creating the Wallabag entry of "+article.Url, func() error {
+			return c.request(ctx, "POST", "/entries.json", body, &entry)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	text := wallabagSummary(article)
+	annotation, found := entry.SummaryAnnotation()
+	err := c.retry.Do(ctx, "writing the Wallabag annotation of "+article.Url, func() error {
+		if found {
+			return c.request(ctx, "PUT", fmt.Sprintf("/annotations/%d.json", annotation.Id), map[string]string{"text": text}, nil)
+		}
+		// The annotation is not attached to a passage of the article
+		body := map[string]any{
+			"text":   text,
+			"quote":  article.Title,
+			"ranges": []map[string]any{{"start": "", "startOffset": 0, "end": "", "endOffset": 0}},
+		}
+		return c.request(ctx, "POST", fmt.Sprintf("/annotations/%d.json", entry.Id), body, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	if tags == "" {
+		return nil
+	}
+	return c.retry.Do(ctx, "tagging the Wallabag entry of "+article.Url, func() error {
+		return c.request(ctx, "POST", fmt.Sprintf("/entries/%d/tags.json", entry.Id), map[string]string{"tags": tags}, nil)
+	})
+}
+
+// wallabagSummary returns the text of the summary annotation, in plain text:
+// the summary followed by the key points.
+func wallabagSummary(article Article) string {
+	doc := newReportDocument(article, time.Now())
+	var sb strings.Builder
+	sb.WriteString(WALLABAG_SUMMARY_PREFIX + strings.TrimSpace(doc.Summary))
+	if len(doc.Keypoints) > 0 {
+		sb.WriteString("\n\n" + doc.KeypointsHeading + ":")
+		for _, keypoint := range doc.Keypoints {
+			sb.WriteString("\n- " + keypoint)
+		}
+	}
+	return sb.String()
+}
+
+// ListEntries returns the unread entries of the server, following the pages
+// of the API.
+func (c *WallabagClient) ListEntries(ctx context.Context) ([]WallabagEntry, error) {
+	var entries []WallabagEntry
+	for page := 1; ; page++ {
+		query := url.Values{
+			"archive": {"0"},
+			"detail":  {"metadata"},
+			"perPage": {fmt.Sprint(WALLABAG_PAGE_SIZE)},
+			"page":    {fmt.Sprint(page)},
+		}
+		var entriesPage WallabagEntriesPage
+		err := c.retry.Do(ctx, "listing the Wallabag entries", func() error {
+			return c.request(ctx, "GET", "/entries.json?"+query.Encode(), nil, &entriesPage)
+		})
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entriesPage.Embedded.Items...)
+		if page >= entriesPage.Pages {
+			return entries, nil
+		}
+	}
+}
+
+// token returns the access token of the API, asking a new one with the
+// password of the user when it is missing or expired.
+func (c *WallabagClient) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessToken != "" && time.Now().Before(c.expires) {
+		return c.accessToken, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"password"},
+		"client_id":     {c.ClientId},
+		"client_secret": {c.ClientSecret},
+		"username":      {c.Username},
+		"password":      {c.Password},
+	}
+	var token WallabagTokenResponse
+	tokenUrl := c.Url + "/oauth/v2/token"
+	if err := c.send(ctx, "POST", tokenUrl, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), "", &token); err != nil {
+		return "", err
+	}
+	c.accessToken = token.AccessToken
+	// Renewed a minute before it expires
+	c.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return c.accessToken, nil
+}
+
+// request calls the Wallabag API, decoding its answer into result unless it
+// is nil.
+func (c *WallabagClient) request(ctx context.Context, method, path string, body, result any) error {
+	var jsonData []byte
+	if body != nil {
+		var err error
+		jsonData, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshaling Wallabag request: %w", err)
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		accessToken, err := c.token(ctx)
+		if err != nil {
+			return fmt.Errorf("authenticating to Wallabag: %w", err)
+		}
+		var reader io.Reader
+		if jsonData != nil {
+			reader = bytes.NewReader(jsonData)
+		}
+		err = c.send(ctx, method, c.Url+"/api"+path, "application/json", reader, accessToken, result)
+
+		// A token revoked before its expiration is asked again, once
+		var fetchErr *scrape.FetchError
+		if attempt == 0 && errors.As(err, &fetchErr) && fetchErr.StatusCode == http.StatusUnauthorized {
+			c.mu.Lock()
+			c.accessToken = ""
+			c.mu.Unlock()
+			continue
+		}
+		return err
+	}
+}
+
+func (c *WallabagClient) send(ctx context.Context, method, apiUrl, contentType string, body io.Reader, accessToken string, result any) error {
+	ctx, cancel := scrape.WithTimeout(ctx, c.timeout, "Wallabag API call")
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, apiUrl, body)
+	if err != nil {
+		return &scrape.FetchError{Url: apiUrl, Err: err}
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &scrape.FetchError{Url: apiUrl, Err: scrape.TimeoutCause(ctx, err)}
+	}
+	defer res.Body.Close()
+
+	fetchErr := scrape.FetchError{Url: apiUrl, StatusCode: res.StatusCode, Status: res.Status, RetryAfter: scrape.ParseRetryAfter(res.Header.Get("Retry-After"))}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		fetchErr.Err = fmt.Errorf("reading body: %w", scrape.TimeoutCause(ctx, err))
+		return &fetchErr
+	}
+	if res.StatusCode >= 400 {
+		var wallabagErr WallabagErrorResponse
+		json.Unmarshal(data, &wallabagErr)
+		switch {
+		case wallabagErr.ErrorDescription != "":
+			fetchErr.Err = fmt.Errorf("%s: %s", wallabagErr.Error, wallabagErr.ErrorDescription)
+		case wallabagErr.Message != "":
+			fetchErr.Err = fmt.Errorf("%s", wallabagErr.Message)
+		default:
+			fetchErr.Err = fmt.Errorf("unexpected response status")
+		}
+		return &fetchErr
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("unmarshaling Wallabag response: %w", err)
+	}
+	return nil
+}
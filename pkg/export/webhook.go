@@ -0,0 +1,117 @@
+package export
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const EXPORT_WEBHOOK = "webhook"
+
+// WebhookExporter posts the JSON reports, as written by -format json, to an
+// url, e.g. the API of a personal knowledge base.
+type WebhookExporter struct {
+	Url string
+	// Sent as a bearer token if set
+	Token string
+	// Key of the HMAC-SHA256 signature of the requests, if set
+	Secret string
+
+	Retry   scrape.RetryPolicy
+	timeout time.Duration
+}
+
+// NewWebhookExporter returns the exporter of a webhook, its url defaulting to
+// REPORT_WEBHOOK_URL or the config file and its token and signing secret
+// being read from REPORT_WEBHOOK_TOKEN and REPORT_WEBHOOK_SECRET or the
+// config file. Its calls are retried with the policy, and limited to the
+// timeout.
+func NewWebhookExporter(webhookUrl string, config WebhookConfig, retry scrape.RetryPolicy, timeout time.Duration) (*WebhookExporter, error) {
+	webhookUrl = cmp.Or(webhookUrl, os.Getenv("REPORT_WEBHOOK_URL"), config.Url)
+	if webhookUrl == "" {
+		return nil, fmt.Errorf("the webhook export needs an url, use -export %s=<url> or set REPORT_WEBHOOK_URL", EXPORT_WEBHOOK)
+	}
+	parsed, err := url.Parse(webhookUrl)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid webhook url '%s', expected an http or https url", webhookUrl)
+	}
+	return &WebhookExporter{
+		Url:     webhookUrl,
+		Token:   cmp.Or(os.Getenv("REPORT_WEBHOOK_TOKEN"), config.Token),
+		Secret:  cmp.Or(os.Getenv("REPORT_WEBHOOK_SECRET"), config.Secret),
+		Retry:   retry,
+		timeout: timeout,
+	}, nil
+}
+
+// Post sends the JSON report of an article to the webhook.
+func (e *WebhookExporter) Post(ctx context.Context, article Article, usage summarize.Usage) error {
+	if err := checkArticleIsComplete(article); err != nil {
+		return err
+	}
+	jsonData, err := json.Marshal(newJsonReport(article, usage, time.Now()))
+	if err != nil {
+		return fmt.Errorf("encoding JSON report: %w", err)
+	}
+	return e.Retry.Do(ctx, "posting the report of "+article.Url+" to "+e.Url, func() error {
+		return e.Send(ctx, jsonData)
+	})
+}
+
+// Send posts a JSON document to the webhook, signed with its secret if any.
+func (e *WebhookExporter) Send(ctx context.Context, jsonData []byte) error {
+	ctx, cancel := scrape.WithTimeout(ctx, e.timeout, "webhook call")
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.Url, bytes.NewReader(jsonData))
+	if err != nil {
+		return &scrape.FetchError{Url: e.Url, Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.Token)
+	}
+	if e.Secret != "" {
+		// Each attempt is signed with its own timestamp, so that the receiver
+		// can refuse the replays of old requests
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Report-Timestamp", timestamp)
+		req.Header.Set("X-Report-Signature", "sha256="+webhookSignature(e.Secret, timestamp, jsonData))
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &scrape.FetchError{Url: e.Url, Err: scrape.TimeoutCause(ctx, err)}
+	}
+	defer res.Body.Close()
+	// The response is not used, it is read for the connection to be reused
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode >= 300 {
+		return &scrape.FetchError{Url: e.Url, StatusCode: res.StatusCode, Status: res.Status, RetryAfter: scrape.ParseRetryAfter(res.Header.Get("Retry-After")), Err: fmt.Errorf("unexpected response status")}
+	}
+	return nil
+}
+
+// webhookSignature returns the hex HMAC-SHA256 of a request, computed with
+// the secret over its timestamp, a dot and its body.
+func webhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
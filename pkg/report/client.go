@@ -0,0 +1,259 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+// Client creates reports with the pipeline of the command line tool, for the
+// Go programs embedding it. Without output folder, the articles are only
+// summarized; with one, their reports are written to it, and the articles
+// already summarized into it are skipped. A client can be used by several
+// goroutines at once.
+type Client struct {
+	options ReportOptions
+	// Options of the default provider, and cache of the answers of the
+	// providers
+	providers summarize.ProviderOptions
+	// Provider given by WithProvider, nil for the default one
+	provider summarize.LLMProvider
+	hooks    pipelineHooks
+}
+
+// Option configures a Client, see New.
+type Option func(c *Client) error
+
+// Result is the report of an url created by a Client.
+type Result struct {
+	// Url as given, and the one of the article, once redirected or read from
+	// its canonical link
+	Url        string
+	ArticleUrl string
+	// RESULT_CREATED, or RESULT_SKIPPED for an article already summarized
+	// into the output folder
+	Status      string
+	Id          string
+	Title       string
+	ContentType string
+	Authors     []string
+	SiteName    string
+	// Zero when the page does not tell it
+	Published time.Time
+	// ISO 639-1 code of the language of the article, empty if unknown
+	Language  string
+	Summary   string
+	Keypoints []string
+	Tags      []string
+	// Files written to the output folder, none without output folder
+	OutputPaths []string
+	// Tokens consumed by the LLM calls made for the article, and their cost
+	Usage summarize.Usage
+}
+
+// New returns a client summarizing with the default provider and profile of
+// the tool, unless options tell otherwise.
+func New(opts ...Option) (*Client, error) {
+	prompt, err := summarize.GetProfilePrompt(summarize.DEFAULT_PROFILE)
+	if err != nil {
+		return nil, err
+	}
+	tokenizers, err := summarize.NewTokenizers(nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{providers: summarize.DefaultProviderOptions(), options: ReportOptions{
+		Scraper:        scrape.New(),
+		HostLimiter:    scrape.NewHostLimiter(time.Second),
+		Frontmatter:    export.FRONTMATTER_TEMPLATE,
+		SystemPrompt:   prompt,
+		Profile:        summarize.DEFAULT_PROFILE,
+		ChunkTokens:    summarize.DEFAULT_CHUNK_TOKENS,
+		Tokenizers:     tokenizers,
+		IncludeContent: export.INCLUDE_CONTENT_NONE,
+		ExcerptMode:    summarize.EXCERPT_PARAGRAPHS,
+		ExcerptLength:  summarize.DEFAULT_EXCERPT_LENGTH,
+		CoverMode:      export.COVER_NONE,
+		NonInteractive: true,
+	}}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(c.hooks.fetched) > 0 {
+		c.options.Scraper.OnFetched = c.hooks.runFetched
+	}
+	switch {
+	case c.provider != nil:
+		c.options.Provider = c.providers.Cached(c.provider, summarize.ProviderModel(c.provider))
+	case c.options.Provider == nil:
+		c.options.Provider, err = summarize.NewLLMProvider(summarize.DEFAULT_LLM_PROVIDER, c.providers)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.options.VisionProvider == nil {
+		c.options.VisionProvider = c.options.Provider
+	}
+	if c.options.OutputFolder != "" {
+		if len(c.options.Formats) == 0 && !c.options.Stdout {
+			c.options.Formats = []string{export.OUTPUT_FORMAT_MARKDOWN}
+		}
+		if c.options.Index == nil {
+			c.options.Index, err = export.LoadArticleIndex(c.options.OutputFolder, c.options.Permissions)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return c, nil
+}
+
+// WithReportOptions configures the whole pipeline, replacing the defaults
+// and the options given before it, e.g. for a program exposing the options of
+// the tool as its command line does. The provider, the index of the output
+// folder and the formats left empty are set by New.
+func WithReportOptions(options ReportOptions) Option {
+	return func(c *Client) error {
+		if options.Scraper == nil || options.HostLimiter == nil {
+			return fmt.Errorf("no scraper or host limiter in the report options")
+		}
+		c.options = options
+		return nil
+	}
+}
+
+// WithProvider summarizes with a provider, e.g. one of NewProvider or one of
+// the program. Its answers are cached like the ones of the providers of the
+// tool, see WithCache.
+func WithProvider(provider summarize.LLMProvider) Option {
+	return func(c *Client) error {
+		if provider == nil {
+			return fmt.Errorf("no LLM provider")
+		}
+		c.provider = provider
+		return nil
+	}
+}
+
+// WithTemplate renders the markdown reports of every content type with a Go
+// text/template file instead of the embedded templates.
+func WithTemplate(path string) Option {
+	return func(c *Client) error {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("reading template: %w", err)
+		}
+		c.options.Template = path
+		return nil
+	}
+}
+
+// WithCache enables or disables the cache of the fetched pages and of the LLM
+// answers of the client, enabled by default. The cache is in the cache
+// directory of the tool, shared with its runs.
+func WithCache(enabled bool) Option {
+	return func(c *Client) error {
+		c.options.Scraper.Cache.Disabled = !enabled
+		c.providers.Cache.Disabled = !enabled
+		return nil
+	}
+}
+
+// WithOutputFolder writes the reports to a folder, as markdown unless
+// WithFormats tells otherwise.
+func WithOutputFolder(folder string) Option {
+	return func(c *Client) error {
+		c.options.OutputFolder = folder
+		return nil
+	}
+}
+
+// WithFormats writes the reports in these formats, e.g. markdown or pdf.
+func WithFormats(formats ...string) Option {
+	return func(c *Client) error {
+		parsed, err := export.ParseOutputFormats(strings.Join(formats, ","))
+		if err != nil {
+			return err
+		}
+		c.options.Formats = parsed
+		return nil
+	}
+}
+
+// WithProfile summarizes with a prompt profile, embedded or from the profiles
+// folder of the config directory.
+func WithProfile(name string) Option {
+	return func(c *Client) error {
+		prompt, err := summarize.GetProfilePrompt(name)
+		if err != nil {
+			return err
+		}
+		c.options.Profile, c.options.SystemPrompt = name, prompt
+		return nil
+	}
+}
+
+// Options returns the options of the pipeline of the client.
+func (c *Client) Options() ReportOptions {
+	return c.options
+}
+
+// Derive returns a client sharing the provider, the hooks and the index of c,
+// with its options changed by change, e.g. for the articles of a feed.
+func (c *Client) Derive(change func(options *ReportOptions)) *Client {
+	derived := *c
+	change(&derived.options)
+	return &derived
+}
+
+// Create runs the pipeline on the article of an url and returns its result,
+// failed, skipped or previewed included, like the command line tool does.
+func (c *Client) Create(ctx context.Context, articleUrl string) ArticleResult {
+	return createReport(withPipelineHooks(ctx, &c.hooks), articleUrl, c.options)
+}
+
+// CreateAll runs the pipeline on the articles of urls with concurrency
+// workers, and returns their results in the order of the urls. A panic fails
+// its article, not the others.
+func (c *Client) CreateAll(ctx context.Context, articleUrls []string, concurrency int) []ArticleResult {
+	return processArticles(withPipelineHooks(ctx, &c.hooks), articleUrls, c.options, concurrency)
+}
+
+// Summarize scrapes and summarizes the article of an url, and writes its
+// reports to the output folder if any. The errors are *StageError values,
+// telling the stage that failed.
+func (c *Client) Summarize(ctx context.Context, articleUrl string) (Result, error) {
+	result := c.Create(ctx, articleUrl)
+	if result.Err != nil {
+		return Result{}, result.Err
+	}
+	article := result.Article
+	summarized := Result{
+		Url:         result.Url,
+		ArticleUrl:  article.Url,
+		Status:      result.Status,
+		Id:          article.Id,
+		Title:       article.Title,
+		ContentType: article.ContentType,
+		Authors:     article.Authors,
+		SiteName:    article.SiteName,
+		Published:   article.Published,
+		Language:    article.Language,
+		OutputPaths: result.OutputPaths,
+		Usage:       result.Usage,
+	}
+	if article.Summary != nil {
+		summarized.Summary = article.Summary.Summary
+		summarized.Keypoints = article.Summary.Keypoints
+		summarized.Tags = article.Summary.Tags
+	}
+	return summarized, nil
+}
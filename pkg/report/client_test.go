@@ -0,0 +1,210 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/brequet/report/internal/paths"
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const TEST_ARTICLE_PAGE = `<!DOCTYPE html>
+<html lang="en">
+<head><title>Testing the pipeline</title></head>
+<body>
+<article>
+<h1>Testing the pipeline</h1>
+<p>The pipeline of the report tool scrapes an article, summarizes it with a language model and writes its report to the output folder, one stage after the other.</p>
+<p>Each stage can fail on its own: the page may not be found, the model may answer with something that is not the expected JSON, and the report may not be written.</p>
+<p>These paragraphs only need to be long enough for the extraction to keep them as the content of the article rather than as the noise around it.</p>
+</article>
+</body>
+</html>`
+
+const TEST_SUMMARY_ANSWER = `{"summary": "The pipeline is tested.", "keypoints": ["It scrapes", "It summarizes"], "tags": ["testing"]}`
+
+// testProvider answers every call with the same answer, and counts them.
+type testProvider struct {
+	answer string
+	err    error
+	calls  atomic.Int32
+}
+
+func (p *testProvider) Name() string {
+	return "test"
+}
+
+func (p *testProvider) Complete(ctx context.Context, messages []summarize.ChatMessage) (string, error) {
+	p.calls.Add(1)
+	return p.answer, p.err
+}
+
+// newTestArticleServer serves the test article on /article and a 404 on the
+// other paths, in directories of the test.
+func newTestArticleServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	for _, env := range []string{paths.CONFIG_DIR_ENV, paths.CACHE_DIR_ENV, paths.DATA_DIR_ENV, paths.STATE_DIR_ENV} {
+		t.Setenv(env, t.TempDir())
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/article" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, TEST_ARTICLE_PAGE)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestClient returns a client summarizing with provider, without cache,
+// rendering nor Wayback Machine copies.
+func newTestClient(t *testing.T, provider summarize.LLMProvider, opts ...Option) *Client {
+	t.Helper()
+	client, err := New(append([]Option{WithProvider(provider), WithCache(false)}, opts...)...)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	client.options.Scraper.Renderer.Mode = scrape.RENDER_NEVER
+	client.options.Scraper.Archive = scrape.ARCHIVE_NEVER
+	return client
+}
+
+func TestClientSummarize(t *testing.T) {
+	server := newTestArticleServer(t)
+	provider := &testProvider{answer: TEST_SUMMARY_ANSWER}
+	var exportedPaths []string
+	client := newTestClient(t, provider,
+		WithOutputFolder(t.TempDir()),
+		OnExtracted(func(ctx context.Context, article *export.Article) error {
+			article.ContentType = "video"
+			return nil
+		}),
+		OnSummarized(func(ctx context.Context, article *export.Article) error {
+			article.Summary.Tags = append(article.Summary.Tags, "work")
+			return nil
+		}),
+		OnExported(func(ctx context.Context, article export.Article, outputPaths []string) error {
+			exportedPaths = outputPaths
+			return nil
+		}),
+	)
+
+	result, err := client.Summarize(context.Background(), server.URL+"/article")
+	if err != nil {
+		t.Fatalf("Summarize() = %v", err)
+	}
+	if result.Status != RESULT_CREATED || result.Title != "Testing the pipeline" || result.Summary != "The pipeline is tested." {
+		t.Errorf("Summarize() = %s %q %q, want created with the title and the summary", result.Status, result.Title, result.Summary)
+	}
+	if result.ContentType != "video" || !slices.Equal(result.Tags, []string{"testing", "work"}) {
+		t.Errorf("Summarize() = content type %s, tags %v, want the ones of the hooks", result.ContentType, result.Tags)
+	}
+	if len(result.OutputPaths) != 1 || !slices.Equal(exportedPaths, result.OutputPaths) {
+		t.Fatalf("Summarize() output paths = %v, exported %v, want one markdown report", result.OutputPaths, exportedPaths)
+	}
+	if _, err := os.Stat(result.OutputPaths[0]); err != nil {
+		t.Errorf("report not written: %v", err)
+	}
+
+	// The article is in the index of the output folder once summarized
+	result, err = client.Summarize(context.Background(), server.URL+"/article")
+	if err != nil || result.Status != RESULT_SKIPPED {
+		t.Errorf("Summarize() again = %s, %v, want skipped", result.Status, err)
+	}
+	if calls := provider.calls.Load(); calls != 1 {
+		t.Errorf("provider called %d times, want 1", calls)
+	}
+}
+
+func TestClientSummarizeError(t *testing.T) {
+	server := newTestArticleServer(t)
+	client := newTestClient(t, &testProvider{err: errors.New("overloaded")})
+
+	_, err := client.Summarize(context.Background(), server.URL+"/article")
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || stageErr.Stage != STAGE_SUMMARIZE {
+		t.Errorf("Summarize() = %v, want a summarize StageError", err)
+	}
+}
+
+func TestClientCreateAll(t *testing.T) {
+	server := newTestArticleServer(t)
+	client := newTestClient(t, &testProvider{answer: TEST_SUMMARY_ANSWER})
+
+	articleUrls := []string{server.URL + "/article", server.URL + "/missing"}
+	results := client.CreateAll(context.Background(), articleUrls, 2)
+	if len(results) != 2 {
+		t.Fatalf("CreateAll() = %d results, want 2", len(results))
+	}
+	if results[0].Url != articleUrls[0] || results[0].Status != RESULT_CREATED {
+		t.Errorf("CreateAll() first = %s %s, %v, want created", results[0].Url, results[0].Status, results[0].Err)
+	}
+	var stageErr *StageError
+	if results[1].Url != articleUrls[1] || results[1].Status != RESULT_FAILED || !errors.As(results[1].Err, &stageErr) || stageErr.Stage != STAGE_SCRAPE {
+		t.Errorf("CreateAll() second = %s %s, %v, want a scrape failure", results[1].Url, results[1].Status, results[1].Err)
+	}
+}
+
+func TestClientDerive(t *testing.T) {
+	client := newTestClient(t, &testProvider{})
+	derived := client.Derive(func(options *ReportOptions) {
+		options.Feed = "https://example.com/feed"
+	})
+	if derived.Options().Feed != "https://example.com/feed" || client.Options().Feed != "" {
+		t.Errorf("Derive() feeds = %q and %q, want only the derived client changed", derived.Options().Feed, client.Options().Feed)
+	}
+	if derived.Options().Provider != client.Options().Provider {
+		t.Errorf("Derive() does not share the provider")
+	}
+}
+
+func TestClientOptionsNotShared(t *testing.T) {
+	t.Setenv(paths.CONFIG_DIR_ENV, t.TempDir())
+	uncached := newTestClient(t, &testProvider{})
+	cached, err := New(WithProvider(&testProvider{}))
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	if !uncached.options.Scraper.Cache.Disabled || cached.options.Scraper.Cache.Disabled {
+		t.Errorf("cache disabled = %v and %v, want only the client without cache", uncached.options.Scraper.Cache.Disabled, cached.options.Scraper.Cache.Disabled)
+	}
+	if uncached.options.Scraper == cached.options.Scraper || uncached.options.HostLimiter == cached.options.HostLimiter {
+		t.Errorf("clients share their scraper or their host limiter")
+	}
+}
+
+func TestWithReportOptions(t *testing.T) {
+	t.Setenv(paths.CONFIG_DIR_ENV, t.TempDir())
+	provider := &testProvider{}
+	options := ReportOptions{
+		Scraper:     scrape.New(),
+		HostLimiter: scrape.NewHostLimiter(0),
+		Provider:    provider,
+		Stdout:      true,
+	}
+	client, err := New(WithReportOptions(options))
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	if client.options.Provider != provider || client.options.VisionProvider != provider {
+		t.Errorf("New() did not keep the provider of the options")
+	}
+	if client.options.Profile != "" || len(client.options.Formats) != 0 {
+		t.Errorf("New() = profile %q, formats %v, want the options as given", client.options.Profile, client.options.Formats)
+	}
+
+	if _, err := New(WithReportOptions(ReportOptions{})); err == nil {
+		t.Errorf("New() without scraper = nil, want an error")
+	}
+}
@@ -0,0 +1,55 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// processArticles creates the reports of the urls with a pool of workers, and
+// returns the results in the order of the urls.
+func processArticles(ctx context.Context, articleUrls []string, options ReportOptions, concurrency int) []ArticleResult {
+	results := make([]ArticleResult, len(articleUrls))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range max(1, min(concurrency, len(articleUrls))) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = processArticle(ctx, articleUrls, i, options)
+			}
+		}()
+	}
+
+	// Once interrupted, the articles not started yet are not processed
+	for i := range articleUrls {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			results[i] = ArticleResult{Url: articleUrls[i], Status: RESULT_FAILED, Err: fmt.Errorf("not processed: %w", context.Cause(ctx))}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func processArticle(ctx context.Context, articleUrls []string, i int, options ReportOptions) (result ArticleResult) {
+	// A panic fails the article, not the whole batch
+	defer func() {
+		if err := NewPanicError(recover(), articleUrls[i]); err != nil {
+			result = ArticleResult{Url: articleUrls[i], Status: RESULT_FAILED, Err: err}
+		}
+	}()
+
+	slog.Info(fmt.Sprintf("[%d/%d] %s", i+1, len(articleUrls), articleUrls[i]))
+	result = createReport(ctx, articleUrls[i], options)
+	if result.Err != nil {
+		slog.Error("article failed", "url", articleUrls[i], "err", result.Err)
+	}
+	return result
+}
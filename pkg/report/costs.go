@@ -0,0 +1,119 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/brequet/report/internal/paths"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const (
+	COSTS_FILE_NAME = "costs.ndjson"
+
+	COSTS_BY_FEED    = "feed"
+	COSTS_BY_PROFILE = "profile"
+	COSTS_BY_TAG     = "tag"
+)
+
+// CostRecord is the usage of an article, with what it is attributed to: the
+// feed or service it was read from, the prompt profile and the tags of its
+// summary.
+type CostRecord struct {
+	Date    time.Time       `json:"date"`
+	Url     string          `json:"url"`
+	Feed    string          `json:"feed,omitempty"`
+	Profile string          `json:"profile,omitempty"`
+	Tags    []string        `json:"tags,omitempty"`
+	Usage   summarize.Usage `json:"usage"`
+}
+
+// costsMutex serializes the appends of the articles processed concurrently.
+var costsMutex sync.Mutex
+
+func costsPath() (string, error) {
+	dir, err := paths.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, COSTS_FILE_NAME), nil
+}
+
+// recordArticleCost appends the usage of an article to the costs file of the
+// state directory, one JSON record per line.
+func recordArticleCost(result ArticleResult, options ReportOptions, usage summarize.Usage, now time.Time) error {
+	if usage == (summarize.Usage{}) {
+		return nil
+	}
+	record := CostRecord{Date: now, Url: result.Url, Feed: options.Feed, Profile: options.Profile, Usage: usage}
+	if result.Article.Url != "" {
+		record.Url = result.Article.Url
+	}
+	if result.Article.Summary != nil {
+		record.Tags = result.Article.Summary.Tags
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshaling cost: %w", err)
+	}
+
+	path, err := costsPath()
+	if err != nil {
+		return err
+	}
+	costsMutex.Lock()
+	defer costsMutex.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("creating state folder: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("opening costs: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing costs: %w", err)
+	}
+	return nil
+}
+
+// LoadCostRecords reads the costs recorded since a date, the lines that
+// cannot be read, e.g. a line cut by a crash, being skipped.
+func LoadCostRecords(since time.Time) ([]CostRecord, error) {
+	path, err := costsPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading costs: %w", err)
+	}
+	defer file.Close()
+
+	var records []CostRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record CostRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if !record.Date.Before(since) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading costs: %w", err)
+	}
+	return records, nil
+}
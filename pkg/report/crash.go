@@ -0,0 +1,149 @@
+package report
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/internal/paths"
+)
+
+// EXIT_CODE_CRASH is the exit code of the tool after a panic, EX_SOFTWARE
+// from sysexits.h, to tell crashes apart from ordinary errors.
+const EXIT_CODE_CRASH = 70
+
+// Flags whose value is a secret, left out of the crash reports, besides the
+// ones whose name tells it
+var secretFlags = map[string]bool{"header": true, "basic-auth": true}
+
+var secretFlagNameRegex = regexp.MustCompile(`(?i)(key|token|secret|password)`)
+
+// PanicError is the error of an article or a job whose processing panicked,
+// its crash report written to the state directory.
+type PanicError struct {
+	Value any
+	// Path of the crash report, empty if it could not be written
+	ReportPath string
+}
+
+func (e *PanicError) Error() string {
+	if e.ReportPath == "" {
+		return fmt.Sprintf("panic: %v", e.Value)
+	}
+	return fmt.Sprintf("panic: %v (crash report: %s)", e.Value, e.ReportPath)
+}
+
+// NewPanicError returns the error of a recovered panic, nil if there was none,
+// and writes its crash report. It must be given the value of recover, called
+// by the deferred function, to recover the panics of the goroutines which
+// must not stop the program: a panic cannot be recovered from another
+// goroutine. input describes what was being processed, if known.
+func NewPanicError(recovered any, input string) error {
+	if recovered == nil {
+		return nil
+	}
+	report := FormatCrashReport(recovered, input, debug.Stack())
+	path, err := WriteCrashReport(report)
+	if err != nil {
+		slog.Error("could not write the crash report", "err", err, "report", report)
+	} else {
+		slog.Error("panic recovered, crash report written", "panic", recovered, "input", input, "path", path)
+	}
+	return &PanicError{Value: recovered, ReportPath: path}
+}
+
+// redactArguments returns the arguments of the command line without the
+// values of the secret flags and the passwords of the urls.
+func redactArguments(args []string) []string {
+	redacted := make([]string, len(args))
+	secretValue := false
+	for i, arg := range args {
+		redacted[i] = arg
+		if secretValue {
+			redacted[i] = "REDACTED"
+			secretValue = false
+			continue
+		}
+		if name, value, ok := strings.Cut(strings.TrimLeft(arg, "-"), "="); strings.HasPrefix(arg, "-") && isSecretFlag(name) {
+			if ok {
+				redacted[i] = arg[:len(arg)-len(value)] + "REDACTED"
+			} else {
+				secretValue = true
+			}
+			continue
+		}
+		if parsed, err := url.Parse(arg); err == nil && parsed.User != nil {
+			if _, hasPassword := parsed.User.Password(); hasPassword {
+				parsed.User = url.UserPassword(parsed.User.Username(), "REDACTED")
+				redacted[i] = parsed.String()
+			}
+		}
+	}
+	return redacted
+}
+
+func isSecretFlag(name string) bool {
+	return secretFlags[name] || secretFlagNameRegex.MatchString(name)
+}
+
+// FormatCrashReport returns the crash report of a panic: its value, the
+// redacted command line, the build information and the stack.
+func FormatCrashReport(recovered any, input string, stack []byte) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Panic: %v\n", recovered)
+	fmt.Fprintf(&sb, "Time: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&sb, "Arguments: %q\n", redactArguments(os.Args))
+	if input != "" {
+		fmt.Fprintf(&sb, "Input: %s\n", input)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		fmt.Fprintf(&sb, "Working directory: %s\n", wd)
+	}
+
+	fmt.Fprintf(&sb, "Go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintf(&sb, "Version: %s %s\n", info.Main.Path, info.Main.Version)
+		for _, setting := range info.Settings {
+			if strings.HasPrefix(setting.Key, "vcs.") {
+				fmt.Fprintf(&sb, "%s: %s\n", setting.Key, setting.Value)
+			}
+		}
+		for _, dep := range info.Deps {
+			fmt.Fprintf(&sb, "Dependency: %s %s\n", dep.Path, dep.Version)
+		}
+	}
+
+	fmt.Fprintf(&sb, "\n%s", stack)
+	return sb.String()
+}
+
+// WriteCrashReport writes a crash report to the crashes folder of the state
+// directory and returns its path.
+func WriteCrashReport(report string) (string, error) {
+	dir, err := paths.StateDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "crashes")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	// Articles processed concurrently may crash at the same time
+	file, err := os.CreateTemp(dir, "crash-"+time.Now().Format("20060102-150405")+"-*.txt")
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.WriteString(report); err != nil {
+		file.Close()
+		return "", err
+	}
+	return file.Name(), file.Close()
+}
@@ -0,0 +1,8 @@
+// Package report runs the pipeline of the report tool over the scrape,
+// summarize and export packages: Scrape reads the article of a page,
+// Summarize summarizes it and Export writes its markdown report, the three
+// stages of a report, and a Client runs them all with hooks between them.
+//
+// The policies of the stages are held by the Client configuring them, and not
+// shared by the process.
+package report
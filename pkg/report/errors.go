@@ -0,0 +1,28 @@
+package report
+
+import "fmt"
+
+const (
+	STAGE_SCRAPE    = "scraping"
+	STAGE_PREPARE   = "preparing"
+	STAGE_SUMMARIZE = "summarizing"
+	STAGE_EXPORT    = "exporting"
+	STAGE_REFERENCE = "referencing"
+)
+
+// StageError is the error of a stage of the creation of a report. It wraps the
+// error that caused it, which is one of the diagnostic errors below when the
+// cause is known, so that callers can inspect it with errors.As.
+type StageError struct {
+	Stage string
+	Url   string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,394 @@
+package report
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brequet/report/internal/buildinfo"
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// Events of the progress of an article, from its start to its outcome
+const (
+	EVENT_STARTED    = "started"
+	EVENT_FETCHED    = "fetched"
+	EVENT_SUMMARIZED = "summarized"
+	EVENT_EXPORTED   = "exported"
+	EVENT_SKIPPED    = "skipped"
+	EVENT_FAILED     = "failed"
+)
+
+var EventTypes = []string{EVENT_STARTED, EVENT_FETCHED, EVENT_SUMMARIZED, EVENT_EXPORTED, EVENT_SKIPPED, EVENT_FAILED}
+
+const (
+	// Events waiting to be sent, the newer ones being dropped once reached
+	EVENT_QUEUE_SIZE = 1000
+	// Time given to the queued events to be sent when the command ends
+	EVENT_FLUSH_TIMEOUT = 5 * time.Second
+	DEFAULT_NATS_PORT   = "4222"
+)
+
+// ProgressEvent is a step of the processing of an article, sent as JSON so
+// that a dashboard can follow the ingestion as it happens.
+type ProgressEvent struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	// ID of the run of the tool, the events of the same run sharing it
+	Run   string   `json:"run"`
+	Url   string   `json:"url"`
+	Title string   `json:"title,omitempty"`
+	Paths []string `json:"paths,omitempty"`
+	// Stage that failed, and why
+	Stage string `json:"stage,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// EventPublisher sends the events to a dashboard.
+type EventPublisher interface {
+	Publish(ctx context.Context, event ProgressEvent, data []byte) error
+	Close() error
+}
+
+// ProgressEvents sends the progress events of the articles to a webhook or a
+// NATS subject. The events are queued and sent in order by a single
+// goroutine, so that the articles processed concurrently neither wait for the
+// dashboard nor fail with it.
+type ProgressEvents struct {
+	Url string
+	// Events sent, all of them if empty
+	Types []string
+
+	run       string
+	publisher EventPublisher
+	queue     chan ProgressEvent
+	done      chan struct{}
+	dropped   sync.Once
+}
+
+// Open validates the target of the events and starts sending them, it does
+// nothing without a target. The webhook config signs the events posted to a
+// webhook, and the events are retried with the policy, each call being
+// limited to the timeout.
+func (e *ProgressEvents) Open(webhook export.WebhookConfig, retry scrape.RetryPolicy, timeout time.Duration) error {
+	if e.Url == "" || e.publisher != nil {
+		return nil
+	}
+	for _, eventType := range e.Types {
+		if !slices.Contains(EventTypes, eventType) {
+			return fmt.Errorf("unknown event '%s' in the config file, expected %s", eventType, strings.Join(EventTypes, ", "))
+		}
+	}
+
+	parsed, err := url.Parse(e.Url)
+	if err != nil {
+		return fmt.Errorf("invalid events url '%s': %w", e.Url, err)
+	}
+	switch parsed.Scheme {
+	case "http", "https":
+		e.publisher, err = newWebhookEvents(e.Url, webhook, retry, timeout)
+	case "nats", "tls":
+		e.publisher, err = newNatsPublisher(parsed, retry, timeout)
+	default:
+		err = fmt.Errorf("invalid events url '%s', expected an http, https or nats url", parsed.Redacted())
+	}
+	if err != nil {
+		return err
+	}
+
+	e.run = export.NewReportId(time.Now())
+	e.queue = make(chan ProgressEvent, EVENT_QUEUE_SIZE)
+	e.done = make(chan struct{})
+	go e.send()
+	return nil
+}
+
+type progressListenerKey struct{}
+
+// WithProgressListener returns a context whose articles also give their
+// events to a function, whatever the target of the events, e.g. to follow a
+// job of the server.
+func WithProgressListener(ctx context.Context, listener func(event ProgressEvent)) context.Context {
+	return context.WithValue(ctx, progressListenerKey{}, listener)
+}
+
+// Emit gives an event to the listener of the context, and queues it unless no
+// target is set or its type is not sent. The events of nil ProgressEvents are
+// only given to the listener.
+func (e *ProgressEvents) Emit(ctx context.Context, event ProgressEvent) {
+	event.Time = time.Now()
+	if e != nil {
+		event.Run = e.run
+	}
+	if listener, ok := ctx.Value(progressListenerKey{}).(func(event ProgressEvent)); ok {
+		listener(event)
+	}
+	if e == nil || e.publisher == nil || (len(e.Types) > 0 && !slices.Contains(e.Types, event.Event)) {
+		return
+	}
+	select {
+	case e.queue <- event:
+	default:
+		e.dropped.Do(func() {
+			slog.Warn("progress events dropped, the events target is too slow", "url", RedactedUrl(e.Url))
+		})
+	}
+}
+
+func (e *ProgressEvents) send() {
+	defer close(e.done)
+	for event := range e.queue {
+		data, err := json.Marshal(event)
+		if err != nil {
+			continue
+		}
+		if err := e.publisher.Publish(context.Background(), event, data); err != nil {
+			slog.Warn("could not send the progress event", "event", event.Event, "url", event.Url, "err", err)
+		}
+	}
+}
+
+// Close sends the queued events, waiting for them at most
+// EVENT_FLUSH_TIMEOUT, and closes the connection to the target.
+func (e *ProgressEvents) Close() {
+	if e == nil || e.publisher == nil {
+		return
+	}
+	close(e.queue)
+	select {
+	case <-e.done:
+	case <-time.After(EVENT_FLUSH_TIMEOUT):
+		slog.Warn("progress events not sent before exiting", "count", len(e.queue))
+	}
+	e.publisher.Close()
+}
+
+// articleEvent returns the event of an article at a stage.
+func articleEvent(eventType, articleUrl string, article export.Article) ProgressEvent {
+	return ProgressEvent{Event: eventType, Url: cmp.Or(article.Url, articleUrl), Title: article.Title}
+}
+
+// resultEvent returns the event of the outcome of an article. The previewed
+// articles are exported to the standard output.
+func resultEvent(result ArticleResult) ProgressEvent {
+	event := articleEvent(EVENT_EXPORTED, result.Url, result.Article)
+	switch result.Status {
+	case RESULT_SKIPPED:
+		event.Event = EVENT_SKIPPED
+	case RESULT_FAILED:
+		event.Event = EVENT_FAILED
+		var stageErr *StageError
+		if errors.As(result.Err, &stageErr) {
+			event.Stage = stageErr.Stage
+		}
+		if result.Err != nil {
+			event.Error = result.Err.Error()
+		}
+	}
+	event.Paths = result.OutputPaths
+	return event
+}
+
+// RedactedUrl returns an url without its password, as is if it is invalid.
+func RedactedUrl(rawUrl string) string {
+	if parsed, err := url.Parse(rawUrl); err == nil {
+		return parsed.Redacted()
+	}
+	return rawUrl
+}
+
+// WebhookEvents posts the events to a webhook, signed like the reports of
+// -export webhook.
+type WebhookEvents struct {
+	webhook *export.WebhookExporter
+}
+
+func newWebhookEvents(webhookUrl string, config export.WebhookConfig, retry scrape.RetryPolicy, timeout time.Duration) (*WebhookEvents, error) {
+	webhook, err := export.NewWebhookExporter(webhookUrl, config, retry, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookEvents{webhook: webhook}, nil
+}
+
+func (w *WebhookEvents) Publish(ctx context.Context, event ProgressEvent, data []byte) error {
+	return w.webhook.Retry.Do(ctx, "posting the "+event.Event+" event of "+event.Url, func() error {
+		return w.webhook.Send(ctx, data)
+	})
+}
+
+func (w *WebhookEvents) Close() error {
+	return nil
+}
+
+// NatsPublisher publishes the events to a NATS server, on the subject of the
+// url followed by the event, e.g. report.progress.failed, so that the
+// subscribers can pick the events with the wildcards of NATS. It speaks the
+// text protocol of NATS, connecting again after a failure.
+type NatsPublisher struct {
+	address string
+	subject string
+	tls     bool
+	// Credentials of the url: user and password, or a token as user
+	user     string
+	password string
+	token    string
+
+	retry   scrape.RetryPolicy
+	timeout time.Duration
+
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newNatsPublisher(parsed *url.URL, retry scrape.RetryPolicy, timeout time.Duration) (*NatsPublisher, error) {
+	subject := strings.Trim(parsed.Path, "/")
+	if parsed.Hostname() == "" || subject == "" || strings.ContainsAny(subject, " \t*>/") {
+		return nil, fmt.Errorf("invalid NATS url '%s', expected nats://host:4222/subject", parsed.Redacted())
+	}
+	p := &NatsPublisher{
+		address: net.JoinHostPort(parsed.Hostname(), cmp.Or(parsed.Port(), DEFAULT_NATS_PORT)),
+		subject: subject,
+		tls:     parsed.Scheme == "tls",
+		retry:   retry,
+		timeout: timeout,
+	}
+	if password, ok := parsed.User.Password(); ok {
+		p.user, p.password = parsed.User.Username(), password
+	} else if parsed.User != nil {
+		p.token = parsed.User.Username()
+	}
+	return p, nil
+}
+
+func (p *NatsPublisher) Publish(ctx context.Context, event ProgressEvent, data []byte) error {
+	return p.retry.Do(ctx, "publishing the "+event.Event+" event of "+event.Url, func() error {
+		err := p.publish(ctx, p.subject+"."+event.Event, data)
+		if err != nil {
+			p.Close()
+		}
+		return err
+	})
+}
+
+// publish sends a message, followed by a PING whose PONG tells that the
+// server accepted it.
+func (p *NatsPublisher) publish(ctx context.Context, subject string, data []byte) error {
+	if p.conn == nil {
+		if err := p.connect(ctx); err != nil {
+			return err
+		}
+	}
+	setConnDeadline(p.conn, p.timeout)
+	message := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", subject, len(data), data)
+	if _, err := p.conn.Write([]byte(message)); err != nil {
+		return &scrape.FetchError{Url: p.address, Err: err}
+	}
+	return p.waitPong()
+}
+
+func (p *NatsPublisher) connect(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", p.address)
+	if err != nil {
+		return &scrape.FetchError{Url: p.address, Err: err}
+	}
+	setConnDeadline(conn, p.timeout)
+	p.conn, p.reader = conn, bufio.NewReader(conn)
+
+	line, err := p.reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		p.Close()
+		return &scrape.FetchError{Url: p.address, Err: fmt.Errorf("not a NATS server: %v", cmp.Or(err, fmt.Errorf("%q", strings.TrimSpace(line))))}
+	}
+	var info struct {
+		TlsRequired bool `json:"tls_required"`
+	}
+	json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info)
+	if p.tls || info.TlsRequired {
+		host, _, _ := net.SplitHostPort(p.address)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		p.conn, p.reader = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	connect, _ := json.Marshal(map[string]any{
+		"verbose":    false,
+		"pedantic":   false,
+		"name":       "report",
+		"lang":       "go",
+		"version":    cmp.Or(buildinfo.Version, "dev"),
+		"user":       p.user,
+		"pass":       p.password,
+		"auth_token": p.token,
+	})
+	if _, err := p.conn.Write([]byte("CONNECT " + string(connect) + "\r\nPING\r\n")); err != nil {
+		p.Close()
+		return &scrape.FetchError{Url: p.address, Err: err}
+	}
+	if err := p.waitPong(); err != nil {
+		p.Close()
+		return err
+	}
+	return nil
+}
+
+// setConnDeadline limits the exchanges with the server to a timeout, a
+// timeout of 0 being no limit.
+func setConnDeadline(conn net.Conn, timeout time.Duration) {
+	deadline := time.Time{}
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	conn.SetDeadline(deadline)
+}
+
+// waitPong reads the answers of the server until its PONG, answering its
+// PINGs, and fails on its errors, e.g. an authorization violation.
+func (p *NatsPublisher) waitPong() error {
+	for {
+		line, err := p.reader.ReadString('\n')
+		if err != nil {
+			return &scrape.FetchError{Url: p.address, Err: err}
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return &scrape.FetchError{Url: p.address, Err: err}
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS server %s: %s", p.address, strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+		}
+	}
+}
+
+func (p *NatsPublisher) Close() error {
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn, p.reader = nil, nil
+	return err
+}
+
+// EventsConfig holds the defaults of the progress events, like the -events
+// and -event-types flags.
+type EventsConfig struct {
+	// Webhook or NATS subject, e.g. nats://localhost:4222/report.progress
+	Url   string   `yaml:"url,omitempty"`
+	Types []string `yaml:"types,omitempty"`
+}
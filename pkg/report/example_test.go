@@ -0,0 +1,86 @@
+package report_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/report"
+)
+
+func ExampleClient_Summarize() {
+	client, err := report.New(report.WithOutputFolder("./articles"), report.WithFormats("markdown", "pdf"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	result, err := client.Summarize(context.Background(), "https://example.com/my-article")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(result.Title, result.Tags, result.OutputPaths)
+}
+
+func ExampleWithProvider() {
+	provider, err := report.NewProvider("anthropic")
+	if err != nil {
+		log.Fatal(err)
+	}
+	client, err := report.New(report.WithProvider(provider), report.WithProfile("executive-brief"), report.WithCache(false))
+	if err != nil {
+		log.Fatal(err)
+	}
+	result, err := client.Summarize(context.Background(), "https://example.com/my-article")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(result.Summary)
+}
+
+func ExampleScrape() {
+	ctx := context.Background()
+	provider, err := report.NewProvider("openai")
+	if err != nil {
+		log.Fatal(err)
+	}
+	article, err := report.Scrape(ctx, "https://example.com/my-article")
+	if err != nil {
+		log.Fatal(err)
+	}
+	summary, err := report.Summarize(ctx, provider, article)
+	if err != nil {
+		log.Fatal(err)
+	}
+	path, err := report.Export("./articles", export.Article{Article: article, Summary: &summary})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(path)
+}
+
+func ExampleOnSummarized() {
+	client, err := report.New(
+		report.WithOutputFolder("./articles"),
+		report.OnExtracted(func(ctx context.Context, article *export.Article) error {
+			if strings.Contains(article.Url, "/podcasts/") {
+				article.ContentType = "video"
+			}
+			return nil
+		}),
+		report.OnSummarized(func(ctx context.Context, article *export.Article) error {
+			article.Summary.Tags = append(article.Summary.Tags, "work")
+			return nil
+		}),
+		report.OnExported(func(ctx context.Context, article export.Article, outputPaths []string) error {
+			log.Printf("%s written to %v", article.Title, outputPaths)
+			return nil
+		}),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := client.Summarize(context.Background(), "https://example.com/my-article"); err != nil {
+		log.Fatal(err)
+	}
+}
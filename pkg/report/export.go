@@ -0,0 +1,171 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+// Export target printing the markdown reports to the standard output
+const EXPORT_STDOUT = "stdout"
+
+// Exporter writes the report of an article to a target: a file of the output
+// folder, the standard output or a service.
+type Exporter interface {
+	Name() string
+	// Local exporters write to the output folder or the standard output, one
+	// article at a time, before the remote exporters, which only run once
+	// they succeeded.
+	Local() bool
+	// Export writes the report and returns where it went: the path of the file
+	// written, the url of the page or the server, empty for the standard
+	// output.
+	Export(ctx context.Context, report ExportedReport) (string, error)
+}
+
+// ExportedReport is what the exporters are given for an article.
+type ExportedReport struct {
+	// Url of the article as given, the key of the per url options
+	Input   string
+	Article export.Article
+	// Markdown template of the content type of the article
+	Template string
+	// Tokens consumed by the LLM calls summarizing the article
+	Usage   summarize.Usage
+	Options ReportOptions
+}
+
+// exporters returns the exporters of the reports: the services, then the
+// formats of the output folder and the standard output.
+func (o ReportOptions) exporters() []Exporter {
+	var exporters []Exporter
+	if o.Notion != nil {
+		exporters = append(exporters, remoteExporter{"Notion", func(ctx context.Context, report ExportedReport) (string, error) {
+			return o.Notion.Export(ctx, report.Article, time.Now())
+		}})
+	}
+	if o.Karakeep != nil {
+		exporters = append(exporters, remoteExporter{"Karakeep", func(ctx context.Context, report ExportedReport) (string, error) {
+			return o.Karakeep.Url, o.Karakeep.Export(ctx, report.Article, report.Options.KarakeepBookmarks[report.Input])
+		}})
+	}
+	if o.Wallabag != nil {
+		exporters = append(exporters, remoteExporter{"Wallabag", func(ctx context.Context, report ExportedReport) (string, error) {
+			return o.Wallabag.Url, o.Wallabag.Export(ctx, report.Article, report.Options.WallabagEntries[report.Input])
+		}})
+	}
+	for _, webhook := range o.Webhooks {
+		exporters = append(exporters, remoteExporter{"webhook", func(ctx context.Context, report ExportedReport) (string, error) {
+			return webhook.Url, webhook.Post(ctx, report.Article, report.Usage)
+		}})
+	}
+	for _, format := range o.Formats {
+		exporters = append(exporters, fileExporter{format})
+	}
+	if o.Stdout {
+		exporters = append(exporters, stdoutExporter{})
+	}
+	return exporters
+}
+
+// remoteExporter exports the reports to a service.
+type remoteExporter struct {
+	name   string
+	export func(ctx context.Context, report ExportedReport) (string, error)
+}
+
+func (e remoteExporter) Name() string {
+	return e.name
+}
+
+func (e remoteExporter) Local() bool {
+	return false
+}
+
+func (e remoteExporter) Export(ctx context.Context, report ExportedReport) (string, error) {
+	location, err := e.export(ctx, report)
+	if err != nil {
+		return "", fmt.Errorf("exporting to %s: %w", e.name, err)
+	}
+	slog.Info("Article exported to "+e.name, "location", location)
+	return location, nil
+}
+
+// fileExporter writes the reports of an output format into the output
+// folder, the markdown reports updating the report of UpdatePath if set.
+type fileExporter struct {
+	format string
+}
+
+func (e fileExporter) Name() string {
+	return e.format
+}
+
+func (e fileExporter) Local() bool {
+	return true
+}
+
+func (e fileExporter) Export(ctx context.Context, report ExportedReport) (string, error) {
+	options, article := report.Options, report.Article
+	switch e.format {
+	case export.OUTPUT_FORMAT_JSON, export.OUTPUT_FORMAT_NDJSON:
+		return export.ExportArticleJson(options.OutputFolder, options.Permissions, article, e.format, report.Usage)
+	case export.OUTPUT_FORMAT_MARKDOWN:
+	default:
+		return export.ExportArticleDocument(options.OutputFolder, options.Permissions, article, e.format, options.IncludeContent, options.Encoding)
+	}
+
+	var outputPath string
+	var err error
+	if options.UpdatePath != "" {
+		outputPath, err = export.UpdateArticle(options.UpdatePath, options.Permissions, article, report.Template, options.Frontmatter, options.IncludeContent, options.Encoding)
+	} else {
+		outputPath, err = export.ExportArticle(options.OutputFolder, options.Permissions, article, report.Template, options.Frontmatter, options.IncludeContent, options.Encoding)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Only markdown reports are part of the archive searched semantically
+	if export.IsEmbeddingConfigured() {
+		if err := export.StoreArticleEmbedding(ctx, options.OutputFolder, options.Permissions, outputPath, article); err != nil {
+			slog.Warn("could not store article embedding", "err", err)
+		}
+	}
+	// An updated report is already linked
+	if options.NoteLinks.Enabled() && options.UpdatePath == "" {
+		if err := options.NoteLinks.Link(options.OutputFolder, options.Permissions, outputPath, article.Title, time.Now()); err != nil {
+			slog.Warn("could not link the report from the notes", "err", err)
+		}
+	}
+	return outputPath, nil
+}
+
+// stdoutExporter prints the markdown reports to the standard output, e.g. to
+// pipe them to another tool.
+type stdoutExporter struct{}
+
+func (e stdoutExporter) Name() string {
+	return EXPORT_STDOUT
+}
+
+func (e stdoutExporter) Local() bool {
+	return true
+}
+
+func (e stdoutExporter) Export(ctx context.Context, report ExportedReport) (string, error) {
+	content, err := export.RenderArticle(report.Article, report.Template, report.Options.Frontmatter, report.Options.IncludeContent)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stdout.WriteString(strings.TrimSuffix(content, "\n") + "\n"); err != nil {
+		return "", fmt.Errorf("writing to the standard output: %w", err)
+	}
+	return "", nil
+}
@@ -0,0 +1,97 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/brequet/report/pkg/export"
+)
+
+const (
+	FILTER_ACTION_SKIP = "skip"
+	FILTER_ACTION_FLAG = "flag"
+
+	// Tag added to the reports of flagged articles.
+	FLAGGED_TAG = "flagged"
+)
+
+// ContentFilter matches articles about a prohibited topic. A skipped article
+// is not summarized, a flagged one is summarized but tagged for review.
+type ContentFilter struct {
+	Action  string
+	Pattern string
+	regex   *regexp.Regexp
+}
+
+// LoadContentFilters reads a filter file, with one rule per line written as
+// "<skip|flag> <keyword>" or "<skip|flag> /<regex>/". Keywords match whole
+// words, case insensitively. Empty lines and lines starting with # are ignored.
+func LoadContentFilters(path string) ([]ContentFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening filter file: %w", err)
+	}
+	defer file.Close()
+
+	var filters []ContentFilter
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		action, pattern, _ := strings.Cut(line, " ")
+		pattern = strings.TrimSpace(pattern)
+		if (action != FILTER_ACTION_SKIP && action != FILTER_ACTION_FLAG) || pattern == "" {
+			return nil, fmt.Errorf("line %d: expected '%s <pattern>' or '%s <pattern>', got '%s'", lineNumber, FILTER_ACTION_SKIP, FILTER_ACTION_FLAG, line)
+		}
+
+		var expression string
+		if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			expression = "(?i)" + pattern[1:len(pattern)-1]
+		} else {
+			expression = `(?i)\b` + regexp.QuoteMeta(pattern) + `\b`
+		}
+
+		regex, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid pattern '%s': %w", lineNumber, pattern, err)
+		}
+
+		filters = append(filters, ContentFilter{Action: action, Pattern: pattern, regex: regex})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading filter file: %w", err)
+	}
+
+	return filters, nil
+}
+
+// matchContentFilters returns the filters matching the article title or
+// content, skip filters first.
+func matchContentFilters(filters []ContentFilter, article export.Article) []ContentFilter {
+	var skipped, flagged []ContentFilter
+	for _, filter := range filters {
+		if !filter.regex.MatchString(article.Title) && !filter.regex.MatchString(article.Content) {
+			continue
+		}
+		if filter.Action == FILTER_ACTION_SKIP {
+			skipped = append(skipped, filter)
+		} else {
+			flagged = append(flagged, filter)
+		}
+	}
+	return append(skipped, flagged...)
+}
+
+func describeContentFilters(filters []ContentFilter) string {
+	patterns := make([]string, len(filters))
+	for i, filter := range filters {
+		patterns[i] = filter.Pattern
+	}
+	return strings.Join(patterns, ", ")
+}
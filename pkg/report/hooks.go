@@ -0,0 +1,127 @@
+package report
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// FetchedHook is called with the page of an url downloaded from its site,
+// before its article is extracted.
+type FetchedHook func(ctx context.Context, articleUrl string, page *scrape.FetchedPage) error
+
+// ArticleHook is called with an article between two stages of the pipeline.
+type ArticleHook func(ctx context.Context, article *export.Article) error
+
+// ExportedHook is called with an article once its reports are written and
+// exported, and the paths of the files written.
+type ExportedHook func(ctx context.Context, article export.Article, outputPaths []string) error
+
+// pipelineHooks are the hooks of a Client, run in the order they were given.
+// A hook returning an error fails the article at the stage it follows.
+type pipelineHooks struct {
+	fetched    []FetchedHook
+	extracted  []ArticleHook
+	summarized []ArticleHook
+	exported   []ExportedHook
+}
+
+type pipelineHooksKey struct{}
+
+// OnFetched calls a hook with the pages fetched from the sites, to read or
+// change them before the extraction. The videos, the rendered pages, the
+// Wayback Machine copies and the saved pages are not fetched pages.
+func OnFetched(hook FetchedHook) Option {
+	return func(c *Client) error {
+		c.hooks.fetched = append(c.hooks.fetched, hook)
+		return nil
+	}
+}
+
+// OnExtracted calls a hook with the extracted articles, to read or change
+// them, e.g. their content or content type, before the summary.
+func OnExtracted(hook ArticleHook) Option {
+	return func(c *Client) error {
+		c.hooks.extracted = append(c.hooks.extracted, hook)
+		return nil
+	}
+}
+
+// OnSummarized calls a hook with the summarized articles, to read or change
+// them, e.g. their summary or tags, before their reports are written.
+func OnSummarized(hook ArticleHook) Option {
+	return func(c *Client) error {
+		c.hooks.summarized = append(c.hooks.summarized, hook)
+		return nil
+	}
+}
+
+// OnExported calls a hook once the reports of an article are written and
+// exported. An error fails the article, its files being kept.
+func OnExported(hook ExportedHook) Option {
+	return func(c *Client) error {
+		c.hooks.exported = append(c.hooks.exported, hook)
+		return nil
+	}
+}
+
+// withPipelineHooks returns a context running the hooks in the pipeline.
+func withPipelineHooks(ctx context.Context, hooks *pipelineHooks) context.Context {
+	return context.WithValue(ctx, pipelineHooksKey{}, hooks)
+}
+
+// contextHooks returns the hooks of the context, nil when there are none.
+func contextHooks(ctx context.Context) *pipelineHooks {
+	hooks, _ := ctx.Value(pipelineHooksKey{}).(*pipelineHooks)
+	return hooks
+}
+
+func (h *pipelineHooks) runFetched(ctx context.Context, articleUrl string, page *scrape.FetchedPage) error {
+	if h == nil {
+		return nil
+	}
+	for _, hook := range h.fetched {
+		if err := hook(ctx, articleUrl, page); err != nil {
+			return fmt.Errorf("fetched hook: %w", err)
+		}
+	}
+	return nil
+}
+
+func (h *pipelineHooks) runExtracted(ctx context.Context, article *export.Article) error {
+	if h == nil {
+		return nil
+	}
+	for _, hook := range h.extracted {
+		if err := hook(ctx, article); err != nil {
+			return fmt.Errorf("extracted hook: %w", err)
+		}
+	}
+	return nil
+}
+
+func (h *pipelineHooks) runSummarized(ctx context.Context, article *export.Article) error {
+	if h == nil {
+		return nil
+	}
+	for _, hook := range h.summarized {
+		if err := hook(ctx, article); err != nil {
+			return fmt.Errorf("summarized hook: %w", err)
+		}
+	}
+	return nil
+}
+
+func (h *pipelineHooks) runExported(ctx context.Context, article export.Article, outputPaths []string) error {
+	if h == nil {
+		return nil
+	}
+	for _, hook := range h.exported {
+		if err := hook(ctx, article, outputPaths); err != nil {
+			return fmt.Errorf("exported hook: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/brequet/report/pkg/export"
+)
+
+// StdinMutex prevents concurrently processed articles from prompting the user
+// at the same time.
+var StdinMutex sync.Mutex
+
+// getUserInputtedArticleTitle asks the user for a valid filename for the
+// title of an article, falling back to the sanitized title when the standard
+// input ends.
+func getUserInputtedArticleTitle(title string) string {
+	StdinMutex.Lock()
+	defer StdinMutex.Unlock()
+	return readArticleTitle(stdinReader, title)
+}
+
+// stdinReader reads the answers of the user, kept across the prompts not to
+// lose the lines it buffered.
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readArticleTitle asks the user for a file name until a valid one is given,
+// the sanitized title being returned when the input ends.
+func readArticleTitle(reader *bufio.Reader, title string) string {
+	for {
+		fmt.Print("Please enter a valid filename: ")
+		input, err := reader.ReadString('\n')
+		// Trim the newline and any spaces
+		input = strings.TrimSpace(input)
+		if input != "" && export.IsValidWindowsFilename(input) {
+			return input
+		}
+		if err != nil {
+			fmt.Println()
+			slog.Warn("no valid filename entered, using the sanitized title", "err", err, "title", title)
+			return export.SanitizeFilename(title)
+		}
+		fmt.Println("The entered filename is still not valid. Please try again.")
+	}
+}
@@ -0,0 +1,28 @@
+package report
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestReadArticleTitle(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"valid filename", "My article\n", "My article"},
+		{"invalid then valid", "a/b\n\nMy article\n", "My article"},
+		{"last line without newline", "My article", "My article"},
+		{"end of input", "", "What is it"},
+		{"invalid then end of input", "a/b\n", "What is it"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if title := readArticleTitle(bufio.NewReader(strings.NewReader(test.input)), "What is it?"); title != test.want {
+				t.Errorf("readArticleTitle = %q, want %q", title, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+// Scrape fetches the page of an url and extracts its article, from its
+// Wayback Machine copy when the page is gone, with the default policies of
+// the tool.
+func Scrape(ctx context.Context, articleUrl string) (scrape.Article, error) {
+	article, err := scrape.New().ScrapeArticle(ctx, articleUrl)
+	if err != nil {
+		return scrape.Article{}, &StageError{Stage: STAGE_SCRAPE, Url: articleUrl, Err: err}
+	}
+	return article, nil
+}
+
+// NewProvider returns the LLM provider of a name, e.g. openai, or of a comma
+// separated list of names tried in order, configured from the environment
+// like the command line tool. Its answers are not cached, unless it is given
+// to a Client, see WithProvider.
+func NewProvider(name string) (summarize.LLMProvider, error) {
+	options := summarize.DefaultProviderOptions()
+	options.Cache.Disabled = true
+	return summarize.NewLLMProvider(name, options)
+}
+
+// Summarize summarizes an article with the default prompt profile and the
+// instructions of its content type.
+func Summarize(ctx context.Context, provider summarize.LLMProvider, article scrape.Article) (summarize.Summary, error) {
+	prompt, err := summarize.GetProfilePrompt(summarize.DEFAULT_PROFILE)
+	if err == nil {
+		prompt, err = summarize.GetContentTypePrompt(article.ContentType, nil, prompt)
+	}
+	var tokenizers *summarize.Tokenizers
+	if err == nil {
+		tokenizers, err = summarize.NewTokenizers(nil)
+	}
+	if err != nil {
+		return summarize.Summary{}, &StageError{Stage: STAGE_PREPARE, Url: article.Url, Err: err}
+	}
+	prompt = summarize.WithLanguageInstructions(prompt, article.Language, "")
+
+	summary, err := summarize.Summarize(ctx, provider, tokenizers, article, prompt, summarize.SummaryConstraints{}, summarize.DEFAULT_CHUNK_TOKENS)
+	if err != nil {
+		return summarize.Summary{}, &StageError{Stage: STAGE_SUMMARIZE, Url: article.Url, Err: err}
+	}
+	return summary, nil
+}
+
+// Export writes the markdown report of a summarized article to an output
+// folder, with the template of its content type, and returns its path. The
+// article is given a new ID when it has none.
+func Export(outputFolder string, article export.Article) (string, error) {
+	if article.Summary == nil {
+		return "", &StageError{Stage: STAGE_EXPORT, Url: article.Url, Err: fmt.Errorf("article not summarized")}
+	}
+	if article.Id == "" {
+		article.Id = export.NewReportId(time.Now())
+	}
+	template, err := export.GetContentTypeTemplate(article.ContentType, nil, "")
+	if err != nil {
+		return "", &StageError{Stage: STAGE_PREPARE, Url: article.Url, Err: err}
+	}
+	// The files are written with the default permissions
+	var permissions export.FilePermissions
+	if err := permissions.Mkdir(outputFolder); err != nil {
+		return "", &StageError{Stage: STAGE_EXPORT, Url: article.Url, Err: err}
+	}
+	outputPath, err := export.ExportArticle(outputFolder, permissions, article, template, export.FRONTMATTER_TEMPLATE, export.INCLUDE_CONTENT_NONE, export.OutputEncoding{})
+	if err != nil {
+		return "", &StageError{Stage: STAGE_EXPORT, Url: article.Url, Err: err}
+	}
+	return outputPath, nil
+}
@@ -0,0 +1,438 @@
+package report
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const (
+	RESULT_CREATED = "created"
+	RESULT_SKIPPED = "skipped"
+	RESULT_FAILED  = "failed"
+	// The report was printed rather than written, see -dry-run
+	RESULT_PREVIEWED = "previewed"
+)
+
+// ReportOptions configures the creation of reports, it is shared by all the
+// articles of a run.
+type ReportOptions struct {
+	OutputFolder string
+	// Scraper fetching and extracting the articles
+	Scraper *scrape.Scraper
+	// Permissions of the files written
+	Permissions   export.FilePermissions
+	HostLimiter   *scrape.HostLimiter
+	Provider      summarize.LLMProvider
+	Formats       []string
+	Encoding      export.OutputEncoding
+	Frontmatter   string
+	Filters       []ContentFilter
+	ContentType   string
+	Template      string
+	TypeTemplates scrape.ContentTypeFiles
+	TypePrompts   scrape.ContentTypeFiles
+	// System prompt of the selected profile, completed for each content type,
+	// and the name of the profile
+	SystemPrompt string
+	Profile      string
+	// Feed or service the urls were read from, the costs of the articles
+	// being attributed to it (see report costs)
+	Feed string
+	// Pages read from web archives, by url, extracted instead of fetching
+	// their url (see report import-warc)
+	ArchivedPages map[string]scrape.ArchivedPage
+	// Language code the summaries are written in, source for the language of
+	// the article, empty to let the LLM pick
+	SummaryLanguage string
+	Constraints     summarize.SummaryConstraints
+	// Number of tokens above which the articles are summarized in chunks,
+	// counted with the tokenizer of the model
+	ChunkTokens int
+	Tokenizers  *summarize.Tokenizers
+	// Text of the article included in the reports: none, excerpt or full
+	IncludeContent string
+	// How the excerpt is made, paragraphs or llm, and its maximum length
+	ExcerptMode   string
+	ExcerptLength int
+	// Cover image referenced by the reports: none, link or download
+	CoverMode string
+	// Include the data tables of the articles in the reports
+	Tables bool
+	// Save the images of the articles in the assets folder, the reports
+	// referencing the local copies
+	DownloadImages bool
+	// Reports of the output folder the articles are compared with, nil if
+	// they are not
+	ArchiveChecker *export.ArchiveChecker
+	// Number of images of the articles described by VisionProvider, 0 for
+	// none
+	DescribeImages  int
+	VisionProvider  summarize.LLMProvider
+	ReferencesFile  string
+	ReferenceFormat string
+	// Index of the articles already summarized into the output folder, they
+	// are skipped unless Force is set.
+	Index *export.ArticleIndex
+	Force bool
+	// Rename the articles whose title is not a valid filename without asking
+	// the user.
+	NonInteractive bool
+	// Notes linking to the new markdown reports
+	NoteLinks export.NoteLinks
+	// Name the reports after the slug of the title of the articles, numbered
+	// when another article has the same slug.
+	Slug bool
+	// Read the arguments that are not urls as saved pages, "-" being the
+	// standard input. Only set for the urls given by the user.
+	AllowLocalInput bool
+	// Dates the urls were added to the reading list they were imported from,
+	// by url as given.
+	AddedDates map[string]time.Time
+	// Tags given by the user in the service the urls were imported from, by
+	// url as given, added to the tags of the summary.
+	ImportedTags map[string][]string
+	// Notion database the articles are also exported to, nil if not exported
+	Notion *export.NotionExporter
+	// Karakeep server the summaries are written to, nil if not exported, and
+	// the bookmarks of the urls read from it, by url as given. The urls
+	// without a bookmark get a new one.
+	Karakeep          *export.KarakeepClient
+	KarakeepBookmarks map[string]string
+	// Wallabag server the summaries are written to as annotations, nil if not
+	// exported, and the entries of the urls read from it, by url as given.
+	// The urls without an entry get a new one.
+	Wallabag        *export.WallabagClient
+	WallabagEntries map[string]export.WallabagEntry
+	// Webhooks the JSON reports are posted to
+	Webhooks []*export.WebhookExporter
+	// Target the progress events of the articles are sent to, nil if they
+	// are only given to the listener of the context
+	Events *ProgressEvents
+	// Print the markdown reports to the standard output
+	Stdout bool
+	// Markdown report updated in place instead of being written after the
+	// title of the article, keeping what the user added to it.
+	UpdatePath string
+	// Print the markdown reports, or their changes with PreviewDiff, instead
+	// of writing or exporting anything. The articles already summarized are
+	// previewed too.
+	DryRun      bool
+	PreviewDiff bool
+	// Show the summaries to the user before writing the reports, letting them
+	// edit or summarize them again (see reviewArticle)
+	Review bool
+}
+
+type ArticleResult struct {
+	Url    string
+	Status string
+	// Article is the summarized article, set once the article is summarized.
+	Article     export.Article
+	OutputPaths []string
+	// Tokens consumed by the LLM calls made for the article, and their cost
+	Usage summarize.Usage
+	Err   error
+}
+
+// outputMutex serializes the writes of concurrently processed articles, as
+// they may share the same report file name, embedding store or references file.
+var outputMutex sync.Mutex
+
+func failedResult(articleUrl, stage string, err error) ArticleResult {
+	return ArticleResult{Url: articleUrl, Status: RESULT_FAILED, Err: &StageError{Stage: stage, Url: articleUrl, Err: err}}
+}
+
+// skipIndexedArticle returns the skipped result of an article already
+// summarized into the output folder, unless Force is set.
+func skipIndexedArticle(input, articleUrl string, options ReportOptions) (ArticleResult, bool) {
+	indexed, found := options.Index.Lookup(articleUrl)
+	if !found || options.Force || options.DryRun {
+		return ArticleResult{}, false
+	}
+	slog.Info("Article already summarized, use -force to summarize it again", "url", articleUrl, "date", indexed.Date, "paths", strings.Join(indexed.Paths, ","))
+	return ArticleResult{Url: input, Status: RESULT_SKIPPED, Article: export.Article{Article: scrape.Article{Url: articleUrl, Title: indexed.Title}, Id: indexed.Id}, OutputPaths: indexed.Paths}, true
+}
+
+// createReport scrapes, summarizes and exports a single article.
+func createReport(ctx context.Context, articleUrl string, options ReportOptions) (result ArticleResult) {
+	options.Events.Emit(ctx, articleEvent(EVENT_STARTED, articleUrl, export.Article{}))
+	defer func() {
+		options.Events.Emit(ctx, resultEvent(result))
+	}()
+
+	local := options.AllowLocalInput && scrape.IsLocalInput(articleUrl)
+	if !local {
+		if result, skipped := skipIndexedArticle(articleUrl, articleUrl, options); skipped {
+			return result
+		}
+	}
+
+	ctx, recorder := summarize.WithUsageRecorder(ctx)
+	defer func() {
+		result.Usage = recorder.Usage()
+		if err := recordUsageStats(recorder, result.Status == RESULT_CREATED, time.Now()); err != nil {
+			slog.Warn("could not record usage stats", "err", err)
+		}
+		if err := recordArticleCost(result, options, result.Usage, time.Now()); err != nil {
+			slog.Warn("could not record the cost of the article", "err", err)
+		}
+	}()
+
+	var scraped scrape.Article
+	var err error
+	if local {
+		scraped, err = options.Scraper.ScrapeLocalArticle(articleUrl)
+		if err != nil {
+			return failedResult(articleUrl, STAGE_SCRAPE, err)
+		}
+		// The url of a saved page is only known once read
+		if result, skipped := skipIndexedArticle(articleUrl, scraped.Url, options); skipped {
+			return result
+		}
+	} else if archived, ok := options.ArchivedPages[articleUrl]; ok {
+		scraped, err = options.Scraper.ExtractArchivedPage(archived)
+		if err != nil {
+			return failedResult(articleUrl, STAGE_SCRAPE, err)
+		}
+	} else {
+		release, err := options.HostLimiter.Acquire(ctx, articleUrl)
+		if err != nil {
+			return failedResult(articleUrl, STAGE_SCRAPE, err)
+		}
+		scraped, err = options.Scraper.ScrapeArticle(ctx, articleUrl)
+		release()
+		if err != nil {
+			return failedResult(articleUrl, STAGE_SCRAPE, err)
+		}
+	}
+
+	article := export.Article{Article: scraped}
+	options.Events.Emit(ctx, articleEvent(EVENT_FETCHED, articleUrl, article))
+
+	matchedFilters := matchContentFilters(options.Filters, article)
+	if len(matchedFilters) > 0 && matchedFilters[0].Action == FILTER_ACTION_SKIP {
+		slog.Info("Article skipped, it matches the content filters", "url", articleUrl, "filters", describeContentFilters(matchedFilters))
+		return ArticleResult{Url: articleUrl, Status: RESULT_SKIPPED, Article: article}
+	}
+
+	if !options.Slug && !export.IsValidWindowsFilename(article.Title) {
+		slog.Info("Article title is not a valid Windows filename", "title", article.Title)
+		article.Aliases = append(article.Aliases, article.Title)
+		if options.NonInteractive {
+			article.Title = export.SanitizeFilename(article.Title)
+		} else {
+			article.Title = getUserInputtedArticleTitle(article.Title)
+		}
+	}
+	if options.ContentType != "" {
+		article.ContentType = options.ContentType
+	}
+	article.Added = options.AddedDates[articleUrl]
+	if !options.Tables {
+		article.Tables = nil
+	}
+	slog.Info("Article extracted", "url", article.Url, "content_type", article.ContentType, "language", article.Language)
+	hooks := contextHooks(ctx)
+	if err := hooks.runExtracted(ctx, &article); err != nil {
+		return failedResult(articleUrl, STAGE_SCRAPE, err)
+	}
+
+	prompt, err := summarize.GetContentTypePrompt(article.ContentType, options.TypePrompts, options.SystemPrompt)
+	if err != nil {
+		return failedResult(articleUrl, STAGE_PREPARE, err)
+	}
+
+	prompt = summarize.WithLanguageInstructions(prompt, article.Language, options.SummaryLanguage)
+
+	template, err := export.GetContentTypeTemplate(article.ContentType, options.TypeTemplates, options.Template)
+	if err != nil {
+		return failedResult(articleUrl, STAGE_PREPARE, err)
+	}
+
+	summarizeArticle := func(ctx context.Context, instructions string) (summarize.Summary, error) {
+		articleSummary, err := summarize.Summarize(ctx, options.Provider, options.Tokenizers, article.Article, withReviewInstructions(prompt, instructions), options.Constraints, options.ChunkTokens)
+		if err != nil {
+			return summarize.Summary{}, err
+		}
+		if len(matchedFilters) > 0 {
+			articleSummary.Tags = append(articleSummary.Tags, FLAGGED_TAG)
+		}
+		if importedTags := options.ImportedTags[articleUrl]; len(importedTags) > 0 {
+			articleSummary.Tags = MergeTags(importedTags, articleSummary.Tags)
+		}
+		return articleSummary, nil
+	}
+	articleSummary, err := summarizeArticle(ctx, "")
+	if err != nil {
+		return failedResult(articleUrl, STAGE_SUMMARIZE, err)
+	}
+	if len(matchedFilters) > 0 {
+		slog.Warn("article flagged, it matches the content filters", "url", articleUrl, "filters", describeContentFilters(matchedFilters))
+	}
+	article.Summary = &articleSummary
+	options.Events.Emit(ctx, articleEvent(EVENT_SUMMARIZED, articleUrl, article))
+
+	if options.Review {
+		var decision ReviewDecision
+		// Summarizing again asks the LLM rather than the cache
+		article, decision, err = reviewArticle(ctx, article, options.Slug, func(ctx context.Context, instructions string) (summarize.Summary, error) {
+			return summarizeArticle(summarize.WithFreshAnswers(ctx), instructions)
+		})
+		if err != nil {
+			return failedResult(articleUrl, STAGE_SUMMARIZE, err)
+		}
+		if decision == REVIEW_SKIP {
+			slog.Info("Article skipped during the review", "url", articleUrl)
+			return ArticleResult{Url: articleUrl, Status: RESULT_SKIPPED, Article: article}
+		}
+	}
+
+	if err := hooks.runSummarized(ctx, &article); err != nil {
+		return failedResult(articleUrl, STAGE_SUMMARIZE, err)
+	}
+
+	article.Id = export.ArticleReportId(options.Index, article.Url, time.Now())
+
+	if options.IncludeContent == export.INCLUDE_CONTENT_EXCERPT {
+		article.Excerpt = summarize.GetArticleExcerpt(ctx, options.Provider, article.Article, options.ExcerptMode, options.ExcerptLength)
+	}
+	if options.ArchiveChecker != nil {
+		article.Conflicts = options.ArchiveChecker.Check(ctx, options.Provider, article)
+	}
+	if options.DescribeImages > 0 && len(article.Images) > 0 {
+		article.ImageDescriptions = summarize.DescribeArticleImages(ctx, options.Scraper, options.VisionProvider, article.Article, options.DescribeImages)
+	}
+	if len(options.Formats) > 0 || options.Stdout {
+		coverMode := options.CoverMode
+		// A preview references the image rather than downloading it
+		if options.DryRun && coverMode == export.COVER_DOWNLOAD {
+			coverMode = export.COVER_LINK
+		}
+		article.Cover = export.ArticleCover(ctx, options.Scraper, options.OutputFolder, options.Permissions, article, coverMode)
+	}
+	// A preview references the images rather than downloading them
+	if options.DownloadImages && !options.DryRun && options.OutputFolder != "" {
+		article = export.DownloadArticleImages(ctx, options.Scraper, options.OutputFolder, options.Permissions, article)
+	}
+
+	// No file is written for a run interrupted during the summary
+	if err := ctx.Err(); err != nil {
+		return failedResult(articleUrl, STAGE_EXPORT, err)
+	}
+
+	if options.DryRun {
+		outputMutex.Lock()
+		defer outputMutex.Unlock()
+		if err := previewArticle(os.Stdout, article, template, options); err != nil {
+			return failedResult(articleUrl, STAGE_EXPORT, err)
+		}
+		return ArticleResult{Url: articleUrl, Status: RESULT_PREVIEWED, Article: article}
+	}
+
+	report := ExportedReport{Input: articleUrl, Article: article, Template: template, Usage: recorder.Usage(), Options: options}
+	exporters := options.exporters()
+
+	outputPaths, stage, err := writeLocalReports(ctx, &report, exporters)
+	if err != nil {
+		return failedResult(articleUrl, stage, err)
+	}
+	article = report.Article
+	result = ArticleResult{Url: articleUrl, Status: RESULT_CREATED, Article: article, OutputPaths: outputPaths}
+
+	// The services are exported to once the files are written, so that a
+	// failed write leaves nothing behind in them, and outside of the lock,
+	// as they do not depend on the other reports
+	var exports []export.IndexedExport
+	for _, exporter := range exporters {
+		if exporter.Local() {
+			continue
+		}
+		export := export.IndexedExport{Target: exporter.Name(), Date: time.Now().Format(scrape.DATE_FORMAT)}
+		location, err := exporter.Export(ctx, report)
+		if err != nil {
+			export.Error = err.Error()
+			if result.Err == nil {
+				result.Status, result.Err = RESULT_FAILED, &StageError{Stage: STAGE_EXPORT, Url: articleUrl, Err: err}
+			} else {
+				slog.Error("Export failed", "url", articleUrl, "err", err)
+			}
+		}
+		export.Location = location
+		exports = append(exports, export)
+	}
+	if err := hooks.runExported(ctx, article, outputPaths); err != nil {
+		if result.Err == nil {
+			result.Status, result.Err = RESULT_FAILED, &StageError{Stage: STAGE_EXPORT, Url: articleUrl, Err: err}
+		} else {
+			slog.Error("Export failed", "url", articleUrl, "err", err)
+		}
+	}
+
+	if len(outputPaths) > 0 || len(exports) > 0 {
+		if err := options.Index.Add(article, outputPaths, exports, recorder.Usage(), time.Now()); err != nil {
+			slog.Warn("could not update the article index", "err", err)
+		}
+	}
+
+	return result
+}
+
+// writeLocalReports writes the report of an article with the local
+// exporters, and appends the article to the references file, one article at
+// a time. It returns the paths of the files written, or the stage that
+// failed.
+func writeLocalReports(ctx context.Context, report *ExportedReport, exporters []Exporter) ([]string, string, error) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+
+	// The slug is picked once the reports of the articles processed before
+	// are written, as they may share it
+	options := report.Options
+	if options.Slug && options.UpdatePath == "" {
+		indexed, _ := options.Index.Lookup(report.Article.Url)
+		report.Article.Slug = export.UniqueSlug(options.OutputFolder, report.Article.Title, options.Formats, indexed.Paths)
+	}
+
+	var outputPaths []string
+	for _, exporter := range exporters {
+		if !exporter.Local() {
+			continue
+		}
+		outputPath, err := exporter.Export(ctx, *report)
+		if err != nil {
+			return nil, STAGE_EXPORT, err
+		}
+		if outputPath != "" {
+			outputPaths = append(outputPaths, outputPath)
+		}
+	}
+
+	if options.ReferencesFile != "" {
+		if err := export.AppendArticleReference(options.ReferencesFile, options.Permissions, options.ReferenceFormat, report.Article); err != nil {
+			return nil, STAGE_REFERENCE, err
+		}
+	}
+	return outputPaths, "", nil
+}
+
+// MergeTags returns the tags of both lists, in order, without duplicates.
+func MergeTags(first, second []string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, tag := range slices.Concat(first, second) {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,206 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+func TestCreateReport(t *testing.T) {
+	failingHook := errors.New("hook failed")
+	tests := []struct {
+		name       string
+		path       string
+		answer     string
+		opts       []Option
+		change     func(options *ReportOptions, articleUrl string)
+		wantStatus string
+		// Stage of the failure, empty when the article does not fail
+		wantStage string
+		wantErr   error
+		wantTags  []string
+		// Number of markdown reports written
+		wantFiles int
+		wantCalls int32
+	}{
+		{
+			name:       "created",
+			path:       "/article",
+			answer:     TEST_SUMMARY_ANSWER,
+			wantStatus: RESULT_CREATED,
+			wantTags:   []string{"testing"},
+			wantFiles:  1,
+			wantCalls:  1,
+		},
+		{
+			name:   "skipped by a filter",
+			path:   "/article",
+			answer: TEST_SUMMARY_ANSWER,
+			change: func(options *ReportOptions, articleUrl string) {
+				options.Filters = []ContentFilter{{Action: FILTER_ACTION_SKIP, Pattern: "pipeline", regex: regexp.MustCompile(`(?i)\bpipeline\b`)}}
+			},
+			wantStatus: RESULT_SKIPPED,
+		},
+		{
+			name:   "flagged by a filter",
+			path:   "/article",
+			answer: TEST_SUMMARY_ANSWER,
+			change: func(options *ReportOptions, articleUrl string) {
+				options.Filters = []ContentFilter{{Action: FILTER_ACTION_FLAG, Pattern: "model", regex: regexp.MustCompile(`(?i)\bmodel\b`)}}
+			},
+			wantStatus: RESULT_CREATED,
+			wantTags:   []string{"testing", FLAGGED_TAG},
+			wantFiles:  1,
+			wantCalls:  1,
+		},
+		{
+			name:   "imported tags",
+			path:   "/article",
+			answer: TEST_SUMMARY_ANSWER,
+			change: func(options *ReportOptions, articleUrl string) {
+				options.ImportedTags = map[string][]string{articleUrl: {"read-later", "testing"}}
+			},
+			wantStatus: RESULT_CREATED,
+			wantTags:   []string{"read-later", "testing"},
+			wantFiles:  1,
+			wantCalls:  1,
+		},
+		{
+			name:       "page not found",
+			path:       "/missing",
+			answer:     TEST_SUMMARY_ANSWER,
+			wantStatus: RESULT_FAILED,
+			wantStage:  STAGE_SCRAPE,
+		},
+		{
+			name:       "invalid summary",
+			path:       "/article",
+			answer:     "I cannot summarize this article.",
+			wantStatus: RESULT_FAILED,
+			wantStage:  STAGE_SUMMARIZE,
+			wantErr:    summarize.ErrInvalidSummaryJSON,
+			wantCalls:  summarize.JSON_MAX_REPAIRS + 1,
+		},
+		{
+			name:   "extracted hook failed",
+			path:   "/article",
+			answer: TEST_SUMMARY_ANSWER,
+			opts: []Option{OnExtracted(func(ctx context.Context, article *export.Article) error {
+				return failingHook
+			})},
+			wantStatus: RESULT_FAILED,
+			wantStage:  STAGE_SCRAPE,
+			wantErr:    failingHook,
+		},
+		{
+			name:   "exported hook failed",
+			path:   "/article",
+			answer: TEST_SUMMARY_ANSWER,
+			opts: []Option{OnExported(func(ctx context.Context, article export.Article, outputPaths []string) error {
+				return failingHook
+			})},
+			wantStatus: RESULT_FAILED,
+			wantStage:  STAGE_EXPORT,
+			wantErr:    failingHook,
+			wantTags:   []string{"testing"},
+			wantFiles:  1,
+			wantCalls:  1,
+		},
+		{
+			name:   "dry run",
+			path:   "/article",
+			answer: TEST_SUMMARY_ANSWER,
+			change: func(options *ReportOptions, articleUrl string) {
+				options.DryRun = true
+			},
+			wantStatus: RESULT_PREVIEWED,
+			wantTags:   []string{"testing"},
+			wantCalls:  1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestArticleServer(t)
+			provider := &testProvider{answer: test.answer}
+			outputFolder := t.TempDir()
+			client := newTestClient(t, provider, append([]Option{WithOutputFolder(outputFolder)}, test.opts...)...)
+			articleUrl := server.URL + test.path
+			if test.change != nil {
+				client = client.Derive(func(options *ReportOptions) {
+					test.change(options, articleUrl)
+				})
+			}
+
+			result := client.Create(context.Background(), articleUrl)
+			if result.Status != test.wantStatus {
+				t.Errorf("status = %s, %v, want %s", result.Status, result.Err, test.wantStatus)
+			}
+			var stageErr *StageError
+			if test.wantStage == "" && result.Err != nil {
+				t.Errorf("error = %v, want none", result.Err)
+			}
+			if test.wantStage != "" && (!errors.As(result.Err, &stageErr) || stageErr.Stage != test.wantStage) {
+				t.Errorf("error = %v, want a %s StageError", result.Err, test.wantStage)
+			}
+			if test.wantErr != nil && !errors.Is(result.Err, test.wantErr) {
+				t.Errorf("error = %v, want %v", result.Err, test.wantErr)
+			}
+			if test.wantTags != nil && (result.Article.Summary == nil || !slices.Equal(result.Article.Summary.Tags, test.wantTags)) {
+				t.Errorf("summary = %+v, want the tags %v", result.Article.Summary, test.wantTags)
+			}
+			if calls := provider.calls.Load(); calls != test.wantCalls {
+				t.Errorf("provider called %d times, want %d", calls, test.wantCalls)
+			}
+
+			reports, err := filepath.Glob(filepath.Join(outputFolder, "*.md"))
+			if err != nil || len(reports) != test.wantFiles {
+				t.Errorf("reports written = %v, %v, want %d", reports, err, test.wantFiles)
+			}
+		})
+	}
+}
+
+func TestCreateReportForce(t *testing.T) {
+	server := newTestArticleServer(t)
+	provider := &testProvider{answer: TEST_SUMMARY_ANSWER}
+	client := newTestClient(t, provider, WithOutputFolder(t.TempDir()))
+	articleUrl := server.URL + "/article"
+
+	first := client.Create(context.Background(), articleUrl)
+	forced := client.Derive(func(options *ReportOptions) {
+		options.Force = true
+	}).Create(context.Background(), articleUrl)
+	if first.Status != RESULT_CREATED || forced.Status != RESULT_CREATED {
+		t.Errorf("statuses = %s and %s, want the article summarized again", first.Status, forced.Status)
+	}
+	if first.Article.Id != forced.Article.Id {
+		t.Errorf("ids = %s and %s, want the id of the report kept", first.Article.Id, forced.Article.Id)
+	}
+	if calls := provider.calls.Load(); calls != 2 {
+		t.Errorf("provider called %d times, want 2", calls)
+	}
+}
+
+func TestProcessArticlesInterrupted(t *testing.T) {
+	server := newTestArticleServer(t)
+	provider := &testProvider{answer: TEST_SUMMARY_ANSWER}
+	client := newTestClient(t, provider)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := client.CreateAll(ctx, []string{server.URL + "/article", server.URL + "/other"}, 1)
+	for _, result := range results {
+		if result.Status != RESULT_FAILED || !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("result of %s = %s, %v, want canceled", result.Url, result.Status, result.Err)
+		}
+	}
+	if calls := provider.calls.Load(); calls != 0 {
+		t.Errorf("provider called %d times, want 0", calls)
+	}
+}
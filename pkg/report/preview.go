@@ -0,0 +1,153 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/brequet/report/pkg/export"
+)
+
+// Unchanged lines shown around the changes of a diff
+const DIFF_CONTEXT_LINES = 3
+
+// previewArticle prints the markdown report of an article instead of writing
+// it, or with PreviewDiff its changes to the report already written.
+func previewArticle(w io.Writer, article export.Article, template string, options ReportOptions) error {
+	var path, content string
+	var err error
+	if options.UpdatePath != "" {
+		path = options.UpdatePath
+		content, err = export.RenderUpdatedArticle(path, article, template, options.Frontmatter, options.IncludeContent)
+	} else {
+		path, content, err = export.RenderArticleFile(options.OutputFolder, article, template, options.Frontmatter, options.IncludeContent)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !options.PreviewDiff {
+		_, err := fmt.Fprintf(w, "==> %s <==\n%s\n", path, strings.TrimSuffix(content, "\n"))
+		return err
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("reading report: %w", err)
+	}
+	diff := unifiedDiff(path, strings.TrimPrefix(export.NormalizeLineEndings(string(existing)), export.UTF8_BOM), content, existing == nil)
+	if diff == "" {
+		slog.Info("No changes to the report", "path", path)
+		return nil
+	}
+	_, err = io.WriteString(w, diff)
+	return err
+}
+
+// unifiedDiff returns the changes from before to after in the unified diff
+// format, empty when they are the same. A new file is diffed from /dev/null.
+func unifiedDiff(path, before, after string, created bool) string {
+	if before == after {
+		return ""
+	}
+	beforeLines, afterLines := splitDiffLines(before), splitDiffLines(after)
+
+	// Longest common subsequence of the lines, lengths[i][j] being the one of
+	// the lines from i and from j
+	lengths := make([][]int, len(beforeLines)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(afterLines)+1)
+	}
+	for i := len(beforeLines) - 1; i >= 0; i-- {
+		for j := len(afterLines) - 1; j >= 0; j-- {
+			if beforeLines[i] == afterLines[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	type diffLine struct {
+		// ' ' for an unchanged line, '-' for a removed one, '+' for an added one
+		op   byte
+		text string
+		// Line numbers, from 1, in before and after
+		before, after int
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(beforeLines) || j < len(afterLines) {
+		switch {
+		case i < len(beforeLines) && j < len(afterLines) && beforeLines[i] == afterLines[j]:
+			lines = append(lines, diffLine{' ', beforeLines[i], i + 1, j + 1})
+			i, j = i+1, j+1
+		case j == len(afterLines) || (i < len(beforeLines) && lengths[i+1][j] >= lengths[i][j+1]):
+			lines = append(lines, diffLine{'-', beforeLines[i], i + 1, j + 1})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', afterLines[j], i + 1, j + 1})
+			j++
+		}
+	}
+
+	var sb strings.Builder
+	if created {
+		sb.WriteString("--- /dev/null\n")
+	} else {
+		fmt.Fprintf(&sb, "--- a/%s\n", path)
+	}
+	fmt.Fprintf(&sb, "+++ b/%s\n", path)
+
+	// Hunks gather the changes less than twice the context apart
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+		end := start
+		for k := start; k < len(lines) && k-end <= 2*DIFF_CONTEXT_LINES; k++ {
+			if lines[k].op != ' ' {
+				end = k
+			}
+		}
+		from := max(start-DIFF_CONTEXT_LINES, 0)
+		to := min(end+DIFF_CONTEXT_LINES+1, len(lines))
+
+		var beforeCount, afterCount int
+		for _, line := range lines[from:to] {
+			if line.op != '+' {
+				beforeCount++
+			}
+			if line.op != '-' {
+				afterCount++
+			}
+		}
+		beforeStart, afterStart := lines[from].before, lines[from].after
+		// An empty range starts at the line before it
+		if beforeCount == 0 {
+			beforeStart--
+		}
+		if afterCount == 0 {
+			afterStart--
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", beforeStart, beforeCount, afterStart, afterCount)
+		for _, line := range lines[from:to] {
+			sb.WriteByte(line.op)
+			sb.WriteString(line.text + "\n")
+		}
+		start = to
+	}
+	return sb.String()
+}
+
+func splitDiffLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
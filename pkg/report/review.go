@@ -0,0 +1,43 @@
+package report
+
+import (
+	"context"
+	"strings"
+
+	"github.com/brequet/report/pkg/summarize"
+)
+
+// ReviewDecision is what the user decided after reviewing the summary of an
+// article.
+type ReviewDecision int
+
+const (
+	REVIEW_ACCEPT ReviewDecision = iota
+	// The article is skipped, nothing being written
+	REVIEW_SKIP
+)
+
+// summarizeFunc summarizes the article again, following the instructions of
+// the user if any.
+type summarizeFunc func(ctx context.Context, instructions string) (summarize.Summary, error)
+
+// renameTag renames a tag, dropping it when the new name is already a tag.
+func renameTag(tags []string, old, new string) []string {
+	var renamed []string
+	for _, tag := range tags {
+		if strings.EqualFold(tag, old) {
+			tag = new
+		}
+		renamed = MergeTags(renamed, []string{tag})
+	}
+	return renamed
+}
+
+// withReviewInstructions adds the instructions the user gave when asking for
+// another summary to the system prompt.
+func withReviewInstructions(prompt, instructions string) string {
+	if instructions == "" {
+		return prompt
+	}
+	return strings.TrimSpace(prompt) + "\n\nThe reader reviewed a previous summary of this article and asks: " + instructions
+}
@@ -0,0 +1,296 @@
+//go:build !wasip1
+
+package report
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/summarize"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// reviewArticle shows the title, summary, keypoints and tags of an article in
+// a terminal interface before its report is written, and lets the user edit
+// the title, delete keypoints, rename tags or ask for another summary. It
+// returns the article as accepted, and whether to write it.
+func reviewArticle(ctx context.Context, article export.Article, slug bool, summarize summarizeFunc) (export.Article, ReviewDecision, error) {
+	StdinMutex.Lock()
+	defer StdinMutex.Unlock()
+
+	program := tea.NewProgram(newReviewModel(ctx, article, slug, summarize), tea.WithContext(ctx), tea.WithInput(os.Stdin), tea.WithOutput(os.Stdout), tea.WithAltScreen())
+	final, err := program.Run()
+	if err != nil {
+		return article, REVIEW_SKIP, fmt.Errorf("review: %w", err)
+	}
+	reviewed := final.(reviewModel)
+	return reviewed.article, reviewed.decision, reviewed.err
+}
+
+// reviewMode is what the keys typed in the review do.
+type reviewMode int
+
+const (
+	// The keys select a line of the review and run the commands
+	REVIEW_MODE_BROWSE reviewMode = iota
+	// The keys type the new title, the new name of the selected tag, or the
+	// instructions of the next summary
+	REVIEW_MODE_TITLE
+	REVIEW_MODE_TAG
+	REVIEW_MODE_INSTRUCTIONS
+	// The article is being summarized again, the keys are ignored
+	REVIEW_MODE_SUMMARIZING
+)
+
+var (
+	reviewHeadingStyle  = lipgloss.NewStyle().Bold(true)
+	reviewSelectedStyle = lipgloss.NewStyle().Reverse(true)
+	reviewHelpStyle     = lipgloss.NewStyle().Faint(true)
+)
+
+// reviewModel is the state of the review of an article, updated by the keys
+// of the user.
+type reviewModel struct {
+	ctx       context.Context
+	summarize summarizeFunc
+	slug      bool
+	// Article being reviewed, with its own copy of the summary
+	article export.Article
+	// Selected line: the title, then the keypoints, then the tags
+	selected int
+	mode     reviewMode
+	// Text typed in the modes editing a line or asking for a summary
+	input string
+	// Outcome of the last command, e.g. why it was refused
+	message string
+	// Width of the terminal the text is wrapped to, 0 until known
+	width    int
+	decision ReviewDecision
+	err      error
+}
+
+// summarizedMsg is the other summary asked by the user.
+type summarizedMsg struct {
+	summary summarize.Summary
+	err     error
+}
+
+func newReviewModel(ctx context.Context, article export.Article, slug bool, summarize summarizeFunc) reviewModel {
+	summary := *article.Summary
+	summary.Keypoints = slices.Clone(summary.Keypoints)
+	summary.Tags = slices.Clone(summary.Tags)
+	article.Summary = &summary
+	return reviewModel{ctx: ctx, summarize: summarize, slug: slug, article: article}
+}
+
+func (m reviewModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m reviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+	case summarizedMsg:
+		if msg.err != nil {
+			m.decision, m.err = REVIEW_SKIP, msg.err
+			return m, tea.Quit
+		}
+		summary := msg.summary
+		m.article.Summary = &summary
+		m.mode, m.selected, m.message = REVIEW_MODE_BROWSE, 0, "Summarized again"
+	case tea.KeyMsg:
+		// The standard input is read raw, Ctrl+C does not interrupt the run
+		if msg.Type == tea.KeyCtrlC {
+			m.decision = REVIEW_SKIP
+			return m, tea.Quit
+		}
+		switch m.mode {
+		case REVIEW_MODE_BROWSE:
+			if msg.Type == tea.KeyRunes && len(msg.Runes) > 1 {
+				return m.typeRunes(msg.Runes)
+			}
+			return m.browse(msg)
+		case REVIEW_MODE_SUMMARIZING:
+		default:
+			return m.edit(msg)
+		}
+	}
+	return m, nil
+}
+
+// typeRunes runs the keys read together, typed quickly or pasted, one at a
+// time, until one quits or summarizes the article again.
+func (m reviewModel) typeRunes(runes []rune) (tea.Model, tea.Cmd) {
+	var model tea.Model = m
+	for _, r := range runes {
+		var cmd tea.Cmd
+		model, cmd = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		if cmd != nil {
+			return model, cmd
+		}
+	}
+	return model, nil
+}
+
+// browse runs the command of a key typed while browsing the review.
+func (m reviewModel) browse(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.message = ""
+	keypoint, isKeypoint := m.selectedKeypoint()
+	tag, isTag := m.selectedTag()
+	switch msg.String() {
+	case "up", "k":
+		m.selected = max(0, m.selected-1)
+	case "down", "j":
+		m.selected = min(len(m.article.Summary.Keypoints)+len(m.article.Summary.Tags), m.selected+1)
+	case "enter", "a":
+		m.decision = REVIEW_ACCEPT
+		return m, tea.Quit
+	case "s":
+		m.decision = REVIEW_SKIP
+		return m, tea.Quit
+	case "t":
+		m.mode, m.input = REVIEW_MODE_TITLE, m.article.Title
+	case "e", "r":
+		switch {
+		case m.selected == 0:
+			m.mode, m.input = REVIEW_MODE_TITLE, m.article.Title
+		case isTag:
+			m.mode, m.input = REVIEW_MODE_TAG, m.article.Summary.Tags[tag]
+		default:
+			m.message = "Select the title or a tag to edit it"
+		}
+	case "d":
+		switch {
+		case !isKeypoint:
+			m.message = "Select a keypoint to delete it"
+		case len(m.article.Summary.Keypoints) == 1:
+			m.message = "A report needs a keypoint, ask for other keypoints with g instead"
+		default:
+			keypoints := m.article.Summary.Keypoints
+			m.article.Summary.Keypoints = append(keypoints[:keypoint:keypoint], keypoints[keypoint+1:]...)
+		}
+	case "g":
+		m.mode, m.input = REVIEW_MODE_INSTRUCTIONS, ""
+	}
+	return m, nil
+}
+
+// edit types the text of a line, and applies it once entered.
+func (m reviewModel) edit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.mode, m.input, m.message = REVIEW_MODE_BROWSE, "", ""
+	case tea.KeyBackspace:
+		input := []rune(m.input)
+		m.input = string(input[:max(0, len(input)-1)])
+	case tea.KeyRunes, tea.KeySpace:
+		m.input += string(msg.Runes)
+	case tea.KeyEnter:
+		return m.apply()
+	}
+	return m, nil
+}
+
+// apply applies the text typed in an editing mode.
+func (m reviewModel) apply() (tea.Model, tea.Cmd) {
+	input := strings.TrimSpace(m.input)
+	switch m.mode {
+	case REVIEW_MODE_TITLE:
+		if input == "" {
+			m.message = "The title cannot be empty"
+			return m, nil
+		}
+		if !m.slug && !export.IsValidWindowsFilename(input) {
+			m.message = "The title is the file name of the report, and is not a valid filename"
+			return m, nil
+		}
+		m.article.Title = input
+	case REVIEW_MODE_TAG:
+		if input == "" || strings.ContainsFunc(input, unicode.IsSpace) {
+			m.message = "A tag is a single word"
+			return m, nil
+		}
+		tag, _ := m.selectedTag()
+		m.article.Summary.Tags = renameTag(m.article.Summary.Tags, m.article.Summary.Tags[tag], input)
+		m.selected = min(m.selected, len(m.article.Summary.Keypoints)+len(m.article.Summary.Tags))
+	case REVIEW_MODE_INSTRUCTIONS:
+		m.mode, m.input, m.message = REVIEW_MODE_SUMMARIZING, "", "Summarizing again..."
+		ctx, summarize := m.ctx, m.summarize
+		return m, func() tea.Msg {
+			summary, err := summarize(ctx, input)
+			return summarizedMsg{summary: summary, err: err}
+		}
+	}
+	m.mode, m.input, m.message = REVIEW_MODE_BROWSE, "", ""
+	return m, nil
+}
+
+// selectedKeypoint returns the index of the selected keypoint, if one is.
+func (m reviewModel) selectedKeypoint() (int, bool) {
+	keypoint := m.selected - 1
+	return keypoint, keypoint >= 0 && keypoint < len(m.article.Summary.Keypoints)
+}
+
+// selectedTag returns the index of the selected tag, if one is.
+func (m reviewModel) selectedTag() (int, bool) {
+	tag := m.selected - 1 - len(m.article.Summary.Keypoints)
+	return tag, tag >= 0 && tag < len(m.article.Summary.Tags)
+}
+
+func (m reviewModel) View() string {
+	var view strings.Builder
+	line := func(index int, text string) {
+		text = m.wrap(text)
+		if index == m.selected {
+			text = reviewSelectedStyle.Render(text)
+		}
+		view.WriteString(text + "\n")
+	}
+
+	view.WriteString(m.wrap(reviewHeadingStyle.Render("Review: ")+m.article.Url) + "\n\n")
+	line(0, "Title: "+m.article.Title)
+	view.WriteString("\n" + m.wrap(strings.TrimSpace(m.article.Summary.Summary)) + "\n\n")
+	view.WriteString(reviewHeadingStyle.Render("Keypoints") + "\n")
+	for i, keypoint := range m.article.Summary.Keypoints {
+		line(1+i, fmt.Sprintf("  %d. %s", i+1, keypoint))
+	}
+	view.WriteString(reviewHeadingStyle.Render("Tags") + "\n")
+	for i, tag := range m.article.Summary.Tags {
+		line(1+len(m.article.Summary.Keypoints)+i, "  #"+tag)
+	}
+	view.WriteString("\n")
+
+	switch m.mode {
+	case REVIEW_MODE_TITLE:
+		view.WriteString("New title: " + m.input + "█\n")
+	case REVIEW_MODE_TAG:
+		view.WriteString("New name of the tag: " + m.input + "█\n")
+	case REVIEW_MODE_INSTRUCTIONS:
+		view.WriteString("Instructions for the next summary, if any: " + m.input + "█\n")
+	}
+	if m.message != "" {
+		view.WriteString(m.message + "\n")
+	}
+
+	help := "↑/↓ select · enter/a accept · t edit the title · e/r edit the selected tag · d delete the selected keypoint · g generate again · s skip"
+	if m.mode != REVIEW_MODE_BROWSE && m.mode != REVIEW_MODE_SUMMARIZING {
+		help = "enter apply · esc cancel"
+	}
+	view.WriteString(reviewHelpStyle.Render(m.wrap(help)))
+	return view.String()
+}
+
+// wrap wraps a text to the width of the terminal, once known.
+func (m reviewModel) wrap(text string) string {
+	if m.width <= 0 {
+		return text
+	}
+	return lipgloss.NewStyle().Width(m.width).Render(text)
+}
@@ -0,0 +1,193 @@
+//go:build !wasip1
+
+package report
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/brequet/report/pkg/export"
+	"github.com/brequet/report/pkg/scrape"
+	"github.com/brequet/report/pkg/summarize"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func newTestReviewModel(summarizeAgain summarizeFunc) reviewModel {
+	article := export.Article{
+		Article: scrape.Article{Url: "https://example.com/article", Title: "Testing the review"},
+		Summary: &summarize.Summary{Summary: "The review is tested.", Keypoints: []string{"First", "Second", "Third"}, Tags: []string{"go", "testing"}},
+	}
+	return newReviewModel(context.Background(), article, false, summarizeAgain)
+}
+
+// pressKeys sends keys to the model, a string being typed as text, and
+// returns the model and the command of the last key.
+func pressKeys(t *testing.T, model reviewModel, keys ...any) (reviewModel, tea.Cmd) {
+	t.Helper()
+	var cmd tea.Cmd
+	for _, key := range keys {
+		var msg tea.KeyMsg
+		switch key := key.(type) {
+		case tea.KeyType:
+			msg = tea.KeyMsg{Type: key}
+		case string:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		default:
+			t.Fatalf("unknown key %v", key)
+		}
+		var updated tea.Model
+		updated, cmd = model.Update(msg)
+		model = updated.(reviewModel)
+	}
+	return model, cmd
+}
+
+// isQuit tells if a command quits the review.
+func isQuit(cmd tea.Cmd) bool {
+	if cmd == nil {
+		return false
+	}
+	_, ok := cmd().(tea.QuitMsg)
+	return ok
+}
+
+func TestReviewModelDecision(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []any
+		want ReviewDecision
+	}{
+		{"enter accepts", []any{tea.KeyEnter}, REVIEW_ACCEPT},
+		{"a accepts", []any{"a"}, REVIEW_ACCEPT},
+		{"s skips", []any{"s"}, REVIEW_SKIP},
+		{"ctrl+c skips", []any{tea.KeyCtrlC}, REVIEW_SKIP},
+		{"ctrl+c skips while editing", []any{"t", tea.KeyCtrlC}, REVIEW_SKIP},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			model, cmd := pressKeys(t, newTestReviewModel(nil), test.keys...)
+			if !isQuit(cmd) || model.decision != test.want {
+				t.Errorf("decision = %v, quit %v, want %v", model.decision, isQuit(cmd), test.want)
+			}
+		})
+	}
+}
+
+func TestReviewModelTitle(t *testing.T) {
+	model, _ := pressKeys(t, newTestReviewModel(nil), "t", tea.KeyBackspace, tea.KeyBackspace, tea.KeyBackspace, tea.KeyBackspace, tea.KeyBackspace, tea.KeyBackspace, "tests", tea.KeyEnter)
+	if model.article.Title != "Testing the tests" || model.mode != REVIEW_MODE_BROWSE {
+		t.Errorf("title = %q, mode %v, want the edited title", model.article.Title, model.mode)
+	}
+
+	model, _ = pressKeys(t, newTestReviewModel(nil), "t", "?", tea.KeyEnter)
+	if model.article.Title != "Testing the review" || model.mode != REVIEW_MODE_TITLE || model.message == "" {
+		t.Errorf("title = %q, mode %v, message %q, want the invalid filename refused", model.article.Title, model.mode, model.message)
+	}
+
+	model, _ = pressKeys(t, newTestReviewModel(nil), "t", " again", tea.KeyEsc)
+	if model.article.Title != "Testing the review" || model.mode != REVIEW_MODE_BROWSE || model.input != "" {
+		t.Errorf("title = %q, mode %v, want the edit canceled", model.article.Title, model.mode)
+	}
+}
+
+func TestReviewModelKeypoints(t *testing.T) {
+	model, _ := pressKeys(t, newTestReviewModel(nil), tea.KeyDown, tea.KeyDown, "d")
+	if !slices.Equal(model.article.Summary.Keypoints, []string{"First", "Third"}) {
+		t.Errorf("keypoints = %v, want the second one deleted", model.article.Summary.Keypoints)
+	}
+
+	model, _ = pressKeys(t, newTestReviewModel(nil), "d")
+	if len(model.article.Summary.Keypoints) != 3 || model.message == "" {
+		t.Errorf("keypoints = %v, message %q, want nothing deleted with the title selected", model.article.Summary.Keypoints, model.message)
+	}
+
+	model, _ = pressKeys(t, newTestReviewModel(nil), "j", "d", "d", "d")
+	if !slices.Equal(model.article.Summary.Keypoints, []string{"Third"}) || model.message == "" {
+		t.Errorf("keypoints = %v, message %q, want the last keypoint kept", model.article.Summary.Keypoints, model.message)
+	}
+}
+
+func TestReviewModelTags(t *testing.T) {
+	// Up from the title stays on it, down past the last tag stays on it
+	model, _ := pressKeys(t, newTestReviewModel(nil), tea.KeyUp, "j", "j", "j", "j", "j", "j", "j", "r", tea.KeyBackspace, tea.KeyBackspace, tea.KeyBackspace, tea.KeyBackspace, tea.KeyBackspace, tea.KeyBackspace, tea.KeyBackspace, "qa", tea.KeyEnter)
+	if !slices.Equal(model.article.Summary.Tags, []string{"go", "qa"}) {
+		t.Errorf("tags = %v, want the last tag renamed", model.article.Summary.Tags)
+	}
+
+	model, _ = pressKeys(t, newTestReviewModel(nil), "j", "j", "j", "j", "e", tea.KeyBackspace, tea.KeyBackspace, "testing", tea.KeyEnter)
+	if !slices.Equal(model.article.Summary.Tags, []string{"testing"}) || model.selected != 4 {
+		t.Errorf("tags = %v, selected %d, want the tags merged and the selection on the remaining tag", model.article.Summary.Tags, model.selected)
+	}
+
+	model, _ = pressKeys(t, newTestReviewModel(nil), "j", "j", "j", "j", "e", " two words", tea.KeyEnter)
+	if !slices.Equal(model.article.Summary.Tags, []string{"go", "testing"}) || model.message == "" {
+		t.Errorf("tags = %v, message %q, want a tag of several words refused", model.article.Summary.Tags, model.message)
+	}
+}
+
+func TestReviewModelRegenerate(t *testing.T) {
+	var gotInstructions string
+	model, cmd := pressKeys(t, newTestReviewModel(func(ctx context.Context, instructions string) (summarize.Summary, error) {
+		gotInstructions = instructions
+		return summarize.Summary{Summary: "Shorter.", Keypoints: []string{"Only"}, Tags: []string{"go"}}, nil
+	}), "j", "g", "shorter", tea.KeyEnter)
+	if model.mode != REVIEW_MODE_SUMMARIZING || cmd == nil {
+		t.Fatalf("mode = %v, want the article summarized again", model.mode)
+	}
+	// The keys are ignored until the summary is there
+	if model, ignored := pressKeys(t, model, "a"); ignored != nil || model.mode != REVIEW_MODE_SUMMARIZING {
+		t.Errorf("mode = %v, want the keys ignored while summarizing", model.mode)
+	}
+
+	updated, _ := model.Update(cmd())
+	model = updated.(reviewModel)
+	if gotInstructions != "shorter" {
+		t.Errorf("instructions = %q, want shorter", gotInstructions)
+	}
+	if model.article.Summary.Summary != "Shorter." || model.mode != REVIEW_MODE_BROWSE || model.selected != 0 {
+		t.Errorf("summary = %q, mode %v, selected %d, want the new summary browsed", model.article.Summary.Summary, model.mode, model.selected)
+	}
+
+	failing := errors.New("overloaded")
+	model, cmd = pressKeys(t, newTestReviewModel(func(ctx context.Context, instructions string) (summarize.Summary, error) {
+		return summarize.Summary{}, failing
+	}), "g", tea.KeyEnter)
+	updated, cmd = model.Update(cmd())
+	model = updated.(reviewModel)
+	if !isQuit(cmd) || model.decision != REVIEW_SKIP || !errors.Is(model.err, failing) {
+		t.Errorf("decision = %v, err %v, want the review failed", model.decision, model.err)
+	}
+}
+
+func TestReviewModelKeysReadTogether(t *testing.T) {
+	model, cmd := pressKeys(t, newTestReviewModel(nil), "jjdtx")
+	if !slices.Equal(model.article.Summary.Keypoints, []string{"First", "Third"}) || model.input != "Testing the reviewx" || cmd != nil {
+		t.Errorf("keypoints = %v, input %q, want the keys run one at a time", model.article.Summary.Keypoints, model.input)
+	}
+
+	model, cmd = pressKeys(t, newTestReviewModel(nil), "jas")
+	if !isQuit(cmd) || model.decision != REVIEW_ACCEPT {
+		t.Errorf("decision = %v, want the keys after the accept ignored", model.decision)
+	}
+}
+
+func TestReviewModelCopiesSummary(t *testing.T) {
+	article := export.Article{Summary: &summarize.Summary{Keypoints: []string{"First", "Second"}, Tags: []string{"go"}}}
+	pressKeys(t, newReviewModel(context.Background(), article, false, nil), "j", "d")
+	if !slices.Equal(article.Summary.Keypoints, []string{"First", "Second"}) {
+		t.Errorf("keypoints of the article = %v, want them unchanged by the review", article.Summary.Keypoints)
+	}
+}
+
+func TestReviewModelView(t *testing.T) {
+	model, _ := pressKeys(t, newTestReviewModel(nil), "t")
+	view := model.View()
+	for _, want := range []string{"https://example.com/article", "Testing the review", "The review is tested.", "2. Second", "#testing", "New title: Testing the review", "esc cancel"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view does not contain %q:\n%s", want, view)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+//go:build wasip1
+
+package report
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/brequet/report/pkg/export"
+)
+
+// reviewArticle fails the articles to review under WebAssembly, which has no
+// terminal to review them in.
+func reviewArticle(ctx context.Context, article export.Article, slug bool, summarize summarizeFunc) (export.Article, ReviewDecision, error) {
+	return article, REVIEW_SKIP, fmt.Errorf("the review needs a terminal, which WebAssembly has not")
+}
@@ -0,0 +1,118 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/brequet/report/internal/paths"
+	"github.com/brequet/report/pkg/summarize"
+)
+
+const STATS_FILE_NAME = "stats.json"
+
+// UsageStats holds the cumulative usage of the tool, persisted in the state
+// directory: in total, by month and by model.
+type UsageStats struct {
+	mu sync.Mutex
+	// Path of the stats file, in the state directory
+	Path   string                     `json:"-"`
+	Total  summarize.Usage            `json:"total"`
+	Months map[string]summarize.Usage `json:"months"`
+	// Usage by model, as provider/model, without articles as an article may
+	// be summarized by several models
+	Models map[string]summarize.Usage `json:"models"`
+}
+
+var (
+	usageStatsOnce sync.Once
+	usageStats     *UsageStats
+	usageStatsErr  error
+)
+
+// LoadUsageStats reads the usage stats from the state directory, empty ones
+// if there are none yet.
+func LoadUsageStats() (*UsageStats, error) {
+	dir, err := paths.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	stats := &UsageStats{Path: filepath.Join(dir, STATS_FILE_NAME)}
+
+	data, err := os.ReadFile(stats.Path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading stats: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, stats); err != nil {
+			return nil, fmt.Errorf("unmarshaling stats: %w", err)
+		}
+	}
+	if stats.Months == nil {
+		stats.Months = map[string]summarize.Usage{}
+	}
+	if stats.Models == nil {
+		stats.Models = map[string]summarize.Usage{}
+	}
+	return stats, nil
+}
+
+// recordUsageStats adds the usage of an article to the stats, loaded on the
+// first article of the run.
+func recordUsageStats(recorder *summarize.UsageRecorder, created bool, now time.Time) error {
+	usageStatsOnce.Do(func() {
+		usageStats, usageStatsErr = LoadUsageStats()
+	})
+	if usageStatsErr != nil {
+		return usageStatsErr
+	}
+	usage := recorder.Usage()
+	if created {
+		usage.Articles = 1
+	}
+	if usage == (summarize.Usage{}) {
+		return nil
+	}
+	return usageStats.add(usage, recorder.ModelUsage(), now)
+}
+
+// add records usage and saves the stats.
+func (s *UsageStats) add(usage summarize.Usage, models map[string]summarize.Usage, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Total.Add(usage)
+	month := s.Months[UsageMonth(now)]
+	month.Add(usage)
+	s.Months[UsageMonth(now)] = month
+	for model, modelUsage := range models {
+		total := s.Models[model]
+		total.Add(modelUsage)
+		s.Models[model] = total
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return fmt.Errorf("creating state folder: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling stats: %w", err)
+	}
+	if err := os.WriteFile(s.Path, data, 0600); err != nil {
+		return fmt.Errorf("writing stats: %w", err)
+	}
+	return nil
+}
+
+// Months are keyed as YYYY-MM.
+const USAGE_MONTH_FORMAT = "2006-01"
+
+// UsageMonth returns the key of the month of a date in the usage stats.
+func UsageMonth(now time.Time) string {
+	return now.Format(USAGE_MONTH_FORMAT)
+}
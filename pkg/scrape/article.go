@@ -0,0 +1,164 @@
+package scrape
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Format of the publication dates of the articles, and of the dates of the
+// reports.
+const DATE_FORMAT = "2006-01-02"
+
+// Article is an article as read from its page.
+type Article struct {
+	Url         string
+	Title       string
+	Content     string
+	ContentType string
+	// Metadata given by the page, empty when not found
+	Authors   []string
+	SiteName  string
+	Published time.Time
+	// Url of the image representing the article
+	Image string
+	// Images of the body of the page
+	Images []ArticleImage
+	// Data tables of the body of the page, only kept in the reports with
+	// -tables
+	Tables []DataTable
+	// Body of the page converted to markdown, empty for the pages that are
+	// not HTML pages (see -include-content markdown)
+	Markdown string
+	// Wayback Machine snapshot the article was read from, and its date, when
+	// the page was gone or paywalled (see -archive)
+	ArchiveUrl string
+	Archived   time.Time
+	// ISO 639-1 code of the language of the article, empty if unknown
+	Language string
+	// Length of a video, zero for the other articles
+	Duration time.Duration
+}
+
+// paywallRegex matches the structured data and metadata publishers use to tell
+// search engines that the content of a page is restricted to subscribers.
+var paywallRegex = regexp.MustCompile(`(?i)"isAccessibleForFree"\s*:\s*"?false|<meta[^>]+property=["']article:content_tier["'][^>]+content=["']locked`)
+
+// Media types of the pages summarized, by extractor: HTML pages, PDF
+// documents and plain text. Other pages, e.g. images or archives, fail with
+// ErrUnsupportedContent rather than being summarized as text.
+var articleMediaTypes = map[string]bool{
+	"text/html":             true,
+	"application/xhtml+xml": true,
+	"application/pdf":       true,
+	"text/plain":            true,
+	"text/markdown":         true,
+}
+
+// FetchedPage is the body of a downloaded page, with what the server told
+// about it.
+type FetchedPage struct {
+	Body []byte
+	// Media type of the body, e.g. text/html, sniffed from the body when the
+	// server does not tell
+	MediaType string
+	Charset   string
+	// Urls the request was redirected to, in order, the last one being the
+	// url of the body
+	Redirects []string
+	// Validators of the body, to ask the server whether it changed
+	ETag         string
+	LastModified string
+	// Answer of the server to a conditional request, the page being the
+	// cached one, see fetchArticlePage
+	NotModified bool
+}
+
+func scrapeArticleTitle(pageContent string) (string, error) {
+	h1Regex := `<h1.*?>(.*?)</h1>`
+	h1Match := findFirstMatch(h1Regex, pageContent)
+	if h1Match == "" {
+		return "", fmt.Errorf("%w: no h1 found in page content", ErrNoTitle)
+	}
+
+	return h1Match, nil
+}
+
+func scrapePageBody(pageContent string) (string, error) {
+	bodyRegex := `(?s)<body.*?>(.*?)</body>`
+	bodyMatch := findFirstMatch(bodyRegex, pageContent)
+	if bodyMatch == "" {
+		return "", fmt.Errorf("no body found in page content")
+	}
+
+	return cleanBodyContent(bodyMatch), nil
+}
+
+func findFirstMatch(regex string, content string) string {
+	re := regexp.MustCompile(regex)
+	match := re.FindStringSubmatch(content)
+	if len(match) == 0 {
+		return ""
+	} else {
+		return match[1]
+	}
+}
+
+func cleanBodyContent(content string) string {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		slog.Warn("could not parse content as HTML", "err", err)
+		return content
+	}
+
+	var buf bytes.Buffer
+
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+		} else if n.Type == html.ElementNode {
+			switch n.Data {
+			case "script", "style", "nav", "footer", "header":
+				return
+			case "p", "h1", "h2", "h3", "h4", "h5", "h6", "div":
+				buf.WriteString("\n")
+			case "img":
+				for _, attr := range n.Attr {
+					if attr.Key == "alt" {
+						buf.WriteString("[Image: " + attr.Val + "]")
+						break
+					}
+				}
+				return
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "p", "br", "div":
+				buf.WriteString("\n")
+			}
+		}
+	}
+
+	traverse(doc)
+
+	// Paragraphs are kept, for the excerpts and the full text of the reports
+	var paragraphs []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if paragraph := strings.Join(strings.Fields(line), " "); paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
@@ -0,0 +1,158 @@
+package scrape
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/brequet/report/internal/paths"
+)
+
+const (
+	PAGE_CACHE_FOLDER = "pages"
+	LLM_CACHE_FOLDER  = "llm"
+	// Age under which a cached page is used without asking the server
+	DEFAULT_PAGE_CACHE_AGE = 24 * time.Hour
+)
+
+// ResponseCache keeps the fetched pages and the LLM answers in a directory,
+// so that summarizing an article again, e.g. to try a template, neither
+// fetches its page nor pays for its summary again. The pages are keyed by
+// canonical url and revalidated with their ETag or Last-Modified date once
+// older than PageMaxAge, the answers by a hash of the provider, the model,
+// the generation parameters and the messages. A cache without directory
+// keeps nothing.
+type ResponseCache struct {
+	Dir        string
+	Disabled   bool
+	PageMaxAge time.Duration
+}
+
+// NewResponseCache returns the cache of the cache directory, one without
+// directory if there is none.
+func NewResponseCache() ResponseCache {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		slog.Debug("No cache directory, nothing is cached", "err", err)
+	}
+	return ResponseCache{Dir: dir, PageMaxAge: DEFAULT_PAGE_CACHE_AGE}
+}
+
+// CachedPage is a fetched page in the cache, with its validators.
+type CachedPage struct {
+	Url          string    `json:"url"`
+	Fetched      time.Time `json:"fetched"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	MediaType    string    `json:"media_type"`
+	Charset      string    `json:"charset,omitempty"`
+	Redirects    []string  `json:"redirects,omitempty"`
+	Body         []byte    `json:"body"`
+}
+
+// page returns the cached page of an url, if any.
+func (c ResponseCache) page(pageUrl string) (CachedPage, bool) {
+	var page CachedPage
+	if !c.Read(PAGE_CACHE_FOLDER, CacheKey(CanonicalUrl(pageUrl)), &page) {
+		return CachedPage{}, false
+	}
+	return page, true
+}
+
+func (c ResponseCache) savePage(pageUrl string, page CachedPage) {
+	c.Write(PAGE_CACHE_FOLDER, CacheKey(CanonicalUrl(pageUrl)), page)
+}
+
+// fetchedPage returns the cached page as fetched.
+func (p CachedPage) fetchedPage() FetchedPage {
+	return FetchedPage{Body: p.Body, MediaType: p.MediaType, Charset: p.Charset, Redirects: p.Redirects, ETag: p.ETag, LastModified: p.LastModified}
+}
+
+// revalidate makes a request conditional, the server answering 304 Not
+// Modified when the page did not change since it was cached.
+func (p CachedPage) revalidate(req *http.Request) {
+	if p.ETag != "" {
+		req.Header.Set("If-None-Match", p.ETag)
+	}
+	if p.LastModified != "" {
+		req.Header.Set("If-Modified-Since", p.LastModified)
+	}
+}
+
+// Read decodes the entry of a key into value, telling whether it was found.
+// A cache that cannot be read is a cache without entries.
+func (c ResponseCache) Read(folder, key string, value any) bool {
+	if c.Disabled || c.Dir == "" {
+		return false
+	}
+	path := c.entryPath(folder, key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Debug("Cache entry not read", "path", path, "err", err)
+		}
+		return false
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		slog.Debug("Cache entry not read", "path", path, "err", err)
+		return false
+	}
+	return true
+}
+
+// Write saves the entry of a key, through a temporary file so that the
+// concurrent readers never read half of it. The errors are only logged, the
+// cache being a shortcut.
+func (c ResponseCache) Write(folder, key string, value any) {
+	if c.Disabled || c.Dir == "" {
+		return
+	}
+	path := c.entryPath(folder, key)
+	if err := writeCacheFile(path, value); err != nil {
+		slog.Debug("Cache entry not written", "folder", folder, "err", err)
+	}
+}
+
+func writeCacheFile(path string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (c ResponseCache) entryPath(folder, key string) string {
+	return filepath.Join(c.Dir, folder, key+".json")
+}
+
+// CacheKey returns the hash of the JSON encoding of parts, the key of a cache
+// entry.
+func CacheKey(parts ...any) string {
+	data, _ := json.Marshal(parts)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,63 @@
+package scrape
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// HostLimiter keeps the tool polite with the fetched websites: a host is only
+// sent one request at a time, with a minimum delay between two requests.
+type HostLimiter struct {
+	delay time.Duration
+	mu    sync.Mutex
+	hosts map[string]*hostSlot
+}
+
+type hostSlot struct {
+	mu          sync.Mutex
+	lastRequest time.Time
+}
+
+// NewHostLimiter returns a limiter waiting delay between two requests to a
+// host.
+func NewHostLimiter(delay time.Duration) *HostLimiter {
+	return &HostLimiter{delay: delay, hosts: map[string]*hostSlot{}}
+}
+
+// Acquire blocks until a request can be sent to the host of the url. The
+// returned function must be called once the request is done.
+func (l *HostLimiter) Acquire(ctx context.Context, rawUrl string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	host := rawUrl
+	if parsed, err := url.Parse(rawUrl); err == nil {
+		host = parsed.Host
+	}
+
+	l.mu.Lock()
+	slot, ok := l.hosts[host]
+	if !ok {
+		slot = &hostSlot{}
+		l.hosts[host] = slot
+	}
+	l.mu.Unlock()
+
+	slot.mu.Lock()
+	if wait := l.delay - time.Since(slot.lastRequest); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			slot.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+
+	return func() {
+		slot.lastRequest = time.Now()
+		slot.mu.Unlock()
+	}, nil
+}
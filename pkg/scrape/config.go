@@ -0,0 +1,17 @@
+package scrape
+
+// SiteConfig holds the CSS selectors of the title and the content of the
+// pages of a site, and of the elements stripped from them.
+type SiteConfig struct {
+	Title   string   `yaml:"title,omitempty"`
+	Content string   `yaml:"content,omitempty"`
+	Strip   []string `yaml:"strip,omitempty"`
+}
+
+// OcrConfig holds the defaults of the OCR of the images, like the -ocr,
+// -ocr-backend and -ocr-lang flags.
+type OcrConfig struct {
+	Mode     string `yaml:"mode,omitempty"`
+	Backend  string `yaml:"backend,omitempty"`
+	Language string `yaml:"language,omitempty"`
+}
@@ -0,0 +1,112 @@
+package scrape
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	CONTENT_TYPE_ARTICLE       = "article"
+	CONTENT_TYPE_NEWS          = "news"
+	CONTENT_TYPE_TUTORIAL      = "tutorial"
+	CONTENT_TYPE_PAPER         = "paper"
+	CONTENT_TYPE_OPINION       = "opinion"
+	CONTENT_TYPE_RELEASE_NOTES = "release-notes"
+	CONTENT_TYPE_VIDEO         = "video"
+)
+
+var ContentTypes = []string{
+	CONTENT_TYPE_ARTICLE,
+	CONTENT_TYPE_NEWS,
+	CONTENT_TYPE_TUTORIAL,
+	CONTENT_TYPE_PAPER,
+	CONTENT_TYPE_OPINION,
+	CONTENT_TYPE_RELEASE_NOTES,
+	CONTENT_TYPE_VIDEO,
+}
+
+// IsKnownContentType tells if a content type is one of ContentTypes.
+func IsKnownContentType(contentType string) bool {
+	for _, known := range ContentTypes {
+		if known == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	videoHostRegex        = regexp.MustCompile(`(^|\.)(youtube\.com|youtu\.be|vimeo\.com|dailymotion\.com|twitch\.tv)$`)
+	paperHostRegex        = regexp.MustCompile(`(^|\.)(arxiv\.org|doi\.org|acm\.org|ieee\.org|semanticscholar\.org|researchgate\.net|openreview\.net|biorxiv\.org)$`)
+	releaseNotesPathRegex = regexp.MustCompile(`(?i)/(releases?|changelog|release-notes|whats-new)(/|$|-)`)
+	releaseTitleRegex     = regexp.MustCompile(`(?i)(release notes|changelog|\bv?\d+\.\d+(\.\d+)?\b.*\breleased?\b|\breleased?\b.*\bv?\d+\.\d+(\.\d+)?\b)`)
+	opinionPathRegex      = regexp.MustCompile(`(?i)/(opinions?|op-ed|editorials?|commentary|column)/`)
+	tutorialPathRegex     = regexp.MustCompile(`(?i)/(tutorials?|how-?to|guides?|getting-started|learn)(/|-|$)`)
+	tutorialTitleRegex    = regexp.MustCompile(`(?i)^(how to|how-to|getting started|a guide|guide to|step by step|learn\s)|tutorial`)
+	newsPathRegex         = regexp.MustCompile(`(?i)/(news|\d{4}/\d{2}/\d{2})/`)
+	ogTypeRegex           = regexp.MustCompile(`(?i)<meta[^>]+property=["']og:type["'][^>]+content=["']([^"']+)["']`)
+	paperMetaRegex        = regexp.MustCompile(`(?i)<meta[^>]+name=["']citation_(title|doi|journal_title)["']`)
+	opinionMetaRegex      = regexp.MustCompile(`(?i)<meta[^>]+property=["']article:section["'][^>]+content=["'](opinion|editorial|commentary)`)
+)
+
+// detectContentType guesses the kind of content of a page from its url, its
+// title and its metadata, falling back to a generic article.
+func detectContentType(articleUrl, title, page string) string {
+	parsed, err := url.Parse(articleUrl)
+	if err != nil {
+		return CONTENT_TYPE_ARTICLE
+	}
+	host := strings.ToLower(parsed.Hostname())
+	path := parsed.Path
+	var ogType string
+	if match := ogTypeRegex.FindStringSubmatch(page); match != nil {
+		ogType = strings.ToLower(match[1])
+	}
+
+	switch {
+	case videoHostRegex.MatchString(host) || strings.HasPrefix(ogType, "video"):
+		return CONTENT_TYPE_VIDEO
+	case paperHostRegex.MatchString(host) || strings.HasSuffix(strings.ToLower(path), ".pdf") || paperMetaRegex.MatchString(page):
+		return CONTENT_TYPE_PAPER
+	case releaseNotesPathRegex.MatchString(path) || releaseTitleRegex.MatchString(title):
+		return CONTENT_TYPE_RELEASE_NOTES
+	case opinionPathRegex.MatchString(path) || opinionMetaRegex.MatchString(page):
+		return CONTENT_TYPE_OPINION
+	case tutorialPathRegex.MatchString(path) || tutorialTitleRegex.MatchString(title):
+		return CONTENT_TYPE_TUTORIAL
+	case newsPathRegex.MatchString(path):
+		return CONTENT_TYPE_NEWS
+	}
+
+	return CONTENT_TYPE_ARTICLE
+}
+
+// ContentTypeFiles maps a content type to a user provided file, overriding the
+// embedded one. It implements flag.Value, as a repeatable "type=path" flag.
+type ContentTypeFiles map[string]string
+
+func (c ContentTypeFiles) String() string {
+	var pairs []string
+	for contentType, path := range c {
+		pairs = append(pairs, contentType+"="+path)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (c ContentTypeFiles) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		contentType, path, found := strings.Cut(pair, "=")
+		if !found || path == "" {
+			return fmt.Errorf("expected type=path, got '%s'", pair)
+		}
+		if !IsKnownContentType(contentType) {
+			return fmt.Errorf("unknown content type '%s', expected one of %s", contentType, strings.Join(ContentTypes, ", "))
+		}
+		c[contentType] = path
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// Cover images bigger than this are not downloaded.
+	MAX_COVER_SIZE = 10 * 1024 * 1024
+	// Images smaller than this, in pixels, are icons, avatars or tracking
+	// pixels rather than illustrations of the article.
+	MIN_COVER_DIMENSION = 100
+)
+
+var coverExtensions = map[string]string{
+	"image/jpeg":    ".jpg",
+	"image/png":     ".png",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/avif":    ".avif",
+	"image/svg+xml": ".svg",
+	// The icons of the sites
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+}
+
+// selectCoverImage returns the absolute url of the image representing a page:
+// the preview image given by its metadata, else the largest image of its body.
+func selectCoverImage(pageUrl, metadataImage, page string) string {
+	imageUrl := metadataImage
+	if imageUrl == "" {
+		imageUrl = largestContentImage(page)
+	}
+	if imageUrl == "" || strings.HasPrefix(imageUrl, "data:") {
+		return ""
+	}
+
+	base, err := url.Parse(pageUrl)
+	if err != nil {
+		return ""
+	}
+	resolved, err := base.Parse(imageUrl)
+	if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+		return ""
+	}
+	return resolved.String()
+}
+
+// largestContentImage returns the source of the largest image of the body of
+// a page, by the dimensions of its attributes, or of its first image when no
+// dimensions are given.
+func largestContentImage(page string) string {
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		return ""
+	}
+
+	var largest, first string
+	largestArea := 0
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "head", "nav", "header", "footer", "aside", "script", "noscript":
+				return
+			case "img":
+				src := strings.TrimSpace(htmlAttribute(n, "src"))
+				if src == "" || strings.HasPrefix(src, "data:") {
+					break
+				}
+				width, _ := strconv.Atoi(htmlAttribute(n, "width"))
+				height, _ := strconv.Atoi(htmlAttribute(n, "height"))
+				if (width > 0 && width < MIN_COVER_DIMENSION) || (height > 0 && height < MIN_COVER_DIMENSION) {
+					break
+				}
+				if first == "" {
+					first = src
+				}
+				if width*height > largestArea {
+					largest, largestArea = src, width*height
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+	traverse(doc)
+
+	if largest != "" {
+		return largest
+	}
+	return first
+}
+
+// DownloadImage downloads an image of at most MAX_COVER_SIZE bytes, and
+// returns it with the extension of its media type.
+func (s *Scraper) DownloadImage(ctx context.Context, imageUrl, operation string) ([]byte, string, error) {
+	ctx, cancel := WithTimeout(ctx, s.Policy.Timeout, operation)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", imageUrl, nil)
+	if err != nil {
+		return nil, "", &FetchError{Url: imageUrl, Err: err}
+	}
+	if err := s.Policy.checkUrl(req.URL); err != nil {
+		return nil, "", &FetchError{Url: imageUrl, Err: err}
+	}
+	s.Credentials.Identify(req)
+
+	res, err := s.httpClient().Do(req)
+	if err != nil {
+		return nil, "", &FetchError{Url: imageUrl, Err: TimeoutCause(ctx, err)}
+	}
+	defer res.Body.Close()
+
+	fetchErr := FetchError{Url: imageUrl, FinalUrl: res.Request.URL.String(), StatusCode: res.StatusCode, Status: res.Status}
+	if res.StatusCode >= 400 {
+		fetchErr.Err = fmt.Errorf("unexpected response status")
+		return nil, "", &fetchErr
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	extension, ok := coverExtensions[mediaType]
+	if !ok {
+		// Servers sending images as application/octet-stream
+		extension = strings.ToLower(path.Ext(res.Request.URL.Path))
+		if !isCoverExtension(extension) {
+			fetchErr.Err = fmt.Errorf("not an image: %s", res.Header.Get("Content-Type"))
+			return nil, "", &fetchErr
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, MAX_COVER_SIZE+1))
+	if err != nil {
+		fetchErr.Err = fmt.Errorf("reading body: %w", TimeoutCause(ctx, err))
+		return nil, "", &fetchErr
+	}
+	if len(data) > MAX_COVER_SIZE {
+		fetchErr.Err = fmt.Errorf("image bigger than %d MB", MAX_COVER_SIZE/1024/1024)
+		return nil, "", &fetchErr
+	}
+	return data, extension, nil
+}
+
+func isCoverExtension(extension string) bool {
+	for _, known := range coverExtensions {
+		if extension == known || extension == ".jpeg" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,6 @@
+// Package scrape fetches web pages and extracts their article: its text, its
+// metadata, its images and tables, and its markdown. A Scraper holds the
+// policies of the fetches, such as the retries, the credentials, the headless
+// browser rendering, the OCR of the images and the page cache, as values, so
+// that scrapers with different policies can run side by side.
+package scrape
@@ -0,0 +1,66 @@
+package scrape
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/brequet/report/internal/errfmt"
+)
+
+// Sentinel errors, for callers to branch on the cause of a failure with
+// errors.Is whatever the error wrapping it.
+var (
+	// ErrNoTitle is returned when no article title is found in the page.
+	ErrNoTitle = errors.New("no article title found")
+	// ErrPaywalled is returned when the article is behind a paywall.
+	ErrPaywalled = errors.New("article is paywalled")
+	// ErrRateLimited is returned when a website or an LLM provider rejects a
+	// request because of too many requests.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrTimeout is returned when a page fetch or an LLM call takes longer
+	// than its timeout.
+	ErrTimeout = errors.New("timed out")
+	// ErrUnsupportedContent is returned when a page is neither an HTML page,
+	// a PDF document nor plain text, e.g. an image or an archive.
+	ErrUnsupportedContent = errors.New("unsupported content type")
+)
+
+// FetchError is the failure of the download of a page.
+type FetchError struct {
+	Url string
+	// Url the request was redirected to, if any.
+	FinalUrl   string
+	StatusCode int
+	Status     string
+	// Delay asked by the server with a Retry-After header.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *FetchError) Error() string {
+	var diagnostics []string
+	if e.Status != "" {
+		diagnostics = append(diagnostics, "HTTP "+e.Status)
+	}
+	if e.FinalUrl != "" && e.FinalUrl != e.Url {
+		diagnostics = append(diagnostics, "final url "+e.FinalUrl)
+	}
+	return errfmt.WithDiagnostics(fmt.Sprintf("fetching '%s': %v", e.Url, e.Err), diagnostics)
+}
+
+// Transient tells if the download may succeed when retried, a network error
+// having no status.
+func (e *FetchError) Transient() (bool, time.Duration) {
+	return e.StatusCode == 0 || IsTransientStatus(e.StatusCode), e.RetryAfter
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+func (e *FetchError) Is(target error) bool {
+	return (target == ErrRateLimited && e.StatusCode == http.StatusTooManyRequests) ||
+		(target == ErrPaywalled && e.StatusCode == http.StatusPaymentRequired)
+}
@@ -0,0 +1,171 @@
+package scrape
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/internal/buildinfo"
+)
+
+// Prefix of the cookies only sent over HTTP in the Netscape cookie files, the
+// other lines starting with # being comments.
+const HTTP_ONLY_COOKIE_PREFIX = "#HttpOnly_"
+
+// FetchCredentials are the identity the pages are fetched with: the user
+// agent, for all the pages, and the headers, basic authentication and cookies
+// of the user, for the sites requiring an account.
+type FetchCredentials struct {
+	UserAgent string
+	Headers   HeaderFlags
+	// Basic authentication, as user:password
+	BasicAuth string
+	// Cookie file in the Netscape format, as exported by curl and the
+	// browser extensions
+	CookieFile string
+	jar        http.CookieJar
+}
+
+// DefaultUserAgent identifies the tool to the sites, instead of Go's
+// "Go-http-client/1.1" that many of them block.
+func DefaultUserAgent() string {
+	return "report/" + buildinfo.GetVersion() + " (+https://github.com/brequet/report)"
+}
+
+// Load validates the credentials and reads the cookie file.
+func (c *FetchCredentials) Load() error {
+	if c.BasicAuth != "" && !strings.Contains(c.BasicAuth, ":") {
+		return fmt.Errorf("invalid basic authentication, expected user:password")
+	}
+	if c.CookieFile == "" {
+		return nil
+	}
+
+	var err error
+	c.jar, err = loadCookieFile(c.CookieFile, time.Now())
+	return err
+}
+
+// authorize adds the user agent, headers and basic authentication to the
+// request of a page. The cookies are sent by the cookie jar of the client.
+func (c FetchCredentials) authorize(req *http.Request) {
+	for name, values := range c.Headers {
+		req.Header[name] = values
+	}
+	if c.BasicAuth != "" {
+		user, password, _ := strings.Cut(c.BasicAuth, ":")
+		req.SetBasicAuth(user, password)
+	}
+	c.Identify(req)
+}
+
+// Identify sets the user agent of a request, unless set by a header.
+func (c FetchCredentials) Identify(req *http.Request) {
+	if req.Header.Get("User-Agent") == "" && c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+}
+
+// loadCookieFile returns a cookie jar holding the cookies of a Netscape
+// cookie file, one cookie per line with tab separated domain, subdomains
+// flag, path, secure flag, expiration time, name and value. Expired cookies
+// are dropped.
+func loadCookieFile(path string, now time.Time) (http.CookieJar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cookie file: %w", err)
+	}
+	defer file.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, HTTP_ONLY_COOKIE_PREFIX)
+		line = strings.TrimPrefix(line, HTTP_ONLY_COOKIE_PREFIX)
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("cookie file '%s', line %d: expected 7 tab separated fields, got %d", path, lineNumber, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cookie file '%s', line %d: invalid expiration time '%s'", path, lineNumber, fields[4])
+		}
+
+		host := strings.TrimPrefix(fields[0], ".")
+		if host == "" {
+			return nil, fmt.Errorf("cookie file '%s', line %d: cookie without a domain", path, lineNumber)
+		}
+		cookie := &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		// Session cookies have no expiration time
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+			if cookie.Expires.Before(now) {
+				continue
+			}
+		}
+		// Cookies without a domain are only sent to the host setting them
+		if !strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = ""
+		}
+		if cookie.Path == "" {
+			cookie.Path = "/"
+		}
+
+		scheme := "http"
+		if cookie.Secure {
+			scheme = "https"
+		}
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: cookie.Path}, []*http.Cookie{cookie})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading cookie file: %w", err)
+	}
+	return jar, nil
+}
+
+// HeaderFlags are the headers given by a repeatable "Name: value" flag. It
+// implements flag.Value.
+type HeaderFlags http.Header
+
+func (h HeaderFlags) String() string {
+	var lines []string
+	for name, values := range h {
+		for _, value := range values {
+			lines = append(lines, name+": "+value)
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, ", ")
+}
+
+func (h HeaderFlags) Set(value string) error {
+	name, headerValue, found := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("expected 'Name: value', got '%s'", value)
+	}
+	http.Header(h).Add(name, strings.TrimSpace(headerValue))
+	return nil
+}
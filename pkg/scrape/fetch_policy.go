@@ -0,0 +1,157 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"syscall"
+	"time"
+)
+
+const (
+	DEFAULT_MAX_REDIRECTS = 10
+	DEFAULT_FETCH_TIMEOUT = 30 * time.Second
+)
+
+// FetchPolicy restricts the pages the tool can fetch. Blocking private
+// networks is meant for instances fetching urls given by untrusted users, so
+// that they cannot be used to reach the internal services of their network
+// (server-side request forgery).
+type FetchPolicy struct {
+	MaxRedirects         int
+	BlockPrivateNetworks bool
+	// Maximum duration of a fetch, body included, 0 for no limit.
+	Timeout time.Duration
+	// Hosts connected to even on a private network, such as the rendering
+	// service, their redirects being checked as any other.
+	TrustedHosts []string
+}
+
+// ErrBlockedUrl is returned when a url is refused by the fetch policy.
+var ErrBlockedUrl = errors.New("url blocked by the fetch policy")
+
+// Special purpose ranges not covered by the net.IP methods.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, may map to private IPv4 addresses
+}
+
+func isPrivateAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUrl validates a url to fetch, before the request and for every redirect.
+func (p FetchPolicy) checkUrl(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("%w: unsupported scheme '%s', expected http or https", ErrBlockedUrl, target.Scheme)
+	}
+	if target.Hostname() == "" {
+		return fmt.Errorf("%w: no host", ErrBlockedUrl)
+	}
+	if p.BlockPrivateNetworks && target.Hostname() == "localhost" {
+		return fmt.Errorf("%w: localhost", ErrBlockedUrl)
+	}
+	return nil
+}
+
+func (p FetchPolicy) checkRawUrl(rawUrl string) error {
+	target, err := url.Parse(rawUrl)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockedUrl, err)
+	}
+	return p.checkUrl(target)
+}
+
+// checkResolvedUrl validates a url as checkUrl does, and when private
+// networks are blocked, checks the addresses its host resolves to, for the
+// pages served without connecting to their host, such as the cached ones.
+func (p FetchPolicy) checkResolvedUrl(ctx context.Context, rawUrl string) error {
+	if err := p.checkRawUrl(rawUrl); err != nil {
+		return err
+	}
+	target, _ := url.Parse(rawUrl)
+	if !p.BlockPrivateNetworks {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", target.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: resolving %s: %w", ErrBlockedUrl, target.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if isPrivateAddress(addr) {
+			return fmt.Errorf("%w: private address %s", ErrBlockedUrl, addr)
+		}
+	}
+	return nil
+}
+
+// httpClient returns a client enforcing the policy, with the cookies of a
+// jar if not nil. Addresses are checked when connecting rather than when
+// parsing the url, so that a host name resolving to a private address is
+// blocked too, whatever the DNS answers.
+func (p FetchPolicy) httpClient(jar http.CookieJar) *http.Client {
+	return &http.Client{
+		Transport: LoggingTransport{Next: p.transport()},
+		Jar:       jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > p.MaxRedirects {
+				return fmt.Errorf("%w: more than %d redirects", ErrBlockedUrl, p.MaxRedirects)
+			}
+			return p.checkUrl(req.URL)
+		},
+	}
+}
+
+func (p FetchPolicy) transport() http.RoundTripper {
+	defaultTransport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		// The requests are carried out by the host of the WebAssembly build,
+		// the addresses are not known to the process
+		return http.DefaultTransport
+	}
+
+	transport := defaultTransport.Clone()
+	if p.BlockPrivateNetworks {
+		// A proxy would connect to the target in our place
+		transport.Proxy = nil
+		dialer := &net.Dialer{
+			Control: func(network, address string, _ syscall.RawConn) error {
+				addrPort, err := netip.ParseAddrPort(address)
+				if err != nil {
+					return fmt.Errorf("%w: %w", ErrBlockedUrl, err)
+				}
+				if isPrivateAddress(addrPort.Addr()) {
+					return fmt.Errorf("%w: private address %s", ErrBlockedUrl, addrPort.Addr())
+				}
+				return nil
+			},
+		}
+		transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			if host, _, err := net.SplitHostPort(address); err == nil && slices.Contains(p.TrustedHosts, host) {
+				return defaultDialer.DialContext(ctx, network, address)
+			}
+			return dialer.DialContext(ctx, network, address)
+		}
+	}
+	return transport
+}
+
+// defaultDialer connects to the trusted hosts as http.DefaultTransport does.
+var defaultDialer = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
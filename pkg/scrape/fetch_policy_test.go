@@ -0,0 +1,139 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestIsPrivateAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		private bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"::1", true},
+		{"fd00::1", true},
+		{"::ffff:10.0.0.1", true},
+		{"64:ff9b::a00:1", true},
+		{"0.0.0.0", true},
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+	}
+	for _, test := range tests {
+		t.Run(test.addr, func(t *testing.T) {
+			if private := isPrivateAddress(netip.MustParseAddr(test.addr)); private != test.private {
+				t.Errorf("isPrivateAddress(%s) = %v, want %v", test.addr, private, test.private)
+			}
+		})
+	}
+}
+
+func TestCheckResolvedUrl(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  FetchPolicy
+		url     string
+		blocked bool
+	}{
+		{"public address", FetchPolicy{BlockPrivateNetworks: true}, "https://93.184.216.34/page", false},
+		{"private address", FetchPolicy{BlockPrivateNetworks: true}, "http://10.0.0.1/admin", true},
+		{"loopback address", FetchPolicy{BlockPrivateNetworks: true}, "http://127.0.0.1:8080/", true},
+		{"localhost", FetchPolicy{BlockPrivateNetworks: true}, "http://localhost/", true},
+		{"private networks allowed", FetchPolicy{}, "http://10.0.0.1/admin", false},
+		{"unsupported scheme", FetchPolicy{}, "file:///etc/passwd", true},
+		{"no host", FetchPolicy{}, "https:///page", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.policy.checkResolvedUrl(context.Background(), test.url)
+			if blocked := errors.Is(err, ErrBlockedUrl); blocked != test.blocked {
+				t.Errorf("checkResolvedUrl(%s) = %v, want blocked %v", test.url, err, test.blocked)
+			}
+		})
+	}
+}
+
+func TestFetchPolicyHttpClient(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		default:
+			io.WriteString(w, "page")
+		}
+	}))
+	defer target.Close()
+	host, _ := url.Parse(target.URL)
+
+	tests := []struct {
+		name    string
+		policy  FetchPolicy
+		path    string
+		blocked bool
+	}{
+		{"private networks allowed", FetchPolicy{MaxRedirects: 2}, "/", false},
+		{"private network blocked", FetchPolicy{MaxRedirects: 2, BlockPrivateNetworks: true}, "/", true},
+		{"trusted host", FetchPolicy{MaxRedirects: 2, BlockPrivateNetworks: true, TrustedHosts: []string{host.Hostname()}}, "/", false},
+		{"too many redirects", FetchPolicy{MaxRedirects: 2}, "/loop", true},
+		{"redirect to another scheme", FetchPolicy{MaxRedirects: 2}, "/file", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := test.policy.httpClient(nil).Get(target.URL + test.path)
+			if err == nil {
+				res.Body.Close()
+			}
+			if blocked := errors.Is(err, ErrBlockedUrl); blocked != test.blocked {
+				t.Errorf("fetching %s = %v, want blocked %v", test.path, err, test.blocked)
+			}
+		})
+	}
+}
+
+func TestRenderWithService(t *testing.T) {
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://10.0.0.1/", http.StatusFound)
+			return
+		}
+		io.WriteString(w, "<html>rendered</html>")
+	}))
+	defer service.Close()
+	scraper := &Scraper{Policy: FetchPolicy{MaxRedirects: 2, BlockPrivateNetworks: true}}
+
+	tests := []struct {
+		name    string
+		backend string
+		url     string
+		blocked bool
+	}{
+		// The service is trusted on the private network
+		{"public page", service.URL, "https://93.184.216.34/page", false},
+		{"private page", service.URL, "http://10.0.0.1/admin", true},
+		{"redirect of the service", service.URL + "/redirect", "https://93.184.216.34/page", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scraper.Renderer = Renderer{Mode: RENDER_ALWAYS, Backend: test.backend}
+			page, err := scraper.renderWithService(context.Background(), test.url)
+			if blocked := errors.Is(err, ErrBlockedUrl); blocked != test.blocked {
+				t.Fatalf("renderWithService = %v, want blocked %v", err, test.blocked)
+			}
+			if !test.blocked && page != "<html>rendered</html>" {
+				t.Errorf("renderWithService = %q, want the rendered page", page)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package scrape
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// FetchSiteIcon downloads the icon of a site: the one its home page links to,
+// else its /favicon.ico.
+func (s *Scraper) FetchSiteIcon(ctx context.Context, host string) ([]byte, string, error) {
+	home := "https://" + host + "/"
+	candidates := []string{}
+	if page, err := s.fetchPage(ctx, home, s.Credentials.Identify); err == nil {
+		candidates = append(candidates, pageIconUrls(home, page)...)
+	} else {
+		slog.Debug("Home page not fetched", "url", home, "err", err)
+	}
+	candidates = append(candidates, home+"favicon.ico")
+
+	var lastErr error
+	for _, candidate := range candidates {
+		data, extension, err := s.DownloadImage(ctx, candidate, "icon download")
+		if err == nil {
+			return data, extension, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, "", lastErr
+}
+
+// pageIconUrls returns the absolute urls of the icons a page links to, the
+// icons first, then the bigger touch icons of the mobile platforms.
+func pageIconUrls(pageUrl, page string) []string {
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		return nil
+	}
+	base, err := url.Parse(pageUrl)
+	if err != nil {
+		return nil
+	}
+
+	var icons, touchIcons []string
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "body" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "link" {
+			href := strings.TrimSpace(htmlAttribute(n, "href"))
+			resolved, err := base.Parse(href)
+			if href != "" && err == nil && (resolved.Scheme == "http" || resolved.Scheme == "https") {
+				for _, rel := range strings.Fields(strings.ToLower(htmlAttribute(n, "rel"))) {
+					if rel == "icon" {
+						icons = append(icons, resolved.String())
+						break
+					}
+					if rel == "apple-touch-icon" {
+						touchIcons = append(touchIcons, resolved.String())
+						break
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+	traverse(doc)
+	return append(icons, touchIcons...)
+}
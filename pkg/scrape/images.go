@@ -0,0 +1,42 @@
+package scrape
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ArticleImage is an image of the body of an article.
+type ArticleImage struct {
+	Url string
+	Alt string
+	// Path of the downloaded copy, relative to the output folder, empty if
+	// not downloaded (see -download-images)
+	Path string
+}
+
+// articleImages returns the images of the body of a page, in order, their
+// urls resolved against the url of the page.
+func articleImages(pageUrl, page string) []ArticleImage {
+	base, err := url.Parse(pageUrl)
+	if err != nil {
+		return nil
+	}
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		return nil
+	}
+
+	var images []ArticleImage
+	seen := map[string]bool{}
+	for _, image := range contentImages(doc) {
+		imageUrl, err := base.Parse(imageSource(image))
+		if err != nil || (imageUrl.Scheme != "http" && imageUrl.Scheme != "https") || seen[imageUrl.String()] {
+			continue
+		}
+		seen[imageUrl.String()] = true
+		images = append(images, ArticleImage{Url: imageUrl.String(), Alt: strings.TrimSpace(htmlAttribute(image, "alt"))})
+	}
+	return images
+}
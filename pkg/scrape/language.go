@@ -0,0 +1,124 @@
+package scrape
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// Number of words of the article read to detect its language
+	LANGUAGE_DETECTION_WORDS = 2000
+	// Minimum number of common words found to trust the detection
+	LANGUAGE_DETECTION_MIN_HITS = 5
+)
+
+// Languages known by -summary-lang, by ISO 639-1 code.
+var LanguageNames = map[string]string{
+	"ar": "Arabic",
+	"cs": "Czech",
+	"da": "Danish",
+	"de": "German",
+	"el": "Greek",
+	"en": "English",
+	"es": "Spanish",
+	"fi": "Finnish",
+	"fr": "French",
+	"he": "Hebrew",
+	"hi": "Hindi",
+	"hu": "Hungarian",
+	"id": "Indonesian",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nl": "Dutch",
+	"no": "Norwegian",
+	"pl": "Polish",
+	"pt": "Portuguese",
+	"ro": "Romanian",
+	"ru": "Russian",
+	"sv": "Swedish",
+	"tr": "Turkish",
+	"uk": "Ukrainian",
+	"vi": "Vietnamese",
+	"zh": "Chinese",
+}
+
+// Most common words of the languages detected from the text of the articles,
+// the others relying on the language declared by the page.
+var languageCommonWords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "that", "with", "for", "this", "are", "was", "it", "on", "be", "as", "have", "not", "you", "which", "from"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "que", "pour", "dans", "qui", "pas", "sur", "avec", "du", "ce", "sont", "mais", "au", "nous"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "den", "ein", "eine", "zu", "sich", "auf", "für", "von", "dem", "auch", "es", "wird", "werden"},
+	"es": {"el", "los", "las", "que", "y", "es", "una", "por", "para", "con", "del", "se", "como", "pero", "más", "su", "al", "está", "son", "lo"},
+	"it": {"il", "che", "di", "e", "la", "per", "una", "sono", "non", "con", "del", "della", "gli", "anche", "come", "più", "questo", "nel", "è", "ma"},
+	"pt": {"o", "os", "que", "e", "de", "não", "uma", "para", "com", "do", "da", "em", "é", "mais", "como", "por", "são", "se", "mas", "ao"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "met", "op", "voor", "zijn", "die", "ook", "te", "maar", "als", "er", "aan", "wordt"},
+}
+
+// detectLanguage returns the ISO 639-1 code of the language of an article,
+// guessed from the common words of its text, else the language declared by
+// the page (e.g. fr-FR), empty if unknown.
+func detectLanguage(declared, content string) string {
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) > LANGUAGE_DETECTION_WORDS {
+		words = words[:LANGUAGE_DETECTION_WORDS]
+	}
+	counts := map[string]int{}
+	for _, word := range words {
+		counts[word]++
+	}
+
+	type score struct {
+		language string
+		hits     int
+	}
+	var scores []score
+	for language, commonWords := range languageCommonWords {
+		hits := 0
+		for _, word := range commonWords {
+			hits += counts[word]
+		}
+		scores = append(scores, score{language, hits})
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].hits != scores[j].hits {
+			return scores[i].hits > scores[j].hits
+		}
+		return scores[i].language < scores[j].language
+	})
+
+	// Close languages share common words, the best one must stand out
+	best, second := scores[0], scores[1]
+	if best.hits >= LANGUAGE_DETECTION_MIN_HITS && float64(best.hits) >= 1.2*float64(second.hits) {
+		return best.language
+	}
+	return NormalizeLanguage(declared)
+}
+
+// NormalizeLanguage returns the ISO 639-1 code of a language given as a
+// code, a locale (fr-FR, fr_FR) or an English name, empty if not recognized.
+func NormalizeLanguage(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	code, _, _ := strings.Cut(strings.ReplaceAll(value, "_", "-"), "-")
+	if _, ok := LanguageNames[code]; ok {
+		return code
+	}
+	for code, name := range LanguageNames {
+		if strings.EqualFold(name, value) {
+			return code
+		}
+	}
+	return ""
+}
+
+// LanguageName returns the English name of a language code, the code itself
+// if unknown.
+func LanguageName(code string) string {
+	if name, ok := LanguageNames[code]; ok {
+		return name
+	}
+	return code
+}
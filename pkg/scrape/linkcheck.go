@@ -0,0 +1,104 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+)
+
+// Statuses of the pages that are gone for good. The pages refused without an
+// account, or failing for a transient reason, are not dead.
+var deadLinkStatusCodes = []int{
+	http.StatusNotFound,
+	http.StatusGone,
+	http.StatusUnavailableForLegalReasons,
+}
+
+// IsDeadLink tells if the page of an url is gone: its host does not exist
+// anymore, or it answers with one of deadLinkStatusCodes. It fails when the
+// page could not be checked, e.g. when the server is down.
+func (s *Scraper) IsDeadLink(ctx context.Context, limiter *HostLimiter, link string) (bool, error) {
+	err := s.Retry.Do(ctx, "checking "+link, func() error {
+		release, err := limiter.Acquire(ctx, link)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		if s.requestLink(ctx, "HEAD", link) == nil {
+			return nil
+		}
+		// Some servers refuse or mishandle the HEAD requests
+		return s.requestLink(ctx, "GET", link)
+	})
+	if err == nil {
+		return false, nil
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return true, nil
+	}
+	var fetchErr *FetchError
+	if errors.As(err, &fetchErr) {
+		for _, statusCode := range deadLinkStatusCodes {
+			if fetchErr.StatusCode == statusCode {
+				return true, nil
+			}
+		}
+	}
+	return false, err
+}
+
+// requestLink requests an url, failing unless it answers successfully,
+// redirects being followed. The body is not read.
+func (s *Scraper) requestLink(ctx context.Context, method, link string) error {
+	ctx, cancel := WithTimeout(ctx, s.Policy.Timeout, "link check")
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, link, nil)
+	if err != nil {
+		return &FetchError{Url: link, Err: err}
+	}
+	if err := s.Policy.checkUrl(req.URL); err != nil {
+		return &FetchError{Url: link, Err: err}
+	}
+	s.Credentials.authorize(req)
+
+	res, err := s.httpClient().Do(req)
+	if err != nil {
+		return &FetchError{Url: link, Err: TimeoutCause(ctx, err)}
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return &FetchError{
+			Url:        link,
+			FinalUrl:   res.Request.URL.String(),
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			RetryAfter: ParseRetryAfter(res.Header.Get("Retry-After")),
+			Err:        fmt.Errorf("unexpected response status"),
+		}
+	}
+	return nil
+}
+
+// LookupSnapshot returns the url of the latest Wayback Machine snapshot of a
+// page, empty if it has none.
+func (s *Scraper) LookupSnapshot(ctx context.Context, link string) string {
+	var snapshot WaybackSnapshot
+	err := s.Retry.Do(ctx, "looking up "+link+" in the Wayback Machine", func() error {
+		var err error
+		snapshot, err = s.findWaybackSnapshot(ctx, link)
+		return err
+	})
+	if err != nil {
+		slog.Warn("no Wayback Machine snapshot of the dead link", "url", link, "err", err)
+		return ""
+	}
+	return snapshot.Url
+}
@@ -0,0 +1,91 @@
+package scrape
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// STDIN_INPUT is the argument reading the page from the standard input
+// instead of fetching an url.
+const STDIN_INPUT = "-"
+
+// Extensions of the saved pages, including the MHTML archives of Chrome, Edge
+// and Internet Explorer
+var localPageExtensions = []string{".html", ".htm", ".xhtml", ".mhtml", ".mht"}
+
+// IsLocalInput tells if an article argument is a saved page or the standard
+// input rather than an url.
+func IsLocalInput(input string) bool {
+	return input == STDIN_INPUT || !strings.Contains(input, "://")
+}
+
+// LooksLikeArticleInput tells apart the articles from the output folder in
+// the arguments of the add command.
+func LooksLikeArticleInput(arg string) bool {
+	if strings.Contains(arg, "://") || arg == STDIN_INPUT {
+		return true
+	}
+	for _, extension := range localPageExtensions {
+		if strings.EqualFold(filepath.Ext(arg), extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScrapeLocalArticle reads an article from a saved page, an MHTML archive or
+// a page saved by SingleFile, or from the standard input, and extracts it like
+// a fetched page.
+func (s *Scraper) ScrapeLocalArticle(input string) (Article, error) {
+	var data []byte
+	var err error
+	if input == STDIN_INPUT {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(input)
+	}
+	if err != nil {
+		return Article{}, fmt.Errorf("reading page '%s': %w", input, err)
+	}
+	page, err := ReadSavedPage(data)
+	if err != nil {
+		return Article{}, fmt.Errorf("reading page '%s': %w", input, err)
+	}
+	slog.Debug("Saved page read", "input", input, "saved_url", page.SavedUrl, "bytes", len(page.Html))
+
+	return s.extractArticle(localArticleUrl(input, page), page.Html)
+}
+
+// localArticleUrl returns the url of a saved page, as given by its canonical
+// link, else the url the archive was saved from, else the file url of the
+// page, or nothing for the standard input.
+func localArticleUrl(input string, page SavedPage) string {
+	for _, pageUrl := range []string{scrapeArticleMetadata(page.Html).Url, page.SavedUrl} {
+		if strings.HasPrefix(pageUrl, "http://") || strings.HasPrefix(pageUrl, "https://") {
+			return pageUrl
+		}
+	}
+	if input == STDIN_INPUT {
+		return ""
+	}
+	return FileUrl(input)
+}
+
+// FileUrl returns the file url of a path, made absolute.
+func FileUrl(input string) string {
+	path, err := filepath.Abs(input)
+	if err != nil {
+		path = input
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows drive letter
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
@@ -0,0 +1,296 @@
+package scrape
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Blank lines between blocks, a block being surrounded by two newlines
+var markdownBlankLinesRegex = regexp.MustCompile(`\n[ \t]*\n(?:[ \t]*\n)+`)
+
+// Language of a code block, from the class of its code element, e.g.
+// language-go or lang-go
+var codeLanguageRegex = regexp.MustCompile(`(?:^|\s)(?:language|lang)-([\w+#-]+)`)
+
+// htmlToMarkdown converts the body of a page to markdown, keeping its
+// headings, lists, links, emphasis, code blocks, blockquotes and tables. Only
+// the article element, else the main element, is converted when the page has
+// one, and the navigation, the forms and the scripts are left out. The
+// headings are one level below the heading of the report holding the text.
+func htmlToMarkdown(pageUrl, page string) string {
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		return ""
+	}
+	root := findElement(doc, "article")
+	if root == nil {
+		root = findElement(doc, "main")
+	}
+	if root == nil {
+		root = findElement(doc, "body")
+	}
+	if root == nil {
+		return ""
+	}
+
+	base, _ := url.Parse(pageUrl)
+	converter := markdownConverter{base: base}
+	markdown := converter.children(root)
+	return strings.TrimSpace(markdownBlankLinesRegex.ReplaceAllString(markdown, "\n\n"))
+}
+
+// markdownConverter converts HTML nodes to markdown, the links and the images
+// being resolved against the url of the page.
+type markdownConverter struct {
+	base *url.URL
+}
+
+func (c markdownConverter) children(n *html.Node) string {
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(c.node(child))
+	}
+	return sb.String()
+}
+
+func (c markdownConverter) node(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return collapseWhitespace(n.Data)
+	}
+	if n.Type != html.ElementNode {
+		return ""
+	}
+
+	switch n.Data {
+	case "script", "style", "noscript", "template", "nav", "header", "footer", "aside", "form", "button", "svg", "iframe", "select":
+		return ""
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := strings.TrimSpace(c.children(n))
+		if text == "" {
+			return ""
+		}
+		level := min(int(n.Data[1]-'0')+1, 6)
+		return block(strings.Repeat("#", level) + " " + strings.ReplaceAll(text, "\n", " "))
+	case "p", "div", "section", "article", "main", "figure", "figcaption", "dl", "dd", "dt", "details", "summary", "center":
+		return block(strings.TrimSpace(c.children(n)))
+	case "br":
+		return "\n"
+	case "hr":
+		return block("---")
+	case "ul", "ol":
+		return block(c.list(n))
+	case "pre":
+		return block(codeBlock(n))
+	case "blockquote":
+		text := strings.TrimSpace(markdownBlankLinesRegex.ReplaceAllString(c.children(n), "\n\n"))
+		if text == "" {
+			return ""
+		}
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return block(strings.Join(lines, "\n"))
+	case "table":
+		// Layout tables are read as the blocks they hold
+		if !hasDescendant(n, "table") {
+			if table, ok := readTable(n); ok {
+				if caption := tableCaption(n); caption != "" {
+					return block("**"+caption+"**") + block(table.Markdown())
+				}
+				return block(table.Markdown())
+			}
+		}
+		return block(strings.TrimSpace(c.children(n)))
+	case "tr", "li":
+		return block(strings.TrimSpace(c.children(n)))
+	case "td", "th":
+		return c.children(n) + " "
+	case "code", "kbd", "samp":
+		return inlineCode(nodeRawText(n))
+	case "strong", "b":
+		return wrapInline(c.children(n), "**", "**")
+	case "em", "i":
+		return wrapInline(c.children(n), "*", "*")
+	case "del", "s", "strike":
+		return wrapInline(c.children(n), "~~", "~~")
+	case "a":
+		text := c.children(n)
+		href := c.resolve(htmlAttribute(n, "href"))
+		if href == "" || strings.TrimSpace(text) == "" {
+			return text
+		}
+		return wrapInline(text, "[", "]("+href+")")
+	case "img":
+		src := c.resolve(imageSource(n))
+		if src == "" {
+			return ""
+		}
+		return fmt.Sprintf("![%s](%s)", collapseWhitespace(strings.TrimSpace(htmlAttribute(n, "alt"))), src)
+	}
+	return c.children(n)
+}
+
+// list converts a list, each item being indented under its marker.
+func (c markdownConverter) list(n *html.Node) string {
+	var items []string
+	number := 1
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.Data != "li" {
+			continue
+		}
+		marker := "- "
+		if n.Data == "ol" {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		// The blocks of an item, such as a nested list, are not separated by
+		// blank lines, which would make the list loose
+		var lines []string
+		for _, line := range strings.Split(strings.TrimSpace(c.children(child)), "\n") {
+			if strings.TrimSpace(line) != "" {
+				lines = append(lines, line)
+			}
+		}
+		// The lines after the first one are part of the item when they are
+		// indented as its text
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+strings.Join(lines, "\n"+indent))
+	}
+	return strings.Join(items, "\n")
+}
+
+// resolve returns the absolute url of a link or an image, empty for the urls
+// that are not http(s) urls, such as javascript: links.
+func (c markdownConverter) resolve(link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" || c.base == nil || strings.HasPrefix(link, "#") {
+		return ""
+	}
+	resolved, err := c.base.Parse(link)
+	if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+		return ""
+	}
+	return MarkdownLinkUrl(resolved.String())
+}
+
+// MarkdownLinkUrl returns an url as the destination of a markdown link, its
+// spaces and parentheses, which would end the link, being escaped.
+func MarkdownLinkUrl(link string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(link)
+}
+
+// block returns the markdown of a block, between blank lines.
+func block(markdown string) string {
+	if markdown == "" {
+		return ""
+	}
+	return "\n\n" + markdown + "\n\n"
+}
+
+// wrapInline wraps inline markdown between markers, e.g. ** for bold text,
+// the spaces around it being kept outside of the markers.
+func wrapInline(markdown, opening, closing string) string {
+	text := strings.TrimSpace(markdown)
+	if text == "" {
+		return markdown
+	}
+	leading := markdown[:strings.Index(markdown, text)]
+	trailing := markdown[len(leading)+len(text):]
+	return leading + opening + text + closing + trailing
+}
+
+// codeBlock returns a fenced code block of a pre element, its language being
+// read from the class of its code element.
+func codeBlock(pre *html.Node) string {
+	code := strings.Trim(nodeRawText(pre), "\n")
+	if code == "" {
+		return ""
+	}
+	language := ""
+	for _, n := range []*html.Node{pre, findElement(pre, "code")} {
+		if n == nil {
+			continue
+		}
+		if match := codeLanguageRegex.FindStringSubmatch(htmlAttribute(n, "class")); match != nil {
+			language = match[1]
+			break
+		}
+	}
+	// The fence is longer than the backticks of the code
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+// inlineCode returns inline code, between more backticks than it holds.
+func inlineCode(code string) string {
+	code = strings.ReplaceAll(code, "\n", " ")
+	if strings.TrimSpace(code) == "" {
+		return code
+	}
+	fence := "`"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		code = " " + code + " "
+	}
+	return fence + code + fence
+}
+
+// nodeRawText returns the text of a node as is, its whitespace included.
+func nodeRawText(n *html.Node) string {
+	var sb strings.Builder
+	var traverse func(*html.Node)
+	traverse = func(c *html.Node) {
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
+		} else if c.Type == html.ElementNode && c.Data == "br" {
+			sb.WriteString("\n")
+		}
+		for child := c.FirstChild; child != nil; child = child.NextSibling {
+			traverse(child)
+		}
+	}
+	traverse(n)
+	return sb.String()
+}
+
+// collapseWhitespace replaces the runs of whitespace of a text by a space.
+func collapseWhitespace(text string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range text {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			if !space {
+				sb.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		sb.WriteRune(r)
+		space = false
+	}
+	return sb.String()
+}
+
+// findElement returns the first element of a tag of a node, in document
+// order, the node included.
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package scrape
+
+import (
+	"cmp"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// ArticleMetadata is the metadata publishers give about a page, in
+// OpenGraph, Twitter card and classic meta tags or in JSON-LD structured data.
+// Fields that are not found are left empty.
+type ArticleMetadata struct {
+	Title     string
+	Authors   []string
+	SiteName  string
+	Published time.Time
+	// Canonical url of the page, from its canonical link or og:url, to know
+	// the url of a page read from a file.
+	Url string
+	// Url of the image representing the page in previews, as given by the
+	// page, possibly relative.
+	Image string
+	// Language of the page, as given by the page, e.g. fr-FR (see
+	// detectLanguage)
+	Language string
+}
+
+// Meta tags holding each field, by order of preference. Tags are keyed by
+// their property, name or itemprop attribute.
+var (
+	metaTitleNames     = []string{"og:title", "twitter:title", "citation_title", "dc.title"}
+	metaAuthorNames    = []string{"author", "article:author", "citation_author", "dc.creator", "parsely-author", "sailthru.author"}
+	metaSiteNames      = []string{"og:site_name", "application-name", "citation_publisher", "dc.publisher"}
+	metaImageNames     = []string{"og:image", "og:image:url", "og:image:secure_url", "twitter:image", "twitter:image:src", "image", "thumbnailurl"}
+	metaLanguageNames  = []string{"og:locale", "content-language", "dc.language", "language", "inlanguage"}
+	metaPublishedNames = []string{"article:published_time", "og:published_time", "datepublished", "citation_publication_date", "dc.date", "dc.date.issued", "date", "pubdate", "publish-date", "parsely-pub-date", "sailthru.date"}
+)
+
+// JSON-LD types describing the article itself rather than the site or a part
+// of the page.
+var jsonLdArticleTypes = map[string]bool{
+	"Article": true, "NewsArticle": true, "BlogPosting": true, "TechArticle": true,
+	"ScholarlyArticle": true, "Report": true, "AnalysisNewsArticle": true, "OpinionNewsArticle": true,
+	"ReportageNewsArticle": true, "ReviewNewsArticle": true, "SocialMediaPosting": true, "VideoObject": true,
+}
+
+var metadataDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006/01/02",
+	"20060102",
+	time.RFC1123Z,
+	time.RFC1123,
+	"January 2, 2006",
+	"2 January 2006",
+}
+
+func scrapeArticleMetadata(pageContent string) ArticleMetadata {
+	doc, err := html.Parse(strings.NewReader(pageContent))
+	if err != nil {
+		return ArticleMetadata{}
+	}
+
+	metas := map[string]string{}
+	var canonicalLink, htmlLang string
+	var jsonLd ArticleMetadata
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "html":
+				htmlLang = strings.TrimSpace(htmlAttribute(n, "lang"))
+			case "meta":
+				key := strings.ToLower(htmlAttribute(n, "property"))
+				if key == "" {
+					key = strings.ToLower(htmlAttribute(n, "name"))
+				}
+				if key == "" {
+					key = strings.ToLower(htmlAttribute(n, "itemprop"))
+				}
+				content := strings.TrimSpace(htmlAttribute(n, "content"))
+				// The first occurrence wins, like for browsers and crawlers
+				if _, found := metas[key]; key != "" && content != "" && !found {
+					metas[key] = content
+				}
+			case "link":
+				if strings.EqualFold(htmlAttribute(n, "rel"), "canonical") && canonicalLink == "" {
+					canonicalLink = strings.TrimSpace(htmlAttribute(n, "href"))
+				}
+			case "script":
+				if strings.EqualFold(htmlAttribute(n, "type"), "application/ld+json") && n.FirstChild != nil {
+					jsonLd = mergeMetadata(jsonLd, parseJsonLdMetadata(n.FirstChild.Data))
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+	traverse(doc)
+
+	meta := ArticleMetadata{
+		Title:    firstMeta(metas, metaTitleNames, nil),
+		SiteName: firstMeta(metas, metaSiteNames, nil),
+		Url:      canonicalLink,
+		Image:    firstMeta(metas, metaImageNames, nil),
+		Language: cmp.Or(firstMeta(metas, metaLanguageNames, nil), htmlLang),
+	}
+	if meta.Url == "" {
+		meta.Url = metas["og:url"]
+	}
+	if author := firstMeta(metas, metaAuthorNames, isAuthorName); author != "" {
+		meta.Authors = []string{author}
+	}
+	if published := firstMeta(metas, metaPublishedNames, func(value string) bool { return !parseMetadataDate(value).IsZero() }); published != "" {
+		meta.Published = parseMetadataDate(published)
+	}
+
+	// Structured data is more reliable than meta tags, which are often filled
+	// with the values of the site for every page
+	return mergeMetadata(jsonLd, meta)
+}
+
+func htmlAttribute(n *html.Node, name string) string {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, name) {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func firstMeta(metas map[string]string, names []string, valid func(string) bool) string {
+	for _, name := range names {
+		if value, found := metas[name]; found && (valid == nil || valid(value)) {
+			return value
+		}
+	}
+	return ""
+}
+
+// isAuthorName filters out the author tags holding the url of a profile
+// page instead of a name.
+func isAuthorName(value string) bool {
+	return !strings.Contains(value, "://") && !strings.HasPrefix(value, "/")
+}
+
+// mergeMetadata fills the empty fields of primary with those of fallback.
+func mergeMetadata(primary, fallback ArticleMetadata) ArticleMetadata {
+	if primary.Title == "" {
+		primary.Title = fallback.Title
+	}
+	if len(primary.Authors) == 0 {
+		primary.Authors = fallback.Authors
+	}
+	if primary.SiteName == "" {
+		primary.SiteName = fallback.SiteName
+	}
+	if primary.Url == "" {
+		primary.Url = fallback.Url
+	}
+	if primary.Image == "" {
+		primary.Image = fallback.Image
+	}
+	if primary.Published.IsZero() {
+		primary.Published = fallback.Published
+	}
+	if primary.Language == "" {
+		primary.Language = fallback.Language
+	}
+	return primary
+}
+
+func parseMetadataDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range metadataDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date
+		}
+	}
+	return time.Time{}
+}
+
+// parseJsonLdMetadata reads the metadata of the first article described by a
+// JSON-LD script, which holds a single object, a list of objects or a graph.
+func parseJsonLdMetadata(script string) ArticleMetadata {
+	var data any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(script)), &data); err != nil {
+		return ArticleMetadata{}
+	}
+
+	var meta ArticleMetadata
+	var visit func(any)
+	visit = func(value any) {
+		switch value := value.(type) {
+		case []any:
+			for _, item := range value {
+				visit(item)
+			}
+		case map[string]any:
+			if graph, found := value["@graph"]; found {
+				visit(graph)
+			}
+			if !isJsonLdArticle(value["@type"]) {
+				return
+			}
+			meta = mergeMetadata(meta, ArticleMetadata{
+				Title:     jsonLdString(value["headline"]),
+				Authors:   jsonLdNames(value["author"]),
+				SiteName:  strings.Join(jsonLdNames(value["publisher"]), ", "),
+				Published: parseMetadataDate(jsonLdString(value["datePublished"])),
+				Image:     jsonLdImage(value["image"]),
+				Language:  jsonLdString(value["inLanguage"]),
+			})
+		}
+	}
+	visit(data)
+
+	return meta
+}
+
+func isJsonLdArticle(value any) bool {
+	switch value := value.(type) {
+	case string:
+		return jsonLdArticleTypes[value]
+	case []any:
+		for _, item := range value {
+			if isJsonLdArticle(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonLdString(value any) string {
+	if value, ok := value.(string); ok {
+		return strings.TrimSpace(value)
+	}
+	return ""
+}
+
+// jsonLdImage returns the url of an image value, which is an url, an
+// ImageObject or a list of them.
+func jsonLdImage(value any) string {
+	switch value := value.(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case map[string]any:
+		if imageUrl := jsonLdString(value["url"]); imageUrl != "" {
+			return imageUrl
+		}
+		return jsonLdString(value["contentUrl"])
+	case []any:
+		for _, item := range value {
+			if imageUrl := jsonLdImage(item); imageUrl != "" {
+				return imageUrl
+			}
+		}
+	}
+	return ""
+}
+
+// jsonLdNames returns the names of a person or organization value, which is
+// a name, an object with a name or a list of them.
+func jsonLdNames(value any) []string {
+	switch value := value.(type) {
+	case string:
+		if name := strings.TrimSpace(value); name != "" && isAuthorName(name) {
+			return []string{name}
+		}
+	case map[string]any:
+		if name := jsonLdString(value["name"]); name != "" {
+			return []string{name}
+		}
+	case []any:
+		var names []string
+		for _, item := range value {
+			names = append(names, jsonLdNames(item)...)
+		}
+		return names
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package scrape
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// SingleFile saves a page as one HTML file, its resources inlined as data
+// urls, starting with a comment giving the url of the page.
+var singleFileUrlRegex = regexp.MustCompile(`<!--\s*Page saved with SingleFile\s+url:\s*(\S+)`)
+
+// Bytes 0x80 to 0x9f of windows-1252, the other bytes being those of
+// iso-8859-1, i.e. the Unicode code points of the same value.
+var windows1252Runes = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '\u008d', 'Ž', '\u008f',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '\u009d', 'ž', 'Ÿ',
+}
+
+// SavedPage is the HTML of a page read from a saved file, with the url it was
+// saved from when the archive records it.
+type SavedPage struct {
+	Html     string
+	SavedUrl string
+}
+
+// ReadSavedPage returns the page held by a saved file: an MHTML archive, a
+// page saved by SingleFile or a plain HTML page.
+func ReadSavedPage(data []byte) (SavedPage, error) {
+	if isMhtml(data) {
+		return parseMhtml(data)
+	}
+	page := SavedPage{Html: string(data)}
+	// The comment comes right after the doctype and the html tag
+	if match := singleFileUrlRegex.FindStringSubmatch(string(data[:min(len(data), 4096)])); match != nil {
+		page.SavedUrl = match[1]
+	}
+	return page, nil
+}
+
+// isMhtml tells if a file is an MHTML archive, which holds a page and its
+// resources in a MIME message of type multipart/related, whatever its
+// extension.
+func isMhtml(data []byte) bool {
+	message, err := mail.ReadMessage(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/related"
+}
+
+// parseMhtml returns the main page of an MHTML archive, decoded, its cid:
+// references to the other parts of the archive replaced by the urls of these
+// resources.
+func parseMhtml(data []byte) (SavedPage, error) {
+	message, err := mail.ReadMessage(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return SavedPage{}, fmt.Errorf("reading MHTML archive: %w", err)
+	}
+	_, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil {
+		return SavedPage{}, fmt.Errorf("reading MHTML archive: %w", err)
+	}
+	if params["boundary"] == "" {
+		return SavedPage{}, fmt.Errorf("reading MHTML archive: no MIME boundary")
+	}
+	// The main page is the part given by the start parameter, else the first
+	// HTML part
+	start := strings.Trim(params["start"], "<>")
+
+	var page SavedPage
+	var pageFound bool
+	resourceUrls := map[string]string{}
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return SavedPage{}, fmt.Errorf("reading MHTML archive: %w", err)
+		}
+		contentId := strings.Trim(part.Header.Get("Content-ID"), "<>")
+		location := part.Header.Get("Content-Location")
+		if contentId != "" && location != "" {
+			resourceUrls["cid:"+contentId] = location
+		}
+
+		mediaType, partParams, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		isPage := (mediaType == "text/html" || mediaType == "application/xhtml+xml") && !pageFound && (start == "" || start == contentId)
+		if !isPage {
+			continue
+		}
+		// The quoted-printable parts are decoded by the multipart reader
+		var body io.Reader = part
+		if strings.EqualFold(strings.TrimSpace(part.Header.Get("Content-Transfer-Encoding")), "base64") {
+			body = base64.NewDecoder(base64.StdEncoding, part)
+		}
+		html, err := io.ReadAll(body)
+		if err != nil {
+			return SavedPage{}, fmt.Errorf("decoding the page of the MHTML archive: %w", err)
+		}
+		page = SavedPage{Html: decodeCharset(html, partParams["charset"]), SavedUrl: location}
+		pageFound = true
+	}
+	if !pageFound {
+		return SavedPage{}, fmt.Errorf("no HTML page in the MHTML archive")
+	}
+
+	if savedUrl := message.Header.Get("Snapshot-Content-Location"); savedUrl != "" {
+		page.SavedUrl = savedUrl
+	}
+	for cid, location := range resourceUrls {
+		page.Html = strings.ReplaceAll(page.Html, cid, location)
+	}
+	return page, nil
+}
+
+// decodeCharset converts text of a charset to UTF-8. Besides UTF-8, only
+// iso-8859-1 and windows-1252 are known, read as windows-1252 like the
+// browsers do, the text of other charsets being kept as is.
+func decodeCharset(data []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+			if b >= 0x80 && b < 0xa0 {
+				runes[i] = windows1252Runes[b-0x80]
+			}
+		}
+		return string(runes)
+	}
+	if !utf8.Valid(data) {
+		return strings.ToValidUTF8(string(data), "�")
+	}
+	return string(data)
+}
@@ -0,0 +1,170 @@
+package scrape
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// mhtmlPart is a part of the MHTML archives of the tests, its body encoded
+// with its transfer encoding.
+type mhtmlPart struct {
+	ContentType string
+	Encoding    string
+	Location    string
+	Id          string
+	Body        string
+}
+
+// writeMhtml writes an MHTML archive as browsers save pages, with the
+// parameters of its multipart/related content type.
+func writeMhtml(t *testing.T, snapshotUrl, params string, parts []mhtmlPart) []byte {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.ContentType)
+		if part.Encoding != "" {
+			header.Set("Content-Transfer-Encoding", part.Encoding)
+		}
+		if part.Location != "" {
+			header.Set("Content-Location", part.Location)
+		}
+		if part.Id != "" {
+			header.Set("Content-ID", "<"+part.Id+">")
+		}
+		partWriter, err := writer.CreatePart(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch part.Encoding {
+		case "base64":
+			_, err = partWriter.Write([]byte(base64.StdEncoding.EncodeToString([]byte(part.Body))))
+		case "quoted-printable":
+			qp := quotedprintable.NewWriter(partWriter)
+			if _, err = qp.Write([]byte(part.Body)); err == nil {
+				err = qp.Close()
+			}
+		default:
+			_, err = partWriter.Write([]byte(part.Body))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("From: <Saved by Blink>\r\n")
+	if snapshotUrl != "" {
+		fmt.Fprintf(&sb, "Snapshot-Content-Location: %s\r\n", snapshotUrl)
+	}
+	sb.WriteString("Subject: A page\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&sb, "Content-Type: multipart/related;\r\n\ttype=\"text/html\";\r\n\tboundary=\"%s\"%s\r\n", writer.Boundary(), params)
+	sb.WriteString("\r\n")
+	sb.Write(body.Bytes())
+	return []byte(sb.String())
+}
+
+func TestReadSavedPageMhtml(t *testing.T) {
+	page := `<html><body><p>Un café à Paris</p><img src="cid:image-1@mhtml.blink"></body></html>`
+	image := mhtmlPart{ContentType: "image/png", Encoding: "base64", Location: "https://example.com/photo.png", Id: "image-1@mhtml.blink", Body: "\x89PNG\r\n"}
+	tests := []struct {
+		name        string
+		snapshotUrl string
+		params      string
+		parts       []mhtmlPart
+		want        SavedPage
+	}{
+		{
+			name:        "quoted-printable page",
+			snapshotUrl: "https://example.com/article",
+			parts: []mhtmlPart{
+				{ContentType: "text/html; charset=utf-8", Encoding: "quoted-printable", Location: "https://example.com/article", Body: page},
+				image,
+			},
+			want: SavedPage{
+				Html:     `<html><body><p>Un café à Paris</p><img src="https://example.com/photo.png"></body></html>`,
+				SavedUrl: "https://example.com/article",
+			},
+		},
+		{
+			name: "base64 latin-1 page",
+			parts: []mhtmlPart{
+				{ContentType: "text/html; charset=iso-8859-1", Encoding: "base64", Location: "https://example.com/latin", Body: "<p>caf\xe9</p>"},
+			},
+			want: SavedPage{Html: "<p>café</p>", SavedUrl: "https://example.com/latin"},
+		},
+		{
+			name:   "start parameter",
+			params: ";\r\n\tstart=\"<main@example.com>\"",
+			parts: []mhtmlPart{
+				{ContentType: "text/html", Location: "https://example.com/frame", Body: "<p>Frame</p>"},
+				{ContentType: "text/html", Location: "https://example.com/main", Id: "main@example.com", Body: "<p>Main</p>"},
+			},
+			want: SavedPage{Html: "<p>Main</p>", SavedUrl: "https://example.com/main"},
+		},
+		{
+			name: "first html part",
+			parts: []mhtmlPart{
+				{ContentType: "text/css", Location: "https://example.com/style.css", Body: "p { color: red }"},
+				{ContentType: "text/html", Location: "https://example.com/first", Body: "<p>First</p>"},
+				{ContentType: "text/html", Location: "https://example.com/second", Body: "<p>Second</p>"},
+			},
+			want: SavedPage{Html: "<p>First</p>", SavedUrl: "https://example.com/first"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := writeMhtml(t, test.snapshotUrl, test.params, test.parts)
+			if !isMhtml(data) {
+				t.Fatalf("isMhtml = false, want true")
+			}
+			saved, err := ReadSavedPage(data)
+			if err != nil {
+				t.Fatalf("readSavedPage: %v", err)
+			}
+			if saved != test.want {
+				t.Errorf("readSavedPage = %+v, want %+v", saved, test.want)
+			}
+		})
+	}
+}
+
+func TestReadSavedPageHtml(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"plain page", "<!DOCTYPE html><html><body><p>Text</p></body></html>", ""},
+		{"singlefile page", "<!DOCTYPE html> <html><!--\n Page saved with SingleFile \n url: https://example.com/saved \n saved date: Thu May 02 2024\n--><body></body></html>", "https://example.com/saved"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			saved, err := ReadSavedPage([]byte(test.html))
+			if err != nil {
+				t.Fatalf("readSavedPage: %v", err)
+			}
+			if saved.Html != test.html || saved.SavedUrl != test.want {
+				t.Errorf("readSavedPage = %+v, want the page saved from '%s'", saved, test.want)
+			}
+		})
+	}
+}
+
+func TestParseMhtmlWithoutPage(t *testing.T) {
+	data := writeMhtml(t, "", "", []mhtmlPart{{ContentType: "image/png", Location: "https://example.com/a.png", Body: "png"}})
+	if page, err := parseMhtml(data); err == nil {
+		t.Errorf("parseMhtml = %+v, want an error", page)
+	}
+}
@@ -0,0 +1,274 @@
+package scrape
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// Read the text of the images of no page, of the pages made mostly of
+	// images, such as slides and infographics, or of every page
+	OCR_NEVER  = "never"
+	OCR_AUTO   = "auto"
+	OCR_ALWAYS = "always"
+	// With OCR_AUTO, pages with fewer words than this per image of their body
+	// have their images read.
+	OCR_AUTO_WORDS_PER_IMAGE = 100
+	// Images read per page, in the order of the page
+	MAX_OCR_IMAGES = 20
+	// Text read from an image shorter than this is noise, e.g. from a photo or
+	// a logo, rather than text.
+	MIN_OCR_TEXT_LENGTH = 20
+)
+
+// OCR engines looked up in the PATH when no backend is given.
+var ocrEngineNames = []string{"tesseract"}
+
+// OcrEngine reads the text of the images of the pages, with a local OCR
+// engine or an OCR service, so that the text of slides, screenshots and
+// infographics is summarized rather than their alt text.
+type OcrEngine struct {
+	Mode string
+	// Tesseract executable, or http(s) url of an OCR service answering a POST
+	// of an image with its text, as plain text or as {"text": "..."}.
+	Backend string
+	// Languages of the text, as given to the engine, e.g. eng+fra for
+	// Tesseract, its default if empty.
+	Language string
+}
+
+func (e OcrEngine) validate() error {
+	if e.Mode != OCR_NEVER && e.Mode != OCR_AUTO && e.Mode != OCR_ALWAYS {
+		return fmt.Errorf("unknown OCR mode '%s', expected %s, %s or %s", e.Mode, OCR_NEVER, OCR_AUTO, OCR_ALWAYS)
+	}
+	if e.Mode == OCR_NEVER || e.isService() {
+		return nil
+	}
+	_, err := e.engine()
+	return err
+}
+
+func (e OcrEngine) isService() bool {
+	return strings.HasPrefix(e.Backend, "http://") || strings.HasPrefix(e.Backend, "https://")
+}
+
+// engine returns the path of the OCR engine, or an error if there is none.
+func (e OcrEngine) engine() (string, error) {
+	if e.Backend != "" {
+		return exec.LookPath(e.Backend)
+	}
+	for _, name := range ocrEngineNames {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no OCR engine found in the PATH (%s), set -ocr-backend", strings.Join(ocrEngineNames, ", "))
+}
+
+// recognize returns the text of an image, in at most timeout if not 0.
+func (e OcrEngine) recognize(ctx context.Context, image FetchedPage, timeout time.Duration) (string, error) {
+	ctx, cancel := WithTimeout(ctx, timeout, "OCR")
+	defer cancel()
+
+	if e.isService() {
+		return e.recognizeWithService(ctx, image)
+	}
+	engine, err := e.engine()
+	if err != nil {
+		return "", err
+	}
+	// Tesseract reads the image from its standard input and writes the text
+	// to its standard output
+	args := []string{"stdin", "stdout"}
+	if e.Language != "" {
+		args = append(args, "-l", e.Language)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, engine, args...)
+	cmd.Stdin = bytes.NewReader(image.Body)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("reading the image with %s: %w: %s", engine, TimeoutCause(ctx, err), strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
+
+func (e OcrEngine) recognizeWithService(ctx context.Context, image FetchedPage) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", e.Backend, bytes.NewReader(image.Body))
+	if err != nil {
+		return "", &FetchError{Url: e.Backend, Err: err}
+	}
+	if e.Language != "" {
+		query := req.URL.Query()
+		query.Set("lang", e.Language)
+		req.URL.RawQuery = query.Encode()
+	}
+	req.Header.Set("Content-Type", image.MediaType)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", &FetchError{Url: e.Backend, Err: TimeoutCause(ctx, err)}
+	}
+	defer res.Body.Close()
+
+	fetchErr := FetchError{Url: e.Backend, StatusCode: res.StatusCode, Status: res.Status, RetryAfter: ParseRetryAfter(res.Header.Get("Retry-After"))}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		fetchErr.Err = fmt.Errorf("reading body: %w", TimeoutCause(ctx, err))
+		return "", &fetchErr
+	}
+	if res.StatusCode >= 400 {
+		fetchErr.Err = fmt.Errorf("unexpected response status")
+		return "", &fetchErr
+	}
+	var response struct {
+		Text string `json:"text"`
+	}
+	if strings.Contains(res.Header.Get("Content-Type"), "json") {
+		if err := json.Unmarshal(data, &response); err != nil {
+			return "", fmt.Errorf("unmarshaling OCR response: %w", err)
+		}
+		return response.Text, nil
+	}
+	return string(data), nil
+}
+
+// readImageText completes the article extracted from the HTML of a page with
+// the text of its images, read by the OCR engine, the page being extracted
+// again with the text of each image next to it. The article is returned as
+// is when no text is read.
+func (s *Scraper) readImageText(ctx context.Context, articleUrl, pageUrl, page string, article Article, err error) (Article, error) {
+	if s.Ocr.Mode == OCR_NEVER || err != nil {
+		return article, err
+	}
+	doc, parseErr := html.Parse(strings.NewReader(page))
+	if parseErr != nil {
+		return article, nil
+	}
+	images := contentImages(doc)
+	if len(images) == 0 {
+		return article, nil
+	}
+	if s.Ocr.Mode == OCR_AUTO && len(strings.Fields(article.Content)) >= OCR_AUTO_WORDS_PER_IMAGE*len(images) {
+		return article, nil
+	}
+	base, parseErr := url.Parse(pageUrl)
+	if parseErr != nil {
+		return article, nil
+	}
+
+	read := 0
+	for _, image := range images[:min(len(images), MAX_OCR_IMAGES)] {
+		imageUrl, parseErr := base.Parse(imageSource(image))
+		if parseErr != nil || (imageUrl.Scheme != "http" && imageUrl.Scheme != "https") {
+			continue
+		}
+		text, ocrErr := s.readImage(ctx, imageUrl.String())
+		if ocrErr != nil {
+			slog.Warn("could not read the text of the image", "url", imageUrl.String(), "err", ocrErr)
+			continue
+		}
+		text = strings.Join(strings.Fields(text), " ")
+		if len(text) < MIN_OCR_TEXT_LENGTH {
+			continue
+		}
+		setImageText(image, text)
+		read++
+	}
+	slog.Debug("Images read", "url", articleUrl, "images", len(images), "read", read)
+	if read == 0 {
+		return article, nil
+	}
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return article, nil
+	}
+	withText, err := s.extractArticle(articleUrl, buf.String())
+	if err != nil {
+		return article, nil
+	}
+	article.Content, article.Markdown = withText.Content, withText.Markdown
+	return article, nil
+}
+
+// readImage downloads an image and returns its text.
+func (s *Scraper) readImage(ctx context.Context, imageUrl string) (string, error) {
+	var image FetchedPage
+	err := s.Retry.Do(ctx, "fetching "+imageUrl, func() error {
+		var err error
+		image, err = s.FetchResponse(ctx, imageUrl, s.Credentials.Identify, func(mediaType string) bool {
+			// Vector images hold their text as text, not as pixels
+			return strings.HasPrefix(mediaType, "image/") && mediaType != "image/svg+xml"
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.Ocr.recognize(ctx, image, s.Policy.Timeout)
+}
+
+// contentImages returns the images of the body of a page, without those of
+// its navigation, the icons and the tracking pixels.
+func contentImages(doc *html.Node) []*html.Node {
+	var images []*html.Node
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "head", "nav", "header", "footer", "aside", "script", "noscript":
+				return
+			case "img":
+				src := imageSource(n)
+				width, _ := strconv.Atoi(htmlAttribute(n, "width"))
+				height, _ := strconv.Atoi(htmlAttribute(n, "height"))
+				if src != "" && !strings.HasPrefix(src, "data:") && (width == 0 || width >= MIN_COVER_DIMENSION) && (height == 0 || height >= MIN_COVER_DIMENSION) {
+					images = append(images, n)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+	traverse(doc)
+	return images
+}
+
+// imageSource returns the source of an image, the lazy loaded images giving
+// it in data-src.
+func imageSource(image *html.Node) string {
+	if src := strings.TrimSpace(htmlAttribute(image, "data-src")); src != "" {
+		return src
+	}
+	return strings.TrimSpace(htmlAttribute(image, "src"))
+}
+
+// setImageText appends the text read from an image to its alt text, which is
+// what the extraction keeps of the images.
+func setImageText(image *html.Node, text string) {
+	for i, attr := range image.Attr {
+		if attr.Key == "alt" {
+			if strings.TrimSpace(attr.Val) != "" {
+				text = strings.TrimSpace(attr.Val) + " - text: " + text
+			}
+			image.Attr[i].Val = text
+			return
+		}
+	}
+	image.Attr = append(image.Attr, html.Attribute{Key: "alt", Val: text})
+}
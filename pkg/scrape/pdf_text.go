@@ -0,0 +1,411 @@
+package scrape
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	// Longest first line taken as the title of a document, longer ones being
+	// paragraphs
+	MAX_DOCUMENT_TITLE_LENGTH = 120
+	// Minimum share of letters in the text read from a PDF document, below
+	// which its fonts are assumed not to map to readable text
+	MIN_PDF_LETTER_RATIO = 0.5
+)
+
+// Title of a PDF document, in its information dictionary, as a literal or
+// a hexadecimal string.
+var pdfTitleRegex = regexp.MustCompile(`/Title\s*([(<])`)
+
+// extractTextArticle extracts the article of a plain text or Markdown page,
+// titled by its first line.
+func extractTextArticle(articleUrl, text string) (Article, error) {
+	content := strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if content == "" {
+		return Article{}, fmt.Errorf("scraping page body: empty text page")
+	}
+	firstLine, _, _ := strings.Cut(content, "\n")
+	title := documentTitle(articleUrl, strings.TrimLeft(firstLine, "# "))
+	slog.Debug("Text page extracted", "url", articleUrl, "title", title, "characters", len(content))
+
+	return Article{
+		Url:         articleUrl,
+		Title:       title,
+		Content:     content,
+		ContentType: detectContentType(articleUrl, title, ""),
+		Language:    detectLanguage("", content),
+	}, nil
+}
+
+// extractPdfArticle extracts the article of a PDF document, titled by the
+// title of its metadata, else by its first line.
+func extractPdfArticle(articleUrl string, document []byte) (Article, error) {
+	content, title := readPdfText(document)
+	if !isReadablePdfText(content) {
+		return Article{}, fmt.Errorf("scraping page body: no text found in the PDF document, e.g. scanned pages or fonts without text mapping")
+	}
+	if title == "" {
+		title, _, _ = strings.Cut(content, "\n")
+	}
+	title = documentTitle(articleUrl, title)
+	slog.Debug("PDF document extracted", "url", articleUrl, "title", title, "characters", len(content))
+
+	return Article{
+		Url:         articleUrl,
+		Title:       title,
+		Content:     content,
+		ContentType: detectContentType(articleUrl, title, ""),
+		Language:    detectLanguage("", content),
+	}, nil
+}
+
+// documentTitle returns the title of a document, or the name of its file
+// when the title is empty or too long to be one.
+func documentTitle(documentUrl, title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	if title != "" && utf8.RuneCountInString(title) <= MAX_DOCUMENT_TITLE_LENGTH {
+		return title
+	}
+	if parsed, err := url.Parse(documentUrl); err == nil && strings.Trim(parsed.Path, "/") != "" {
+		if name, err := url.PathUnescape(path.Base(parsed.Path)); err == nil {
+			return name
+		}
+	}
+	return documentUrl
+}
+
+// isReadablePdfText tells if the text read from a PDF document is text
+// rather than the glyph codes of fonts mapped to no characters.
+func isReadablePdfText(text string) bool {
+	var letters, others int
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r):
+			letters++
+		case !unicode.IsSpace(r):
+			others++
+		}
+	}
+	return letters > 0 && float64(letters) >= MIN_PDF_LETTER_RATIO*float64(letters+others)
+}
+
+// readPdfText returns the text of a PDF document, read from the text
+// operators of its page content streams, and its title when its metadata has
+// one. Only the fonts encoding their characters as bytes of a single byte
+// encoding are read: the glyphs of the other fonts only map to characters
+// through tables this reader leaves out.
+func readPdfText(document []byte) (string, string) {
+	var text strings.Builder
+	title := readPdfTitle(document)
+	for _, stream := range pdfStreams(document) {
+		if bytes.Contains(stream, []byte("BT")) && bytes.Contains(stream, []byte("ET")) {
+			text.WriteString(pdfContentText(stream))
+			text.WriteString("\n")
+		} else if title == "" {
+			// The information dictionary may be in a compressed object stream
+			title = readPdfTitle(stream)
+		}
+	}
+
+	var lines []string
+	for _, line := range strings.Split(text.String(), "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n"), title
+}
+
+// readPdfTitle returns the title of the information dictionary of a PDF
+// document, empty if not found.
+func readPdfTitle(data []byte) string {
+	match := pdfTitleRegex.FindSubmatchIndex(data)
+	if match == nil {
+		return ""
+	}
+	start := match[2]
+	if data[start] == '(' {
+		value, _ := readPdfLiteralString(data, start)
+		return decodePdfString(value)
+	}
+	end := bytes.IndexByte(data[start:], '>')
+	if end < 0 {
+		return ""
+	}
+	return decodePdfString(decodePdfHexString(data[start+1 : start+end]))
+}
+
+// pdfStreams returns the data of the streams of a PDF document, decompressed.
+// The streams of images and fonts, and those compressed with filters other
+// than Flate, are left out.
+func pdfStreams(document []byte) [][]byte {
+	var streams [][]byte
+	for offset := 0; ; {
+		start := bytes.Index(document[offset:], []byte("stream"))
+		if start < 0 {
+			return streams
+		}
+		start += offset
+		offset = start + len("stream")
+		// Skips the endstream keywords
+		if start >= 3 && string(document[start-3:start]) == "end" {
+			continue
+		}
+
+		// The dictionary of the stream is between the start of its object
+		// and the stream keyword
+		dictionary := document[max(0, bytes.LastIndex(document[:start], []byte("obj"))):start]
+		dataStart := offset
+		if dataStart < len(document) && document[dataStart] == '\r' {
+			dataStart++
+		}
+		if dataStart < len(document) && document[dataStart] == '\n' {
+			dataStart++
+		}
+		end := bytes.Index(document[dataStart:], []byte("endstream"))
+		if end < 0 {
+			return streams
+		}
+		data := document[dataStart : dataStart+end]
+		offset = dataStart + end
+
+		if bytes.Contains(dictionary, []byte("/Image")) || bytes.Contains(dictionary, []byte("/FontFile")) || bytes.Contains(dictionary, []byte("/Length1")) || bytes.Contains(dictionary, []byte("/Subtype/Type1C")) || bytes.Contains(dictionary, []byte("/Subtype /Type1C")) {
+			continue
+		}
+		switch {
+		case bytes.Contains(dictionary, []byte("/FlateDecode")):
+			reader, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				continue
+			}
+			// A truncated stream still gives the text before the damage
+			inflated, _ := io.ReadAll(reader)
+			streams = append(streams, inflated)
+		case !bytes.Contains(dictionary, []byte("/Filter")):
+			streams = append(streams, data)
+		}
+	}
+}
+
+// pdfContentText returns the text shown by the operators of a content
+// stream, a line per line of the page.
+func pdfContentText(content []byte) string {
+	var text strings.Builder
+	// Operands of the next operator: the strings to show, and the numbers
+	var shown []string
+	var numbers []float64
+	inArray := false
+	lineY := 0.0
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0:
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			value, end := readPdfLiteralString(content, i)
+			shown = append(shown, decodePdfString(value))
+			i = end
+		case c == '<' && i+1 < len(content) && content[i+1] == '<':
+			// Dictionaries are only operands of the marked content operators
+			end := bytes.Index(content[i:], []byte(">>"))
+			if end < 0 {
+				return text.String()
+			}
+			i += end + 2
+		case c == '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return text.String()
+			}
+			shown = append(shown, decodePdfString(decodePdfHexString(content[i+1:i+end])))
+			i += end + 1
+		case c == '[':
+			inArray = true
+			i++
+		case c == ']':
+			inArray = false
+			i++
+		case c == '/':
+			i++
+			for i < len(content) && !isPdfDelimiter(content[i]) {
+				i++
+			}
+		default:
+			start := i
+			for i < len(content) && !isPdfDelimiter(content[i]) {
+				i++
+			}
+			if i == start {
+				// A delimiter out of place
+				i++
+				continue
+			}
+			token := string(content[start:i])
+			if number, err := strconv.ParseFloat(token, 64); err == nil {
+				// A large negative adjustment between the strings of an array
+				// is a space between words
+				if inArray && number < -200 {
+					shown = append(shown, " ")
+				}
+				numbers = append(numbers, number)
+				continue
+			}
+
+			switch token {
+			case "Tj", "TJ":
+				text.WriteString(strings.Join(shown, ""))
+			case "'", "\"":
+				text.WriteString("\n" + strings.Join(shown, ""))
+			case "T*":
+				text.WriteString("\n")
+			case "Td", "TD":
+				if len(numbers) >= 2 && numbers[len(numbers)-1] != 0 {
+					text.WriteString("\n")
+				} else if len(numbers) >= 2 && numbers[len(numbers)-2] > 0 {
+					text.WriteString(" ")
+				}
+			case "Tm":
+				if len(numbers) >= 6 {
+					if y := numbers[len(numbers)-1]; y != lineY {
+						text.WriteString("\n")
+						lineY = y
+					} else {
+						text.WriteString(" ")
+					}
+				}
+			case "ET":
+				text.WriteString(" ")
+			case "BI":
+				// Inline images end with the EI operator
+				end := bytes.Index(content[i:], []byte("EI"))
+				if end < 0 {
+					return text.String()
+				}
+				i += end + 2
+			}
+			shown, numbers = nil, nil
+		}
+	}
+	return text.String()
+}
+
+func isPdfDelimiter(c byte) bool {
+	return strings.IndexByte(" \t\r\n\f\x00()<>[]{}/%", c) >= 0
+}
+
+// readPdfLiteralString reads the literal string starting at the opening
+// parenthesis at start, and returns its bytes and the offset following it.
+func readPdfLiteralString(data []byte, start int) ([]byte, int) {
+	var value []byte
+	depth := 0
+	for i := start; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '(':
+			if depth > 0 {
+				value = append(value, c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return value, i + 1
+			}
+			value = append(value, c)
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch escaped := data[i]; escaped {
+			case 'n':
+				value = append(value, '\n')
+			case 'r':
+				value = append(value, '\r')
+			case 't':
+				value = append(value, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// A line continuation
+				if escaped == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			default:
+				if escaped >= '0' && escaped <= '7' {
+					code := 0
+					for n := 0; n < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; n++ {
+						code = code*8 + int(data[i]-'0')
+						i++
+					}
+					i--
+					value = append(value, byte(code))
+				} else {
+					value = append(value, escaped)
+				}
+			}
+		default:
+			value = append(value, c)
+		}
+	}
+	return value, len(data)
+}
+
+func decodePdfHexString(hexDigits []byte) []byte {
+	var digits []byte
+	for _, c := range hexDigits {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	// A missing last digit is a 0
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	value := make([]byte, len(digits)/2)
+	for i := range value {
+		b, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		value[i] = byte(b)
+	}
+	return value
+}
+
+// decodePdfString decodes a string of a PDF document: UTF-16 when it starts
+// with a byte order mark, else a single byte encoding read as windows-1252,
+// which agrees with the standard encodings of PDF for the usual characters.
+// Control characters are dropped.
+func decodePdfString(value []byte) string {
+	var text string
+	if len(value) >= 2 && value[0] == 0xfe && value[1] == 0xff {
+		units := make([]uint16, 0, len(value)/2)
+		for i := 2; i+1 < len(value); i += 2 {
+			units = append(units, uint16(value[i])<<8|uint16(value[i+1]))
+		}
+		text = string(utf16.Decode(units))
+	} else {
+		text = decodeCharset(value, "windows-1252")
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return ' '
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
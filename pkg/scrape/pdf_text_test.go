@@ -0,0 +1,109 @@
+package scrape
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPdfContentText(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"show string", "BT /F1 12 Tf 72 712 Td (Hello world) Tj ET", "\nHello world "},
+		{"escapes", `BT (Caf\351 \(draft\)\\ \101\102) Tj ET`, "Café (draft)\\ AB "},
+		{"nested parentheses", "BT (a (b) c) Tj ET", "a (b) c "},
+		{"line continuation", "BT (one \\\ntwo) Tj ET", "one two "},
+		{"hex string", "BT <48656C6C6F> Tj ET", "Hello "},
+		{"hex string with odd digits", "BT <4869 2> Tj ET", "Hi  "},
+		{"utf-16 hex string", "BT <FEFF00E9006C00E800760065> Tj ET", "élève "},
+		{"kerned array", "BT [(W) 120 (orld) -250 (wide)] TJ ET", "World wide "},
+		{"new lines", "BT (First) Tj 0 -14 Td (Second) Tj T* (Third) Tj (Fourth) ' ET", "First\nSecond\nThird\nFourth "},
+		{"same line", "BT (left) Tj 40 0 Td (right) Tj ET", "left right "},
+		{"text matrix", "BT 1 0 0 1 72 700 Tm (A) Tj 1 0 0 1 200 700 Tm (B) Tj 1 0 0 1 72 680 Tm (C) Tj ET", "\nA B\nC "},
+		{"comments and marked content", "% a comment (not shown) Tj\n/Span <</ActualText (x)>> BDC BT (shown) Tj ET EMC", "shown "},
+		{"inline image", "BI /W 2 /H 2 ID (\x00\xff) EI BT (after) Tj ET", "after "},
+		{"control characters", "BT (tab\there\x07) Tj ET", "tab here "},
+		{"unterminated hex string", "BT (kept) Tj <4142", "kept"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if text := pdfContentText([]byte(test.content)); text != test.want {
+				t.Errorf("pdfContentText(%q) = %q, want %q", test.content, text, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodePdfString(t *testing.T) {
+	tests := []struct {
+		name  string
+		value []byte
+		want  string
+	}{
+		{"ascii", []byte("plain"), "plain"},
+		{"windows-1252", []byte("\x93quoted\x94 \x96 5\x80"), "“quoted” – 5€"},
+		{"latin-1", []byte("na\xefve"), "naïve"},
+		{"utf-16", []byte("\xfe\xff\x00S\x00\xe9\x00a"), "Séa"},
+		{"utf-16 surrogate pair", []byte("\xfe\xff\xd8\x3d\xde\x00"), "😀"},
+		{"line breaks", []byte("a\nb\tc"), "a b c"},
+		{"control characters", []byte("a\x00b\x1bc"), "abc"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if text := decodePdfString(test.value); text != test.want {
+				t.Errorf("decodePdfString(%q) = %q, want %q", test.value, text, test.want)
+			}
+		})
+	}
+}
+
+// writeTestPdf writes a PDF document of one page per content stream,
+// compressed when asked, with an information dictionary giving its title.
+func writeTestPdf(t *testing.T, title string, compress bool, contents ...string) []byte {
+	t.Helper()
+	var sb strings.Builder
+	sb.WriteString("%PDF-1.4\n")
+	fmt.Fprintf(&sb, "1 0 obj\n<< /Title (%s) /Producer (test) >>\nendobj\n", title)
+	for i, content := range contents {
+		data := []byte(content)
+		filter := ""
+		if compress {
+			var buf bytes.Buffer
+			writer := zlib.NewWriter(&buf)
+			if _, err := writer.Write(data); err != nil {
+				t.Fatal(err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+			data, filter = buf.Bytes(), " /Filter /FlateDecode"
+		}
+		fmt.Fprintf(&sb, "%d 0 obj\n<< /Length %d%s >>\nstream\n%s\nendstream\nendobj\n", i+2, len(data), filter, data)
+	}
+	sb.WriteString("trailer\n<< /Info 1 0 R >>\n%%EOF\n")
+	return []byte(sb.String())
+}
+
+func TestReadPdfText(t *testing.T) {
+	pages := []string{
+		"BT /F1 24 Tf 72 720 Td (A   title) Tj ET\nBT /F1 12 Tf 72 690 Td (First paragraph,) Tj 0 -14 Td (on two lines.) Tj ET",
+		"BT 72 720 Td [(Second) -300 (page)] TJ ET",
+	}
+	want := "A title\nFirst paragraph,\non two lines.\nSecond page"
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compressed %v", compress), func(t *testing.T) {
+			text, title := readPdfText(writeTestPdf(t, "The document", compress, pages...))
+			if text != want {
+				t.Errorf("readPdfText text = %q, want %q", text, want)
+			}
+			if title != "The document" {
+				t.Errorf("readPdfText title = %q, want %q", title, "The document")
+			}
+		})
+	}
+}
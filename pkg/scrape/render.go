@@ -0,0 +1,166 @@
+package scrape
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Render the pages with a browser only when their content looks empty.
+	RENDER_AUTO   = "auto"
+	RENDER_ALWAYS = "always"
+	RENDER_NEVER  = "never"
+	// Pages with less text than this are assumed to need JavaScript, such as
+	// single page applications.
+	MIN_UNRENDERED_CONTENT_LENGTH = 500
+)
+
+// Browsers looked up in the PATH when no renderer is given.
+var browserNames = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome"}
+
+// Renderer renders the pages relying on JavaScript, with a local headless
+// browser or a rendering service.
+type Renderer struct {
+	Mode string
+	// Browser executable, or http(s) url of a rendering service answering a
+	// POST of {"url": "..."} with the rendered HTML, like browserless.
+	Backend string
+}
+
+func (r Renderer) validate() error {
+	if r.Mode != RENDER_AUTO && r.Mode != RENDER_ALWAYS && r.Mode != RENDER_NEVER {
+		return fmt.Errorf("unknown render mode '%s', expected %s, %s or %s", r.Mode, RENDER_AUTO, RENDER_ALWAYS, RENDER_NEVER)
+	}
+	return nil
+}
+
+func (r Renderer) isService() bool {
+	return strings.HasPrefix(r.Backend, "http://") || strings.HasPrefix(r.Backend, "https://")
+}
+
+// Browser returns the path of the browser, or an error if there is none.
+func (r Renderer) Browser() (string, error) {
+	if r.Backend != "" {
+		return exec.LookPath(r.Backend)
+	}
+	for _, name := range browserNames {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no browser found in the PATH (%s), set -renderer", strings.Join(browserNames, ", "))
+}
+
+// canRender tells if pages can be rendered, for the automatic mode.
+func (s *Scraper) canRender() bool {
+	if s.Renderer.isService() {
+		return true
+	}
+	// The browser makes its own requests, it cannot enforce the fetch policy
+	if s.Policy.BlockPrivateNetworks {
+		return false
+	}
+	_, err := s.Renderer.Browser()
+	return err == nil
+}
+
+// render returns the HTML of a page once its scripts have run.
+func (s *Scraper) render(ctx context.Context, pageUrl string) (string, error) {
+	ctx, cancel := WithTimeout(ctx, s.Policy.Timeout, "page rendering")
+	defer cancel()
+
+	if s.Renderer.isService() {
+		return s.renderWithService(ctx, pageUrl)
+	}
+	if s.Policy.BlockPrivateNetworks {
+		return "", &FetchError{Url: pageUrl, Err: fmt.Errorf("%w: a local browser cannot block private networks, use a rendering service", ErrBlockedUrl)}
+	}
+
+	browser, err := s.Renderer.Browser()
+	if err != nil {
+		return "", fmt.Errorf("rendering '%s': %w", pageUrl, err)
+	}
+
+	// Failures of the browser are not transient, unlike network errors
+	var stderr bytes.Buffer
+	args := []string{"--headless", "--disable-gpu", "--hide-scrollbars", "--virtual-time-budget=10000"}
+	if s.Credentials.UserAgent != "" {
+		args = append(args, "--user-agent="+s.Credentials.UserAgent)
+	}
+	cmd := exec.CommandContext(ctx, browser, append(args, "--dump-dom", pageUrl)...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("rendering '%s' with %s: %w: %s", pageUrl, browser, TimeoutCause(ctx, err), strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
+
+func (s *Scraper) renderWithService(ctx context.Context, pageUrl string) (string, error) {
+	// The service connects to the page in our place, its addresses are
+	// checked before
+	if err := s.Policy.checkResolvedUrl(ctx, pageUrl); err != nil {
+		return "", &FetchError{Url: pageUrl, Err: err}
+	}
+	backend, err := url.Parse(s.Renderer.Backend)
+	if err != nil {
+		return "", &FetchError{Url: pageUrl, Err: fmt.Errorf("rendering: %w", err)}
+	}
+
+	body, err := json.Marshal(map[string]string{"url": pageUrl})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.Renderer.Backend, bytes.NewReader(body))
+	if err != nil {
+		return "", &FetchError{Url: pageUrl, Err: fmt.Errorf("rendering: %w", err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// The rendering service is trusted, whatever the fetch policy, but not
+	// its redirects
+	policy := s.Policy
+	policy.TrustedHosts = append(slices.Clone(policy.TrustedHosts), backend.Hostname())
+	res, err := policy.httpClient(s.Credentials.jar).Do(req)
+	if err != nil {
+		return "", &FetchError{Url: pageUrl, Err: fmt.Errorf("rendering: %w", TimeoutCause(ctx, err))}
+	}
+	defer res.Body.Close()
+
+	fetchErr := FetchError{
+		Url:        pageUrl,
+		FinalUrl:   s.Renderer.Backend,
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		RetryAfter: ParseRetryAfter(res.Header.Get("Retry-After")),
+	}
+	if res.StatusCode >= 400 {
+		fetchErr.Err = fmt.Errorf("unexpected response status of the rendering service")
+		return "", &fetchErr
+	}
+
+	page, err := io.ReadAll(res.Body)
+	if err != nil {
+		fetchErr.Err = fmt.Errorf("reading rendered page: %w", TimeoutCause(ctx, err))
+		return "", &fetchErr
+	}
+	return string(page), nil
+}
+
+// needsRendering tells if an article scraped without JavaScript looks empty.
+func needsRendering(article Article, err error) bool {
+	if errors.Is(err, ErrPaywalled) {
+		return false
+	}
+	return err != nil || utf8.RuneCountInString(strings.TrimSpace(article.Content)) < MIN_UNRENDERED_CONTENT_LENGTH
+}
@@ -0,0 +1,133 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy retries the requests failing for a transient reason: network
+// errors, rate limits (429) and server errors (5xx). Retries are spaced by an
+// exponential backoff with jitter, unless the server tells how long to wait
+// with a Retry-After header.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	// Longest wait between two attempts. A server asking to wait longer makes
+	// the request fail right away.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy returns the policy of the requests when the flags do
+// not tell otherwise.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxRetries: 3, BaseDelay: time.Second, MaxDelay: time.Minute}
+}
+
+// IsTransientStatus tells if a response status is worth retrying.
+func IsTransientStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// retryAfter tells if an error is transient, and how long the server asked to
+// wait before retrying, if it did.
+func retryAfter(err error) (bool, time.Duration) {
+	// Checked first as a timeout also wraps the deadline of its context
+	if errors.Is(err, ErrTimeout) {
+		return true, 0
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrBlockedUrl) {
+		return false, 0
+	}
+
+	var retryable retryableError
+	if errors.As(err, &retryable) {
+		return retryable.Transient()
+	}
+	return false, 0
+}
+
+// retryableError is the failure of a call to a server, which tells if it is
+// transient and how long the server asked to wait before retrying.
+type retryableError interface {
+	error
+	Transient() (bool, time.Duration)
+}
+
+// ParseRetryAfter parses a Retry-After header, either a number of seconds or
+// an HTTP date.
+func ParseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return max(0, time.Until(date))
+	}
+	return 0
+}
+
+// backoff returns the delay before a retry: the base delay doubled at each
+// retry, with a random jitter so that concurrent clients do not retry at the
+// same time.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay << retry
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// Do calls attempt until it succeeds, fails for a reason that is not
+// transient, or the retries are exhausted.
+func (p RetryPolicy) Do(ctx context.Context, description string, attempt func() error) error {
+	for retry := 0; ; retry++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+
+		transient, wait := retryAfter(err)
+		if !transient || retry >= p.MaxRetries {
+			return err
+		}
+		if wait > p.MaxDelay {
+			return fmt.Errorf("asked to retry in %s, more than the maximum delay: %w", wait.Round(time.Second), err)
+		}
+		if wait == 0 {
+			wait = p.backoff(retry)
+		}
+
+		slog.Warn(description+" failed, retrying", "in", wait, "retry", fmt.Sprintf("%d/%d", retry+1, p.MaxRetries), "err", err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// WithTimeout returns a context canceled after timeout with ErrTimeout as its
+// cause, or simply a cancelable context if timeout is 0.
+func WithTimeout(ctx context.Context, timeout time.Duration, description string) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w: %s took more than %s", ErrTimeout, description, timeout))
+}
+
+// TimeoutCause returns the timeout error instead of the error of an operation
+// interrupted by the timeout of its context.
+func TimeoutCause(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); err != nil && errors.Is(cause, ErrTimeout) {
+		return cause
+	}
+	return err
+}
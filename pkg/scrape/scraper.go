@@ -0,0 +1,387 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Scraper fetches the pages of the articles and extracts them. Its fields
+// are the policies and the identity of the requests, and the fallbacks of
+// the pages the fetch alone cannot read: rendering, OCR and the Wayback
+// Machine. A Scraper must not be changed once in use, and can then be used by
+// several goroutines at once.
+type Scraper struct {
+	Policy      FetchPolicy
+	Retry       RetryPolicy
+	Credentials FetchCredentials
+	Renderer    Renderer
+	Ocr         OcrEngine
+	// When the Wayback Machine copy of a page is summarized instead of the
+	// page itself: ARCHIVE_AUTO, ARCHIVE_ALWAYS or ARCHIVE_NEVER
+	Archive string
+	// Cache of the fetched pages
+	Cache ResponseCache
+	// Rules of the sites the generic extractor gets wrong, the most specific
+	// domains first, see CompileSiteRules
+	SiteRules []SiteRule
+	// OnFetched is called with the pages downloaded from the sites, if not
+	// nil, before their article is extracted.
+	OnFetched func(ctx context.Context, articleUrl string, page *FetchedPage) error
+}
+
+// newScraper returns a scraper with the default policies, caching the pages
+// in the cache directory.
+func New() *Scraper {
+	return &Scraper{
+		Policy:      FetchPolicy{MaxRedirects: DEFAULT_MAX_REDIRECTS, Timeout: DEFAULT_FETCH_TIMEOUT},
+		Retry:       DefaultRetryPolicy(),
+		Credentials: FetchCredentials{UserAgent: DefaultUserAgent(), Headers: HeaderFlags{}},
+		Renderer:    Renderer{Mode: RENDER_AUTO},
+		Ocr:         OcrEngine{Mode: OCR_NEVER},
+		Archive:     ARCHIVE_AUTO,
+		Cache:       NewResponseCache(),
+	}
+}
+
+// Load validates the settings of a scraper and reads its cookie file.
+func (s *Scraper) Load() error {
+	if err := s.Renderer.validate(); err != nil {
+		return err
+	}
+	if err := s.Ocr.validate(); err != nil {
+		return err
+	}
+	if err := validateArchiveMode(s.Archive); err != nil {
+		return err
+	}
+	return s.Credentials.Load()
+}
+
+// httpClient returns a client enforcing the fetch policy and sending the
+// cookies of the credentials.
+func (s *Scraper) httpClient() *http.Client {
+	return s.Policy.httpClient(s.Credentials.jar)
+}
+
+// Author returns the authors of the article, comma separated.
+func (a Article) Author() string {
+	return strings.Join(a.Authors, ", ")
+}
+
+// ScrapeArticle scrapes the article of an url, from its Wayback Machine copy
+// when the page is gone or paywalled, depending on the archive mode.
+func (s *Scraper) ScrapeArticle(ctx context.Context, articleUrl string) (Article, error) {
+	if s.Archive == ARCHIVE_ALWAYS {
+		return s.scrapeArchivedArticle(ctx, articleUrl)
+	}
+
+	article, err := s.scrapeLiveArticle(ctx, articleUrl)
+	if s.Archive == ARCHIVE_AUTO && needsArchive(err) && ctx.Err() == nil {
+		slog.Info("Page unavailable, trying its Wayback Machine copy", "url", articleUrl, "err", err)
+		archived, archiveErr := s.scrapeArchivedArticle(ctx, articleUrl)
+		if archiveErr != nil {
+			slog.Warn("could not use the Wayback Machine copy", "url", articleUrl, "err", archiveErr)
+			return article, err
+		}
+		slog.Info("Using the Wayback Machine copy", "archived", archived.Archived.Format(DATE_FORMAT), "url", archived.ArchiveUrl)
+		return archived, nil
+	}
+	return article, err
+}
+
+func (s *Scraper) scrapeLiveArticle(ctx context.Context, articleUrl string) (Article, error) {
+	// The page of a video only holds its player, its transcript is read from
+	// its captions
+	if videoId := youtubeVideoId(articleUrl); videoId != "" {
+		return s.scrapeYoutubeVideo(ctx, articleUrl, videoId)
+	}
+	if s.Renderer.Mode == RENDER_ALWAYS {
+		page, err := s.renderPage(ctx, articleUrl)
+		if err != nil {
+			return Article{}, err
+		}
+		article, err := s.extractArticle(articleUrl, page)
+		return s.readImageText(ctx, articleUrl, articleUrl, page, article, err)
+	}
+
+	var page FetchedPage
+	err := s.Retry.Do(ctx, "fetching "+articleUrl, func() error {
+		var err error
+		page, err = s.fetchArticlePage(ctx, articleUrl)
+		return err
+	})
+	if err != nil {
+		return Article{}, err
+	}
+	if len(page.Redirects) > 0 {
+		slog.Info("Page redirected", "url", articleUrl, "final_url", page.Redirects[len(page.Redirects)-1], "redirects", len(page.Redirects))
+	}
+	if s.OnFetched != nil {
+		if err := s.OnFetched(ctx, articleUrl, &page); err != nil {
+			return Article{}, err
+		}
+	}
+
+	article, err := s.extractFetchedArticle(articleUrl, page)
+	if page.isHtml() && s.Renderer.Mode == RENDER_AUTO && needsRendering(article, err) && s.canRender() {
+		slog.Info("Page content looks empty, rendering it with JavaScript", "url", articleUrl)
+		rendered, renderErr := s.renderPage(ctx, articleUrl)
+		if renderErr != nil {
+			slog.Warn("could not render the page", "url", articleUrl, "err", renderErr)
+			return article, err
+		}
+		article, err = s.extractArticle(articleUrl, rendered)
+		return s.readImageText(ctx, articleUrl, articleUrl, rendered, article, err)
+	}
+	if page.isHtml() {
+		// The images are relative to the page the redirects led to
+		pageUrl := articleUrl
+		if len(page.Redirects) > 0 {
+			pageUrl = page.Redirects[len(page.Redirects)-1]
+		}
+		return s.readImageText(ctx, articleUrl, pageUrl, page.text(), article, err)
+	}
+	return article, err
+}
+
+func (s *Scraper) renderPage(ctx context.Context, articleUrl string) (string, error) {
+	var page string
+	err := s.Retry.Do(ctx, "rendering "+articleUrl, func() error {
+		var err error
+		page, err = s.render(ctx, articleUrl)
+		return err
+	})
+	return page, err
+}
+
+// extractFetchedArticle extracts the article of a downloaded page with the
+// extractor of its media type.
+func (s *Scraper) extractFetchedArticle(articleUrl string, page FetchedPage) (Article, error) {
+	switch page.MediaType {
+	case "application/pdf":
+		return extractPdfArticle(articleUrl, page.Body)
+	case "text/plain", "text/markdown":
+		return extractTextArticle(articleUrl, page.text())
+	}
+	return s.extractArticle(articleUrl, page.text())
+}
+
+// extractArticle extracts the article from the HTML of its page.
+func (s *Scraper) extractArticle(articleUrl string, page string) (Article, error) {
+	if paywallRegex.MatchString(page) {
+		return Article{}, ErrPaywalled
+	}
+	return s.extractPageArticle(articleUrl, page)
+}
+
+// extractPageArticle extracts the article from the HTML of a page, whether
+// it is paywalled or not.
+func (s *Scraper) extractPageArticle(articleUrl string, page string) (Article, error) {
+	metadata := scrapeArticleMetadata(page)
+
+	// The title is read from the whole page, the content picked by the site
+	// rule possibly leaving out its heading
+	title, err := scrapeArticleTitle(page)
+	if rule, ok := siteRuleFor(s.SiteRules, articleUrl); ok {
+		ruleTitle := ""
+		page, ruleTitle = rule.apply(articleUrl, page)
+		if ruleTitle != "" {
+			title, err = ruleTitle, nil
+		}
+	}
+	if errors.Is(err, ErrNoTitle) && metadata.Title != "" {
+		title, err = metadata.Title, nil
+	}
+	if err != nil {
+		return Article{}, fmt.Errorf("scraping article title: %w", err)
+	}
+
+	content, err := scrapePageBody(page)
+	if err != nil {
+		return Article{}, fmt.Errorf("scraping page body: %w", err)
+	}
+	slog.Debug("Page extracted", "url", articleUrl, "title", title, "characters", len(content), "authors", strings.Join(metadata.Authors, ", "), "site_name", metadata.SiteName, "declared_language", metadata.Language)
+
+	return Article{
+		Url:         articleUrl,
+		Title:       title,
+		Content:     content,
+		ContentType: detectContentType(articleUrl, title, page),
+		Authors:     metadata.Authors,
+		SiteName:    metadata.SiteName,
+		Published:   metadata.Published,
+		Image:       selectCoverImage(articleUrl, metadata.Image, page),
+		Images:      articleImages(articleUrl, page),
+		Tables:      articleTables(page),
+		Markdown:    htmlToMarkdown(articleUrl, page),
+		Language:    detectLanguage(metadata.Language, content),
+	}, nil
+}
+
+// FetchUrlAndReturnPage fetches a page with the credentials of the scraper
+// and returns its body.
+func (s *Scraper) FetchUrlAndReturnPage(ctx context.Context, url string) (string, error) {
+	return s.fetchPage(ctx, url, s.Credentials.authorize)
+}
+
+// text returns the body of the page, decoded from its charset.
+func (p FetchedPage) text() string {
+	return decodeCharset(p.Body, p.Charset)
+}
+
+func (p FetchedPage) isHtml() bool {
+	return p.MediaType == "text/html" || p.MediaType == "application/xhtml+xml"
+}
+
+// fetchArticlePage downloads the page of an article, which must be of one of
+// the media types of articleMediaTypes. A page of the cache is used as is
+// when recent enough, else if the server tells it did not change.
+func (s *Scraper) fetchArticlePage(ctx context.Context, url string) (FetchedPage, error) {
+	if err := s.Policy.checkRawUrl(url); err != nil {
+		return FetchedPage{}, &FetchError{Url: url, Err: err}
+	}
+	cached, found := s.Cache.page(url)
+	if found {
+		// The page may have been cached by a run allowing the private
+		// networks the current policy blocks, e.g. by the command line for
+		// the server: it is then fetched, the fetch enforcing the policy
+		for _, target := range append([]string{url}, cached.Redirects...) {
+			if err := s.Policy.checkResolvedUrl(ctx, target); err != nil {
+				slog.Debug("Cached page not used", "url", url, "err", err)
+				found = false
+				break
+			}
+		}
+	}
+	if found && time.Since(cached.Fetched) < s.Cache.PageMaxAge {
+		slog.Debug("Page read from the cache", "url", url, "fetched", cached.Fetched)
+		return cached.fetchedPage(), nil
+	}
+
+	page, err := s.FetchResponse(ctx, url, func(req *http.Request) {
+		s.Credentials.authorize(req)
+		if found {
+			cached.revalidate(req)
+		}
+	}, func(mediaType string) bool {
+		return articleMediaTypes[mediaType]
+	})
+	if err != nil {
+		return FetchedPage{}, err
+	}
+	if page.NotModified {
+		slog.Debug("Page not modified, read from the cache", "url", url)
+		cached.Fetched = time.Now()
+		s.Cache.savePage(url, cached)
+		return cached.fetchedPage(), nil
+	}
+	s.Cache.savePage(url, CachedPage{
+		Url:          url,
+		Fetched:      time.Now(),
+		ETag:         page.ETag,
+		LastModified: page.LastModified,
+		MediaType:    page.MediaType,
+		Charset:      page.Charset,
+		Redirects:    page.Redirects,
+		Body:         page.Body,
+	})
+	return page, nil
+}
+
+// fetchPage returns the body of a page, its request being prepared by
+// prepare, e.g. to send the credentials of the user.
+func (s *Scraper) fetchPage(ctx context.Context, url string, prepare func(req *http.Request)) (string, error) {
+	page, err := s.FetchResponse(ctx, url, prepare, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(page.Body), nil
+}
+
+// FetchResponse downloads a page, its request being prepared by prepare. The
+// response must be successful, redirects being followed, and of a media type
+// accepted by accept when it is not nil. The body is not downloaded when the
+// server tells a media type that is not accepted.
+func (s *Scraper) FetchResponse(ctx context.Context, url string, prepare func(req *http.Request), accept func(mediaType string) bool) (FetchedPage, error) {
+	ctx, cancel := WithTimeout(ctx, s.Policy.Timeout, "page fetch")
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return FetchedPage{}, &FetchError{Url: url, Err: err}
+	}
+	if err := s.Policy.checkUrl(req.URL); err != nil {
+		return FetchedPage{}, &FetchError{Url: url, Err: err}
+	}
+	prepare(req)
+
+	var page FetchedPage
+	client := s.httpClient()
+	checkRedirect := client.CheckRedirect
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := checkRedirect(req, via); err != nil {
+			return err
+		}
+		slog.Debug("Redirected", "url", via[len(via)-1].URL.Redacted(), "to", req.URL.Redacted(), "status", req.Response.StatusCode)
+		page.Redirects = append(page.Redirects, req.URL.String())
+		return nil
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return FetchedPage{}, &FetchError{Url: url, Err: TimeoutCause(ctx, err)}
+	}
+	defer res.Body.Close()
+
+	fetchErr := FetchError{
+		Url:        url,
+		FinalUrl:   res.Request.URL.String(),
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		RetryAfter: ParseRetryAfter(res.Header.Get("Retry-After")),
+	}
+	// A cached page that did not change, the request being conditional
+	if res.StatusCode == http.StatusNotModified && (req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "") {
+		return FetchedPage{NotModified: true}, nil
+	}
+	// Redirects are followed, a redirect left is one without location
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		fetchErr.Err = fmt.Errorf("unexpected response status")
+		return FetchedPage{}, &fetchErr
+	}
+
+	// Servers not knowing the type of a file send it as a stream of bytes
+	mediaType, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	sniff := err != nil || mediaType == "application/octet-stream"
+	if !sniff && accept != nil && !accept(mediaType) {
+		fetchErr.Err = fmt.Errorf("%w '%s'", ErrUnsupportedContent, mediaType)
+		return FetchedPage{}, &fetchErr
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		fetchErr.Err = fmt.Errorf("reading body: %w", TimeoutCause(ctx, err))
+		return FetchedPage{}, &fetchErr
+	}
+	if sniff {
+		mediaType, params, _ = mime.ParseMediaType(http.DetectContentType(body))
+		if accept != nil && !accept(mediaType) {
+			fetchErr.Err = fmt.Errorf("%w '%s', sniffed from the body", ErrUnsupportedContent, mediaType)
+			return FetchedPage{}, &fetchErr
+		}
+	}
+	slog.Debug("Page fetched", "url", url, "final_url", fetchErr.FinalUrl, "media_type", mediaType, "bytes", len(body))
+
+	page.Body = body
+	page.MediaType = mediaType
+	page.Charset = params["charset"]
+	page.ETag = res.Header.Get("ETag")
+	page.LastModified = res.Header.Get("Last-Modified")
+	return page, nil
+}
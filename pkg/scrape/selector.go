@@ -0,0 +1,324 @@
+package scrape
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// Selector is a CSS selector, of the subset needed to pick the parts of a
+// page: type and universal selectors, #id, .class and attribute selectors
+// ([attr], [attr=value], [attr~=value], [attr|=value], [attr^=value],
+// [attr$=value] and [attr*=value]), combined with the descendant and child
+// (>) combinators, and grouped with commas. Pseudo-classes are not supported.
+type Selector struct {
+	source string
+	// Complex selectors of the group, a node matching any of them
+	groups [][]selectorStep
+}
+
+// selectorStep is a compound selector of a complex selector, and how it
+// relates to the previous one: ' ' for a descendant, '>' for a child.
+type selectorStep struct {
+	combinator byte
+	tag        string
+	id         string
+	classes    []string
+	attributes []attributeSelector
+}
+
+type attributeSelector struct {
+	name     string
+	operator string
+	value    string
+}
+
+// parseSelector parses a CSS selector, failing on the syntax it does not
+// support.
+func parseSelector(source string) (Selector, error) {
+	parser := selectorParser{input: strings.TrimSpace(source)}
+	selector := Selector{source: source}
+	for {
+		steps, err := parser.complex()
+		if err != nil {
+			return Selector{}, fmt.Errorf("invalid selector '%s': %w", source, err)
+		}
+		selector.groups = append(selector.groups, steps)
+		parser.skipSpaces()
+		if parser.done() {
+			return selector, nil
+		}
+		if parser.peek() != ',' {
+			return Selector{}, fmt.Errorf("invalid selector '%s': unexpected '%c'", source, parser.peek())
+		}
+		parser.pos++
+		parser.skipSpaces()
+	}
+}
+
+func (s Selector) String() string {
+	return s.source
+}
+
+// matches tells whether an element matches the selector.
+func (s Selector) matches(n *html.Node) bool {
+	for _, steps := range s.groups {
+		if matchSteps(n, steps, len(steps)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectAll returns the elements of a node matching the selector, in
+// document order, the node included. With outermost, the matching elements
+// inside another matching element are left out.
+func (s Selector) selectAll(root *html.Node, outermost bool) []*html.Node {
+	var found []*html.Node
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && s.matches(n) {
+			found = append(found, n)
+			if outermost {
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+	traverse(root)
+	return found
+}
+
+// matchSteps matches an element against the steps of a complex selector, up
+// to the step i, from right to left.
+func matchSteps(n *html.Node, steps []selectorStep, i int) bool {
+	if !steps[i].matches(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	if steps[i].combinator == '>' {
+		return n.Parent != nil && matchSteps(n.Parent, steps, i-1)
+	}
+	for parent := n.Parent; parent != nil; parent = parent.Parent {
+		if matchSteps(parent, steps, i-1) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s selectorStep) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if s.tag != "" && s.tag != "*" && !strings.EqualFold(n.Data, s.tag) {
+		return false
+	}
+	if s.id != "" && htmlAttribute(n, "id") != s.id {
+		return false
+	}
+	classes := strings.Fields(htmlAttribute(n, "class"))
+	for _, class := range s.classes {
+		if !slices.Contains(classes, class) {
+			return false
+		}
+	}
+	for _, attribute := range s.attributes {
+		if !attribute.matches(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a attributeSelector) matches(n *html.Node) bool {
+	value, found := "", false
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, a.name) {
+			value, found = attr.Val, true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	switch a.operator {
+	case "":
+		return true
+	case "=":
+		return value == a.value
+	case "~=":
+		return slices.Contains(strings.Fields(value), a.value)
+	case "|=":
+		return value == a.value || strings.HasPrefix(value, a.value+"-")
+	case "^=":
+		return a.value != "" && strings.HasPrefix(value, a.value)
+	case "$=":
+		return a.value != "" && strings.HasSuffix(value, a.value)
+	case "*=":
+		return a.value != "" && strings.Contains(value, a.value)
+	}
+	return false
+}
+
+type selectorParser struct {
+	input string
+	pos   int
+}
+
+func (p *selectorParser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *selectorParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+// skipSpaces skips the whitespace, telling whether there was any.
+func (p *selectorParser) skipSpaces() bool {
+	start := p.pos
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\n') {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+// complex parses compound selectors separated by combinators, up to a comma
+// or the end.
+func (p *selectorParser) complex() ([]selectorStep, error) {
+	var steps []selectorStep
+	combinator := byte(' ')
+	for {
+		step, err := p.compound()
+		if err != nil {
+			return nil, err
+		}
+		step.combinator = combinator
+		steps = append(steps, step)
+
+		spaces := p.skipSpaces()
+		switch {
+		case p.done() || p.peek() == ',':
+			return steps, nil
+		case p.peek() == '>':
+			p.pos++
+			p.skipSpaces()
+			combinator = '>'
+		case p.peek() == '+' || p.peek() == '~':
+			return nil, fmt.Errorf("sibling combinator '%c' not supported", p.peek())
+		case spaces:
+			combinator = ' '
+		default:
+			return nil, fmt.Errorf("unexpected '%c'", p.peek())
+		}
+	}
+}
+
+func (p *selectorParser) compound() (selectorStep, error) {
+	var step selectorStep
+	if p.peek() == '*' {
+		p.pos++
+		step.tag = "*"
+	} else if isIdentifierByte(p.peek()) {
+		step.tag = strings.ToLower(p.identifier())
+	}
+	for !p.done() {
+		switch p.peek() {
+		case '#':
+			p.pos++
+			if step.id = p.identifier(); step.id == "" {
+				return step, fmt.Errorf("expected an id after '#'")
+			}
+		case '.':
+			p.pos++
+			class := p.identifier()
+			if class == "" {
+				return step, fmt.Errorf("expected a class after '.'")
+			}
+			step.classes = append(step.classes, class)
+		case '[':
+			p.pos++
+			attribute, err := p.attribute()
+			if err != nil {
+				return step, err
+			}
+			step.attributes = append(step.attributes, attribute)
+		case ':':
+			return step, fmt.Errorf("pseudo-classes not supported")
+		default:
+			if step.tag == "" && step.id == "" && len(step.classes) == 0 && len(step.attributes) == 0 {
+				return step, fmt.Errorf("expected a selector at '%s'", p.input[p.pos:])
+			}
+			return step, nil
+		}
+	}
+	if step.tag == "" && step.id == "" && len(step.classes) == 0 && len(step.attributes) == 0 {
+		return step, fmt.Errorf("empty selector")
+	}
+	return step, nil
+}
+
+// attribute parses an attribute selector, after its opening bracket.
+func (p *selectorParser) attribute() (attributeSelector, error) {
+	p.skipSpaces()
+	attribute := attributeSelector{name: p.identifier()}
+	if attribute.name == "" {
+		return attribute, fmt.Errorf("expected an attribute name after '['")
+	}
+	p.skipSpaces()
+	if p.peek() == ']' {
+		p.pos++
+		return attribute, nil
+	}
+	for _, operator := range []string{"=", "~=", "|=", "^=", "$=", "*="} {
+		if strings.HasPrefix(p.input[p.pos:], operator) {
+			attribute.operator = operator
+			p.pos += len(operator)
+			break
+		}
+	}
+	if attribute.operator == "" {
+		return attribute, fmt.Errorf("unexpected '%c' in attribute selector", p.peek())
+	}
+	p.skipSpaces()
+	if quote := p.peek(); quote == '"' || quote == '\'' {
+		end := strings.IndexByte(p.input[p.pos+1:], quote)
+		if end < 0 {
+			return attribute, fmt.Errorf("unterminated string in attribute selector")
+		}
+		attribute.value = p.input[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+	} else {
+		attribute.value = p.identifier()
+	}
+	p.skipSpaces()
+	if p.peek() != ']' {
+		return attribute, fmt.Errorf("expected ']' to close the attribute selector")
+	}
+	p.pos++
+	return attribute, nil
+}
+
+func (p *selectorParser) identifier() string {
+	start := p.pos
+	for !p.done() && isIdentifierByte(p.peek()) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+// isIdentifierByte tells whether a byte can be part of a CSS identifier, the
+// bytes of the non ASCII characters included.
+func isIdentifierByte(b byte) bool {
+	return b >= 0x80 || b == '-' || b == '_' || unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b))
+}
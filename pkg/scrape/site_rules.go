@@ -0,0 +1,139 @@
+package scrape
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// SiteRule picks the title and the content of the pages of a site whose
+// markup the generic extractor gets wrong, with CSS selectors.
+type SiteRule struct {
+	// Domain of the site, its subdomains included
+	Domain  string
+	Title   *Selector
+	Content *Selector
+	// Elements removed before extracting the page, e.g. ads or newsletter
+	// signups
+	Strip []Selector
+}
+
+// CompileSiteRules parses the selectors of the site rules of the config file,
+// the most specific domains first.
+func CompileSiteRules(sites map[string]SiteConfig) ([]SiteRule, error) {
+	var rules []SiteRule
+	for domain, site := range sites {
+		rule := SiteRule{Domain: strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")}
+		if rule.Domain == "" || strings.ContainsAny(rule.Domain, "/:") {
+			return nil, fmt.Errorf("invalid site '%s', expected a domain such as example.com", domain)
+		}
+		for _, field := range []struct {
+			source   string
+			selector **Selector
+		}{{site.Title, &rule.Title}, {site.Content, &rule.Content}} {
+			if field.source == "" {
+				continue
+			}
+			selector, err := parseSelector(field.source)
+			if err != nil {
+				return nil, fmt.Errorf("site '%s': %w", domain, err)
+			}
+			*field.selector = &selector
+		}
+		for _, source := range site.Strip {
+			selector, err := parseSelector(source)
+			if err != nil {
+				return nil, fmt.Errorf("site '%s': %w", domain, err)
+			}
+			rule.Strip = append(rule.Strip, selector)
+		}
+		if rule.Title == nil && rule.Content == nil && len(rule.Strip) == 0 {
+			return nil, fmt.Errorf("site '%s' has no title, content or strip selector", domain)
+		}
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if len(rules[i].Domain) != len(rules[j].Domain) {
+			return len(rules[i].Domain) > len(rules[j].Domain)
+		}
+		return rules[i].Domain < rules[j].Domain
+	})
+	return rules, nil
+}
+
+// siteRuleFor returns the rule of the site of an url, the rule of the most
+// specific domain when several match, the rules being sorted as
+// CompileSiteRules does.
+func siteRuleFor(rules []SiteRule, pageUrl string) (SiteRule, bool) {
+	parsed, err := url.Parse(pageUrl)
+	if err != nil {
+		return SiteRule{}, false
+	}
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	for _, rule := range rules {
+		if host == rule.Domain || strings.HasSuffix(host, "."+rule.Domain) {
+			return rule, true
+		}
+	}
+	return SiteRule{}, false
+}
+
+// apply rewrites a page with the rule: the stripped elements are removed,
+// and the body only holds the content, in an article element, so that the
+// text, the images, the tables and the markdown of the article are read from
+// it. The head is kept, for the metadata. It also returns the title picked
+// by the rule, empty when the rule has none or it matches nothing, the
+// generic extractor reading it then.
+func (r SiteRule) apply(pageUrl, page string) (string, string) {
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		return page, ""
+	}
+
+	for _, strip := range r.Strip {
+		for _, n := range strip.selectAll(doc, true) {
+			if n.Parent != nil {
+				n.Parent.RemoveChild(n)
+			}
+		}
+	}
+
+	title := ""
+	if r.Title != nil {
+		if matches := r.Title.selectAll(doc, true); len(matches) > 0 {
+			title = nodeText(matches[0])
+		}
+		if title == "" {
+			slog.Warn("title selector of the site matches nothing, using the generic extractor", "url", pageUrl, "selector", r.Title.String())
+		}
+	}
+
+	body := findElement(doc, "body")
+	if r.Content != nil && body != nil {
+		content := r.Content.selectAll(doc, true)
+		if len(content) == 0 {
+			slog.Warn("content selector of the site matches nothing, using the generic extractor", "url", pageUrl, "selector", r.Content.String())
+		} else {
+			article := &html.Node{Type: html.ElementNode, Data: "article"}
+			for _, n := range content {
+				n.Parent.RemoveChild(n)
+				article.AppendChild(n)
+			}
+			for body.FirstChild != nil {
+				body.RemoveChild(body.FirstChild)
+			}
+			body.AppendChild(article)
+		}
+	}
+
+	var sb strings.Builder
+	if err := html.Render(&sb, doc); err != nil {
+		return page, title
+	}
+	slog.Debug("Site rule applied", "url", pageUrl, "domain", r.Domain)
+	return sb.String(), title
+}
@@ -0,0 +1,259 @@
+package scrape
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// Tables kept per article, in the order of the page
+	MAX_DATA_TABLES = 5
+	// Rows kept per table, without the header, the others being counted
+	MAX_TABLE_ROWS = 30
+	// Columns spanned by a cell at most, larger colspans being mistakes
+	MAX_TABLE_COLSPAN = 20
+)
+
+// A data table holds figures, unlike the tables laying out a page
+var tableFigureRegex = regexp.MustCompile(`\d`)
+
+// DataTable is a table of figures of an article, e.g. the data of a chart, kept
+// as is in the reports (see -tables).
+type DataTable struct {
+	Caption string     `json:"caption,omitempty"`
+	Header  []string   `json:"header"`
+	Rows    [][]string `json:"rows"`
+	// Rows left out past MAX_TABLE_ROWS
+	MoreRows int `json:"more_rows,omitempty"`
+}
+
+// Markdown returns the table as a markdown table, followed by the number of
+// rows left out if any.
+func (t DataTable) Markdown() string {
+	var sb strings.Builder
+	writeRow := func(cells []string) {
+		sb.WriteString("|")
+		for _, cell := range cells {
+			sb.WriteString(" " + strings.ReplaceAll(cell, "|", `\|`) + " |")
+		}
+		sb.WriteString("\n")
+	}
+
+	writeRow(t.Header)
+	sb.WriteString(strings.Repeat("| --- ", len(t.Header)) + "|\n")
+	for _, row := range t.Rows {
+		writeRow(row)
+	}
+	if t.MoreRows > 0 {
+		fmt.Fprintf(&sb, "\n*%d more rows*\n", t.MoreRows)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// articleTables returns the data tables of the body of a page: the tables of
+// at least two columns and two rows holding figures, including the tables
+// giving the data of the charts to screen readers, without the tables laying
+// out the page.
+func articleTables(page string) []DataTable {
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		return nil
+	}
+
+	var tables []DataTable
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if len(tables) == MAX_DATA_TABLES {
+			return
+		}
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "head", "nav", "header", "footer", "aside", "script", "style":
+				return
+			case "table":
+				// The tables of a layout table are looked at on their own
+				if !hasDescendant(n, "table") {
+					if table, ok := dataTable(n); ok {
+						tables = append(tables, table)
+					}
+					return
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+	traverse(doc)
+	return tables
+}
+
+// dataTable reads a table without nested tables, and tells whether it is a
+// data table.
+func dataTable(n *html.Node) (DataTable, bool) {
+	if role := htmlAttribute(n, "role"); role == "presentation" || role == "none" {
+		return DataTable{}, false
+	}
+	table, ok := readTable(n)
+	if !ok {
+		return DataTable{}, false
+	}
+	hasFigures := slices.ContainsFunc(table.Rows, func(row []string) bool {
+		return tableFigureRegex.MatchString(strings.Join(row, " "))
+	})
+	if !hasFigures {
+		return DataTable{}, false
+	}
+
+	table.Caption = tableCaption(n)
+	if len(table.Rows) > MAX_TABLE_ROWS {
+		table.Rows, table.MoreRows = table.Rows[:MAX_TABLE_ROWS], len(table.Rows)-MAX_TABLE_ROWS
+	}
+	return table, true
+}
+
+// readTable reads the header and the rows of a table without nested tables,
+// and tells whether it has at least two columns and a row under its header.
+func readTable(n *html.Node) (DataTable, bool) {
+	var rows [][]string
+	var headerRows int
+	var traverse func(*html.Node)
+	traverse = func(c *html.Node) {
+		if c.Type == html.ElementNode && c.Data == "tr" {
+			row, isHeader := tableRow(c)
+			if len(row) > 0 {
+				// Only the first rows can be the header
+				if isHeader && headerRows == len(rows) {
+					headerRows++
+				}
+				rows = append(rows, row)
+			}
+			return
+		}
+		for child := c.FirstChild; child != nil; child = child.NextSibling {
+			traverse(child)
+		}
+	}
+	traverse(n)
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns < 2 || len(rows) < 2 {
+		return DataTable{}, false
+	}
+	for i := range rows {
+		for len(rows[i]) < columns {
+			rows[i] = append(rows[i], "")
+		}
+	}
+
+	// The first row is the header of the tables without one, markdown tables
+	// needing one
+	header := rows[0]
+	if headerRows > 1 {
+		header = mergeHeaderRows(rows[:headerRows])
+	}
+	body := rows[max(1, headerRows):]
+	if len(body) == 0 {
+		return DataTable{}, false
+	}
+	return DataTable{Header: header, Rows: body}, true
+}
+
+// tableRow returns the text of the cells of a row, a cell spanning several
+// columns being followed by empty cells, and whether it is a header row, made
+// of th cells only.
+func tableRow(tr *html.Node) ([]string, bool) {
+	var cells []string
+	isHeader := true
+	for c := tr.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || (c.Data != "td" && c.Data != "th") {
+			continue
+		}
+		if c.Data == "td" {
+			isHeader = false
+		}
+		cells = append(cells, nodeText(c))
+		span, _ := strconv.Atoi(htmlAttribute(c, "colspan"))
+		for i := 1; i < min(span, MAX_TABLE_COLSPAN); i++ {
+			cells = append(cells, "")
+		}
+	}
+	return cells, isHeader && len(cells) > 0
+}
+
+// mergeHeaderRows merges the rows of a header of several rows, e.g. a group
+// of columns above their names, into one.
+func mergeHeaderRows(rows [][]string) []string {
+	header := make([]string, len(rows[0]))
+	for column := range header {
+		var names []string
+		for _, row := range rows {
+			if row[column] != "" {
+				names = append(names, row[column])
+			}
+		}
+		header[column] = strings.Join(names, " ")
+	}
+	return header
+}
+
+// tableCaption returns the caption of a table, else the caption of the figure
+// holding it, else its label.
+func tableCaption(table *html.Node) string {
+	for c := table.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == "caption" {
+			return nodeText(c)
+		}
+	}
+	for parent := table.Parent; parent != nil; parent = parent.Parent {
+		if parent.Type != html.ElementNode || parent.Data != "figure" {
+			continue
+		}
+		for c := parent.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.Data == "figcaption" {
+				return nodeText(c)
+			}
+		}
+		break
+	}
+	return strings.Join(strings.Fields(htmlAttribute(table, "aria-label")), " ")
+}
+
+// nodeText returns the text of a node, its whitespace collapsed.
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var traverse func(*html.Node)
+	traverse = func(c *html.Node) {
+		switch {
+		case c.Type == html.TextNode:
+			sb.WriteString(c.Data)
+		case c.Type == html.ElementNode && (c.Data == "script" || c.Data == "style"):
+			return
+		case c.Type == html.ElementNode && c.Data == "br":
+			sb.WriteString(" ")
+		}
+		for child := c.FirstChild; child != nil; child = child.NextSibling {
+			traverse(child)
+		}
+	}
+	traverse(n)
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// hasDescendant tells whether a node holds an element of a tag.
+func hasDescendant(n *html.Node, tag string) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if (c.Type == html.ElementNode && c.Data == tag) || hasDescendant(c, tag) {
+			return true
+		}
+	}
+	return false
+}
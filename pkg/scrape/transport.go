@@ -0,0 +1,33 @@
+package scrape
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// LoggingTransport logs the HTTP requests at the debug level, with the
+// status and the duration of their response. Requests are carried out by
+// Next, the default transport when nil.
+type LoggingTransport struct {
+	Next http.RoundTripper
+}
+
+func (t LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if !slog.Default().Enabled(req.Context(), slog.LevelDebug) {
+		return next.RoundTrip(req)
+	}
+
+	started := time.Now()
+	res, err := next.RoundTrip(req)
+	if err != nil {
+		slog.Debug("HTTP request failed", "method", req.Method, "url", req.URL.Redacted(), "duration", time.Since(started), "err", err)
+		return nil, err
+	}
+	slog.Debug("HTTP request", "method", req.Method, "url", req.URL.Redacted(), "status", res.StatusCode, "content_type", res.Header.Get("Content-Type"), "duration", time.Since(started))
+	return res, nil
+}
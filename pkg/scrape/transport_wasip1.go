@@ -0,0 +1,106 @@
+//go:build wasip1
+
+package scrape
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"unsafe"
+)
+
+// WASI has no sockets: under WebAssembly, the HTTP requests (page fetches and
+// LLM calls) are delegated to the host through the functions it exports in the
+// "report" module. The host receives the request as JSON, carries it out and
+// keeps the JSON response until the guest copies it:
+//
+//	http_request(request_ptr, request_len) -> response_len, or -1 on failure
+//	http_response(buffer_ptr, buffer_len) -> number of bytes copied
+//
+// See HostHttpRequest and HostHttpResponse for the JSON documents.
+
+//go:wasmimport report http_request
+//go:noescape
+func hostHttpRequest(request unsafe.Pointer, requestLen uint32) int32
+
+//go:wasmimport report http_response
+//go:noescape
+func hostHttpResponse(buffer unsafe.Pointer, bufferLen uint32) int32
+
+// HostHttpRequest is the request sent to the host, the body being base64
+// encoded.
+type HostHttpRequest struct {
+	Method string      `json:"method"`
+	Url    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// HostHttpResponse is the response of the host, the body being base64
+// encoded. Error is set when no response could be obtained at all, such as on
+// a network error, in which case the other fields are ignored.
+type HostHttpResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// HostTransport carries out the HTTP requests through the host. Redirects are
+// followed by the HTTP client, so the host should not follow them itself.
+type HostTransport struct{}
+
+func init() {
+	http.DefaultTransport = HostTransport{}
+}
+
+func (HostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	hostRequest := HostHttpRequest{Method: req.Method, Url: req.URL.String(), Header: req.Header}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading request body: %w", err)
+		}
+		hostRequest.Body = body
+	}
+
+	request, err := json.Marshal(hostRequest)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling host request: %w", err)
+	}
+
+	responseLen := hostHttpRequest(unsafe.Pointer(&request[0]), uint32(len(request)))
+	if responseLen <= 0 {
+		return nil, fmt.Errorf("host could not carry out the request")
+	}
+	response := make([]byte, responseLen)
+	if copied := hostHttpResponse(unsafe.Pointer(&response[0]), uint32(len(response))); copied != responseLen {
+		return nil, fmt.Errorf("host returned %d bytes of a %d bytes response", copied, responseLen)
+	}
+
+	var hostResponse HostHttpResponse
+	if err := json.Unmarshal(response, &hostResponse); err != nil {
+		return nil, fmt.Errorf("unmarshaling host response: %w", err)
+	}
+	if hostResponse.Error != "" {
+		return nil, fmt.Errorf("host request failed: %s", hostResponse.Error)
+	}
+	if hostResponse.Header == nil {
+		hostResponse.Header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", hostResponse.StatusCode, http.StatusText(hostResponse.StatusCode)),
+		StatusCode:    hostResponse.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        hostResponse.Header,
+		Body:          io.NopCloser(bytes.NewReader(hostResponse.Body)),
+		ContentLength: int64(len(hostResponse.Body)),
+		Request:       req,
+	}, nil
+}
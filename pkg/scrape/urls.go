@@ -0,0 +1,45 @@
+package scrape
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Query parameters that only track where a visitor comes from, they are
+// dropped from canonical urls.
+var trackingQueryParams = []string{"fbclid", "gclid", "mc_cid", "mc_eid"}
+
+// CanonicalUrl normalizes an url so that the usual variants of an article url
+// (case of the host, default port, fragment, tracking parameters, order of
+// the query parameters) share the same key.
+func CanonicalUrl(articleUrl string) string {
+	parsed, err := url.Parse(strings.TrimSpace(articleUrl))
+	if err != nil || parsed.Host == "" {
+		return strings.TrimSpace(articleUrl)
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	if (parsed.Scheme == "http" && parsed.Port() == "80") || (parsed.Scheme == "https" && parsed.Port() == "443") {
+		parsed.Host = parsed.Hostname()
+	}
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+
+	query := parsed.Query()
+	for name := range query {
+		if strings.HasPrefix(name, "utm_") {
+			query.Del(name)
+		}
+	}
+	for _, name := range trackingQueryParams {
+		query.Del(name)
+	}
+	// Encode sorts the parameters by name
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
@@ -0,0 +1,184 @@
+package scrape
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pages bigger than this are left out of the archives, as binary files served
+// as HTML rather than articles.
+const MAX_ARCHIVED_PAGE_SIZE = 20 * 1024 * 1024
+
+// ArchivedPage is a page read from a web archive, summarized without fetching
+// its url.
+type ArchivedPage struct {
+	Url  string
+	Html string
+	// Date the page was archived
+	Archived time.Time
+	// Url of the archived copy, e.g. its ArchiveBox snapshot, empty when the
+	// copy only exists in a file
+	ArchiveUrl string
+}
+
+// ExtractArchivedPage extracts the article of an archived page. Like for
+// the Wayback Machine, crawlers are usually served the whole article,
+// whatever the paywall markers of the page say.
+func (s *Scraper) ExtractArchivedPage(p ArchivedPage) (Article, error) {
+	article, err := s.extractPageArticle(p.Url, p.Html)
+	if err != nil {
+		return Article{}, err
+	}
+	article.ArchiveUrl = p.ArchiveUrl
+	article.Archived = p.Archived
+	return article, nil
+}
+
+// ReadWarcFile returns the HTML pages of a WARC file, compressed or not,
+// whose url matches match when it is not nil.
+func ReadWarcFile(path string, match *regexp.Regexp) ([]ArchivedPage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening WARC file: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	// A compressed WARC file is a series of gzip members, one per record
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("reading WARC file '%s': %w", path, err)
+		}
+		defer gzipReader.Close()
+		reader = bufio.NewReader(gzipReader)
+	}
+
+	pages, err := readWarcPages(reader, match)
+	if err != nil {
+		return nil, fmt.Errorf("reading WARC file '%s': %w", path, err)
+	}
+	return pages, nil
+}
+
+// readWarcPages reads the records of a WARC file and returns the HTML pages
+// of its response records answered with 200 OK, and of its resource records.
+func readWarcPages(reader *bufio.Reader, match *regexp.Regexp) ([]ArchivedPage, error) {
+	textReader := textproto.NewReader(reader)
+	var pages []ArchivedPage
+	for {
+		// Records are separated by empty lines
+		line, err := textReader.ReadLine()
+		if errors.Is(err, io.EOF) {
+			return pages, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "WARC/") {
+			return nil, fmt.Errorf("expected a WARC record, got '%s'", line)
+		}
+
+		header, err := textReader.ReadMIMEHeader()
+		if err != nil {
+			return nil, fmt.Errorf("reading WARC record header: %w", err)
+		}
+		length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("invalid WARC record length '%s'", header.Get("Content-Length"))
+		}
+		block := io.LimitReader(reader, length)
+
+		// A damaged or unsupported record does not prevent reading the others
+		page, ok, err := readWarcPage(header, block, match)
+		if err != nil {
+			slog.Warn("skipping WARC record", "err", err)
+		}
+		if ok {
+			pages = append(pages, page)
+		}
+		if _, err := io.Copy(io.Discard, block); err != nil {
+			return nil, fmt.Errorf("reading WARC record: %w", err)
+		}
+	}
+}
+
+// readWarcPage returns the HTML page of a WARC record, false if the record
+// holds no page or a page that does not match.
+func readWarcPage(header textproto.MIMEHeader, block io.Reader, match *regexp.Regexp) (ArchivedPage, bool, error) {
+	recordType := header.Get("WARC-Type")
+	// Some writers put the uri between angle brackets, as in the WARC 1.0
+	// examples
+	pageUrl := strings.Trim(header.Get("WARC-Target-URI"), "<>")
+	if recordType != "response" && recordType != "resource" {
+		return ArchivedPage{}, false, nil
+	}
+	if !strings.HasPrefix(pageUrl, "http://") && !strings.HasPrefix(pageUrl, "https://") {
+		return ArchivedPage{}, false, nil
+	}
+	if match != nil && !match.MatchString(pageUrl) {
+		return ArchivedPage{}, false, nil
+	}
+	archived, _ := time.Parse(time.RFC3339, header.Get("WARC-Date"))
+
+	contentType := header.Get("Content-Type")
+	var body io.Reader = block
+	if recordType == "response" {
+		// The block of a response is the HTTP response, headers included
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if mediaType != "application/http" {
+			return ArchivedPage{}, false, nil
+		}
+		res, err := http.ReadResponse(bufio.NewReader(block), nil)
+		if err != nil {
+			return ArchivedPage{}, false, fmt.Errorf("reading the HTTP response of %s: %w", pageUrl, err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return ArchivedPage{}, false, nil
+		}
+		contentType = res.Header.Get("Content-Type")
+		body = res.Body
+		switch encoding := strings.ToLower(res.Header.Get("Content-Encoding")); encoding {
+		case "", "identity":
+		case "gzip":
+			gzipReader, err := gzip.NewReader(res.Body)
+			if err != nil {
+				return ArchivedPage{}, false, fmt.Errorf("decompressing the page of %s: %w", pageUrl, err)
+			}
+			defer gzipReader.Close()
+			body = gzipReader
+		default:
+			return ArchivedPage{}, false, fmt.Errorf("unsupported encoding '%s' of the page of %s", encoding, pageUrl)
+		}
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return ArchivedPage{}, false, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(body, MAX_ARCHIVED_PAGE_SIZE+1))
+	if err != nil {
+		return ArchivedPage{}, false, fmt.Errorf("reading the page of %s: %w", pageUrl, err)
+	}
+	if len(data) > MAX_ARCHIVED_PAGE_SIZE || len(bytes.TrimSpace(data)) == 0 {
+		return ArchivedPage{}, false, nil
+	}
+	return ArchivedPage{Url: pageUrl, Html: decodeCharset(data, params["charset"]), Archived: archived}, true, nil
+}
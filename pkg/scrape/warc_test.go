@@ -0,0 +1,180 @@
+package scrape
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// warcRecord is a record of the WARC files of the tests.
+type warcRecord struct {
+	Type        string
+	Url         string
+	Date        time.Time
+	ContentType string
+	Block       string
+}
+
+func (r warcRecord) bytes() []byte {
+	var sb strings.Builder
+	sb.WriteString("WARC/1.0\r\n")
+	fmt.Fprintf(&sb, "WARC-Type: %s\r\n", r.Type)
+	fmt.Fprintf(&sb, "WARC-Target-URI: %s\r\n", r.Url)
+	fmt.Fprintf(&sb, "WARC-Date: %s\r\n", r.Date.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&sb, "WARC-Record-ID: <urn:uuid:%s>\r\n", uuidOf(r.Type+r.Url))
+	fmt.Fprintf(&sb, "Content-Type: %s\r\n", r.ContentType)
+	fmt.Fprintf(&sb, "Content-Length: %d\r\n", len(r.Block))
+	sb.WriteString("\r\n")
+	sb.WriteString(r.Block)
+	sb.WriteString("\r\n\r\n")
+	return []byte(sb.String())
+}
+
+// uuidOf returns a UUID made of the MD5 hash of a text, the records of the
+// tests not needing random ones.
+func uuidOf(text string) string {
+	sum := md5.Sum([]byte(text))
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[:4], sum[4:6], sum[6:8], sum[8:10], sum[10:])
+}
+
+func httpResponse(status, contentType, body string) string {
+	return fmt.Sprintf("HTTP/1.1 %s\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n%s", status, contentType, len(body), body)
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadWarcPages(t *testing.T) {
+	archived := time.Date(2024, time.May, 2, 14, 0, 0, 0, time.UTC)
+	article := "<html><head><title>An article</title></head><body><p>Text</p></body></html>"
+	tests := []struct {
+		name    string
+		records []warcRecord
+		match   *regexp.Regexp
+		want    []ArchivedPage
+	}{
+		{
+			name: "response",
+			records: []warcRecord{
+				{"warcinfo", "", archived, "application/warc-fields", "software: test\r\n"},
+				{"request", "https://example.com/a", archived, "application/http; msgtype=request", "GET /a HTTP/1.1\r\nHost: example.com\r\n\r\n"},
+				{"response", "https://example.com/a", archived, "application/http; msgtype=response", httpResponse("200 OK", "text/html; charset=utf-8", article)},
+			},
+			want: []ArchivedPage{{Url: "https://example.com/a", Html: article, Archived: archived}},
+		},
+		{
+			name: "resource with bracketed url",
+			records: []warcRecord{
+				{"resource", "<https://example.com/b>", archived, "text/html", article},
+			},
+			want: []ArchivedPage{{Url: "https://example.com/b", Html: article, Archived: archived}},
+		},
+		{
+			name: "latin-1 page",
+			records: []warcRecord{
+				{"response", "https://example.com/c", archived, "application/http", httpResponse("200 OK", "text/html; charset=iso-8859-1", "caf\xe9 \x80")},
+			},
+			want: []ArchivedPage{{Url: "https://example.com/c", Html: "café €", Archived: archived}},
+		},
+		{
+			name: "skipped records",
+			records: []warcRecord{
+				{"response", "https://example.com/missing", archived, "application/http", httpResponse("404 Not Found", "text/html", article)},
+				{"response", "https://example.com/image.png", archived, "application/http", httpResponse("200 OK", "image/png", "\x89PNG")},
+				{"response", "https://example.com/empty", archived, "application/http", httpResponse("200 OK", "text/html", "  \n")},
+				{"response", "dns:example.com", archived, "text/dns", "example.com. 300 IN A 93.184.216.34"},
+				{"metadata", "https://example.com/a", archived, "application/warc-fields", "via: https://example.com/\r\n"},
+			},
+		},
+		{
+			name: "matching urls",
+			records: []warcRecord{
+				{"response", "https://example.com/blog/1", archived, "application/http", httpResponse("200 OK", "text/html", article)},
+				{"response", "https://example.com/about", archived, "application/http", httpResponse("200 OK", "text/html", article)},
+			},
+			match: regexp.MustCompile(`/blog/`),
+			want:  []ArchivedPage{{Url: "https://example.com/blog/1", Html: article, Archived: archived}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var data []byte
+			for _, record := range test.records {
+				data = append(data, record.bytes()...)
+			}
+			pages, err := readWarcPages(bufio.NewReader(bytes.NewReader(data)), test.match)
+			if err != nil {
+				t.Fatalf("readWarcPages: %v", err)
+			}
+			if len(pages) != len(test.want) {
+				t.Fatalf("readWarcPages = %d pages, want %d: %+v", len(pages), len(test.want), pages)
+			}
+			for i, page := range pages {
+				if page != test.want[i] {
+					t.Errorf("page %d = %+v, want %+v", i, page, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadWarcFileCompressed(t *testing.T) {
+	archived := time.Date(2024, time.May, 2, 14, 0, 0, 0, time.UTC)
+	records := []warcRecord{
+		{"response", "https://example.com/a", archived, "application/http", httpResponse("200 OK", "text/html", "<p>A</p>")},
+		{"response", "https://example.com/b", archived, "application/http", httpResponse("200 OK", "text/html", "<p>B</p>")},
+	}
+	// A compressed WARC file is a gzip member per record
+	var data []byte
+	for _, record := range records {
+		data = append(data, gzipped(t, record.bytes())...)
+	}
+	path := filepath.Join(t.TempDir(), "crawl.warc.gz")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := ReadWarcFile(path, nil)
+	if err != nil {
+		t.Fatalf("readWarcFile: %v", err)
+	}
+	if len(pages) != 2 || pages[0].Html != "<p>A</p>" || pages[1].Html != "<p>B</p>" {
+		t.Errorf("readWarcFile = %+v, want the pages A and B", pages)
+	}
+}
+
+func TestReadWarcPagesInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not a record", "HTTP/1.1 200 OK\r\n\r\n"},
+		{"no length", "WARC/1.0\r\nWARC-Type: resource\r\n\r\n"},
+		{"negative length", "WARC/1.0\r\nWARC-Type: resource\r\nContent-Length: -1\r\n\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if pages, err := readWarcPages(bufio.NewReader(strings.NewReader(test.data)), nil); err == nil {
+				t.Errorf("readWarcPages = %+v, want an error", pages)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package scrape
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Fetch the Wayback Machine copy of the pages that are gone or paywalled
+	ARCHIVE_AUTO = "auto"
+	// Always summarize the Wayback Machine copy of the pages
+	ARCHIVE_ALWAYS = "always"
+	ARCHIVE_NEVER  = "never"
+	// Availability API of the Wayback Machine, giving the latest snapshot of
+	// an url
+	WAYBACK_AVAILABILITY_URL = "https://archive.org/wayback/available"
+	// Layout of the timestamps of the Wayback Machine snapshots
+	WAYBACK_TIMESTAMP_FORMAT = "20060102150405"
+)
+
+// Statuses of the pages that are gone, or whose content is refused without
+// an account, worth looking up in the Wayback Machine.
+var archivedStatusCodes = []int{
+	http.StatusUnauthorized,
+	http.StatusPaymentRequired,
+	http.StatusForbidden,
+	http.StatusNotFound,
+	http.StatusGone,
+	http.StatusUnavailableForLegalReasons,
+}
+
+type WaybackAvailability struct {
+	ArchivedSnapshots struct {
+		Closest *struct {
+			Available bool   `json:"available"`
+			Url       string `json:"url"`
+			Timestamp string `json:"timestamp"`
+			Status    string `json:"status"`
+		} `json:"closest"`
+	} `json:"archived_snapshots"`
+}
+
+// WaybackSnapshot is a copy of a page archived by the Wayback Machine.
+type WaybackSnapshot struct {
+	// Url of the snapshot, shown with the Wayback Machine toolbar
+	Url      string
+	Archived time.Time
+}
+
+// rawUrl returns the url of the page as archived, without the toolbar and the
+// rewriting of its links.
+func (s WaybackSnapshot) rawUrl() string {
+	timestamp := s.Archived.Format(WAYBACK_TIMESTAMP_FORMAT)
+	return strings.Replace(s.Url, "/web/"+timestamp+"/", "/web/"+timestamp+"id_/", 1)
+}
+
+func validateArchiveMode(mode string) error {
+	if mode != ARCHIVE_AUTO && mode != ARCHIVE_ALWAYS && mode != ARCHIVE_NEVER {
+		return fmt.Errorf("unknown archive mode '%s', expected %s, %s or %s", mode, ARCHIVE_AUTO, ARCHIVE_ALWAYS, ARCHIVE_NEVER)
+	}
+	return nil
+}
+
+// needsArchive tells if the failure to scrape a page may be worked around
+// with its Wayback Machine copy: the page is gone, its host does not exist
+// anymore, or it is paywalled.
+func needsArchive(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrPaywalled) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return true
+	}
+	var fetchErr *FetchError
+	if errors.As(err, &fetchErr) {
+		for _, statusCode := range archivedStatusCodes {
+			if fetchErr.StatusCode == statusCode {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findWaybackSnapshot returns the latest snapshot of an url in the Wayback
+// Machine.
+func (s *Scraper) findWaybackSnapshot(ctx context.Context, articleUrl string) (WaybackSnapshot, error) {
+	ctx, cancel := WithTimeout(ctx, s.Policy.Timeout, "Wayback Machine lookup")
+	defer cancel()
+
+	apiUrl := WAYBACK_AVAILABILITY_URL + "?url=" + url.QueryEscape(articleUrl)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiUrl, nil)
+	if err != nil {
+		return WaybackSnapshot{}, &FetchError{Url: apiUrl, Err: err}
+	}
+	s.Credentials.Identify(req)
+	res, err := s.httpClient().Do(req)
+	if err != nil {
+		return WaybackSnapshot{}, &FetchError{Url: apiUrl, Err: TimeoutCause(ctx, err)}
+	}
+	defer res.Body.Close()
+
+	fetchErr := FetchError{Url: apiUrl, StatusCode: res.StatusCode, Status: res.Status, RetryAfter: ParseRetryAfter(res.Header.Get("Retry-After"))}
+	if res.StatusCode >= 400 {
+		fetchErr.Err = fmt.Errorf("unexpected response status")
+		return WaybackSnapshot{}, &fetchErr
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		fetchErr.Err = fmt.Errorf("reading body: %w", TimeoutCause(ctx, err))
+		return WaybackSnapshot{}, &fetchErr
+	}
+
+	var availability WaybackAvailability
+	if err := json.Unmarshal(body, &availability); err != nil {
+		return WaybackSnapshot{}, fmt.Errorf("unmarshaling Wayback Machine availability: %w", err)
+	}
+	closest := availability.ArchivedSnapshots.Closest
+	if closest == nil || !closest.Available || closest.Url == "" {
+		return WaybackSnapshot{}, fmt.Errorf("no Wayback Machine snapshot of '%s'", articleUrl)
+	}
+	archived, err := time.Parse(WAYBACK_TIMESTAMP_FORMAT, closest.Timestamp)
+	if err != nil {
+		return WaybackSnapshot{}, fmt.Errorf("parsing snapshot timestamp '%s': %w", closest.Timestamp, err)
+	}
+
+	// The API answers with http urls
+	snapshotUrl := strings.Replace(closest.Url, "http://web.archive.org/", "https://web.archive.org/", 1)
+	return WaybackSnapshot{Url: snapshotUrl, Archived: archived}, nil
+}
+
+// scrapeArchivedArticle scrapes the latest Wayback Machine snapshot of an
+// article. The article keeps its url, the snapshot being recorded apart.
+func (s *Scraper) scrapeArchivedArticle(ctx context.Context, articleUrl string) (Article, error) {
+	var snapshot WaybackSnapshot
+	err := s.Retry.Do(ctx, "looking up "+articleUrl+" in the Wayback Machine", func() error {
+		var err error
+		snapshot, err = s.findWaybackSnapshot(ctx, articleUrl)
+		return err
+	})
+	if err != nil {
+		return Article{}, err
+	}
+
+	var page string
+	err = s.Retry.Do(ctx, "fetching "+snapshot.Url, func() error {
+		var err error
+		// The credentials of the user are for the site, not the archive
+		page, err = s.fetchPage(ctx, snapshot.rawUrl(), s.Credentials.Identify)
+		return err
+	})
+	if err != nil {
+		return Article{}, err
+	}
+
+	// Crawlers are usually served the whole article, whatever the paywall
+	// markers of the page say
+	article, err := s.extractPageArticle(articleUrl, page)
+	if err != nil {
+		return Article{}, err
+	}
+	article.ArchiveUrl = snapshot.Url
+	article.Archived = snapshot.Archived
+	return article, nil
+}
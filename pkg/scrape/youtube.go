@@ -0,0 +1,324 @@
+package scrape
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// A paragraph of the transcript, headed by its timestamp, every so often
+	YOUTUBE_PARAGRAPH_DURATION = time.Minute
+	// Caption tracks generated by the speech recognition of YouTube
+	YOUTUBE_AUTO_CAPTIONS = "asr"
+)
+
+var (
+	youtubeVideoIdRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	// The player response is assigned to a variable by a script of the page
+	youtubePlayerResponseRegex = regexp.MustCompile(`ytInitialPlayerResponse\s*=\s*\{`)
+)
+
+// YoutubePlayerResponse is the description of a video given to the player of
+// its page: its details and its caption tracks.
+type YoutubePlayerResponse struct {
+	PlayabilityStatus struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	} `json:"playabilityStatus"`
+	VideoDetails struct {
+		Title            string `json:"title"`
+		Author           string `json:"author"`
+		LengthSeconds    string `json:"lengthSeconds"`
+		ShortDescription string `json:"shortDescription"`
+		Thumbnail        struct {
+			Thumbnails []struct {
+				Url   string `json:"url"`
+				Width int    `json:"width"`
+			} `json:"thumbnails"`
+		} `json:"thumbnail"`
+	} `json:"videoDetails"`
+	Microformat struct {
+		PlayerMicroformatRenderer struct {
+			// A date, or a time in the newer pages
+			PublishDate string `json:"publishDate"`
+		} `json:"playerMicroformatRenderer"`
+	} `json:"microformat"`
+	Captions struct {
+		PlayerCaptionsTracklistRenderer struct {
+			CaptionTracks []YoutubeCaptionTrack `json:"captionTracks"`
+		} `json:"playerCaptionsTracklistRenderer"`
+	} `json:"captions"`
+}
+
+type YoutubeCaptionTrack struct {
+	BaseUrl      string `json:"baseUrl"`
+	LanguageCode string `json:"languageCode"`
+	// asr for the automatic captions, empty for those written by a person
+	Kind string `json:"kind"`
+}
+
+// youtubeVideoId returns the id of the YouTube video of an url, empty if the
+// url is not the one of a video: a watch page, a short, a live, an embedded
+// player or a youtu.be link.
+func youtubeVideoId(videoUrl string) string {
+	parsed, err := url.Parse(videoUrl)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(parsed.Hostname())
+	var id string
+	switch {
+	case host == "youtu.be":
+		id, _, _ = strings.Cut(strings.TrimPrefix(parsed.Path, "/"), "/")
+	case host == "youtube.com" || strings.HasSuffix(host, ".youtube.com") || host == "youtube-nocookie.com" || strings.HasSuffix(host, ".youtube-nocookie.com"):
+		if parsed.Path == "/watch" {
+			id = parsed.Query().Get("v")
+			break
+		}
+		for _, prefix := range []string{"/shorts/", "/live/", "/embed/", "/v/"} {
+			if rest, ok := strings.CutPrefix(parsed.Path, prefix); ok {
+				id, _, _ = strings.Cut(rest, "/")
+			}
+		}
+	}
+	if !youtubeVideoIdRegex.MatchString(id) {
+		return ""
+	}
+	return id
+}
+
+// scrapeYoutubeVideo returns the article of a YouTube video: its transcript,
+// read from its captions, with its title, channel, duration and publication
+// date. A video without captions is summarized from its description.
+func (s *Scraper) scrapeYoutubeVideo(ctx context.Context, videoUrl, videoId string) (Article, error) {
+	watchUrl := "https://www.youtube.com/watch?v=" + videoId
+	var page string
+	err := s.Retry.Do(ctx, "fetching "+watchUrl, func() error {
+		var err error
+		page, err = s.fetchPage(ctx, watchUrl, s.youtubeRequest)
+		return err
+	})
+	if err != nil {
+		return Article{}, err
+	}
+	player, err := parseYoutubePlayerResponse(page)
+	if err != nil {
+		return Article{}, fmt.Errorf("reading the YouTube video %s: %w", videoId, err)
+	}
+	if status := player.PlayabilityStatus.Status; status != "" && status != "OK" {
+		return Article{}, fmt.Errorf("YouTube video %s is not available: %s", videoId, cmp.Or(player.PlayabilityStatus.Reason, status))
+	}
+	details := player.VideoDetails
+	if details.Title == "" {
+		return Article{}, fmt.Errorf("%w: the YouTube video %s has no title", ErrNoTitle, videoId)
+	}
+
+	article := Article{
+		Url:         videoUrl,
+		Title:       details.Title,
+		ContentType: CONTENT_TYPE_VIDEO,
+		SiteName:    "YouTube",
+	}
+	if details.Author != "" {
+		article.Authors = []string{details.Author}
+	}
+	if seconds, err := strconv.Atoi(details.LengthSeconds); err == nil {
+		article.Duration = time.Duration(seconds) * time.Second
+	}
+	publishDate := player.Microformat.PlayerMicroformatRenderer.PublishDate
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if published, err := time.Parse(layout, publishDate); err == nil {
+			article.Published = published
+			break
+		}
+	}
+	widest := 0
+	for _, thumbnail := range details.Thumbnail.Thumbnails {
+		if thumbnail.Width >= widest {
+			article.Image, widest = thumbnail.Url, thumbnail.Width
+		}
+	}
+
+	track, found := selectYoutubeCaptionTrack(player.Captions.PlayerCaptionsTracklistRenderer.CaptionTracks)
+	if !found {
+		if strings.TrimSpace(details.ShortDescription) == "" {
+			return Article{}, fmt.Errorf("the YouTube video %s has neither captions nor description", videoId)
+		}
+		slog.Warn("the video has no captions, summarizing its description", "url", videoUrl)
+		article.Content = details.ShortDescription
+		article.Language = detectLanguage("", article.Content)
+		return article, nil
+	}
+
+	var captions string
+	err = s.Retry.Do(ctx, "fetching the captions of "+watchUrl, func() error {
+		var err error
+		captions, err = s.fetchPage(ctx, track.BaseUrl, s.youtubeRequest)
+		return err
+	})
+	if err != nil {
+		return Article{}, fmt.Errorf("fetching the captions of the YouTube video %s: %w", videoId, err)
+	}
+	transcript, err := parseYoutubeCaptions(captions)
+	if err != nil {
+		return Article{}, fmt.Errorf("reading the captions of the YouTube video %s: %w", videoId, err)
+	}
+	slog.Debug("YouTube transcript read", "url", videoUrl, "language", track.LanguageCode, "automatic", track.Kind == YOUTUBE_AUTO_CAPTIONS, "characters", len(transcript))
+
+	article.Content = transcript
+	article.Language = cmp.Or(NormalizeLanguage(track.LanguageCode), detectLanguage("", transcript))
+	return article, nil
+}
+
+// youtubeRequest prepares the requests to YouTube, accepting its cookie
+// consent so that the pages are served rather than the consent form.
+func (s *Scraper) youtubeRequest(req *http.Request) {
+	s.Credentials.Identify(req)
+	req.AddCookie(&http.Cookie{Name: "SOCS", Value: "CAI"})
+}
+
+// parseYoutubePlayerResponse reads the player response of a watch page.
+func parseYoutubePlayerResponse(page string) (YoutubePlayerResponse, error) {
+	match := youtubePlayerResponseRegex.FindStringIndex(page)
+	if match == nil {
+		return YoutubePlayerResponse{}, fmt.Errorf("no player response in the page")
+	}
+	// The decoder stops at the end of the object, before the rest of the
+	// script
+	var player YoutubePlayerResponse
+	if err := json.NewDecoder(strings.NewReader(page[match[1]-1:])).Decode(&player); err != nil {
+		return YoutubePlayerResponse{}, fmt.Errorf("decoding the player response: %w", err)
+	}
+	return player, nil
+}
+
+// selectYoutubeCaptionTrack picks the captions of a video written by a person
+// in its spoken language, else any captions written by a person, else the
+// automatic captions.
+func selectYoutubeCaptionTrack(tracks []YoutubeCaptionTrack) (YoutubeCaptionTrack, bool) {
+	var spokenLanguage string
+	var automatic, written []YoutubeCaptionTrack
+	for _, track := range tracks {
+		if track.BaseUrl == "" {
+			continue
+		}
+		if track.Kind == YOUTUBE_AUTO_CAPTIONS {
+			spokenLanguage = track.LanguageCode
+			automatic = append(automatic, track)
+		} else {
+			written = append(written, track)
+		}
+	}
+	for _, track := range written {
+		if spokenLanguage != "" && track.LanguageCode == spokenLanguage {
+			return track, true
+		}
+	}
+	if len(written) > 0 {
+		return written[0], true
+	}
+	if len(automatic) > 0 {
+		return automatic[0], true
+	}
+	return YoutubeCaptionTrack{}, false
+}
+
+// parseYoutubeCaptions returns the transcript of the captions of a video, in
+// paragraphs headed by their timestamp and separated by blank lines. Both the
+// legacy format, <text start="1.5"> elements in seconds, and the timed text
+// format 3, <p t="1500"> elements in milliseconds, are read.
+func parseYoutubeCaptions(captions string) (string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(captions))
+	var paragraphs []string
+	var paragraph strings.Builder
+	paragraphStart := -YOUTUBE_PARAGRAPH_DURATION
+	var cue strings.Builder
+	inCue := false
+
+	endParagraph := func() {
+		if text := strings.TrimSpace(paragraph.String()); text != "" {
+			paragraphs = append(paragraphs, "["+formatTimestamp(paragraphStart)+"] "+text)
+		}
+		paragraph.Reset()
+	}
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			var start time.Duration
+			switch token.Name.Local {
+			case "text":
+				seconds, _ := strconv.ParseFloat(xmlAttr(token, "start"), 64)
+				start = time.Duration(seconds * float64(time.Second))
+			case "p":
+				milliseconds, _ := strconv.Atoi(xmlAttr(token, "t"))
+				start = time.Duration(milliseconds) * time.Millisecond
+			default:
+				continue
+			}
+			if start-paragraphStart >= YOUTUBE_PARAGRAPH_DURATION {
+				endParagraph()
+				paragraphStart = start
+			}
+			cue.Reset()
+			inCue = true
+		case xml.CharData:
+			if inCue {
+				cue.Write(token)
+			}
+		case xml.EndElement:
+			if inCue && (token.Name.Local == "text" || token.Name.Local == "p") {
+				// The text of the cues is escaped again, e.g. &amp;#39;
+				text := strings.Join(strings.Fields(html.UnescapeString(cue.String())), " ")
+				if text != "" {
+					paragraph.WriteString(text + " ")
+				}
+				inCue = false
+			}
+		}
+	}
+	endParagraph()
+
+	if len(paragraphs) == 0 {
+		return "", fmt.Errorf("empty captions")
+	}
+	return strings.Join(paragraphs, "\n\n"), nil
+}
+
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// formatTimestamp formats a position in a video, e.g. 4:05 or 1:02:03.
+func formatTimestamp(position time.Duration) string {
+	seconds := int(position.Seconds())
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
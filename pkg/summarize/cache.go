@@ -0,0 +1,96 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// CachedAnswer is an LLM answer in the cache.
+type CachedAnswer struct {
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+	Created  time.Time `json:"created"`
+	Content  string    `json:"content"`
+}
+
+// CachingProvider answers the messages already sent to a provider from a
+// cache. The answers without a JSON object, which the tool asks the LLM to
+// correct, are not kept, so that a new run asks for a new answer.
+type CachingProvider struct {
+	provider LLMProvider
+	model    string
+	cache    scrape.ResponseCache
+	// Sampling parameters of the provider, which the answers depend on
+	generation GenerationParams
+}
+
+func (p *CachingProvider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *CachingProvider) Model() string {
+	return p.model
+}
+
+type freshAnswersKey struct{}
+
+// WithFreshAnswers makes the LLM calls of a context ask the provider even for
+// cached messages, e.g. to summarize an article again, the new answers
+// replacing the cached ones.
+func WithFreshAnswers(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshAnswersKey{}, true)
+}
+
+func (p *CachingProvider) Complete(ctx context.Context, messages []ChatMessage) (string, error) {
+	key := p.key(messages)
+	var cached CachedAnswer
+	if fresh, _ := ctx.Value(freshAnswersKey{}).(bool); !fresh && p.cache.Read(scrape.LLM_CACHE_FOLDER, key, &cached) {
+		slog.Debug("LLM answer read from the cache", "provider", p.Name(), "model", p.model)
+		return cached.Content, nil
+	}
+
+	content, err := p.provider.Complete(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	var object map[string]any
+	if json.Unmarshal([]byte(content), &object) == nil || unmarshalLenientJson(content, &object) == nil {
+		p.cache.Write(scrape.LLM_CACHE_FOLDER, key, CachedAnswer{Provider: p.Name(), Model: p.model, Created: time.Now(), Content: content})
+	}
+	return content, nil
+}
+
+// key hashes what the answer depends on: the provider, its model, the
+// generation parameters and the messages, images included.
+func (p *CachingProvider) key(messages []ChatMessage) string {
+	type cachedImage struct {
+		MediaType string
+		Data      []byte
+	}
+	type cachedMessage struct {
+		Role    string
+		Content string
+		Images  []cachedImage
+	}
+	var cachedMessages []cachedMessage
+	for _, message := range messages {
+		cached := cachedMessage{Role: message.Role, Content: message.Content}
+		for _, image := range message.Images {
+			cached.Images = append(cached.Images, cachedImage{MediaType: image.MediaType, Data: image.Data})
+		}
+		cachedMessages = append(cachedMessages, cached)
+	}
+	return scrape.CacheKey(p.Name(), p.model, p.generation.Temperature, p.generation.TopP, p.generation.MaxTokens, cachedMessages)
+}
+
+// ProviderModel returns the model of a provider, empty if it does not tell.
+func ProviderModel(provider LLMProvider) string {
+	if withModel, ok := provider.(interface{ Model() string }); ok {
+		return withModel.Model()
+	}
+	return ""
+}
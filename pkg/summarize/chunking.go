@@ -0,0 +1,142 @@
+package summarize
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+const (
+	// Maximum number of tokens of the content sent in one request,
+	// longer content is summarized in chunks. It leaves room for the prompts
+	// and the answer in the context of small models and in the per-minute
+	// token limits of free plans.
+	DEFAULT_CHUNK_TOKENS = 6000
+	// Rough average number of characters of a token for English text, for the
+	// models without a known tokenizer.
+	CHARS_PER_TOKEN = 4
+	// Maximum number of reduce passes, each one summarizing the chunk
+	// summaries of the previous pass when they are still too long.
+	SUMMARY_MAX_REDUCE_PASSES = 3
+)
+
+//go:embed summary-reduce-prompt.md
+var summaryReducePrompt string
+
+// splitIntoChunks splits a text into chunks of at most maxTokens tokens of a
+// tokenizer, cutting between sentences when possible, else between words. The
+// tokens of a chunk are the sum of the tokens of its sentences, which is
+// close enough as the tokens seldom span two sentences.
+func splitIntoChunks(text string, maxTokens int, tokenizer Tokenizer) []string {
+	var chunks []string
+	var current strings.Builder
+	currentTokens := 0
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+		currentTokens = 0
+	}
+
+	for _, sentence := range splitSentences(text) {
+		pieces := []string{sentence}
+		if tokenizer.CountTokens(sentence) > maxTokens {
+			pieces = strings.Fields(sentence)
+		}
+		for _, piece := range pieces {
+			tokens := tokenizer.CountTokens(" " + piece)
+			if current.Len() > 0 && currentTokens+tokens > maxTokens {
+				flush()
+			}
+			if current.Len() > 0 {
+				current.WriteString(" ")
+			}
+			current.WriteString(piece)
+			currentTokens += tokens
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+func splitSentences(text string) []string {
+	var sentences []string
+	words := strings.Fields(text)
+	start := 0
+	for i, word := range words {
+		if strings.HasSuffix(word, ".") || strings.HasSuffix(word, "!") || strings.HasSuffix(word, "?") {
+			sentences = append(sentences, strings.Join(words[start:i+1], " "))
+			start = i + 1
+		}
+	}
+	if start < len(words) {
+		sentences = append(sentences, strings.Join(words[start:], " "))
+	}
+	return sentences
+}
+
+// summarizeChunks is the map step of the summary of a long content: each chunk
+// is summarized separately, and the summaries are returned in place of the
+// content, for the reduce step to combine them.
+func summarizeChunks(ctx context.Context, provider LLMProvider, content, systemPrompt string, maxTokens int, tokenizer Tokenizer) (string, error) {
+	chunks := splitIntoChunks(content, maxTokens, tokenizer)
+
+	var sb strings.Builder
+	for i, chunk := range chunks {
+		slog.Info(fmt.Sprintf("Summarizing part %d/%d", i+1, len(chunks)))
+		var partSummary Summary
+		_, err := CompleteJson(ctx, provider, []ChatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: chunk},
+		}, &partSummary)
+		if err != nil {
+			return "", fmt.Errorf("summarizing part %d/%d: %w", i+1, len(chunks), err)
+		}
+
+		fmt.Fprintf(&sb, "# Part %d/%d\n\n## Summary\n%s\n\n## Key points\n", i+1, len(chunks), partSummary.Summary)
+		for _, keypoint := range partSummary.Keypoints {
+			fmt.Fprintf(&sb, "- %s\n", keypoint)
+		}
+		fmt.Fprintf(&sb, "\n## Tags\n%s\n\n", strings.Join(partSummary.Tags, ", "))
+	}
+
+	return sb.String(), nil
+}
+
+// reduceLongContent replaces a content too long to be summarized at once by
+// the summaries of its chunks, and returns the system prompt asking to combine
+// them. Content that fits is returned as is. The tokens are counted with the
+// tokenizer of the model of the provider.
+func reduceLongContent(ctx context.Context, provider LLMProvider, tokenizers *Tokenizers, content, systemPrompt string, maxTokens int) (string, string, error) {
+	if maxTokens <= 0 {
+		return content, systemPrompt, nil
+	}
+	tokenizer := tokenizers.providerTokenizer(ctx, provider)
+	tokens := tokenizer.CountTokens(content)
+	slog.Debug("Article tokens counted", "tokens", tokens, "tokenizer", tokenizer.Name())
+	if tokens <= maxTokens {
+		return content, systemPrompt, nil
+	}
+
+	slog.Info("Article too long, summarizing it in parts", "tokens", tokens)
+	for pass := 0; pass < SUMMARY_MAX_REDUCE_PASSES && tokens > maxTokens; pass++ {
+		summaries, err := summarizeChunks(ctx, provider, content, systemPrompt, maxTokens, tokenizer)
+		if err != nil {
+			return "", "", err
+		}
+		// Chunks too small to be summarized any shorter, do not make it worse
+		summariesTokens := tokenizer.CountTokens(summaries)
+		shrunk := summariesTokens < tokens
+		tokens = summariesTokens
+		content = summaries
+		if !shrunk {
+			break
+		}
+	}
+
+	return content, strings.TrimSpace(systemPrompt) + "\n\n" + strings.TrimSpace(summaryReducePrompt), nil
+}
@@ -0,0 +1,19 @@
+package summarize
+
+// TokenizerConfig holds the tokenizer counting the tokens of the models
+// matching a pattern: heuristic, tiktoken with a public encoding or a rank
+// file, or sentencepiece with a model file.
+type TokenizerConfig struct {
+	Backend  string `yaml:"backend"`
+	Encoding string `yaml:"encoding,omitempty"`
+	File     string `yaml:"file,omitempty"`
+}
+
+type ProviderConfig struct {
+	ApiKey string `yaml:"api_key,omitempty"`
+	Model  string `yaml:"model,omitempty"`
+	// Price of the model in USD per million tokens, for the cost estimates,
+	// instead of the known price of the model
+	InputPrice  *float64 `yaml:"input_price,omitempty"`
+	OutputPrice *float64 `yaml:"output_price,omitempty"`
+}
@@ -0,0 +1,158 @@
+package summarize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Number of times the LLM is asked again for a summary that does not respect
+// the constraints.
+const SUMMARY_MAX_REASKS = 2
+
+const (
+	SUMMARY_LENGTH_SHORT  = "short"
+	SUMMARY_LENGTH_MEDIUM = "medium"
+	SUMMARY_LENGTH_LONG   = "long"
+)
+
+// SummaryLength is the length asked for the summary, and the number of words
+// accepted, looser than asked as the LLMs count words poorly, 0 meaning no
+// limit.
+type SummaryLength struct {
+	Instruction string
+	MinWords    int
+	MaxWords    int
+}
+
+var summaryLengths = map[string]SummaryLength{
+	SUMMARY_LENGTH_SHORT:  {Instruction: "one or two sentences, under 50 words", MaxWords: 70},
+	SUMMARY_LENGTH_MEDIUM: {Instruction: "one paragraph of 80 to 150 words", MinWords: 50, MaxWords: 200},
+	SUMMARY_LENGTH_LONG:   {Instruction: "three to five paragraphs, 250 to 400 words in total", MinWords: 180, MaxWords: 550},
+}
+
+// ValidateSummaryLength checks a summary length, see -length.
+func ValidateSummaryLength(length string) error {
+	if _, ok := summaryLengths[length]; !ok && length != "" {
+		return fmt.Errorf("unknown summary length '%s', expected %s, %s or %s", length, SUMMARY_LENGTH_SHORT, SUMMARY_LENGTH_MEDIUM, SUMMARY_LENGTH_LONG)
+	}
+	return nil
+}
+
+// CountRange is an inclusive range of allowed counts, a zero bound meaning no
+// limit. It implements flag.Value, parsing "5-8", "5-" or "5".
+type CountRange struct {
+	Min int
+	Max int
+}
+
+func (r *CountRange) String() string {
+	switch {
+	case r.Min == 0 && r.Max == 0:
+		return ""
+	case r.Min == r.Max:
+		return strconv.Itoa(r.Min)
+	case r.Max == 0:
+		return fmt.Sprintf("%d-", r.Min)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+func (r *CountRange) Set(value string) error {
+	minValue, maxValue, isRange := strings.Cut(value, "-")
+	if !isRange {
+		maxValue = minValue
+	}
+
+	parsed := CountRange{}
+	var err error
+	if minValue != "" {
+		if parsed.Min, err = strconv.Atoi(minValue); err != nil || parsed.Min < 0 {
+			return fmt.Errorf("invalid minimum in '%s'", value)
+		}
+	}
+	if maxValue != "" {
+		if parsed.Max, err = strconv.Atoi(maxValue); err != nil || parsed.Max < 0 {
+			return fmt.Errorf("invalid maximum in '%s'", value)
+		}
+	}
+	if parsed.Max != 0 && parsed.Min > parsed.Max {
+		return fmt.Errorf("minimum is greater than maximum in '%s'", value)
+	}
+
+	*r = parsed
+	return nil
+}
+
+func (r CountRange) isSet() bool {
+	return r.Min > 0 || r.Max > 0
+}
+
+func (r CountRange) describe() string {
+	switch {
+	case r.Min == r.Max:
+		return fmt.Sprintf("exactly %d", r.Min)
+	case r.Max == 0:
+		return fmt.Sprintf("at least %d", r.Min)
+	case r.Min == 0:
+		return fmt.Sprintf("at most %d", r.Max)
+	}
+	return fmt.Sprintf("between %d and %d", r.Min, r.Max)
+}
+
+// SummaryConstraints are the counts of keypoints and tags, and the length of
+// the summary, the summary must respect.
+type SummaryConstraints struct {
+	Keypoints CountRange
+	Tags      CountRange
+	// Key of summaryLengths, empty to let the prompt decide
+	Length string
+}
+
+// promptInstructions returns the instructions to append to the system prompt
+// so that the LLM knows about the constraints.
+func (c SummaryConstraints) promptInstructions() string {
+	var instructions []string
+	if c.Keypoints.isSet() {
+		instructions = append(instructions, fmt.Sprintf("- keypoints: give %s keypoints", c.Keypoints.describe()))
+	}
+	if c.Tags.isSet() {
+		instructions = append(instructions, fmt.Sprintf("- tags: give %s tags", c.Tags.describe()))
+	}
+	if length, ok := summaryLengths[c.Length]; ok {
+		instructions = append(instructions, "- summary: write "+length.Instruction)
+	}
+	if len(instructions) == 0 {
+		return ""
+	}
+	return "These constraints must be respected, they take precedence over any count or length given above:\n" + strings.Join(instructions, "\n")
+}
+
+// violations describes what the summary lacks to respect the minimum counts,
+// and a summary of another length than asked. Exceeding keypoints or tags are
+// not a problem, they are trimmed.
+func (c SummaryConstraints) violations(summary Summary) []string {
+	var problems []string
+	if len(summary.Keypoints) < c.Keypoints.Min {
+		problems = append(problems, fmt.Sprintf("you gave %d keypoints but I need %s", len(summary.Keypoints), c.Keypoints.describe()))
+	}
+	if len(summary.Tags) < c.Tags.Min {
+		problems = append(problems, fmt.Sprintf("you gave %d tags but I need %s", len(summary.Tags), c.Tags.describe()))
+	}
+	if length, ok := summaryLengths[c.Length]; ok {
+		words := len(strings.Fields(summary.Summary))
+		if words < length.MinWords || (length.MaxWords > 0 && words > length.MaxWords) {
+			problems = append(problems, fmt.Sprintf("your summary has %d words but I need %s", words, length.Instruction))
+		}
+	}
+	return problems
+}
+
+func (c SummaryConstraints) trim(summary *Summary) {
+	if c.Keypoints.Max > 0 && len(summary.Keypoints) > c.Keypoints.Max {
+		summary.Keypoints = summary.Keypoints[:c.Keypoints.Max]
+	}
+	if c.Tags.Max > 0 && len(summary.Tags) > c.Tags.Max {
+		summary.Tags = summary.Tags[:c.Tags.Max]
+	}
+}
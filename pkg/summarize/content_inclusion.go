@@ -0,0 +1,169 @@
+package summarize
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+const (
+	// Maximum length of an excerpt, in characters, cut at the end of a
+	// sentence.
+	DEFAULT_EXCERPT_LENGTH = 600
+	// The excerpt is made of the first meaningful paragraphs of the article
+	EXCERPT_PARAGRAPHS = "paragraphs"
+	// The excerpt is picked by the LLM
+	EXCERPT_LLM = "llm"
+	// Paragraphs shorter than this are bylines, captions or buttons rather
+	// than text, unless they end a sentence.
+	MIN_EXCERPT_PARAGRAPH_LENGTH = 80
+)
+
+//go:embed excerpt-prompt.md
+var excerptPrompt string
+
+// ExcerptResult is the answer of the LLM writing an excerpt.
+type ExcerptResult struct {
+	Excerpt string `json:"excerpt"`
+}
+
+// boilerplateParagraphRegex matches the paragraphs surrounding the text of the
+// articles: bylines, dates, ads, sharing and subscription calls, photo credits.
+var boilerplateParagraphRegex = regexp.MustCompile(`(?i)^(by |written by |posted |published |updated |last updated|advertisement|sponsored|share |share$|subscribe|sign up|read more|related:|photo:|image:|credit:|\(?photo |\[image: |listen to|\d+ min read)`)
+
+// ValidateExcerptMode checks an excerpt mode, see -excerpt.
+func ValidateExcerptMode(mode string) error {
+	if mode != EXCERPT_PARAGRAPHS && mode != EXCERPT_LLM {
+		return fmt.Errorf("unknown excerpt mode '%s', expected %s or %s", mode, EXCERPT_PARAGRAPHS, EXCERPT_LLM)
+	}
+	return nil
+}
+
+// isMeaningfulParagraph tells if a paragraph is part of the text of the
+// article rather than of the page around it.
+func isMeaningfulParagraph(paragraph, title string) bool {
+	if strings.EqualFold(paragraph, title) || boilerplateParagraphRegex.MatchString(paragraph) {
+		return false
+	}
+	if utf8.RuneCountInString(paragraph) >= MIN_EXCERPT_PARAGRAPH_LENGTH {
+		return true
+	}
+	// A short sentence, such as the hook of a lede, is kept
+	return len(strings.Fields(paragraph)) >= 5 && strings.ContainsAny(paragraph[len(paragraph)-1:], ".!?")
+}
+
+// paragraphsExcerpt returns the first meaningful paragraphs of an article, up
+// to maxChars characters.
+func paragraphsExcerpt(article scrape.Article, maxChars int) string {
+	var paragraphs []string
+	for _, paragraph := range strings.Split(article.Content, "\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph != "" && isMeaningfulParagraph(paragraph, article.Title) {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	// Better some text than none for pages made of short paragraphs
+	if len(paragraphs) == 0 {
+		return excerpt(article.Content, maxChars)
+	}
+	return excerpt(strings.Join(paragraphs, "\n"), maxChars)
+}
+
+// llmExcerpt asks the LLM to pick the lede of an article, of at most maxChars
+// characters.
+func llmExcerpt(ctx context.Context, provider LLMProvider, article scrape.Article, maxChars int) (string, error) {
+	// The lede is at the start, the rest of a long article is not needed
+	content := truncateWords(article.Content, DEFAULT_CHUNK_TOKENS*CHARS_PER_TOKEN)
+	prompt := strings.TrimSpace(excerptPrompt) + fmt.Sprintf("\n\nThe excerpt must be at most %d characters long.", maxChars)
+
+	answer, err := provider.Complete(ctx, []ChatMessage{
+		{Role: "system", Content: prompt},
+		{Role: "user", Content: "# " + article.Title + "\n\n" + content},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var result ExcerptResult
+	if err := json.Unmarshal([]byte(answer), &result); err != nil {
+		return "", fmt.Errorf("unmarshaling excerpt: %w", err)
+	}
+	if strings.TrimSpace(result.Excerpt) == "" {
+		return "", fmt.Errorf("empty excerpt")
+	}
+	// Models do not count characters well
+	return excerpt(result.Excerpt, maxChars), nil
+}
+
+// GetArticleExcerpt returns the excerpt of an article, falling back to its
+// first paragraphs when the LLM fails to write it.
+func GetArticleExcerpt(ctx context.Context, provider LLMProvider, article scrape.Article, mode string, maxChars int) string {
+	if mode == EXCERPT_LLM {
+		text, err := llmExcerpt(ctx, provider, article, maxChars)
+		if err == nil {
+			return text
+		}
+		slog.Warn("could not write the excerpt with the LLM, using the first paragraphs", "err", err)
+	}
+	return paragraphsExcerpt(article, maxChars)
+}
+
+// excerpt returns the first paragraphs of a text, up to maxChars characters.
+// A paragraph too long to fit is cut at the end of a sentence, or else
+// between words.
+func excerpt(text string, maxChars int) string {
+	var paragraphs []string
+	length := 0
+	for _, paragraph := range strings.Split(text, "\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+
+		remaining := maxChars - length
+		if utf8.RuneCountInString(paragraph) <= remaining {
+			paragraphs = append(paragraphs, paragraph)
+			length += utf8.RuneCountInString(paragraph)
+			continue
+		}
+
+		var cut []string
+		cutLength := 0
+		for _, sentence := range splitSentences(paragraph) {
+			if cutLength+utf8.RuneCountInString(sentence) > remaining {
+				break
+			}
+			cut = append(cut, sentence)
+			cutLength += utf8.RuneCountInString(sentence) + 1
+		}
+		if len(cut) > 0 {
+			paragraphs = append(paragraphs, strings.Join(cut, " "))
+		} else if len(paragraphs) == 0 {
+			paragraphs = append(paragraphs, truncateWords(paragraph, maxChars)+"…")
+		}
+		break
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// truncateWords returns the words of a text fitting in maxChars characters.
+func truncateWords(text string, maxChars int) string {
+	var sb strings.Builder
+	for _, word := range strings.Fields(text) {
+		if sb.Len() > 0 && utf8.RuneCountInString(sb.String())+1+utf8.RuneCountInString(word) > maxChars {
+			break
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(word)
+	}
+	return sb.String()
+}
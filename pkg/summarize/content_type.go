@@ -0,0 +1,36 @@
+package summarize
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// Each content type, except the default article type, has its own prompt,
+// found in content-types/<type>/prompt.md.
+//
+//go:embed content-types/*/prompt.md
+var contentTypePrompts embed.FS
+
+// GetContentTypePrompt returns the system prompt of the profile completed with
+// the instructions specific to the content type.
+func GetContentTypePrompt(contentType string, userPrompts scrape.ContentTypeFiles, profilePrompt string) (string, error) {
+	var typePrompt []byte
+	if path, ok := userPrompts[contentType]; ok {
+		prompt, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading %s prompt: %w", contentType, err)
+		}
+		typePrompt = prompt
+	} else if prompt, err := contentTypePrompts.ReadFile("content-types/" + contentType + "/prompt.md"); err == nil {
+		typePrompt = prompt
+	}
+
+	if len(typePrompt) == 0 {
+		return profilePrompt, nil
+	}
+	return strings.TrimSpace(profilePrompt) + "\n\n" + strings.TrimSpace(string(typePrompt)), nil
+}
@@ -0,0 +1,5 @@
+// Package summarize summarizes the articles with an LLM. It holds the
+// providers, their cache and retries, the prompt profiles, the tokenizers
+// splitting the long articles into chunks and the accounting of the tokens
+// consumed.
+package summarize
@@ -0,0 +1,59 @@
+package summarize
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/brequet/report/internal/errfmt"
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// ErrInvalidSummaryJSON is returned when the LLM answer is not the
+// expected JSON summary, or label of the other prompts, even once asked
+// to correct it.
+var ErrInvalidSummaryJSON = errors.New("invalid summary JSON")
+
+// LLMError is the failure of a call to an LLM provider.
+type LLMError struct {
+	Provider   string
+	Model      string
+	RequestId  string
+	StatusCode int
+	// Delay asked by the provider with a Retry-After header.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *LLMError) Error() string {
+	diagnostics := []string{"model " + e.Model}
+	if e.StatusCode != 0 {
+		diagnostics = append(diagnostics, fmt.Sprintf("HTTP %d", e.StatusCode))
+	}
+	if e.RequestId != "" {
+		diagnostics = append(diagnostics, "request id "+e.RequestId)
+	}
+	return errfmt.WithDiagnostics(fmt.Sprintf("%s: %v", e.Provider, e.Err), diagnostics)
+}
+
+// Transient tells if the call may succeed when retried, a network error
+// having no status.
+func (e *LLMError) Transient() (bool, time.Duration) {
+	return e.StatusCode == 0 || scrape.IsTransientStatus(e.StatusCode), e.RetryAfter
+}
+
+func (e *LLMError) Unwrap() error {
+	return e.Err
+}
+
+func (e *LLMError) Is(target error) bool {
+	return target == scrape.ErrRateLimited && e.StatusCode == http.StatusTooManyRequests
+}
+
+// wrap returns a copy of the error wrapping err, for the providers to fill the
+// diagnostics as the call goes and attach them to any failure.
+func (e LLMError) wrap(err error) error {
+	e.Err = err
+	return &e
+}
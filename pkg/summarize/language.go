@@ -0,0 +1,56 @@
+package summarize
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+const (
+	// Summary language of -summary-lang writing the summaries in the language
+	// of the article
+	SUMMARY_LANGUAGE_SOURCE = "source"
+)
+
+// ParseSummaryLanguage validates the value of -summary-lang: empty to let
+// the LLM pick, source for the language of the article, or a language.
+func ParseSummaryLanguage(value string) (string, error) {
+	if value == "" || value == SUMMARY_LANGUAGE_SOURCE {
+		return value, nil
+	}
+	if code := scrape.NormalizeLanguage(value); code != "" {
+		return code, nil
+	}
+	codes := make([]string, 0, len(scrape.LanguageNames))
+	for code := range scrape.LanguageNames {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return "", fmt.Errorf("unknown summary language '%s', expected %s or one of %s", value, SUMMARY_LANGUAGE_SOURCE, strings.Join(codes, ", "))
+}
+
+// WithLanguageInstructions completes a system prompt with the language the
+// summary is written in, summaryLanguage being a language code or source,
+// and with the language of the article when known. The prompt is unchanged
+// when no summary language is set.
+func WithLanguageInstructions(systemPrompt, articleLanguage, summaryLanguage string) string {
+	if summaryLanguage == "" {
+		return systemPrompt
+	}
+
+	var instructions []string
+	if articleLanguage != "" {
+		instructions = append(instructions, fmt.Sprintf("The page is written in %s.", scrape.LanguageName(articleLanguage)))
+	}
+	switch {
+	case summaryLanguage == SUMMARY_LANGUAGE_SOURCE && articleLanguage == "":
+		instructions = append(instructions, "Write the summary and the keypoints in the language of the page.")
+	case summaryLanguage == SUMMARY_LANGUAGE_SOURCE || summaryLanguage == articleLanguage:
+		instructions = append(instructions, fmt.Sprintf("Write the summary and the keypoints in %s.", scrape.LanguageName(articleLanguage)))
+	default:
+		instructions = append(instructions, fmt.Sprintf("Write the summary and the keypoints in %s, translating what you quote from the page.", scrape.LanguageName(summaryLanguage)))
+	}
+	return strings.TrimSpace(systemPrompt) + "\n\n" + strings.Join(instructions, " ")
+}
@@ -0,0 +1,222 @@
+package summarize
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+const (
+	DEFAULT_LLM_PROVIDER = "groq"
+	// Long enough for local models summarizing long chunks on a CPU
+	DEFAULT_LLM_TIMEOUT = 5 * time.Minute
+	// Sampling parameters of the LLM calls
+	DEFAULT_TEMPERATURE = 1.0
+	DEFAULT_MAX_TOKENS  = 1024
+	DEFAULT_TOP_P       = 1.0
+	MAX_TEMPERATURE     = 2.0
+)
+
+// GenerationParams are the model and the sampling parameters of the LLM
+// calls.
+type GenerationParams struct {
+	// Model of the first provider, instead of its configured or default
+	// model, empty to keep it
+	Model       string
+	Temperature float64
+	// Maximum length of an answer, in tokens
+	MaxTokens int
+	TopP      float64
+}
+
+func (g GenerationParams) validate() error {
+	if g.Temperature < 0 || g.Temperature > MAX_TEMPERATURE {
+		return fmt.Errorf("invalid temperature %g, expected a value from 0 to %g", g.Temperature, MAX_TEMPERATURE)
+	}
+	if g.MaxTokens <= 0 {
+		return fmt.Errorf("invalid max tokens %d, expected a positive number", g.MaxTokens)
+	}
+	if g.TopP <= 0 || g.TopP > 1 {
+		return fmt.Errorf("invalid top_p %g, expected a value above 0 and up to 1", g.TopP)
+	}
+	return nil
+}
+
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Images sent with the content, to the models reading images, which the
+	// providers send in their own format
+	Images []ChatImage `json:"-"`
+}
+
+// ChatImage is an image sent with a message.
+type ChatImage struct {
+	// Media type of the image, e.g. image/png
+	MediaType string
+	Data      []byte
+}
+
+// dataUrl returns the image as a data url.
+func (i ChatImage) dataUrl() string {
+	return "data:" + i.MediaType + ";base64," + base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// LLMProvider is a chat completion API. The system prompts of the tool ask
+// for a JSON object, which providers are expected to enforce when they can.
+type LLMProvider interface {
+	Name() string
+	Complete(ctx context.Context, messages []ChatMessage) (string, error)
+}
+
+// ProviderOptions configure the providers created by NewLLMProvider.
+type ProviderOptions struct {
+	Generation GenerationParams
+	// Maximum duration of an LLM call, 0 for no limit
+	Timeout time.Duration
+	Retry   scrape.RetryPolicy
+	// Cache of the answers
+	Cache scrape.ResponseCache
+	// API keys, models and prices of the providers, by name, as set in the
+	// config file. The environment variables take precedence.
+	Providers map[string]ProviderConfig
+}
+
+// DefaultProviderOptions returns the options of the providers when the flags
+// and the config file do not tell otherwise.
+func DefaultProviderOptions() ProviderOptions {
+	return ProviderOptions{
+		Generation: GenerationParams{Temperature: DEFAULT_TEMPERATURE, MaxTokens: DEFAULT_MAX_TOKENS, TopP: DEFAULT_TOP_P},
+		Timeout:    DEFAULT_LLM_TIMEOUT,
+		Retry:      scrape.DefaultRetryPolicy(),
+		Cache:      scrape.NewResponseCache(),
+	}
+}
+
+// apiKey returns the API key of a provider, from its environment variable or
+// else from the options.
+func (o ProviderOptions) apiKey(provider, env string) string {
+	if key := os.Getenv(env); key != "" {
+		return key
+	}
+	return o.Providers[provider].ApiKey
+}
+
+// model returns the model of a provider set in the options, or the default
+// one.
+func (o ProviderOptions) model(provider, defaultModel string) string {
+	if model := o.Providers[provider].Model; model != "" {
+		return model
+	}
+	return defaultModel
+}
+
+// LLMProviders maps provider names to their constructor. Constructors read
+// their configuration (API key, host, model) from the environment, else from
+// the options. A model given to the constructor takes precedence.
+var LLMProviders = map[string]func(model string, options ProviderOptions) (LLMProvider, error){
+	"groq":      newGroqProvider,
+	"openai":    newOpenAIProvider,
+	"anthropic": newAnthropicProvider,
+	"ollama":    newOllamaProvider,
+}
+
+// LLMProviderNames returns the names of the known providers, sorted.
+func LLMProviderNames() []string {
+	var names []string
+	for name := range LLMProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewLLMProvider creates the provider named by value. A comma separated list
+// of names creates a provider falling back on the next one when a provider
+// fails, e.g. "ollama,groq" to summarize locally and use Groq when Ollama is
+// not running.
+func NewLLMProvider(value string, options ProviderOptions) (LLMProvider, error) {
+	if err := options.Generation.validate(); err != nil {
+		return nil, err
+	}
+
+	var providers []LLMProvider
+	for i, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		constructor, ok := LLMProviders[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown LLM provider '%s', expected one of %s", name, strings.Join(LLMProviderNames(), ", "))
+		}
+		// The fallbacks are other providers, which do not serve the same models
+		model := ""
+		if i == 0 {
+			model = options.Generation.Model
+		}
+		provider, err := constructor(model, options)
+		if err != nil {
+			return nil, fmt.Errorf("creating %s provider: %w", name, err)
+		}
+		providers = append(providers, options.wrap(provider))
+	}
+
+	if len(providers) == 1 {
+		return providers[0], nil
+	}
+	return &FallbackProvider{providers: providers}, nil
+}
+
+// wrap makes a provider retry its calls and cache its answers.
+func (o ProviderOptions) wrap(provider LLMProvider) LLMProvider {
+	retrying := &RetryingProvider{provider: provider, retry: o.Retry, timeout: o.Timeout}
+	return o.Cached(retrying, ProviderModel(provider))
+}
+
+// Cached makes a provider of a model cache its answers, unless the cache is
+// disabled.
+func (o ProviderOptions) Cached(provider LLMProvider, model string) LLMProvider {
+	if o.Cache.Disabled {
+		return provider
+	}
+	return &CachingProvider{provider: provider, model: model, cache: o.Cache, generation: o.Generation}
+}
+
+// FallbackProvider tries its providers in order until one of them answers.
+type FallbackProvider struct {
+	providers []LLMProvider
+}
+
+func (p *FallbackProvider) Name() string {
+	names := make([]string, len(p.providers))
+	for i, provider := range p.providers {
+		names[i] = provider.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Model returns the model of the first provider, the one expected to answer.
+func (p *FallbackProvider) Model() string {
+	return ProviderModel(p.providers[0])
+}
+
+func (p *FallbackProvider) Complete(ctx context.Context, messages []ChatMessage) (string, error) {
+	var errs []error
+	for i, provider := range p.providers {
+		content, err := provider.Complete(ctx, messages)
+		if err == nil {
+			return content, nil
+		}
+		errs = append(errs, err)
+		if i < len(p.providers)-1 {
+			slog.Warn("LLM provider failed, falling back on the next one", "provider", provider.Name(), "next", p.providers[i+1].Name(), "err", err)
+		}
+	}
+	return "", fmt.Errorf("all LLM providers failed: %w", errors.Join(errs...))
+}
@@ -0,0 +1,193 @@
+package summarize
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+const (
+	ANTHROPIC_API_URL     = "https://api.anthropic.com/v1/messages"
+	ANTHROPIC_API_VERSION = "2023-06-01"
+	ANTHROPIC_MODEL       = "claude-3-5-haiku-latest"
+)
+
+type AnthropicRequestBody struct {
+	Model       string  `json:"model"`
+	System      string  `json:"system,omitempty"`
+	Messages    []any   `json:"messages"`
+	MaxTokens   int     `json:"max_tokens"`
+	Temperature float64 `json:"temperature"`
+	// Only sent when set, Anthropic advising to change the temperature or
+	// top_p but not both
+	TopP float64 `json:"top_p,omitempty"`
+}
+
+// AnthropicContentBlock is a block of the content of a message with images,
+// its text or one of its images.
+type AnthropicContentBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *AnthropicImageSource `json:"source,omitempty"`
+}
+
+type AnthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type AnthropicResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+type AnthropicErrorResponse struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type AnthropicProvider struct {
+	apiKey     string
+	model      string
+	generation GenerationParams
+	price      ModelPrice
+}
+
+func newAnthropicProvider(model string, options ProviderOptions) (LLMProvider, error) {
+	anthropicApiKey := options.apiKey("anthropic", "ANTHROPIC_API_KEY")
+	if anthropicApiKey == "" {
+		return nil, fmt.Errorf("ANTHROPIC_API_KEY environment variable not set, nor anthropic api_key in the config file")
+	}
+
+	if options.Generation.Temperature > 1 {
+		return nil, fmt.Errorf("invalid temperature %g, anthropic expects a value from 0 to 1", options.Generation.Temperature)
+	}
+
+	model = cmp.Or(model, options.model("anthropic", ANTHROPIC_MODEL))
+	return &AnthropicProvider{apiKey: anthropicApiKey, model: model, generation: options.Generation, price: options.price("anthropic", model)}, nil
+}
+
+func (p *AnthropicProvider) Name() string {
+	return "anthropic"
+}
+
+func (p *AnthropicProvider) Model() string {
+	return p.model
+}
+
+// Complete sends the messages to the Messages API. It has no JSON mode, so the
+// answer is prefilled with the opening brace of the expected JSON object.
+func (p *AnthropicProvider) Complete(ctx context.Context, messages []ChatMessage) (string, error) {
+	requestBody := AnthropicRequestBody{
+		Model:       p.model,
+		MaxTokens:   p.generation.MaxTokens,
+		Temperature: p.generation.Temperature,
+	}
+	if p.generation.TopP < 1 {
+		requestBody.TopP = p.generation.TopP
+	}
+	for _, message := range messages {
+		if message.Role == "system" {
+			requestBody.System = strings.TrimSpace(requestBody.System + "\n\n" + message.Content)
+			continue
+		}
+		requestBody.Messages = append(requestBody.Messages, anthropicMessage(message))
+	}
+	requestBody.Messages = append(requestBody.Messages, ChatMessage{Role: "assistant", Content: "{"})
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return "", fmt.Errorf("marshaling JSON: %w", err)
+	}
+
+	diagnostics := LLMError{Provider: p.Name(), Model: p.model}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", ANTHROPIC_API_URL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", diagnostics.wrap(fmt.Errorf("creating request: %w", err))
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("anthropic-version", ANTHROPIC_API_VERSION)
+
+	client := &http.Client{Transport: scrape.LoggingTransport{}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", diagnostics.wrap(fmt.Errorf("sending request: %w", err))
+	}
+	defer resp.Body.Close()
+	diagnostics.StatusCode = resp.StatusCode
+	diagnostics.RequestId = resp.Header.Get("request-id")
+	diagnostics.RetryAfter = scrape.ParseRetryAfter(resp.Header.Get("Retry-After"))
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", diagnostics.wrap(fmt.Errorf("reading response body: %w", err))
+	}
+
+	var errorResp AnthropicErrorResponse
+	if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Type == "error" {
+		return "", diagnostics.wrap(fmt.Errorf("API error: %s (Type: %s)", errorResp.Error.Message, errorResp.Error.Type))
+	}
+
+	var anthropicResp AnthropicResponse
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		return "", diagnostics.wrap(fmt.Errorf("unmarshaling response: %w", err))
+	}
+	recordTokenUsage(ctx, p.Name(), p.model, p.price, anthropicResp.Usage.InputTokens, anthropicResp.Usage.OutputTokens)
+
+	var text strings.Builder
+	for _, content := range anthropicResp.Content {
+		if content.Type == "text" {
+			text.WriteString(content.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", diagnostics.wrap(fmt.Errorf("no text content in response"))
+	}
+
+	return "{" + text.String(), nil
+}
+
+// anthropicMessage returns a message of a request, the content of a message
+// with images being its images, base64 encoded, followed by its text.
+func anthropicMessage(message ChatMessage) any {
+	if len(message.Images) == 0 {
+		return message
+	}
+	var blocks []AnthropicContentBlock
+	for _, image := range message.Images {
+		blocks = append(blocks, AnthropicContentBlock{Type: "image", Source: &AnthropicImageSource{
+			Type:      "base64",
+			MediaType: image.MediaType,
+			Data:      base64.StdEncoding.EncodeToString(image.Data),
+		}})
+	}
+	blocks = append(blocks, AnthropicContentBlock{Type: "text", Text: message.Content})
+	return struct {
+		Role    string                  `json:"role"`
+		Content []AnthropicContentBlock `json:"content"`
+	}{message.Role, blocks}
+}
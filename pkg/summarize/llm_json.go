@@ -0,0 +1,66 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Number of times the LLM is asked to correct an answer that is not valid
+// JSON.
+const JSON_MAX_REPAIRS = 2
+
+// Code block around the JSON of an answer, e.g. ```json ... ```
+var jsonCodeBlockRegex = regexp.MustCompile("(?s)```(?:json|JSON)?\\s*\\n(.*?)\\n?```")
+
+// CompleteJson asks the LLM for the JSON object decoded into result. An answer
+// that is not valid JSON is read leniently, the object being taken out of the
+// prose or the code block around it, else the LLM is asked to correct it, with
+// the parse error, up to JSON_MAX_REPAIRS times. It returns the conversation
+// ending with the valid answer, to ask for changes to it.
+func CompleteJson(ctx context.Context, provider LLMProvider, messages []ChatMessage, result any) ([]ChatMessage, error) {
+	for attempt := 0; ; attempt++ {
+		content, err := provider.Complete(ctx, messages)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, ChatMessage{Role: "assistant", Content: content})
+
+		err = json.Unmarshal([]byte(content), result)
+		if err == nil {
+			return messages, nil
+		}
+		if lenientErr := unmarshalLenientJson(content, result); lenientErr == nil {
+			slog.Debug("JSON read from the text around it", "provider", provider.Name())
+			return messages, nil
+		}
+		if attempt == JSON_MAX_REPAIRS {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSummaryJSON, err)
+		}
+
+		slog.Warn("the LLM answer is not valid JSON, asking for a correction", "provider", provider.Name(), "err", err)
+		messages = append(messages, ChatMessage{
+			Role:    "user",
+			Content: fmt.Sprintf("Your answer is not valid JSON (%v). Answer again with only the corrected JSON object, without any text or code block around it.", err),
+		})
+	}
+}
+
+// unmarshalLenientJson decodes the JSON object of an answer holding more than
+// the object: the object of its code block, else the first object of the text.
+func unmarshalLenientJson(content string, result any) error {
+	if match := jsonCodeBlockRegex.FindStringSubmatch(content); match != nil {
+		if err := json.Unmarshal([]byte(match[1]), result); err == nil {
+			return nil
+		}
+	}
+	start := strings.Index(content, "{")
+	if start < 0 {
+		return fmt.Errorf("no JSON object in the answer")
+	}
+	// The decoder stops at the end of the object, before the text after it
+	return json.NewDecoder(strings.NewReader(content[start:])).Decode(result)
+}
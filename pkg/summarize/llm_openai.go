@@ -0,0 +1,339 @@
+package summarize
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+const (
+	GROQ_API_URL = "https://api.groq.com/openai/v1/chat/completions"
+	GROQ_MODEL   = "llama-3.1-8b-instant"
+
+	OPENAI_API_URL = "https://api.openai.com/v1/chat/completions"
+	OPENAI_MODEL   = "gpt-4o-mini"
+
+	OLLAMA_DEFAULT_HOST = "http://localhost:11434"
+	OLLAMA_MODEL        = "llama3.1"
+)
+
+type ChatCompletionRequestBody struct {
+	Messages       []any                        `json:"messages"`
+	Model          string                       `json:"model"`
+	Temperature    float64                      `json:"temperature"`
+	MaxTokens      int                          `json:"max_tokens"`
+	TopP           float64                      `json:"top_p"`
+	Stream         bool                         `json:"stream"`
+	StreamOptions  *ChatCompletionStreamOptions `json:"stream_options,omitempty"`
+	ResponseFormat struct {
+		Type string `json:"type"`
+	} `json:"response_format"`
+	Stop interface{} `json:"stop"`
+}
+
+// ChatCompletionContentPart is a part of the content of a message with
+// images, its text or one of its images.
+type ChatCompletionContentPart struct {
+	Type     string                  `json:"type"`
+	Text     string                  `json:"text,omitempty"`
+	ImageUrl *ChatCompletionImageUrl `json:"image_url,omitempty"`
+}
+
+type ChatCompletionImageUrl struct {
+	Url string `json:"url"`
+}
+
+type ChatCompletionResponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index   int `json:"index"`
+		Message struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"message"`
+		LogProbs     interface{} `json:"logprobs"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		QueueTime        float64 `json:"queue_time"`
+		PromptTokens     int     `json:"prompt_tokens"`
+		PromptTime       float64 `json:"prompt_time"`
+		CompletionTokens int     `json:"completion_tokens"`
+		CompletionTime   float64 `json:"completion_time"`
+		TotalTokens      int     `json:"total_tokens"`
+		TotalTime        float64 `json:"total_time"`
+	} `json:"usage"`
+	SystemFingerprint string `json:"system_fingerprint"`
+	XGroq             struct {
+		ID string `json:"id"`
+	} `json:"x_groq"`
+}
+
+type ChatCompletionStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type ChatCompletionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// ChatCompletionChunk is an event of a streamed answer. Groq gives the usage
+// in x_groq, OpenAI and Ollama in the last chunk.
+type ChatCompletionChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *ChatCompletionUsage `json:"usage"`
+	XGroq struct {
+		ID    string               `json:"id"`
+		Usage *ChatCompletionUsage `json:"usage"`
+	} `json:"x_groq"`
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+	} `json:"error"`
+}
+
+type ChatCompletionErrorResponse struct {
+	Error struct {
+		Message          string `json:"message"`
+		Type             string `json:"type"`
+		Code             string `json:"code"`
+		FailedGeneration string `json:"failed_generation"`
+	} `json:"error"`
+}
+
+// OpenAICompatibleProvider talks to any API implementing the OpenAI chat
+// completions endpoint, which Groq and Ollama both do.
+type OpenAICompatibleProvider struct {
+	name       string
+	apiUrl     string
+	apiKey     string
+	model      string
+	generation GenerationParams
+	price      ModelPrice
+}
+
+func newGroqProvider(model string, options ProviderOptions) (LLMProvider, error) {
+	groqApiKey := options.apiKey("groq", "GROQ_API_KEY")
+	if groqApiKey == "" {
+		return nil, fmt.Errorf("GROQ_API_KEY environment variable not set, nor groq api_key in the config file")
+	}
+
+	model = cmp.Or(model, options.model("groq", GROQ_MODEL))
+	return &OpenAICompatibleProvider{name: "groq", apiUrl: GROQ_API_URL, apiKey: groqApiKey, model: model, generation: options.Generation, price: options.price("groq", model)}, nil
+}
+
+func newOpenAIProvider(model string, options ProviderOptions) (LLMProvider, error) {
+	openAIApiKey := options.apiKey("openai", "OPENAI_API_KEY")
+	if openAIApiKey == "" {
+		return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set, nor openai api_key in the config file")
+	}
+
+	model = cmp.Or(model, options.model("openai", OPENAI_MODEL))
+	return &OpenAICompatibleProvider{name: "openai", apiUrl: OPENAI_API_URL, apiKey: openAIApiKey, model: model, generation: options.Generation, price: options.price("openai", model)}, nil
+}
+
+func newOllamaProvider(model string, options ProviderOptions) (LLMProvider, error) {
+	host := os.Getenv("OLLAMA_HOST")
+	if host == "" {
+		host = OLLAMA_DEFAULT_HOST
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	if model == "" {
+		model = os.Getenv("OLLAMA_MODEL")
+	}
+	if model == "" {
+		model = options.model("ollama", OLLAMA_MODEL)
+	}
+
+	return &OpenAICompatibleProvider{name: "ollama", apiUrl: strings.TrimSuffix(host, "/") + "/v1/chat/completions", model: model, generation: options.Generation, price: options.price("ollama", model)}, nil
+}
+
+func (p *OpenAICompatibleProvider) Name() string {
+	return p.name
+}
+
+func (p *OpenAICompatibleProvider) Model() string {
+	return p.model
+}
+
+// Complete sends the messages to the API and returns the content of the first
+// choice, which is requested to be a JSON object. The answer is streamed when
+// its progress is shown.
+func (p *OpenAICompatibleProvider) Complete(ctx context.Context, messages []ChatMessage) (string, error) {
+	stream := llmProgress(ctx) != nil
+	requestBody := ChatCompletionRequestBody{
+		Messages:    openAIMessages(messages),
+		Model:       p.model,
+		Temperature: p.generation.Temperature,
+		MaxTokens:   p.generation.MaxTokens,
+		TopP:        p.generation.TopP,
+		Stream:      stream,
+		ResponseFormat: struct {
+			Type string `json:"type"`
+		}{
+			Type: "json_object",
+		},
+		Stop: nil,
+	}
+	if stream {
+		requestBody.StreamOptions = &ChatCompletionStreamOptions{IncludeUsage: true}
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return "", fmt.Errorf("marshaling JSON: %w", err)
+	}
+
+	diagnostics := LLMError{Provider: p.name, Model: p.model}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.apiUrl, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", diagnostics.wrap(fmt.Errorf("creating request: %w", err))
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	client := &http.Client{Transport: scrape.LoggingTransport{}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", diagnostics.wrap(fmt.Errorf("sending request: %w", err))
+	}
+	defer resp.Body.Close()
+	diagnostics.StatusCode = resp.StatusCode
+	diagnostics.RequestId = resp.Header.Get("x-request-id")
+	diagnostics.RetryAfter = scrape.ParseRetryAfter(resp.Header.Get("Retry-After"))
+
+	// Errors are answered as a plain JSON object
+	if stream && resp.StatusCode < 400 {
+		return p.readStream(ctx, resp.Body, diagnostics)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", diagnostics.wrap(fmt.Errorf("reading response body: %w", err))
+	}
+
+	var errorResp ChatCompletionErrorResponse
+	if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Error.Message != "" {
+		return "", diagnostics.wrap(fmt.Errorf("API error: %s (Type: %s, Code: %s, Failed Generation: %s)",
+			errorResp.Error.Message,
+			errorResp.Error.Type,
+			errorResp.Error.Code,
+			errorResp.Error.FailedGeneration))
+	}
+
+	var completionResp ChatCompletionResponse
+	if err := json.Unmarshal(body, &completionResp); err != nil {
+		return "", diagnostics.wrap(fmt.Errorf("unmarshaling response: %w", err))
+	}
+	if diagnostics.RequestId == "" {
+		diagnostics.RequestId = completionResp.XGroq.ID
+	}
+	recordTokenUsage(ctx, p.name, p.model, p.price, completionResp.Usage.PromptTokens, completionResp.Usage.CompletionTokens)
+
+	if len(completionResp.Choices) == 0 {
+		return "", diagnostics.wrap(fmt.Errorf("no choices in response"))
+	}
+
+	return completionResp.Choices[0].Message.Content, nil
+}
+
+// openAIMessages returns the messages of a request, the content of the
+// messages with images being a list of parts, the text and the images as data
+// urls.
+func openAIMessages(messages []ChatMessage) []any {
+	result := make([]any, len(messages))
+	for i, message := range messages {
+		if len(message.Images) == 0 {
+			result[i] = message
+			continue
+		}
+		parts := []ChatCompletionContentPart{{Type: "text", Text: message.Content}}
+		for _, image := range message.Images {
+			parts = append(parts, ChatCompletionContentPart{Type: "image_url", ImageUrl: &ChatCompletionImageUrl{Url: image.dataUrl()}})
+		}
+		result[i] = struct {
+			Role    string                      `json:"role"`
+			Content []ChatCompletionContentPart `json:"content"`
+		}{message.Role, parts}
+	}
+	return result
+}
+
+// readStream reads an answer streamed as server-sent events, counting the
+// tokens received in the progress of the context.
+func (p *OpenAICompatibleProvider) readStream(ctx context.Context, body io.Reader, diagnostics LLMError) (string, error) {
+	progress := llmProgress(ctx)
+
+	var content strings.Builder
+	var usage *ChatCompletionUsage
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, found := strings.CutPrefix(scanner.Text(), "data:")
+		if !found {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", diagnostics.wrap(fmt.Errorf("unmarshaling stream chunk: %w", err))
+		}
+		if chunk.Error != nil {
+			return "", diagnostics.wrap(fmt.Errorf("API error: %s (Type: %s, Code: %s)", chunk.Error.Message, chunk.Error.Type, chunk.Error.Code))
+		}
+		if diagnostics.RequestId == "" {
+			diagnostics.RequestId = chunk.XGroq.ID
+		}
+		// A chunk holds about one token
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				progress.addTokens(1)
+			}
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		} else if chunk.XGroq.Usage != nil {
+			usage = chunk.XGroq.Usage
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", diagnostics.wrap(fmt.Errorf("reading response stream: %w", err))
+	}
+
+	if usage != nil {
+		recordTokenUsage(ctx, p.name, p.model, p.price, usage.PromptTokens, usage.CompletionTokens)
+	}
+	if content.Len() == 0 {
+		return "", diagnostics.wrap(fmt.Errorf("no content in response stream"))
+	}
+	return content.String(), nil
+}
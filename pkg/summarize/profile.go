@@ -0,0 +1,122 @@
+package summarize
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/brequet/report/internal/paths"
+)
+
+const (
+	// Profile of the embedded system prompt
+	DEFAULT_PROFILE        = "default"
+	PROFILES_FOLDER_NAME   = "profiles"
+	PROFILE_FILE_EXTENSION = ".md"
+)
+
+// The profiles are system prompts giving other summary styles, selected with
+// -profile. The embedded ones are found in profiles/, and the user's in the
+// profiles folder of the config directory, where they override the embedded
+// ones of the same name.
+//
+//go:embed profiles
+var profileFiles embed.FS
+
+// PromptProfile is a system prompt, embedded or written by the user.
+type PromptProfile struct {
+	Name string
+	// Path of the user's file, empty for the embedded profiles
+	Path string
+}
+
+// ProfilesDir returns the folder of the user's profiles.
+func ProfilesDir() (string, error) {
+	dir, err := paths.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, PROFILES_FOLDER_NAME), nil
+}
+
+// GetProfilePrompt returns the system prompt of a profile, the user's file of
+// that name, else the embedded one. The default profile is system-prompt.md,
+// unless the user has a default profile.
+func GetProfilePrompt(name string) (string, error) {
+	if name == "" {
+		name = DEFAULT_PROFILE
+	}
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid profile name '%s'", name)
+	}
+
+	if dir, err := ProfilesDir(); err == nil {
+		prompt, err := os.ReadFile(filepath.Join(dir, name+PROFILE_FILE_EXTENSION))
+		if err == nil {
+			if strings.TrimSpace(string(prompt)) == "" {
+				return "", fmt.Errorf("profile '%s' is empty", name)
+			}
+			return string(prompt), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("reading profile '%s': %w", name, err)
+		}
+	}
+
+	if name == DEFAULT_PROFILE {
+		return systemPrompt, nil
+	}
+	prompt, err := profileFiles.ReadFile(PROFILES_FOLDER_NAME + "/" + name + PROFILE_FILE_EXTENSION)
+	if err != nil {
+		profiles, _ := ListProfiles()
+		var names []string
+		for _, profile := range profiles {
+			names = append(names, profile.Name)
+		}
+		return "", fmt.Errorf("unknown profile '%s', expected one of %s", name, strings.Join(names, ", "))
+	}
+	return string(prompt), nil
+}
+
+// ListProfiles returns the embedded and user profiles, sorted by name, the
+// default one first.
+func ListProfiles() ([]PromptProfile, error) {
+	profiles := map[string]PromptProfile{DEFAULT_PROFILE: {Name: DEFAULT_PROFILE}}
+	entries, err := profileFiles.ReadDir(PROFILES_FOLDER_NAME)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), PROFILE_FILE_EXTENSION)
+		profiles[name] = PromptProfile{Name: name}
+	}
+
+	dir, err := ProfilesDir()
+	if err == nil {
+		entries, err = os.ReadDir(dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("reading profiles folder: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), PROFILE_FILE_EXTENSION) {
+				continue
+			}
+			name := strings.TrimSuffix(entry.Name(), PROFILE_FILE_EXTENSION)
+			profiles[name] = PromptProfile{Name: name, Path: filepath.Join(dir, entry.Name())}
+		}
+	}
+
+	list := []PromptProfile{profiles[DEFAULT_PROFILE]}
+	for name, profile := range profiles {
+		if name != DEFAULT_PROFILE {
+			list = append(list, profile)
+		}
+	}
+	sort.Slice(list[1:], func(i, j int) bool { return list[i+1].Name < list[j+1].Name })
+	return list, nil
+}
@@ -0,0 +1,111 @@
+package summarize
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Delay between two redraws of the progress line.
+const PROGRESS_REDRAW_INTERVAL = 100 * time.Millisecond
+
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// LLMProgress draws a progress line while an LLM call runs: a spinner, the
+// elapsed time and the number of tokens received. The providers stream their
+// answer when the context of the call has a progress, to count the tokens as
+// they arrive.
+type LLMProgress struct {
+	out io.Writer
+
+	mu      sync.Mutex
+	name    string
+	started time.Time
+	tokens  int
+	frame   int
+}
+
+type llmProgressKey struct{}
+
+// WithTerminalProgress adds a progress line to the context of the reports when
+// it is shown, the standard output is a terminal and the articles are
+// processed one at a time, the lines of concurrent articles being mixed up
+// otherwise.
+func WithTerminalProgress(ctx context.Context, show bool, concurrency int) context.Context {
+	if !show || !slog.Default().Enabled(context.Background(), slog.LevelInfo) || concurrency > 1 || !IsTerminal(os.Stdout) {
+		return ctx
+	}
+	return context.WithValue(ctx, llmProgressKey{}, &LLMProgress{out: os.Stdout})
+}
+
+// IsTerminal tells if a file is a terminal.
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// llmProgress returns the progress of the context, nil if it has none.
+func llmProgress(ctx context.Context) *LLMProgress {
+	progress, _ := ctx.Value(llmProgressKey{}).(*LLMProgress)
+	return progress
+}
+
+// start draws the progress of a call until the returned function is called,
+// which erases it.
+func (p *LLMProgress) start(name string) func() {
+	if p == nil {
+		return func() {}
+	}
+
+	p.mu.Lock()
+	p.name, p.started, p.tokens = name, time.Now(), 0
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(PROGRESS_REDRAW_INTERVAL)
+		defer ticker.Stop()
+		for {
+			p.draw()
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+}
+
+// addTokens counts the tokens received from a streamed answer.
+func (p *LLMProgress) addTokens(count int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.tokens += count
+	p.mu.Unlock()
+}
+
+func (p *LLMProgress) draw() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.frame = (p.frame + 1) % len(spinnerFrames)
+	line := fmt.Sprintf("%c Waiting for %s: %.1fs", spinnerFrames[p.frame], p.name, time.Since(p.started).Seconds())
+	if p.tokens > 0 {
+		line += fmt.Sprintf(", %d tokens received", p.tokens)
+	}
+	fmt.Fprint(p.out, "\r\033[K"+line)
+}
@@ -0,0 +1,35 @@
+package summarize
+
+import (
+	"context"
+	"time"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// RetryingProvider retries the calls to a provider failing for a transient
+// reason, before falling back on another provider if any, each call being
+// given at most timeout, if not 0.
+type RetryingProvider struct {
+	provider LLMProvider
+	retry    scrape.RetryPolicy
+	timeout  time.Duration
+}
+
+func (p *RetryingProvider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *RetryingProvider) Complete(ctx context.Context, messages []ChatMessage) (string, error) {
+	var content string
+	err := p.retry.Do(ctx, p.provider.Name()+" call", func() error {
+		attemptCtx, cancel := scrape.WithTimeout(ctx, p.timeout, p.provider.Name()+" call")
+		defer cancel()
+		defer llmProgress(ctx).start(p.provider.Name())()
+
+		var err error
+		content, err = p.provider.Complete(attemptCtx, messages)
+		return scrape.TimeoutCause(attemptCtx, err)
+	})
+	return content, err
+}
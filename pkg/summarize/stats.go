@@ -0,0 +1,87 @@
+package summarize
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ModelPrice is the price of a model, in USD per million tokens.
+type ModelPrice struct {
+	Input  float64
+	Output float64
+}
+
+// Prices of the default and usual models of the hosted providers, by model
+// name prefix, the dated versions of a model having its price. The models of
+// Ollama run locally and cost nothing.
+var modelPrices = map[string]ModelPrice{
+	"llama-3.1-8b-instant":    {Input: 0.05, Output: 0.08},
+	"llama-3.1-70b-versatile": {Input: 0.59, Output: 0.79},
+	"llama-3.3-70b-versatile": {Input: 0.59, Output: 0.79},
+	"gemma2-9b-it":            {Input: 0.20, Output: 0.20},
+	"mixtral-8x7b-32768":      {Input: 0.24, Output: 0.24},
+	"gpt-4o-mini":             {Input: 0.15, Output: 0.60},
+	"gpt-4o":                  {Input: 2.50, Output: 10.00},
+	"gpt-4.1-nano":            {Input: 0.10, Output: 0.40},
+	"gpt-4.1-mini":            {Input: 0.40, Output: 1.60},
+	"gpt-4.1":                 {Input: 2.00, Output: 8.00},
+	"claude-3-haiku":          {Input: 0.25, Output: 1.25},
+	"claude-3-5-haiku":        {Input: 0.80, Output: 4.00},
+	"claude-3-5-sonnet":       {Input: 3.00, Output: 15.00},
+	"claude-3-7-sonnet":       {Input: 3.00, Output: 15.00},
+	"claude-sonnet-4":         {Input: 3.00, Output: 15.00},
+	"claude-opus-4":           {Input: 15.00, Output: 75.00},
+}
+
+// price returns the price of a model of a provider, 0 when unknown, see
+// lookupModelPrice.
+func (o ProviderOptions) price(provider, model string) ModelPrice {
+	price, _ := LookupModelPrice(provider, model, o.Providers[provider])
+	return price
+}
+
+// LookupModelPrice returns the price of a model of a provider: the prices set for
+// the provider in its config, else the known price of the model. Unknown
+// models have no price.
+func LookupModelPrice(provider, model string, config ProviderConfig) (ModelPrice, bool) {
+	if config.InputPrice != nil || config.OutputPrice != nil {
+		var price ModelPrice
+		if config.InputPrice != nil {
+			price.Input = *config.InputPrice
+		}
+		if config.OutputPrice != nil {
+			price.Output = *config.OutputPrice
+		}
+		return price, true
+	}
+	if provider == "ollama" {
+		return ModelPrice{}, true
+	}
+
+	// The longest prefix, so that gpt-4o-mini is not priced as gpt-4o
+	found := ""
+	for name := range modelPrices {
+		if strings.HasPrefix(model, name) && len(name) > len(found) {
+			found = name
+		}
+	}
+	if found == "" {
+		return ModelPrice{}, false
+	}
+	return modelPrices[found], true
+}
+
+// cost returns the estimated cost of tokens, in USD.
+func (p ModelPrice) cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1e6
+}
+
+// FormatCost formats a cost in USD.
+func FormatCost(cost float64) string {
+	return fmt.Sprintf("$%.4f", cost)
+}
+
+// FormatUsage formats the tokens and the cost of a usage.
+func FormatUsage(usage Usage) string {
+	return fmt.Sprintf("%d input and %d output tokens, %s", usage.InputTokens, usage.OutputTokens, FormatCost(usage.Cost))
+}
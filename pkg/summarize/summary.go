@@ -0,0 +1,64 @@
+package summarize
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+//go:embed system-prompt.md
+var systemPrompt string
+
+// Summary is the summary of an article written by the LLM.
+type Summary struct {
+	Summary   string   `json:"summary"`
+	Keypoints []string `json:"keypoints"`
+	Tags      []string `json:"tags"`
+}
+
+// Summarize summarizes an article with a system prompt, in chunks when its
+// content is longer than chunkTokens, and checks the summary against the
+// constraints.
+func Summarize(ctx context.Context, provider LLMProvider, tokenizers *Tokenizers, article scrape.Article, systemPrompt string, constraints SummaryConstraints, chunkTokens int) (Summary, error) {
+	userContent, systemPrompt, err := reduceLongContent(ctx, provider, tokenizers, article.Content, systemPrompt, chunkTokens)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	if instructions := constraints.promptInstructions(); instructions != "" {
+		systemPrompt = strings.TrimSpace(systemPrompt) + "\n\n" + instructions
+	}
+
+	messages := []ChatMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userContent},
+	}
+
+	var articleSummary Summary
+	for attempt := 0; ; attempt++ {
+		articleSummary = Summary{}
+		messages, err = CompleteJson(ctx, provider, messages, &articleSummary)
+		if err != nil {
+			return Summary{}, fmt.Errorf("unmarshaling article summary: %w", err)
+		}
+
+		problems := constraints.violations(articleSummary)
+		if len(problems) == 0 {
+			break
+		}
+		if attempt == SUMMARY_MAX_REASKS {
+			slog.Warn("summary does not respect the constraints", "problems", strings.Join(problems, ", "))
+			break
+		}
+
+		messages = append(messages, ChatMessage{Role: "user", Content: strings.Join(problems, ", ") + ". Answer again with the complete JSON."})
+	}
+
+	constraints.trim(&articleSummary)
+
+	return articleSummary, nil
+}
@@ -0,0 +1,205 @@
+package summarize
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/brequet/report/internal/paths"
+)
+
+// Tokenizer counts the tokens of a text the way a model does, to cut long
+// articles into chunks the model can take.
+type Tokenizer interface {
+	Name() string
+	CountTokens(text string) int
+}
+
+const (
+	TOKENIZER_HEURISTIC     = "heuristic"
+	TOKENIZER_TIKTOKEN      = "tiktoken"
+	TOKENIZER_SENTENCEPIECE = "sentencepiece"
+)
+
+// defaultTokenizers are the tokenizers of the models with a public one, by
+// model pattern. The tokenizers of the config file take precedence.
+var defaultTokenizers = map[string]TokenizerConfig{
+	"gpt-4o*":           {Backend: TOKENIZER_TIKTOKEN, Encoding: "o200k_base"},
+	"gpt-4.1*":          {Backend: TOKENIZER_TIKTOKEN, Encoding: "o200k_base"},
+	"gpt-4.5*":          {Backend: TOKENIZER_TIKTOKEN, Encoding: "o200k_base"},
+	"gpt-5*":            {Backend: TOKENIZER_TIKTOKEN, Encoding: "o200k_base"},
+	"o1*":               {Backend: TOKENIZER_TIKTOKEN, Encoding: "o200k_base"},
+	"o3*":               {Backend: TOKENIZER_TIKTOKEN, Encoding: "o200k_base"},
+	"o4*":               {Backend: TOKENIZER_TIKTOKEN, Encoding: "o200k_base"},
+	"gpt-4*":            {Backend: TOKENIZER_TIKTOKEN, Encoding: "cl100k_base"},
+	"gpt-3.5*":          {Backend: TOKENIZER_TIKTOKEN, Encoding: "cl100k_base"},
+	"text-embedding-3*": {Backend: TOKENIZER_TIKTOKEN, Encoding: "cl100k_base"},
+}
+
+// TokenizerRule selects the tokenizer of the models matching a pattern, in
+// which * matches any text, e.g. gpt-4o* or llama3*.
+type TokenizerRule struct {
+	Pattern string
+	Config  TokenizerConfig
+	regex   *regexp.Regexp
+}
+
+func (c TokenizerConfig) validate() error {
+	switch c.Backend {
+	case TOKENIZER_HEURISTIC:
+	case TOKENIZER_TIKTOKEN:
+		if c.Encoding == "" && c.File == "" {
+			return fmt.Errorf("the %s tokenizer needs an encoding, e.g. %s, or a file", c.Backend, strings.Join(tiktokenEncodingNames(), ", "))
+		}
+		if c.Encoding != "" && c.File == "" {
+			if _, ok := tiktokenEncodings[c.Encoding]; !ok {
+				return fmt.Errorf("unknown %s encoding '%s', expected one of %s, or a file", c.Backend, c.Encoding, strings.Join(tiktokenEncodingNames(), ", "))
+			}
+		}
+	case TOKENIZER_SENTENCEPIECE:
+		if c.File == "" {
+			return fmt.Errorf("the %s tokenizer needs the file of its model, e.g. tokenizer.model", c.Backend)
+		}
+	default:
+		return fmt.Errorf("unknown tokenizer '%s', expected %s, %s or %s", c.Backend, TOKENIZER_HEURISTIC, TOKENIZER_TIKTOKEN, TOKENIZER_SENTENCEPIECE)
+	}
+	return nil
+}
+
+// CompileTokenizerRules returns the rules of the tokenizers, the most
+// specific patterns first, the configured ones before the default ones.
+func CompileTokenizerRules(configured map[string]TokenizerConfig) ([]TokenizerRule, error) {
+	compile := func(tokenizers map[string]TokenizerConfig) ([]TokenizerRule, error) {
+		var rules []TokenizerRule
+		for pattern, config := range tokenizers {
+			if err := config.validate(); err != nil {
+				return nil, fmt.Errorf("tokenizer of '%s': %w", pattern, err)
+			}
+			expression := "^" + strings.ReplaceAll(regexp.QuoteMeta(strings.ToLower(pattern)), `\*`, ".*") + "$"
+			rules = append(rules, TokenizerRule{Pattern: pattern, Config: config, regex: regexp.MustCompile(expression)})
+		}
+		sort.Slice(rules, func(i, j int) bool {
+			a, b := strings.ReplaceAll(rules[i].Pattern, "*", ""), strings.ReplaceAll(rules[j].Pattern, "*", "")
+			if len(a) != len(b) {
+				return len(a) > len(b)
+			}
+			return rules[i].Pattern < rules[j].Pattern
+		})
+		return rules, nil
+	}
+
+	rules, err := compile(configured)
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := compile(defaultTokenizers)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules, defaults...), nil
+}
+
+// Tokenizers picks the tokenizers of the models from rules, loading each
+// tokenizer once.
+type Tokenizers struct {
+	rules []TokenizerRule
+	mutex sync.Mutex
+	// Loaded tokenizers, by backend and encoding or file
+	loaded map[TokenizerConfig]Tokenizer
+}
+
+// NewTokenizers returns the tokenizers of the rules of the config file, by
+// model pattern, and of the default rules.
+func NewTokenizers(configured map[string]TokenizerConfig) (*Tokenizers, error) {
+	rules, err := CompileTokenizerRules(configured)
+	if err != nil {
+		return nil, err
+	}
+	return &Tokenizers{rules: rules, loaded: map[TokenizerConfig]Tokenizer{}}, nil
+}
+
+// tokenizerFor returns the tokenizer of a model, the heuristic one when no
+// rule matches the model or its tokenizer cannot be loaded, or without
+// tokenizers. A tokenizer is loaded, and possibly downloaded, without holding
+// the lock of the loaded tokenizers, so that the other models are not kept
+// waiting.
+func (t *Tokenizers) tokenizerFor(ctx context.Context, model string) Tokenizer {
+	if t == nil {
+		return HeuristicTokenizer{}
+	}
+	config := TokenizerConfig{Backend: TOKENIZER_HEURISTIC}
+	for _, rule := range t.rules {
+		if rule.regex.MatchString(strings.ToLower(model)) {
+			config = rule.Config
+			break
+		}
+	}
+	if config.Backend == TOKENIZER_HEURISTIC {
+		return HeuristicTokenizer{}
+	}
+
+	t.mutex.Lock()
+	tokenizer, ok := t.loaded[config]
+	t.mutex.Unlock()
+	if ok {
+		return tokenizer
+	}
+
+	var err error
+	switch config.Backend {
+	case TOKENIZER_TIKTOKEN:
+		tokenizer, err = loadTiktokenTokenizer(ctx, config)
+	case TOKENIZER_SENTENCEPIECE:
+		tokenizer, err = loadSentencePieceTokenizer(paths.ExpandHome(config.File))
+	}
+	if err != nil {
+		slog.Warn("could not load the tokenizer of the model, estimating its tokens from the length of the text", "model", model, "tokenizer", config.Backend, "err", err)
+		tokenizer = HeuristicTokenizer{}
+		// Loaded again by the next article when the download was interrupted
+		if ctx.Err() != nil {
+			return tokenizer
+		}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	// Another article may have loaded it in the meantime
+	if loaded, ok := t.loaded[config]; ok {
+		return loaded
+	}
+	// Cached even when it failed, to warn once
+	t.loaded[config] = tokenizer
+	return tokenizer
+}
+
+// providerTokenizer returns the tokenizer of the model of a provider, the
+// first one of a list of providers.
+func (t *Tokenizers) providerTokenizer(ctx context.Context, provider LLMProvider) Tokenizer {
+	return t.tokenizerFor(ctx, ProviderModel(provider))
+}
+
+// HeuristicTokenizer estimates the number of tokens of a text from its
+// length, for the models without a known tokenizer. The ideographs and the
+// kana and hangul syllables are about a token each.
+type HeuristicTokenizer struct{}
+
+func (HeuristicTokenizer) Name() string {
+	return TOKENIZER_HEURISTIC
+}
+
+func (HeuristicTokenizer) CountTokens(text string) int {
+	wide, other := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			wide++
+		} else {
+			other++
+		}
+	}
+	return wide + (other+CHARS_PER_TOKEN-1)/CHARS_PER_TOKEN
+}
@@ -0,0 +1,268 @@
+package summarize
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Types of the models and of the pieces of sentencepiece, in its model files.
+const (
+	SENTENCEPIECE_UNIGRAM = 1
+	SENTENCEPIECE_BPE     = 2
+
+	SENTENCEPIECE_NORMAL       = 1
+	SENTENCEPIECE_USER_DEFINED = 4
+	SENTENCEPIECE_BYTE         = 6
+
+	// Marker of the spaces in the pieces
+	SENTENCEPIECE_SPACE = "▁"
+)
+
+// SentencePieceTokenizer counts the tokens of the unigram and BPE models of
+// sentencepiece, such as the ones of Llama 2, Mistral or Gemma, read from
+// their tokenizer.model file. The text is not NFKC normalized, which only
+// changes the count of unusual characters.
+type SentencePieceTokenizer struct {
+	name      string
+	modelType int
+	scores    map[string]float32
+	// Length of the longest piece, in runes
+	maxPieceLength int
+	// Characters missing from the pieces count a token per byte instead of
+	// an unknown token
+	byteFallback   bool
+	addDummyPrefix bool
+	// Whether runs of spaces are collapsed and the text trimmed
+	removeExtraWhitespaces bool
+	unknownScore           float32
+}
+
+// loadSentencePieceTokenizer reads a sentencepiece model file, a ModelProto
+// protocol buffer.
+func loadSentencePieceTokenizer(path string) (*SentencePieceTokenizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading tokenizer: %w", err)
+	}
+	tokenizer := &SentencePieceTokenizer{
+		name:                   TOKENIZER_SENTENCEPIECE + ":" + filepath.Base(path),
+		modelType:              SENTENCEPIECE_UNIGRAM,
+		scores:                 map[string]float32{},
+		addDummyPrefix:         true,
+		removeExtraWhitespaces: true,
+	}
+	minScore := float32(0)
+	err = readProtobuf(data, func(field int, value uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			// SentencePiece: piece, score and type
+			var piece string
+			var score float32
+			pieceType := uint64(SENTENCEPIECE_NORMAL)
+			if err := readProtobuf(bytes, func(field int, value uint64, bytes []byte) error {
+				switch field {
+				case 1:
+					piece = string(bytes)
+				case 2:
+					score = math.Float32frombits(uint32(value))
+				case 3:
+					pieceType = value
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			switch pieceType {
+			case SENTENCEPIECE_NORMAL, SENTENCEPIECE_USER_DEFINED:
+				tokenizer.scores[piece] = score
+				tokenizer.maxPieceLength = max(tokenizer.maxPieceLength, utf8.RuneCountInString(piece))
+				minScore = min(minScore, score)
+			case SENTENCEPIECE_BYTE:
+				tokenizer.byteFallback = true
+			}
+		case 2:
+			// TrainerSpec: model type
+			return readProtobuf(bytes, func(field int, value uint64, bytes []byte) error {
+				if field == 3 {
+					tokenizer.modelType = int(value)
+				}
+				return nil
+			})
+		case 3:
+			// NormalizerSpec: dummy prefix and whitespaces
+			return readProtobuf(bytes, func(field int, value uint64, bytes []byte) error {
+				switch field {
+				case 3:
+					tokenizer.addDummyPrefix = value != 0
+				case 4:
+					tokenizer.removeExtraWhitespaces = value != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading tokenizer '%s': %w", path, err)
+	}
+	if len(tokenizer.scores) == 0 {
+		return nil, fmt.Errorf("reading tokenizer '%s': no piece", path)
+	}
+	if tokenizer.modelType != SENTENCEPIECE_UNIGRAM && tokenizer.modelType != SENTENCEPIECE_BPE {
+		return nil, fmt.Errorf("reading tokenizer '%s': unsupported model type %d, expected unigram or BPE", path, tokenizer.modelType)
+	}
+	// As sentencepiece, an unknown character costs more than any piece
+	tokenizer.unknownScore = minScore - 10
+	slog.Debug("Tokenizer loaded", "tokenizer", TOKENIZER_SENTENCEPIECE, "path", path, "pieces", len(tokenizer.scores))
+	return tokenizer, nil
+}
+
+// readProtobuf calls fn for each field of a protocol buffer message, with
+// the value of the varint and fixed size fields, and the bytes of the length
+// delimited ones.
+func readProtobuf(data []byte, fn func(field int, value uint64, bytes []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("invalid protocol buffer field")
+		}
+		data = data[n:]
+		field := int(key >> 3)
+		var value uint64
+		var bytes []byte
+		switch key & 7 {
+		case 0:
+			if value, n = binary.Uvarint(data); n <= 0 {
+				return fmt.Errorf("invalid varint of field %d", field)
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return fmt.Errorf("truncated field %d", field)
+			}
+			value, data = binary.LittleEndian.Uint64(data), data[8:]
+		case 2:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return fmt.Errorf("truncated field %d", field)
+			}
+			bytes, data = data[n:n+int(length)], data[n+int(length):]
+		case 5:
+			if len(data) < 4 {
+				return fmt.Errorf("truncated field %d", field)
+			}
+			value, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d of field %d", key&7, field)
+		}
+		if err := fn(field, value, bytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *SentencePieceTokenizer) Name() string {
+	return t.name
+}
+
+// CountTokens encodes the words of the text separately, the pieces of the
+// models seldom spanning several words.
+func (t *SentencePieceTokenizer) CountTokens(text string) int {
+	if t.removeExtraWhitespaces {
+		text = strings.Join(strings.Fields(text), " ")
+	}
+	if text == "" {
+		return 0
+	}
+	if t.addDummyPrefix {
+		text = " " + text
+	}
+	text = strings.ReplaceAll(text, " ", SENTENCEPIECE_SPACE)
+
+	count := 0
+	for len(text) > 0 {
+		end := strings.Index(text[len(SENTENCEPIECE_SPACE):], SENTENCEPIECE_SPACE)
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += len(SENTENCEPIECE_SPACE)
+		}
+		word := []rune(text[:end])
+		text = text[end:]
+		if t.modelType == SENTENCEPIECE_BPE {
+			count += t.countBpe(word)
+		} else {
+			count += t.countUnigram(word)
+		}
+	}
+	return count
+}
+
+// unknownCount is the number of tokens of a character missing from the
+// pieces.
+func (t *SentencePieceTokenizer) unknownCount(r rune) int {
+	if t.byteFallback {
+		return utf8.RuneLen(r)
+	}
+	return 1
+}
+
+// countUnigram counts the pieces of the segmentation of a word of the highest
+// score, found with the Viterbi algorithm.
+func (t *SentencePieceTokenizer) countUnigram(word []rune) int {
+	scores := make([]float32, len(word)+1)
+	counts := make([]int, len(word)+1)
+	for end := 1; end <= len(word); end++ {
+		// An unknown character, replaced by a known piece when there is one
+		scores[end] = scores[end-1] + t.unknownScore
+		counts[end] = counts[end-1] + t.unknownCount(word[end-1])
+		for start := max(0, end-t.maxPieceLength); start < end; start++ {
+			score, ok := t.scores[string(word[start:end])]
+			if ok && scores[start]+score > scores[end] {
+				scores[end] = scores[start] + score
+				counts[end] = counts[start] + 1
+			}
+		}
+	}
+	return counts[len(word)]
+}
+
+// countBpe counts the pieces of a word after merging its characters, the
+// pair making the piece of the highest score first.
+func (t *SentencePieceTokenizer) countBpe(word []rune) int {
+	parts := make([]string, len(word))
+	for i, r := range word {
+		parts[i] = string(r)
+	}
+	for len(parts) > 1 {
+		best, bestScore := -1, float32(0)
+		for i := 0; i < len(parts)-1; i++ {
+			if score, ok := t.scores[parts[i]+parts[i+1]]; ok && (best < 0 || score > bestScore) {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+
+	count := 0
+	for _, part := range parts {
+		if _, ok := t.scores[part]; ok {
+			count++
+		} else {
+			r, _ := utf8.DecodeRuneInString(part)
+			count += t.unknownCount(r)
+		}
+	}
+	return count
+}
@@ -0,0 +1,206 @@
+package summarize
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/brequet/report/internal/paths"
+)
+
+// tiktokenRanks writes the tokens as a rank file of tiktoken, ranked in
+// order.
+func tiktokenRanks(tokens ...string) []byte {
+	var sb strings.Builder
+	for rank, token := range tokens {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+	}
+	return []byte(sb.String())
+}
+
+func TestParseTiktokenRanks(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]int
+		wantErr bool
+	}{
+		{"ranks", string(tiktokenRanks("a", "b", "ab", " a")), map[string]int{"a": 0, "b": 1, "ab": 2, " a": 3}, false},
+		{"blank lines", "YQ== 0\n\nYg== 1\n", map[string]int{"a": 0, "b": 1}, false},
+		{"missing rank", "YQ==\n", nil, true},
+		{"invalid base64", "!!! 0\n", nil, true},
+		{"invalid rank", "YQ== first\n", nil, true},
+		{"empty file", "", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ranks, err := parseTiktokenRanks([]byte(test.data))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseTiktokenRanks error = %v, want an error: %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(ranks, test.want) {
+				t.Errorf("parseTiktokenRanks = %v, want %v", ranks, test.want)
+			}
+		})
+	}
+}
+
+func TestSplitTiktokenPieces(t *testing.T) {
+	cl100k := regexp.MustCompile(`^(?:` + CL100K_PATTERN + `)`)
+	o200k := regexp.MustCompile(`^(?:` + O200K_PATTERN + `)`)
+	tests := []struct {
+		name    string
+		pattern *regexp.Regexp
+		text    string
+		want    []string
+	}{
+		{"words", cl100k, "hello world", []string{"hello", " world"}},
+		{"contractions", cl100k, "I'm sure they'LL go", []string{"I", "'m", " sure", " they", "'LL", " go"}},
+		{"numbers by three digits", cl100k, "year 20245", []string{"year", " ", "202", "45"}},
+		{"punctuation", cl100k, "Hi!! (ok)", []string{"Hi", "!!", " (", "ok", ")"}},
+		{"spaces before a word", cl100k, "a   b", []string{"a", "  ", " b"}},
+		{"trailing spaces", cl100k, "a   ", []string{"a", "   "}},
+		{"new lines", cl100k, "one\n\ntwo", []string{"one", "\n\n", "two"}},
+		{"spaces before new lines", cl100k, "one  \n two", []string{"one", "  \n", " two"}},
+		{"non-latin letters", cl100k, "お誕生日 おめでとう", []string{"お誕生日", " おめでとう"}},
+		{"o200k case split", o200k, "HelloWorld isOK", []string{"Hello", "World", " is", "OK"}},
+		{"o200k contraction", o200k, "they're", []string{"they're"}},
+		{"o200k slashes", o200k, "a/b//\nc", []string{"a", "/b", "//\n", "c"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pieces := splitTiktokenPieces(test.pattern, test.text)
+			if !reflect.DeepEqual(pieces, test.want) {
+				t.Errorf("splitTiktokenPieces(%q) = %q, want %q", test.text, pieces, test.want)
+			}
+			if joined := strings.Join(pieces, ""); joined != test.text {
+				t.Errorf("splitTiktokenPieces(%q) joined = %q, want the text", test.text, joined)
+			}
+		})
+	}
+}
+
+func TestBytePairEncode(t *testing.T) {
+	ranks, err := parseTiktokenRanks(tiktokenRanks("l", "o", "w", "e", "r", " ", "lo", "low", "er", " low", "lower"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		piece string
+		want  []string
+	}{
+		{"low", []string{"low"}},
+		{"lower", []string{"lower"}},
+		{"lowe", []string{"low", "e"}},
+		{" lower", []string{" low", "er"}},
+		{"rol", []string{"r", "o", "l"}},
+		{"wow", []string{"w", "o", "w"}},
+		{"é", []string{"\xc3", "\xa9"}},
+	}
+	for _, test := range tests {
+		t.Run(test.piece, func(t *testing.T) {
+			if parts := bytePairEncode(test.piece, ranks); !reflect.DeepEqual(parts, test.want) {
+				t.Errorf("bytePairEncode(%q) = %q, want %q", test.piece, parts, test.want)
+			}
+		})
+	}
+}
+
+func TestTiktokenTokenizerRankFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tiny.tiktoken")
+	if err := os.WriteFile(path, tiktokenRanks("h", "e", "l", "o", " ", "w", "r", "d", "he", "ll", "hell", "hello", " w", " wor", "or", "ld"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tokenizer, err := loadTiktokenTokenizer(context.Background(), TokenizerConfig{Backend: TOKENIZER_TIKTOKEN, File: path})
+	if err != nil {
+		t.Fatalf("loadTiktokenTokenizer: %v", err)
+	}
+	if name := tokenizer.Name(); name != "tiktoken:tiny.tiktoken" {
+		t.Errorf("Name = %q, want %q", name, "tiktoken:tiny.tiktoken")
+	}
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello", 1},
+		{"hello world", 3},
+		{"hello  hello", 4},
+		{"hell", 1},
+		{"held", 2},
+	}
+	for _, test := range tests {
+		if count := tokenizer.CountTokens(test.text); count != test.want {
+			t.Errorf("CountTokens(%q) = %d, want %d", test.text, count, test.want)
+		}
+	}
+}
+
+// TestTiktokenKnownCounts checks the counts of the public encodings against
+// those of tiktoken. The rank files are read from the cache folder, where the
+// tool downloads them, the test being skipped when they are missing.
+func TestTiktokenKnownCounts(t *testing.T) {
+	tests := []struct {
+		encoding string
+		text     string
+		want     int
+	}{
+		{"cl100k_base", "hello world", 2},
+		{"cl100k_base", "tiktoken is great!", 6},
+		{"cl100k_base", "antidisestablishmentarianism", 6},
+		{"cl100k_base", "2 + 2 = 4", 7},
+		{"cl100k_base", "お誕生日おめでとう", 9},
+		{"o200k_base", "hello world", 2},
+		{"o200k_base", "2 + 2 = 4", 7},
+	}
+	dir, err := paths.CacheDir()
+	if err != nil {
+		t.Skipf("no cache folder: %v", err)
+	}
+	tokenizers := map[string]*TiktokenTokenizer{}
+	for _, test := range tests {
+		t.Run(test.encoding+"/"+test.text, func(t *testing.T) {
+			tokenizer, ok := tokenizers[test.encoding]
+			if !ok {
+				path := filepath.Join(dir, TIKTOKEN_CACHE_FOLDER, test.encoding+".tiktoken")
+				if _, err := os.Stat(path); err != nil {
+					t.Skipf("no rank file: %v", err)
+				}
+				if tokenizer, err = loadTiktokenTokenizer(context.Background(), TokenizerConfig{Backend: TOKENIZER_TIKTOKEN, Encoding: test.encoding, File: path}); err != nil {
+					t.Fatalf("loadTiktokenTokenizer: %v", err)
+				}
+				tokenizers[test.encoding] = tokenizer
+			}
+			if count := tokenizer.CountTokens(test.text); count != test.want {
+				t.Errorf("CountTokens(%q) = %d, want %d", test.text, count, test.want)
+			}
+		})
+	}
+}
+
+func TestHeuristicTokenizer(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abc", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"hello world", 3},
+		{"お誕生日", 4},
+		{"日本 is", 3},
+		{"한국어", 3},
+	}
+	for _, test := range tests {
+		if count := (HeuristicTokenizer{}).CountTokens(test.text); count != test.want {
+			t.Errorf("CountTokens(%q) = %d, want %d", test.text, count, test.want)
+		}
+	}
+}
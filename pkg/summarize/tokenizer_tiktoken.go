@@ -0,0 +1,247 @@
+package summarize
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/brequet/report/internal/paths"
+	"github.com/brequet/report/pkg/scrape"
+)
+
+const (
+	TIKTOKEN_ENCODINGS_URL = "https://openaipublic.blob.core.windows.net/encodings/"
+	TIKTOKEN_CACHE_FOLDER  = "tokenizers"
+	TIKTOKEN_FETCH_TIMEOUT = 2 * time.Minute
+)
+
+// Splitting patterns of the text into pieces before their byte pair encoding,
+// as in tiktoken. Go has no lookahead, the "\s+(?!\S)" alternative of
+// tiktoken being handled by splitTiktokenPieces.
+const (
+	CL100K_PATTERN = `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`
+	O200K_PATTERN  = `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+`
+)
+
+// tiktokenEncodings are the public encodings of OpenAI, by name, and their
+// splitting pattern. The files of other encodings, such as the one of Llama
+// 3, are split like cl100k_base.
+var tiktokenEncodings = map[string]string{
+	"cl100k_base": CL100K_PATTERN,
+	"o200k_base":  O200K_PATTERN,
+}
+
+func tiktokenEncodingNames() []string {
+	names := make([]string, 0, len(tiktokenEncodings))
+	for name := range tiktokenEncodings {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// TiktokenTokenizer is a byte pair encoding tokenizer reading the rank files
+// of tiktoken: a base64 encoded token and its rank per line.
+type TiktokenTokenizer struct {
+	name    string
+	ranks   map[string]int
+	pattern *regexp.Regexp
+}
+
+// loadTiktokenTokenizer reads the rank file of a tokenizer, downloading the
+// public encodings of OpenAI in the cache folder the first time.
+func loadTiktokenTokenizer(ctx context.Context, config TokenizerConfig) (*TiktokenTokenizer, error) {
+	pattern := CL100K_PATTERN
+	if encodingPattern, ok := tiktokenEncodings[config.Encoding]; ok {
+		pattern = encodingPattern
+	}
+	path := paths.ExpandHome(config.File)
+	if path == "" {
+		var err error
+		if path, err = fetchTiktokenEncoding(ctx, config.Encoding); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading tokenizer: %w", err)
+	}
+	ranks, err := parseTiktokenRanks(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading tokenizer '%s': %w", path, err)
+	}
+	slog.Debug("Tokenizer loaded", "tokenizer", TOKENIZER_TIKTOKEN, "path", path, "tokens", len(ranks))
+	return &TiktokenTokenizer{name: TOKENIZER_TIKTOKEN + ":" + cmp.Or(config.Encoding, filepath.Base(path)), ranks: ranks, pattern: regexp.MustCompile(`^(?:` + pattern + `)`)}, nil
+}
+
+// fetchTiktokenEncoding returns the path of the rank file of a public
+// encoding in the cache folder, downloading it if missing.
+func fetchTiktokenEncoding(ctx context.Context, encoding string) (string, error) {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, TIKTOKEN_CACHE_FOLDER, encoding+".tiktoken")
+	if _, err := os.Stat(path); err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return path, err
+	}
+
+	slog.Info("Downloading tokenizer", "encoding", encoding)
+	ctx, cancel := context.WithTimeout(ctx, TIKTOKEN_FETCH_TIMEOUT)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, TIKTOKEN_ENCODINGS_URL+encoding+".tiktoken", nil)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	res, err := (&http.Client{Transport: scrape.LoggingTransport{}}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("downloading tokenizer: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("downloading tokenizer: status %s", res.Status)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("downloading tokenizer: %w", err)
+	}
+	// Checked before it is kept, a truncated file being worse than none
+	if _, err := parseTiktokenRanks(data); err != nil {
+		return "", fmt.Errorf("downloading tokenizer: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return "", fmt.Errorf("creating cache folder: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("writing tokenizer: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("writing tokenizer: %w", err)
+	}
+	return path, nil
+}
+
+func parseTiktokenRanks(data []byte) (map[string]int, error) {
+	ranks := map[string]int{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := bytes.Fields(scanner.Bytes())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a token and its rank", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(string(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rank, err := strconv.Atoi(string(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("no token")
+	}
+	return ranks, nil
+}
+
+func (t *TiktokenTokenizer) Name() string {
+	return t.name
+}
+
+func (t *TiktokenTokenizer) CountTokens(text string) int {
+	count := 0
+	for _, piece := range splitTiktokenPieces(t.pattern, text) {
+		if _, ok := t.ranks[piece]; ok {
+			count++
+			continue
+		}
+		count += len(bytePairEncode(piece, t.ranks))
+	}
+	return count
+}
+
+// splitTiktokenPieces splits a text with the pattern of an encoding. The
+// whitespace before a word, which does not end a line, leaves its last space
+// to the word, as the "\s+(?!\S)" alternative of tiktoken does.
+func splitTiktokenPieces(pattern *regexp.Regexp, text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		loc := pattern.FindStringIndex(text)
+		_, end := utf8.DecodeRuneInString(text)
+		if loc != nil && loc[1] > 0 {
+			end = loc[1]
+		}
+		piece := text[:end]
+		if end > 1 && end < len(text) && isAllSpace(piece) && !strings.HasSuffix(piece, "\n") && !strings.HasSuffix(piece, "\r") {
+			// Whitespace followed by a word: the last space goes with the word
+			end--
+			piece = text[:end]
+		}
+		pieces = append(pieces, piece)
+		text = text[end:]
+	}
+	return pieces
+}
+
+// isAllSpace tells whether a text is only made of the ASCII whitespace the
+// \s of the patterns matches.
+func isAllSpace(s string) bool {
+	return strings.Trim(s, " \t\n\r\f\v") == ""
+}
+
+// bytePairEncode splits a piece into the tokens of the ranks, merging the
+// pair of adjacent parts of the lowest rank first, starting from its bytes.
+func bytePairEncode(piece string, ranks map[string]int) []string {
+	parts := make([]string, len(piece))
+	for i := 0; i < len(piece); i++ {
+		parts[i] = piece[i : i+1]
+	}
+	for len(parts) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i < len(parts)-1; i++ {
+			if rank, ok := ranks[parts[i]+parts[i+1]]; ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	return parts
+}
@@ -0,0 +1,80 @@
+package summarize
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Usage counts the articles summarized, the tokens consumed and their
+// estimated cost.
+type Usage struct {
+	Articles     int `json:"articles"`
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	// Estimated cost in USD, from the prices of the models (see
+	// ProviderOptions.price)
+	Cost float64 `json:"cost,omitempty"`
+}
+
+// Add adds another usage to the usage.
+func (u *Usage) Add(other Usage) {
+	u.Articles += other.Articles
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.Cost += other.Cost
+}
+
+// UsageRecorder accumulates the tokens consumed by the LLM calls made with a
+// context, the providers recording the usage reported by their API. Usage is
+// also recorded by the recorders of the parent contexts.
+type UsageRecorder struct {
+	mu    sync.Mutex
+	usage Usage
+	// Usage by model, as provider/model
+	models map[string]Usage
+	parent *UsageRecorder
+}
+
+type usageRecorderKey struct{}
+
+// WithUsageRecorder returns a context recording the usage of the LLM calls
+// made with it, and its recorder.
+func WithUsageRecorder(ctx context.Context) (context.Context, *UsageRecorder) {
+	parent, _ := ctx.Value(usageRecorderKey{}).(*UsageRecorder)
+	recorder := &UsageRecorder{parent: parent, models: map[string]Usage{}}
+	return context.WithValue(ctx, usageRecorderKey{}, recorder), recorder
+}
+
+// recordTokenUsage records the tokens of an LLM call to a model of a
+// provider, and their estimated cost at the price of the model.
+func recordTokenUsage(ctx context.Context, provider, model string, price ModelPrice, inputTokens, outputTokens int) {
+	usage := Usage{InputTokens: inputTokens, OutputTokens: outputTokens, Cost: price.cost(inputTokens, outputTokens)}
+	slog.Debug("LLM call", "provider", provider, "model", model, "input_tokens", inputTokens, "output_tokens", outputTokens)
+	recorder, _ := ctx.Value(usageRecorderKey{}).(*UsageRecorder)
+	for ; recorder != nil; recorder = recorder.parent {
+		recorder.mu.Lock()
+		recorder.usage.Add(usage)
+		modelUsage := recorder.models[provider+"/"+model]
+		modelUsage.Add(usage)
+		recorder.models[provider+"/"+model] = modelUsage
+		recorder.mu.Unlock()
+	}
+}
+
+func (r *UsageRecorder) Usage() Usage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.usage
+}
+
+// ModelUsage returns the usage by model, as provider/model.
+func (r *UsageRecorder) ModelUsage() map[string]Usage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	models := map[string]Usage{}
+	for model, usage := range r.models {
+		models[model] = usage
+	}
+	return models
+}
@@ -0,0 +1,116 @@
+package summarize
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/brequet/report/pkg/scrape"
+)
+
+// Images larger than this are not sent to the vision model, the APIs
+// rejecting them.
+const MAX_DESCRIBED_IMAGE_SIZE = 5 * 1024 * 1024
+
+//go:embed image-prompt.md
+var imagePrompt string
+
+// Media types of the images the vision models read
+var visionMediaTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// ImageDescription is the description of an image of an article, written by
+// a vision model (see -describe-images).
+type ImageDescription struct {
+	Url string `json:"url"`
+	// Path of the downloaded copy, see ArticleImage
+	Path        string `json:"path,omitempty"`
+	Description string `json:"description"`
+}
+
+// ImageDescriptionResult is the answer of the LLM describing an image.
+type ImageDescriptionResult struct {
+	Description string `json:"description"`
+}
+
+// NewVisionProvider creates the provider describing the images: the first
+// provider of the comma separated list of names, with the model given, the
+// fallbacks not serving it.
+func NewVisionProvider(providerNames, model string, options ProviderOptions) (LLMProvider, error) {
+	name, _, _ := strings.Cut(providerNames, ",")
+	provider, err := LLMProviders[strings.TrimSpace(name)](model, options)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s vision provider: %w", strings.TrimSpace(name), err)
+	}
+	return options.wrap(provider), nil
+}
+
+// DescribeArticleImages asks the vision model for a short description of the
+// first count images of an article. The images that cannot be downloaded are
+// skipped, and the images left once the model fails are not described, the
+// model likely not reading images.
+func DescribeArticleImages(ctx context.Context, scraper *scrape.Scraper, provider LLMProvider, article scrape.Article, count int) []ImageDescription {
+	var descriptions []ImageDescription
+	for _, image := range article.Images {
+		if len(descriptions) == count || ctx.Err() != nil {
+			break
+		}
+		description, err := describeImage(ctx, scraper, provider, article, image)
+		var llmErr *LLMError
+		if errors.As(err, &llmErr) || errors.Is(err, ErrInvalidSummaryJSON) {
+			slog.Warn("could not describe the images of the article", "url", article.Url, "provider", provider.Name(), "err", err)
+			break
+		}
+		if err != nil {
+			slog.Warn("could not describe the image", "url", image.Url, "err", err)
+			continue
+		}
+		descriptions = append(descriptions, ImageDescription{Url: image.Url, Description: description})
+	}
+	slog.Debug("Images described", "url", article.Url, "images", len(article.Images), "described", len(descriptions))
+	return descriptions
+}
+
+// describeImage downloads an image with the scraper and returns its
+// description by the vision model.
+func describeImage(ctx context.Context, scraper *scrape.Scraper, provider LLMProvider, article scrape.Article, image scrape.ArticleImage) (string, error) {
+	var fetched scrape.FetchedPage
+	err := scraper.Retry.Do(ctx, "fetching "+image.Url, func() error {
+		var err error
+		fetched, err = scraper.FetchResponse(ctx, image.Url, scraper.Credentials.Identify, func(mediaType string) bool {
+			return visionMediaTypes[mediaType]
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(fetched.Body) > MAX_DESCRIBED_IMAGE_SIZE {
+		return "", fmt.Errorf("image of %d bytes, larger than %d bytes", len(fetched.Body), MAX_DESCRIBED_IMAGE_SIZE)
+	}
+
+	content := "# " + article.Title
+	if image.Alt != "" {
+		content += "\n\nAlt text of the image: " + image.Alt
+	}
+	var result ImageDescriptionResult
+	_, err = CompleteJson(ctx, provider, []ChatMessage{
+		{Role: "system", Content: imagePrompt},
+		{Role: "user", Content: content, Images: []ChatImage{{MediaType: fetched.MediaType, Data: fetched.Body}}},
+	}, &result)
+	if err != nil {
+		return "", err
+	}
+	description := strings.Join(strings.Fields(result.Description), " ")
+	if description == "" {
+		return "", fmt.Errorf("empty description")
+	}
+	return description, nil
+}